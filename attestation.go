@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Types of the in-toto statements of --attestation, see https://slsa.dev/provenance/v1
+const (
+	inTotoStatementType = "https://in-toto.io/Statement/v1"
+	slsaProvenanceType  = "https://slsa.dev/provenance/v1"
+	// attestationBuildType is the type of the generations described by the attestation
+	attestationBuildType = "https://github.com/alexandre-normand/safekeeper/generate@v1"
+	attestationBuilderID = "https://github.com/alexandre-normand/safekeeper"
+)
+
+// attestationBundleExt is the extension of the cosign bundle of a signed attestation, next to it
+const attestationBundleExt = ".bundle"
+
+// inTotoStatement is the attestation of the files generated by a run, with SLSA provenance as predicate. It
+// never has values, only the names of the keys.
+type inTotoStatement struct {
+	Type          string          `json:"_type"`
+	Subject       []inTotoSubject `json:"subject"`
+	PredicateType string          `json:"predicateType"`
+	Predicate     slsaProvenance  `json:"predicate"`
+}
+
+// inTotoSubject is a generated file, by its path and digest
+type inTotoSubject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+type slsaProvenance struct {
+	BuildDefinition slsaBuildDefinition `json:"buildDefinition"`
+	RunDetails      slsaRunDetails      `json:"runDetails"`
+}
+
+type slsaBuildDefinition struct {
+	BuildType          string             `json:"buildType"`
+	ExternalParameters attestedParameters `json:"externalParameters"`
+	// ResolvedDependencies are the templates, by path and digest
+	ResolvedDependencies []slsaResourceDescriptor `json:"resolvedDependencies"`
+}
+
+// attestedParameters are the files generated by the run with the keys substituted in each
+type attestedParameters struct {
+	Files []attestedFile `json:"files"`
+}
+
+type attestedFile struct {
+	Template string `json:"template"`
+	Output   string `json:"output"`
+	// Keys are the keys substituted in the file, by name
+	Keys []string `json:"keys"`
+}
+
+type slsaResourceDescriptor struct {
+	URI    string            `json:"uri"`
+	Digest map[string]string `json:"digest"`
+}
+
+type slsaRunDetails struct {
+	Builder  slsaBuilder   `json:"builder"`
+	Metadata *slsaMetadata `json:"metadata,omitempty"`
+}
+
+type slsaBuilder struct {
+	ID      string            `json:"id"`
+	Version map[string]string `json:"version,omitempty"`
+}
+
+type slsaMetadata struct {
+	StartedOn  string `json:"startedOn"`
+	FinishedOn string `json:"finishedOn"`
+}
+
+// digestSet returns the in-toto digest set of the content hash of a manifest file, e.g. sha256:<hex>
+func digestSet(hash string) map[string]string {
+	algorithm, digest := "sha256", hash
+	if i := strings.IndexByte(hash, ':'); i >= 0 {
+		algorithm, digest = hash[:i], hash[i+1:]
+	}
+	return map[string]string{algorithm: digest}
+}
+
+// attestation returns the in-toto statement of the files generated by g from start to end, without the times of
+// the run when reproducible
+func (g *generation) attestation(start time.Time, end time.Time) inTotoStatement {
+	files := append([]manifestFile{}, g.manifestFiles...)
+	sort.Slice(files, func(i, j int) bool { return files[i].Output < files[j].Output })
+
+	statement := inTotoStatement{
+		Type:          inTotoStatementType,
+		Subject:       []inTotoSubject{},
+		PredicateType: slsaProvenanceType,
+		Predicate: slsaProvenance{
+			BuildDefinition: slsaBuildDefinition{
+				BuildType:            attestationBuildType,
+				ExternalParameters:   attestedParameters{Files: []attestedFile{}},
+				ResolvedDependencies: []slsaResourceDescriptor{},
+			},
+			RunDetails: slsaRunDetails{Builder: slsaBuilder{ID: attestationBuilderID}},
+		},
+	}
+	if g.version != "" {
+		statement.Predicate.RunDetails.Builder.Version = map[string]string{"safekeeper": g.version}
+	}
+	if !g.reproducible {
+		statement.Predicate.RunDetails.Metadata = &slsaMetadata{StartedOn: start.UTC().Format(time.RFC3339), FinishedOn: end.UTC().Format(time.RFC3339)}
+	}
+
+	build := &statement.Predicate.BuildDefinition
+	for _, file := range files {
+		template, output := filepath.ToSlash(file.Template), filepath.ToSlash(file.Output)
+		statement.Subject = append(statement.Subject, inTotoSubject{Name: output, Digest: digestSet(file.OutputHash)})
+		build.ExternalParameters.Files = append(build.ExternalParameters.Files, attestedFile{Template: template, Output: output, Keys: file.Keys})
+		build.ResolvedDependencies = append(build.ResolvedDependencies, slsaResourceDescriptor{URI: template, Digest: digestSet(file.TemplateHash)})
+	}
+	return statement
+}
+
+// writeAttestation writes the in-toto statement of the files generated by g from start to end to path, signing
+// it with cosign (keyless) into path.bundle if sign is set
+func (g *generation) writeAttestation(path string, start time.Time, end time.Time, sign bool, run commandRunner) error {
+	content, err := json.MarshalIndent(g.attestation(start, end), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(path, append(content, '\n'), defaultFileMode); err != nil {
+		return withExitCode(exitWriteFailure, err)
+	}
+	if sign {
+		return signWithCosign(path, run)
+	}
+	return nil
+}
+
+// signWithCosign signs the attestation at path with cosign, keyless (with the OIDC identity of the CI or of the
+// user), writing the signature and certificate as a bundle next to it for cosign verify-blob
+func signWithCosign(path string, run commandRunner) error {
+	if _, err := run("cosign", "sign-blob", "--yes", "--bundle", path+attestationBundleExt, path); err != nil {
+		return withExitCode(exitWriteFailure, errors.New("Signing the attestation with cosign failed: "+err.Error()))
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+func TestAttestation(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "attestation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	template := []byte("package secrets\n\nconst token = \"ENV_TOKEN\"\n")
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "secrets.go"+templateExt), template, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	attestation := filepath.Join(tempDir, "safekeeper.intoto.json")
+	env := safekeeper.LookupMap(map[string]string{"TOKEN": "s3cr3t"})
+	opts := options{paths: []string{tempDir}, attestation: attestation, version: "v1.4.0", reproducible: true, lookupEnv: env}
+	if err := run(opts); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := ioutil.ReadFile(attestation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "s3cr3t") {
+		t.Errorf("Attestation shouldn't have values but was:\n\n%s", content)
+	}
+	var statement inTotoStatement
+	if err := json.Unmarshal(content, &statement); err != nil {
+		t.Fatal(err)
+	}
+	if statement.Type != inTotoStatementType || statement.PredicateType != slsaProvenanceType {
+		t.Errorf("Expected an in-toto statement of SLSA provenance but got:\n\n%s", content)
+	}
+
+	generated, err := ioutil.ReadFile(filepath.Join(tempDir, "secrets.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.ToSlash(filepath.Join(tempDir, "secrets.go"))
+	if len(statement.Subject) != 1 || statement.Subject[0].Name != output || "sha256:"+statement.Subject[0].Digest["sha256"] != contentHash(generated) {
+		t.Errorf("Expected the generated file with its digest as subject but got %+v", statement.Subject)
+	}
+	build := statement.Predicate.BuildDefinition
+	if files := build.ExternalParameters.Files; len(files) != 1 || len(files[0].Keys) != 1 || files[0].Keys[0] != "TOKEN" {
+		t.Errorf("Expected the keys substituted in the generated file but got %+v", files)
+	}
+	if deps := build.ResolvedDependencies; len(deps) != 1 || "sha256:"+deps[0].Digest["sha256"] != contentHash(template) {
+		t.Errorf("Expected the template with its digest as dependency but got %+v", deps)
+	}
+	if details := statement.Predicate.RunDetails; details.Builder.Version["safekeeper"] != "v1.4.0" || details.Metadata != nil {
+		t.Errorf("Expected the version of safekeeper without the times of a reproducible run but got %+v", details)
+	}
+
+	if err := run(options{paths: []string{tempDir}, signAttestation: true, lookupEnv: env}); err == nil || !strings.Contains(err.Error(), "requires --attestation") {
+		t.Errorf("Expected --sign-attestation without --attestation to fail but got [%v]", err)
+	}
+}
+
+func TestSignAttestation(t *testing.T) {
+	var args []string
+	run := func(name string, arguments ...string) ([]byte, error) {
+		args = append([]string{name}, arguments...)
+		return nil, nil
+	}
+	if err := signWithCosign("safekeeper.intoto.json", run); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(args, " ") != "cosign sign-blob --yes --bundle safekeeper.intoto.json.bundle safekeeper.intoto.json" {
+		t.Errorf("Expected the attestation to be signed with cosign but ran [%s]", strings.Join(args, " "))
+	}
+
+	failing := func(name string, arguments ...string) ([]byte, error) { return nil, errors.New("no identity token") }
+	if err := signWithCosign("safekeeper.intoto.json", failing); exitCode(err) != exitWriteFailure || !strings.Contains(err.Error(), "no identity token") {
+		t.Errorf("Expected the failure of cosign to be reported but got [%v]", err)
+	}
+}
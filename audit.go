@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"os/user"
+	"sort"
+	"time"
+)
+
+// auditRecord is the line a generation appends to the audit log. It never has values.
+type auditRecord struct {
+	Time string `json:"time"`
+	User string `json:"user"`
+	// Keys are the keys that had a value, by name
+	Keys    []string      `json:"keys"`
+	Sources []string      `json:"sources"`
+	Files   []auditedFile `json:"files"`
+}
+
+// auditedFile is a file written by an audited generation
+type auditedFile struct {
+	Output     string `json:"output"`
+	OutputHash string `json:"outputHash"`
+}
+
+// appendAuditLog appends the record of the generation of g at now, with the settings of opts, to the audit log
+// of opts
+func (g *generation) appendAuditLog(opts options, now time.Time) error {
+	record := auditRecord{Time: now.UTC().Format(time.RFC3339), User: currentUser(), Keys: []string{}, Sources: opts.sources, Files: []auditedFile{}}
+	for key := range g.keyValues {
+		record.Keys = append(record.Keys, key)
+	}
+	sort.Strings(record.Keys)
+	if len(record.Sources) == 0 {
+		record.Sources = []string{"env"}
+	}
+	for _, generated := range g.manifestFiles {
+		record.Files = append(record.Files, auditedFile{Output: generated.Output, OutputHash: generated.OutputHash})
+	}
+	sort.Slice(record.Files, func(i, j int) bool { return record.Files[i].Output < record.Files[j].Output })
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(opts.auditLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return withExitCode(exitWriteFailure, err)
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return withExitCode(exitWriteFailure, err)
+	}
+	return withExitCode(exitWriteFailure, file.Close())
+}
+
+// currentUser returns the name of the user running safekeeper, or $USER if it can't be looked up
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAuditLog(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	os.Setenv("TOKEN", "s3cr3t")
+	defer os.Unsetenv("TOKEN")
+
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "secrets.go"+templateExt), []byte("package secrets\n\nconst token = \"ENV_TOKEN\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	auditLog := filepath.Join(tempDir, "audit.log")
+	opts := options{paths: []string{tempDir}, auditLog: auditLog}
+	for i := 0; i < 2; i++ {
+		if err := run(opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Checks don't bake values into anything
+	opts.check = true
+	if err := run(opts); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := ioutil.ReadFile(auditLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "s3cr3t") {
+		t.Errorf("Audit log shouldn't have values but was:\n\n%s", string(content))
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected a record per generation but got:\n\n%s", string(content))
+	}
+
+	var record auditRecord
+	if err := json.Unmarshal([]byte(lines[1]), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Time == "" || strings.Join(record.Keys, ",") != "TOKEN" || strings.Join(record.Sources, ",") != "env" || len(record.Files) != 1 || record.Files[0].Output != filepath.Join(tempDir, "secrets.go") {
+		t.Errorf("Unexpected audit record %+v", record)
+	}
+}
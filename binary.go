@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// binarySniffSize is the size of the start of files looked at for NUL bytes to tell binary files, as git does
+const binarySniffSize = 8000
+
+// isBinary reports whether content is (or starts like) the one of a binary file rather than text
+func isBinary(content []byte) bool {
+	if len(content) > binarySniffSize {
+		content = content[:binarySniffSize]
+	}
+	return bytes.IndexByte(content, 0) >= 0
+}
+
+// isBinaryFile reports whether the file at path is a binary file according to isBinary
+func isBinaryFile(path string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	start := make([]byte, binarySniffSize)
+	n, err := io.ReadFull(file, start)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	return isBinary(start[:n]), nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSkipBinaryTemplates(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "binary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	os.Setenv("TOKEN", "s3cr3t")
+	defer os.Unsetenv("TOKEN")
+
+	templates := map[string]string{
+		"secrets.go": "package secrets\n\nconst token = \"ENV_TOKEN\"\n",
+		"logo.png":   "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR ENV_MISSING",
+	}
+	for name, template := range templates {
+		if err := ioutil.WriteFile(filepath.Join(tempDir, name+templateExt), []byte(template), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var stderr bytes.Buffer
+	if err := run(options{paths: []string{tempDir}, stderr: &stderr}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "logo.png")); !os.IsNotExist(err) {
+		t.Errorf("Expected the binary file to be skipped")
+	}
+	if !strings.Contains(stderr.String(), "Skipping binary file ["+filepath.Join(tempDir, "logo.png"+templateExt)+"]") {
+		t.Errorf("Expected a warning about the skipped binary file but got [%s]", stderr.String())
+	}
+	if output, _ := ioutil.ReadFile(filepath.Join(tempDir, "secrets.go")); !strings.Contains(string(output), "s3cr3t") {
+		t.Errorf("Expected the text template to be generated but got [%s]", string(output))
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+// clean rewrites the templates of the generated files of opts from the files themselves, their values being
+// replaced back by placeholders on the lines that differ from the existing templates, see
+// safekeeper.Generator.RevertTemplate. Keys are the ones of opts or else the ones of the existing templates, their
+// values loaded from the sources of opts. With the dry run of opts, the diff of templates is printed instead.
+func clean(opts options) error {
+	opts, cancel := opts.withContext()
+	defer cancel()
+	finder := opts.finder()
+	inputPaths, err := finder.expandPaths(opts.paths)
+	if err != nil {
+		return err
+	}
+
+	outputs, err := finder.outputsOf(inputPaths)
+	if err != nil {
+		return err
+	}
+
+	generator, err := opts.generator()
+	if err != nil {
+		return err
+	}
+	keys, defaulted, _, err := scanTemplates(&generator, finder, outputs, nil)
+	if err != nil {
+		return err
+	}
+	optional := parseOptionalKeys(opts.optionalKeys)
+	if opts.keys != "" {
+		keys = parseKeys(opts.keys, optional)
+	}
+	source, err := newSecretSource(opts)
+	if err != nil {
+		return err
+	}
+	values, err := loadKeyValues(opts.context(), keys, source, defaulted, optional)
+	if err != nil {
+		return err
+	}
+
+	reverter := safekeeper.Generator{Prefix: generator.Prefix, Suffix: generator.Suffix, Mode: generator.Mode, Engine: generator.Engine}
+	g := &generation{stdout: opts.stdout}
+	if g.stdout == nil {
+		g.stdout = os.Stdout
+	}
+	g.color = opts.useColor(g.stdout)
+	for _, output := range outputs {
+		generated, err := os.Open(output)
+		if err != nil {
+			return withExitCode(exitFailure, err)
+		}
+		// Only the lines that differ from the existing template are reverted, a value also appearing elsewhere
+		// (e.g. a port used as a literal) being left alone
+		templatePath := finder.ext.templateOf(output)
+		existing, err := ioutil.ReadFile(templatePath)
+		mode := os.FileMode(0644)
+		var template bytes.Buffer
+		switch {
+		case err == nil:
+			if info, err := os.Stat(templatePath); err == nil {
+				mode = info.Mode().Perm()
+			}
+			err = reverter.RevertTemplate(generated, existing, &template, values)
+		case os.IsNotExist(err):
+			err = reverter.Revert(generated, &template, values)
+		}
+		generated.Close()
+		if err != nil {
+			return err
+		}
+
+		if opts.dryRun {
+			err = g.printDiff(templatePath, template.Bytes(), nil)
+		} else {
+			err = withExitCode(exitWriteFailure, writeFileAtomic(templatePath, template.Bytes(), mode))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestClean(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "clean")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	os.Setenv("TOKEN", "s3cr3t")
+	defer os.Unsetenv("TOKEN")
+
+	template := filepath.Join(tempDir, "secrets.go"+templateExt)
+	if err := ioutil.WriteFile(template, []byte("package secrets\n\nconst token = \"ENV_TOKEN\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(options{paths: []string{tempDir}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The generated file is edited directly, the edit making it back to the template
+	generated := filepath.Join(tempDir, "secrets.go")
+	source, err := ioutil.ReadFile(generated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source = append(source, []byte("\nconst header = \"Bearer s3cr3t\"\n")...)
+	if err := ioutil.WriteFile(generated, source, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := clean(options{paths: []string{tempDir}}); err != nil {
+		t.Fatal(err)
+	}
+
+	cleaned, err := ioutil.ReadFile(template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "package secrets\n\nconst token = \"ENV_TOKEN\"\n\nconst header = \"Bearer ENV_TOKEN\"\n"
+	if string(cleaned) != expected {
+		t.Errorf("Expected template [%s] but got [%s]", expected, string(cleaned))
+	}
+}
+
+func TestCleanUneditedFile(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "clean")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	os.Setenv("PORT", "8")
+	defer os.Unsetenv("PORT")
+
+	// The value of PORT also appears as an unrelated literal
+	content := "package secrets\n\nconst port = \"ENV_PORT\"\nconst host = \"db:ENV_PORT\"\nconst maxRetries = 8\nconst addr = \"host:8\"\n"
+	template := filepath.Join(tempDir, "secrets.go"+templateExt)
+	if err := ioutil.WriteFile(template, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(options{paths: []string{tempDir}, keys: "PORT", buildTags: "prod", valueTTL: time.Hour, lineDirectives: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := clean(options{paths: []string{tempDir}}); err != nil {
+		t.Fatal(err)
+	}
+	cleaned, err := ioutil.ReadFile(template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(cleaned) != content {
+		t.Errorf("Expected the template of an unedited file to be kept as is [%s] but got [%s]", content, string(cleaned))
+	}
+	if info, err := os.Stat(template); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the mode of the template to be kept but got %v (err: %v)", info, err)
+	}
+}
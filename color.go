@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// ANSI escape sequences of the colors of terminal output
+const (
+	colorReset  = "\x1b[0m"
+	colorBold   = "\x1b[1m"
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+	colorCyan   = "\x1b[36m"
+)
+
+// useColor reports whether the output of opts written to w is colored: only on terminals, unless --no-color, the
+// NO_COLOR environment variable (see https://no-color.org) or a dumb terminal say otherwise
+func (opts options) useColor(w io.Writer) bool {
+	if opts.noColor || opts.getenv("NO_COLOR") != "" || opts.getenv("TERM") == "dumb" {
+		return false
+	}
+	f, ok := w.(*os.File)
+	return ok && term.IsTerminal(int(f.Fd()))
+}
+
+// paint returns s in color, as is if color is empty
+func paint(s string, color string) string {
+	if color == "" || s == "" {
+		return s
+	}
+	return color + s + colorReset
+}
+
+// colorDiff returns the unified diff in colors: file names in bold, hunk headers in cyan, removed lines in red and
+// added ones in green
+func colorDiff(diff []byte) []byte {
+	var colored bytes.Buffer
+	for _, line := range bytes.SplitAfter(diff, []byte("\n")) {
+		content := bytes.TrimSuffix(line, []byte("\n"))
+		var color string
+		switch {
+		case bytes.HasPrefix(content, []byte("--- ")), bytes.HasPrefix(content, []byte("+++ ")):
+			color = colorBold
+		case bytes.HasPrefix(content, []byte("@@")):
+			color = colorCyan
+		case bytes.HasPrefix(content, []byte("-")):
+			color = colorRed
+		case bytes.HasPrefix(content, []byte("+")):
+			color = colorGreen
+		}
+		colored.WriteString(paint(string(content), color))
+		colored.Write(line[len(content):])
+	}
+	return colored.Bytes()
+}
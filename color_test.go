@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+func TestColorDiff(t *testing.T) {
+	diff := "--- secrets.go\n+++ secrets.go\n@@ -1,2 +1,2 @@\n package secrets\n-const a = \"1\"\n+const a = \"2\"\n"
+	expected := colorBold + "--- secrets.go" + colorReset + "\n" + colorBold + "+++ secrets.go" + colorReset + "\n" +
+		colorCyan + "@@ -1,2 +1,2 @@" + colorReset + "\n package secrets\n" +
+		colorRed + "-const a = \"1\"" + colorReset + "\n" + colorGreen + "+const a = \"2\"" + colorReset + "\n"
+	if colored := string(colorDiff([]byte(diff))); colored != expected {
+		t.Errorf("Expected colored diff %q but got %q", expected, colored)
+	}
+}
+
+func TestColoredLogger(t *testing.T) {
+	var stderr bytes.Buffer
+	log, err := newLogger(&stderr, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.color = true
+	log.errorf("2 generated files are out of date:\na.go\nb.go")
+	expected := colorBold + colorRed + "Error: " + colorReset + "2 generated files are out of date:" + colorRed + "\na.go\nb.go" + colorReset + "\n"
+	if stderr.String() != expected {
+		t.Errorf("Expected colored error %q but got %q", expected, stderr.String())
+	}
+}
+
+func TestUseColor(t *testing.T) {
+	var buffer bytes.Buffer
+	if (options{}).useColor(&buffer) {
+		t.Error("Expected no color when not writing to a terminal")
+	}
+	for _, opts := range []options{{noColor: true}, {lookupEnv: safekeeper.LookupMap(map[string]string{"NO_COLOR": "1"})}} {
+		if opts.useColor(os.Stderr) {
+			t.Errorf("Expected no color with --no-color or NO_COLOR")
+		}
+	}
+}
@@ -0,0 +1,408 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/kingpin"
+)
+
+// Shells completion scripts are generated for
+const (
+	shellBash = "bash"
+	shellZsh  = "zsh"
+	shellFish = "fish"
+)
+
+// keyCommands are the commands whose first argument is a key
+var keyCommands = []string{"which", "store", "delete"}
+
+// shellFlag is a flag offered by completion scripts
+type shellFlag struct {
+	name string
+	help string
+	// takesValue is true for the flags that aren't booleans, their value being the next word if not joined with =
+	takesValue bool
+}
+
+// shellCommand is a command offered by completion scripts, with its own flags and subcommands
+type shellCommand struct {
+	// name is the full name of the command, e.g. hook pre-commit
+	name        string
+	help        string
+	flags       []shellFlag
+	subcommands []shellCommand
+}
+
+// completionModel is what completion scripts complete: the commands and flags of the CLI and the keys of the
+// project
+type completionModel struct {
+	commands []shellCommand
+	flags    []shellFlag
+	keys     []string
+}
+
+// newCompletionModel returns the completion model of the commands and flags of app, with keys as the keys of
+// the project. Hidden commands and flags are left out.
+func newCompletionModel(app *kingpin.ApplicationModel, keys []string) completionModel {
+	return completionModel{
+		commands: completionCommands(app.Commands),
+		flags:    completionFlags(app.Flags),
+		keys:     keys,
+	}
+}
+
+// completionCommands returns the completion commands of the visible commands
+func completionCommands(commands []*kingpin.CmdModel) []shellCommand {
+	var result []shellCommand
+	for _, command := range commands {
+		if command.Hidden {
+			continue
+		}
+		result = append(result, shellCommand{
+			name:        command.FullCommand,
+			help:        helpSummary(command.Help),
+			flags:       completionFlags(command.Flags),
+			subcommands: completionCommands(command.Commands),
+		})
+	}
+	return result
+}
+
+// completionFlags returns the completion flags of the visible flags
+func completionFlags(flags []*kingpin.FlagModel) []shellFlag {
+	var result []shellFlag
+	for _, flag := range flags {
+		if flag.Hidden {
+			continue
+		}
+		result = append(result, shellFlag{name: flag.Name, help: helpSummary(flag.Help), takesValue: !flag.IsBoolFlag()})
+	}
+	return result
+}
+
+// helpSummary returns the first sentence of help, without its final period, for the descriptions of completions.
+// The periods of abbreviations (e.g.) and ellipses don't end sentences.
+func helpSummary(help string) string {
+	for start := 0; ; {
+		i := strings.Index(help[start:], ". ")
+		if i < 0 {
+			break
+		}
+		end := start + i
+		sentence := help[:end]
+		if !strings.HasSuffix(sentence, "e.g") && !strings.HasSuffix(sentence, "i.e") && !strings.HasSuffix(sentence, "..") {
+			help = sentence
+			break
+		}
+		start = end + 2
+	}
+	return strings.TrimSuffix(strings.TrimSpace(help), ".")
+}
+
+// allCommands returns the commands of m and their subcommands, depth first
+func (m completionModel) allCommands() []shellCommand {
+	var all []shellCommand
+	var add func(commands []shellCommand)
+	add = func(commands []shellCommand) {
+		for _, command := range commands {
+			all = append(all, command)
+			add(command.subcommands)
+		}
+	}
+	add(m.commands)
+	return all
+}
+
+// completionKeys returns the keys of the project completed as the arguments of key commands: the keys and
+// optional keys of the config file and the ones of the placeholders of its templates. Only names are read,
+// never values.
+func completionKeys(opts options) ([]string, error) {
+	known := make(map[string]bool)
+	for _, list := range []string{opts.keys, opts.optionalKeys} {
+		for _, key := range parseKeys(list, make(map[string]bool)) {
+			if key != "" {
+				known[key] = true
+			}
+		}
+	}
+	if len(opts.paths) > 0 {
+		usages, err := keyUsages(opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, usage := range usages {
+			known[usage.Key] = true
+		}
+	}
+
+	keys := make([]string, 0, len(known))
+	for key := range known {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// completion writes the completion script of shell for the commands and flags of app and the keys of the project
+// of opts to its stdout
+func completion(opts options, shell string, app *kingpin.ApplicationModel) error {
+	keys, err := completionKeys(opts)
+	if err != nil {
+		return err
+	}
+
+	stdout := opts.stdout
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	if err := writeCompletion(stdout, shell, newCompletionModel(app, keys)); err != nil {
+		return withExitCode(exitWriteFailure, err)
+	}
+	return nil
+}
+
+// writeCompletion writes the completion script of m for shell to w
+func writeCompletion(w io.Writer, shell string, m completionModel) error {
+	var script string
+	switch shell {
+	case shellBash:
+		script = bashCompletion(m)
+	case shellZsh:
+		script = zshCompletion(m)
+	case shellFish:
+		script = fishCompletion(m)
+	default:
+		return fmt.Errorf("Unknown shell [%s], expected %s, %s or %s", shell, shellBash, shellZsh, shellFish)
+	}
+	_, err := io.WriteString(w, script)
+	return err
+}
+
+// flagNames returns the --names of flags, separated by spaces
+func flagNames(flags []shellFlag) string {
+	names := make([]string, len(flags))
+	for i, flag := range flags {
+		names[i] = "--" + flag.name
+	}
+	return strings.Join(names, " ")
+}
+
+// commandNames returns the last word of the names of commands, separated by spaces
+func commandNames(commands []shellCommand) string {
+	names := make([]string, len(commands))
+	for i, command := range commands {
+		names[i] = lastWord(command.name)
+	}
+	return strings.Join(names, " ")
+}
+
+// lastWord returns the last of the space-separated words of s
+func lastWord(s string) string {
+	return s[strings.LastIndex(s, " ")+1:]
+}
+
+// bashCompletion returns the bash completion script of m. Words are commands until one isn't, the values of the
+// flags taking one being skipped.
+func bashCompletion(m completionModel) string {
+	var valueFlags []string
+	for _, flag := range m.flags {
+		if flag.takesValue {
+			valueFlags = append(valueFlags, "--"+flag.name)
+		}
+	}
+	for _, command := range m.allCommands() {
+		for _, flag := range command.flags {
+			if flag.takesValue {
+				valueFlags = append(valueFlags, "--"+flag.name)
+			}
+		}
+	}
+	var commandPatterns []string
+	for _, command := range m.allCommands() {
+		commandPatterns = append(commandPatterns, fmt.Sprintf("%q", command.name))
+	}
+
+	var b strings.Builder
+	b.WriteString("# bash completion for safekeeper, generated by safekeeper completion bash\n")
+	b.WriteString("_safekeeper() {\n")
+	b.WriteString("    local cur=\"${COMP_WORDS[COMP_CWORD]}\" prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	b.WriteString("    local command=\"\" candidate word i\n")
+	b.WriteString("    for ((i = 1; i < COMP_CWORD; i++)); do\n")
+	b.WriteString("        word=\"${COMP_WORDS[i]}\"\n")
+	b.WriteString("        case \"$word\" in\n")
+	if len(valueFlags) > 0 {
+		fmt.Fprintf(&b, "            %s) [[ \"${COMP_WORDS[i+1]}\" == = ]] && ((i++)); ((i++)) ;;\n", strings.Join(valueFlags, "|"))
+	}
+	b.WriteString("            -*) ;;\n")
+	b.WriteString("            *)\n")
+	b.WriteString("                candidate=\"${command:+$command }$word\"\n")
+	b.WriteString("                case \"$candidate\" in\n")
+	fmt.Fprintf(&b, "                    %s) command=\"$candidate\" ;;\n", strings.Join(commandPatterns, "|"))
+	b.WriteString("                    *) [[ -z \"$command\" ]] && command=generate; break ;;\n")
+	b.WriteString("                esac\n")
+	b.WriteString("                ;;\n")
+	b.WriteString("        esac\n")
+	b.WriteString("    done\n\n")
+
+	b.WriteString("    if [[ \"$prev\" == --explain ]]; then\n")
+	b.WriteString("        COMPREPLY=($(compgen -W \"$_safekeeper_keys\" -- \"$cur\"))\n")
+	b.WriteString("        return\n")
+	b.WriteString("    fi\n")
+	b.WriteString("    if [[ \"$cur\" == -* ]]; then\n")
+	b.WriteString("        local flags=\"$_safekeeper_flags\"\n")
+	b.WriteString("        case \"$command\" in\n")
+	for _, command := range m.allCommands() {
+		if len(command.flags) > 0 {
+			fmt.Fprintf(&b, "            %q) flags+=\" %s\" ;;\n", command.name, flagNames(command.flags))
+		}
+	}
+	b.WriteString("        esac\n")
+	b.WriteString("        COMPREPLY=($(compgen -W \"$flags\" -- \"$cur\"))\n")
+	b.WriteString("        return\n")
+	b.WriteString("    fi\n")
+	b.WriteString("    case \"$command\" in\n")
+	fmt.Fprintf(&b, "        \"\") COMPREPLY=($(compgen -W %q -- \"$cur\")) ;;\n", commandNames(m.commands))
+	for _, command := range m.allCommands() {
+		if len(command.subcommands) > 0 {
+			fmt.Fprintf(&b, "        %q) COMPREPLY=($(compgen -W %q -- \"$cur\")) ;;\n", command.name, commandNames(command.subcommands))
+		}
+	}
+	fmt.Fprintf(&b, "        %s) COMPREPLY=($(compgen -W \"$_safekeeper_keys\" -- \"$cur\")) ;;\n", strings.Join(keyCommands, "|"))
+	b.WriteString("    esac\n")
+	b.WriteString("}\n")
+	fmt.Fprintf(&b, "_safekeeper_flags=%q\n", flagNames(m.flags))
+	fmt.Fprintf(&b, "_safekeeper_keys=%q\n", strings.Join(m.keys, " "))
+	b.WriteString("complete -o default -o bashdefault -F _safekeeper safekeeper\n")
+	return b.String()
+}
+
+// zshSpec returns the _arguments spec of flag
+func zshSpec(flag shellFlag) string {
+	help := strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`, ":", `\:`).Replace(flag.help)
+	if flag.takesValue {
+		return shellQuote(fmt.Sprintf("--%s=[%s]:%s:_files", flag.name, help, flag.name))
+	}
+	return shellQuote(fmt.Sprintf("--%s[%s]", flag.name, help))
+}
+
+// zshDescriptions returns the _describe entries of commands
+func zshDescriptions(commands []shellCommand) string {
+	entries := make([]string, len(commands))
+	for i, command := range commands {
+		entries[i] = shellQuote(lastWord(command.name) + ":" + strings.Replace(command.help, ":", `\:`, -1))
+	}
+	return strings.Join(entries, " ")
+}
+
+// zshCompletion returns the zsh completion script of m
+func zshCompletion(m completionModel) string {
+	var b strings.Builder
+	b.WriteString("#compdef safekeeper\n")
+	b.WriteString("# zsh completion for safekeeper, generated by safekeeper completion zsh\n\n")
+	b.WriteString("_safekeeper() {\n")
+	b.WriteString("    local -a flags commands keys command_flags\n")
+	b.WriteString("    local state line\n")
+	b.WriteString("    flags=(\n")
+	for _, flag := range m.flags {
+		fmt.Fprintf(&b, "        %s\n", zshSpec(flag))
+	}
+	b.WriteString("    )\n")
+	fmt.Fprintf(&b, "    commands=(%s)\n", zshDescriptions(m.commands))
+	quotedKeys := make([]string, len(m.keys))
+	for i, key := range m.keys {
+		quotedKeys[i] = shellQuote(key)
+	}
+	fmt.Fprintf(&b, "    keys=(%s)\n\n", strings.Join(quotedKeys, " "))
+
+	b.WriteString("    _arguments -C $flags '1: :->command' '*:: :->args'\n")
+	b.WriteString("    case $state in\n")
+	b.WriteString("        command)\n")
+	b.WriteString("            _describe -t commands command commands\n")
+	b.WriteString("            _files\n")
+	b.WriteString("            ;;\n")
+	b.WriteString("        args)\n")
+	b.WriteString("            case $line[1] in\n")
+	for _, command := range m.commands {
+		var specs []string
+		for _, flag := range command.flags {
+			specs = append(specs, zshSpec(flag))
+		}
+		switch {
+		case len(command.subcommands) > 0:
+			fmt.Fprintf(&b, "                %s)\n", command.name)
+			fmt.Fprintf(&b, "                    local -a subcommands=(%s)\n", zshDescriptions(command.subcommands))
+			b.WriteString("                    _describe -t commands subcommand subcommands\n")
+			b.WriteString("                    ;;\n")
+		case len(specs) > 0:
+			fmt.Fprintf(&b, "                %s)\n", command.name)
+			fmt.Fprintf(&b, "                    command_flags=(%s)\n", strings.Join(specs, " "))
+			b.WriteString("                    _arguments $flags $command_flags '*:file:_files'\n")
+			b.WriteString("                    ;;\n")
+		}
+	}
+	fmt.Fprintf(&b, "                %s)\n", strings.Join(keyCommands, "|"))
+	b.WriteString("                    _arguments $flags '1:key:($keys)' '*:file:_files'\n")
+	b.WriteString("                    ;;\n")
+	b.WriteString("                *)\n")
+	b.WriteString("                    _arguments $flags '*:file:_files'\n")
+	b.WriteString("                    ;;\n")
+	b.WriteString("            esac\n")
+	b.WriteString("            ;;\n")
+	b.WriteString("    esac\n")
+	b.WriteString("}\n\n")
+	b.WriteString("if [[ $zsh_eval_context[-1] == loadautofunc ]]; then\n")
+	b.WriteString("    _safekeeper \"$@\"\n")
+	b.WriteString("else\n")
+	b.WriteString("    compdef _safekeeper safekeeper\n")
+	b.WriteString("fi\n")
+	return b.String()
+}
+
+// fishCompletion returns the fish completion script of m
+func fishCompletion(m completionModel) string {
+	var b strings.Builder
+	b.WriteString("# fish completion for safekeeper, generated by safekeeper completion fish\n")
+	b.WriteString("complete -c safekeeper -e\n")
+	for _, command := range m.commands {
+		fmt.Fprintf(&b, "complete -c safekeeper -n __fish_use_subcommand -a %s -d %s\n", command.name, shellQuote(command.help))
+		for _, subcommand := range command.subcommands {
+			fmt.Fprintf(&b, "complete -c safekeeper -f -n '__fish_seen_subcommand_from %s' -a %s -d %s\n", command.name, lastWord(subcommand.name), shellQuote(subcommand.help))
+		}
+	}
+	for _, flag := range m.flags {
+		b.WriteString(fishFlag("", flag))
+	}
+	for _, command := range m.allCommands() {
+		for _, flag := range command.flags {
+			b.WriteString(fishFlag(lastWord(command.name), flag))
+		}
+	}
+	if len(m.keys) > 0 {
+		keys := strings.Join(m.keys, " ")
+		fmt.Fprintf(&b, "complete -c safekeeper -n '__fish_seen_subcommand_from %s' -a %s -d key\n", strings.Join(keyCommands, " "), shellQuote(keys))
+		fmt.Fprintf(&b, "complete -c safekeeper -l explain -x -a %s -d key\n", shellQuote(keys))
+	}
+	return b.String()
+}
+
+// fishFlag returns the fish completion of flag, only offered after command if not empty
+func fishFlag(command string, flag shellFlag) string {
+	condition := ""
+	if command != "" {
+		condition = fmt.Sprintf(" -n '__fish_seen_subcommand_from %s'", command)
+	}
+	value := ""
+	if flag.takesValue {
+		value = " -r"
+	}
+	return fmt.Sprintf("complete -c safekeeper%s -l %s%s -d %s\n", condition, flag.name, value, shellQuote(flag.help))
+}
+
+// shellQuote returns s single-quoted for POSIX shells, zsh and fish
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/kingpin"
+)
+
+// testCompletionApp returns the model of a CLI shaped like safekeeper's
+func testCompletionApp() *kingpin.ApplicationModel {
+	app := kingpin.New("safekeeper", "")
+	app.Flag("keys", "Comma-delimited list of keys. Discovered otherwise.").String()
+	app.Flag("strict", "Fail if generated files still have placeholders, e.g. of a key missing from --keys.").Bool()
+	app.Flag("hidden-token", "Hidden.").Hidden().String()
+	app.Command("generate", "Generate sources.").Default()
+	listKeys := app.Command("list-keys", "Print the keys.")
+	listKeys.Flag("json", "Print JSON.").Bool()
+	hook := app.Command("hook", "Git hooks.")
+	hook.Command("pre-commit", "Fail on staged secrets.")
+	hook.Command("install", "Install the hook.")
+	app.Command("which", "Print the templates using a key.")
+	return app.Model()
+}
+
+func TestCompletionModel(t *testing.T) {
+	m := newCompletionModel(testCompletionApp(), []string{"API_KEY"})
+
+	expectedFlags := []shellFlag{
+		{name: "help", help: "Show context-sensitive help (also try --help-long and --help-man)"},
+		{name: "keys", help: "Comma-delimited list of keys", takesValue: true},
+		{name: "strict", help: "Fail if generated files still have placeholders, e.g. of a key missing from --keys"},
+	}
+	if !reflect.DeepEqual(m.flags, expectedFlags) {
+		t.Errorf("Expected flags %+v but got %+v", expectedFlags, m.flags)
+	}
+
+	var names []string
+	for _, command := range m.allCommands() {
+		names = append(names, command.name)
+	}
+	expectedNames := []string{"generate", "list-keys", "hook", "hook pre-commit", "hook install", "which"}
+	if !reflect.DeepEqual(names, expectedNames) {
+		t.Errorf("Expected commands %v but got %v", expectedNames, names)
+	}
+}
+
+func TestWriteCompletion(t *testing.T) {
+	m := newCompletionModel(testCompletionApp(), []string{"API_KEY", "DB_PASSWORD"})
+
+	for shell, expected := range map[string][]string{
+		shellBash: {"complete -o default -o bashdefault -F _safekeeper safekeeper", `"hook pre-commit"`, `"list-keys") flags+=" --json"`, `_safekeeper_keys="API_KEY DB_PASSWORD"`},
+		shellZsh:  {"#compdef safekeeper", "'--keys=[Comma-delimited list of keys]:keys:_files'", "'pre-commit:Fail on staged secrets'", "keys=('API_KEY' 'DB_PASSWORD')"},
+		shellFish: {"-n __fish_use_subcommand -a list-keys -d 'Print the keys'", "-l keys -r", "-n '__fish_seen_subcommand_from list-keys' -l json", "-l explain -x -a 'API_KEY DB_PASSWORD'"},
+	} {
+		var script bytes.Buffer
+		if err := writeCompletion(&script, shell, m); err != nil {
+			t.Fatal(err)
+		}
+		for _, line := range expected {
+			if !strings.Contains(script.String(), line) {
+				t.Errorf("Expected the %s script to have [%s] but got:\n%s", shell, line, script.String())
+			}
+		}
+		if strings.Contains(script.String(), "hidden-token") {
+			t.Errorf("Expected the %s script to leave hidden flags out but got:\n%s", shell, script.String())
+		}
+	}
+
+	if err := writeCompletion(ioutil.Discard, "powershell", m); err == nil {
+		t.Error("Expected an unknown shell to fail")
+	}
+}
+
+func TestBashCompletion(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash isn't installed")
+	}
+
+	var script bytes.Buffer
+	if err := writeCompletion(&script, shellBash, newCompletionModel(testCompletionApp(), []string{"API_KEY", "DB_PASSWORD"})); err != nil {
+		t.Fatal(err)
+	}
+
+	for line, expected := range map[string]string{
+		"safekeeper ho":                     "hook",
+		"safekeeper hook ":                  "pre-commit install",
+		"safekeeper --keys FOO which ":      "API_KEY DB_PASSWORD",
+		"safekeeper --keys = FOO which DB_": "DB_PASSWORD",
+		"safekeeper list-keys --j":          "--json",
+		"safekeeper secrets.go --st":        "--strict",
+	} {
+		words := strings.Split(line, " ")
+		command := exec.Command("bash", "-c", script.String()+`
+COMP_WORDS=("$@"); COMP_CWORD=$(($# - 1)); _safekeeper; echo "${COMPREPLY[*]}"`, "bash")
+		command.Args = append(command.Args, words...)
+		output, err := command.Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if completed := strings.TrimSpace(string(output)); completed != expected {
+			t.Errorf("Expected [%s] to complete to [%s] but got [%s]", line, expected, completed)
+		}
+	}
+}
+
+func TestCompletionKeys(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "completion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "secrets.go.safekeeper"), []byte("const token = \"ENV_TOKEN\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := completionKeys(options{keys: "API_KEY,TOKEN", optionalKeys: "DEBUG?", paths: []string{tempDir}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"API_KEY", "DEBUG", "TOKEN"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Expected keys %v but got %v", expected, keys)
+	}
+}
@@ -0,0 +1,531 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/alecthomas/kingpin"
+	"gopkg.in/yaml.v2"
+)
+
+// configFileNames are the names of the project config file, in order of preference
+var configFileNames = []string{"safekeeper.yaml", "safekeeper.yml", ".safekeeper.yaml", "safekeeper.toml", ".safekeeper.toml"}
+
+// config is a project config file declaring the settings shared by all the go:generate lines of a project.
+// Paths are relative to the directory of the config file.
+type config struct {
+	// Keys are the keys to replace, as with --keys
+	Keys []string `yaml:"keys" toml:"keys"`
+	// OptionalKeys are the keys replaced by an empty string when they have no value, as with --optional-keys
+	OptionalKeys []string `yaml:"optional-keys" toml:"optional-keys"`
+	// Prefix and Suffix delimit the placeholders, as with --prefix and --suffix
+	Prefix string `yaml:"prefix" toml:"prefix"`
+	Suffix string `yaml:"suffix" toml:"suffix"`
+	// Mode selects where placeholders are replaced, as with --mode
+	Mode string `yaml:"mode" toml:"mode"`
+	// Obfuscate embeds the literals with placeholders obfuscated (xor or aes), as with --obfuscate
+	Obfuscate string `yaml:"obfuscate" toml:"obfuscate"`
+	// Split is the number of fragments the literals with placeholders are split into, as with --split
+	Split int `yaml:"split" toml:"split"`
+	// EnvFallback makes literals check the environment at runtime before their value, as with --env-fallback
+	EnvFallback bool `yaml:"env-fallback" toml:"env-fallback"`
+	// Escape escapes values for the Go string literal their placeholder is in, as with --escape
+	Escape bool `yaml:"escape" toml:"escape"`
+	// Transforms maps keys to the transforms their values go through, as with --transform
+	Transforms map[string]string `yaml:"transforms" toml:"transforms"`
+	// WasmModules maps transforms to the WebAssembly modules implementing them, as with --wasm-transform
+	WasmModules map[string]string `yaml:"wasm-transforms" toml:"wasm-transforms"`
+	// Types maps keys to the type of their values, written as unquoted Go literals, as with --type
+	Types map[string]string `yaml:"types" toml:"types"`
+	// PrefixMaps maps keys to the prefix of the environment variables gathered as their value, as with --prefix-map
+	PrefixMaps map[string]string `yaml:"prefix-maps" toml:"prefix-maps"`
+	// ListDelimiter separates the elements of the values of list keys, as with --list-delimiter
+	ListDelimiter string `yaml:"list-delimiter" toml:"list-delimiter"`
+	// LogLevel and LogFormat configure the messages written to standard error, as with --log-level and --log-format
+	LogLevel  string `yaml:"log-level" toml:"log-level"`
+	LogFormat string `yaml:"log-format" toml:"log-format"`
+	// Engine selects how templates are substituted, as with --engine
+	Engine string `yaml:"engine" toml:"engine"`
+	// Map maps keys to the names they're resolved as by the sources, as with --map
+	Map map[string]string `yaml:"map" toml:"map"`
+	// Format and Validate check that generated files are valid Go, as with --format and --validate
+	Format   bool `yaml:"format" toml:"format"`
+	Validate bool `yaml:"validate" toml:"validate"`
+	// Strict fails the generation of files left with placeholders, as with --strict
+	Strict bool `yaml:"strict" toml:"strict"`
+	// NoHeader leaves the header out of generated files, as with --no-header
+	NoHeader bool `yaml:"no-header" toml:"no-header"`
+	// HeaderTemplate is the template of the header of generated files, as with --header-template
+	HeaderTemplate string `yaml:"header-template" toml:"header-template"`
+	// BuildTags is the build constraint of generated files, as with --build-tags
+	BuildTags string `yaml:"build-tags" toml:"build-tags"`
+	// FileMode is the octal permissions of generated files, as with --file-mode
+	FileMode string `yaml:"file-mode" toml:"file-mode"`
+	// LineEndings normalizes the line endings of generated files, as with --line-endings
+	LineEndings string `yaml:"line-endings" toml:"line-endings"`
+	// LineDirectives maps generated Go files back to their template with //line directives, as with
+	// --line-directives
+	LineDirectives bool `yaml:"line-directives" toml:"line-directives"`
+	// Reproducible guarantees byte-identical generated files, as with --reproducible
+	Reproducible bool `yaml:"reproducible" toml:"reproducible"`
+	// RequiredVersion is the minimum version of safekeeper generating files, e.g. 1.4.0
+	RequiredVersion string `yaml:"required-version" toml:"required-version"`
+	// Fingerprint records the fingerprints of the values of generated files, as with --fingerprint. The key they're
+	// keyed with, a secret, is only set with --fingerprint-key.
+	Fingerprint bool `yaml:"fingerprint" toml:"fingerprint"`
+	// TemplateExt is the extension of the template files, as with --template-ext
+	TemplateExt string `yaml:"template-ext" toml:"template-ext"`
+	// Excludes are the patterns of the paths skipped when walking directories, as with --exclude
+	Excludes []string `yaml:"excludes" toml:"excludes"`
+	// IncludeSkipped walks the directories skipped by default, as with --include-skipped
+	IncludeSkipped bool `yaml:"include-skipped" toml:"include-skipped"`
+	// Gitignore skips the paths ignored by .gitignore files, as with --gitignore
+	Gitignore bool `yaml:"gitignore" toml:"gitignore"`
+	// IncludeHidden walks the hidden directories, as with --include-hidden
+	IncludeHidden bool `yaml:"include-hidden" toml:"include-hidden"`
+	// MaxDepth is the number of levels of directories walked, as with --max-depth
+	MaxDepth int `yaml:"max-depth" toml:"max-depth"`
+	// MaxFileSize is the size of the largest template walks find, as with --max-file-size
+	MaxFileSize string `yaml:"max-file-size" toml:"max-file-size"`
+	// FollowSymlinks walks the directories symbolic links point to, as with --follow-symlinks
+	FollowSymlinks bool `yaml:"follow-symlinks" toml:"follow-symlinks"`
+	// Jobs is the maximum number of files generated concurrently, as with --jobs
+	Jobs int `yaml:"jobs" toml:"jobs"`
+	// Sources are the value sources as name[:argument] specs, as with --source
+	Sources []string `yaml:"sources" toml:"sources"`
+	// KeySources maps keys to the source resolving them instead of the chain of sources, as with --key-source
+	KeySources map[string]string `yaml:"key-sources" toml:"key-sources"`
+	// Templates are the inputs (files, directories or glob patterns) generated when none is given
+	Templates []string `yaml:"templates" toml:"templates"`
+	// Lang is the language of the generated files, as with --lang
+	Lang string `yaml:"lang" toml:"lang"`
+	// OutputDir is the directory generated files are written to, as with --output-dir
+	OutputDir string `yaml:"output-dir" toml:"output-dir"`
+	// CopyOthers copies the files that aren't templates to the output directory, as with --copy-others
+	CopyOthers bool `yaml:"copy-others" toml:"copy-others"`
+	// OutputPattern names the generated files after their default output, as with --output-pattern
+	OutputPattern string `yaml:"output-pattern" toml:"output-pattern"`
+	// Manifest is the file recording the generated files, as with --manifest
+	Manifest string `yaml:"manifest" toml:"manifest"`
+	// Lockfile is the file recording the generated files for status, as with --lockfile
+	Lockfile string `yaml:"lockfile" toml:"lockfile"`
+	// Backup saves overwritten generated files, as with --backup
+	Backup bool `yaml:"backup" toml:"backup"`
+	// BackupDir is the directory overwritten generated files are saved to, as with --backup-dir
+	BackupDir string `yaml:"backup-dir" toml:"backup-dir"`
+	// AuditLog is the file generations append a record to, as with --audit-log
+	AuditLog string `yaml:"audit-log" toml:"audit-log"`
+	// Attestation is the file the attestation of the generated files is written to, as with --attestation
+	Attestation string `yaml:"attestation" toml:"attestation"`
+	// SignAttestation signs the attestation with cosign, as with --sign-attestation
+	SignAttestation bool `yaml:"sign-attestation" toml:"sign-attestation"`
+	// PostHooks are the commands run after a successful generation, as with --post-hook
+	PostHooks []string `yaml:"post-hooks" toml:"post-hooks"`
+	// Outputs maps inputs to the file their source is written to, as with --output
+	Outputs map[string]string `yaml:"outputs" toml:"outputs"`
+	// EnvFiles are the dotenv files to load values from, as with --env-file
+	EnvFiles []string `yaml:"env-files" toml:"env-files"`
+	// SecretsFiles are the SOPS- or age-encrypted files to load values from, as with --secrets-file
+	SecretsFiles []string `yaml:"secrets-files" toml:"secrets-files"`
+	// Rules are the constraints the values of keys must satisfy
+	Rules map[string]keyRule `yaml:"rules" toml:"rules"`
+	// EnforceRotation fails the generation with secrets due for rotation according to their rule, as with
+	// --enforce-rotation
+	EnforceRotation bool `yaml:"enforce-rotation" toml:"enforce-rotation"`
+	// Precedence is the order of the groups of sources, as with --precedence
+	Precedence []string `yaml:"precedence" toml:"precedence"`
+	// Server is the address of the safekeeper server resolving keys in place of the sources, as with --server
+	Server string `yaml:"server" toml:"server"`
+	// Options are the settings of the sources, named after their flag
+	Options configOptions `yaml:"options" toml:"options"`
+	// Values are the values of keys that aren't secrets (e.g. the URL of a service), taking precedence over the
+	// sources
+	Values map[string]string `yaml:"values" toml:"values"`
+	// Profiles are named settings (e.g. per environment) overriding the other ones when selected with --profile.
+	// Profiles of profiles are ignored.
+	Profiles map[string]config `yaml:"profiles" toml:"profiles"`
+}
+
+// configOptions are the source settings of a config file
+type configOptions struct {
+	OpRef          string            `yaml:"op-ref" toml:"op-ref"`
+	OpItems        map[string]string `yaml:"op-item" toml:"op-item"`
+	VaultAddr      string            `yaml:"vault-addr" toml:"vault-addr"`
+	VaultPath      string            `yaml:"vault-path" toml:"vault-path"`
+	AwsSecretId    string            `yaml:"aws-secret-id" toml:"aws-secret-id"`
+	AwsSecrets     map[string]string `yaml:"aws-secret" toml:"aws-secret"`
+	SsmPath        string            `yaml:"ssm-path" toml:"ssm-path"`
+	SsmParams      map[string]string `yaml:"ssm-parameter" toml:"ssm-parameter"`
+	GcpProject     string            `yaml:"gcp-project" toml:"gcp-project"`
+	GcpSecrets     map[string]string `yaml:"gcp-secret" toml:"gcp-secret"`
+	AzureVaultName string            `yaml:"azure-vault-name" toml:"azure-vault-name"`
+	AzureSecrets   map[string]string `yaml:"azure-secret" toml:"azure-secret"`
+	DopplerProject string            `yaml:"doppler-project" toml:"doppler-project"`
+	DopplerConfig  string            `yaml:"doppler-config" toml:"doppler-config"`
+	InfisicalID    string            `yaml:"infisical-project" toml:"infisical-project"`
+	InfisicalEnv   string            `yaml:"infisical-env" toml:"infisical-env"`
+	InfisicalPath  string            `yaml:"infisical-path" toml:"infisical-path"`
+	InfisicalURL   string            `yaml:"infisical-url" toml:"infisical-url"`
+	BwsProject     string            `yaml:"bws-project" toml:"bws-project"`
+	BwsSecrets     map[string]string `yaml:"bws-secret" toml:"bws-secret"`
+	PassPath       string            `yaml:"pass-path" toml:"pass-path"`
+	K8sSecret      string            `yaml:"k8s-secret" toml:"k8s-secret"`
+	K8sNamespace   string            `yaml:"k8s-namespace" toml:"k8s-namespace"`
+	K8sContext     string            `yaml:"k8s-context" toml:"k8s-context"`
+	DockerDir      string            `yaml:"docker-secrets-dir" toml:"docker-secrets-dir"`
+}
+
+// findConfigFile looks for a config file in dir and its parents, up to the repository root (the first directory
+// with a .git entry). It returns an empty path if there is none.
+func findConfigFile(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		for _, name := range configFileNames {
+			path := filepath.Join(dir, name)
+			if _, err := os.Stat(path); err == nil {
+				return path, nil
+			}
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return "", nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// loadConfig reads the YAML or TOML (depending on its extension) config file at path. Its paths are
+// rewritten relative to the working directory.
+func loadConfig(path string) (config, error) {
+	var c config
+
+	if strings.HasSuffix(path, ".toml") {
+		if _, err := toml.DecodeFile(path, &c); err != nil {
+			return c, fmt.Errorf("Invalid config file [%s]: %s", path, err)
+		}
+	} else {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return c, err
+		}
+		if err := yaml.Unmarshal(data, &c); err != nil {
+			return c, fmt.Errorf("Invalid config file [%s]: %s", path, err)
+		}
+	}
+
+	return c.relativeTo(filepath.Dir(path))
+}
+
+// relativeTo returns c with its paths, relative to dir, rewritten relative to the working directory
+func (c config) relativeTo(dir string) (config, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return c, err
+	}
+
+	rel := func(path string) string {
+		if filepath.IsAbs(path) {
+			return path
+		}
+		path = filepath.Join(dir, path)
+		if r, err := filepath.Rel(wd, path); err == nil {
+			return r
+		}
+		return path
+	}
+	relAll := func(paths []string) []string {
+		result := make([]string, len(paths))
+		for i, path := range paths {
+			result[i] = rel(path)
+		}
+		return result
+	}
+
+	c.Templates = relAll(c.Templates)
+	if c.OutputDir != "" {
+		c.OutputDir = rel(c.OutputDir)
+	}
+	if c.Manifest != "" {
+		c.Manifest = rel(c.Manifest)
+	}
+	if c.AuditLog != "" {
+		c.AuditLog = rel(c.AuditLog)
+	}
+	if c.Attestation != "" {
+		c.Attestation = rel(c.Attestation)
+	}
+	if c.Lockfile != "" {
+		c.Lockfile = rel(c.Lockfile)
+	}
+	if c.BackupDir != "" {
+		c.BackupDir = rel(c.BackupDir)
+	}
+	c.EnvFiles = relAll(c.EnvFiles)
+	c.SecretsFiles = relAll(c.SecretsFiles)
+	for name, module := range c.WasmModules {
+		c.WasmModules[name] = rel(module)
+	}
+
+	// The files of envfile and secretsfile sources are relative to the config file too
+	relSpec := func(spec string) string {
+		for _, name := range []string{"envfile:", "secretsfile:"} {
+			if strings.HasPrefix(spec, name) {
+				return name + rel(strings.TrimPrefix(spec, name))
+			}
+		}
+		return spec
+	}
+	sources := make([]string, len(c.Sources))
+	for i, spec := range c.Sources {
+		sources[i] = relSpec(spec)
+	}
+	c.Sources = sources
+	if c.KeySources != nil {
+		keySources := make(map[string]string, len(c.KeySources))
+		for key, spec := range c.KeySources {
+			keySources[key] = relSpec(spec)
+		}
+		c.KeySources = keySources
+	}
+
+	for name, profile := range c.Profiles {
+		if c.Profiles[name], err = profile.relativeTo(dir); err != nil {
+			return c, err
+		}
+	}
+
+	// Outputs are looked up by the key of the absolute input path, see pathKey
+	outputs := make(map[string]string, len(c.Outputs))
+	for input, out := range c.Outputs {
+		if !filepath.IsAbs(input) {
+			if input, err = filepath.Abs(filepath.Join(dir, input)); err != nil {
+				return c, err
+			}
+		}
+		outputs[pathKey(input)] = rel(out)
+	}
+	c.Outputs = outputs
+
+	return c, nil
+}
+
+// apply returns opts with the settings of c for those not set on the command line
+func (c config) apply(opts options) options {
+	if opts.keys == "" {
+		opts.keys = strings.Join(c.Keys, ",")
+	}
+	if opts.optionalKeys == "" {
+		opts.optionalKeys = strings.Join(c.OptionalKeys, ",")
+	}
+	if len(opts.paths) == 0 {
+		opts.paths = c.Templates
+	}
+	opts.prefix = valueOr(opts.prefix, c.Prefix)
+	opts.suffix = valueOr(opts.suffix, c.Suffix)
+	opts.mode = valueOr(opts.mode, c.Mode)
+	opts.transforms = mergeMaps(c.Transforms, opts.transforms)
+	opts.wasmModules = mergeMaps(c.WasmModules, opts.wasmModules)
+	opts.types = mergeMaps(c.Types, opts.types)
+	opts.engine = valueOr(opts.engine, c.Engine)
+	opts.logLevel = valueOr(opts.logLevel, c.LogLevel)
+	opts.logFormat = valueOr(opts.logFormat, c.LogFormat)
+	opts.listDelimiter = valueOr(opts.listDelimiter, c.ListDelimiter)
+	opts.prefixMaps = mergeMaps(c.PrefixMaps, opts.prefixMaps)
+	opts.keyMap = mergeMaps(c.Map, opts.keyMap)
+	opts.values = mergeMaps(c.Values, opts.values)
+	if len(c.Rules) > 0 {
+		rules := make(map[string]keyRule, len(c.Rules)+len(opts.rules))
+		for key, rule := range c.Rules {
+			rules[key] = rule
+		}
+		// Rules of a profile, applied first, win
+		for key, rule := range opts.rules {
+			rules[key] = rule
+		}
+		opts.rules = rules
+	}
+	if opts.precedence == "" {
+		opts.precedence = strings.Join(c.Precedence, ",")
+	}
+	opts.server = valueOr(opts.server, c.Server)
+	opts.obfuscate = valueOr(opts.obfuscate, c.Obfuscate)
+	opts.envFallback = opts.boolOr("env-fallback", opts.envFallback, c.EnvFallback)
+	if opts.split == 0 {
+		opts.split = c.Split
+	}
+	opts.escape = opts.boolOr("escape", opts.escape, c.Escape)
+	opts.format = opts.boolOr("format", opts.format, c.Format)
+	opts.validate = opts.boolOr("validate", opts.validate, c.Validate)
+	opts.strict = opts.boolOr("strict", opts.strict, c.Strict)
+	opts.enforceRotate = opts.boolOr("enforce-rotation", opts.enforceRotate, c.EnforceRotation)
+	opts.reproducible = opts.boolOr("reproducible", opts.reproducible, c.Reproducible)
+	opts.requiredVersion = valueOr(opts.requiredVersion, c.RequiredVersion)
+	opts.fingerprint = opts.boolOr("fingerprint", opts.fingerprint, c.Fingerprint)
+	opts.noHeader = opts.boolOr("no-header", opts.noHeader, c.NoHeader)
+	opts.headerTemplate = valueOr(opts.headerTemplate, c.HeaderTemplate)
+	opts.buildTags = valueOr(opts.buildTags, c.BuildTags)
+	opts.fileMode = valueOr(opts.fileMode, c.FileMode)
+	opts.lineEndings = valueOr(opts.lineEndings, c.LineEndings)
+	opts.lineDirectives = opts.boolOr("line-directives", opts.lineDirectives, c.LineDirectives)
+	if opts.templateExt == "" {
+		opts.templateExt = templateExtension(c.TemplateExt)
+	}
+	if len(opts.excludes) == 0 {
+		opts.excludes = c.Excludes
+	}
+	opts.includeSkipped = opts.boolOr("include-skipped", opts.includeSkipped, c.IncludeSkipped)
+	opts.gitignore = opts.boolOr("gitignore", opts.gitignore, c.Gitignore)
+	opts.includeHidden = opts.boolOr("include-hidden", opts.includeHidden, c.IncludeHidden)
+	if opts.maxDepth == 0 {
+		opts.maxDepth = c.MaxDepth
+	}
+	opts.maxFileSize = valueOr(opts.maxFileSize, c.MaxFileSize)
+	opts.followSymlinks = opts.boolOr("follow-symlinks", opts.followSymlinks, c.FollowSymlinks)
+	if opts.jobs == 0 {
+		opts.jobs = c.Jobs
+	}
+	if len(opts.sources) == 0 {
+		opts.sources = c.Sources
+	}
+	opts.keySources = mergeMaps(c.KeySources, opts.keySources)
+	if len(opts.envFiles) == 0 {
+		opts.envFiles = c.EnvFiles
+	}
+	if len(opts.secretsFiles) == 0 {
+		opts.secretsFiles = c.SecretsFiles
+	}
+	opts.outputDir = valueOr(opts.outputDir, c.OutputDir)
+	opts.copyOthers = opts.boolOr("copy-others", opts.copyOthers, c.CopyOthers)
+	opts.outputPattern = valueOr(opts.outputPattern, c.OutputPattern)
+	opts.manifest = valueOr(opts.manifest, c.Manifest)
+	opts.lockfile = valueOr(opts.lockfile, c.Lockfile)
+	opts.auditLog = valueOr(opts.auditLog, c.AuditLog)
+	if len(opts.postHooks) == 0 {
+		opts.postHooks = c.PostHooks
+	}
+	opts.attestation = valueOr(opts.attestation, c.Attestation)
+	opts.signAttestation = opts.boolOr("sign-attestation", opts.signAttestation, c.SignAttestation)
+	opts.backup = opts.boolOr("backup", opts.backup, c.Backup)
+	opts.backupDir = valueOr(opts.backupDir, c.BackupDir)
+	opts.lang = valueOr(opts.lang, c.Lang)
+	opts.outputs = mergeMaps(c.Outputs, opts.outputs)
+
+	o := c.Options
+	opts.opRefTemplate = valueOr(opts.opRefTemplate, o.OpRef)
+	opts.opRefs = mergeMaps(o.OpItems, opts.opRefs)
+	opts.vaultAddr = valueOr(opts.vaultAddr, o.VaultAddr)
+	opts.vaultPath = valueOr(opts.vaultPath, o.VaultPath)
+	opts.awsSecretId = valueOr(opts.awsSecretId, o.AwsSecretId)
+	opts.awsSecrets = mergeMaps(o.AwsSecrets, opts.awsSecrets)
+	opts.ssmPath = valueOr(opts.ssmPath, o.SsmPath)
+	opts.ssmParams = mergeMaps(o.SsmParams, opts.ssmParams)
+	opts.gcpProject = valueOr(opts.gcpProject, o.GcpProject)
+	opts.gcpSecrets = mergeMaps(o.GcpSecrets, opts.gcpSecrets)
+	opts.azureVault = valueOr(opts.azureVault, o.AzureVaultName)
+	opts.azureSecrets = mergeMaps(o.AzureSecrets, opts.azureSecrets)
+	opts.dopplerProject = valueOr(opts.dopplerProject, o.DopplerProject)
+	opts.dopplerConfig = valueOr(opts.dopplerConfig, o.DopplerConfig)
+	opts.infisicalID = valueOr(opts.infisicalID, o.InfisicalID)
+	opts.infisicalEnv = valueOr(opts.infisicalEnv, o.InfisicalEnv)
+	opts.infisicalPath = valueOr(opts.infisicalPath, o.InfisicalPath)
+	opts.infisicalURL = valueOr(opts.infisicalURL, o.InfisicalURL)
+	opts.bwsProject = valueOr(opts.bwsProject, o.BwsProject)
+	opts.bwsSecrets = mergeMaps(o.BwsSecrets, opts.bwsSecrets)
+	opts.passPath = valueOr(opts.passPath, o.PassPath)
+	opts.k8sSecret = valueOr(opts.k8sSecret, o.K8sSecret)
+	opts.k8sNamespace = valueOr(opts.k8sNamespace, o.K8sNamespace)
+	opts.k8sContext = valueOr(opts.k8sContext, o.K8sContext)
+	opts.dockerDir = valueOr(opts.dockerDir, o.DockerDir)
+
+	return opts
+}
+
+// withConfig returns opts completed with the config file at path or, if path is empty, the one found from the
+// working directory, and its profile selected by opts. opts is returned as is if there is no config file.
+func withConfig(path string, opts options) (options, error) {
+	// Keys of --keys-file are given on the command line, overriding the ones of the config file
+	opts, err := withKeysFile(opts)
+	if err != nil {
+		return opts, err
+	}
+	if path == "" {
+		var err error
+		if path, err = findConfigFile("."); err != nil {
+			return opts, err
+		}
+		if path == "" && opts.profile != "" {
+			return opts, fmt.Errorf("Profile [%s] needs a config file declaring it", opts.profile)
+		}
+		if path == "" {
+			return opts, nil
+		}
+	}
+
+	c, err := loadConfig(path)
+	if err != nil {
+		return opts, err
+	}
+
+	if opts.profile != "" {
+		profile, ok := c.Profiles[opts.profile]
+		if !ok {
+			return opts, fmt.Errorf("Unknown profile [%s] in config file [%s]", opts.profile, path)
+		}
+		// Settings are only taken from the config when unset, so the ones of the profile win
+		opts = profile.apply(opts)
+	}
+	return c.apply(opts), nil
+}
+
+// boolOr returns value, the one of the bool flag name, if it's set on the command line and else value or
+// configured, the one of the config file, so that --no-strict overrides strict: true
+func (opts options) boolOr(name string, value bool, configured bool) bool {
+	if opts.setFlags[name] {
+		return value
+	}
+	return value || configured
+}
+
+// flagsSetOn returns the names of the flags set by args, the command line of app
+func flagsSetOn(app *kingpin.Application, args []string) map[string]bool {
+	set := make(map[string]bool)
+	context, err := app.ParseContext(args)
+	if err != nil {
+		return set
+	}
+	for _, element := range context.Elements {
+		if flag, ok := element.Clause.(*kingpin.FlagClause); ok {
+			set[flag.Model().Name] = true
+		}
+	}
+	return set
+}
+
+// mergeMaps returns the entries of base and overrides, the entries of overrides taking precedence
+func mergeMaps(base map[string]string, overrides map[string]string) map[string]string {
+	if len(base) == 0 {
+		return overrides
+	}
+
+	merged := make(map[string]string, len(base)+len(overrides))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range overrides {
+		merged[k] = v
+	}
+	return merged
+}
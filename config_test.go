@@ -0,0 +1,261 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/kingpin"
+)
+
+func TestLoadYAMLConfig(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	configFile := filepath.Join(tempDir, "safekeeper.yaml")
+	content := `keys: [CLIENT_ID, CLIENT_SECRET]
+sources:
+  - env
+  - envfile:.env
+key-sources:
+  DB_PASSWORD: vault
+  FEATURE_FLAGS: envfile:flags.env
+templates:
+  - internal/**/*.go
+options:
+  vault-path: secret/data/myapp
+  op-item:
+    CLIENT_ID: op://Work/client/id
+`
+	if err := ioutil.WriteFile(configFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := loadConfig(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(c.Keys, []string{"CLIENT_ID", "CLIENT_SECRET"}) {
+		t.Errorf("Unexpected keys %v", c.Keys)
+	}
+	if len(c.Sources) != 2 || c.Sources[0] != "env" || !samePath(strings.TrimPrefix(c.Sources[1], "envfile:"), filepath.Join(tempDir, ".env")) {
+		t.Errorf("Expected env file source relative to the config file but got %v", c.Sources)
+	}
+	if c.KeySources["DB_PASSWORD"] != "vault" || !samePath(strings.TrimPrefix(c.KeySources["FEATURE_FLAGS"], "envfile:"), filepath.Join(tempDir, "flags.env")) {
+		t.Errorf("Expected the env file of a key relative to the config file but got %v", c.KeySources)
+	}
+	if len(c.Templates) != 1 || !samePath(c.Templates[0], filepath.Join(tempDir, "internal", "**", "*.go")) {
+		t.Errorf("Expected template glob relative to the config file but got %v", c.Templates)
+	}
+	if c.Options.VaultPath != "secret/data/myapp" || c.Options.OpItems["CLIENT_ID"] != "op://Work/client/id" {
+		t.Errorf("Unexpected options %+v", c.Options)
+	}
+}
+
+func TestLoadTOMLConfig(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	configFile := filepath.Join(tempDir, ".safekeeper.toml")
+	content := `keys = ["CLIENT_ID"]
+sources = ["vault"]
+
+[options]
+vault-addr = "https://vault.example.com"
+`
+	if err := ioutil.WriteFile(configFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := loadConfig(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(c.Keys, []string{"CLIENT_ID"}) || !reflect.DeepEqual(c.Sources, []string{"vault"}) {
+		t.Errorf("Unexpected keys %v or sources %v", c.Keys, c.Sources)
+	}
+	if c.Options.VaultAddr != "https://vault.example.com" {
+		t.Errorf("Unexpected vault address [%s]", c.Options.VaultAddr)
+	}
+}
+
+func TestFindConfigFile(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	repoDir := filepath.Join(tempDir, "repo")
+	packageDir := filepath.Join(repoDir, "internal", "config")
+	for _, dir := range []string{filepath.Join(repoDir, ".git"), packageDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Config files above the repository root are ignored
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "safekeeper.yaml"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	path, err := findConfigFile(packageDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != "" {
+		t.Errorf("Expected no config file outside of the repository but got [%s]", path)
+	}
+
+	configFile := filepath.Join(repoDir, "safekeeper.yaml")
+	if err := ioutil.WriteFile(configFile, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	path, err = findConfigFile(packageDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !samePath(path, configFile) {
+		t.Errorf("Expected config file [%s] at the repository root but got [%s]", configFile, path)
+	}
+}
+
+func TestFlagsOverrideConfig(t *testing.T) {
+	c := config{
+		Keys:    []string{"CLIENT_ID"},
+		Sources: []string{"vault"},
+		Options: configOptions{VaultPath: "secret/data/config", OpItems: map[string]string{"CLIENT_ID": "op://config", "CLIENT_SECRET": "op://config"}},
+	}
+
+	opts := c.apply(options{keys: "CLIENT_SECRET", opRefs: map[string]string{"CLIENT_ID": "op://flag"}})
+
+	if opts.keys != "CLIENT_SECRET" {
+		t.Errorf("Expected --keys to override the config keys but got [%s]", opts.keys)
+	}
+	if !reflect.DeepEqual(opts.sources, []string{"vault"}) || opts.vaultPath != "secret/data/config" {
+		t.Errorf("Expected unset flags to come from the config but got sources %v and vault path [%s]", opts.sources, opts.vaultPath)
+	}
+	expectedRefs := map[string]string{"CLIENT_ID": "op://flag", "CLIENT_SECRET": "op://config"}
+	if !reflect.DeepEqual(opts.opRefs, expectedRefs) {
+		t.Errorf("Expected op references %v but got %v", expectedRefs, opts.opRefs)
+	}
+}
+
+func TestBoolFlagsOverrideConfig(t *testing.T) {
+	cases := []struct {
+		args       []string
+		configured bool
+		expected   bool
+	}{
+		{[]string{"--no-strict"}, true, false},
+		{[]string{"--strict"}, false, true},
+		{nil, true, true},
+		{nil, false, false},
+	}
+
+	for _, c := range cases {
+		opts := options{strict: len(c.args) > 0 && c.args[0] == "--strict", setFlags: flagsSetOn(kingpin.CommandLine, c.args)}
+		if opts = (config{Strict: c.configured}).apply(opts); opts.strict != c.expected {
+			t.Errorf("Expected strict to be %t with %v and strict: %t in the config file but got %t", c.expected, c.args, c.configured, opts.strict)
+		}
+	}
+}
+
+func TestConfigOutputs(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	template, err := writeTestTemplate(tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	configFile := filepath.Join(tempDir, "safekeeper.yaml")
+	content := "keys: [CLIENT_ID]\noutputs:\n  secrets.go: generated.go\n"
+	if err := ioutil.WriteFile(configFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("CLIENT_ID", "safeid")
+	defer os.Unsetenv("CLIENT_ID")
+
+	opts, err := withConfig(configFile, options{paths: []string{strings.TrimSuffix(template, templateExt)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := run(opts); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(tempDir, "generated.go")); err != nil {
+		t.Errorf("Expected the output declared in the config file to be generated but got [%s]", err)
+	}
+}
+
+// samePath reports whether a and b, relative to the working directory or absolute, are the same path
+func samePath(a string, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+func TestConfigProfiles(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	configFile := filepath.Join(tempDir, "safekeeper.yaml")
+	content := `keys: [API_URL, TOKEN]
+env-files: [dev.env]
+values:
+  API_URL: https://dev.example.com
+profiles:
+  staging:
+    env-files: [staging.env]
+    values:
+      API_URL: https://staging.example.com
+`
+	if err := ioutil.WriteFile(configFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts, err := withConfig(configFile, options{profile: "staging"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.keys != "API_URL,TOKEN" {
+		t.Errorf("Expected the keys of the config file outside of the profile but got [%s]", opts.keys)
+	}
+	if len(opts.envFiles) != 1 || !samePath(opts.envFiles[0], filepath.Join(tempDir, "staging.env")) {
+		t.Errorf("Expected the env files of the profile, relative to the config file, but got %v", opts.envFiles)
+	}
+	if opts.values["API_URL"] != "https://staging.example.com" {
+		t.Errorf("Expected the values of the profile to override the other ones but got %v", opts.values)
+	}
+
+	if opts, err = withConfig(configFile, options{}); err != nil {
+		t.Fatal(err)
+	}
+	if opts.values["API_URL"] != "https://dev.example.com" || len(opts.envFiles) != 1 || !samePath(opts.envFiles[0], filepath.Join(tempDir, "dev.env")) {
+		t.Errorf("Expected the settings outside of profiles without --profile but got %v and %v", opts.values, opts.envFiles)
+	}
+
+	if _, err := withConfig(configFile, options{profile: "prod"}); err == nil || !strings.Contains(err.Error(), "Unknown profile [prod]") {
+		t.Errorf("Expected an unknown profile to fail but got [%v]", err)
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+// packageTypes are the types of the accessors of a generated config package, string being the default
+var packageTypes = map[string]string{
+	"string":   "string",
+	"int":      "int",
+	"int64":    "int64",
+	"float64":  "float64",
+	"bool":     "bool",
+	"duration": "time.Duration",
+}
+
+// zeroValues are the zero values of the accessor types, returned for optional keys without a value
+var zeroValues = map[string]string{
+	"string":   `""`,
+	"int":      "0",
+	"int64":    "0",
+	"float64":  "0",
+	"bool":     "false",
+	"duration": "0",
+}
+
+// initialisms are the words of key names kept in upper case in accessor names, as golint expects
+var initialisms = map[string]bool{
+	"API": true, "AWS": true, "DB": true, "DNS": true, "GCP": true, "HTTP": true, "HTTPS": true, "ID": true,
+	"IP": true, "JSON": true, "JWT": true, "SQL": true, "SSH": true, "SSL": true, "TLS": true, "TTL": true,
+	"URI": true, "URL": true, "UUID": true, "XML": true,
+}
+
+// packageKey is a key of a generated config package
+type packageKey struct {
+	name string
+	// typ is the name of the type of the key, as given in --keys
+	typ      string
+	optional bool
+}
+
+// parsePackageKeys parses the comma-delimited KEY[:type][?] key specs of a config package
+func parsePackageKeys(specs string) ([]packageKey, error) {
+	var keys []packageKey
+	for _, spec := range strings.Split(specs, ",") {
+		if spec == "" {
+			continue
+		}
+
+		key := packageKey{typ: "string"}
+		if strings.HasSuffix(spec, "?") {
+			key.optional = true
+			spec = strings.TrimSuffix(spec, "?")
+		}
+		key.name = spec
+		if i := strings.Index(spec, ":"); i >= 0 {
+			key.name, key.typ = spec[:i], spec[i+1:]
+		}
+		if _, ok := packageTypes[key.typ]; !ok {
+			return nil, fmt.Errorf("Unknown type [%s] for key [%s], expected one of string, int, int64, float64, bool or duration", key.typ, key.name)
+		}
+		keys = append(keys, key)
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("--keys is required to generate a config package")
+	}
+	return keys, nil
+}
+
+// goName returns the exported Go name of key, e.g. APIKey for API_KEY and MaxConns for MAX_CONNS
+func goName(key string) string {
+	var name string
+	for _, word := range strings.Split(key, "_") {
+		if word == "" {
+			continue
+		}
+		word = strings.ToUpper(word)
+		if !initialisms[word] {
+			word = word[:1] + strings.ToLower(word[1:])
+		}
+		name += word
+	}
+
+	if name == "" || unicode.IsDigit(rune(name[0])) {
+		return "Key" + name
+	}
+	return name
+}
+
+// goLiteral returns value as a Go literal of the type of key. Invalid values are reported without being
+// quoted since they're secrets.
+func goLiteral(key packageKey, value string) (string, error) {
+	// Optional keys without a value get the zero value of their type
+	if value == "" && key.optional {
+		return zeroValues[key.typ], nil
+	}
+
+	invalid := fmt.Errorf("Value of [%s] isn't a valid %s", key.name, key.typ)
+
+	switch key.typ {
+	case "int", "int64":
+		bitSize := 0
+		if key.typ == "int64" {
+			bitSize = 64
+		}
+		i, err := strconv.ParseInt(value, 10, bitSize)
+		if err != nil {
+			return "", invalid
+		}
+		return strconv.FormatInt(i, 10), nil
+	case "float64":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "", invalid
+		}
+		return strconv.FormatFloat(f, 'g', -1, 64), nil
+	case "bool":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", invalid
+		}
+		return strconv.FormatBool(b), nil
+	case "duration":
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return "", invalid
+		}
+		return fmt.Sprintf("time.Duration(%d)", d), nil
+	default:
+		return strconv.Quote(value), nil
+	}
+}
+
+// packageNameOf returns the name of the package of the file at path, named after its directory
+func packageNameOf(path string) string {
+	if path == stdio {
+		return "config"
+	}
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return "config"
+	}
+
+	var name []rune
+	for _, r := range strings.ToLower(filepath.Base(dir)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			name = append(name, r)
+		}
+	}
+	if len(name) == 0 || unicode.IsDigit(name[0]) {
+		return "config"
+	}
+	return string(name)
+}
+
+// writeConfigPackage returns the source of the package named name with an accessor returning the value of each
+// of keys. The go:generate line regenerates it with specs, the key specs, and the explicit name, if any.
+func writeConfigPackage(name string, explicitName bool, specs string, keys []packageKey, values map[string]string) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := safekeeper.WriteGeneratedComment(&buffer); err != nil {
+		return nil, err
+	}
+
+	generateLine := "//go:generate safekeeper package --keys=" + specs
+	if explicitName {
+		generateLine += " --name=" + name
+	}
+	fmt.Fprintf(&buffer, "%s --output=$GOFILE\n\n", generateLine)
+
+	names := make([]string, len(keys))
+	usesTime := false
+	for i, key := range keys {
+		names[i] = key.name
+		usesTime = usesTime || key.typ == "duration"
+	}
+	fmt.Fprintf(&buffer, "// Package %s provides the values of %s\n", name, strings.Join(names, ", "))
+	fmt.Fprintf(&buffer, "package %s\n\n", name)
+	if usesTime {
+		buffer.WriteString("import \"time\"\n\n")
+	}
+
+	for _, key := range keys {
+		literal, err := goLiteral(key, values[key.name])
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&buffer, "// %s returns the value of %s\n", goName(key.name), key.name)
+		fmt.Fprintf(&buffer, "func %s() %s {\n\treturn %s\n}\n\n", goName(key.name), packageTypes[key.typ], literal)
+	}
+
+	return formatSource(buffer.Bytes())
+}
+
+// generateConfigPackage generates the Go package named name (after the directory of the output if empty) with
+// a typed accessor per key of opts, or the constant file of the language of opts, writing it to the output of opts
+func generateConfigPackage(opts options, name string) error {
+	opts, cancel := opts.withContext()
+	defer cancel()
+	keys, err := parsePackageKeys(opts.keys)
+	if err != nil {
+		return err
+	}
+	if opts.output == "" {
+		return errors.New("--output is required to generate a config package")
+	}
+	if _, ok := constantSyntaxes[opts.lang]; !ok && opts.lang != "" && opts.lang != langGo {
+		return fmt.Errorf("The package command generates %s, %s, %s or %s files, not --lang=%s", langGo, langJS, langPython, langJava, opts.lang)
+	}
+
+	source, err := newSecretSource(opts)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, len(keys))
+	optional := parseOptionalKeys(opts.optionalKeys)
+	for i, key := range keys {
+		names[i] = key.name
+		optional[key.name] = optional[key.name] || key.optional
+	}
+	for i := range keys {
+		keys[i].optional = optional[keys[i].name]
+	}
+
+	values, err := loadKeyValues(opts.context(), names, source, nil, optional)
+	if err != nil {
+		return err
+	}
+
+	var src []byte
+	switch opts.lang {
+	case "", langGo:
+		explicitName := name != ""
+		if !explicitName {
+			name = packageNameOf(opts.output)
+		}
+		src, err = writeConfigPackage(name, explicitName, opts.keys, keys, values)
+	default:
+		src, err = writeConstants(opts.lang, name, opts.keys, keys, values, opts.output)
+	}
+	if err != nil {
+		return err
+	}
+
+	if opts.output == stdio {
+		stdout := opts.stdout
+		if stdout == nil {
+			stdout = os.Stdout
+		}
+		_, err := stdout.Write(src)
+		return withExitCode(exitWriteFailure, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(opts.output), 0755); err != nil {
+		return withExitCode(exitWriteFailure, err)
+	}
+	return withExitCode(exitWriteFailure, writeFileAtomic(opts.output, src, defaultFileMode))
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestGoName(t *testing.T) {
+	cases := map[string]string{
+		"API_KEY":   "APIKey",
+		"MAX_CONNS": "MaxConns",
+		"CLIENT_ID": "ClientID",
+		"db_url":    "DBURL",
+		"2FA_SEED":  "Key2faSeed",
+	}
+	for key, expected := range cases {
+		if name := goName(key); name != expected {
+			t.Errorf("Expected Go name [%s] for [%s] but got [%s]", expected, key, name)
+		}
+	}
+}
+
+func TestConfigPackage(t *testing.T) {
+	for key, value := range map[string]string{"API_KEY": "se\"cret", "MAX_CONNS": "10", "TIMEOUT": "1m30s"} {
+		os.Setenv(key, value)
+		defer os.Unsetenv(key)
+	}
+
+	var stdout bytes.Buffer
+	opts := options{keys: "API_KEY,MAX_CONNS:int,TIMEOUT:duration,DEBUG:bool?", output: stdio, stdout: &stdout}
+	if err := generateConfigPackage(opts, "config"); err != nil {
+		t.Fatal(err)
+	}
+
+	src := stdout.String()
+	for _, expected := range []string{
+		"//go:generate safekeeper package --keys=API_KEY,MAX_CONNS:int,TIMEOUT:duration,DEBUG:bool? --name=config --output=$GOFILE",
+		"package config",
+		"import \"time\"",
+		"func APIKey() string {\n\treturn \"se\\\"cret\"\n}",
+		"func MaxConns() int {\n\treturn 10\n}",
+		"func Timeout() time.Duration {\n\treturn time.Duration(90000000000)\n}",
+		"func Debug() bool {\n\treturn false\n}",
+	} {
+		if !strings.Contains(src, expected) {
+			t.Errorf("Generated package should contain [%s] but was:\n\n%s", expected, src)
+		}
+	}
+}
+
+func TestConfigPackageInvalidValue(t *testing.T) {
+	os.Setenv("MAX_CONNS", "many")
+	defer os.Unsetenv("MAX_CONNS")
+
+	err := generateConfigPackage(options{keys: "MAX_CONNS:int", output: stdio, stdout: &bytes.Buffer{}}, "")
+	if err == nil || err.Error() != "Value of [MAX_CONNS] isn't a valid int" {
+		t.Errorf("Expected the invalid value to be reported without its value but got [%v]", err)
+	}
+}
+
+func TestConfigPackageUnknownType(t *testing.T) {
+	if _, err := parsePackageKeys("MAX_CONNS:uint8"); err == nil {
+		t.Error("Expected an unknown type to be rejected")
+	}
+}
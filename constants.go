@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+// Languages of the constant files of the package command, besides Go packages
+const (
+	langJS     = "js"
+	langPython = "python"
+	langJava   = "java"
+)
+
+// constantSyntax is how a language declares the constants of a generated file
+type constantSyntax struct {
+	comment string
+	// declaration is the format of a constant declaration, given its type, name and literal
+	declaration func(typ string, name string, literal string) string
+	// trues and falses are the literals of booleans
+	trues, falses string
+	// duration returns the literal of a duration, in the idiom of the language
+	duration func(d time.Duration) string
+	// longSuffix ends the literals of int64 values
+	longSuffix string
+}
+
+// constantSyntaxes are the syntaxes of the languages of constant files
+var constantSyntaxes = map[string]constantSyntax{
+	langJS: {
+		comment: "//",
+		declaration: func(_ string, name string, literal string) string {
+			return "export const " + name + " = " + literal + ";"
+		},
+		trues: "true", falses: "false",
+		// Durations are numbers of milliseconds, as taken by setTimeout
+		duration: func(d time.Duration) string { return strconv.FormatInt(int64(d/time.Millisecond), 10) },
+	},
+	langPython: {
+		comment:     "#",
+		declaration: func(_ string, name string, literal string) string { return name + " = " + literal },
+		trues:       "True", falses: "False",
+		// Durations are numbers of seconds, as taken by time.sleep
+		duration: func(d time.Duration) string { return strconv.FormatFloat(d.Seconds(), 'g', -1, 64) },
+	},
+	langJava: {
+		comment: "//",
+		declaration: func(typ string, name string, literal string) string {
+			return "    public static final " + javaTypes[typ] + " " + name + " = " + literal + ";"
+		},
+		trues: "true", falses: "false",
+		duration:   func(d time.Duration) string { return fmt.Sprintf("java.time.Duration.ofNanos(%dL)", int64(d)) },
+		longSuffix: "L",
+	},
+}
+
+// javaTypes are the Java types of the types of package keys
+var javaTypes = map[string]string{
+	"string":   "String",
+	"int":      "int",
+	"int64":    "long",
+	"float64":  "double",
+	"bool":     "boolean",
+	"duration": "java.time.Duration",
+}
+
+// constantLiteral returns value as a literal of the type of key in the language of syntax, validated as the Go
+// accessor of the key would be
+func constantLiteral(syntax constantSyntax, key packageKey, value string) (string, error) {
+	goValue, err := goLiteral(key, value)
+	if err != nil {
+		return "", err
+	}
+
+	switch key.typ {
+	case "bool":
+		if goValue == "true" {
+			return syntax.trues, nil
+		}
+		return syntax.falses, nil
+	case "duration":
+		d, _ := time.ParseDuration(value)
+		return syntax.duration(d), nil
+	case "int64":
+		return goValue + syntax.longSuffix, nil
+	case "string":
+		// JSON strings are valid string literals of all the languages
+		literal, err := json.Marshal(value)
+		return string(literal), err
+	default:
+		return goValue, nil
+	}
+}
+
+// javaClassName returns the name of the class of the Java file at path, its base name
+func javaClassName(path string) string {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if path == stdio || name == "" || !unicode.IsLetter(rune(name[0])) {
+		return "Config"
+	}
+	return name
+}
+
+// writeConstants returns the source of the constant file in lang declaring the value of each of keys, named as
+// the keys. Java constants are members of the class named after output, in the package name if not empty.
+func writeConstants(lang string, name string, specs string, keys []packageKey, values map[string]string, output string) ([]byte, error) {
+	syntax := constantSyntaxes[lang]
+
+	var buffer bytes.Buffer
+	if err := safekeeper.WriteGeneratedNotice(&buffer, syntax.comment, ""); err != nil {
+		return nil, err
+	}
+	regenerate := "safekeeper package --keys=" + specs + " --lang=" + lang
+	if name != "" {
+		regenerate += " --name=" + name
+	}
+	fmt.Fprintf(&buffer, "%s Regenerate with: %s --output=%s\n\n", syntax.comment, regenerate, filepath.Base(output))
+
+	if lang == langJava {
+		if name != "" {
+			fmt.Fprintf(&buffer, "package %s;\n\n", name)
+		}
+		fmt.Fprintf(&buffer, "public final class %s {\n    private %s() {}\n\n", javaClassName(output), javaClassName(output))
+	}
+	for _, key := range keys {
+		literal, err := constantLiteral(syntax, key, values[key.name])
+		if err != nil {
+			return nil, err
+		}
+		buffer.WriteString(syntax.declaration(key.typ, key.name, literal) + "\n")
+	}
+	if lang == langJava {
+		buffer.WriteString("}\n")
+	}
+
+	return buffer.Bytes(), nil
+}
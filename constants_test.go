@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestConstantFiles(t *testing.T) {
+	specs := "API_KEY,MAX_CONNS:int,TIMEOUT:duration,DEBUG:bool"
+	keys, err := parsePackageKeys(specs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := map[string]string{"API_KEY": "se\"cret", "MAX_CONNS": "10", "TIMEOUT": "1m30s", "DEBUG": "true"}
+
+	cases := map[string][]string{
+		langJS: {
+			"// Regenerate with: safekeeper package --keys=API_KEY,MAX_CONNS:int,TIMEOUT:duration,DEBUG:bool --lang=js --output=Secrets.java",
+			"export const API_KEY = \"se\\\"cret\";\n",
+			"export const MAX_CONNS = 10;\n",
+			"export const TIMEOUT = 90000;\n",
+			"export const DEBUG = true;\n",
+		},
+		langPython: {
+			"# Regenerate with: safekeeper package",
+			"API_KEY = \"se\\\"cret\"\n",
+			"TIMEOUT = 90\n",
+			"DEBUG = True\n",
+		},
+		langJava: {
+			"public final class Secrets {",
+			"    public static final String API_KEY = \"se\\\"cret\";\n",
+			"    public static final int MAX_CONNS = 10;\n",
+			"    public static final java.time.Duration TIMEOUT = java.time.Duration.ofNanos(90000000000L);\n",
+			"    public static final boolean DEBUG = true;\n}\n",
+		},
+	}
+	for lang, expected := range cases {
+		src, err := writeConstants(lang, "", specs, keys, values, "config/Secrets.java")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range expected {
+			if !strings.Contains(string(src), e) {
+				t.Errorf("Generated %s file should contain [%s] but was:\n\n%s", lang, e, string(src))
+			}
+		}
+	}
+}
+
+func TestConstantFilesLang(t *testing.T) {
+	err := generateConfigPackage(options{keys: "API_KEY", output: stdio, lang: langText, stdout: &bytes.Buffer{}}, "")
+	if err == nil || !strings.Contains(err.Error(), "not --lang=text") {
+		t.Errorf("Expected --lang=text to be rejected by the package command but got [%v]", err)
+	}
+	if err := checkLang(options{lang: langJS}); err == nil {
+		t.Error("Expected --lang=js to be rejected when generating from templates")
+	}
+}
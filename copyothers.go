@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// findOthers walks dir like findTemplates and returns the paths of the files that are neither one of its templates
+// nor the output of one, for --copy-others
+func (t templateFinder) findOthers(dir string, templates []string) ([]string, error) {
+	generated := make(map[string]bool, len(templates))
+	for _, template := range templates {
+		generated[pathKey(t.ext.outputOf(template))] = true
+	}
+
+	var others []string
+	err := t.walk(dir, func(path string, info os.FileInfo, err error) error {
+		if !info.IsDir() && !t.ext.isTemplate(info.Name()) && !generated[pathKey(path)] {
+			others = append(others, path)
+		}
+		return nil
+	})
+	return others, err
+}
+
+// copyOther copies path, a file of a directory input that isn't a template, to out as is with its mode. Like
+// generated files, it's compared with out with --check and left out with --dry-run.
+func (g *generation) copyOther(path string, out string) error {
+	g.jobs.acquire()
+	defer g.jobs.release()
+	defer g.progress.step()
+	if err := contextErr(g.ctx); err != nil {
+		return err
+	}
+	// The output directory can be in the walked one, its files being copies already
+	outputDir, _ := filepath.Abs(g.outputDir)
+	absPath, _ := filepath.Abs(path)
+	if rel, err := filepath.Rel(outputDir, absPath); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if g.check {
+		if current, err := ioutil.ReadFile(out); err != nil || !bytes.Equal(current, content) {
+			g.log.fileInfof(out, "[%s] is out of date", out)
+			g.mu.Lock()
+			g.stale = append(g.stale, out)
+			g.mu.Unlock()
+		}
+		return nil
+	}
+	if g.dryRun {
+		g.log.fileInfof(out, "Would copy [%s] to [%s]", path, out)
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+		return withExitCode(exitWriteFailure, err)
+	}
+	if err := writeFileAtomic(out, content, info.Mode().Perm()); err != nil {
+		return withExitCode(exitWriteFailure, err)
+	}
+	g.log.fileInfof(out, "Copied [%s] to [%s]", path, out)
+	return nil
+}
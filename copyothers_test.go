@@ -0,0 +1,80 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestCopyOthers(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "copy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	input, outputDir := filepath.Join(tempDir, "templates"), filepath.Join(tempDir, "out")
+	files := map[string]string{
+		"config/secrets.go" + templateExt: "package config\n\nconst token = \"ENV_TOKEN\"\n",
+		// Generated next to its template before, it's generated rather than copied
+		"config/secrets.go":  "package config\n\nconst token = \"old\"\n",
+		"config/defaults.go": "package config\n\nconst region = \"ENV_REGION\"\n",
+		"scripts/run.sh":     "#!/bin/sh\necho run\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(input, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		mode := os.FileMode(0644)
+		if strings.HasSuffix(name, ".sh") {
+			mode = 0755
+		}
+		if err := ioutil.WriteFile(path, []byte(content), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.Setenv("TOKEN", "s3cr3t")
+	defer os.Unsetenv("TOKEN")
+
+	opts := options{keys: "TOKEN", paths: []string{input}, outputDir: outputDir, copyOthers: true, noHeader: true, quiet: true}
+	if err := run(opts); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"config/secrets.go":  "package config\n\nconst token = \"s3cr3t\"\n",
+		"config/defaults.go": files["config/defaults.go"],
+		"scripts/run.sh":     files["scripts/run.sh"],
+	}
+	for name, content := range expected {
+		copied, err := ioutil.ReadFile(filepath.Join(outputDir, filepath.FromSlash(name)))
+		if err != nil || string(copied) != content {
+			t.Errorf("Expected [%s] to be [%s] but got [%s] (%v)", name, content, copied, err)
+		}
+	}
+	if info, err := os.Stat(filepath.Join(outputDir, "scripts", "run.sh")); runtime.GOOS != "windows" && (err != nil || info.Mode().Perm() != 0755) {
+		t.Errorf("Expected the mode of the file to be kept but got %v (%v)", info.Mode(), err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "config", "secrets.go"+templateExt)); !os.IsNotExist(err) {
+		t.Errorf("Expected templates not to be copied but got [%v]", err)
+	}
+
+	// Copies are checked like generated files
+	opts.check = true
+	if err := run(opts); err != nil {
+		t.Errorf("Expected the output directory to be up to date but got [%v]", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(input, "scripts", "run.sh"), []byte("#!/bin/sh\necho changed\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(opts); err == nil || !strings.Contains(err.Error(), "run.sh") {
+		t.Errorf("Expected the changed file to be out of date but got [%v]", err)
+	}
+
+	if err := run(options{keys: "TOKEN", paths: []string{input}, copyOthers: true}); err == nil {
+		t.Error("Expected --copy-others to require --output-dir")
+	}
+}
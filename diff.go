@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around changes in a unified diff
+const diffContext = 3
+
+// edit is a line of an edit script: kept (' '), removed ('-') or added ('+')
+type edit struct {
+	op   byte
+	line string
+}
+
+// diffLines returns the shortest edit script turning a into b, using Myers' algorithm
+func diffLines(a []string, b []string) []edit {
+	n, m := len(a), len(b)
+	max := n + m
+	v := make([]int, 2*max+2)
+	var trace [][]int
+
+search:
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[max+k-1] < v[max+k+1]) {
+				x = v[max+k+1]
+			} else {
+				x = v[max+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			v[max+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// Walk the trace back from the end, trace[d] being the furthest reaching paths before step d
+	var edits []edit
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && v[max+k-1] < v[max+k+1]) {
+			prevK = k + 1
+		}
+		prevX := v[max+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			edits = append(edits, edit{op: ' ', line: a[x-1]})
+			x, y = x-1, y-1
+		}
+		if x == prevX {
+			edits = append(edits, edit{op: '+', line: b[y-1]})
+			y--
+		} else {
+			edits = append(edits, edit{op: '-', line: a[x-1]})
+			x--
+		}
+	}
+	for x > 0 && y > 0 {
+		edits = append(edits, edit{op: ' ', line: a[x-1]})
+		x, y = x-1, y-1
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+// splitLines splits text in lines, a trailing newline not starting an extra line
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// writeUnifiedDiff writes the unified diff between from and to, named fromName and toName, to w. Nothing is
+// written if they're the same.
+func writeUnifiedDiff(w io.Writer, fromName string, toName string, from string, to string) error {
+	edits := diffLines(splitLines(from), splitLines(to))
+
+	// Positions of each edit in from and to
+	fromPos, toPos := make([]int, len(edits)+1), make([]int, len(edits)+1)
+	var changes []int
+	for i, e := range edits {
+		fromPos[i+1], toPos[i+1] = fromPos[i], toPos[i]
+		if e.op != '+' {
+			fromPos[i+1]++
+		}
+		if e.op != '-' {
+			toPos[i+1]++
+		}
+		if e.op != ' ' {
+			changes = append(changes, i)
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	ew := &errWriter{w: w}
+	ew.printf("--- %s\n+++ %s\n", fromName, toName)
+
+	for i := 0; i < len(changes); {
+		start := changes[i] - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end := changes[i] + diffContext + 1
+		// Merge the following changes whose context overlaps
+		for i++; i < len(changes) && changes[i]-diffContext <= end; i++ {
+			end = changes[i] + diffContext + 1
+		}
+		if end > len(edits) {
+			end = len(edits)
+		}
+
+		fromStart, fromCount := fromPos[start]+1, fromPos[end]-fromPos[start]
+		toStart, toCount := toPos[start]+1, toPos[end]-toPos[start]
+		if fromCount == 0 {
+			fromStart--
+		}
+		if toCount == 0 {
+			toStart--
+		}
+		ew.printf("@@ -%d,%d +%d,%d @@\n", fromStart, fromCount, toStart, toCount)
+		for _, e := range edits[start:end] {
+			ew.printf("%c%s\n", e.op, e.line)
+		}
+	}
+
+	return ew.err
+}
+
+// errWriter is a writer that remembers the first error it encountered and ignores all writes after it
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) printf(format string, args ...interface{}) {
+	if ew.err != nil {
+		return
+	}
+	_, ew.err = fmt.Fprintf(ew.w, format, args...)
+}
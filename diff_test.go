@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteUnifiedDiff(t *testing.T) {
+	from := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\n"
+	to := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\n"
+
+	var output bytes.Buffer
+	if err := writeUnifiedDiff(&output, "old", "new", from, to); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := strings.Join([]string{
+		"--- old",
+		"+++ new",
+		"@@ -1,5 +1,5 @@",
+		" a",
+		"-b",
+		"+B",
+		" c",
+		" d",
+		" e",
+		"@@ -9,3 +9,4 @@",
+		" i",
+		" j",
+		" k",
+		"+l",
+		"",
+	}, "\n")
+	if output.String() != expected {
+		t.Errorf("Expected diff [%s] but got [%s]", expected, output.String())
+	}
+}
+
+func TestWriteUnifiedDiffNewFile(t *testing.T) {
+	var output bytes.Buffer
+	if err := writeUnifiedDiff(&output, "/dev/null", "new", "", "a\nb\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "--- /dev/null\n+++ new\n@@ -0,0 +1,2 @@\n+a\n+b\n"
+	if output.String() != expected {
+		t.Errorf("Expected diff [%s] but got [%s]", expected, output.String())
+	}
+}
+
+func TestWriteUnifiedDiffIdentical(t *testing.T) {
+	var output bytes.Buffer
+	if err := writeUnifiedDiff(&output, "old", "new", "a\nb\n", "a\nb\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	if output.Len() != 0 {
+		t.Errorf("Expected no diff for identical contents but got [%s]", output.String())
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+)
+
+// utf8BOM is the byte order mark some editors start UTF-8 files with
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// templateText returns the template source of the file generated at path without its byte order mark, which
+// would end up after the header, failing if it isn't valid UTF-8 rather than corrupting it
+func templateText(path string, template []byte) ([]byte, error) {
+	text := bytes.TrimPrefix(template, utf8BOM)
+	for offset := 0; offset < len(text); {
+		r, size := utf8.DecodeRune(text[offset:])
+		if r == utf8.RuneError && size <= 1 {
+			return nil, invalidUTF8Error(path, 1+bytes.Count(text[:offset], []byte("\n")))
+		}
+		offset += size
+	}
+	return text, nil
+}
+
+// invalidUTF8Error returns the error of the template of path that isn't valid UTF-8 at line
+func invalidUTF8Error(path string, line int) error {
+	return fmt.Errorf("Template of [%s] isn't valid UTF-8 (line %d), convert it to UTF-8", path, line)
+}
+
+// withBOM returns src starting with the byte order mark of template, if it has one. Only text files keep it, Go
+// sources not needing one.
+func (g *generation) withBOM(src []byte, template []byte) []byte {
+	if g.lang != langText || !bytes.HasPrefix(template, utf8BOM) {
+		return src
+	}
+	return append(append([]byte{}, utf8BOM...), src...)
+}
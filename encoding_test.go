@@ -0,0 +1,53 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTemplateEncoding(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "encoding")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	os.Setenv("TOKEN", "s3cr3t")
+	defer os.Unsetenv("TOKEN")
+
+	templates := map[string]string{
+		"secrets.go":  "\xEF\xBB\xBFpackage secrets\n\nconst token = \"ENV_TOKEN\"\n",
+		"config.yaml": "\xEF\xBB\xBFtoken: ENV_TOKEN\n",
+		"latin1.yaml": "name: caf\xE9\ntoken: ENV_TOKEN\n",
+	}
+	for name, template := range templates {
+		if err := ioutil.WriteFile(filepath.Join(tempDir, name+templateExt), []byte(template), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := run(options{keys: "TOKEN", paths: []string{filepath.Join(tempDir, "secrets.go")}}); err != nil {
+		t.Fatal(err)
+	}
+	if output, _ := ioutil.ReadFile(filepath.Join(tempDir, "secrets.go")); strings.Contains(string(output), "\xEF\xBB\xBF") {
+		t.Errorf("Expected the BOM of the Go template to be left out but got [%q]", string(output))
+	}
+
+	if err := run(options{keys: "TOKEN", paths: []string{filepath.Join(tempDir, "config.yaml")}, lang: langText}); err != nil {
+		t.Fatal(err)
+	}
+	if output, _ := ioutil.ReadFile(filepath.Join(tempDir, "config.yaml")); !strings.HasPrefix(string(output), "\xEF\xBB\xBF# GENERATED") || strings.Count(string(output), "\xEF\xBB\xBF") != 1 {
+		t.Errorf("Expected the BOM of the text template to start the generated file but got [%q]", string(output))
+	}
+
+	err = run(options{keys: "TOKEN", paths: []string{filepath.Join(tempDir, "latin1.yaml")}, lang: langText})
+	if err == nil || !strings.Contains(err.Error(), "UTF-8 (line 1)") {
+		t.Errorf("Expected a template that isn't UTF-8 to fail but got [%v]", err)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "latin1.yaml")); !os.IsNotExist(err) {
+		t.Errorf("Expected no file generated from a template that isn't UTF-8")
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import "os"
+
+// lookup returns the function looking up the environment variables of opts, os.LookupEnv unless injected
+func (opts options) lookup() func(string) (string, bool) {
+	if opts.lookupEnv == nil {
+		return os.LookupEnv
+	}
+	return opts.lookupEnv
+}
+
+// getenv returns the environment variable key of opts, empty if unset, like os.Getenv
+func (opts options) getenv(key string) string {
+	value, _ := opts.lookup()(key)
+	return value
+}
+
+// environment returns the KEY=VALUE environment variables of opts, os.Environ() unless injected
+func (opts options) environment() []string {
+	if opts.environ == nil {
+		return os.Environ()
+	}
+	return opts.environ()
+}
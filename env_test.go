@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+func TestInjectedEnvironment(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// The process environment has a value the injected one overrides
+	os.Setenv("SAFEKEEPER_TEST_CLIENT_ID", "process")
+	defer os.Unsetenv("SAFEKEEPER_TEST_CLIENT_ID")
+
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "config.go"+templateExt), []byte("package config\n\nconst id = \"ENV_SAFEKEEPER_TEST_CLIENT_ID\"\n\nvar flags = \"ENV_FEATURES\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	generated := filepath.Join(tempDir, "config.go")
+
+	env := map[string]string{"SAFEKEEPER_TEST_CLIENT_ID": "injected", "FEATURE_SEARCH": "on"}
+	opts := options{
+		paths:      []string{generated},
+		prefixMaps: map[string]string{"FEATURES": "FEATURE_"},
+		lookupEnv:  safekeeper.LookupMap(env),
+		environ:    func() []string { return []string{"FEATURE_SEARCH=on"} },
+	}
+	if err := run(opts); err != nil {
+		t.Fatal(err)
+	}
+	output, _ := ioutil.ReadFile(generated)
+	for _, expected := range []string{"const id = \"injected\"", "var flags = map[string]string{\"FEATURE_SEARCH\": \"on\"}"} {
+		if !strings.Contains(string(output), expected) {
+			t.Errorf("Expected [%s] from the injected environment but got [%s]", expected, output)
+		}
+	}
+}
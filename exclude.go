@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ignoreFileName is the name of the files listing the paths skipped by walks, relative to the directory of the file
+const ignoreFileName = ".safekeeperignore"
+
+// gitignoreFileName is the name of the git ignore files, honored by walks with --gitignore
+const gitignoreFileName = ".gitignore"
+
+// skippedDirs are the directories walks skip unless --include-skipped is set, on top of hidden ones
+var skippedDirs = map[string]bool{"vendor": true, "node_modules": true, "testdata": true}
+
+// isSkippedDir reports whether walks skip the directory named name by default
+func isSkippedDir(name string) bool {
+	return skippedDirs[name] || isHidden(name)
+}
+
+// isHidden reports whether the file named name is hidden, e.g. .git
+func isHidden(name string) bool {
+	return strings.HasPrefix(name, ".") && name != "." && name != ".."
+}
+
+// skipsDir reports whether the walks of t skip the directory named name, hidden directories being walked with
+// --include-hidden and all of them with --include-skipped
+func (t templateFinder) skipsDir(name string) bool {
+	if t.includeSkipped || (t.includeHidden && isHidden(name)) {
+		return false
+	}
+	return isSkippedDir(name)
+}
+
+// exclusion is a pattern of the paths skipped by walks, e.g. testdata, vendor/ or internal/**/fixtures
+type exclusion struct {
+	// dir is the directory the pattern is relative to
+	dir      string
+	pattern  string
+	segments []string
+	// anchored patterns (with a / other than a trailing one) match paths relative to dir, the others match names
+	anchored bool
+	// dirOnly patterns (with a trailing /) only match directories
+	dirOnly bool
+}
+
+// parseExclusion returns the exclusion of pattern relative to dir. It returns false for blank and comment lines
+// as well as for the negations of .gitignore files, which aren't supported.
+func parseExclusion(dir string, pattern string) (exclusion, bool) {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" || strings.HasPrefix(pattern, "#") || strings.HasPrefix(pattern, "!") {
+		return exclusion{}, false
+	}
+
+	e := exclusion{dir: dir, pattern: pattern}
+	pattern = filepath.ToSlash(pattern)
+	if strings.HasSuffix(pattern, "/") {
+		e.dirOnly = true
+		pattern = strings.TrimRight(pattern, "/")
+	}
+	e.anchored = strings.Contains(pattern, "/")
+	e.segments = strings.Split(strings.TrimPrefix(pattern, "/"), "/")
+
+	return e, true
+}
+
+// matches reports whether e matches path, a directory if isDir
+func (e exclusion) matches(path string, isDir bool) (bool, error) {
+	if e.dirOnly && !isDir {
+		return false, nil
+	}
+
+	rel, err := filepath.Rel(e.dir, path)
+	if err != nil {
+		return false, nil
+	}
+	rel = filepath.ToSlash(rel)
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, "../") {
+		return false, nil
+	}
+
+	segments := strings.Split(rel, "/")
+	var matched bool
+	if e.anchored {
+		matched, err = matchSegments(e.segments, segments)
+	} else {
+		matched, err = matchName(e.segments[0], segments[len(segments)-1])
+	}
+	if err != nil {
+		return false, fmt.Errorf("Invalid exclusion pattern [%s]: %s", e.pattern, err)
+	}
+	return matched, nil
+}
+
+// readIgnoreFile returns the exclusions of the ignore file named name in dir, none if there is no such file
+func readIgnoreFile(dir string, name string) ([]exclusion, error) {
+	file, err := os.Open(filepath.Join(dir, name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var exclusions []exclusion
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if e, ok := parseExclusion(dir, scanner.Text()); ok {
+			exclusions = append(exclusions, e)
+		}
+	}
+
+	return exclusions, scanner.Err()
+}
+
+// walk walks root like filepath.Walk, skipping the paths matching the --exclude patterns of t (relative to
+// root), the patterns of the .safekeeperignore files found on the way (and .gitignore files with --gitignore)
+// as well as the vendor, node_modules, testdata and hidden directories under root unless t includes them.
+// Symbolic links are skipped unless t follows them, see walkTree.
+func (t templateFinder) walk(root string, fn filepath.WalkFunc) error {
+	var exclusions []exclusion
+	for _, pattern := range t.excludes {
+		if e, ok := parseExclusion(root, pattern); ok {
+			exclusions = append(exclusions, e)
+		}
+	}
+
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		realRoot = root
+	}
+	return t.walkTree(root, root, 0, []string{realRoot}, exclusions, fn)
+}
+
+// walkTree walks dir, the directory at root or the one a symbolic link at root points to, calling fn with the
+// paths under root, depth being the one of root in the walk. walked are the real paths of the directories of the
+// links followed to get there, starting with the one of the root of the walk, so that links back to them are
+// skipped rather than walked forever.
+func (t templateFinder) walkTree(root string, dir string, depth int, walked []string, exclusions []exclusion, fn filepath.WalkFunc) error {
+	ignoreFiles := []string{ignoreFileName}
+	if t.gitignore {
+		ignoreFiles = append(ignoreFiles, gitignoreFileName)
+	}
+
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if ctxErr := contextErr(t.ctx); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			return err
+		}
+		if dir != root {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			path = filepath.Join(root, rel)
+		}
+		if t.maxDepth > 0 && depth+depthUnder(root, path) > t.maxDepth {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		// Links are excluded or skipped like what they point to
+		link := info.Mode()&os.ModeSymlink != 0
+		if link {
+			target, err := os.Stat(path)
+			if err != nil {
+				t.warnings.warnf(path, "Skipping symbolic link [%s]: %s", path, err)
+				return nil
+			}
+			info = renamedFileInfo{FileInfo: target, name: info.Name()}
+		}
+
+		if info.IsDir() && path != root && t.skipsDir(info.Name()) {
+			return filepath.SkipDir
+		}
+
+		for _, e := range exclusions {
+			excluded, err := e.matches(path, info.IsDir())
+			if err != nil {
+				return err
+			}
+			if excluded && info.IsDir() && !link {
+				return filepath.SkipDir
+			}
+			if excluded {
+				return nil
+			}
+		}
+
+		if t.maxFileSize > 0 && !info.IsDir() && info.Size() > t.maxFileSize && t.ext.isTemplate(info.Name()) {
+			t.warnings.warnf(path, "Skipping [%s] of %d bytes, larger than --max-file-size", path, info.Size())
+			return nil
+		}
+		if link {
+			return t.walkLink(path, info, depth+depthUnder(root, path), walked, exclusions, fn)
+		}
+
+		if info.IsDir() {
+			for _, name := range ignoreFiles {
+				ignored, err := readIgnoreFile(path, name)
+				if err != nil {
+					return err
+				}
+				exclusions = append(exclusions, ignored...)
+			}
+		}
+
+		return fn(path, info, nil)
+	})
+}
+
+// depthUnder returns the number of path segments of path under root, 0 for root itself
+func depthUnder(root string, path string) int {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." {
+		return 0
+	}
+	return strings.Count(rel, string(filepath.Separator)) + 1
+}
+
+// walkWarnings warns of the files skipped by walks once per file, the same directories being walked several times
+// per run (to find the keys of their templates, then to generate them)
+type walkWarnings struct {
+	log  *logger
+	mu   sync.Mutex
+	seen map[string]bool
+}
+
+func newWalkWarnings(log *logger) *walkWarnings {
+	return &walkWarnings{log: log, seen: make(map[string]bool)}
+}
+
+// warnf logs the warning about the file at path unless it already did, nothing if w is nil
+func (w *walkWarnings) warnf(path string, format string, args ...interface{}) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.seen[path] {
+		return
+	}
+	w.seen[path] = true
+	w.log.warnf(format, args...)
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExclusionMatches(t *testing.T) {
+	cases := []struct {
+		pattern string
+		path    string
+		isDir   bool
+		matched bool
+	}{
+		{"testdata", "/repo/a/testdata", true, true},
+		{"*.go.safekeeper", "/repo/a/b.go.safekeeper", false, true},
+		{"fixtures/", "/repo/fixtures", false, false},
+		{"fixtures/", "/repo/a/fixtures", true, true},
+		{"internal/**/fixtures", "/repo/internal/a/b/fixtures", true, true},
+		{"internal/fixtures", "/repo/a/internal/fixtures", true, false},
+		{"/vendor", "/repo/vendor", true, true},
+		{"testdata", "/other/testdata", true, false},
+	}
+
+	for _, c := range cases {
+		e, ok := parseExclusion("/repo", c.pattern)
+		if !ok {
+			t.Fatalf("Expected [%s] to be a pattern", c.pattern)
+		}
+		matched, err := e.matches(filepath.FromSlash(c.path), c.isDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if matched != c.matched {
+			t.Errorf("Expected match of [%s] against [%s] to be %t", c.path, c.pattern, c.matched)
+		}
+	}
+
+	for _, line := range []string{"", "  ", "# comment"} {
+		if _, ok := parseExclusion("/repo", line); ok {
+			t.Errorf("Expected [%s] not to be a pattern", line)
+		}
+	}
+}
+
+func TestExcludedTemplates(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	dirs := []string{tempDir, filepath.Join(tempDir, "generated"), filepath.Join(tempDir, "third_party", "lib"), filepath.Join(tempDir, "internal", "fixtures")}
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := writeTestTemplate(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ignore := "# Generated fixtures\ngenerated/\n"
+	if err := ioutil.WriteFile(filepath.Join(tempDir, ignoreFileName), []byte(ignore), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "internal", ignoreFileName), []byte("fixtures\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	finder := templateFinder{excludes: []string{"third_party"}}
+	templates, err := finder.findTemplates(tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{filepath.Join(tempDir, "secrets.go.safekeeper")}
+	if !reflect.DeepEqual(templates, expected) {
+		t.Errorf("Expected templates %v but got %v", expected, templates)
+	}
+}
+
+func TestSkippedDirs(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	var dirs []string
+	for _, name := range []string{"vendor", "node_modules", "testdata", ".git", "ignored"} {
+		dirs = append(dirs, filepath.Join(tempDir, name))
+	}
+	for _, dir := range append(dirs, tempDir) {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := writeTestTemplate(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(tempDir, gitignoreFileName), []byte("ignored/\n!ignored/secrets.go.safekeeper\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	templates, err := templateFinder{gitignore: true}.findTemplates(tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{filepath.Join(tempDir, "secrets.go.safekeeper")}
+	if !reflect.DeepEqual(templates, expected) {
+		t.Errorf("Expected templates %v but got %v", expected, templates)
+	}
+
+	templates, err = templateFinder{includeSkipped: true}.findTemplates(tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(templates) != len(dirs)+1 {
+		t.Errorf("Expected the templates of all the directories with --include-skipped but got %v", templates)
+	}
+
+	// A skipped directory is still walked when given explicitly
+	templates, err = templateFinder{}.findTemplates(filepath.Join(tempDir, "testdata"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(templates) != 1 {
+		t.Errorf("Expected the template of the walked directory but got %v", templates)
+	}
+}
+
+func TestWalkLimits(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	for _, dir := range []string{tempDir, filepath.Join(tempDir, "db"), filepath.Join(tempDir, "db", "replica"), filepath.Join(tempDir, ".config"), filepath.Join(tempDir, "vendor")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := writeTestTemplate(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	large := filepath.Join(tempDir, "db", "large.go"+templateExt)
+	if err := ioutil.WriteFile(large, bytes.Repeat([]byte("// padding\n"), 1024), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	templates, err := templateFinder{maxDepth: 2}.findTemplates(tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{large, filepath.Join(tempDir, "db", "secrets.go.safekeeper"), filepath.Join(tempDir, "secrets.go.safekeeper")}
+	if !reflect.DeepEqual(templates, expected) {
+		t.Errorf("Expected templates %v with --max-depth=2 but got %v", expected, templates)
+	}
+
+	templates, err = templateFinder{maxDepth: 1, includeHidden: true}.findTemplates(tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{filepath.Join(tempDir, ".config", "secrets.go.safekeeper"), filepath.Join(tempDir, "secrets.go.safekeeper")}
+	if len(templates) != 1 || templates[0] != expected[1] {
+		t.Errorf("Expected the templates of the directory only with --max-depth=1 but got %v", templates)
+	}
+	templates, err = templateFinder{maxDepth: 2, includeHidden: true}.findTemplates(tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(templates) != 4 || templates[0] != expected[0] {
+		t.Errorf("Expected the templates of hidden directories but not vendor with --include-hidden but got %v", templates)
+	}
+
+	var stderr bytes.Buffer
+	log, err := newLogger(&stderr, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	templates, err = templateFinder{maxFileSize: 4096, warnings: newWalkWarnings(log)}.findTemplates(tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, template := range templates {
+		if template == large {
+			t.Errorf("Expected [%s] to be skipped with --max-file-size but got %v", large, templates)
+		}
+	}
+	if !strings.Contains(stderr.String(), "larger than --max-file-size") {
+		t.Errorf("Expected a warning about the skipped template but got [%s]", stderr.String())
+	}
+}
+
+func TestParseFileSize(t *testing.T) {
+	for size, expected := range map[string]int64{"": 0, "65536": 65536, "512KB": 512 << 10, "1mb": 1 << 20, "2 GB": 2 << 30, "10B": 10} {
+		if n, err := parseFileSize(size); err != nil || n != expected {
+			t.Errorf("Expected size [%s] to be %d but got [%d, %v]", size, expected, n, err)
+		}
+	}
+	for _, size := range []string{"large", "-1MB", "1TB"} {
+		if _, err := parseFileSize(size); err == nil {
+			t.Errorf("Expected size [%s] to be invalid", size)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+// Exit codes of the safekeeper command. When several inputs fail for different reasons, the exit code is the one of
+// the first failure.
+const (
+	// exitFailure is the exit code of any failure not covered by a more specific code (invalid flags, etc.)
+	exitFailure = 1
+	// exitMissingValue is the exit code when a key has no value in the value source
+	exitMissingValue = 2
+	// exitTemplateNotFound is the exit code when an input has no .safekeeper template
+	exitTemplateNotFound = 3
+	// exitWriteFailure is the exit code when a generated file can't be written
+	exitWriteFailure = 4
+	// exitSourceFailure is the exit code when the value source itself fails (CLI not installed, backend down, etc.)
+	exitSourceFailure = 5
+	// exitInvalidOutput is the exit code when a generated file isn't valid Go and --format or --validate is set
+	exitInvalidOutput = 6
+	// exitStale is the exit code when --check finds generated files that aren't up to date
+	exitStale = 7
+	// exitFindings is the exit code when scan finds likely hardcoded secrets and --fail-on-findings is set
+	exitFindings = 8
+	// exitEdited is the exit code when a generated file was edited since it was generated and --force isn't set
+	exitEdited = 9
+	// exitInvalidValue is the exit code when a value doesn't satisfy the rule of its key in the config file
+	exitInvalidValue = 10
+	// exitTimeout is the exit code when --timeout elapses before the command completes
+	exitTimeout = 11
+	// exitRotationDue is the exit code when a secret is expired or due for rotation and --enforce-rotation is set
+	exitRotationDue = 12
+	// exitUnbalancedBlocks is the exit code when validate finds conditional blocks of a template that aren't balanced
+	// or have a condition without a key
+	exitUnbalancedBlocks = 13
+	// exitInvalidSettings is the exit code when validate finds an invalid file setting directive in a template
+	exitInvalidSettings = 14
+	// exitSplitPlaceholder is the exit code when validate finds a placeholder split over two lines in a template
+	exitSplitPlaceholder = 15
+	// exitInvalidTemplate is the exit code when validate finds a template that doesn't parse (Go templates of the
+	// gotemplate engine, Go sources with --mode=ast)
+	exitInvalidTemplate = 16
+)
+
+// codedError is an error carrying the exit code the command should terminate with
+type codedError struct {
+	code int
+	err  error
+}
+
+func (e codedError) Error() string {
+	return e.err.Error()
+}
+
+func (e codedError) Unwrap() error {
+	return e.err
+}
+
+// withExitCode tags err with the given exit code. A nil err stays nil.
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return codedError{code: code, err: err}
+}
+
+// exitCode returns the exit code the command should terminate with for err
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var errs generationErrors
+	if errors.As(err, &errs) && len(errs) > 0 {
+		return exitCode(errs[0])
+	}
+
+	var coded codedError
+	if errors.As(err, &coded) {
+		return coded.code
+	}
+
+	return exitFailure
+}
+
+// redactedError is an error whose message had values redacted
+type redactedError struct {
+	err     error
+	message string
+}
+
+func (e redactedError) Error() string {
+	return e.message
+}
+
+func (e redactedError) Unwrap() error {
+	return e.err
+}
+
+// redactError returns err with secrets redacted from its message, err itself if it has none. A nil err stays nil.
+func redactError(err error, secrets map[string]safekeeper.Secret) error {
+	if err == nil || len(secrets) == 0 {
+		return err
+	}
+	if message := safekeeper.NewRedactor(secrets).Replace(err.Error()); message != err.Error() {
+		return redactedError{err: err, message: message}
+	}
+	return err
+}
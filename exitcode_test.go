@@ -0,0 +1,47 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExitCodes(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	if _, err := writeTestTemplate(tempDir); err != nil {
+		t.Fatal(err)
+	}
+
+	generationDriverFile, err := writeGenerationDriverFile(tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("CLIENT_ID", "safeid")
+	defer os.Unsetenv("CLIENT_ID")
+	os.Unsetenv("SAFEKEEPER_UNSET_KEY")
+
+	cases := []struct {
+		name string
+		opts options
+		code int
+	}{
+		{"missing value", options{keys: "SAFEKEEPER_UNSET_KEY", paths: []string{generationDriverFile}}, exitMissingValue},
+		{"missing template", options{keys: "CLIENT_ID", paths: []string{filepath.Join(tempDir, "other.go")}}, exitTemplateNotFound},
+		{"write failure", options{keys: "CLIENT_ID", output: filepath.Join(tempDir, "missing", "out.go"), paths: []string{generationDriverFile}}, exitWriteFailure},
+		{"invalid usage", options{keys: "CLIENT_ID", output: "out.go", paths: []string{tempDir}}, exitFailure},
+		{"success", options{keys: "CLIENT_ID", output: filepath.Join(tempDir, "out.go"), paths: []string{generationDriverFile}}, 0},
+	}
+
+	for _, c := range cases {
+		if code := exitCode(run(c.opts)); code != c.code {
+			t.Errorf("Expected exit code %d for %s but got %d", c.code, c.name, code)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+// explain prints to the stdout of opts, for each of keys, the source its value comes from and the other sources
+// having one, which it shadows. Values are never printed.
+func explain(opts options, keys []string) error {
+	opts, cancel := opts.withContext()
+	defer cancel()
+	source, err := newLayeredSource(opts)
+	if err != nil {
+		return err
+	}
+
+	stdout := opts.stdout
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	for _, key := range keys {
+		var found, searched []string
+		for _, layer := range source.layersOf(key) {
+			_, ok, err := safekeeper.ResolveContext(opts.context(), layer.source, source.nameIn(layer, key))
+			if ctxErr := contextErr(opts.context()); ctxErr != nil {
+				return ctxErr
+			}
+			if err != nil {
+				return withExitCode(exitSourceFailure, err)
+			}
+			if ok {
+				found = append(found, layer.name)
+			}
+			searched = append(searched, layer.name)
+		}
+
+		name := key
+		if alias, ok := source.aliases[key]; ok {
+			name = fmt.Sprintf("%s (as %s)", key, alias)
+		}
+		var line string
+		switch {
+		case len(found) == 0:
+			line = fmt.Sprintf("%s: not found in %s", name, strings.Join(searched, ", "))
+		case len(found) == 1:
+			line = fmt.Sprintf("%s: %s", name, found[0])
+		default:
+			line = fmt.Sprintf("%s: %s, shadowing %s", name, found[0], strings.Join(found[1:], ", "))
+		}
+		if _, err := fmt.Fprintln(stdout, line); err != nil {
+			return withExitCode(exitWriteFailure, err)
+		}
+	}
+	return nil
+}
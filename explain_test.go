@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "explain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	envFile := filepath.Join(tempDir, ".env")
+	if err := ioutil.WriteFile(envFile, []byte("SAFEKEEPER_TEST_TOKEN=from-file\nSAFEKEEPER_TEST_ID=from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("SAFEKEEPER_TEST_TOKEN", "from-env")
+	defer os.Unsetenv("SAFEKEEPER_TEST_TOKEN")
+
+	opts := options{envFiles: []string{envFile}, values: map[string]string{"SAFEKEEPER_TEST_URL": "https://example.com"}}
+	keys := []string{"SAFEKEEPER_TEST_TOKEN", "SAFEKEEPER_TEST_ID", "SAFEKEEPER_TEST_URL", "SAFEKEEPER_TEST_MISSING"}
+
+	var output bytes.Buffer
+	opts.stdout = &output
+	if err := explain(opts, keys); err != nil {
+		t.Fatal(err)
+	}
+	expected := "SAFEKEEPER_TEST_TOKEN: env, shadowing envfile:" + envFile + "\n" +
+		"SAFEKEEPER_TEST_ID: envfile:" + envFile + "\n" +
+		"SAFEKEEPER_TEST_URL: values of the config file\n" +
+		"SAFEKEEPER_TEST_MISSING: not found in values of the config file, env, envfile:" + envFile + "\n"
+	if output.String() != expected {
+		t.Errorf("Expected explanation [%s] but got [%s]", expected, output.String())
+	}
+	if strings.Contains(output.String(), "from-") {
+		t.Errorf("Expected values to never be printed but got [%s]", output.String())
+	}
+
+	output.Reset()
+	opts.precedence = "env-files"
+	if err := explain(opts, keys[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "SAFEKEEPER_TEST_TOKEN: envfile:" + envFile + ", shadowing env\n"; output.String() != expected {
+		t.Errorf("Expected env files to win with --precedence=env-files but got [%s]", output.String())
+	}
+
+	opts.precedence = "env-files,nope"
+	if err := explain(opts, keys[:1]); err == nil {
+		t.Errorf("Expected an unknown source group to fail")
+	}
+}
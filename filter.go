@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+)
+
+// filterValues returns the values of keys (and of the keys of opts) loaded from the sources of opts, keys
+// without a value being left out rather than failing, so that a checkout never fails on them
+func filterValues(opts options, keys []string) (map[string]string, error) {
+	opts, cancel := opts.withContext()
+	defer cancel()
+	optional := parseOptionalKeys(opts.optionalKeys)
+	if opts.keys != "" {
+		keys = append(keys, parseKeys(opts.keys, optional)...)
+	}
+	skipped := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		skipped[key] = true
+	}
+
+	source, err := newSecretSource(opts)
+	if err != nil {
+		return nil, err
+	}
+	return loadKeyValues(opts.context(), keys, source, skipped, nil)
+}
+
+// filterSmudge is the git smudge filter: it writes the file read from the stdin of opts to its stdout with the
+// placeholders of the keys having a value replaced, everything else being kept as is
+func filterSmudge(opts options) error {
+	content, err := ioutil.ReadAll(opts.stdinOrDefault())
+	if err != nil {
+		return err
+	}
+	generator, err := opts.generator()
+	if err != nil {
+		return err
+	}
+	keys, err := generator.FindKeys(bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	values, err := filterValues(opts, keys)
+	if err != nil {
+		return err
+	}
+
+	stdout := opts.stdout
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	return generator.Smudge(bytes.NewReader(content), stdout, values)
+}
+
+// filterClean is the git clean filter: it writes the file read from the stdin of opts to its stdout with the
+// values of the keys of opts replaced back by placeholders, so that committed blobs never have values
+func filterClean(opts options) error {
+	if opts.keys == "" {
+		return errors.New("--keys is required to clean values (e.g. in the config file)")
+	}
+	generator, err := opts.generator()
+	if err != nil {
+		return err
+	}
+	values, err := filterValues(opts, nil)
+	if err != nil {
+		return err
+	}
+
+	stdout := opts.stdout
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	return generator.Clean(opts.stdinOrDefault(), stdout, values)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestFilters(t *testing.T) {
+	os.Setenv("TOKEN", "s3cr3t")
+	defer os.Unsetenv("TOKEN")
+
+	template := "token: ENV_TOKEN\nmissing: ENV_MISSING\n"
+	var smudged bytes.Buffer
+	if err := filterSmudge(options{stdin: strings.NewReader(template), stdout: &smudged}); err != nil {
+		t.Fatal(err)
+	}
+	expected := "token: s3cr3t\nmissing: ENV_MISSING\n"
+	if smudged.String() != expected {
+		t.Errorf("Expected smudged file [%s] but got [%s]", expected, smudged.String())
+	}
+
+	var cleaned bytes.Buffer
+	if err := filterClean(options{keys: "TOKEN,MISSING", stdin: &smudged, stdout: &cleaned}); err != nil {
+		t.Fatal(err)
+	}
+	if cleaned.String() != template {
+		t.Errorf("Expected cleaned file [%s] but got [%s]", template, cleaned.String())
+	}
+
+	if err := filterClean(options{stdin: strings.NewReader(expected), stdout: &cleaned}); err == nil {
+		t.Errorf("Expected an error without --keys")
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+// fingerprintValues returns the values of the keys of template, the ones fingerprinted in the file generated from it
+func (g *generation) fingerprintValues(template []byte) (map[string]string, error) {
+	keys, err := g.generator.FindKeys(bytes.NewReader(template))
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(keys))
+	for _, key := range keys {
+		if value, ok := g.keyValues[key]; ok {
+			values[key] = value.Reveal()
+		}
+	}
+	return values, nil
+}
+
+// staleReason returns why the generated file current is out of date when its fingerprints tell that the values of
+// keys changed since it was generated (e.g. rotated secrets) or it was generated by another version of safekeeper,
+// empty otherwise
+func (g *generation) staleReason(current []byte) string {
+	var reasons []string
+	if changed := safekeeper.ChangedKeys(current, g.fingerprintKey, safekeeper.Reveal(g.keyValues)); len(changed) > 0 {
+		reasons = append(reasons, fmt.Sprintf("values of [%s] changed", strings.Join(changed, ", ")))
+	}
+	if recorded := safekeeper.ReadVersion(current); recorded != "" && g.version != "" && recorded != g.version {
+		reasons = append(reasons, "generated by safekeeper "+recorded)
+	}
+	if generated, ttl, ok := safekeeper.ReadTTL(current); ok && g.expired(current) {
+		reasons = append(reasons, "values expired at "+generated.Add(ttl).UTC().Format(time.RFC3339))
+	}
+	if len(reasons) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(reasons, ", ") + ")"
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+func TestFingerprintedCheck(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	generationDriverFile, err := writeGenerationDriverFile(tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writeTestTemplate(tempDir); err != nil {
+		t.Fatal(err)
+	}
+
+	env := map[string]string{"CLIENT_ID": "safeid", "CLIENT_SECRET": "safesecret"}
+	generatedFile := filepath.Join(tempDir, "appsecrets.go")
+	opts := options{keys: "CLIENT_ID,CLIENT_SECRET", output: generatedFile, paths: []string{generationDriverFile}, fingerprint: true, fingerprintKey: "pepper", lookupEnv: safekeeper.LookupMap(env)}
+	if err := run(opts); err != nil {
+		t.Fatal(err)
+	}
+
+	generated, err := ioutil.ReadFile(generatedFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fingerprints := safekeeper.ReadFingerprints(generated); len(fingerprints) != 2 {
+		t.Fatalf("Expected the values of CLIENT_ID and CLIENT_SECRET to be fingerprinted but got %v", fingerprints)
+	}
+
+	opts.check = true
+	if err := run(opts); err != nil {
+		t.Errorf("Expected the fingerprinted file to be up to date but got [%v]", err)
+	}
+
+	env["CLIENT_SECRET"] = "rotated"
+	err = run(opts)
+	if exitCode(err) != exitStale || !strings.Contains(err.Error(), generatedFile+" (values of [CLIENT_SECRET] changed)") {
+		t.Fatalf("Expected the rotated CLIENT_SECRET to be reported but got [%v]", err)
+	}
+	for _, value := range []string{"rotated", "safesecret", "safeid"} {
+		if strings.Contains(err.Error(), value) {
+			t.Errorf("Expected the error not to reveal values but got [%v]", err)
+		}
+	}
+}
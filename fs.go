@@ -0,0 +1,21 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+)
+
+// osFS is the fs.FS of the OS filesystem, opening names as OS paths (absolute or relative to the working
+// directory) rather than as the rooted, slash-separated names of fs.ValidPath, like the paths given to the command
+type osFS struct{}
+
+func (osFS) Open(name string) (fs.File, error) {
+	return os.Open(name)
+}
+
+// atomicFS is the safekeeper.WriteFS of the OS filesystem, writing files with writeFileAtomic
+type atomicFS struct{}
+
+func (atomicFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return writeFileAtomic(name, data, perm)
+}
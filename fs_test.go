@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+func TestGenerateVirtualFiles(t *testing.T) {
+	files := safekeeper.MapFS{
+		"virtual/config.go.safekeeper": &fstest.MapFile{Data: []byte("package config\n\nconst id = \"ENV_CLIENT_ID\"\n"), Mode: 0600},
+	}
+	log, err := newLogger(ioutil.Discard, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := &generation{
+		keyValues: safekeeper.Secrets(map[string]string{"CLIENT_ID": "id"}),
+		finder:    templateFinder{fsys: files},
+		outputs:   files,
+		noHeader:  true,
+		log:       log,
+		jobs:      newSemaphore(1),
+		ctx:       context.Background(),
+	}
+
+	if err := g.generateFile("virtual/config.go", ""); err != nil {
+		t.Fatal(err)
+	}
+	generated, ok := files["virtual/config.go"]
+	if !ok {
+		t.Fatal("Expected virtual/config.go to be written to the virtual filesystem")
+	}
+	if !strings.Contains(string(generated.Data), "const id = \"id\"") {
+		t.Errorf("Expected the value to be substituted but got [%s]", generated.Data)
+	}
+	if generated.Mode != 0600 {
+		t.Errorf("Expected the mode of the template but got %o", generated.Mode)
+	}
+}
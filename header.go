@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/build/constraint"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+// generateDirective is the go:generate line running safekeeper in file
+type generateDirective struct {
+	file string
+	line string
+}
+
+// currentDirective returns the go:generate line safekeeper is run by, from the $GOFILE and $GOLINE set by go
+// generate, or the zero directive if it isn't run by go generate
+func currentDirective(getenv func(string) string) generateDirective {
+	file, lineNumber := getenv("GOFILE"), getenv("GOLINE")
+	n, err := strconv.Atoi(lineNumber)
+	if file == "" || err != nil {
+		return generateDirective{}
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return generateDirective{}
+	}
+	defer f.Close()
+
+	// Lines are read whole since the ones before the directive can be of any length
+	r := bufio.NewReader(f)
+	for i := 1; ; i++ {
+		line, err := r.ReadString('\n')
+		if i == n {
+			line = strings.TrimRight(line, "\r\n")
+			if !safekeeper.IsGenerateLine(line) {
+				return generateDirective{}
+			}
+			return generateDirective{file: file, line: line}
+		}
+		if err != nil {
+			return generateDirective{}
+		}
+	}
+}
+
+// buildConstraint returns the //go:build line of the expression of --build-tags, e.g. prod or prod && !debug.
+// Comma-delimited tags must all be set, as in the old +build syntax.
+func buildConstraint(tags string) (string, error) {
+	if strings.Contains(tags, ",") {
+		tags = strings.Join(strings.Split(tags, ","), " && ")
+	}
+	line := "//go:build " + tags
+	expr, err := constraint.Parse(line)
+	if err != nil {
+		return "", fmt.Errorf("Invalid --build-tags [%s]: %s", tags, err)
+	}
+	return "//go:build " + expr.String(), nil
+}
+
+// headerVars are the variables of header templates
+type headerVars struct {
+	// Keys are the keys of --keys, comma-delimited
+	Keys     string
+	Output   string
+	Template string
+	// GenerateLine is the go:generate line of the default header, empty if it has none
+	GenerateLine string
+}
+
+// writeHeader writes the build constraint and the header of the file generated from path to out, with the version
+// of safekeeper following its code generation warning, and the generation time and TTL of values with --value-ttl
+func (g *generation) writeHeader(w io.Writer, path string, out string) error {
+	var header bytes.Buffer
+	if err := g.writeHeaderLines(&header, path, out); err != nil {
+		return err
+	}
+	src := safekeeper.AddVersion(header.Bytes(), g.version)
+	if g.valueTTL > 0 {
+		src = safekeeper.AddTTL(src, g.generatedAt(valueOr(out, path)), g.valueTTL)
+	}
+	_, err := w.Write(src)
+	return err
+}
+
+// writeHeaderLines writes the build constraint and the header of the file generated from path to out: no header
+// with --no-header, the one of the header template if set and else the default one
+func (g *generation) writeHeaderLines(w io.Writer, path string, out string) error {
+	// The build constraint has to come before the package clause, it's written whatever the header
+	if g.buildConstraint != "" {
+		if _, err := io.WriteString(w, g.buildConstraint+"\n\n"); err != nil {
+			return err
+		}
+	}
+	if g.noHeader {
+		return nil
+	}
+	if g.headerTemplate == nil {
+		return g.writeDefaultHeader(w, path, out)
+	}
+
+	var defaultHeader bytes.Buffer
+	if err := g.writeDefaultHeader(&defaultHeader, path, out); err != nil {
+		return err
+	}
+	vars := headerVars{Keys: strings.Join(g.keys, ","), Output: out, Template: path}
+	if out == "" {
+		vars.Output = path
+	}
+	if path != stdio {
+		vars.Template = g.finder.ext.templateOf(path)
+	}
+	for _, line := range splitLines(defaultHeader.String()) {
+		if safekeeper.IsGenerateLine(line) {
+			vars.GenerateLine = line
+		}
+	}
+
+	var header bytes.Buffer
+	if err := g.headerTemplate.Execute(&header, vars); err != nil {
+		return err
+	}
+	if header.Len() > 0 && !bytes.HasSuffix(header.Bytes(), []byte("\n")) {
+		header.WriteString("\n")
+	}
+	_, err := w.Write(header.Bytes())
+	return err
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/kingpin"
+)
+
+func TestHeaderOptions(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "header")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	os.Setenv("TOKEN", "s3cr3t")
+	defer os.Unsetenv("TOKEN")
+
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "secrets.go"+templateExt), []byte("package secrets\n\nconst token = \"ENV_TOKEN\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	generated := filepath.Join(tempDir, "secrets.go")
+	body := "package secrets\n\nconst token = \"s3cr3t\"\n"
+
+	if err := run(options{keys: "TOKEN", paths: []string{generated}, noHeader: true}); err != nil {
+		t.Fatal(err)
+	}
+	if output, _ := ioutil.ReadFile(generated); string(output) != body {
+		t.Errorf("Expected no header with --no-header but got [%s]", string(output))
+	}
+
+	headerTemplate := "// Code generated for {{.Keys}} from {{.Template}}, DO NOT EDIT.\n{{.GenerateLine}}"
+	err = run(options{keys: "TOKEN", paths: []string{generated}, headerTemplate: headerTemplate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "// Code generated for TOKEN from " + generated + templateExt + ", DO NOT EDIT.\n//go:generate safekeeper --keys=TOKEN $GOFILE\n" + body
+	if output, _ := ioutil.ReadFile(generated); string(output) != expected {
+		t.Errorf("Expected the header of the template [%s] but got [%s]", expected, string(output))
+	}
+
+	if err := run(options{paths: []string{generated}, headerTemplate: "{{.Missing"}); err == nil {
+		t.Errorf("Expected an invalid header template to fail")
+	}
+}
+
+func TestHeaderKeepsGenerateLine(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "header")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	os.Setenv("TOKEN", "s3cr3t")
+	defer os.Unsetenv("TOKEN")
+
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "secrets.go"+templateExt), []byte("package secrets\n\nconst token = \"ENV_TOKEN\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	generated := filepath.Join(tempDir, "secrets.go")
+	directive := "//go:generate safekeeper --keys=TOKEN --format $GOFILE"
+	if err := ioutil.WriteFile(generated, []byte("// hand-written\n"+directive+"\npackage secrets\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// As run by go generate from the directive of the file
+	os.Setenv("GOFILE", generated)
+	os.Setenv("GOLINE", "2")
+	defer os.Unsetenv("GOFILE")
+	defer os.Unsetenv("GOLINE")
+	if err := run(options{keys: "TOKEN", format: true, paths: []string{generated}}); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := ioutil.ReadFile(generated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(output), "\n"+directive+"\n") {
+		t.Errorf("Expected the go:generate line [%s] to be kept but got [%s]", directive, string(output))
+	}
+}
+
+func TestBuildTags(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "header")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	os.Setenv("TOKEN", "s3cr3t")
+	defer os.Unsetenv("TOKEN")
+
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "secrets.go"+templateExt), []byte("package secrets\n\nconst token = \"ENV_TOKEN\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	generated := filepath.Join(tempDir, "secrets.go")
+
+	if err := run(options{keys: "TOKEN", paths: []string{generated}, buildTags: "prod,!debug"}); err != nil {
+		t.Fatal(err)
+	}
+	output, _ := ioutil.ReadFile(generated)
+	if !strings.HasPrefix(string(output), "//go:build prod && !debug\n\n") {
+		t.Errorf("Expected the generated file to start with the build constraint but got [%s]", string(output))
+	}
+	if !strings.Contains(string(output), "\"--build-tags=prod && !debug\"") {
+		t.Errorf("Expected the go:generate line to keep the build tags but got [%s]", string(output))
+	}
+
+	if err := run(options{keys: "TOKEN", paths: []string{generated}, buildTags: "prod &&"}); err == nil {
+		t.Errorf("Expected an invalid build constraint to fail")
+	}
+}
+
+func TestHeaderRegeneratesFile(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "header")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	os.Setenv("SERVICE_SECRET", "s3cr3t")
+	defer os.Unsetenv("SERVICE_SECRET")
+	envFile := filepath.Join(tempDir, "app.env")
+	if err := ioutil.WriteFile(envFile, []byte("CLIENT_ID=id\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "secrets.go"+templateExt), []byte("package secrets\n\nconst (\n\tid = \"ENV_CLIENT_ID\"\n\tsecret    = \"ENV_CLIENT_SECRET\"\n)\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	generated := filepath.Join(tempDir, "secrets.go")
+	opts := options{
+		paths:    []string{generated},
+		sources:  []string{"env"},
+		envFiles: []string{envFile},
+		keyMap:   map[string]string{"CLIENT_SECRET": "SERVICE_SECRET"},
+		format:   true,
+		strict:   true,
+	}
+	if err := run(opts); err != nil {
+		t.Fatal(err)
+	}
+	expected, err := ioutil.ReadFile(generated)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines, err := generateLinesOf(generated)
+	if err != nil || len(lines) != 1 {
+		t.Fatalf("Expected the go:generate line of the header but got %v (%v)", lines, err)
+	}
+	args := make([]string, len(lines[0].args))
+	for i, arg := range lines[0].args {
+		args[i] = os.Expand(arg, func(name string) string {
+			return map[string]string{"GOFILE": generated, "DOLLAR": "$"}[name]
+		})
+	}
+	if err := os.Remove(generated); err != nil {
+		t.Fatal(err)
+	}
+
+	// As run by go generate, with the repeatable flags cleared from other parses
+	*paths, *sources, *envFiles, *secretsFiles, *keyMap = nil, nil, nil, nil, map[string]string{}
+	if _, err := kingpin.CommandLine.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(parsedOptions("", args)); err != nil {
+		t.Fatalf("Expected the go:generate line %v to regenerate the file but got %v", args, err)
+	}
+	regenerated, err := ioutil.ReadFile(generated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(regenerated) != string(expected) {
+		t.Errorf("Expected the go:generate line %v to regenerate [%s] but got [%s]", args, expected, regenerated)
+	}
+}
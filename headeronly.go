@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+// headerOnly rewrites the header of the generated files of opts to the current format, their body being kept as
+// is, without resolving any value: the go:generate line is the one of the flags of opts, its keys defaulting to
+// the ones of the existing line. With the check of opts, the files whose header isn't current are reported
+// instead, and with its dry run their diff is printed.
+func headerOnly(opts options) error {
+	opts, cancel := opts.withContext()
+	defer cancel()
+	log, err := opts.logger()
+	if err != nil {
+		return err
+	}
+	finder := opts.finder()
+	inputPaths, err := finder.expandPaths(opts.paths)
+	if err != nil {
+		return err
+	}
+	outputs, err := finder.outputsOf(inputPaths)
+	if err != nil {
+		return err
+	}
+	generator, err := opts.generator()
+	if err != nil {
+		return err
+	}
+	constraintLine := ""
+	if opts.buildTags != "" {
+		if constraintLine, err = buildConstraint(opts.buildTags); err != nil {
+			return err
+		}
+	}
+
+	g := &generation{
+		keysFile:        opts.keysFile,
+		sources:         opts.sources,
+		secretsFiles:    opts.secretsFiles,
+		envFiles:        opts.envFiles,
+		keyMap:          opts.keyMap,
+		generator:       generator,
+		finder:          finder,
+		lang:            opts.lang,
+		format:          opts.format,
+		validate:        opts.validate,
+		strict:          opts.strict,
+		reproducible:    opts.reproducible,
+		fingerprint:     opts.fingerprint,
+		lineDirectives:  opts.lineDirectives,
+		lineEndings:     opts.lineEndings,
+		buildConstraint: constraintLine,
+		version:         opts.version,
+		noHeader:        opts.noHeader,
+		stdout:          opts.stdout,
+		log:             log,
+	}
+	if g.stdout == nil {
+		g.stdout = os.Stdout
+	}
+	g.color = opts.useColor(g.stdout)
+
+	var stale []string
+	for _, output := range outputs {
+		current, err := ioutil.ReadFile(output)
+		if err != nil {
+			return withExitCode(exitTemplateNotFound, err)
+		}
+		if !opts.force {
+			if err := checkNotEdited(output); err != nil {
+				return err
+			}
+		}
+
+		g.keys = parseKeys(opts.keys, map[string]bool{})
+		if opts.keys == "" {
+			g.keys = generateLineKeys(current)
+		}
+		var header bytes.Buffer
+		if err := g.writeHeader(&header, output, ""); err != nil {
+			return err
+		}
+		// Build constraints aren't part of the header, they're kept as is
+		updated, ok := safekeeper.ReplaceHeader(current, bytes.TrimPrefix(header.Bytes(), []byte(constraintLine+"\n\n")))
+		if !ok {
+			return inputError{path: output, err: fmt.Errorf("[%s] has no safekeeper header to rewrite", output)}
+		}
+		if bytes.Equal(updated, current) {
+			continue
+		}
+
+		switch {
+		case opts.check:
+			log.fileInfof(output, "The header of [%s] is out of date", output)
+			stale = append(stale, output)
+		case opts.dryRun:
+			if err := g.printDiff(output, updated, nil); err != nil {
+				return err
+			}
+		default:
+			info, err := os.Stat(output)
+			if err != nil {
+				return err
+			}
+			if err := writeFileAtomic(output, updated, info.Mode().Perm()); err != nil {
+				return withExitCode(exitWriteFailure, err)
+			}
+			log.fileInfof(output, "Rewrote the header of [%s]", output)
+		}
+	}
+	if len(stale) > 0 {
+		sort.Strings(stale)
+		return withExitCode(exitStale, fmt.Errorf("%d generated files have an out of date header, run safekeeper header-only:\n%s", len(stale), strings.Join(stale, "\n")))
+	}
+	return nil
+}
+
+// generateLineKeys returns the keys of the --keys of the go:generate line running safekeeper of content, if any
+func generateLineKeys(content []byte) []string {
+	for _, line := range splitLines(string(content)) {
+		line = strings.TrimRight(line, "\r")
+		if !strings.HasPrefix(line, "//go:generate ") || !safekeeper.IsGenerateLine(line) {
+			continue
+		}
+		words, err := splitGenerateLine(strings.TrimPrefix(line, "//go:generate "))
+		if err != nil {
+			return nil
+		}
+		args, _ := safekeeperArgs(words)
+		for i, arg := range args {
+			if strings.HasPrefix(arg, "--keys=") {
+				return strings.Split(strings.TrimPrefix(arg, "--keys="), ",")
+			}
+			if arg == "--keys" && i+1 < len(args) {
+				return strings.Split(args[i+1], ",")
+			}
+		}
+		return nil
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHeaderOnly(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "header")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	template := filepath.Join(tempDir, "secrets.go"+templateExt)
+	if err := ioutil.WriteFile(template, []byte("package secrets\n\nconst token = \"ENV_TOKEN\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// An old-style header, without content hash nor version, and a value that's never resolved again
+	generated := filepath.Join(tempDir, "secrets.go")
+	body := "package secrets\n\nconst token = \"s3cr3t\"\n"
+	if err := ioutil.WriteFile(generated, []byte("// Code generated by safekeeper. DO NOT EDIT.\n//go:generate safekeeper --keys=TOKEN\n"+body), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The header is out of date for --check
+	opts := options{paths: []string{tempDir}, version: "v1.4.0", check: true}
+	if err := headerOnly(opts); err == nil || exitCode(err) != exitStale || !strings.Contains(err.Error(), generated) {
+		t.Fatalf("Expected the header to be out of date but got [%v]", err)
+	}
+
+	opts.check = false
+	if err := headerOnly(opts); err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(generated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expected bytes.Buffer
+	g := &generation{keys: []string{"TOKEN"}, version: "v1.4.0"}
+	if err := g.writeHeader(&expected, generated, ""); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), strings.SplitN(expected.String(), "\n", 2)[1]) || !strings.HasSuffix(string(content), "\n"+body) || strings.Contains(string(content), "Code generated") {
+		t.Errorf("Expected the header to be rewritten, keeping the body and the keys, but got:\n%s", content)
+	}
+	if info, err := os.Stat(generated); err != nil || (info.Mode().Perm() != 0600 && os.PathSeparator == '/') {
+		t.Errorf("Expected the mode of the file to be kept but got %v (%v)", info.Mode(), err)
+	}
+
+	// Once current, the header is up to date and rewriting it again changes nothing
+	opts.check = true
+	if err := headerOnly(opts); err != nil {
+		t.Errorf("Expected the header to be up to date but got [%v]", err)
+	}
+
+	// Edits are kept unless forced
+	if err := ioutil.WriteFile(generated, append(content, "// edited\n"...), 0600); err != nil {
+		t.Fatal(err)
+	}
+	opts.check = false
+	if err := headerOnly(opts); exitCode(err) != exitEdited {
+		t.Errorf("Expected an edited file to be refused but got [%v]", err)
+	}
+}
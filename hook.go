@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+// preCommitHook is the git pre-commit hook installed by installHook
+const preCommitHook = "#!/bin/sh\n# Installed by safekeeper hook install\nexec safekeeper hook pre-commit\n"
+
+// preCommit fails if a file staged in git has the value of a key or, for generated files, likely hardcoded
+// secrets, reporting them to the stdout of opts. Keys are the ones of opts or else the ones of the templates of
+// its paths, values of keys that have none being skipped.
+func preCommit(opts options, run commandRunner) error {
+	out, err := run("git", "diff", "--cached", "--name-only", "--diff-filter=ACMR", "-z")
+	if err != nil {
+		return err
+	}
+	var staged []string
+	for _, path := range strings.Split(string(out), "\x00") {
+		if path != "" {
+			staged = append(staged, path)
+		}
+	}
+	if len(staged) == 0 {
+		return nil
+	}
+
+	values, err := hookValues(opts)
+	if err != nil {
+		return err
+	}
+
+	var findings []finding
+	for _, path := range staged {
+		content, err := run("git", "show", ":"+path)
+		if err != nil {
+			return err
+		}
+		if bytes.IndexByte(content, 0) >= 0 {
+			continue
+		}
+		findings = append(findings, valueFindings(path, content, values)...)
+
+		if _, err := os.Stat(opts.finder().ext.templateOf(path)); err == nil {
+			secrets, err := scanSecrets(path, bytes.NewReader(content))
+			if err != nil {
+				return err
+			}
+			findings = append(findings, secrets...)
+		}
+	}
+
+	stdout := opts.stdout
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	for _, f := range findings {
+		if _, err := fmt.Fprintln(stdout, f); err != nil {
+			return withExitCode(exitWriteFailure, err)
+		}
+	}
+	if len(findings) > 0 {
+		return withExitCode(exitFindings, fmt.Errorf("%d likely secrets are staged, commit aborted", len(findings)))
+	}
+	return nil
+}
+
+// hookValues returns the values of the keys of opts or else of the keys of the templates of its paths, the
+// working directory by default
+func hookValues(opts options) (map[string]string, error) {
+	opts, cancel := opts.withContext()
+	defer cancel()
+	optional := parseOptionalKeys(opts.optionalKeys)
+	var keys []string
+	if opts.keys != "" {
+		keys = parseKeys(opts.keys, optional)
+	} else {
+		paths := opts.paths
+		if len(paths) == 0 {
+			paths = []string{"."}
+		}
+		finder := opts.finder()
+		inputPaths, err := finder.expandPaths(paths)
+		if err != nil {
+			return nil, err
+		}
+		generator, err := opts.generator()
+		if err != nil {
+			return nil, err
+		}
+		if keys, _, _, err = scanTemplates(&generator, finder, inputPaths, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	skipped := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		skipped[key] = true
+	}
+	source, err := newSecretSource(opts)
+	if err != nil {
+		return nil, err
+	}
+	return loadKeyValues(opts.context(), keys, source, skipped, nil)
+}
+
+// valueFindings returns the lines of content (the file at path) that have one of values, only reporting their
+// key. Values are looked for as they're redacted, shorter ones than safekeeper.MinRedactedLength being skipped.
+func valueFindings(path string, content []byte, values map[string]string) []finding {
+	keys := make([]string, 0, len(values))
+	redactors := make(map[string]*safekeeper.Redactor, len(values))
+	for key, value := range values {
+		keys = append(keys, key)
+		redactors[key] = safekeeper.NewRedactor(map[string]safekeeper.Secret{key: safekeeper.Secret(value)})
+	}
+	sort.Strings(keys)
+
+	var findings []finding
+	for i, line := range splitLines(string(content)) {
+		for _, key := range keys {
+			if redactors[key].Replace(line) != line {
+				findings = append(findings, finding{path: path, line: i + 1, rule: fmt.Sprintf("value of [%s]", key)})
+			}
+		}
+	}
+	return findings
+}
+
+// installHook installs the pre-commit hook running safekeeper hook pre-commit in the git repository of the
+// working directory, failing if there already is another one
+func installHook(run commandRunner) error {
+	out, err := run("git", "rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(strings.TrimSpace(string(out)), "pre-commit")
+
+	existing, err := ioutil.ReadFile(path)
+	if err == nil && string(existing) != preCommitHook {
+		return fmt.Errorf("Hook [%s] already exists, add safekeeper hook pre-commit to it instead", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return withExitCode(exitWriteFailure, err)
+	}
+	return withExitCode(exitWriteFailure, ioutil.WriteFile(path, []byte(preCommitHook), 0755))
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// gitStub returns the commandRunner answering git commands with outputs, keyed by their arguments
+func gitStub(outputs map[string]string) commandRunner {
+	return func(name string, args ...string) ([]byte, error) {
+		return []byte(outputs[strings.Join(args, " ")]), nil
+	}
+}
+
+func TestPreCommit(t *testing.T) {
+	os.Setenv("CLIENT_SECRET", "s3cr3t-value")
+	defer os.Unsetenv("CLIENT_SECRET")
+	os.Setenv("CLIENT_PIN", "4711")
+	defer os.Unsetenv("CLIENT_PIN")
+
+	run := gitStub(map[string]string{
+		"diff --cached --name-only --diff-filter=ACMR -z": "main.go\x00README.md\x00",
+		"show :main.go":   "package main\n\nconst secret = \"s3cr3t-value\"\nconst pin = 4711\n",
+		"show :README.md": "No secrets here, 47110 isn't the pin\n",
+	})
+
+	var stdout bytes.Buffer
+	err := preCommit(options{keys: "CLIENT_SECRET,CLIENT_PIN", stdout: &stdout}, run)
+	if exitCode(err) != exitFindings {
+		t.Errorf("Expected exit code %d but got %d (%v)", exitFindings, exitCode(err), err)
+	}
+	expected := "main.go:3: value of [CLIENT_SECRET]\nmain.go:4: value of [CLIENT_PIN]\n"
+	if stdout.String() != expected {
+		t.Errorf("Expected findings [%s] but got [%s]", expected, stdout.String())
+	}
+}
+
+func TestPreCommitNothingStaged(t *testing.T) {
+	if err := preCommit(options{keys: "CLIENT_SECRET"}, gitStub(nil)); err != nil {
+		t.Errorf("Expected no error without staged files but got [%s]", err)
+	}
+}
+
+func TestInstallHook(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "hook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	hooksDir := filepath.Join(tempDir, ".git", "hooks")
+	run := gitStub(map[string]string{"rev-parse --git-path hooks": hooksDir + "\n"})
+	if err := installHook(run); err != nil {
+		t.Fatal(err)
+	}
+	hook, err := ioutil.ReadFile(filepath.Join(hooksDir, "pre-commit"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(hook) != preCommitHook {
+		t.Errorf("Expected hook [%s] but got [%s]", preCommitHook, string(hook))
+	}
+
+	// Installing again is fine but another hook isn't overwritten
+	if err := installHook(run); err != nil {
+		t.Errorf("Expected reinstalling the hook to succeed but got [%s]", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(hooksDir, "pre-commit"), []byte("#!/bin/sh\nmake lint\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := installHook(run); err == nil {
+		t.Errorf("Expected an error for an existing hook")
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+// checkTemplateNotGenerated fails if template, the template of path with extension ext, is a file generated by safekeeper (e.g. a
+// generated file copied over its template, or a file generated into its own template) rather than a template:
+// generating it would bake the values it already has into the file again, with no placeholders left to replace.
+func checkTemplateNotGenerated(ext templateExtension, path string, template []byte) error {
+	if !safekeeper.IsGenerated(template) {
+		return nil
+	}
+	if path == stdio {
+		return fmt.Errorf("The template read from stdin is a file generated by safekeeper rather than a template")
+	}
+	return fmt.Errorf("Template [%s] is a file generated by safekeeper rather than a template, restore it with safekeeper clean or from version control", ext.templateOf(path))
+}
+
+// checkOutputNotTemplate fails if out, the file generated from the template of path, is that template, which
+// would be overwritten by the generated file and make the next run substitute generated values
+func (g *generation) checkOutputNotTemplate(path string, out string) error {
+	if path == stdio || out == stdio {
+		return nil
+	}
+	if template := g.finder.ext.templateOf(path); equalPaths(out, template) {
+		return fmt.Errorf("Output [%s] is the template of [%s], which generating would overwrite", out, path)
+	}
+	return nil
+}
+
+// generatedFromOtherInput reports whether path is a file generated by safekeeper without a template of its own,
+// generated from the one of another input (e.g. appsecrets.go generated from secrets.go with
+// --output=appsecrets.go): the go:generate line kept in its header has nothing to generate, the line of the
+// other input regenerating it
+func generatedFromOtherInput(ext templateExtension, path string) bool {
+	if _, err := os.Stat(ext.templateOf(path)); !os.IsNotExist(err) {
+		return false
+	}
+	content, err := ioutil.ReadFile(path)
+	return err == nil && safekeeper.IsGenerated(content)
+}
+
+// withoutGeneratedGoFile returns inputPaths without gofile, the $GOFILE of go generate, if it's generated from
+// another input: running go generate again then does nothing rather than failing on its missing template
+func withoutGeneratedGoFile(inputPaths []string, gofile string, ext templateExtension, log *logger) []string {
+	if gofile == "" || !generatedFromOtherInput(ext, gofile) {
+		return inputPaths
+	}
+	kept := inputPaths[:0:0]
+	for _, path := range inputPaths {
+		if path != stdio && equalPaths(outputPath(path, ext), gofile) {
+			log.infof("Skipping [%s], generated from another input that regenerates it", path)
+			continue
+		}
+		kept = append(kept, path)
+	}
+	return kept
+}
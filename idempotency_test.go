@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+func TestGenerateTwice(t *testing.T) {
+	env := safekeeper.LookupMap(map[string]string{"TOKEN": "s3cr3t", "HOST": "db.internal"})
+	for name, test := range map[string]struct {
+		template string
+		opts     options
+	}{
+		"default":     {"package secrets\n\nconst token = \"ENV_TOKEN\"\n", options{}},
+		"keys":        {"package secrets\n\nconst token = \"ENV_TOKEN\"\n", options{keys: "TOKEN", fingerprint: true, reproducible: true}},
+		"crlf":        {"package secrets\r\n\r\nconst token = \"ENV_TOKEN\"\r\n", options{}},
+		"directive":   {"package secrets\n\nconst token = \"ENV_TOKEN\"\n", options{keys: "TOKEN"}},
+		"text":        {"host: ENV_HOST\ntoken: ENV_TOKEN\n", options{lang: langText}},
+		"obfuscation": {"package secrets\n\nconst token = \"ENV_TOKEN\"\n", options{obfuscate: "xor", reproducible: true}},
+	} {
+		tempDir, err := ioutil.TempDir("", "idempotency")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(tempDir)
+
+		output := filepath.Join(tempDir, "secrets.go")
+		if test.opts.lang == langText {
+			output = filepath.Join(tempDir, "config.yaml")
+		}
+		if err := ioutil.WriteFile(output+templateExt, []byte(test.template), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		opts := test.opts
+		opts.paths, opts.lookupEnv = []string{output}, env
+		var generated [2][]byte
+		for i := range generated {
+			if err := run(opts); err != nil {
+				t.Fatalf("%s: %s", name, err)
+			}
+			if generated[i], err = ioutil.ReadFile(output); err != nil {
+				t.Fatal(err)
+			}
+			if name == "directive" {
+				// Then run by go generate from the go:generate line of the file it generated
+				lines := strings.Split(string(generated[i]), "\n")
+				for n, line := range lines {
+					if strings.HasPrefix(line, "//go:generate") {
+						opts.lookupEnv = safekeeper.LookupMap(map[string]string{"TOKEN": "s3cr3t", "GOFILE": output, "GOLINE": strconv.Itoa(n + 1)})
+					}
+				}
+			}
+		}
+		if !bytes.Equal(generated[0], generated[1]) {
+			t.Errorf("%s: Expected generating twice to generate the same file but got:\n%s\nthen:\n%s", name, generated[0], generated[1])
+		}
+		opts.check = true
+		if err := run(opts); err != nil {
+			t.Errorf("%s: Expected the file generated twice to be up to date but got [%v]", name, err)
+		}
+	}
+}
+
+func TestGeneratedTemplate(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "idempotency")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	output := filepath.Join(tempDir, "secrets.go")
+	if err := ioutil.WriteFile(output+templateExt, []byte("package secrets\n\nconst token = \"ENV_TOKEN\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := options{paths: []string{output}, lookupEnv: safekeeper.LookupMap(map[string]string{"TOKEN": "s3cr3t"})}
+	if err := run(opts); err != nil {
+		t.Fatal(err)
+	}
+
+	// The generated file copied over its template
+	generated, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(output+templateExt, generated, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(opts); err == nil || !strings.Contains(err.Error(), "is a file generated by safekeeper") {
+		t.Errorf("Expected a generated template to be refused but got [%v]", err)
+	}
+
+	opts.stdin = bytes.NewReader(generated)
+	opts.paths, opts.stdout = []string{stdio}, ioutil.Discard
+	if err := run(opts); err == nil || !strings.Contains(err.Error(), "read from stdin is a file generated") {
+		t.Errorf("Expected a generated file read from stdin to be refused but got [%v]", err)
+	}
+}
+
+func TestOutputIsTemplate(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "idempotency")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	template := filepath.Join(tempDir, "secrets.go"+templateExt)
+	content := []byte("package secrets\n\nconst token = \"ENV_TOKEN\"\n")
+	if err := ioutil.WriteFile(template, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := options{paths: []string{filepath.Join(tempDir, "secrets.go")}, output: template, lookupEnv: safekeeper.LookupMap(map[string]string{"TOKEN": "s3cr3t"})}
+	if err := run(opts); err == nil || !strings.Contains(err.Error(), "is the template of") {
+		t.Errorf("Expected generating into the template to be refused but got [%v]", err)
+	}
+	if kept, err := ioutil.ReadFile(template); err != nil || !bytes.Equal(kept, content) {
+		t.Errorf("Expected the template to be left untouched but got [%s] (%v)", kept, err)
+	}
+}
+
+func TestGenerateTwiceWithOutput(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "idempotency")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// The layout of the README: secrets.go drives the generation of appsecrets.go from secrets.go.safekeeper
+	if _, err := writeTestTemplate(tempDir); err != nil {
+		t.Fatal(err)
+	}
+	driver, err := writeGenerationDriverFile(tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(tempDir, "appsecrets.go")
+	env := map[string]string{"CLIENT_ID": "safeid", "CLIENT_SECRET": "safesecret", "GOFILE": driver, "GOLINE": "2"}
+	opts := options{keys: "CLIENT_ID,CLIENT_SECRET", output: output, paths: []string{driver}, lookupEnv: safekeeper.LookupMap(env)}
+	if err := run(opts); err != nil {
+		t.Fatal(err)
+	}
+	generated, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// go generate then also runs the go:generate line kept in the header of appsecrets.go, which does nothing,
+	// not even resolving values
+	env["GOFILE"], env["GOLINE"] = output, "4"
+	delete(env, "CLIENT_SECRET")
+	opts.paths = []string{output}
+	if err := run(opts); err != nil {
+		t.Fatalf("Expected the line of the generated file to do nothing but got [%v]", err)
+	}
+	regenerated, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(generated, regenerated) {
+		t.Errorf("Expected the generated file to be left as is but got:\n%s\nthen:\n%s", generated, regenerated)
+	}
+
+	// Run directly rather than by go generate, the missing template is still reported
+	delete(env, "GOFILE")
+	env["CLIENT_SECRET"] = "safesecret"
+	if err := run(opts); exitCode(err) != exitTemplateNotFound {
+		t.Errorf("Expected the missing template to be reported but got [%v]", err)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+// keyPattern matches the valid names of keys
+var keyPattern = regexp.MustCompile("^[A-Za-z0-9_]+$")
+
+// initTemplate extracts the template of the Go file at path from its literals having the values of mapped (key
+// to value) and of the keys of opts (loaded from its sources), replaced by placeholders. With interactive, the
+// key of each other string literal is asked for on prompt, answers being read from the stdin of opts. The file
+// itself then gets the header of a generated file, go:generate line included.
+func initTemplate(opts options, path string, mapped map[string]string, interactive bool, prompt io.Writer) error {
+	opts, cancel := opts.withContext()
+	defer cancel()
+	finder := opts.finder()
+	templatePath := finder.ext.templateOf(path)
+	if _, err := os.Stat(templatePath); err == nil {
+		return fmt.Errorf("Template [%s] already exists", templatePath)
+	}
+
+	source, err := ioutil.ReadFile(path)
+	if err != nil {
+		return withExitCode(exitTemplateNotFound, err)
+	}
+
+	values := make(map[string]string, len(mapped))
+	for key, value := range mapped {
+		if !keyPattern.MatchString(key) {
+			return fmt.Errorf("Invalid key [%s]", key)
+		}
+		values[key] = value
+	}
+	if opts.keys != "" {
+		optional := parseOptionalKeys(opts.optionalKeys)
+		keys := parseKeys(opts.keys, optional)
+		secretSource, err := newSecretSource(opts)
+		if err != nil {
+			return err
+		}
+		loaded, err := loadKeyValues(opts.context(), keys, secretSource, nil, optional)
+		if err != nil {
+			return err
+		}
+		for key, value := range loaded {
+			if _, ok := values[key]; !ok {
+				values[key] = value
+			}
+		}
+	}
+	if interactive {
+		if err := askKeys(source, values, opts.stdinOrDefault(), prompt); err != nil {
+			return err
+		}
+	}
+	if len(values) == 0 {
+		return errors.New("No key to extract, --keys, --value or --interactive is required")
+	}
+
+	generator, err := opts.generator()
+	if err != nil {
+		return err
+	}
+	// Values are only replaced in the string literals of the source, never in identifiers or comments
+	reverter := safekeeper.Generator{Prefix: generator.Prefix, Suffix: generator.Suffix, Mode: safekeeper.ASTMode, Engine: generator.Engine}
+	var template bytes.Buffer
+	if err := reverter.Revert(bytes.NewReader(source), &template, values); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(templatePath, template.Bytes(), 0644); err != nil {
+		return withExitCode(exitWriteFailure, err)
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	g := &generation{keys: keys, generator: generator, finder: finder}
+	var file bytes.Buffer
+	if err := g.writeHeader(&file, path, ""); err != nil {
+		return err
+	}
+	if err := stripGenerateLines(source, &file); err != nil {
+		return err
+	}
+	return withExitCode(exitWriteFailure, ioutil.WriteFile(path, file.Bytes(), 0644))
+}
+
+// askKeys asks on prompt for the key of each string literal of source that has no key in values yet, reading
+// answers from answers, and adds the ones given to values. An empty answer skips the literal.
+func askKeys(source []byte, values map[string]string, answers io.Reader, prompt io.Writer) error {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", source, 0)
+	if err != nil {
+		return fmt.Errorf("Invalid Go file: %s", err)
+	}
+
+	known := make(map[string]bool, len(values))
+	for _, value := range values {
+		known[value] = true
+	}
+
+	var literals []*ast.BasicLit
+	ast.Inspect(file, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.ImportSpec:
+			return false
+		case *ast.BasicLit:
+			if n.Kind == token.STRING {
+				literals = append(literals, n)
+			}
+		}
+		return true
+	})
+
+	scanner := bufio.NewScanner(answers)
+	for _, literal := range literals {
+		value, err := strconv.Unquote(literal.Value)
+		if err != nil || value == "" || known[value] {
+			continue
+		}
+		known[value] = true
+
+		fmt.Fprintf(prompt, "Key of %s (line %d), empty to skip: ", literal.Value, fset.Position(literal.Pos()).Line)
+		if !scanner.Scan() {
+			fmt.Fprintln(prompt)
+			return scanner.Err()
+		}
+		key := strings.TrimSpace(scanner.Text())
+		if key == "" {
+			continue
+		}
+		if !keyPattern.MatchString(key) {
+			return fmt.Errorf("Invalid key [%s]", key)
+		}
+		values[key] = value
+	}
+	return nil
+}
+
+// stripGenerateLines writes source to w without its go:generate safekeeper lines, whatever the length of the
+// others
+func stripGenerateLines(source []byte, w io.Writer) error {
+	ew := bufio.NewWriter(w)
+	for _, line := range bytes.SplitAfter(source, []byte("\n")) {
+		if !safekeeper.IsGenerateLine(string(line)) {
+			ew.Write(line)
+		}
+	}
+	return ew.Flush()
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInitTemplate(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "init")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	path := filepath.Join(tempDir, "secrets.go")
+	source := "package secrets\n\nconst (\n\tclientId = \"my-client\"\n\tclientSecret = \"s3cr3t\"\n\tname = \"app\"\n\tregion = \"us-east-1\"\n)\n"
+	if err := ioutil.WriteFile(path, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("CLIENT_SECRET", "s3cr3t")
+	defer os.Unsetenv("CLIENT_SECRET")
+
+	var prompt bytes.Buffer
+	opts := options{keys: "CLIENT_SECRET", stdin: strings.NewReader("\nREGION\n")}
+	if err := initTemplate(opts, path, map[string]string{"CLIENT_ID": "my-client"}, true, &prompt); err != nil {
+		t.Fatal(err)
+	}
+
+	template, err := ioutil.ReadFile(path + templateExt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedTemplate := "package secrets\n\nconst (\n\tclientId = \"ENV_CLIENT_ID\"\n\tclientSecret = \"ENV_CLIENT_SECRET\"\n\tname = \"app\"\n\tregion = \"ENV_REGION\"\n)\n"
+	if string(template) != expectedTemplate {
+		t.Errorf("Expected template [%s] but got [%s]", expectedTemplate, string(template))
+	}
+
+	generated, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedGenerateLine := "//go:generate safekeeper --keys=CLIENT_ID,CLIENT_SECRET,REGION $GOFILE\n"
+	if !strings.Contains(string(generated), expectedGenerateLine) || !strings.HasSuffix(string(generated), source) {
+		t.Errorf("Expected the file to keep its source with the go:generate line [%s] but was [%s]", expectedGenerateLine, string(generated))
+	}
+
+	if err := initTemplate(opts, path, nil, false, &prompt); err == nil {
+		t.Errorf("Expected an error for an existing template")
+	}
+}
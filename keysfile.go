@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// readKeysFile returns the keys listed in the file at path, one per line as in --keys (e.g. TIMEOUT:duration or
+// DEBUG?), blank lines and # comments being ignored
+func readKeysFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var keys []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		if key := strings.TrimSpace(line); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys, scanner.Err()
+}
+
+// withKeysFile returns opts with the keys of its --keys-file added to --keys, but for the ones already there
+func withKeysFile(opts options) (options, error) {
+	if opts.keysFile == "" {
+		return opts, nil
+	}
+	fileKeys, err := readKeysFile(opts.keysFile)
+	if err != nil {
+		return opts, err
+	}
+
+	var keys []string
+	seen := make(map[string]bool)
+	if opts.keys != "" {
+		keys = strings.Split(opts.keys, ",")
+		for _, key := range keys {
+			seen[keyName(key)] = true
+		}
+	}
+	for _, key := range fileKeys {
+		if !seen[keyName(key)] {
+			seen[keyName(key)] = true
+			keys = append(keys, key)
+		}
+	}
+	opts.keys = strings.Join(keys, ",")
+	return opts, nil
+}
+
+// withoutKeysFile returns keys, the keys of the go:generate line of the header, without the ones of keysFile
+// since the line refers to it
+func withoutKeysFile(keys []string, keysFile string) ([]string, error) {
+	if keysFile == "" {
+		return keys, nil
+	}
+	fileKeys, err := readKeysFile(keysFile)
+	if err != nil {
+		return nil, err
+	}
+	listed := make(map[string]bool, len(fileKeys))
+	for _, key := range fileKeys {
+		listed[keyName(key)] = true
+	}
+	var kept []string
+	for _, key := range keys {
+		if !listed[keyName(key)] {
+			kept = append(kept, key)
+		}
+	}
+	return kept, nil
+}
+
+// keyName returns the name of key as written in --keys, without its type nor optional marker
+func keyName(key string) string {
+	if i := strings.IndexByte(key, ':'); i >= 0 {
+		key = key[:i]
+	}
+	return strings.TrimSuffix(key, "?")
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+func TestReadKeysFile(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	keysFile := filepath.Join(tempDir, "keys.txt")
+	content := "# OAuth client\nCLIENT_ID\nCLIENT_SECRET  # rotated monthly\n\n  ANALYTICS_TOKEN?\r\nTIMEOUT:duration\n"
+	if err := ioutil.WriteFile(keysFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := readKeysFile(keysFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"CLIENT_ID", "CLIENT_SECRET", "ANALYTICS_TOKEN?", "TIMEOUT:duration"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Expected keys %v but got %v", expected, keys)
+	}
+
+	opts, err := withKeysFile(options{keys: "CLIENT_ID,DEBUG?", keysFile: keysFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.keys != "CLIENT_ID,DEBUG?,CLIENT_SECRET,ANALYTICS_TOKEN?,TIMEOUT:duration" {
+		t.Errorf("Expected the keys of the file to be added to --keys but got [%s]", opts.keys)
+	}
+	if again, err := withKeysFile(opts); err != nil || again.keys != opts.keys {
+		t.Errorf("Expected the keys of the file to be added once but got [%s, %v]", again.keys, err)
+	}
+}
+
+func TestKeysFileHeader(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	generationDriverFile, err := writeGenerationDriverFile(tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writeTestTemplate(tempDir); err != nil {
+		t.Fatal(err)
+	}
+	keysFile := filepath.Join(tempDir, "keys.txt")
+	if err := ioutil.WriteFile(keysFile, []byte("CLIENT_ID\nCLIENT_SECRET\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	env := safekeeper.LookupMap(map[string]string{"CLIENT_ID": "safeid", "CLIENT_SECRET": "safesecret"})
+	generatedFile := filepath.Join(tempDir, "appsecrets.go")
+	if err := run(options{keysFile: keysFile, output: generatedFile, paths: []string{generationDriverFile}, lookupEnv: env}); err != nil {
+		t.Fatal(err)
+	}
+
+	generated, err := ioutil.ReadFile(generatedFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "//go:generate safekeeper --keys-file=" + filepath.ToSlash(keysFile) + " --output="
+	if !strings.Contains(string(generated), expected) || strings.Contains(string(generated), "--keys=") {
+		t.Errorf("Expected the go:generate line to refer to the keys file but got:\n%s", generated)
+	}
+	if !strings.Contains(string(generated), "\"safesecret\"") {
+		t.Errorf("Expected the keys of the file to be substituted but got:\n%s", generated)
+	}
+
+	if err := run(options{keysFile: filepath.Join(tempDir, "missing.txt"), output: generatedFile, paths: []string{generationDriverFile}, lookupEnv: env}); err == nil {
+		t.Error("Expected a missing keys file to fail")
+	}
+}
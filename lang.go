@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// langGo generates Go sources, with a header go:generate can regenerate them with
+	langGo = "go"
+	// langText generates any other text file (YAML, JSON, Dockerfiles, etc.), the header being a comment in the
+	// syntax of the file if it has one
+	langText = "text"
+)
+
+// commentSyntax is how a line comment starts and, for block-only syntaxes, ends
+type commentSyntax struct {
+	start string
+	end   string
+}
+
+// commentSyntaxes are the comment syntaxes of text files by extension
+var commentSyntaxes = map[string]commentSyntax{
+	".yaml":       {start: "#"},
+	".yml":        {start: "#"},
+	".toml":       {start: "#"},
+	".properties": {start: "#"},
+	".env":        {start: "#"},
+	".conf":       {start: "#"},
+	".ini":        {start: ";"},
+	".sh":         {start: "#"},
+	".py":         {start: "#"},
+	".tf":         {start: "#"},
+	".sql":        {start: "--"},
+	".js":         {start: "//"},
+	".ts":         {start: "//"},
+	".java":       {start: "//"},
+	".xml":        {start: "<!--", end: "-->"},
+	".html":       {start: "<!--", end: "-->"},
+	".css":        {start: "/*", end: "*/"},
+}
+
+// commentSyntaxOf returns the comment syntax of the text file at path, false if it has none (or it's unknown,
+// e.g. JSON)
+func commentSyntaxOf(path string) (commentSyntax, bool) {
+	name := filepath.Base(path)
+	if strings.HasPrefix(name, "Dockerfile") || strings.HasSuffix(name, ".dockerfile") || name == "Makefile" || name == ".env" {
+		return commentSyntax{start: "#"}, true
+	}
+
+	syntax, ok := commentSyntaxes[strings.ToLower(filepath.Ext(name))]
+	return syntax, ok
+}
+
+// checkLang fails if opts has settings that don't apply to its language
+func checkLang(opts options) error {
+	switch opts.lang {
+	case "", langGo:
+		return nil
+	case langText:
+		for flag, set := range map[string]bool{"--format": opts.format, "--validate": opts.validate, "--mode=ast": opts.mode == "ast", "--escape": opts.escape, "--obfuscate": opts.obfuscate != "", "--split": opts.split > 1, "--env-fallback": opts.envFallback, "--build-tags": opts.buildTags != "", "--line-directives": opts.lineDirectives} {
+			if set {
+				return fmt.Errorf("%s only applies to Go, not to --lang=%s", flag, langText)
+			}
+		}
+		return nil
+	case langJS, langPython, langJava:
+		return fmt.Errorf("--lang=%s only applies to the package command", opts.lang)
+	default:
+		return fmt.Errorf("Unknown language [%s], expected %s or %s", opts.lang, langGo, langText)
+	}
+}
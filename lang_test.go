@@ -0,0 +1,77 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTextLang(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "manifests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	templates := map[string]string{
+		"deployment.yaml": "env:\n  - name: API_URL\n    value: ENV_API_URL\n",
+		"config.json":     "{\"apiUrl\": \"ENV_API_URL\"}\n",
+	}
+	for name, template := range templates {
+		if err := ioutil.WriteFile(filepath.Join(tempDir, name+templateExt), []byte(template), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	os.Setenv("API_URL", "https://api.example.com")
+	defer os.Unsetenv("API_URL")
+
+	if err := run(options{paths: []string{tempDir}, lang: langText}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"deployment.yaml": "# GENERATED by safekeeper (https://github.com/alexandre-normand/safekeeper, DO NOT EDIT\n# safekeeper:sha256=0e20917bdfd5b268ef87e921fa8ddde70e366d69cc2c3450f18e75859170310b\nenv:\n  - name: API_URL\n    value: https://api.example.com\n",
+		"config.json":     "{\"apiUrl\": \"https://api.example.com\"}\n",
+	}
+	for name, content := range expected {
+		output, err := ioutil.ReadFile(filepath.Join(tempDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(output) != content {
+			t.Errorf("Expected [%s] to be:\n\n%s\nbut was:\n\n%s", name, content, string(output))
+		}
+	}
+}
+
+func TestTextLangRejectsGoSettings(t *testing.T) {
+	err := checkLang(options{lang: langText, format: true})
+	if err == nil || !strings.Contains(err.Error(), "--format only applies to Go") {
+		t.Errorf("Expected --format to be rejected with --lang=text but got [%v]", err)
+	}
+
+	if err := checkLang(options{lang: "rust"}); err == nil {
+		t.Error("Expected an unknown language to be rejected")
+	}
+}
+
+func TestCommentSyntaxOf(t *testing.T) {
+	cases := map[string]string{
+		"k8s/deployment.yaml": "#",
+		"Dockerfile":          "#",
+		"db/schema.sql":       "--",
+		"web/index.html":      "<!--",
+	}
+	for path, start := range cases {
+		if syntax, ok := commentSyntaxOf(path); !ok || syntax.start != start {
+			t.Errorf("Expected comments of [%s] to start with [%s] but got [%s]", path, start, syntax.start)
+		}
+	}
+
+	if _, ok := commentSyntaxOf("config.json"); ok {
+		t.Error("JSON files shouldn't have a comment syntax")
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+// ldflags returns the -X flags setting the variable of each key (named after it, e.g. APIKey for API_KEY) of
+// the package at importPath to the value of the key, as given to go build -ldflags
+func ldflags(importPath string, keys []string, values map[string]string) (string, error) {
+	flags := make([]string, len(keys))
+	for i, key := range keys {
+		arg, err := ldflagsArg(fmt.Sprintf("%s.%s=%s", importPath, goName(key), values[key]))
+		if err != nil {
+			return "", fmt.Errorf("Value of [%s] can't be quoted for -ldflags, it has both single and double quotes", key)
+		}
+		flags[i] = "-X " + arg
+	}
+	return strings.Join(flags, " "), nil
+}
+
+// ldflagsArg returns arg quoted, if needed, for go build to split -ldflags as expected
+func ldflagsArg(arg string) (string, error) {
+	switch {
+	case !strings.ContainsAny(arg, " \t\n'\""):
+		return arg, nil
+	case !strings.Contains(arg, "'"):
+		return "'" + arg + "'", nil
+	case !strings.Contains(arg, "\""):
+		return "\"" + arg + "\"", nil
+	default:
+		return "", errors.New("Can't quote both single and double quotes")
+	}
+}
+
+// ldflagsVarsSource returns the source of the Go file of the package named name declaring the variable of each key,
+// set by the -X flags of ldflags
+func ldflagsVarsSource(name string, keys []string) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := safekeeper.WriteGeneratedComment(&buffer); err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(&buffer, "\npackage %s\n\n// Values set at link time with the -X flags of safekeeper ldflags\nvar (\n", name)
+	for _, key := range keys {
+		fmt.Fprintf(&buffer, "\t// %s is the value of %s\n\t%s string\n", goName(key), key, goName(key))
+	}
+	buffer.WriteString(")\n")
+
+	return formatSource(buffer.Bytes())
+}
+
+// generateLdflags writes the -X flags setting a variable of the package at importPath to the value of each key
+// of opts to the output of opts (stdout if not set) and, if varsFile isn't empty, the file declaring them
+func generateLdflags(opts options, importPath string, varsFile string) error {
+	if opts.keys == "" {
+		return errors.New("--keys is required to generate -ldflags")
+	}
+	optional := parseOptionalKeys(opts.optionalKeys)
+	keys := parseKeys(opts.keys, optional)
+
+	opts, cancel := opts.withContext()
+	defer cancel()
+	source, err := newSecretSource(opts)
+	if err != nil {
+		return err
+	}
+	values, err := loadKeyValues(opts.context(), keys, source, nil, optional)
+	if err != nil {
+		return err
+	}
+
+	flags, err := ldflags(importPath, keys, values)
+	if err != nil {
+		return err
+	}
+
+	if varsFile != "" {
+		src, err := ldflagsVarsSource(packageNameOf(varsFile), keys)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(varsFile), 0755); err != nil {
+			return withExitCode(exitWriteFailure, err)
+		}
+		if err := writeFileAtomic(varsFile, src, defaultFileMode); err != nil {
+			return withExitCode(exitWriteFailure, err)
+		}
+	}
+
+	if opts.output == "" || opts.output == stdio {
+		stdout := opts.stdout
+		if stdout == nil {
+			stdout = os.Stdout
+		}
+		_, err := fmt.Fprintln(stdout, flags)
+		return withExitCode(exitWriteFailure, err)
+	}
+	return withExitCode(exitWriteFailure, ioutil.WriteFile(opts.output, []byte(flags+"\n"), 0600))
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLdflags(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "ldflags")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	os.Setenv("API_KEY", "s3cr3t")
+	os.Setenv("DB_PASSWORD", "hunter 2")
+	defer os.Unsetenv("API_KEY")
+	defer os.Unsetenv("DB_PASSWORD")
+
+	var stdout bytes.Buffer
+	varsFile := filepath.Join(tempDir, "config", "vars.go")
+	err = generateLdflags(options{keys: "API_KEY,DB_PASSWORD,DEBUG?", stdout: &stdout}, "github.com/me/app/config", varsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "-X github.com/me/app/config.APIKey=s3cr3t -X 'github.com/me/app/config.DBPassword=hunter 2' -X github.com/me/app/config.Debug=\n"
+	if stdout.String() != expected {
+		t.Errorf("Expected flags [%s] but got [%s]", expected, stdout.String())
+	}
+
+	vars, err := ioutil.ReadFile(varsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, declaration := range []string{"package config", "APIKey string", "DBPassword string"} {
+		if !strings.Contains(string(vars), declaration) {
+			t.Errorf("Variables file should contain [%s] but was:\n\n%s", declaration, string(vars))
+		}
+	}
+	if strings.Contains(string(vars), "s3cr3t") {
+		t.Errorf("Variables file shouldn't contain values but was:\n\n%s", string(vars))
+	}
+}
+
+func TestLdflagsUnquotableValue(t *testing.T) {
+	_, err := ldflags("config", []string{"TOKEN"}, map[string]string{"TOKEN": "it's \"quoted\""})
+	if err == nil || strings.Contains(err.Error(), "it's") {
+		t.Errorf("Expected an error without the value but got [%v]", err)
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"path/filepath"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+// fileGenerator returns the generator of the file generated from the template of path to out. With
+// --line-directives, it writes //line directives naming the template relative to the directory of out, as the
+// compiler resolves them.
+func (g *generation) fileGenerator(path string, out string) safekeeper.Generator {
+	generator := g.generator
+	if !g.lineDirectives || path == stdio {
+		return generator
+	}
+
+	template := g.finder.ext.templateOf(path)
+	name := template
+	if out != stdio {
+		if rel, err := filepath.Rel(filepath.Dir(out), template); err == nil {
+			name = rel
+		}
+	}
+	generator.LineDirectives = filepath.ToSlash(name)
+	return generator
+}
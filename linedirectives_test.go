@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+func TestLineDirectives(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	generationDriverFile, err := writeGenerationDriverFile(tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writeTestTemplate(tempDir); err != nil {
+		t.Fatal(err)
+	}
+
+	env := safekeeper.LookupMap(map[string]string{"CLIENT_ID": "safeid", "CLIENT_SECRET": "safesecret"})
+	outputDir := filepath.Join(tempDir, "gen")
+	if err := run(options{keys: "CLIENT_ID,CLIENT_SECRET", paths: []string{generationDriverFile}, outputDir: outputDir, lineDirectives: true, format: true, lookupEnv: env}); err != nil {
+		t.Fatal(err)
+	}
+
+	generated, err := ioutil.ReadFile(filepath.Join(outputDir, "secrets.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(generated), "\n//line ../secrets.go.safekeeper:1\npackage secrets\n") {
+		t.Errorf("Expected the package clause to be mapped to the first line of the template relative to the output but got [%s]", string(generated))
+	}
+
+	if err := checkLang(options{lang: langText, lineDirectives: true}); err == nil {
+		t.Error("Expected --line-directives to only apply to Go")
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+)
+
+const (
+	// lineEndingsPreserve keeps the line endings of templates, the header taking the ones of its template
+	lineEndingsPreserve = "preserve"
+	// lineEndingsLF normalizes line endings to LF
+	lineEndingsLF = "lf"
+	// lineEndingsCRLF normalizes line endings to CRLF
+	lineEndingsCRLF = "crlf"
+)
+
+// checkLineEndings fails if lineEndings isn't a known normalization, e.g. from a config file
+func checkLineEndings(lineEndings string) error {
+	switch lineEndings {
+	case "", lineEndingsPreserve, lineEndingsLF, lineEndingsCRLF:
+		return nil
+	}
+	return fmt.Errorf("Unknown line endings [%s], expected %s, %s or %s", lineEndings, lineEndingsPreserve, lineEndingsLF, lineEndingsCRLF)
+}
+
+// normalizeLineEndings returns src with its CRLF line endings replaced by LF ones
+func normalizeLineEndings(src []byte) []byte {
+	return bytes.Replace(src, []byte("\r\n"), []byte("\n"), -1)
+}
+
+// crlfLineEndings returns src with its LF line endings replaced by CRLF ones
+func crlfLineEndings(src []byte) []byte {
+	return bytes.Replace(normalizeLineEndings(src), []byte("\n"), []byte("\r\n"), -1)
+}
+
+// usesCRLF reports whether the template source ends its first line with CRLF
+func usesCRLF(template []byte) bool {
+	i := bytes.IndexByte(template, '\n')
+	return i > 0 && template[i-1] == '\r'
+}
+
+// withLineEndings returns the generated src, starting with a header of headerLen bytes, with the line endings
+// of g: the ones of template (the header being written with LF endings) unless normalized
+func (g *generation) withLineEndings(src []byte, headerLen int, template []byte) []byte {
+	switch g.lineEndings {
+	case lineEndingsLF:
+		return normalizeLineEndings(src)
+	case lineEndingsCRLF:
+		return crlfLineEndings(src)
+	}
+	if usesCRLF(template) {
+		return append(crlfLineEndings(src[:headerLen]), src[headerLen:]...)
+	}
+	return src
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLineEndings(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "lineendings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	os.Setenv("TOKEN", "s3cr3t")
+	defer os.Unsetenv("TOKEN")
+
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "secrets.go"+templateExt), []byte("package secrets\r\n\r\nconst token = \"ENV_TOKEN\""), 0644); err != nil {
+		t.Fatal(err)
+	}
+	generated := filepath.Join(tempDir, "secrets.go")
+
+	if err := run(options{keys: "TOKEN", paths: []string{generated}}); err != nil {
+		t.Fatal(err)
+	}
+	output, _ := ioutil.ReadFile(generated)
+	if strings.Count(string(output), "\n") != strings.Count(string(output), "\r\n") || !strings.HasSuffix(string(output), "const token = \"s3cr3t\"") {
+		t.Errorf("Expected the CRLF line endings and missing final newline of the template to be preserved but got [%q]", string(output))
+	}
+
+	if err := run(options{keys: "TOKEN", paths: []string{generated}, lineEndings: lineEndingsLF}); err != nil {
+		t.Fatal(err)
+	}
+	output, _ = ioutil.ReadFile(generated)
+	if strings.Contains(string(output), "\r") || !strings.Contains(string(output), " --line-endings=lf ") {
+		t.Errorf("Expected LF line endings with --line-endings=lf but got [%q]", string(output))
+	}
+
+	if err := run(options{keys: "TOKEN", paths: []string{generated}, lineEndings: "cr"}); err == nil {
+		t.Errorf("Expected unknown line endings to fail")
+	}
+}
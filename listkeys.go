@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// keyUsage is a key with the templates that reference it
+type keyUsage struct {
+	Key       string   `json:"key"`
+	Templates []string `json:"templates"`
+}
+
+// keyUsages returns the keys of the placeholders of the templates of opts, sorted, with the templates that
+// reference them. The template of - is stdin.
+func keyUsages(opts options) ([]keyUsage, error) {
+	finder := opts.finder()
+	inputPaths, err := finder.expandPaths(opts.paths)
+	if err != nil {
+		return nil, err
+	}
+	generator, err := opts.generator()
+	if err != nil {
+		return nil, err
+	}
+	stdinTemplate, err := opts.stdinTemplate(inputPaths)
+	if err != nil {
+		return nil, err
+	}
+	outputs, err := finder.outputsOf(inputPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	templates := make(map[string][]string)
+	for _, output := range outputs {
+		file, err := openTemplateFile(finder.filesystem(), output, finder.ext, stdinTemplate)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys, err := generator.FindKeys(file)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		template := output
+		if output != stdio {
+			template = finder.ext.templateOf(output)
+		}
+		for _, key := range keys {
+			templates[key] = append(templates[key], template)
+		}
+	}
+
+	usages := make([]keyUsage, 0, len(templates))
+	for key, keyTemplates := range templates {
+		usages = append(usages, keyUsage{Key: key, Templates: keyTemplates})
+	}
+	sort.Slice(usages, func(i, j int) bool { return usages[i].Key < usages[j].Key })
+	return usages, nil
+}
+
+// listKeys writes the sorted keys of the placeholders of the templates of opts to its stdout, one per line or,
+// with files, as a table of the keys and the templates using them. With asJSON, they're written as a JSON array
+// of keys and templates instead.
+func listKeys(opts options, files bool, asJSON bool) error {
+	usages, err := keyUsages(opts)
+	if err != nil {
+		return err
+	}
+
+	stdout := opts.stdout
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	if asJSON {
+		content, err := json.MarshalIndent(usages, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(stdout, string(content))
+		return withExitCode(exitWriteFailure, err)
+	}
+
+	table := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	for _, usage := range usages {
+		if files {
+			fmt.Fprintf(table, "%s\t%s\n", usage.Key, strings.Join(usage.Templates, ", "))
+		} else {
+			fmt.Fprintln(table, usage.Key)
+		}
+	}
+	return withExitCode(exitWriteFailure, table.Flush())
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListKeys(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "listkeys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	templates := map[string]string{
+		"secrets.go.safekeeper":   "package secrets\nconst token = \"ENV_TOKEN\"\n",
+		"db/config.go.safekeeper": "package db\nconst dsn = \"ENV_DB_USER:ENV_DB_PASSWORD@ENV_DB_HOST:-localhost\"\n",
+	}
+	for name, template := range templates {
+		path := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(template), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var stdout bytes.Buffer
+	if err := listKeys(options{paths: []string{tempDir}, stdout: &stdout}, false, false); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "DB_HOST\nDB_PASSWORD\nDB_USER\nTOKEN\n"
+	if stdout.String() != expected {
+		t.Errorf("Expected keys [%s] but got [%s]", expected, stdout.String())
+	}
+}
+
+func TestListKeysWithFiles(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "listkeys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	for name, template := range map[string]string{"a.go.safekeeper": "ENV_TOKEN ENV_URL", "b.go.safekeeper": "ENV_TOKEN"} {
+		if err := ioutil.WriteFile(filepath.Join(tempDir, name), []byte(template), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a, b := filepath.Join(tempDir, "a.go.safekeeper"), filepath.Join(tempDir, "b.go.safekeeper")
+
+	var table bytes.Buffer
+	if err := listKeys(options{paths: []string{tempDir}, stdout: &table}, true, false); err != nil {
+		t.Fatal(err)
+	}
+	expected := "TOKEN  " + a + ", " + b + "\nURL    " + a + "\n"
+	if table.String() != expected {
+		t.Errorf("Expected table [%s] but got [%s]", expected, table.String())
+	}
+
+	var usages []keyUsage
+	var output bytes.Buffer
+	if err := listKeys(options{paths: []string{tempDir}, stdout: &output}, false, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(output.Bytes(), &usages); err != nil {
+		t.Fatal(err)
+	}
+	if len(usages) != 2 || usages[0].Key != "TOKEN" || len(usages[0].Templates) != 2 || usages[1].Key != "URL" {
+		t.Errorf("Unexpected key usages %+v", usages)
+	}
+}
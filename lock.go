@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+// defaultLockFile is the lockfile status reads unless --lockfile (or lockfile in the config file) is set
+const defaultLockFile = "safekeeper.lock"
+
+// lock records the files generated by the last successful runs, for status to tell which ones are out of date
+// without resolving any value. Its paths are relative to its directory, with forward slashes.
+type lock struct {
+	Files []lockFile `json:"files"`
+}
+
+// lockFile records the generation of a file, like manifestFile
+type lockFile struct {
+	Template string `json:"template"`
+	Output   string `json:"output"`
+	// Keys are the keys of the placeholders of the template, by name
+	Keys         []string `json:"keys"`
+	TemplateHash string   `json:"templateHash"`
+	OutputHash   string   `json:"outputHash"`
+}
+
+// readLock reads the lockfile at path, empty if it doesn't exist
+func readLock(path string) (lock, error) {
+	var l lock
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return l, err
+	}
+	if err := json.Unmarshal(content, &l); err != nil {
+		return l, fmt.Errorf("Invalid lockfile [%s]: %s", path, err)
+	}
+	return l, nil
+}
+
+// updateLock records the files generated by g in the lockfile at path, replacing the entries of their outputs.
+// Since go:generate lines usually generate a file each, the entries of the other outputs are kept.
+func (g *generation) updateLock(path string) error {
+	l, err := readLock(path)
+	if err != nil {
+		return err
+	}
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+
+	files := make(map[string]lockFile, len(l.Files)+len(g.manifestFiles))
+	for _, file := range l.Files {
+		files[file.Output] = file
+	}
+	for _, generated := range g.manifestFiles {
+		if generated.Output == stdio || generated.Template == stdio {
+			continue
+		}
+		file := lockFile{TemplateHash: generated.TemplateHash, OutputHash: generated.OutputHash}
+		if file.Keys, err = templateKeys(g.generator, generated.Template); err != nil {
+			return err
+		}
+		if file.Template, err = lockPath(dir, generated.Template); err != nil {
+			return err
+		}
+		if file.Output, err = lockPath(dir, generated.Output); err != nil {
+			return err
+		}
+		files[file.Output] = file
+	}
+
+	l.Files = make([]lockFile, 0, len(files))
+	for _, file := range files {
+		l.Files = append(l.Files, file)
+	}
+	sort.Slice(l.Files, func(i, j int) bool { return l.Files[i].Output < l.Files[j].Output })
+	content, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	return withExitCode(exitWriteFailure, writeFileAtomic(path, append(content, '\n'), 0644))
+}
+
+// lockPath returns path relative to dir, the directory of a lockfile, with forward slashes
+func lockPath(dir string, path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(dir, abs)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// templateKeys returns the sorted keys of the placeholders of the template at path
+func templateKeys(generator safekeeper.Generator, path string) ([]string, error) {
+	template, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	text, err := templateText(path, template)
+	if err != nil {
+		return nil, err
+	}
+	return generator.FindKeys(bytes.NewReader(text))
+}
+
+// drift returns why the file of the lock entry file, under dir, is out of date: its template is missing or
+// changed (its keys in particular), or the file itself is missing or was changed since. It's empty if the file is
+// up to date.
+func drift(generator safekeeper.Generator, dir string, file lockFile) (string, error) {
+	templatePath := filepath.Join(dir, filepath.FromSlash(file.Template))
+	template, err := ioutil.ReadFile(templatePath)
+	if os.IsNotExist(err) {
+		return "template missing", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if contentHash(template) != file.TemplateHash {
+		keys, err := templateKeys(generator, templatePath)
+		if err != nil {
+			return "", err
+		}
+		if added, removed := diffKeys(file.Keys, keys); len(added) > 0 || len(removed) > 0 {
+			var changes []string
+			for _, key := range added {
+				changes = append(changes, "+"+key)
+			}
+			for _, key := range removed {
+				changes = append(changes, "-"+key)
+			}
+			return fmt.Sprintf("keys changed (%s)", strings.Join(changes, ", ")), nil
+		}
+		return "template changed", nil
+	}
+
+	output, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(file.Output)))
+	if os.IsNotExist(err) {
+		return "output missing", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if contentHash(output) != file.OutputHash {
+		return "output changed", nil
+	}
+	return "", nil
+}
+
+// diffKeys returns the sorted keys of current that aren't in locked and the ones of locked that aren't in current
+func diffKeys(locked []string, current []string) (added []string, removed []string) {
+	in := func(keys []string, key string) bool {
+		i := sort.SearchStrings(keys, key)
+		return i < len(keys) && keys[i] == key
+	}
+	for _, key := range current {
+		if !in(locked, key) {
+			added = append(added, key)
+		}
+	}
+	for _, key := range locked {
+		if !in(current, key) {
+			removed = append(removed, key)
+		}
+	}
+	return added, removed
+}
+
+// status writes the generated files of the lockfile of opts that are out of date to its stdout, with why, failing
+// if there are any. Only hashes are compared: no value is resolved.
+func status(opts options) error {
+	path := valueOr(opts.lockfile, defaultLockFile)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return fmt.Errorf("No lockfile [%s], generate with --lockfile=%s first", path, path)
+	}
+	l, err := readLock(path)
+	if err != nil {
+		return err
+	}
+	generator, err := opts.generator()
+	if err != nil {
+		return err
+	}
+
+	stdout := opts.stdout
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	var color string
+	if opts.useColor(stdout) {
+		color = colorYellow
+	}
+	dir := filepath.Dir(path)
+	table := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	outdated := 0
+	for _, file := range l.Files {
+		reason, err := drift(generator, dir, file)
+		if err != nil {
+			return err
+		}
+		if reason != "" {
+			outdated++
+			fmt.Fprintf(table, "%s\t%s\n", paint(filepath.Join(dir, filepath.FromSlash(file.Output)), color), reason)
+		}
+	}
+	if err := table.Flush(); err != nil {
+		return withExitCode(exitWriteFailure, err)
+	}
+
+	if outdated > 0 {
+		return withExitCode(exitStale, fmt.Errorf("%d of %d generated files are out of date, run go generate", outdated, len(l.Files)))
+	}
+	_, err = fmt.Fprintf(stdout, "All %d generated files are up to date\n", len(l.Files))
+	return withExitCode(exitWriteFailure, err)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+func TestLockfileStatus(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	if err := os.Mkdir(filepath.Join(tempDir, "config"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	secretsDriver, err := writeGenerationDriverFile(tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secretsTemplate, err := writeTestTemplate(tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	configTemplate := filepath.Join(tempDir, "config", "config.go.safekeeper")
+	if err := ioutil.WriteFile(configTemplate, []byte("package config\n\nconst url = \"ENV_API_URL\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	lockfile := filepath.Join(tempDir, defaultLockFile)
+	env := safekeeper.LookupMap(map[string]string{"CLIENT_ID": "safeid", "CLIENT_SECRET": "safesecret", "API_URL": "https://api", "API_KEY": "s3cr3t"})
+	for _, path := range []string{secretsDriver, filepath.Join(tempDir, "config", "config.go")} {
+		if err := run(options{paths: []string{path}, lockfile: lockfile, lookupEnv: env}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	l, err := readLock(lockfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l.Files) != 2 || l.Files[0].Output != "config/config.go" || l.Files[1].Output != "secrets.go" || l.Files[1].Template != "secrets.go.safekeeper" {
+		t.Fatalf("Expected both generations to be recorded relative to the lockfile but got %+v", l.Files)
+	}
+	if !reflect.DeepEqual(l.Files[1].Keys, []string{"CLIENT_ID", "CLIENT_SECRET"}) {
+		t.Errorf("Expected the keys of the template to be recorded but got %v", l.Files[1].Keys)
+	}
+	if content, _ := ioutil.ReadFile(lockfile); bytes.Contains(content, []byte("safesecret")) {
+		t.Errorf("The lockfile shouldn't have values but got [%s]", string(content))
+	}
+
+	var stdout bytes.Buffer
+	opts := options{lockfile: lockfile, stdout: &stdout}
+	if err := status(opts); err != nil || !strings.Contains(stdout.String(), "All 2 generated files are up to date") {
+		t.Fatalf("Expected the generated files to be up to date but got [%v] and [%s]", err, stdout.String())
+	}
+
+	if err := ioutil.WriteFile(configTemplate, []byte("package config\n\nconst url = \"ENV_API_URL\"\nconst key = \"ENV_API_KEY\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(tempDir, "secrets.go")); err != nil {
+		t.Fatal(err)
+	}
+	stdout.Reset()
+	err = status(opts)
+	if exitCode(err) != exitStale || !strings.Contains(err.Error(), "2 of 2") {
+		t.Fatalf("Expected both files to be out of date but got [%v]", err)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	for i, expected := range [][]string{{filepath.Join(tempDir, "config", "config.go"), "keys changed (+API_KEY)"}, {filepath.Join(tempDir, "secrets.go"), "output missing"}} {
+		if i >= len(lines) || strings.Join(strings.Fields(lines[i]), " ") != strings.Join(expected, " ") {
+			t.Errorf("Expected %v in the status but got [%s]", expected, stdout.String())
+		}
+	}
+
+	if err := os.Remove(secretsTemplate); err != nil {
+		t.Fatal(err)
+	}
+	stdout.Reset()
+	if err := status(opts); exitCode(err) != exitStale || !strings.Contains(stdout.String(), "template missing") {
+		t.Errorf("Expected the missing template to be reported but got [%v] and [%s]", err, stdout.String())
+	}
+
+	if err := status(options{lockfile: filepath.Join(tempDir, "missing.lock")}); err == nil || !strings.Contains(err.Error(), "--lockfile") {
+		t.Errorf("Expected a missing lockfile to fail but got [%v]", err)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+// Levels of log messages, in increasing severity
+const (
+	levelDebug = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+// logLevels are the levels of --log-level, by name
+var logLevels = map[string]int{"debug": levelDebug, "info": levelInfo, "warn": levelWarn, "error": levelError}
+
+// levelNames are the names of the levels, by level
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+// Formats of --log-format
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// logger writes the messages of a run at or above its level, as text or JSON lines, with the values of the keys
+// it was given redacted
+type logger struct {
+	w     io.Writer
+	level int
+	json  bool
+	// color colors the level of text messages, and their following lines (e.g. the files check lists)
+	color bool
+
+	mu       sync.Mutex
+	redactor *safekeeper.Redactor
+}
+
+// newLogger returns the logger writing to w the messages at or above the level named level, warn if empty, in
+// format, text if empty
+func newLogger(w io.Writer, level string, format string) (*logger, error) {
+	l := &logger{w: w, level: levelWarn}
+	if level != "" {
+		var ok bool
+		if l.level, ok = logLevels[level]; !ok {
+			return nil, fmt.Errorf("Unknown log level [%s], expected %s", level, strings.Join(levelNames, ", "))
+		}
+	}
+	switch format {
+	case "", logFormatText:
+	case logFormatJSON:
+		l.json = true
+	default:
+		return nil, fmt.Errorf("Unknown log format [%s], expected %s or %s", format, logFormatText, logFormatJSON)
+	}
+	return l, nil
+}
+
+// redact makes l redact secrets from its messages
+func (l *logger) redact(secrets map[string]safekeeper.Secret) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.redactor = safekeeper.NewRedactor(secrets)
+}
+
+// logEntry is a message of the JSON format
+type logEntry struct {
+	Time    string `json:"time"`
+	Level   string `json:"level"`
+	Message string `json:"msg"`
+	// File is the file the message is about, if any
+	File string `json:"file,omitempty"`
+}
+
+// log writes the message of level about file, if any, unless it's below the level of l
+func (l *logger) log(level int, file string, format string, args ...interface{}) {
+	if level < l.level {
+		return
+	}
+	l.write(level, file, format, args...)
+}
+
+// note writes an info message whatever the level of l, e.g. the progress of a run that --quiet didn't silence
+func (l *logger) note(format string, args ...interface{}) {
+	l.write(levelInfo, "", format, args...)
+}
+
+// write writes the message of level about file, if any
+func (l *logger) write(level int, file string, format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	message := fmt.Sprintf(format, args...)
+	if l.redactor != nil {
+		message = l.redactor.Replace(message)
+	}
+
+	if l.json {
+		entry, _ := json.Marshal(logEntry{Time: time.Now().UTC().Format(time.RFC3339), Level: levelNames[level], Message: message, File: file})
+		fmt.Fprintf(l.w, "%s\n", entry)
+		return
+	}
+	var prefix, color string
+	switch level {
+	case levelDebug:
+		prefix = "Debug: "
+	case levelWarn:
+		prefix, color = "Warning: ", colorYellow
+	case levelError:
+		prefix, color = "Error: ", colorRed
+	}
+	if l.color {
+		first, rest := message, ""
+		if i := strings.IndexByte(message, '\n'); i >= 0 {
+			first, rest = message[:i], message[i:]
+		}
+		message = paint(prefix, colorBold+color) + first + paint(rest, color)
+	} else {
+		message = prefix + message
+	}
+	fmt.Fprintln(l.w, message)
+}
+
+func (l *logger) debugf(format string, args ...interface{}) { l.log(levelDebug, "", format, args...) }
+func (l *logger) infof(format string, args ...interface{})  { l.log(levelInfo, "", format, args...) }
+func (l *logger) warnf(format string, args ...interface{})  { l.log(levelWarn, "", format, args...) }
+func (l *logger) errorf(format string, args ...interface{}) { l.log(levelError, "", format, args...) }
+
+// fileInfof logs the progress of the generation of file
+func (l *logger) fileInfof(file string, format string, args ...interface{}) {
+	l.log(levelInfo, file, format, args...)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+func TestLoggerLevels(t *testing.T) {
+	var stderr bytes.Buffer
+	log, err := newLogger(&stderr, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.debugf("debug")
+	log.infof("info")
+	log.warnf("Skipping [%s]", "file")
+	log.errorf("failed")
+
+	if stderr.String() != "Warning: Skipping [file]\nError: failed\n" {
+		t.Errorf("Expected only the warnings and errors to be logged by default but got [%s]", stderr.String())
+	}
+	if _, err := newLogger(&stderr, "trace", ""); err == nil {
+		t.Error("Expected an unknown log level to be rejected")
+	}
+}
+
+func TestLoggerRedactsValues(t *testing.T) {
+	var stderr bytes.Buffer
+	log, err := newLogger(&stderr, "debug", logFormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.redact(safekeeper.Secrets(map[string]string{"TOKEN": "s3cr3t", "LONG_TOKEN": "s3cr3t-and-more", "EMPTY": ""}))
+	log.fileInfof("config.go", "Value s3cr3t-and-more then s3cr3t")
+
+	var entry logEntry
+	if err := json.Unmarshal(stderr.Bytes(), &entry); err != nil {
+		t.Fatalf("Expected a JSON line but got [%s]: %s", stderr.String(), err)
+	}
+	if entry.Level != "info" || entry.File != "config.go" || entry.Message != "Value [REDACTED] then [REDACTED]" {
+		t.Errorf("Expected the values to be redacted from the message of the file but got %+v", entry)
+	}
+}
+
+func TestLogProgress(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "secrets.go"+templateExt), []byte("package secrets\n\nconst token = \"ENV_TOKEN\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("TOKEN", "s3cr3t")
+	defer os.Unsetenv("TOKEN")
+
+	var stderr bytes.Buffer
+	if err := run(options{paths: []string{tempDir}, stderr: &stderr, logLevel: "debug"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stderr.String(), "Generated ["+filepath.Join(tempDir, "secrets.go")+"]") || !strings.Contains(stderr.String(), "Debug: Resolved 1 keys") {
+		t.Errorf("Expected the progress of the generation to be logged but got [%s]", stderr.String())
+	}
+	if strings.Contains(stderr.String(), "s3cr3t") {
+		t.Errorf("Values should never be logged but got [%s]", stderr.String())
+	}
+}
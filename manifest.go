@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+)
+
+// manifest records what a run generated, for tooling auditing what was injected where. It never has values.
+type manifest struct {
+	Files []manifestFile `json:"files"`
+}
+
+// manifestFile records the generation of a file
+type manifestFile struct {
+	Template string `json:"template"`
+	Output   string `json:"output"`
+	// Keys are the keys substituted in the file, by name
+	Keys         []string `json:"keys"`
+	TemplateHash string   `json:"templateHash"`
+	OutputHash   string   `json:"outputHash"`
+}
+
+// contentHash returns the hash of content as recorded in manifests
+func contentHash(content []byte) string {
+	hash := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(hash[:])
+}
+
+// record adds the generation of out from template (the template of path) to the manifest of g, if it has one
+func (g *generation) record(path string, out string, template []byte, src []byte) error {
+	if !g.recordFiles {
+		return nil
+	}
+
+	placeholders, err := g.generator.FindPlaceholders(bytes.NewReader(template))
+	if err != nil {
+		return err
+	}
+	keys := []string{}
+	seen := make(map[string]bool)
+	for _, placeholder := range placeholders {
+		if _, ok := g.keyValues[placeholder.Key]; (ok || placeholder.HasDefault) && !seen[placeholder.Key] {
+			seen[placeholder.Key] = true
+			keys = append(keys, placeholder.Key)
+		}
+	}
+	sort.Strings(keys)
+
+	templatePath := path
+	if path != stdio {
+		templatePath = g.finder.ext.templateOf(path)
+	}
+	file := manifestFile{Template: templatePath, Output: out, Keys: keys, TemplateHash: contentHash(template), OutputHash: contentHash(src)}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.manifestFiles = append(g.manifestFiles, file)
+	return nil
+}
+
+// writeManifest writes the manifest of the files generated by g to path, sorted by output
+func (g *generation) writeManifest(path string) error {
+	files := append([]manifestFile{}, g.manifestFiles...)
+	sort.Slice(files, func(i, j int) bool { return files[i].Output < files[j].Output })
+
+	content, err := json.MarshalIndent(manifest{Files: files}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return withExitCode(exitWriteFailure, ioutil.WriteFile(path, append(content, '\n'), 0644))
+}
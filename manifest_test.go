@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestManifest(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	os.Setenv("TOKEN", "s3cr3t")
+	defer os.Unsetenv("TOKEN")
+
+	template := filepath.Join(tempDir, "secrets.go"+templateExt)
+	if err := ioutil.WriteFile(template, []byte("package secrets\n\nconst (\n\ttoken = \"ENV_TOKEN\"\n\tregion = \"ENV_REGION:-us-east-1\"\n)\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	manifestFile := filepath.Join(tempDir, "safekeeper-manifest.json")
+	if err := run(options{paths: []string{tempDir}, manifest: manifestFile}); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := ioutil.ReadFile(manifestFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "s3cr3t") {
+		t.Errorf("Manifest shouldn't have values but was:\n\n%s", string(content))
+	}
+
+	var m manifest
+	if err := json.Unmarshal(content, &m); err != nil {
+		t.Fatal(err)
+	}
+	generated, err := ioutil.ReadFile(filepath.Join(tempDir, "secrets.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Files) != 1 {
+		t.Fatalf("Expected one generated file in the manifest but got:\n\n%s", string(content))
+	}
+	file := m.Files[0]
+	if file.Template != template || file.Output != filepath.Join(tempDir, "secrets.go") || strings.Join(file.Keys, ",") != "REGION,TOKEN" {
+		t.Errorf("Unexpected manifest entry %+v", file)
+	}
+	if file.OutputHash != contentHash(generated) || !strings.HasPrefix(file.TemplateHash, "sha256:") {
+		t.Errorf("Expected the hash of the generated file [%s] but got %+v", contentHash(generated), file)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"time"
+)
+
+// checkMerge fails if the settings of opts can't be used when merging the templates of several inputs into its
+// output
+func checkMerge(opts options) error {
+	if opts.lineDirectives {
+		return errors.New("--line-directives can't be used when merging several inputs into --output")
+	}
+	if opts.lockfile != "" {
+		return errors.New("--lockfile can't be used when merging several inputs into --output, it records a template per output")
+	}
+	return nil
+}
+
+// generateMerged generates the sources of paths and writes them to out as a single file, the sources following
+// a single header in the order of paths. For Go, they also share a single package clause and import declaration.
+func (g *generation) generateMerged(paths []string, out string) (err error) {
+	g.progress.add(1)
+	g.jobs.acquire()
+	defer g.jobs.release()
+	defer g.progress.step()
+	if err := contextErr(g.ctx); err != nil {
+		return err
+	}
+
+	outputs, err := g.finder.outputsOf(paths)
+	if err != nil {
+		return err
+	}
+	for _, output := range outputs {
+		if err := g.checkOutputNotTemplate(output, out); err != nil {
+			return err
+		}
+	}
+
+	var report *fileReport
+	if g.report != nil {
+		templates := make([]string, len(outputs))
+		for i, output := range outputs {
+			templates[i] = output
+			if output != stdio {
+				templates[i] = g.finder.ext.templateOf(output)
+			}
+		}
+		report = g.report.startFile(strings.Join(templates, ", "))
+		defer func() { g.report.endFile(report, err) }()
+	}
+
+	var header bytes.Buffer
+	if err := g.writeHeader(&header, outputs[0], out); err != nil {
+		return err
+	}
+
+	substituting := time.Now()
+	sources := make([][]byte, len(outputs))
+	templates := make([][]byte, len(outputs))
+	for i, output := range outputs {
+		var buffer bytes.Buffer
+		if sources[i], templates[i], err = g.substituteValues(output, out, &buffer); err != nil {
+			return inputError{path: output, err: err}
+		}
+	}
+	template := bytes.Join(templates, nil)
+
+	var body []byte
+	if g.lang == langText {
+		body = concatSources(sources)
+	} else if body, err = mergeGoSources(outputs, sources); err != nil {
+		return err
+	}
+
+	src := append(header.Bytes(), body...)
+	src = g.withBOM(g.withLineEndings(src, header.Len(), template), template)
+	if err := report.count(&g.generator, template, src, g.keyValues); err != nil {
+		return err
+	}
+	substitution, writing := time.Since(substituting), time.Now()
+	defer func() { g.timings.timeFile(report, out, substitution, time.Since(writing)) }()
+	return g.finishFile(outputs[0], out, template, src, report)
+}
+
+// concatSources returns sources one after the other, each ending with a line ending
+func concatSources(sources [][]byte) []byte {
+	var merged bytes.Buffer
+	for _, src := range sources {
+		merged.Write(src)
+		if len(src) > 0 && !bytes.HasSuffix(src, []byte("\n")) {
+			merged.WriteString("\n")
+		}
+	}
+	return merged.Bytes()
+}
+
+// mergeGoSources returns the Go sources generated from the templates of paths merged into a single file: the
+// package clause (and what precedes it) of the first source, the imports of all the sources without duplicates
+// and their declarations in order. Helpers identical from one source to the next (e.g. the functions revealing
+// obfuscated literals) are only kept once. All the sources must be of the same package.
+func mergeGoSources(paths []string, sources [][]byte) ([]byte, error) {
+	var merged bytes.Buffer
+	var decls [][]byte
+	var packageName string
+	var imports []string
+	seenImports := make(map[string]bool)
+	seenHelpers := make(map[string]bool)
+	for i, src := range sources {
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, paths[i], src, parser.ParseComments)
+		if err != nil {
+			return nil, inputError{path: paths[i], err: invalidSourceError(err)}
+		}
+		offset := func(pos token.Pos) int { return fset.Position(pos).Offset }
+
+		if i == 0 {
+			packageName = file.Name.Name
+			merged.Write(src[:offset(file.Name.End())])
+			merged.WriteString("\n")
+		} else if file.Name.Name != packageName {
+			return nil, fmt.Errorf("Can't merge [%s] of package %s with [%s] of package %s", paths[i], file.Name.Name, paths[0], packageName)
+		}
+
+		// Declarations start after the package clause or the last import declaration
+		start := offset(file.Name.End())
+		var cuts [][2]int
+		for _, decl := range file.Decls {
+			switch decl := decl.(type) {
+			case *ast.GenDecl:
+				if decl.Tok != token.IMPORT {
+					continue
+				}
+				for _, spec := range decl.Specs {
+					importSpec := spec.(*ast.ImportSpec)
+					importLine := importSpec.Path.Value
+					if importSpec.Name != nil {
+						importLine = importSpec.Name.Name + " " + importLine
+					}
+					if !seenImports[importLine] {
+						seenImports[importLine] = true
+						imports = append(imports, importLine)
+					}
+				}
+				start = offset(decl.End())
+			case *ast.FuncDecl:
+				if !strings.HasPrefix(decl.Name.Name, "safekeeper") {
+					continue
+				}
+				declStart, declEnd := offset(decl.Pos()), offset(decl.End())
+				if decl.Doc != nil {
+					declStart = offset(decl.Doc.Pos())
+				}
+				helper := string(src[declStart:declEnd])
+				if seenHelpers[helper] {
+					// The blank lines following the helper go with it
+					for declEnd < len(src) && src[declEnd] == '\n' {
+						declEnd++
+					}
+					cuts = append(cuts, [2]int{declStart, declEnd})
+				}
+				seenHelpers[helper] = true
+			}
+		}
+
+		var kept bytes.Buffer
+		last := start
+		for _, cut := range cuts {
+			kept.Write(src[last:cut[0]])
+			last = cut[1]
+		}
+		kept.Write(src[last:])
+		if trimmed := bytes.Trim(kept.Bytes(), "\n"); len(trimmed) > 0 {
+			decls = append(decls, trimmed)
+		}
+	}
+
+	if len(imports) > 0 {
+		merged.WriteString("\nimport (\n")
+		for _, importLine := range imports {
+			merged.WriteString("\t" + importLine + "\n")
+		}
+		merged.WriteString(")\n")
+	}
+	for _, decl := range decls {
+		merged.WriteString("\n")
+		merged.Write(decl)
+		merged.WriteString("\n")
+	}
+	return merged.Bytes(), nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+func TestMergedOutput(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "merge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	templates := map[string]string{
+		"db.go.safekeeper":  "// Package config has the settings\npackage config\n\nimport \"fmt\"\n\n// DSN is the database\nvar DSN = fmt.Sprintf(\"postgres://%s\", \"ENV_DB_PASSWORD\")\n",
+		"api.go.safekeeper": "package config\n\nimport (\n\t\"fmt\"\n\t\"strings\"\n)\n\nvar APIKey = strings.TrimSpace(fmt.Sprint(\"ENV_API_KEY\"))\n",
+	}
+	for name, template := range templates {
+		if err := ioutil.WriteFile(filepath.Join(tempDir, name), []byte(template), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	env := safekeeper.LookupMap(map[string]string{"DB_PASSWORD": "s3cr3t", "API_KEY": "k3y"})
+	merged := filepath.Join(tempDir, "merged_gen.go")
+	inputs := []string{filepath.Join(tempDir, "db.go"), filepath.Join(tempDir, "api.go")}
+	if err := run(options{output: merged, paths: inputs, obfuscate: "xor", lookupEnv: env}); err != nil {
+		t.Fatal(err)
+	}
+
+	generated, err := ioutil.ReadFile(merged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := parser.ParseFile(token.NewFileSet(), merged, generated, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("Expected the merged file to be valid Go but got [%s]:\n%s", err, generated)
+	}
+	if len(file.Imports) != 2 || strings.Count(string(generated), "package config") != 1 {
+		t.Errorf("Expected a single package clause and the 2 distinct imports but got:\n%s", generated)
+	}
+	if strings.Count(string(generated), "func safekeeperXOR(") != 1 {
+		t.Errorf("Expected the obfuscation helper to be declared once but got:\n%s", generated)
+	}
+	if strings.Index(string(generated), "var DSN") > strings.Index(string(generated), "var APIKey") || !strings.Contains(string(generated), "// Package config has the settings") {
+		t.Errorf("Expected the declarations in the order of the inputs but got:\n%s", generated)
+	}
+	if strings.Contains(string(generated), "go:generate") {
+		t.Errorf("Expected the merged file to have no go:generate line but got:\n%s", generated)
+	}
+
+	if err := run(options{output: merged, paths: inputs, obfuscate: "xor", lookupEnv: env, check: true}); err != nil {
+		t.Errorf("Expected the merged file to be up to date but got [%v]", err)
+	}
+	if err := run(options{output: merged, paths: inputs, lookupEnv: env, lockfile: filepath.Join(tempDir, "safekeeper.lock")}); err == nil {
+		t.Error("Expected --lockfile to be rejected when merging")
+	}
+}
+
+func TestMergedOutputOfDifferentPackages(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "merge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	for name, template := range map[string]string{"a.go.safekeeper": "package a\n", "b.go.safekeeper": "package b\n"} {
+		if err := ioutil.WriteFile(filepath.Join(tempDir, name), []byte(template), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = run(options{output: filepath.Join(tempDir, "merged.go"), paths: []string{filepath.Join(tempDir, "a.go"), filepath.Join(tempDir, "b.go")}})
+	if err == nil || !strings.Contains(err.Error(), "of package b") {
+		t.Errorf("Expected sources of different packages not to be merged but got [%v]", err)
+	}
+}
+
+func TestMergedTextOutput(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "merge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	for name, template := range map[string]string{"a.env.safekeeper": "A=ENV_A", "b.env.safekeeper": "B=ENV_B\n"} {
+		if err := ioutil.WriteFile(filepath.Join(tempDir, name), []byte(template), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	merged := filepath.Join(tempDir, "merged.env")
+	env := safekeeper.LookupMap(map[string]string{"A": "1", "B": "2"})
+	if err := run(options{output: merged, paths: []string{filepath.Join(tempDir, "a.env"), filepath.Join(tempDir, "b.env")}, lang: langText, noHeader: true, lookupEnv: env}); err != nil {
+		t.Fatal(err)
+	}
+	generated, err := ioutil.ReadFile(merged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(generated) != "A=1\nB=2\n" {
+		t.Errorf("Expected the sources to be concatenated but got [%s]", generated)
+	}
+}
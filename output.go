@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+// backupExt is the extension of the backups of overwritten generated files
+const backupExt = ".bak"
+
+// defaultFileMode is the mode of the files generated from stdin, without a template file to take it from
+const defaultFileMode os.FileMode = 0644
+
+// parseFileMode parses the octal mode of --file-mode, e.g. 0600
+func parseFileMode(mode string) (os.FileMode, error) {
+	perm, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || perm == 0 || perm > 0777 {
+		return 0, fmt.Errorf("Invalid --file-mode [%s], expected octal permissions such as 0600", mode)
+	}
+	return os.FileMode(perm), nil
+}
+
+// outputMode returns the mode of the file generated from path: the one of --file-mode or else the one of its
+// template
+func (g *generation) outputMode(path string) os.FileMode {
+	if g.fileMode != 0 {
+		return g.fileMode
+	}
+	if info, err := fs.Stat(g.finder.filesystem(), g.finder.ext.templateOf(path)); err == nil && path != stdio {
+		return info.Mode().Perm()
+	}
+	return defaultFileMode
+}
+
+// writeOutput writes src to out, the file generated from path, with the mode of outputMode even if out
+// already exists
+func (g *generation) writeOutput(path string, out string, src []byte) error {
+	if !g.force {
+		if err := checkNotEdited(out); err != nil {
+			return err
+		}
+	}
+	if g.backup {
+		if err := g.backupOutput(out, src); err != nil {
+			return withExitCode(exitWriteFailure, err)
+		}
+	}
+	outputs := g.outputs
+	if outputs == nil {
+		outputs = atomicFS{}
+	}
+	return withExitCode(exitWriteFailure, outputs.WriteFile(out, src, g.outputMode(path)))
+}
+
+// checkNotEdited fails if the generated file at out was edited since it was generated, according to the content
+// hash of its header. Files without one (or that don't exist) are never considered edited.
+func checkNotEdited(out string) error {
+	current, err := ioutil.ReadFile(out)
+	if err != nil {
+		return nil
+	}
+	if hashed, intact := safekeeper.CheckContentHash(current); hashed && !intact {
+		return withExitCode(exitEdited, fmt.Errorf("[%s] was edited since it was generated, regenerating it would lose the changes (--force overwrites it)", out))
+	}
+	return nil
+}
+
+// backupOutput saves the current content of out, about to be overwritten by src, unless out doesn't exist yet or
+// is unchanged
+func (g *generation) backupOutput(out string, src []byte) error {
+	current, err := ioutil.ReadFile(out)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(current, src) {
+		return nil
+	}
+
+	info, err := os.Stat(out)
+	if err != nil {
+		return err
+	}
+	backupPath, err := g.backupPathOf(out)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(backupPath), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(backupPath, current, info.Mode().Perm())
+}
+
+// backupPathOf returns the path out is backed up to: out.bak or, with a backup directory, the same path relative to
+// it (only its name if out is outside of the working directory)
+func (g *generation) backupPathOf(out string) (string, error) {
+	if g.backupDir == "" {
+		return out + backupExt, nil
+	}
+
+	abs, err := filepath.Abs(out)
+	if err != nil {
+		return "", err
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	name, err := filepath.Rel(wd, abs)
+	if err != nil || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+		name = filepath.Base(out)
+	}
+	return filepath.Join(g.backupDir, name+backupExt), nil
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it over path, so that an
+// interrupted run never leaves a half-written file behind (one that could even compile). path gets mode
+// whatever its permissions were.
+func writeFileAtomic(path string, data []byte, mode os.FileMode) (err error) {
+	file, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			file.Close()
+			os.Remove(file.Name())
+		}
+	}()
+
+	if _, err = file.Write(data); err != nil {
+		return err
+	}
+	if err = file.Chmod(mode); err != nil {
+		return err
+	}
+	if err = file.Sync(); err != nil {
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
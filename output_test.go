@@ -0,0 +1,166 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileMode(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	os.Setenv("TOKEN", "s3cr3t")
+	defer os.Unsetenv("TOKEN")
+
+	templatePath := filepath.Join(tempDir, "secrets.go"+templateExt)
+	if err := ioutil.WriteFile(templatePath, []byte("package secrets\n\nconst token = \"ENV_TOKEN\"\n"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(templatePath, 0640); err != nil {
+		t.Fatal(err)
+	}
+	generated := filepath.Join(tempDir, "secrets.go")
+
+	if err := run(options{keys: "TOKEN", paths: []string{generated}}); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(generated); err != nil {
+		t.Fatal(err)
+	} else if info.Mode().Perm() != 0640 {
+		t.Errorf("Expected the generated file to have the mode of its template but got [%v]", info.Mode())
+	}
+
+	if err := run(options{keys: "TOKEN", paths: []string{generated}, fileMode: "0600"}); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(generated); err != nil {
+		t.Fatal(err)
+	} else if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the existing generated file to get the mode of --file-mode but got [%v]", info.Mode())
+	}
+
+	for _, mode := range []string{"rw", "0", "01777", "0800"} {
+		if err := run(options{keys: "TOKEN", paths: []string{generated}, fileMode: mode}); err == nil {
+			t.Errorf("Expected --file-mode=%s to fail", mode)
+		}
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	path := filepath.Join(tempDir, "secrets.go")
+	if err := ioutil.WriteFile(path, []byte("previous"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFileAtomic(path, []byte("package secrets\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := ioutil.ReadFile(path); string(content) != "package secrets\n" {
+		t.Errorf("Expected the file to be replaced but got [%s]", string(content))
+	}
+	if files, _ := ioutil.ReadDir(tempDir); len(files) != 1 {
+		t.Errorf("Expected no temporary file left but got %d files", len(files))
+	}
+
+	if err := writeFileAtomic(filepath.Join(tempDir, "missing", "secrets.go"), []byte("package secrets\n"), 0600); err == nil {
+		t.Errorf("Expected writing in a missing directory to fail")
+	}
+}
+
+func TestBackup(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	os.Setenv("TOKEN", "s3cr3t")
+	defer os.Unsetenv("TOKEN")
+
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "secrets.go"+templateExt), []byte("package secrets\n\nconst token = \"ENV_TOKEN\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	generated := filepath.Join(tempDir, "secrets.go")
+
+	if err := run(options{keys: "TOKEN", paths: []string{generated}, noHeader: true, backup: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(generated + backupExt); !os.IsNotExist(err) {
+		t.Errorf("Expected no backup of a file that didn't exist but got [%v]", err)
+	}
+
+	if err := ioutil.WriteFile(generated, []byte("edited"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(options{keys: "TOKEN", paths: []string{generated}, noHeader: true, backup: true}); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := ioutil.ReadFile(generated + backupExt); string(content) != "edited" {
+		t.Errorf("Expected the backup to have the previous content but got [%s]", string(content))
+	}
+
+	backupDir := filepath.Join(tempDir, "backups")
+	if err := ioutil.WriteFile(generated, []byte("edited again"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(options{keys: "TOKEN", paths: []string{generated}, noHeader: true, backupDir: backupDir}); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := ioutil.ReadFile(filepath.Join(backupDir, "secrets.go"+backupExt)); string(content) != "edited again" {
+		t.Errorf("Expected the backup in the backup directory to have the previous content but got [%s]", string(content))
+	}
+}
+
+func TestRefuseEditedOutput(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	os.Setenv("TOKEN", "s3cr3t")
+	defer os.Unsetenv("TOKEN")
+
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "secrets.go"+templateExt), []byte("package secrets\n\nconst token = \"ENV_TOKEN\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	generated := filepath.Join(tempDir, "secrets.go")
+
+	if err := run(options{keys: "TOKEN", paths: []string{generated}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(options{keys: "TOKEN", paths: []string{generated}}); err != nil {
+		t.Errorf("Expected an intact generated file to be regenerated but got [%v]", err)
+	}
+
+	content, _ := ioutil.ReadFile(generated)
+	edited := strings.Replace(string(content), "const token", "// Hand-written\nconst token", 1)
+	if err := ioutil.WriteFile(generated, []byte(edited), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = run(options{keys: "TOKEN", paths: []string{generated}})
+	if exitCode(err) != exitEdited {
+		t.Errorf("Expected exit code %d but got %d (%v)", exitEdited, exitCode(err), err)
+	}
+	if content, _ := ioutil.ReadFile(generated); string(content) != edited {
+		t.Errorf("Expected the edited file to be left as is but got [%s]", string(content))
+	}
+
+	if err := run(options{keys: "TOKEN", paths: []string{generated}, force: true}); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := ioutil.ReadFile(generated); strings.Contains(string(content), "Hand-written") {
+		t.Errorf("Expected --force to overwrite the edited file but got [%s]", string(content))
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// outputPatternVariable matches the variables of output patterns, e.g. {{base}}
+var outputPatternVariable = regexp.MustCompile(`\{\{\s*(\w*)\s*\}\}`)
+
+// outputPatternVariables are the variables of output patterns, in the order they're documented
+var outputPatternVariables = []string{"dir", "name", "base", "ext"}
+
+// checkOutputPattern fails if pattern, the naming pattern of generated files of --output-pattern, has variables
+// other than {{dir}}, {{name}}, {{base}} and {{ext}}
+func checkOutputPattern(pattern string) error {
+	for _, match := range outputPatternVariable.FindAllStringSubmatch(pattern, -1) {
+		known := false
+		for _, variable := range outputPatternVariables {
+			known = known || match[1] == variable
+		}
+		if !known {
+			return fmt.Errorf("Unknown variable [%s] in --output-pattern [%s], expected {{%s}}", match[0], pattern, strings.Join(outputPatternVariables, "}}, {{"))
+		}
+	}
+	if !strings.Contains(pattern, "{{") {
+		return fmt.Errorf("--output-pattern [%s] has no variable, every template would generate the same file", pattern)
+	}
+	return nil
+}
+
+// applyOutputPattern returns the file generated in place of output, the one of a template by default, according to
+// pattern: {{dir}} is the directory of output, {{name}} its name, {{base}} its name without extension and {{ext}}
+// its extension (e.g. config, secrets.go, secrets and .go for config/secrets.go). output is returned as is if
+// pattern is empty.
+func applyOutputPattern(pattern string, output string) string {
+	if pattern == "" {
+		return output
+	}
+	name := filepath.Base(output)
+	ext := filepath.Ext(name)
+	vars := map[string]string{"dir": filepath.ToSlash(filepath.Dir(output)), "name": name, "base": strings.TrimSuffix(name, ext), "ext": ext}
+	named := outputPatternVariable.ReplaceAllStringFunc(pattern, func(variable string) string {
+		return vars[outputPatternVariable.FindStringSubmatch(variable)[1]]
+	})
+	return filepath.Clean(filepath.FromSlash(named))
+}
+
+// patternOutput returns out, the output of the file generated from path (path itself if empty), named according to
+// the output pattern if any
+func (g *generation) patternOutput(path string, out string) string {
+	if g.outputPattern == "" || path == stdio {
+		return out
+	}
+	return applyOutputPattern(g.outputPattern, valueOr(out, path))
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestApplyOutputPattern(t *testing.T) {
+	for _, test := range []struct {
+		pattern  string
+		output   string
+		expected string
+	}{
+		{"", "config/secrets.go", "config/secrets.go"},
+		{"{{dir}}/{{base}}_gen{{ext}}", "config/secrets.go", "config/secrets_gen.go"},
+		{"{{dir}}/{{base}}_gen.go", "secrets.go", "secrets_gen.go"},
+		{"gen/{{ dir }}/{{name}}", "config/db/secrets.go", "gen/config/db/secrets.go"},
+		{"{{dir}}/{{base}}.generated{{ext}}", "deploy/app.yaml", "deploy/app.generated.yaml"},
+	} {
+		if output := applyOutputPattern(test.pattern, filepath.FromSlash(test.output)); output != filepath.FromSlash(test.expected) {
+			t.Errorf("Expected pattern [%s] to name [%s] %s but got %s", test.pattern, test.output, test.expected, output)
+		}
+	}
+}
+
+func TestCheckOutputPattern(t *testing.T) {
+	if err := checkOutputPattern("{{dir}}/{{base}}_gen{{ext}}"); err != nil {
+		t.Errorf("Expected a valid pattern but got [%s]", err)
+	}
+	if err := checkOutputPattern("{{dir}}/{{file}}_gen.go"); err == nil || !strings.Contains(err.Error(), "Unknown variable [{{file}}]") {
+		t.Errorf("Expected an unknown variable to be rejected but got [%v]", err)
+	}
+	if err := checkOutputPattern("secrets_gen.go"); err == nil {
+		t.Error("Expected a pattern without variables to be rejected")
+	}
+}
+
+func TestOutputPattern(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "pattern")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	templatesDir := filepath.Join(tempDir, "templates")
+	for _, dir := range []string{templatesDir, filepath.Join(templatesDir, "db")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := writeTestTemplate(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	os.Setenv("CLIENT_ID", "safeid")
+	os.Setenv("CLIENT_SECRET", "safesecret")
+	defer os.Unsetenv("CLIENT_ID")
+	defer os.Unsetenv("CLIENT_SECRET")
+
+	opts := options{paths: []string{templatesDir}, outputPattern: "{{dir}}/{{base}}_gen{{ext}}"}
+	if err := run(opts); err != nil {
+		t.Fatal(err)
+	}
+	for _, generated := range []string{filepath.Join(templatesDir, "secrets_gen.go"), filepath.Join(templatesDir, "db", "secrets_gen.go")} {
+		output, err := ioutil.ReadFile(generated)
+		if err != nil {
+			t.Fatalf("Expected generated file [%s] but got [%s]", generated, err)
+		}
+		if strings.Contains(string(output), "go:generate") {
+			t.Errorf("Generated file [%s] shouldn't have a go:generate line since it can't regenerate itself:\n\n%s", generated, string(output))
+		}
+	}
+	if _, err := os.Stat(filepath.Join(templatesDir, "secrets.go")); !os.IsNotExist(err) {
+		t.Errorf("Nothing should be generated under the default name with an output pattern")
+	}
+	if generated := opts.generatedFileOf(filepath.Join(templatesDir, "db", "secrets.go"+templateExt)); generated != filepath.Join(templatesDir, "db", "secrets_gen.go") {
+		t.Errorf("Expected the generated file of the template to follow the pattern but got [%s]", generated)
+	}
+
+	opts.check = true
+	if err := run(opts); err != nil {
+		t.Errorf("Expected the files generated with the pattern to be up to date but got [%v]", err)
+	}
+
+	opts.check = false
+	opts.output = filepath.Join(tempDir, "secrets.go")
+	opts.paths = []string{filepath.Join(templatesDir, "secrets.go")}
+	if err := run(opts); err == nil || !strings.Contains(err.Error(), "--output-pattern") {
+		t.Errorf("Expected --output to be rejected with --output-pattern but got [%v]", err)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import "sync"
+
+// forEach calls fn with every index from 0 to n concurrently and returns the errors, err[i] being the one of
+// fn(i). Bounding the concurrency is up to fn.
+func forEach(n int, fn func(i int) error) []error {
+	errs := make([]error, n)
+
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = fn(i)
+		}(i)
+	}
+	wg.Wait()
+
+	return errs
+}
+
+// semaphore bounds the number of concurrent jobs. A nil semaphore doesn't bound anything.
+type semaphore chan struct{}
+
+func newSemaphore(jobs int) semaphore {
+	return make(semaphore, jobs)
+}
+
+// acquire waits for a job slot
+func (s semaphore) acquire() {
+	if s != nil {
+		s <- struct{}{}
+	}
+}
+
+// release frees the job slot taken by acquire
+func (s semaphore) release() {
+	if s != nil {
+		<-s
+	}
+}
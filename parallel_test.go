@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestForEachBoundedBySemaphore(t *testing.T) {
+	jobs := newSemaphore(2)
+
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	errs := forEach(10, func(i int) error {
+		jobs.acquire()
+		defer jobs.release()
+
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+
+		defer func() {
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}()
+
+		if i == 3 {
+			return errors.New("failed")
+		}
+		return nil
+	})
+
+	if maxRunning > 2 {
+		t.Errorf("Expected at most 2 concurrent jobs but got %d", maxRunning)
+	}
+	for i, err := range errs {
+		if (err != nil) != (i == 3) {
+			t.Errorf("Unexpected error [%v] for job %d", err, i)
+		}
+	}
+}
+
+func TestConcurrentGeneration(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	var dirs []string
+	for i := 0; i < 20; i++ {
+		dir := filepath.Join(tempDir, fmt.Sprintf("pkg%d", i))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := writeTestTemplate(dir); err != nil {
+			t.Fatal(err)
+		}
+		dirs = append(dirs, dir)
+	}
+
+	os.Setenv("CLIENT_ID", "safeid")
+	os.Setenv("CLIENT_SECRET", "safesecret")
+	defer os.Unsetenv("CLIENT_ID")
+	defer os.Unsetenv("CLIENT_SECRET")
+
+	if err := run(options{paths: []string{tempDir}, jobs: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dir := range dirs {
+		output, err := ioutil.ReadFile(filepath.Join(dir, "secrets.go"))
+		if err != nil {
+			t.Fatalf("Expected generated file in [%s] but got [%s]", dir, err)
+		}
+		if !strings.Contains(string(output), "appSecrets.ClientSecret = \"safesecret\"") {
+			t.Errorf("Generated file in [%s] wasn't fully replaced: \n\n%s", dir, string(output))
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// caseInsensitivePaths is true on the platforms whose filesystems compare file names case-insensitively by
+// default (NTFS on Windows, APFS on macOS), where Secrets.go and secrets.go are the same file
+var caseInsensitivePaths = runtime.GOOS == "windows" || runtime.GOOS == "darwin"
+
+// pathKey returns path as a key of the maps of paths: cleaned, with forward slashes and, on case-insensitive
+// filesystems, in lower case, so that C:\Config\secrets.go and c:/config/Secrets.go have the same key
+func pathKey(path string) string {
+	key := filepath.ToSlash(filepath.Clean(path))
+	if caseInsensitivePaths {
+		return strings.ToLower(key)
+	}
+	return key
+}
+
+// equalPaths reports whether a and b name the same file, without resolving links
+func equalPaths(a string, b string) bool {
+	return pathKey(a) == pathKey(b)
+}
+
+// hasPathSuffix reports whether path ends with suffix, regardless of case on case-insensitive filesystems
+func hasPathSuffix(path string, suffix string) bool {
+	if caseInsensitivePaths {
+		return len(path) >= len(suffix) && strings.EqualFold(path[len(path)-len(suffix):], suffix)
+	}
+	return strings.HasSuffix(path, suffix)
+}
+
+// trimPathSuffix returns path without suffix, as matched by hasPathSuffix
+func trimPathSuffix(path string, suffix string) string {
+	if hasPathSuffix(path, suffix) {
+		return path[:len(path)-len(suffix)]
+	}
+	return path
+}
+
+// matchName reports whether name matches the filepath.Match pattern, regardless of case on case-insensitive
+// filesystems
+func matchName(pattern string, name string) (bool, error) {
+	if caseInsensitivePaths {
+		return filepath.Match(strings.ToLower(pattern), strings.ToLower(name))
+	}
+	return filepath.Match(pattern, name)
+}
+
+// volumeRoot returns the root of the volume of segment, the first segment of a slash-separated path, if it's a
+// volume name such as C: (a bare C: being the current directory of the drive rather than its root), or the empty
+// string
+func volumeRoot(segment string) string {
+	if volume := filepath.VolumeName(segment); volume != "" && volume == segment {
+		return volume + string(filepath.Separator)
+	}
+	return ""
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestCaseInsensitivePaths(t *testing.T) {
+	defer func(caseInsensitive bool) { caseInsensitivePaths = caseInsensitive }(caseInsensitivePaths)
+
+	caseInsensitivePaths = false
+	if equalPaths("config/Secrets.go", "config/secrets.go") {
+		t.Error("Expected paths differing in case to be different files on case-sensitive filesystems")
+	}
+	if templateExtension("").isTemplate("secrets.go.SAFEKEEPER") {
+		t.Error("Expected the template extension to be case-sensitive on case-sensitive filesystems")
+	}
+
+	caseInsensitivePaths = true
+	if !equalPaths("config/Secrets.go", "./config/secrets.go") {
+		t.Error("Expected paths differing in case to be the same file on case-insensitive filesystems")
+	}
+	ext := templateExtension("")
+	if !ext.isTemplate("secrets.go.SafeKeeper") || ext.outputOf("secrets.go.SafeKeeper") != "secrets.go" {
+		t.Errorf("Expected the template extension to be case-insensitive but got output [%s]", ext.outputOf("secrets.go.SafeKeeper"))
+	}
+	if matched, err := matchSegments([]string{"Internal", "**", "*.GO"}, []string{"internal", "config", "secrets.go"}); err != nil || !matched {
+		t.Errorf("Expected patterns to match regardless of case but got [%t, %v]", matched, err)
+	}
+}
+
+func TestBaseDirOfVolume(t *testing.T) {
+	if filepath.VolumeName("C:") == "" {
+		t.Skip("Volume names are only parsed on Windows")
+	}
+	if dir := baseDir([]string{"C:", "**", "*.go"}); dir != `C:\` {
+		t.Errorf("Expected the walk to start at the root of the drive but got [%s]", dir)
+	}
+	if dir := baseDir([]string{"C:", "src", "**", "*.go"}); dir != `C:\src` {
+		t.Errorf("Expected the walk to start in the literal directory but got [%s]", dir)
+	}
+}
@@ -0,0 +1,67 @@
+package safekeeper
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+)
+
+// span is the [start, end) byte range of a string literal in a source
+type span struct {
+	start int
+	end   int
+	// line is the line of the start of the literal
+	line int
+}
+
+// stringLiterals parses source as a Go file and returns the spans of its string literals, in order, leaving out
+// import paths
+func stringLiterals(source []byte) ([]span, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", source, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid Go template: %s", err)
+	}
+
+	var spans []span
+	ast.Inspect(file, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.ImportSpec:
+			return false
+		case *ast.BasicLit:
+			if n.Kind == token.STRING {
+				position := fset.Position(n.Pos())
+				spans = append(spans, span{start: position.Offset, end: position.Offset + len(n.Value), line: position.Line})
+			}
+		}
+		return true
+	})
+
+	return spans, nil
+}
+
+// substituteLiterals returns source with the placeholders of its string literals replaced with replace, the rest
+// of the source (identifiers, comments, import paths) being left untouched
+func substituteLiterals(source []byte, replace func(literal string) (string, error)) ([]byte, error) {
+	spans, err := stringLiterals(source)
+	if err != nil {
+		return nil, err
+	}
+
+	var buffer bytes.Buffer
+	last := 0
+	for _, s := range spans {
+		buffer.Write(source[last:s.start])
+		replaced, err := replace(string(source[s.start:s.end]))
+		if err != nil {
+			return nil, err
+		}
+		buffer.WriteString(replaced)
+		last = s.end
+	}
+	buffer.Write(source[last:])
+
+	return buffer.Bytes(), nil
+}
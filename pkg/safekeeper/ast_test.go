@@ -0,0 +1,50 @@
+package safekeeper
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const astTemplate = `package secrets
+
+import "example.com/ENV_TOKEN/client"
+
+// ENV_TOKEN is read from the environment
+var ENV_TOKEN = client.New("ENV_TOKEN", ` + "`ENV_URL:-http://localhost`" + `)
+`
+
+func TestGenerateASTMode(t *testing.T) {
+	generator := Generator{Mode: ASTMode}
+	var output bytes.Buffer
+	err := generator.Generate(strings.NewReader(astTemplate), &output, map[string]string{"TOKEN": "s3cr3t"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := strings.Replace(strings.Replace(astTemplate, `"ENV_TOKEN"`, `"s3cr3t"`, 1), "`ENV_URL:-http://localhost`", "`http://localhost`", 1)
+	if output.String() != expected {
+		t.Errorf("Expected only string literals to be substituted [%s] but was [%s]", expected, output.String())
+	}
+}
+
+func TestFindPlaceholdersASTMode(t *testing.T) {
+	generator := Generator{Mode: ASTMode}
+	keys, err := generator.FindKeys(strings.NewReader(astTemplate))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(keys, ",") != "TOKEN,URL" {
+		t.Errorf("Expected keys [TOKEN URL] from string literals but got %v", keys)
+	}
+}
+
+func TestGenerateASTModeInvalidSource(t *testing.T) {
+	generator := Generator{Mode: ASTMode}
+	var output bytes.Buffer
+	err := generator.Generate(strings.NewReader("not go"), &output, nil)
+	if err == nil || !strings.Contains(err.Error(), "Invalid Go template") {
+		t.Errorf("Expected an invalid template error but got [%v]", err)
+	}
+}
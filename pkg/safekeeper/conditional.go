@@ -0,0 +1,132 @@
+package safekeeper
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+)
+
+// directivePattern matches the lines of conditional block directives, commented in the syntax of the template
+// (e.g. //safekeeper:if KEY or #safekeeper:if !KEY), the directive, negation and key being the submatches
+var directivePattern = regexp.MustCompile(`^\s*(?:\S*\s*)?safekeeper:(if|else|endif)\b(?:\s+(!)?([A-Za-z0-9_]+))?`)
+
+// block is an open conditional block
+type block struct {
+	line int
+	// kept reports whether the lines of the current branch are kept, enclosing blocks included
+	kept bool
+	// enclosing reports whether the lines of the enclosing blocks are kept
+	enclosing bool
+}
+
+// selectBlocks returns source with the lines of its conditional blocks kept only when their condition holds,
+// safekeeper:if KEY holding when KEY has a non-empty value and safekeeper:if !KEY when it hasn't. Blocks can
+// have a safekeeper:else branch and be nested, and the directive lines (and the ones of file settings) are left out. origins are the numbers of
+// the lines of source that are kept, in order.
+func selectBlocks(source []byte, values map[string]string) (selected []byte, origins []int, err error) {
+	blocks := newBlockReader(bytes.NewReader(source), values)
+	if selected, err = ioutil.ReadAll(blocks); err != nil {
+		return nil, nil, err
+	}
+	return selected, blocks.origins, nil
+}
+
+// blockReader reads the lines of a source kept by its conditional blocks, like selectBlocks, one line at a time.
+// The numbers of the lines read so far are recorded in origins.
+type blockReader struct {
+	lines      *lineReader
+	values     map[string]string
+	blocks     []block
+	kept       bool
+	lineNumber int
+	origins    []int
+	pending    string
+	err        error
+}
+
+// newBlockReader returns the blockReader of the source read from src
+func newBlockReader(src io.Reader, values map[string]string) *blockReader {
+	return &blockReader{lines: newLineReader(src), values: values, kept: true}
+}
+
+func (r *blockReader) Read(p []byte) (int, error) {
+	for r.pending == "" {
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.err = r.next()
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// next reads the next line of the source, pending if it's kept, failing with io.EOF at the end of the source
+func (r *blockReader) next() error {
+	if !r.lines.Scan() {
+		if err := r.lines.Err(); err != nil {
+			return err
+		}
+		if len(r.blocks) > 0 {
+			return fmt.Errorf("safekeeper:if at line %d has no safekeeper:endif", r.blocks[len(r.blocks)-1].line)
+		}
+		return io.EOF
+	}
+	r.lineNumber++
+	line := r.lines.Text()
+	if isSettingLine(line) {
+		return nil
+	}
+	match := directivePattern.FindStringSubmatch(line)
+	if match == nil {
+		if r.kept {
+			r.pending = line
+			r.origins = append(r.origins, r.lineNumber)
+		}
+		return nil
+	}
+
+	switch match[1] {
+	case "if":
+		if match[3] == "" {
+			return fmt.Errorf("safekeeper:if without a key at line %d", r.lineNumber)
+		}
+		holds := (r.values[match[3]] != "") != (match[2] == "!")
+		r.blocks = append(r.blocks, block{line: r.lineNumber, kept: r.kept && holds, enclosing: r.kept})
+	case "else":
+		if len(r.blocks) == 0 {
+			return fmt.Errorf("safekeeper:else without safekeeper:if at line %d", r.lineNumber)
+		}
+		current := &r.blocks[len(r.blocks)-1]
+		current.kept = current.enclosing && !current.kept
+	case "endif":
+		if len(r.blocks) == 0 {
+			return fmt.Errorf("safekeeper:endif without safekeeper:if at line %d", r.lineNumber)
+		}
+		r.blocks = r.blocks[:len(r.blocks)-1]
+	}
+	r.kept = len(r.blocks) == 0 || r.blocks[len(r.blocks)-1].kept
+	return nil
+}
+
+// conditionPlaceholders returns the placeholders of the keys of the conditions of source, with an empty default
+// since a condition doesn't hold when its key has no value
+func conditionPlaceholders(source []byte) []Placeholder {
+	var placeholders []Placeholder
+	scanner := newLineReader(bytes.NewReader(source))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		if match := directivePattern.FindStringSubmatch(scanner.Text()); match != nil && match[3] != "" && match[1] == "if" {
+			placeholders = append(placeholders, Placeholder{Key: match[3], HasDefault: true, Line: lineNumber, Condition: true})
+		}
+	}
+	return placeholders
+}
+
+// byLine returns placeholders sorted by line, in order of appearance within a line
+func byLine(placeholders []Placeholder) []Placeholder {
+	sort.SliceStable(placeholders, func(i, j int) bool { return placeholders[i].Line < placeholders[j].Line })
+	return placeholders
+}
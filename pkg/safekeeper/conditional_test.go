@@ -0,0 +1,59 @@
+package safekeeper
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGenerateConditionalBlocks(t *testing.T) {
+	template := "func init() {\n\t//safekeeper:if ANALYTICS_TOKEN\n\tanalytics.Start(\"ENV_ANALYTICS_TOKEN\")\n\t// safekeeper:if DEBUG\n\tanalytics.Debug()\n\t// safekeeper:endif\n\t//safekeeper:else\n\tlog.Print(\"No analytics\")\n\t//safekeeper:endif\n\t//safekeeper:if !SENTRY_DSN\n\tlog.Print(\"No sentry\")\n\t//safekeeper:endif\n}\n"
+
+	var generator Generator
+	var output bytes.Buffer
+	if err := generator.Generate(strings.NewReader(template), &output, map[string]string{"ANALYTICS_TOKEN": "tk", "SENTRY_DSN": ""}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "func init() {\n\tanalytics.Start(\"tk\")\n\tlog.Print(\"No sentry\")\n}\n"
+	if output.String() != expected {
+		t.Errorf("Expected generated source to be [%s] but was [%s]", expected, output.String())
+	}
+
+	output.Reset()
+	if err := generator.Generate(strings.NewReader(template), &output, map[string]string{"SENTRY_DSN": "dsn"}); err != nil {
+		t.Fatal(err)
+	}
+	expected = "func init() {\n\tlog.Print(\"No analytics\")\n}\n"
+	if output.String() != expected {
+		t.Errorf("Expected generated source to be [%s] but was [%s]", expected, output.String())
+	}
+}
+
+func TestUnbalancedConditionalBlocks(t *testing.T) {
+	var generator Generator
+	for template, expected := range map[string]string{
+		"# safekeeper:if KEY\nkey: ENV_KEY\n":   "safekeeper:if at line 1 has no safekeeper:endif",
+		"key: ENV_KEY\n# safekeeper:endif\n":    "safekeeper:endif without safekeeper:if at line 2",
+		"# safekeeper:if\n# safekeeper:endif\n": "safekeeper:if without a key at line 1",
+	} {
+		err := generator.Generate(strings.NewReader(template), &bytes.Buffer{}, map[string]string{"KEY": "value"})
+		if err == nil || err.Error() != expected {
+			t.Errorf("Expected [%s] to fail with [%s] but got [%v]", template, expected, err)
+		}
+	}
+}
+
+func TestFindConditionPlaceholders(t *testing.T) {
+	generator := Generator{Mode: ASTMode}
+	placeholders, err := generator.FindPlaceholders(strings.NewReader("package config\n\n//safekeeper:if TOKEN\nconst token = \"ENV_TOKEN\"\n//safekeeper:endif\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Placeholder{{Key: "TOKEN", HasDefault: true, Line: 3, Condition: true}, {Key: "TOKEN", Line: 4}}
+	if !reflect.DeepEqual(placeholders, expected) {
+		t.Errorf("Expected placeholders to be %v but were %v", expected, placeholders)
+	}
+}
@@ -0,0 +1,83 @@
+package safekeeper
+
+import (
+	"context"
+	"io"
+)
+
+// ContextSecretSource is a SecretSource whose resolutions can be cancelled, e.g. sources calling a network backend
+type ContextSecretSource interface {
+	SecretSource
+	// ResolveContext is Resolve, giving up with the error of ctx once it's done
+	ResolveContext(ctx context.Context, key string) (value string, found bool, err error)
+}
+
+// ResolveContext resolves key with source, giving up with the error of ctx once it's done. Sources that aren't
+// ContextSecretSources are left to finish in the background, their result being dropped.
+func ResolveContext(ctx context.Context, source SecretSource, key string) (string, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return "", false, err
+	}
+	if s, ok := source.(ContextSecretSource); ok {
+		return s.ResolveContext(ctx, key)
+	}
+	if ctx.Done() == nil {
+		return source.Resolve(key)
+	}
+
+	type result struct {
+		value string
+		found bool
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		value, found, err := source.Resolve(key)
+		done <- result{value, found, err}
+	}()
+	select {
+	case r := <-done:
+		return r.value, r.found, r.err
+	case <-ctx.Done():
+		return "", false, ctx.Err()
+	}
+}
+
+func (c chain) ResolveContext(ctx context.Context, key string) (string, bool, error) {
+	for _, source := range c {
+		value, found, err := ResolveContext(ctx, source, key)
+		if err != nil || found {
+			return value, found, err
+		}
+	}
+
+	return "", false, nil
+}
+
+func (s MapSource) ResolveContext(ctx context.Context, key string) (string, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return "", false, err
+	}
+	return s.Resolve(key)
+}
+
+// contextReader is a reader failing with the error of its context once it's done
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// GenerateContext is Generate, failing with the error of ctx once it's done
+func (g *Generator) GenerateContext(ctx context.Context, src io.Reader, dst io.Writer, values map[string]string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return g.Generate(contextReader{ctx: ctx, r: src}, dst, values)
+}
@@ -0,0 +1,41 @@
+package safekeeper
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// hungSource is a SecretSource that never answers
+type hungSource struct{}
+
+func (hungSource) Resolve(key string) (string, bool, error) {
+	select {}
+}
+
+func TestResolveContextGivesUp(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, _, err := ResolveContext(ctx, Chain(MapSource{"OTHER": "value"}, hungSource{}), "KEY")
+	if err != context.DeadlineExceeded {
+		t.Errorf("Expected the resolution to time out but got [%v]", err)
+	}
+
+	if value, found, err := ResolveContext(context.Background(), Chain(MapSource{"KEY": "value"}, hungSource{}), "KEY"); err != nil || !found || value != "value" {
+		t.Errorf("Expected the first source to resolve the key but got [%s] (found: %t, err: %v)", value, found, err)
+	}
+}
+
+func TestGenerateContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var generator Generator
+	err := generator.GenerateContext(ctx, strings.NewReader("const id = \"ENV_ID\"\n"), &bytes.Buffer{}, map[string]string{"ID": "id"})
+	if err != context.Canceled {
+		t.Errorf("Expected the cancelled generation to fail but got [%v]", err)
+	}
+}
@@ -0,0 +1,251 @@
+package safekeeper
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// Engine selects how templates are substituted
+type Engine int
+
+const (
+	// PlaceholderEngine replaces the placeholders of templates (ENV_<KEY> by default)
+	PlaceholderEngine Engine = iota
+	// GoTemplateEngine executes templates with text/template, keys being the fields of the data ({{ .KEY }}) and
+	// TemplateFuncs its function library. Templates are executed whole, so none of the settings of the
+	// placeholder engine apply.
+	GoTemplateEngine
+)
+
+// String returns the name of e, as given to the --engine flag
+func (e Engine) String() string {
+	if e == GoTemplateEngine {
+		return "gotemplate"
+	}
+	return "placeholder"
+}
+
+// TemplateFuncs are the functions of the templates of GoTemplateEngine, named after their sprig equivalent and
+// taking their arguments in the same order so that values can be piped into them ({{ .KEY | trimPrefix "v" }})
+var TemplateFuncs = template.FuncMap{
+	"upper":      strings.ToUpper,
+	"lower":      strings.ToLower,
+	"title":      strings.Title,
+	"trim":       strings.TrimSpace,
+	"trimPrefix": func(prefix string, s string) string { return strings.TrimPrefix(s, prefix) },
+	"trimSuffix": func(suffix string, s string) string { return strings.TrimSuffix(s, suffix) },
+	"replace":    func(old string, new string, s string) string { return strings.Replace(s, old, new, -1) },
+	"contains":   func(substr string, s string) bool { return strings.Contains(s, substr) },
+	"hasPrefix":  func(prefix string, s string) bool { return strings.HasPrefix(s, prefix) },
+	"hasSuffix":  func(suffix string, s string) bool { return strings.HasSuffix(s, suffix) },
+	"quote":      strconv.Quote,
+	"squote":     func(s string) string { return "'" + s + "'" },
+	"b64enc":     func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) },
+	"b64dec": func(s string) (string, error) {
+		decoded, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return "", fmt.Errorf("Value isn't valid base64")
+		}
+		return string(decoded), nil
+	},
+	"sha256sum": func(s string) string {
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:])
+	},
+	"default": func(fallback string, value string) string {
+		if value == "" {
+			return fallback
+		}
+		return value
+	},
+	"required": func(message string, value string) (string, error) {
+		if value == "" {
+			return "", fmt.Errorf("%s", message)
+		}
+		return value, nil
+	},
+	"indent": indent,
+	"nindent": func(spaces int, s string) string {
+		return "\n" + indent(spaces, s)
+	},
+	// transform applies the transforms of placeholders, registered ones included ({{ .CERT | transform "hex" }}),
+	// and the ones of the Generator.TransformFuncs of the generator executing the template
+	"transform": (&Generator{}).templateTransform,
+}
+
+// templateTransform is the transform function of the templates executed by g
+func (g *Generator) templateTransform(name string, value string) (string, error) {
+	transform, ok := g.transformFunc(name)
+	if !ok {
+		return "", fmt.Errorf("Unknown transform [%s], expected one of %s", name, strings.Join(g.transformNames(), ", "))
+	}
+	transformed, err := transform(value)
+	if err != nil {
+		return "", fmt.Errorf("Value can't go through transform [%s]", name)
+	}
+	return transformed, nil
+}
+
+// indent indents every line of s with spaces
+func indent(spaces int, s string) string {
+	padding := strings.Repeat(" ", spaces)
+	return padding + strings.Replace(s, "\n", "\n"+padding, -1)
+}
+
+// checkEngine fails if g has settings that don't apply to its engine
+func (g *Generator) checkEngine() error {
+	if g.Engine != GoTemplateEngine {
+		return nil
+	}
+	for setting, set := range map[string]bool{"Prefix": g.Prefix != "", "Suffix": g.Suffix != "", "ASTMode": g.Mode == ASTMode, "Obfuscation": g.Obfuscation != NoObfuscation, "Split": g.Split > 1, "EnvFallback": g.EnvFallback, "Escape": g.Escape, "Transforms": len(g.Transforms) > 0, "Types": len(g.Types) > 0, "LineDirectives": g.LineDirectives != ""} {
+		if set {
+			return fmt.Errorf("%s doesn't apply to the %s engine", setting, GoTemplateEngine)
+		}
+	}
+	return nil
+}
+
+// parseTemplate parses the template read from src, its go:generate safekeeper lines and file settings left out or,
+// to keep the lines of the positions of the template, blanked
+func parseTemplate(src io.Reader, blank bool) (*template.Template, string, error) {
+	source, err := ioutil.ReadAll(src)
+	if err != nil {
+		return nil, "", err
+	}
+	lines := strings.SplitAfter(string(source), "\n")
+	for i, line := range lines {
+		if skipped := IsGenerateLine(line) || isSettingLine(line); skipped && blank {
+			lines[i] = line[len(strings.TrimRight(line, "\r\n")):]
+		} else if skipped {
+			lines[i] = ""
+		}
+	}
+	text := strings.Join(lines, "")
+
+	parsed, err := template.New("template").Funcs(TemplateFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, "", fmt.Errorf("Invalid template: %s", err)
+	}
+	return parsed, text, nil
+}
+
+// executeTemplate executes the template read from src with values, writing the result to dst. Keys without a
+// value are empty, see the default and required functions.
+func (g *Generator) executeTemplate(src io.Reader, dst io.Writer, values map[string]string) error {
+	parsed, _, err := parseTemplate(src, false)
+	if err != nil {
+		return err
+	}
+	parsed.Funcs(template.FuncMap{"transform": g.templateTransform})
+	if values == nil {
+		values = map[string]string{}
+	}
+	return parsed.Execute(dst, values)
+}
+
+// templatePlaceholders returns the fields of the data of the template read from src, in order of appearance.
+// Fields piped into default have its fallback as default.
+func templatePlaceholders(src io.Reader) ([]Placeholder, error) {
+	parsed, text, err := parseTemplate(src, true)
+	if err != nil {
+		return nil, err
+	}
+
+	type positioned struct {
+		Placeholder
+		pos parse.Pos
+	}
+	var found []positioned
+	add := func(pos parse.Pos, key string, fallback *parse.StringNode) {
+		placeholder := Placeholder{Key: key, Line: 1 + strings.Count(text[:pos], "\n")}
+		if fallback != nil {
+			placeholder.Default, placeholder.HasDefault = fallback.Text, true
+		}
+		found = append(found, positioned{placeholder, pos})
+	}
+
+	var walk func(node parse.Node, fallback *parse.StringNode)
+	walkBranch := func(branch *parse.BranchNode) {
+		walk(branch.Pipe, nil)
+		walk(branch.List, nil)
+		walk(branch.ElseList, nil)
+	}
+	walk = func(node parse.Node, fallback *parse.StringNode) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				walk(child, nil)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe, nil)
+		case *parse.IfNode:
+			walkBranch(&n.BranchNode)
+		case *parse.RangeNode:
+			walkBranch(&n.BranchNode)
+		case *parse.WithNode:
+			walkBranch(&n.BranchNode)
+		case *parse.TemplateNode:
+			walk(n.Pipe, nil)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, command := range n.Cmds {
+				if fallback == nil {
+					fallback = defaultFallback(command)
+				}
+			}
+			for _, command := range n.Cmds {
+				walk(command, fallback)
+			}
+		case *parse.CommandNode:
+			for _, arg := range n.Args {
+				walk(arg, fallback)
+			}
+		case *parse.ChainNode:
+			walk(n.Node, fallback)
+		case *parse.FieldNode:
+			add(n.Pos, n.Ident[0], fallback)
+		case *parse.VariableNode:
+			if len(n.Ident) > 1 && n.Ident[0] == "$" {
+				add(n.Pos, n.Ident[1], fallback)
+			}
+		}
+	}
+	for _, defined := range parsed.Templates() {
+		if defined.Tree != nil {
+			walk(defined.Tree.Root, nil)
+		}
+	}
+
+	sort.SliceStable(found, func(i, j int) bool { return found[i].pos < found[j].pos })
+	placeholders := make([]Placeholder, len(found))
+	for i, f := range found {
+		placeholders[i] = f.Placeholder
+	}
+	return placeholders, nil
+}
+
+// defaultFallback returns the fallback of command if it calls default with a string literal, nil otherwise
+func defaultFallback(command *parse.CommandNode) *parse.StringNode {
+	if len(command.Args) < 2 {
+		return nil
+	}
+	if identifier, ok := command.Args[0].(*parse.IdentifierNode); !ok || identifier.Ident != "default" {
+		return nil
+	}
+	fallback, _ := command.Args[1].(*parse.StringNode)
+	return fallback
+}
@@ -0,0 +1,59 @@
+package safekeeper
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGenerateWithGoTemplateEngine(t *testing.T) {
+	template := "//go:generate safekeeper --keys=STRIPE_KEY --engine=gotemplate $GOFILE\nkey: {{ .STRIPE_KEY | upper }}\nencoded: {{ b64enc .STRIPE_KEY }}\nhex: {{ .STRIPE_KEY | transform \"hex\" }}\nregion: {{ .REGION | default \"us-east-1\" }}\n{{- if .DEBUG }}\ndebug: true\n{{- end }}\n"
+
+	generator := Generator{Engine: GoTemplateEngine}
+	var output bytes.Buffer
+	if err := generator.Generate(strings.NewReader(template), &output, map[string]string{"STRIPE_KEY": "sk_test"}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "key: SK_TEST\nencoded: c2tfdGVzdA==\nhex: 736b5f74657374\nregion: us-east-1\n"
+	if output.String() != expected {
+		t.Errorf("Expected generated source to be [%s] but was [%s]", expected, output.String())
+	}
+}
+
+func TestFindPlaceholdersWithGoTemplateEngine(t *testing.T) {
+	generator := Generator{Engine: GoTemplateEngine}
+	placeholders, err := generator.FindPlaceholders(strings.NewReader("//go:generate safekeeper --engine=gotemplate $GOFILE\nregion: {{ .REGION | default \"us-east-1\" }}\n{{ with .TOKEN }}token: {{ $.USER }}:{{ . }}{{ end }}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Placeholder{
+		{Key: "REGION", Default: "us-east-1", HasDefault: true, Line: 2},
+		{Key: "TOKEN", Line: 3},
+		{Key: "USER", Line: 3},
+	}
+	if !reflect.DeepEqual(placeholders, expected) {
+		t.Errorf("Expected placeholders to be %v but were %v", expected, placeholders)
+	}
+}
+
+func TestGoTemplateEngineRejectsPlaceholderSettings(t *testing.T) {
+	generator := Generator{Engine: GoTemplateEngine, Mode: ASTMode}
+	err := generator.Generate(strings.NewReader(""), &bytes.Buffer{}, nil)
+	if err == nil || err.Error() != "ASTMode doesn't apply to the gotemplate engine" {
+		t.Errorf("Expected ASTMode to be rejected but got [%v]", err)
+	}
+}
+
+func TestRevertWithGoTemplateEngine(t *testing.T) {
+	generator := Generator{Engine: GoTemplateEngine}
+	var template bytes.Buffer
+	if err := generator.Revert(strings.NewReader("key: sk_test\n"), &template, map[string]string{"STRIPE_KEY": "sk_test"}); err != nil {
+		t.Fatal(err)
+	}
+	if template.String() != "key: {{ .STRIPE_KEY }}\n" {
+		t.Errorf("Expected the value to be reverted to a template field but got [%s]", template.String())
+	}
+}
@@ -0,0 +1,27 @@
+package safekeeper
+
+import "os"
+
+// EnvSource is a SecretSource resolving keys as environment variables with Lookup, os.LookupEnv if nil, so that
+// a fake environment can be given instead of the process one. Empty variables count as unset.
+type EnvSource struct {
+	Lookup func(key string) (string, bool)
+}
+
+func (s EnvSource) Resolve(key string) (string, bool, error) {
+	lookup := s.Lookup
+	if lookup == nil {
+		lookup = os.LookupEnv
+	}
+	value, _ := lookup(key)
+	return value, value != "", nil
+}
+
+// LookupMap returns a function looking up the variables of env like os.LookupEnv, e.g. for the Lookup of an
+// EnvSource in tests
+func LookupMap(env map[string]string) func(key string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+}
@@ -0,0 +1,25 @@
+package safekeeper
+
+import (
+	"os"
+	"testing"
+)
+
+func TestEnvSource(t *testing.T) {
+	source := EnvSource{Lookup: LookupMap(map[string]string{"CLIENT_ID": "id", "EMPTY": ""})}
+	if value, found, err := source.Resolve("CLIENT_ID"); err != nil || !found || value != "id" {
+		t.Errorf("Expected the value of the fake environment but got [%s] (found: %t, err: %v)", value, found, err)
+	}
+	if _, found, _ := source.Resolve("EMPTY"); found {
+		t.Error("Expected an empty variable to count as unset")
+	}
+
+	os.Setenv("SAFEKEEPER_TEST_ENV", "value")
+	defer os.Unsetenv("SAFEKEEPER_TEST_ENV")
+	if _, found, _ := source.Resolve("SAFEKEEPER_TEST_ENV"); found {
+		t.Error("Expected the process environment to be left out of a fake one")
+	}
+	if value, found, _ := (EnvSource{}).Resolve("SAFEKEEPER_TEST_ENV"); !found || value != "value" {
+		t.Errorf("Expected the process environment by default but got [%s]", value)
+	}
+}
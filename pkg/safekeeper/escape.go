@@ -0,0 +1,104 @@
+package safekeeper
+
+import (
+	"strconv"
+	"strings"
+)
+
+// literalKind is the kind of Go literal a position of a source is in
+type literalKind int
+
+const (
+	// noLiteral is code or comments, outside of any string literal
+	noLiteral literalKind = iota
+	// interpretedLiteral is a "..." string literal
+	interpretedLiteral
+	// rawLiteral is a `...` string literal, which can span several lines
+	rawLiteral
+	// runeLiteral is a '.' rune literal
+	runeLiteral
+)
+
+// literalKindOf returns the kind of the string literal as written in a source, quotes included
+func literalKindOf(literal string) literalKind {
+	if strings.HasPrefix(literal, "`") {
+		return rawLiteral
+	}
+	return interpretedLiteral
+}
+
+// escapeValue returns value escaped to be written in a literal of the given kind. Since backticks can't be
+// escaped in raw strings, they're written as "`" between two raw strings.
+func escapeValue(kind literalKind, value string) string {
+	switch kind {
+	case interpretedLiteral:
+		quoted := strconv.Quote(value)
+		return quoted[1 : len(quoted)-1]
+	case rawLiteral:
+		return strings.Replace(value, "`", "` + \"`\" + `", -1)
+	default:
+		return value
+	}
+}
+
+// literalScanner tracks the literals of a Go source read line by line, raw strings and comments being able to
+// span lines
+type literalScanner struct {
+	raw          bool
+	blockComment bool
+}
+
+// scan returns the kind of literal each byte offset of line (and the end of the line) is in, the kind of an
+// opening quote being the one of the literal it starts
+func (s *literalScanner) scan(line string) []literalKind {
+	kinds := make([]literalKind, len(line)+1)
+	kind := noLiteral
+	if s.raw {
+		kind = rawLiteral
+	}
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case s.blockComment:
+			if strings.HasPrefix(line[i:], "*/") {
+				s.blockComment = false
+				kinds[i] = noLiteral
+				i++
+			}
+		case kind == rawLiteral:
+			kinds[i] = kind
+			if c == '`' {
+				kind = noLiteral
+			}
+			continue
+		case kind == interpretedLiteral || kind == runeLiteral:
+			kinds[i] = kind
+			if c == '\\' && i+1 < len(line) {
+				kinds[i+1] = kind
+				i++
+			} else if (c == '"' && kind == interpretedLiteral) || (c == '\'' && kind == runeLiteral) {
+				kind = noLiteral
+			}
+			continue
+		case strings.HasPrefix(line[i:], "//"):
+			// The rest of the line is a comment
+			s.raw = false
+			return kinds
+		case strings.HasPrefix(line[i:], "/*"):
+			s.blockComment = true
+			i++
+		case c == '"':
+			kind = interpretedLiteral
+		case c == '`':
+			kind = rawLiteral
+		case c == '\'':
+			kind = runeLiteral
+		}
+		kinds[i] = kind
+	}
+
+	kinds[len(line)] = kind
+	s.raw = kind == rawLiteral
+	return kinds
+}
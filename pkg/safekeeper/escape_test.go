@@ -0,0 +1,48 @@
+package safekeeper
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestGenerateEscapesValues(t *testing.T) {
+	template := "package secrets\n\nconst (\n\tpassword = \"ENV_PASSWORD\"\n\tpem = `\n-----BEGIN-----\nENV_PASSWORD\n`\n\tquoted = '\"'\n\tother = \"ENV_PASSWORD\"\n)\n"
+	value := "p\"a\\ss`\nword"
+
+	for _, mode := range []Mode{TextMode, ASTMode} {
+		generator := Generator{Mode: mode, Escape: true}
+		var output bytes.Buffer
+		if err := generator.Generate(strings.NewReader(template), &output, map[string]string{"PASSWORD": value}); err != nil {
+			t.Fatal(err)
+		}
+
+		generated := output.String()
+		if _, err := parser.ParseFile(token.NewFileSet(), "", generated, 0); err != nil {
+			t.Fatalf("Generated source in %s mode should be valid Go but got [%s]:\n\n%s", mode, err, generated)
+		}
+		for _, expected := range []string{"password = \"p\\\"a\\\\ss`\\nword\"", "p\"a\\ss` + \"`\" + `\nword", "other = \"p\\\"a\\\\ss`\\nword\""} {
+			if !strings.Contains(generated, expected) {
+				t.Errorf("Generated source in %s mode should contain [%s] but was:\n\n%s", mode, expected, generated)
+			}
+		}
+	}
+}
+
+func TestLiteralScanner(t *testing.T) {
+	var scanner literalScanner
+	line := "a := \"x\\\"y\" + `z"
+	kinds := scanner.scan(line)
+
+	expected := map[int]literalKind{0: noLiteral, 6: interpretedLiteral, 8: interpretedLiteral, 12: noLiteral, 15: rawLiteral}
+	for offset, kind := range expected {
+		if kinds[offset] != kind {
+			t.Errorf("Expected offset %d of [%s] to be in a literal of kind %d but was %d", offset, line, kind, kinds[offset])
+		}
+	}
+	if next := scanner.scan("still raw`"); next[0] != rawLiteral || next[len(next)-1] != noLiteral {
+		t.Errorf("Expected the raw string to span lines, got %v", next)
+	}
+}
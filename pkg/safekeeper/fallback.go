@@ -0,0 +1,118 @@
+package safekeeper
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// fallbackImports is the import of the accessors of Generator.EnvFallback, named so that it can't clash with
+// the ones of the template
+const fallbackImports = `
+
+import safekeeperos "os"`
+
+// accessor is the function returning the value of a key, from the environment if set there at runtime or else
+// its substituted value
+type accessor struct {
+	key        string
+	name       string
+	fallback   string
+	expression string
+}
+
+// accessor returns the name of the accessor of key falling back to value, embedded as configured by g
+func (e *embedding) accessor(g *Generator, key string, value string) (string, error) {
+	for _, a := range e.accessors {
+		if a.key != key {
+			continue
+		}
+		if a.fallback != value {
+			return "", fmt.Errorf("Placeholders of [%s] have different values, which can't fall back to the environment", key)
+		}
+		return a.name, nil
+	}
+
+	expression := strconv.Quote(value)
+	if g.Obfuscation != NoObfuscation || g.Split > 1 {
+		var err error
+		if expression, err = g.embed(value, e); err != nil {
+			return "", err
+		}
+	}
+	a := accessor{key: key, name: accessorName(key), fallback: value, expression: expression}
+	e.accessors = append(e.accessors, a)
+	return a.name, nil
+}
+
+// accessorName returns the name of the accessor of key, e.g. safekeeperApiKey for API_KEY
+func accessorName(key string) string {
+	var name strings.Builder
+	name.WriteString("safekeeper")
+	for _, word := range strings.Split(key, "_") {
+		if word == "" {
+			continue
+		}
+		runes := []rune(strings.ToLower(word))
+		runes[0] = unicode.ToUpper(runes[0])
+		name.WriteString(string(runes))
+	}
+	return name.String()
+}
+
+// accessorDeclarations returns the source of the accessors of e
+func (e *embedding) accessorDeclarations() string {
+	var buffer bytes.Buffer
+	for _, a := range e.accessors {
+		fmt.Fprintf(&buffer, `
+// %s returns the value of %s, from the environment if set there
+func %s() string {
+	if value, ok := safekeeperos.LookupEnv(%q); ok {
+		return value
+	}
+	return %s
+}
+`, a.name, a.key, a.name, a.key, a.expression)
+	}
+	return buffer.String()
+}
+
+// fallbackExpression returns the expression of the literal value with its placeholders replaced by calls to the
+// accessor of their key. The parts of value that aren't placeholders, as well as placeholders left untouched,
+// stay plain.
+func (g *Generator) fallbackExpression(pattern *regexp.Regexp, value string, values map[string]string, keyTransforms map[string][]string, e *embedding) (string, error) {
+	var parts []string
+	var text strings.Builder
+	last := 0
+	for _, match := range pattern.FindAllStringSubmatchIndex(value, -1) {
+		text.WriteString(value[last:match[0]])
+		last = match[1]
+
+		placeholder := value[match[0]:match[1]]
+		substituted, err := g.replacePlaceholders(pattern, placeholder, values, keyTransforms, nil)
+		if err != nil {
+			return "", err
+		}
+		if substituted == placeholder {
+			text.WriteString(placeholder)
+			continue
+		}
+		name, err := e.accessor(g, value[match[2]:match[3]], substituted)
+		if err != nil {
+			return "", err
+		}
+		if text.Len() > 0 {
+			parts = append(parts, strconv.Quote(text.String()))
+			text.Reset()
+		}
+		parts = append(parts, name+"()")
+	}
+	text.WriteString(value[last:])
+	if text.Len() > 0 || len(parts) == 0 {
+		parts = append(parts, strconv.Quote(text.String()))
+	}
+	return strings.Join(parts, " + "), nil
+}
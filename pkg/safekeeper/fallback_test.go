@@ -0,0 +1,66 @@
+package safekeeper
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestGenerateEnvFallback(t *testing.T) {
+	template := "package secrets\n\nvar (\n\tdsn = \"ENV_DB_USER:ENV_DB_PASSWORD@tcp(localhost)\"\n\tuser = \"ENV_DB_USER\"\n\tunset = \"ENV_UNSET\"\n)\n"
+
+	generator := Generator{EnvFallback: true}
+	var output bytes.Buffer
+	if err := generator.Generate(strings.NewReader(template), &output, map[string]string{"DB_USER": "app", "DB_PASSWORD": "s3cr3t"}); err != nil {
+		t.Fatal(err)
+	}
+
+	generated := output.String()
+	for _, expected := range []string{
+		"import safekeeperos \"os\"",
+		"dsn = safekeeperDbUser() + \":\" + safekeeperDbPassword() + \"@tcp(localhost)\"",
+		"user = safekeeperDbUser()",
+		"unset = \"ENV_UNSET\"",
+		"func safekeeperDbPassword() string {\n\tif value, ok := safekeeperos.LookupEnv(\"DB_PASSWORD\"); ok {\n\t\treturn value\n\t}\n\treturn \"s3cr3t\"\n}",
+	} {
+		if !strings.Contains(generated, expected) {
+			t.Errorf("Generated source should contain [%s] but was:\n\n%s", expected, generated)
+		}
+	}
+	if strings.Count(generated, "func safekeeperDbUser()") != 1 {
+		t.Errorf("Generated source should have one accessor per key but was:\n\n%s", generated)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "", generated, 0); err != nil {
+		t.Errorf("Generated source should be valid Go but got [%s]", err)
+	}
+}
+
+func TestGenerateEnvFallbackObfuscated(t *testing.T) {
+	template := "package secrets\n\nvar token = \"ENV_TOKEN\"\n"
+
+	generator := Generator{EnvFallback: true, Obfuscation: XORObfuscation}
+	var output bytes.Buffer
+	if err := generator.Generate(strings.NewReader(template), &output, map[string]string{"TOKEN": "s3cr3t"}); err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := revealAll(output.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(values, "|") != "s3cr3t" || strings.Contains(output.String(), "\"s3cr3t\"") {
+		t.Errorf("Expected the fallback value to be obfuscated but got %v:\n\n%s", values, output.String())
+	}
+}
+
+func TestGenerateEnvFallbackConflictingValues(t *testing.T) {
+	template := "package secrets\n\nvar (\n\ta = \"ENV_REGION:-us-east-1\"\n\tb = \"ENV_REGION:-eu-west-1\"\n)\n"
+
+	generator := Generator{EnvFallback: true}
+	var output bytes.Buffer
+	if err := generator.Generate(strings.NewReader(template), &output, nil); err == nil {
+		t.Errorf("Expected an error for placeholders of a key with different values but got:\n\n%s", output.String())
+	}
+}
@@ -0,0 +1,83 @@
+package safekeeper
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// Smudge reads a template from src and writes it to dst with the placeholders of the keys of values replaced,
+// as a git smudge filter: the rest of the template is kept byte for byte, go:generate lines included, and
+// placeholders of keys without a value are left untouched so that Clean gives the template back. Placeholders
+// are only replaced in string literals in ASTMode.
+func (g *Generator) Smudge(src io.Reader, dst io.Writer, values map[string]string) error {
+	if g.Engine == GoTemplateEngine {
+		return fmt.Errorf("Templates of the %s engine can't be smudged, only executed whole", GoTemplateEngine)
+	}
+	pattern, err := g.placeholderPattern()
+	if err != nil {
+		return err
+	}
+	keyTransforms, err := g.keyTransforms()
+	if err != nil {
+		return err
+	}
+	source, err := ioutil.ReadAll(src)
+	if err != nil {
+		return err
+	}
+
+	substitute := func(text string) (string, error) {
+		var err error
+		substituted := pattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+			key := pattern.FindStringSubmatch(placeholder)[1]
+			if _, ok := values[key]; !ok || err != nil {
+				return placeholder
+			}
+			var value string
+			value, err = g.replacePlaceholders(pattern, placeholder, values, keyTransforms, nil)
+			return value
+		})
+		return substituted, err
+	}
+	if g.Mode == ASTMode {
+		source, err = substituteLiterals(source, substitute)
+	} else {
+		var substituted string
+		substituted, err = substitute(string(source))
+		source = []byte(substituted)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = dst.Write(source)
+	return err
+}
+
+// Clean reads a source from src and writes it to dst with the values of values replaced by the placeholder of
+// their key, as a git clean filter: unlike Revert, the rest of the source is kept byte for byte. Cleaning a
+// source that has no values leaves it unchanged.
+func (g *Generator) Clean(src io.Reader, dst io.Writer, values map[string]string) error {
+	replacer, err := g.revertReplacer(values)
+	if err != nil {
+		return err
+	}
+	source, err := ioutil.ReadAll(src)
+	if err != nil {
+		return err
+	}
+
+	if g.Mode == ASTMode {
+		if source, err = substituteLiterals(source, func(literal string) (string, error) {
+			return replacer.Replace(literal), nil
+		}); err != nil {
+			return err
+		}
+	} else {
+		source = []byte(replacer.Replace(string(source)))
+	}
+
+	_, err = dst.Write(source)
+	return err
+}
@@ -0,0 +1,30 @@
+package safekeeper
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSmudgeAndClean(t *testing.T) {
+	template := "package secrets\n//go:generate go run gen.go\nconst (\n\ttoken = \"ENV_TOKEN\"\n\tunset = \"ENV_UNSET:-default\"\n)"
+	values := map[string]string{"TOKEN": "s3cr3t"}
+
+	var generator Generator
+	var smudged bytes.Buffer
+	if err := generator.Smudge(strings.NewReader(template), &smudged, values); err != nil {
+		t.Fatal(err)
+	}
+	expected := "package secrets\n//go:generate go run gen.go\nconst (\n\ttoken = \"s3cr3t\"\n\tunset = \"ENV_UNSET:-default\"\n)"
+	if smudged.String() != expected {
+		t.Errorf("Expected smudged file [%s] but was [%s]", expected, smudged.String())
+	}
+
+	var cleaned bytes.Buffer
+	if err := generator.Clean(&smudged, &cleaned, values); err != nil {
+		t.Fatal(err)
+	}
+	if cleaned.String() != template {
+		t.Errorf("Expected cleaned file to be the template [%s] but was [%s]", template, cleaned.String())
+	}
+}
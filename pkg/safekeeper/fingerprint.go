@@ -0,0 +1,84 @@
+package safekeeper
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+)
+
+// fingerprintsMarker starts the fingerprints of the values of a generated file, in a comment of its header
+const fingerprintsMarker = "safekeeper:fingerprints="
+
+// fingerprintSize is the number of bytes of the HMAC kept in fingerprints, enough to tell values apart without
+// recording the whole of it
+const fingerprintSize = 16
+
+// Fingerprint returns the fingerprint of the value of key: the hex encoded HMAC-SHA256 of the key and value with
+// secret, truncated, salted by the key so that keys with the same value don't have the same fingerprint. Without
+// a secret, the values of low-entropy keys can be guessed from their fingerprint by brute force.
+func Fingerprint(secret []byte, key string, value string) string {
+	mac := hmac.New(sha256.New, append([]byte("safekeeper:"), secret...))
+	mac.Write([]byte(key + "\x00" + value))
+	return hex.EncodeToString(mac.Sum(nil)[:fingerprintSize])
+}
+
+// AddFingerprints returns the generated source src with the fingerprints of values (see Fingerprint) in a comment
+// following the code generation warning, like AddContentHash, so that ChangedKeys can later tell which values
+// changed since it was generated without revealing them. src is returned as is if it has no code generation warning
+// or values is empty.
+func AddFingerprints(src []byte, secret []byte, values map[string]string) []byte {
+	if len(values) == 0 {
+		return src
+	}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fingerprints := make([]string, len(keys))
+	for i, key := range keys {
+		fingerprints[i] = key + ":" + Fingerprint(secret, key, values[key])
+	}
+	commented, _, _ := addNoticeComment(src, fingerprintsMarker+strings.Join(fingerprints, ","))
+	return commented
+}
+
+// ReadFingerprints returns the fingerprints added to content by AddFingerprints by key, nil if there are none
+func ReadFingerprints(content []byte) map[string]string {
+	for _, line := range bytes.Split(content, []byte("\n")) {
+		i := bytes.Index(line, []byte(fingerprintsMarker))
+		if i < 0 {
+			continue
+		}
+
+		recorded := line[i+len(fingerprintsMarker):]
+		if j := bytes.IndexAny(recorded, " \t\r"); j >= 0 {
+			recorded = recorded[:j]
+		}
+		fingerprints := make(map[string]string)
+		for _, fingerprint := range strings.Split(string(recorded), ",") {
+			if parts := strings.SplitN(fingerprint, ":", 2); len(parts) == 2 {
+				fingerprints[parts[0]] = parts[1]
+			}
+		}
+		return fingerprints
+	}
+	return nil
+}
+
+// ChangedKeys returns the sorted keys fingerprinted in content by AddFingerprints whose value in values has a
+// different fingerprint, i.e. changed since content was generated. Keys without a value are left out.
+func ChangedKeys(content []byte, secret []byte, values map[string]string) []string {
+	var changed []string
+	for key, fingerprint := range ReadFingerprints(content) {
+		if value, ok := values[key]; ok && !hmac.Equal([]byte(Fingerprint(secret, key, value)), []byte(fingerprint)) {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
@@ -0,0 +1,51 @@
+package safekeeper
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFingerprints(t *testing.T) {
+	generated := generatedComment + "package secrets\n\nconst token = \"s3cr3t\"\nconst id = \"safeid\"\n"
+	values := map[string]string{"TOKEN": "s3cr3t", "CLIENT_ID": "safeid"}
+	secret := []byte("pepper")
+
+	src := AddFingerprints([]byte(generated), secret, values)
+	lines := strings.Split(string(src), "\n")
+	if !strings.HasPrefix(lines[1], "// "+fingerprintsMarker+"CLIENT_ID:") || !strings.Contains(lines[1], ",TOKEN:") {
+		t.Fatalf("Expected the fingerprints to follow the code generation warning but got [%s]", string(src))
+	}
+	if strings.Contains(lines[1], "s3cr3t") || strings.Contains(lines[1], "safeid") {
+		t.Errorf("Fingerprints shouldn't reveal values but got [%s]", lines[1])
+	}
+	if fingerprints := ReadFingerprints(src); len(fingerprints) != 2 || fingerprints["TOKEN"] != Fingerprint(secret, "TOKEN", "s3cr3t") {
+		t.Errorf("Unexpected fingerprints %v", fingerprints)
+	}
+
+	if changed := ChangedKeys(src, secret, values); len(changed) != 0 {
+		t.Errorf("Expected no changed keys but got %v", changed)
+	}
+	rotated := map[string]string{"TOKEN": "r0tated", "CLIENT_ID": "safeid", "OTHER": "new"}
+	if changed := ChangedKeys(src, secret, rotated); !reflect.DeepEqual(changed, []string{"TOKEN"}) {
+		t.Errorf("Expected [TOKEN] to have changed but got %v", changed)
+	}
+	if changed := ChangedKeys(src, []byte("other"), values); len(changed) != 2 {
+		t.Errorf("Expected fingerprints of another secret not to match but got %v", changed)
+	}
+
+	if Fingerprint(nil, "A", "same") == Fingerprint(nil, "B", "same") {
+		t.Error("Expected keys with the same value to have different fingerprints")
+	}
+	if src := AddFingerprints([]byte("package secrets\n"), secret, values); string(src) != "package secrets\n" {
+		t.Errorf("Expected a source without code generation warning to be left as is but got [%s]", string(src))
+	}
+	if ReadFingerprints([]byte(generated)) != nil {
+		t.Error("Expected a source without fingerprints to have none")
+	}
+
+	xml := AddFingerprints([]byte("<!-- "+generatedNotice+" -->\n<token>s3cr3t</token>\n"), secret, values)
+	if changed := ChangedKeys(xml, secret, rotated); !reflect.DeepEqual(changed, []string{"TOKEN"}) {
+		t.Errorf("Expected the fingerprints of a comment with delimiters to be read but got %v from [%s]", changed, string(xml))
+	}
+}
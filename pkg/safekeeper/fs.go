@@ -0,0 +1,69 @@
+package safekeeper
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing/fstest"
+)
+
+// DefaultFileMode is the mode of the files written by GenerateFS when the template has none
+const DefaultFileMode fs.FileMode = 0644
+
+// WriteFS is a filesystem generated files are written to, the writing counterpart of fs.FS. Names follow the
+// rules of fs.ValidPath.
+type WriteFS interface {
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+}
+
+// DirFS is the tree of files rooted at a directory, both read (like os.DirFS) and written
+type DirFS string
+
+func (d DirFS) Open(name string) (fs.File, error) {
+	return os.DirFS(string(d)).Open(name)
+}
+
+func (d DirFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+	return ioutil.WriteFile(filepath.Join(string(d), filepath.FromSlash(name)), data, perm)
+}
+
+// MapFS is an in-memory filesystem read like an fstest.MapFS and written by setting its entries, e.g. to run
+// generations hermetically in tests or on virtual filesystems. It isn't safe for concurrent writes.
+type MapFS fstest.MapFS
+
+func (m MapFS) Open(name string) (fs.File, error) {
+	return fstest.MapFS(m).Open(name)
+}
+
+func (m MapFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+	m[name] = &fstest.MapFile{Data: append([]byte(nil), data...), Mode: perm}
+	return nil
+}
+
+// GenerateFS is Generate, reading the template named template from src and writing the generated file named
+// output to dst with the permissions of the template
+func (g *Generator) GenerateFS(src fs.FS, template string, dst WriteFS, output string, values map[string]string) error {
+	content, err := fs.ReadFile(src, template)
+	if err != nil {
+		return err
+	}
+	perm := DefaultFileMode
+	if info, err := fs.Stat(src, template); err == nil && info.Mode().Perm() != 0 {
+		perm = info.Mode().Perm()
+	}
+
+	var buffer bytes.Buffer
+	if err := g.Generate(bytes.NewReader(content), &buffer, values); err != nil {
+		return fmt.Errorf("Failed to generate [%s] from [%s]: %w", output, template, err)
+	}
+	return dst.WriteFile(output, buffer.Bytes(), perm)
+}
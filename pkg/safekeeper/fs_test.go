@@ -0,0 +1,61 @@
+package safekeeper
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestGenerateFS(t *testing.T) {
+	fsys := MapFS{
+		"config/config.go.safekeeper": &fstest.MapFile{Data: []byte("package config\n\nconst id = \"ENV_CLIENT_ID\"\n"), Mode: 0600},
+	}
+
+	var generator Generator
+	if err := generator.GenerateFS(fsys, "config/config.go.safekeeper", fsys, "config/config.go", map[string]string{"CLIENT_ID": "id"}); err != nil {
+		t.Fatal(err)
+	}
+
+	generated, ok := fsys["config/config.go"]
+	if !ok {
+		t.Fatal("Expected config/config.go to be written")
+	}
+	if expected := "package config\n\nconst id = \"id\"\n"; string(generated.Data) != expected {
+		t.Errorf("Expected [%s] but got [%s]", expected, generated.Data)
+	}
+	if generated.Mode != 0600 {
+		t.Errorf("Expected the mode of the template but got %o", generated.Mode)
+	}
+	if err := fstest.TestFS(fsys, "config/config.go.safekeeper", "config/config.go"); err != nil {
+		t.Error(err)
+	}
+
+	if err := generator.GenerateFS(fsys, "missing.safekeeper", fsys, "missing", nil); !os.IsNotExist(err) {
+		t.Errorf("Expected a missing template to fail but got [%v]", err)
+	}
+}
+
+func TestDirFS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "safekeeper-fs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "config.go.safekeeper"), []byte("const id = \"ENV_CLIENT_ID\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var generator Generator
+	if err := generator.GenerateFS(DirFS(dir), "config.go.safekeeper", DirFS(dir), "config.go", map[string]string{"CLIENT_ID": "id"}); err != nil {
+		t.Fatal(err)
+	}
+	if generated, err := ioutil.ReadFile(filepath.Join(dir, "config.go")); err != nil || string(generated) != "const id = \"id\"\n" {
+		t.Errorf("Expected the generated file in the directory but got [%s] (%v)", generated, err)
+	}
+
+	if err := DirFS(dir).WriteFile("../escaped.go", nil, 0644); err == nil {
+		t.Error("Expected a path out of the directory to be rejected")
+	}
+}
@@ -0,0 +1,568 @@
+// Package safekeeper implements the substitution engine of the safekeeper command: it replaces the
+// placeholders of a template (ENV_<KEY> by default) with the values of their keys.
+package safekeeper
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultPrefix is the prefix of placeholders unless configured otherwise
+const DefaultPrefix = "ENV_"
+
+// Generator generates sources from safekeeper templates. The zero value is ready to use and replaces
+// ENV_<KEY> placeholders.
+type Generator struct {
+	// Prefix is the start of a placeholder, DefaultPrefix if empty
+	Prefix string
+	// Suffix is the end of a placeholder, none if empty (e.g. {{ and }} for {{KEY}} placeholders)
+	Suffix string
+	// Mode selects where placeholders are replaced, TextMode by default
+	Mode Mode
+	// Obfuscation selects how the string literals with placeholders are embedded, NoObfuscation by default.
+	// Obfuscated literals are replaced by a call revealing them at runtime, so they can't be constants.
+	Obfuscation Obfuscation
+	// Split is the number of fragments the string literals with placeholders are split into, reassembled at
+	// runtime so that their value never appears contiguously (composable with Obfuscation). Literals aren't
+	// split if it's less than 2.
+	Split int
+	// EnvFallback replaces the placeholders of string literals by a call to an accessor of their key, returning
+	// the value of the environment variable named after the key if set at runtime and else the substituted
+	// value (embedded as configured by Obfuscation and Split). Such literals can't be constants.
+	EnvFallback bool
+	// Escape escapes values for the Go string literal (interpreted or raw) their placeholder is in, so that
+	// quotes, backslashes, newlines and backticks don't break the generated source
+	Escape bool
+	// Transforms maps keys to the chain of transforms their values go through (e.g. base64decode|json-escape),
+	// before the ones of their placeholders
+	Transforms map[string]string
+	// TransformFuncs are transforms of this generator only, by name, available as the registered ones are (see
+	// RegisterTransform) and replacing them, the builtin ones excepted. Names are made of lowercase letters,
+	// digits and dashes.
+	TransformFuncs map[string]TransformFunc
+	// Types maps keys to the type of their values (int, bool, float, duration, list or map, see TypeNames), written as
+	// unquoted Go literals: string literals made of a placeholder of one of these keys (e.g. "ENV_MAX_CONNS")
+	// are replaced by its value (50), as are its placeholders outside of literals in TextMode. Durations are
+	// written in nanoseconds. Values that don't parse as their type fail the generation. Values of list keys are
+	// written as a []string literal of their elements, separated by ListDelimiter, and values of map keys as a
+	// map[string]string literal of their NAME=VALUE elements.
+	Types map[string]string
+	// ListDelimiter separates the elements of the values of list keys, DefaultListDelimiter if empty
+	ListDelimiter string
+	// Engine selects how templates are substituted, PlaceholderEngine by default. The other settings only apply
+	// to PlaceholderEngine.
+	Engine Engine
+	// Source resolves the keys of the template that have no value in the values given to Generate, if not nil
+	Source SecretSource
+	// Strict fails the generation when a placeholder without a default has no value, instead of leaving it as is,
+	// as with FailOnMissing
+	Strict bool
+	// OnMissing is what the generation does with the placeholders without a default that have no value,
+	// KeepMissing by default
+	OnMissing OnMissing
+	// Header is the header written before the generated source, none if nil
+	Header *Header
+	// LineDirectives is the name of the template written in //line directives (e.g. secrets.go.safekeeper), so that
+	// the positions of panics and debuggers in the generated source point back to the lines of the template. They're
+	// written before the first line and wherever the lines stop matching the ones of the template (skipped blocks,
+	// values spanning several lines), none if empty. It doesn't apply to literals embedded with Obfuscation, Split
+	// or EnvFallback.
+	LineDirectives string
+}
+
+// Header is the header of a generated file, see WriteHeader
+type Header struct {
+	// Keys are the keys of the go:generate line, discovered from the template when regenerating if empty
+	Keys []string
+	// Output is the output of the go:generate line, the default one if empty
+	Output string
+	// Args are the other flags of the go:generate line
+	Args []string
+}
+
+// OnMissing is what a generation does with the placeholders without a default that have no value
+type OnMissing int
+
+const (
+	// KeepMissing leaves them as is
+	KeepMissing OnMissing = iota
+	// FailOnMissing fails the generation, naming their keys
+	FailOnMissing
+	// EmptyMissing replaces them by an empty value
+	EmptyMissing
+)
+
+// Mode selects the parts of a template in which placeholders are replaced
+type Mode int
+
+const (
+	// TextMode replaces placeholders anywhere in a template
+	TextMode Mode = iota
+	// ASTMode parses the template as Go source and only replaces placeholders in string literals, never in
+	// identifiers, comments or import paths
+	ASTMode
+)
+
+// String returns the name of m, as given to the --mode flag
+func (m Mode) String() string {
+	if m == ASTMode {
+		return "ast"
+	}
+	return "text"
+}
+
+// errWriter is a writer that remembers the first error it encountered and ignores all writes after it
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) writeString(value string) {
+	if ew.err != nil {
+		return
+	}
+	_, ew.err = io.WriteString(ew.w, value)
+}
+
+// Generate reads the template from src and writes it to dst with every placeholder (ENV_<KEY> by default)
+// replaced by the value of KEY in values. ENV_<KEY>:-<default> is replaced by <default> when KEY isn't in values
+// and ENV_<KEY>|<transform> by the value passed through the transform (e.g. ENV_CERT|base64decode). The lines
+// between safekeeper:if KEY and safekeeper:endif directives are only kept when KEY has a non-empty value.
+// Templates are generated line by line as they're read, except with Types, ASTMode, Obfuscation, Split,
+// EnvFallback, Strict, OnMissing, Source, Header or GoTemplateEngine, so a failing template can leave dst with
+// the lines generated before the failure.
+func (g *Generator) Generate(src io.Reader, dst io.Writer, values map[string]string) error {
+	if g.Source != nil || g.Strict || g.OnMissing != KeepMissing || g.Header != nil {
+		return g.generateWith(src, dst, values)
+	}
+	if err := g.checkEngine(); err != nil {
+		return err
+	}
+	if g.Engine == GoTemplateEngine {
+		return g.executeTemplate(src, dst, values)
+	}
+
+	pattern, err := g.placeholderPattern()
+	if err != nil {
+		return err
+	}
+	keyTransforms, err := g.keyTransforms()
+	if err != nil {
+		return err
+	}
+	// The template is read line by line, as it's generated, unless a setting needs it whole
+	blocks := newBlockReader(src, values)
+	src = blocks
+	if len(g.Types) > 0 {
+		if err := g.checkTypes(); err != nil {
+			return err
+		}
+		source, err := ioutil.ReadAll(src)
+		if err != nil {
+			return err
+		}
+		if source, err = g.unquoteTypedLiterals(pattern, source, values, keyTransforms); err != nil {
+			return err
+		}
+		src = bytes.NewReader(source)
+		if values, err = g.typedValues(values); err != nil {
+			return err
+		}
+	}
+
+	// Values are escaped for the literal their placeholder is in, tracked across the lines of the template
+	var literals literalScanner
+	replace := func(line string) (string, error) {
+		var escape func(int, string) string
+		if g.Escape {
+			kinds := literals.scan(line)
+			escape = func(offset int, value string) string {
+				return escapeValue(kinds[offset], value)
+			}
+		}
+		return g.replacePlaceholders(pattern, line, values, keyTransforms, escape)
+	}
+	if g.Obfuscation != NoObfuscation || g.Split > 1 || g.EnvFallback {
+		if g.LineDirectives != "" {
+			return errors.New("LineDirectives doesn't apply to literals embedded with Obfuscation, Split or EnvFallback")
+		}
+		source, err := ioutil.ReadAll(src)
+		if err != nil {
+			return err
+		}
+		embedded, err := g.embedLiterals(pattern, source, values, keyTransforms)
+		if err != nil {
+			return err
+		}
+		src = bytes.NewReader(embedded)
+	}
+	if g.Mode == ASTMode {
+		source, err := ioutil.ReadAll(src)
+		if err != nil {
+			return err
+		}
+		substituted, err := substituteLiterals(source, func(literal string) (string, error) {
+			var escape func(int, string) string
+			if g.Escape {
+				kind := literalKindOf(literal)
+				escape = func(offset int, value string) string {
+					return escapeValue(kind, value)
+				}
+			}
+			return g.replacePlaceholders(pattern, literal, values, keyTransforms, escape)
+		})
+		if err != nil {
+			return err
+		}
+		if g.LineDirectives != "" && bytes.Count(substituted, []byte("\n")) != bytes.Count(source, []byte("\n")) {
+			return errors.New("LineDirectives doesn't apply to ASTMode with values spanning several lines")
+		}
+		src = bytes.NewReader(substituted)
+		replace = func(line string) (string, error) { return line, nil }
+	}
+
+	// Lines keep their ending, so that the line endings of the template and its final newline (or lack
+	// thereof) are preserved
+	scanner := newLineReader(src)
+	ew := &errWriter{w: dst}
+
+	// next is the line of the template the compiler takes the next line for, after the last //line directive.
+	// Directives can't be written in raw strings and comments spanning lines.
+	next := 0
+	var directives literalScanner
+	for i := 0; scanner.Scan(); i++ {
+		line, ending := splitLineEnding(scanner.Text())
+		spanned := directives.raw || directives.blockComment
+		directives.scan(line)
+		// Any go:generate safekeeper line should be ignored since it was read from the original source and
+		// is going to be included in the header
+		if IsGenerateLine(line) {
+			continue
+		}
+		replaced, err := replace(line)
+		if err != nil {
+			return err
+		}
+		if origins := blocks.origins; g.LineDirectives != "" && i < len(origins) {
+			if origins[i] != next && !spanned {
+				directiveEnding := ending
+				if directiveEnding == "" {
+					directiveEnding = "\n"
+				}
+				ew.writeString(fmt.Sprintf("//line %s:%d%s", g.LineDirectives, origins[i], directiveEnding))
+				next = origins[i]
+			}
+			next += strings.Count(replaced+ending, "\n")
+		}
+		ew.writeString(replaced + ending)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	return ew.err
+}
+
+// prefix returns the configured placeholder prefix or DefaultPrefix
+func (g *Generator) prefix() string {
+	if g.Prefix == "" {
+		return DefaultPrefix
+	}
+	return g.Prefix
+}
+
+// placeholderPattern returns the pattern matching placeholders, the key, the |<transform> chain and the optional
+// :-<default> part being the submatches. A default runs up to the suffix or, without one, up to the first
+// whitespace or quote.
+func (g *Generator) placeholderPattern() (*regexp.Regexp, error) {
+	defaultPattern := "[^\\s\"'`]*"
+	if g.Suffix != "" {
+		defaultPattern = ".*?"
+	}
+	transforms, err := g.transformsPattern()
+	if err != nil {
+		return nil, err
+	}
+	return regexp.Compile(regexp.QuoteMeta(g.prefix()) + "([A-Za-z0-9_]+)" + transforms + "(:-" + defaultPattern + ")?" + regexp.QuoteMeta(g.Suffix))
+}
+
+// keyTransforms returns the transforms of the keys of g.Transforms, failing on unknown ones
+func (g *Generator) keyTransforms() (map[string][]string, error) {
+	keyTransforms := make(map[string][]string, len(g.Transforms))
+	for key, chain := range g.Transforms {
+		names, err := g.parseTransforms(chain)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid transforms for key [%s]: %s", key, err)
+		}
+		keyTransforms[key] = names
+	}
+	return keyTransforms, nil
+}
+
+// IsGenerateLine reports whether line is a go:generate directive running safekeeper
+func IsGenerateLine(line string) bool {
+	return strings.Contains(line, "go:generate") && strings.Contains(line, "safekeeper")
+}
+
+// Placeholder is a placeholder of a template
+type Placeholder struct {
+	Key string
+	// Default is the value used when the key has none, from ENV_<KEY>:-<default>
+	Default string
+	// HasDefault reports whether the placeholder has a default, which can be empty
+	HasDefault bool
+	// Transforms are the transforms the value goes through, from ENV_<KEY>|<transform>
+	Transforms []string
+	// Line is the line of the placeholder in the template, starting at 1
+	Line int
+	// Condition reports whether the placeholder is the key of the condition of a conditional block
+	Condition bool
+}
+
+// FindPlaceholders returns all the placeholders of the template read from src, in order of appearance. The keys
+// of the conditions of conditional blocks are placeholders with an empty default.
+func (g *Generator) FindPlaceholders(src io.Reader) ([]Placeholder, error) {
+	if g.Engine == GoTemplateEngine {
+		return templatePlaceholders(src)
+	}
+
+	pattern, err := g.placeholderPattern()
+	if err != nil {
+		return nil, err
+	}
+	source, err := ioutil.ReadAll(src)
+	if err != nil {
+		return nil, err
+	}
+
+	placeholders := conditionPlaceholders(source)
+	if g.Mode == ASTMode {
+		spans, err := stringLiterals(source)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range spans {
+			placeholders = append(placeholders, placeholdersIn(pattern, string(source[s.start:s.end]), s.line)...)
+		}
+		return byLine(placeholders), nil
+	}
+
+	scanner := newLineReader(bytes.NewReader(source))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line, _ := splitLineEnding(scanner.Text())
+		if IsGenerateLine(line) {
+			continue
+		}
+		placeholders = append(placeholders, placeholdersIn(pattern, line, lineNumber)...)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return byLine(placeholders), nil
+}
+
+// placeholdersIn returns the placeholders matched by pattern in text, text starting at line. Lines are counted
+// for multi-line (raw string) texts.
+func placeholdersIn(pattern *regexp.Regexp, text string, line int) []Placeholder {
+	var placeholders []Placeholder
+	for _, match := range pattern.FindAllStringSubmatchIndex(text, -1) {
+		placeholder := Placeholder{
+			Key:  text[match[2]:match[3]],
+			Line: line + strings.Count(text[:match[0]], "\n"),
+		}
+		if match[5] > match[4] {
+			placeholder.Transforms = strings.Split(strings.TrimPrefix(text[match[4]:match[5]], "|"), "|")
+		}
+		if match[6] >= 0 {
+			placeholder.Default = strings.TrimPrefix(text[match[6]:match[7]], ":-")
+			placeholder.HasDefault = true
+		}
+		placeholders = append(placeholders, placeholder)
+	}
+	return placeholders
+}
+
+// FindKeys returns the sorted keys of all the placeholders of the template read from src
+func (g *Generator) FindKeys(src io.Reader) ([]string, error) {
+	placeholders, err := g.FindPlaceholders(src)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]bool)
+	for _, placeholder := range placeholders {
+		found[placeholder.Key] = true
+	}
+
+	keys := make([]string, 0, len(found))
+	for key := range found {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+// replacePlaceholders replaces the placeholders of line by the value of their key or, if the key has no value,
+// by their default, passed through the transforms of the key in keyTransforms and then the ones of the
+// placeholder. Placeholders of keys without a value nor a default are left untouched. Since a key spans all
+// the identifier characters following the prefix, ENV_API_KEY_ID is never replaced by the value of API_KEY.
+//
+// escape, if not nil, escapes the replacement of the placeholder at the given offset of line.
+func (g *Generator) replacePlaceholders(pattern *regexp.Regexp, line string, values map[string]string, keyTransforms map[string][]string, escape func(offset int, value string) string) (string, error) {
+	var buffer strings.Builder
+	last := 0
+	for _, match := range pattern.FindAllStringSubmatchIndex(line, -1) {
+		buffer.WriteString(line[last:match[0]])
+		last = match[1]
+
+		key := line[match[2]:match[3]]
+		value, ok := values[key]
+		if !ok && match[6] < 0 {
+			buffer.WriteString(line[match[0]:match[1]])
+			continue
+		}
+		if !ok {
+			value = strings.TrimPrefix(line[match[6]:match[7]], ":-")
+		}
+
+		names := keyTransforms[key]
+		if match[5] > match[4] {
+			names = append(append([]string(nil), names...), strings.Split(strings.TrimPrefix(line[match[4]:match[5]], "|"), "|")...)
+		}
+		value, err := g.applyTransforms(key, value, names)
+		if err != nil {
+			return "", err
+		}
+		if escape != nil {
+			value = escape(match[0], value)
+		}
+		buffer.WriteString(value)
+	}
+	buffer.WriteString(line[last:])
+
+	return buffer.String(), nil
+}
+
+// generatedNotice is the code generation warning starting generated files
+const generatedNotice = "GENERATED by safekeeper (https://github.com/alexandre-normand/safekeeper, DO NOT EDIT"
+
+// generatedComment is the code generation warning as a Go comment
+const generatedComment = "// " + generatedNotice + "\n"
+
+// IsGenerated reports whether content was generated by safekeeper, having the code generation warning of its
+// header in a comment of any syntax
+func IsGenerated(content []byte) bool {
+	return bytes.Contains(content, []byte(generatedNotice))
+}
+
+// WriteGeneratedComment writes the code generation warning of the header alone, for output that go:generate
+// can't regenerate (e.g. streamed to stdout)
+func WriteGeneratedComment(w io.Writer) error {
+	_, err := io.WriteString(w, generatedComment)
+	return err
+}
+
+// WriteGeneratedNotice writes the code generation warning as a comment starting with commentStart and, if not
+// empty, ending with commentEnd, for files that aren't Go (e.g. # in YAML files)
+func WriteGeneratedNotice(w io.Writer, commentStart string, commentEnd string) error {
+	comment := commentStart + " " + generatedNotice
+	if commentEnd != "" {
+		comment += " " + commentEnd
+	}
+	_, err := io.WriteString(w, comment+"\n")
+	return err
+}
+
+// WriteHeader writes the header of a generated file (code generation warning as well as the go:generate line).
+// The go:generate line has no --keys if keyNames is empty, the keys being discovered from the template.
+func WriteHeader(w io.Writer, keyNames []string, output string) error {
+	var g Generator
+	return g.WriteHeader(w, keyNames, output)
+}
+
+// WriteHeader writes the header of a file generated by g, its go:generate line including the placeholder
+// syntax of g when it isn't the default one as well as args, the other flags to regenerate the file with
+func (g *Generator) WriteHeader(w io.Writer, keyNames []string, output string, args ...string) error {
+	ew := &errWriter{w: w}
+	ew.writeString(generatedComment)
+	ew.writeString("//go:generate safekeeper")
+	if len(keyNames) > 0 {
+		ew.writeString(fmt.Sprintf(" --keys=%s", strings.Join(keyNames, ",")))
+	}
+	if g.Prefix != "" && g.Prefix != DefaultPrefix {
+		ew.writeString(" " + generateArg("--prefix="+g.Prefix))
+	}
+	if g.Suffix != "" {
+		ew.writeString(" " + generateArg("--suffix="+g.Suffix))
+	}
+	if g.Mode != TextMode {
+		ew.writeString(fmt.Sprintf(" --mode=%s", g.Mode))
+	}
+	if g.Escape {
+		ew.writeString(" --escape")
+	}
+	if g.Obfuscation != NoObfuscation {
+		ew.writeString(fmt.Sprintf(" --obfuscate=%s", g.Obfuscation))
+	}
+	if g.Split > 1 {
+		ew.writeString(fmt.Sprintf(" --split=%d", g.Split))
+	}
+	if g.EnvFallback {
+		ew.writeString(" --env-fallback")
+	}
+	if g.Engine != PlaceholderEngine {
+		ew.writeString(fmt.Sprintf(" --engine=%s", g.Engine))
+	}
+	transformKeys := make([]string, 0, len(g.Transforms))
+	for key := range g.Transforms {
+		transformKeys = append(transformKeys, key)
+	}
+	sort.Strings(transformKeys)
+	for _, key := range transformKeys {
+		ew.writeString(" " + generateArg(fmt.Sprintf("--transform=%s=%s", key, g.Transforms[key])))
+	}
+	typedKeys := make([]string, 0, len(g.Types))
+	for key := range g.Types {
+		typedKeys = append(typedKeys, key)
+	}
+	sort.Strings(typedKeys)
+	for _, key := range typedKeys {
+		ew.writeString(" " + generateArg(fmt.Sprintf("--type=%s=%s", key, g.Types[key])))
+	}
+	if g.ListDelimiter != "" {
+		ew.writeString(" " + generateArg("--list-delimiter="+g.ListDelimiter))
+	}
+	for _, arg := range args {
+		ew.writeString(" " + generateArg(arg))
+	}
+	if output != "" {
+		// Forward slashes work on every platform, and go generate splits the line itself rather than a shell, so
+		// that the line works the same from cmd, PowerShell and sh
+		ew.writeString(" " + generateArg("--output="+filepath.ToSlash(output)))
+	}
+	ew.writeString(" $GOFILE\n")
+
+	return ew.err
+}
+
+// generateArg returns arg as written in a go:generate line: $ is escaped as $DOLLAR since go generate expands
+// variables, and arg is quoted if it contains spaces or quotes
+func generateArg(arg string) string {
+	arg = strings.Replace(arg, "$", "$DOLLAR", -1)
+	if strings.ContainsAny(arg, " \t\"") {
+		return strconv.Quote(arg)
+	}
+	return arg
+}
@@ -0,0 +1,273 @@
+package safekeeper
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	template := "package secrets\n//go:generate safekeeper --keys=CLIENT_ID $GOFILE\nconst clientId = \"ENV_CLIENT_ID\"\n"
+
+	var generator Generator
+	var output bytes.Buffer
+	err := generator.Generate(strings.NewReader(template), &output, map[string]string{"CLIENT_ID": "safeid"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "package secrets\nconst clientId = \"safeid\"\n"
+	if output.String() != expected {
+		t.Errorf("Expected generated source to be [%s] but was [%s]", expected, output.String())
+	}
+}
+
+func TestGeneratePreservesLineEndings(t *testing.T) {
+	template := "package secrets\r\n//go:generate safekeeper --keys=CLIENT_ID $GOFILE\r\nconst clientId = \"ENV_CLIENT_ID\"\nconst name = \"app\""
+
+	var generator Generator
+	var output bytes.Buffer
+	err := generator.Generate(strings.NewReader(template), &output, map[string]string{"CLIENT_ID": "safeid"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "package secrets\r\nconst clientId = \"safeid\"\nconst name = \"app\""
+	if output.String() != expected {
+		t.Errorf("Expected generated source to be [%q] but was [%q]", expected, output.String())
+	}
+}
+
+func TestGenerateLongLines(t *testing.T) {
+	blob := strings.Repeat("QUFB", 100000)
+	template := "var asset = \"" + blob + "\"\nconst token = \"ENV_TOKEN\" // " + blob + "\n"
+
+	var generator Generator
+	var output bytes.Buffer
+	if err := generator.Generate(strings.NewReader(template), &output, map[string]string{"TOKEN": "s3cr3t"}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "var asset = \"" + blob + "\"\nconst token = \"s3cr3t\" // " + blob + "\n"
+	if output.String() != expected {
+		t.Errorf("Expected lines longer than 64KB to be substituted")
+	}
+
+	placeholders, err := generator.FindPlaceholders(strings.NewReader(template))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(placeholders) != 1 || placeholders[0].Line != 2 {
+		t.Errorf("Expected the placeholder of the second line but got %v", placeholders)
+	}
+}
+
+func TestGenerateMatchesWholeKeys(t *testing.T) {
+	template := "const (\n\tkey = \"ENV_API_KEY\"\n\tkeyId = \"ENV_API_KEY_ID\"\n\tkeyIdSuffix = \"ENV_API_KEY-id\"\n)\n"
+
+	var generator Generator
+	var output bytes.Buffer
+	err := generator.Generate(strings.NewReader(template), &output, map[string]string{"API_KEY": "s3cr3t"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "const (\n\tkey = \"s3cr3t\"\n\tkeyId = \"ENV_API_KEY_ID\"\n\tkeyIdSuffix = \"s3cr3t-id\"\n)\n"
+	if output.String() != expected {
+		t.Errorf("Expected generated source to be [%s] but was [%s]", expected, output.String())
+	}
+}
+
+func TestGenerateOverlappingKeysIsStable(t *testing.T) {
+	template := "ENV_TOKEN ENV_TOKEN_SECRET ENV_TOKEN_SECRETS\n"
+	values := map[string]string{"TOKEN": "t", "TOKEN_SECRET": "ENV_TOKEN", "TOKEN_SECRETS": "ts"}
+
+	// Substituted values aren't replaced again, whatever the key iteration order
+	expected := "t ENV_TOKEN ts\n"
+	for i := 0; i < 20; i++ {
+		var generator Generator
+		var output bytes.Buffer
+		if err := generator.Generate(strings.NewReader(template), &output, values); err != nil {
+			t.Fatal(err)
+		}
+		if output.String() != expected {
+			t.Fatalf("Expected generated source to be [%s] but was [%s]", expected, output.String())
+		}
+	}
+}
+
+func TestGenerateDefaults(t *testing.T) {
+	template := "const (\n\tapiUrl = \"ENV_API_URL:-https://api.example.com\"\n\tregion = \"ENV_REGION:-us-east-1\"\n\tempty = \"ENV_EMPTY:-\"\n)\n"
+
+	var generator Generator
+	var output bytes.Buffer
+	err := generator.Generate(strings.NewReader(template), &output, map[string]string{"REGION": "eu-west-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "const (\n\tapiUrl = \"https://api.example.com\"\n\tregion = \"eu-west-1\"\n\tempty = \"\"\n)\n"
+	if output.String() != expected {
+		t.Errorf("Expected generated source to be [%s] but was [%s]", expected, output.String())
+	}
+}
+
+func TestFindPlaceholders(t *testing.T) {
+	var generator Generator
+	placeholders, err := generator.FindPlaceholders(strings.NewReader("url := \"ENV_API_URL:-http://localhost:8080/v1\" + \"ENV_TOKEN\"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Placeholder{{Key: "API_URL", Default: "http://localhost:8080/v1", HasDefault: true, Line: 1}, {Key: "TOKEN", Line: 1}}
+	if fmt.Sprint(placeholders) != fmt.Sprint(expected) {
+		t.Errorf("Expected placeholders %v but got %v", expected, placeholders)
+	}
+}
+
+func TestGenerateWithDelimiters(t *testing.T) {
+	template := "url: ${API_URL:-https://api.example.com}\ntoken: ${TOKEN}\nunrelated: ENV_TOKEN $TOKEN\n"
+
+	generator := Generator{Prefix: "${", Suffix: "}"}
+	var output bytes.Buffer
+	err := generator.Generate(strings.NewReader(template), &output, map[string]string{"TOKEN": "s3cr3t"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "url: https://api.example.com\ntoken: s3cr3t\nunrelated: ENV_TOKEN $TOKEN\n"
+	if output.String() != expected {
+		t.Errorf("Expected generated source to be [%s] but was [%s]", expected, output.String())
+	}
+}
+
+func TestWriteHeaderWithDelimiters(t *testing.T) {
+	generator := Generator{Prefix: "${", Suffix: "}"}
+	var output bytes.Buffer
+	if err := generator.WriteHeader(&output, []string{"TOKEN"}, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	expectedGenerateLine := "//go:generate safekeeper --keys=TOKEN --prefix=$DOLLAR{ --suffix=} $GOFILE\n"
+	if !strings.HasSuffix(output.String(), expectedGenerateLine) {
+		t.Errorf("Header should end with [%s] but was [%s]", expectedGenerateLine, output.String())
+	}
+}
+
+func TestWriteHeader(t *testing.T) {
+	var output bytes.Buffer
+	if err := WriteHeader(&output, []string{"CLIENT_ID", "CLIENT_SECRET"}, "appsecrets.go"); err != nil {
+		t.Fatal(err)
+	}
+
+	expectedGenerateLine := "//go:generate safekeeper --keys=CLIENT_ID,CLIENT_SECRET --output=appsecrets.go $GOFILE\n"
+	if !strings.HasSuffix(output.String(), expectedGenerateLine) {
+		t.Errorf("Header should end with [%s] but was [%s]", expectedGenerateLine, output.String())
+	}
+}
+
+func TestWriteHeaderWithArgs(t *testing.T) {
+	var generator Generator
+	var output bytes.Buffer
+	if err := generator.WriteHeader(&output, nil, "appsecrets.go", "--template-ext=.tmpl.go"); err != nil {
+		t.Fatal(err)
+	}
+
+	expectedGenerateLine := "//go:generate safekeeper --template-ext=.tmpl.go --output=appsecrets.go $GOFILE\n"
+	if !strings.HasSuffix(output.String(), expectedGenerateLine) {
+		t.Errorf("Header should end with [%s] but was [%s]", expectedGenerateLine, output.String())
+	}
+}
+
+func TestWriteGeneratedNotice(t *testing.T) {
+	var output bytes.Buffer
+	if err := WriteGeneratedNotice(&output, "<!--", "-->"); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "<!-- GENERATED by safekeeper (https://github.com/alexandre-normand/safekeeper, DO NOT EDIT -->\n"
+	if output.String() != expected {
+		t.Errorf("Expected notice [%s] but got [%s]", expected, output.String())
+	}
+	if !IsGenerated(output.Bytes()) || IsGenerated([]byte("# GENERATED by hand\n")) {
+		t.Errorf("Expected only the notice of safekeeper to mark generated files")
+	}
+}
+
+func TestWriteHeaderWithoutKeys(t *testing.T) {
+	var output bytes.Buffer
+	if err := WriteHeader(&output, nil, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	expectedGenerateLine := "//go:generate safekeeper $GOFILE\n"
+	if !strings.HasSuffix(output.String(), expectedGenerateLine) {
+		t.Errorf("Header should end with [%s] but was [%s]", expectedGenerateLine, output.String())
+	}
+}
+
+func TestFindKeys(t *testing.T) {
+	template := "package secrets\n//go:generate safekeeper --keys=ENV_IGNORED $GOFILE\nconst (\n\tclientId = \"ENV_CLIENT_ID\"\n\tdsn = \"ENV_DB_USER:ENV_DB_PASSWORD@ENV_CLIENT_ID\"\n)\n"
+
+	var generator Generator
+	keys, err := generator.FindKeys(strings.NewReader(template))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"CLIENT_ID", "DB_PASSWORD", "DB_USER"}
+	if strings.Join(keys, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected keys %v but got %v", expected, keys)
+	}
+}
+
+func TestLineDirectives(t *testing.T) {
+	template := "package secrets\n\n//go:generate safekeeper $GOFILE\n//safekeeper:if DEBUG\nconst debug = true\n//safekeeper:endif\nconst cert = `ENV_CERT`\nconst token = \"ENV_TOKEN\"\n\nfunc f() {}"
+	generator := Generator{LineDirectives: "secrets.go.safekeeper"}
+
+	var output bytes.Buffer
+	if err := generator.Generate(strings.NewReader(template), &output, map[string]string{"CERT": "line1\nline2", "TOKEN": "s3cr3t"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := "//line secrets.go.safekeeper:1\npackage secrets\n\n//line secrets.go.safekeeper:7\nconst cert = `line1\nline2`\n//line secrets.go.safekeeper:8\nconst token = \"s3cr3t\"\n\nfunc f() {}"
+	if output.String() != expected {
+		t.Errorf("Expected\n%s\nbut got\n%s", expected, output.String())
+	}
+
+	output.Reset()
+	if err := generator.Generate(strings.NewReader("package secrets\n\nconst usage = `\nENV_USAGE\nend`\nconst token = \"ENV_TOKEN\"\n"), &output, map[string]string{"USAGE": "a\nb", "TOKEN": "s3cr3t"}); err != nil {
+		t.Fatal(err)
+	}
+	expected = "//line secrets.go.safekeeper:1\npackage secrets\n\nconst usage = `\na\nb\nend`\n//line secrets.go.safekeeper:6\nconst token = \"s3cr3t\"\n"
+	if output.String() != expected {
+		t.Errorf("Expected no directive inside raw strings but got\n%s", output.String())
+	}
+
+	for name, unsupported := range map[string]Generator{"obfuscation": {LineDirectives: "t", Obfuscation: XORObfuscation}, "gotemplate": {LineDirectives: "t", Engine: GoTemplateEngine}} {
+		if err := unsupported.Generate(strings.NewReader("package secrets\nconst token = \"ENV_TOKEN\"\n"), &output, map[string]string{"TOKEN": "s3cr3t"}); err == nil || !strings.Contains(err.Error(), "LineDirectives") {
+			t.Errorf("Expected line directives with %s to fail but got [%v]", name, err)
+		}
+	}
+}
+
+func ExampleGenerator_Generate() {
+	var generator Generator
+	var output bytes.Buffer
+	generator.Generate(strings.NewReader("const token = \"ENV_TOKEN\"\n"), &output, map[string]string{"TOKEN": "s3cr3t"})
+	fmt.Print(output.String())
+	// Output: const token = "s3cr3t"
+}
+
+func TestWriteHeaderWithOutputPath(t *testing.T) {
+	var output bytes.Buffer
+	if err := WriteHeader(&output, nil, filepath.Join("my secrets", "appsecrets.go")); err != nil {
+		t.Fatal(err)
+	}
+
+	expectedGenerateLine := "//go:generate safekeeper \"--output=my secrets/appsecrets.go\" $GOFILE\n"
+	if !strings.HasSuffix(output.String(), expectedGenerateLine) {
+		t.Errorf("Header should end with [%s] but was [%s]", expectedGenerateLine, output.String())
+	}
+}
@@ -0,0 +1,90 @@
+package safekeeper
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// contentHashMarker starts the content hash of a generated file, in a comment of its header
+const contentHashMarker = "safekeeper:sha256="
+
+// AddContentHash returns the generated source src with the hash of its content in a comment following the code
+// generation warning, with the same comment delimiters, so that CheckContentHash can tell if the file was edited
+// since. src is returned as is if it has no code generation warning, e.g. with a custom header.
+func AddContentHash(src []byte) []byte {
+	reserved, offset, ok := ReserveContentHash(src)
+	if !ok {
+		return src
+	}
+	hash := sha256.Sum256(src)
+	copy(reserved[offset:], hex.EncodeToString(hash[:]))
+	return reserved
+}
+
+// ReserveContentHash returns the header of a generated source with the comment of its content hash, like
+// AddContentHash, the hash itself being left to fill in at offset once the whole source is written: it's the hex
+// encoded SHA-256 of header followed by the rest of the source. This lets sources be streamed to their output rather
+// than held in memory. ok is false if header has no code generation warning.
+func ReserveContentHash(header []byte) (reserved []byte, offset int, ok bool) {
+	reserved, offset, ok = addNoticeComment(header, contentHashMarker+strings.Repeat("0", hex.EncodedLen(sha256.Size)))
+	return reserved, offset + len(contentHashMarker), ok
+}
+
+// addNoticeComment returns src with a comment of text following its code generation warning, with the same
+// comment delimiters, and the offset of text in it. ok is false if src has no code generation warning.
+func addNoticeComment(src []byte, text string) (commented []byte, offset int, ok bool) {
+	start, end, ok := noticeLine(src)
+	if !ok {
+		return src, 0, false
+	}
+
+	notice := string(src[start:end])
+	i := strings.Index(notice, generatedNotice)
+	// The warning can follow the byte order mark of the file, which only starts it
+	prefix, suffix := strings.TrimPrefix(notice[:i], "\uFEFF"), notice[i+len(generatedNotice):]
+
+	var buffer bytes.Buffer
+	buffer.Write(src[:end])
+	buffer.WriteString("\n" + prefix)
+	offset = buffer.Len()
+	buffer.WriteString(text + suffix)
+	buffer.Write(src[end:])
+	return buffer.Bytes(), offset, true
+}
+
+// CheckContentHash checks the content hash added to content by AddContentHash: hashed is false if there's none,
+// intact is false if content was edited since it was generated
+func CheckContentHash(content []byte) (hashed bool, intact bool) {
+	lines := bytes.SplitAfter(content, []byte("\n"))
+	for i, line := range lines {
+		j := bytes.Index(line, []byte(contentHashMarker))
+		if j < 0 {
+			continue
+		}
+
+		recorded := line[j+len(contentHashMarker):]
+		if k := bytes.IndexAny(recorded, " \t\r\n"); k >= 0 {
+			recorded = recorded[:k]
+		}
+		hash := sha256.Sum256(bytes.Join(append(lines[:i:i], lines[i+1:]...), nil))
+		return true, string(recorded) == hex.EncodeToString(hash[:])
+	}
+	return false, false
+}
+
+// noticeLine returns the bounds of the line of src with the code generation warning, without its line ending.
+// ok is false if there's none or it's the last line, without line ending.
+func noticeLine(src []byte) (start int, end int, ok bool) {
+	i := bytes.Index(src, []byte(generatedNotice))
+	if i < 0 {
+		return 0, 0, false
+	}
+	start = bytes.LastIndexByte(src[:i], '\n') + 1
+	j := bytes.IndexByte(src[i:], '\n')
+	if j < 0 {
+		return 0, 0, false
+	}
+	return start, i + j, true
+}
@@ -0,0 +1,40 @@
+package safekeeper
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestContentHash(t *testing.T) {
+	generated := "//go:build prod\n\n" + generatedComment + "//go:generate safekeeper --keys=TOKEN $GOFILE\npackage secrets\n\nconst token = \"s3cr3t\"\n"
+
+	hashed := AddContentHash([]byte(generated))
+	lines := strings.Split(string(hashed), "\n")
+	if lines[2] != strings.TrimSuffix(generatedComment, "\n") || !strings.HasPrefix(lines[3], "// "+contentHashMarker) {
+		t.Errorf("Expected the hash to follow the code generation warning but got [%s]", string(hashed))
+	}
+	if isHashed, intact := CheckContentHash(hashed); !isHashed || !intact {
+		t.Errorf("Expected the generated source to be intact but got hashed=%t, intact=%t", isHashed, intact)
+	}
+
+	edited := bytes.Replace(hashed, []byte("s3cr3t"), []byte("edited"), 1)
+	if isHashed, intact := CheckContentHash(edited); !isHashed || intact {
+		t.Errorf("Expected the edited source to be detected but got hashed=%t, intact=%t", isHashed, intact)
+	}
+
+	if isHashed, _ := CheckContentHash([]byte(generated)); isHashed {
+		t.Errorf("Expected a source without hash not to be hashed")
+	}
+	if src := AddContentHash([]byte("package secrets\n")); string(src) != "package secrets\n" {
+		t.Errorf("Expected a source without code generation warning to be left as is but got [%s]", string(src))
+	}
+
+	xml := AddContentHash([]byte("<!-- " + generatedNotice + " -->\r\n<token>s3cr3t</token>\r\n"))
+	if !strings.Contains(string(xml), "\r\n<!-- "+contentHashMarker) || !strings.Contains(string(xml), " -->\r\n<token>") {
+		t.Errorf("Expected the hash to have the comment delimiters and line ending of the warning but got [%s]", string(xml))
+	}
+	if isHashed, intact := CheckContentHash(xml); !isHashed || !intact {
+		t.Errorf("Expected the generated XML to be intact but got hashed=%t, intact=%t", isHashed, intact)
+	}
+}
@@ -0,0 +1,54 @@
+package safekeeper
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// lineReader reads the lines of a source with their ending (\n or \r\n), the last line having none if the source
+// doesn't end with a newline, so that writing them back as they are preserves the line endings of the source.
+// Unlike bufio.Scanner, lines can be of any length (e.g. embedded base64 blobs or minified assets).
+type lineReader struct {
+	r    *bufio.Reader
+	line string
+	err  error
+}
+
+// newLineReader returns a lineReader of the lines of r
+func newLineReader(r io.Reader) *lineReader {
+	return &lineReader{r: bufio.NewReader(r)}
+}
+
+// Scan reads the next line, returning false at the end of the source or on error
+func (l *lineReader) Scan() bool {
+	if l.err != nil {
+		return false
+	}
+	l.line, l.err = l.r.ReadString('\n')
+	return l.err == nil || (l.err == io.EOF && l.line != "")
+}
+
+// Text returns the last line read by Scan, with its ending
+func (l *lineReader) Text() string {
+	return l.line
+}
+
+// Err returns the error that stopped Scan, nil at the end of the source
+func (l *lineReader) Err() error {
+	if l.err == io.EOF {
+		return nil
+	}
+	return l.err
+}
+
+// splitLineEnding returns line without its ending, and the ending
+func splitLineEnding(line string) (string, string) {
+	if strings.HasSuffix(line, "\r\n") {
+		return line[:len(line)-2], "\r\n"
+	}
+	if strings.HasSuffix(line, "\n") {
+		return line[:len(line)-1], "\n"
+	}
+	return line, ""
+}
@@ -0,0 +1,100 @@
+package safekeeper
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+)
+
+// maskMarker starts the markers of masked values
+const maskMarker = "•••"
+
+// templateActionPattern matches the actions of the templates of GoTemplateEngine
+var templateActionPattern = regexp.MustCompile(`\{\{.*?\}\}`)
+
+// MaskValue returns the marker of value: ••• followed by the start of its SHA-256, telling values apart without
+// revealing them
+func MaskValue(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return maskMarker + hex.EncodeToString(sum[:])[:8]
+}
+
+// maskedLine is a line of a template having placeholders, matching the lines generated from it
+type maskedLine struct {
+	pattern *regexp.Regexp
+	// placeholders are the placeholders of the line, in order, a group of pattern matching one as is meaning it
+	// wasn't substituted (e.g. in a comment with --mode=ast)
+	placeholders []string
+}
+
+// Mask returns generated, a source generated from template, with the values substituted for the placeholders of
+// template replaced by their MaskValue marker. Substituted values are found by matching the lines of generated with
+// the lines of template having placeholders, so that values that aren't in values, e.g. the ones of a previous
+// generation, are masked as well. Values of MinRedactedLength or more are also masked elsewhere where they're
+// whole words, shorter ones (e.g. 1) being left as is outside of the placeholders since they'd mask unrelated text.
+func (g *Generator) Mask(generated []byte, template []byte, values map[string]string) ([]byte, error) {
+	pattern := templateActionPattern
+	if g.Engine != GoTemplateEngine {
+		var err error
+		if pattern, err = g.placeholderPattern(); err != nil {
+			return nil, err
+		}
+	}
+
+	var maskedLines []maskedLine
+	for _, line := range strings.Split(string(template), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if !pattern.MatchString(line) {
+			continue
+		}
+		var linePattern strings.Builder
+		var placeholders []string
+		last := 0
+		for _, match := range pattern.FindAllStringIndex(line, -1) {
+			linePattern.WriteString(regexp.QuoteMeta(line[last:match[0]]) + "(.*?)")
+			placeholders = append(placeholders, line[match[0]:match[1]])
+			last = match[1]
+		}
+		linePattern.WriteString(regexp.QuoteMeta(line[last:]))
+		if compiled, err := regexp.Compile("^" + linePattern.String() + "$"); err == nil {
+			maskedLines = append(maskedLines, maskedLine{pattern: compiled, placeholders: placeholders})
+		}
+	}
+
+	lines := strings.Split(maskReplacer(values).Replace(string(generated)), "\n")
+	for i, line := range lines {
+		content := strings.TrimSuffix(line, "\r")
+		for _, maskedLine := range maskedLines {
+			match := maskedLine.pattern.FindStringSubmatchIndex(content)
+			if match == nil {
+				continue
+			}
+			var masked strings.Builder
+			last := 0
+			for group := 1; group < len(match)/2; group++ {
+				start, end := match[2*group], match[2*group+1]
+				if start == end || content[start:end] == maskedLine.placeholders[group-1] || strings.HasPrefix(content[start:end], maskMarker) {
+					continue
+				}
+				masked.WriteString(content[last:start] + MaskValue(content[start:end]))
+				last = end
+			}
+			lines[i] = masked.String() + line[last:]
+			break
+		}
+	}
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+// maskReplacer returns the replacer of the values of MinRedactedLength or more by their marker where they're whole
+// words
+func maskReplacer(values map[string]string) *wordReplacer {
+	replacements := make(map[string]string, len(values))
+	for _, value := range values {
+		if len(value) >= MinRedactedLength {
+			replacements[value] = MaskValue(value)
+		}
+	}
+	return newWordReplacer(replacements)
+}
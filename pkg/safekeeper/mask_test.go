@@ -0,0 +1,43 @@
+package safekeeper
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMask(t *testing.T) {
+	template := "package config\n\nconst (\n\tuser = \"ENV_USER\"\n\turl = \"https://ENV_HOST/ENV_DB:-main\"\n)\n"
+	// The host is the one of a previous generation, not in values
+	generated := "package config\n\nconst (\n\tuser = \"admin-user\"\n\turl = \"https://old.example.com/main\"\n)\n// admin-user\n"
+
+	var generator Generator
+	masked, err := generator.Mask([]byte(generated), []byte(template), map[string]string{"USER": "admin-user", "HOST": "db.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "package config\n\nconst (\n\tuser = \"" + MaskValue("admin-user") + "\"\n\turl = \"https://" + MaskValue("old.example.com") + "/" + MaskValue("main") + "\"\n)\n// " + MaskValue("admin-user") + "\n"
+	if string(masked) != expected {
+		t.Errorf("Expected masked source to be [%s] but was [%s]", expected, string(masked))
+	}
+	if MaskValue("admin-user") == MaskValue("admin-user2") || !strings.HasPrefix(MaskValue("admin-user"), "•••") {
+		t.Errorf("Expected markers to tell values apart, got [%s]", MaskValue("admin-user"))
+	}
+}
+
+func TestMaskShortValues(t *testing.T) {
+	template := "package config\n\n// ENV_API_KEY is used here\nconst port = ENV_PORT\n"
+	generated := "// safekeeper:sha256=57c3bbb90251\npackage config\n\n// ENV_API_KEY is used here\nconst port = 1\n"
+
+	var generator Generator
+	masked, err := generator.Mask([]byte(generated), []byte(template), map[string]string{"PORT": "1", "API_KEY": "031538b1-key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Short values are only masked where they were substituted, and placeholders left as is aren't masked
+	expected := "// safekeeper:sha256=57c3bbb90251\npackage config\n\n// ENV_API_KEY is used here\nconst port = " + MaskValue("1") + "\n"
+	if string(masked) != expected {
+		t.Errorf("Expected masked source to be [%s] but was [%s]", expected, string(masked))
+	}
+}
@@ -0,0 +1,300 @@
+package safekeeper
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Obfuscation selects how the string literals with placeholders are embedded in generated sources, see also
+// Generator.Split
+type Obfuscation int
+
+const (
+	// NoObfuscation embeds values in plain string literals
+	NoObfuscation Obfuscation = iota
+	// XORObfuscation embeds values as byte slices XORed with a key, revealed at runtime
+	XORObfuscation
+	// AESObfuscation embeds values as byte slices encrypted with AES-GCM, revealed at runtime
+	AESObfuscation
+)
+
+// String returns the name of o, as given to the --obfuscate flag
+func (o Obfuscation) String() string {
+	switch o {
+	case XORObfuscation:
+		return "xor"
+	case AESObfuscation:
+		return "aes"
+	default:
+		return "none"
+	}
+}
+
+// xorDecoder is the source of the function revealing XORed values
+const xorDecoder = `
+// safekeeperXOR reveals a value obfuscated by safekeeper
+func safekeeperXOR(data []byte, key []byte) string {
+	revealed := make([]byte, len(data))
+	for i := range data {
+		revealed[i] = data[i] ^ key[i%len(key)]
+	}
+	return string(revealed)
+}
+`
+
+// aesDecoder is the source of the function revealing encrypted values
+const aesDecoder = `
+// safekeeperAES reveals a value encrypted by safekeeper
+func safekeeperAES(key []byte, nonce []byte, data []byte) string {
+	block, err := safekeeperaes.NewCipher(key)
+	if err != nil {
+		panic(err)
+	}
+	gcm, err := safekeepercipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	revealed, err := gcm.Open(nil, nonce, data, nil)
+	if err != nil {
+		panic(err)
+	}
+	return string(revealed)
+}
+`
+
+// aesImports are the imports of aesDecoder, named so that they can't clash with the ones of the template
+const aesImports = `
+
+import (
+	safekeeperaes "crypto/aes"
+	safekeepercipher "crypto/cipher"
+)`
+
+// joinDecoder is the source of the function reassembling split values
+const joinDecoder = `
+// safekeeperJoin reassembles a value split by safekeeper
+func safekeeperJoin(parts ...string) string {
+	var joined []byte
+	for _, part := range parts {
+		joined = append(joined, part...)
+	}
+	return string(joined)
+}
+`
+
+// embedding accumulates what the embedded literals of a source need to be added to it
+type embedding struct {
+	// fragments are the fragments of split values, declared as constants when they aren't obfuscated
+	fragments []string
+	joined    bool
+	// accessors are the accessors of the keys falling back to the environment, in order of appearance
+	accessors []accessor
+}
+
+// fragment returns the name of the constant of a new fragment of a split value
+func (e *embedding) fragment(part string) string {
+	e.fragments = append(e.fragments, part)
+	return fragmentName(len(e.fragments) - 1)
+}
+
+// fragmentName returns the name of the constant of the i-th fragment
+func fragmentName(i int) string {
+	return fmt.Sprintf("safekeeperFragment%d", i)
+}
+
+// declarations returns the source of the fragment constants, of the functions revealing the literals
+// embedded with obfuscation and of the accessors, once they're all embedded
+func (e *embedding) declarations(obfuscation Obfuscation) string {
+	var buffer bytes.Buffer
+	if len(e.fragments) > 0 {
+		// Fragments are declared in an order that doesn't follow the values, derived from their content to be
+		// the same from one run to the next
+		order := make([]int, len(e.fragments))
+		rank := make([]string, len(e.fragments))
+		for i, part := range e.fragments {
+			order[i] = i
+			hash := sha256.Sum256([]byte(fmt.Sprintf("%d:%s", i, part)))
+			rank[i] = string(hash[:])
+		}
+		sort.Slice(order, func(i, j int) bool { return rank[order[i]] < rank[order[j]] })
+
+		buffer.WriteString("\n// Fragments of the values split by safekeeper\nconst (\n")
+		for _, i := range order {
+			fmt.Fprintf(&buffer, "\t%s = %s\n", fragmentName(i), strconv.Quote(e.fragments[i]))
+		}
+		buffer.WriteString(")\n")
+	}
+	if e.joined {
+		buffer.WriteString(joinDecoder)
+	}
+	switch obfuscation {
+	case XORObfuscation:
+		buffer.WriteString(xorDecoder)
+	case AESObfuscation:
+		buffer.WriteString(aesDecoder)
+	}
+	buffer.WriteString(e.accessorDeclarations())
+	return buffer.String()
+}
+
+// embedLiterals returns source with its string literals having placeholders replaced by an expression
+// revealing their substituted value at runtime, obfuscated and split in fragments as configured by g or
+// checking the environment first with g.EnvFallback, the declarations revealing them being added to the
+// source. Such literals can't be constants.
+func (g *Generator) embedLiterals(pattern *regexp.Regexp, source []byte, values map[string]string, keyTransforms map[string][]string) ([]byte, error) {
+	spans, err := stringLiterals(source)
+	if err != nil {
+		return nil, err
+	}
+	file, err := parser.ParseFile(token.NewFileSet(), "", source, parser.PackageClauseOnly)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid Go template: %s", err)
+	}
+	packageEnd := int(file.Name.End()) - 1
+
+	// The imports of the declarations are added once the literals are embedded, after the package clause
+	var body bytes.Buffer
+	last := packageEnd
+
+	var e embedding
+	embedded := false
+	for _, s := range spans {
+		literal := string(source[s.start:s.end])
+		if !pattern.MatchString(literal) {
+			continue
+		}
+
+		value, err := strconv.Unquote(literal)
+		if err != nil {
+			return nil, err
+		}
+		var expression string
+		if g.EnvFallback {
+			expression, err = g.fallbackExpression(pattern, value, values, keyTransforms, &e)
+		} else if value, err = g.replacePlaceholders(pattern, value, values, keyTransforms, nil); err == nil {
+			expression, err = g.embed(value, &e)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		body.Write(source[last:s.start])
+		body.WriteString(expression)
+		last = s.end
+		embedded = true
+	}
+
+	if !embedded {
+		return source, nil
+	}
+
+	body.Write(source[last:])
+	body.WriteString(e.declarations(g.Obfuscation))
+
+	var buffer bytes.Buffer
+	buffer.Write(source[:packageEnd])
+	if g.Obfuscation == AESObfuscation {
+		buffer.WriteString(aesImports)
+	}
+	if len(e.accessors) > 0 {
+		buffer.WriteString(fallbackImports)
+	}
+	buffer.Write(body.Bytes())
+
+	return buffer.Bytes(), nil
+}
+
+// embed returns the expression revealing value at runtime, split in g.Split fragments (each obfuscated with
+// g.Obfuscation or declared as a constant of e) if more than one
+func (g *Generator) embed(value string, e *embedding) (string, error) {
+	parts := []string{value}
+	if g.Split > 1 {
+		parts = splitValue(value, g.Split)
+	}
+
+	expressions := make([]string, len(parts))
+	for i, part := range parts {
+		if g.Obfuscation == NoObfuscation {
+			expressions[i] = e.fragment(part)
+			continue
+		}
+
+		expression, err := g.Obfuscation.embed(part)
+		if err != nil {
+			return "", err
+		}
+		expressions[i] = expression
+	}
+
+	if len(expressions) == 1 && g.Split <= 1 {
+		return expressions[0], nil
+	}
+	e.joined = true
+	return "safekeeperJoin(" + strings.Join(expressions, ", ") + ")", nil
+}
+
+// splitValue splits value in n fragments of about the same size, fewer if value is shorter
+func splitValue(value string, n int) []string {
+	if len(value) < n {
+		n = len(value)
+	}
+	if n <= 1 {
+		return []string{value}
+	}
+
+	parts := make([]string, n)
+	for i := range parts {
+		parts[i] = value[i*len(value)/n : (i+1)*len(value)/n]
+	}
+	return parts
+}
+
+// embed returns the expression revealing value at runtime. Keys are derived from the value so that generating
+// the same values twice gives the same source.
+func (o Obfuscation) embed(value string) (string, error) {
+	seed := sha256.Sum256([]byte("safekeeper:" + value))
+
+	if o == AESObfuscation {
+		nonceSeed := sha256.Sum256(seed[:])
+		key, nonce := seed[:], nonceSeed[:12]
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return "", err
+		}
+		gcm, err := cipher.NewGCM(block)
+		if err != nil {
+			return "", err
+		}
+		data := gcm.Seal(nil, nonce, []byte(value), nil)
+		return fmt.Sprintf("safekeeperAES(%s, %s, %s)", byteSlice(key), byteSlice(nonce), byteSlice(data)), nil
+	}
+
+	key := make([]byte, 0, len(value))
+	for block := seed; len(key) < len(value); block = sha256.Sum256(block[:]) {
+		key = append(key, block[:]...)
+	}
+	key = key[:len(value)]
+	data := make([]byte, len(value))
+	for i := range data {
+		data[i] = value[i] ^ key[i]
+	}
+	return fmt.Sprintf("safekeeperXOR(%s, %s)", byteSlice(data), byteSlice(key)), nil
+}
+
+// byteSlice returns the Go literal of the byte slice b
+func byteSlice(b []byte) string {
+	elements := make([]string, len(b))
+	for i, c := range b {
+		elements[i] = fmt.Sprintf("0x%02x", c)
+	}
+	return "[]byte{" + strings.Join(elements, ", ") + "}"
+}
@@ -0,0 +1,142 @@
+package safekeeper
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestGenerateObfuscated(t *testing.T) {
+	template := "package secrets\n\nimport \"fmt\"\n\nvar (\n\tclientSecret = \"ENV_CLIENT_SECRET\"\n\tdsn = fmt.Sprint(\"postgres://app:ENV_DB_PASSWORD@db\")\n)\n"
+	values := map[string]string{"CLIENT_SECRET": "s3cr3t", "DB_PASSWORD": "hunter2"}
+
+	for _, obfuscation := range []Obfuscation{XORObfuscation, AESObfuscation} {
+		generator := Generator{Obfuscation: obfuscation}
+		var output bytes.Buffer
+		if err := generator.Generate(strings.NewReader(template), &output, values); err != nil {
+			t.Fatal(err)
+		}
+
+		generated := output.String()
+		if strings.Contains(generated, "s3cr3t") || strings.Contains(generated, "hunter2") {
+			t.Errorf("Generated source with %s obfuscation shouldn't have the plain values:\n\n%s", obfuscation, generated)
+		}
+
+		revealed, err := revealAll(generated)
+		if err != nil {
+			t.Fatalf("Generated source with %s obfuscation should be valid Go but got [%s]:\n\n%s", obfuscation, err, generated)
+		}
+		expected := []string{"s3cr3t", "postgres://app:hunter2@db"}
+		if strings.Join(revealed, ",") != strings.Join(expected, ",") {
+			t.Errorf("Expected %s obfuscated values %v but got %v", obfuscation, expected, revealed)
+		}
+	}
+}
+
+func TestGenerateObfuscatedIsReproducible(t *testing.T) {
+	template := "package secrets\n\nvar token = \"ENV_TOKEN\"\n"
+
+	generator := Generator{Obfuscation: AESObfuscation}
+	var first, second bytes.Buffer
+	for _, output := range []*bytes.Buffer{&first, &second} {
+		if err := generator.Generate(strings.NewReader(template), output, map[string]string{"TOKEN": "tk"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if first.String() != second.String() {
+		t.Errorf("Expected the same source for the same values but got:\n\n%s\n\nand\n\n%s", first.String(), second.String())
+	}
+}
+
+// revealAll parses src and returns the values revealed by its calls to the safekeeper decoders, in order
+func revealAll(src string) ([]string, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), "", src, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var revealed []string
+	ast.Inspect(file, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		name, ok := call.Fun.(*ast.Ident)
+		if !ok || (name.Name != "safekeeperXOR" && name.Name != "safekeeperAES") {
+			return true
+		}
+
+		args := make([][]byte, len(call.Args))
+		for i, arg := range call.Args {
+			for _, element := range arg.(*ast.CompositeLit).Elts {
+				b, _ := strconv.ParseUint(element.(*ast.BasicLit).Value, 0, 8)
+				args[i] = append(args[i], byte(b))
+			}
+		}
+
+		switch name.Name {
+		case "safekeeperXOR":
+			value := make([]byte, len(args[0]))
+			for i := range value {
+				value[i] = args[0][i] ^ args[1][i%len(args[1])]
+			}
+			revealed = append(revealed, string(value))
+		case "safekeeperAES":
+			block, _ := aes.NewCipher(args[0])
+			gcm, _ := cipher.NewGCM(block)
+			value, _ := gcm.Open(nil, args[1], args[2], nil)
+			revealed = append(revealed, string(value))
+		}
+		return true
+	})
+
+	return revealed, nil
+}
+
+func TestGenerateSplit(t *testing.T) {
+	template := "package secrets\n\nvar token = \"ENV_TOKEN\"\n"
+
+	generator := Generator{Split: 3}
+	var output bytes.Buffer
+	if err := generator.Generate(strings.NewReader(template), &output, map[string]string{"TOKEN": "abcdefghi"}); err != nil {
+		t.Fatal(err)
+	}
+
+	generated := output.String()
+	if strings.Contains(generated, "abcdefghi") {
+		t.Errorf("Split value shouldn't appear contiguously:\n\n%s", generated)
+	}
+	for _, expected := range []string{"var token = safekeeperJoin(safekeeperFragment0, safekeeperFragment1, safekeeperFragment2)", "safekeeperFragment0 = \"abc\"", "safekeeperFragment1 = \"def\"", "safekeeperFragment2 = \"ghi\""} {
+		if !strings.Contains(generated, expected) {
+			t.Errorf("Generated source should contain [%s] but was:\n\n%s", expected, generated)
+		}
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "", generated, 0); err != nil {
+		t.Errorf("Generated source should be valid Go but got [%s]", err)
+	}
+}
+
+func TestGenerateSplitObfuscated(t *testing.T) {
+	template := "package secrets\n\nvar token = \"ENV_TOKEN\"\n"
+
+	generator := Generator{Split: 2, Obfuscation: XORObfuscation}
+	var output bytes.Buffer
+	if err := generator.Generate(strings.NewReader(template), &output, map[string]string{"TOKEN": "abcdef"}); err != nil {
+		t.Fatal(err)
+	}
+
+	fragments, err := revealAll(output.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(fragments, "|") != "abc|def" {
+		t.Errorf("Expected the value to be obfuscated in 2 fragments but got %v:\n\n%s", fragments, output.String())
+	}
+}
@@ -0,0 +1,220 @@
+package safekeeper
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// Option configures the Generator returned by New
+type Option func(*Generator)
+
+// New returns a Generator configured by opts, e.g. New(WithPrefix("${"), WithSuffix("}"), WithStrict()). Generators
+// only read their settings, so a Generator can be used by several goroutines at once.
+func New(opts ...Option) *Generator {
+	g := &Generator{}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// WithPrefix sets the start of placeholders, DefaultPrefix by default
+func WithPrefix(prefix string) Option {
+	return func(g *Generator) { g.Prefix = prefix }
+}
+
+// WithSuffix sets the end of placeholders, none by default
+func WithSuffix(suffix string) Option {
+	return func(g *Generator) { g.Suffix = suffix }
+}
+
+// WithMode sets where placeholders are replaced, TextMode by default
+func WithMode(mode Mode) Option {
+	return func(g *Generator) { g.Mode = mode }
+}
+
+// WithEngine sets how templates are substituted, PlaceholderEngine by default
+func WithEngine(engine Engine) Option {
+	return func(g *Generator) { g.Engine = engine }
+}
+
+// WithTransforms sets the transforms of the values of keys, see Generator.Transforms
+func WithTransforms(transforms map[string]string) Option {
+	return func(g *Generator) { g.Transforms = transforms }
+}
+
+// WithTransformFuncs sets the transforms of the generator only, by name, see Generator.TransformFuncs
+func WithTransformFuncs(transforms map[string]TransformFunc) Option {
+	return func(g *Generator) { g.TransformFuncs = transforms }
+}
+
+// WithTypes sets the types of the values of keys, see Generator.Types
+func WithTypes(types map[string]string) Option {
+	return func(g *Generator) { g.Types = types }
+}
+
+// WithListDelimiter sets the delimiter of the elements of the values of list keys, DefaultListDelimiter by default
+func WithListDelimiter(delimiter string) Option {
+	return func(g *Generator) { g.ListDelimiter = delimiter }
+}
+
+// WithObfuscation sets how the string literals with placeholders are embedded, see Generator.Obfuscation
+func WithObfuscation(obfuscation Obfuscation) Option {
+	return func(g *Generator) { g.Obfuscation = obfuscation }
+}
+
+// WithSplit sets the number of fragments the string literals with placeholders are split into, see Generator.Split
+func WithSplit(fragments int) Option {
+	return func(g *Generator) { g.Split = fragments }
+}
+
+// WithEnvFallback makes string literals check the environment at runtime, see Generator.EnvFallback
+func WithEnvFallback() Option {
+	return func(g *Generator) { g.EnvFallback = true }
+}
+
+// WithEscape escapes values for the Go string literal their placeholder is in
+func WithEscape() Option {
+	return WithEscaping(true)
+}
+
+// WithEscaping sets whether values are escaped for the Go string literal their placeholder is in, see
+// Generator.Escape
+func WithEscaping(escape bool) Option {
+	return func(g *Generator) { g.Escape = escape }
+}
+
+// WithStrict fails generations leaving a placeholder without a value
+func WithStrict() Option {
+	return WithOnMissing(FailOnMissing)
+}
+
+// WithOnMissing sets what generations do with the placeholders without a default that have no value, see OnMissing
+func WithOnMissing(onMissing OnMissing) Option {
+	return func(g *Generator) { g.OnMissing = onMissing }
+}
+
+// WithLineDirectives writes //line directives mapping the generated source back to the lines of the template
+// named name, see Generator.LineDirectives
+func WithLineDirectives(name string) Option {
+	return func(g *Generator) { g.LineDirectives = name }
+}
+
+// WithHeader writes the header of a generated file before the generated source, see WriteHeader
+func WithHeader(keyNames []string, output string, args ...string) Option {
+	return func(g *Generator) { g.Header = &Header{Keys: keyNames, Output: output, Args: args} }
+}
+
+// Options are the settings of a Generator most callers configure, as a struct for the ones building them from
+// their own settings (e.g. flags) rather than choosing options. WithOptions applies them.
+type Options struct {
+	// Prefix and Suffix delimit placeholders, see WithPrefix and WithSuffix
+	Prefix string
+	Suffix string
+	// Escaping escapes values for the Go string literal their placeholder is in, see WithEscaping
+	Escaping bool
+	// OnMissing is what generations do with the placeholders without a value, see WithOnMissing
+	OnMissing OnMissing
+	// Header is the header written before the generated source, none if nil, see WithHeader
+	Header *Header
+	// Transforms are the transforms of the values of keys, see WithTransforms
+	Transforms map[string]string
+}
+
+// WithOptions sets the settings of opts, the empty ones included
+func WithOptions(opts Options) Option {
+	return func(g *Generator) {
+		for _, opt := range []Option{WithPrefix(opts.Prefix), WithSuffix(opts.Suffix), WithEscaping(opts.Escaping), WithOnMissing(opts.OnMissing), WithTransforms(opts.Transforms)} {
+			opt(g)
+		}
+		g.Header = opts.Header
+	}
+}
+
+// WithSources resolves the keys without a value with the first of sources that has them
+func WithSources(sources ...SecretSource) Option {
+	return func(g *Generator) { g.Source = Chain(sources...) }
+}
+
+// generateWith is Generate for the generators with a Source, an OnMissing (or Strict) or a Header, handled around
+// the substitution of a copy of g without them
+func (g *Generator) generateWith(src io.Reader, dst io.Writer, values map[string]string) error {
+	substitution := *g
+	substitution.Source, substitution.Strict, substitution.OnMissing, substitution.Header = nil, false, KeepMissing, nil
+	onMissing := g.OnMissing
+	if g.Strict {
+		onMissing = FailOnMissing
+	}
+
+	template, err := ioutil.ReadAll(src)
+	if err != nil {
+		return err
+	}
+	var placeholders []Placeholder
+	if g.Source != nil || onMissing != KeepMissing {
+		if placeholders, err = substitution.FindPlaceholders(bytes.NewReader(template)); err != nil {
+			return err
+		}
+	}
+
+	if g.Source != nil {
+		resolved := make(map[string]string, len(values))
+		for key, value := range values {
+			resolved[key] = value
+		}
+		for _, p := range placeholders {
+			if _, ok := resolved[p.Key]; ok {
+				continue
+			}
+			value, found, err := g.Source.Resolve(p.Key)
+			if err != nil {
+				return err
+			}
+			if found {
+				resolved[p.Key] = value
+			}
+		}
+		values = resolved
+	}
+
+	missing := make(map[string]bool)
+	for _, p := range placeholders {
+		if _, ok := values[p.Key]; !ok && !p.HasDefault {
+			missing[p.Key] = true
+		}
+	}
+	switch {
+	case onMissing == EmptyMissing && len(missing) > 0:
+		completed := make(map[string]string, len(values)+len(missing))
+		for key, value := range values {
+			completed[key] = value
+		}
+		for key := range missing {
+			completed[key] = ""
+		}
+		values = completed
+	case onMissing == FailOnMissing && len(missing) > 0:
+		keys := make([]string, 0, len(missing))
+		for key := range missing {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return fmt.Errorf("Values for keys [%s] not found", strings.Join(keys, ", "))
+	}
+
+	var buffer bytes.Buffer
+	if g.Header != nil {
+		if err := substitution.WriteHeader(&buffer, g.Header.Keys, g.Header.Output, g.Header.Args...); err != nil {
+			return err
+		}
+	}
+	if err := substitution.Generate(bytes.NewReader(template), &buffer, values); err != nil {
+		return err
+	}
+	_, err = dst.Write(buffer.Bytes())
+	return err
+}
@@ -0,0 +1,142 @@
+package safekeeper
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestNewWithOptions(t *testing.T) {
+	g := New(WithPrefix("${"), WithSuffix("}"), WithTransforms(map[string]string{"NAME": "base64"}), WithSources(MapSource{"NAME": "safekeeper"}))
+
+	var buffer bytes.Buffer
+	if err := g.Generate(strings.NewReader("id=${ID} name=${NAME}\n"), &buffer, map[string]string{"ID": "id"}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "id=id name=c2FmZWtlZXBlcg==\n"; buffer.String() != expected {
+		t.Errorf("Expected [%s] but got [%s]", expected, buffer.String())
+	}
+}
+
+func TestWithStrict(t *testing.T) {
+	g := New(WithStrict())
+
+	err := g.Generate(strings.NewReader("const id = \"ENV_ID\"\nconst secret = \"ENV_SECRET\"\nconst level = \"ENV_LEVEL:-info\"\n"), &bytes.Buffer{}, nil)
+	if err == nil || err.Error() != "Values for keys [ID, SECRET] not found" {
+		t.Errorf("Expected the keys without a value to fail but got [%v]", err)
+	}
+
+	if err := g.Generate(strings.NewReader("const level = \"ENV_LEVEL:-info\"\n"), &bytes.Buffer{}, nil); err != nil {
+		t.Errorf("Expected placeholders with a default to be satisfied but got [%v]", err)
+	}
+}
+
+func TestWithHeader(t *testing.T) {
+	g := New(WithHeader([]string{"ID"}, "config.go"))
+
+	var buffer bytes.Buffer
+	if err := g.Generate(strings.NewReader("package config\n\nconst id = \"ENV_ID\"\n"), &buffer, map[string]string{"ID": "id"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var header bytes.Buffer
+	if err := WriteHeader(&header, []string{"ID"}, "config.go"); err != nil {
+		t.Fatal(err)
+	}
+	if expected := header.String() + "package config\n\nconst id = \"id\"\n"; buffer.String() != expected {
+		t.Errorf("Expected [%s] but got [%s]", expected, buffer.String())
+	}
+}
+
+func TestGeneratorConcurrentUse(t *testing.T) {
+	g := New(WithStrict(), WithSources(MapSource{"ID": "id"}))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var buffer bytes.Buffer
+			if err := g.Generate(strings.NewReader("const id = \"ENV_ID\"\n"), &buffer, nil); err != nil {
+				errs <- err
+			} else if buffer.String() != "const id = \"id\"\n" {
+				errs <- nil
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("Expected every concurrent generation to succeed but got [%v]", err)
+	}
+}
+
+func TestOptionCombinations(t *testing.T) {
+	template := "const name = \"${NAME}\"\nconst id = \"${ID}\"\n"
+	cases := []struct {
+		name     string
+		opts     []Option
+		expected string
+		err      string
+	}{
+		{"kept", []Option{WithPrefix("${"), WithSuffix("}")}, "const name = \"say \"hi\"\"\nconst id = \"${ID}\"\n", ""},
+		{"escaped and empty", []Option{WithPrefix("${"), WithSuffix("}"), WithEscaping(true), WithOnMissing(EmptyMissing)}, "const name = \"say \\\"hi\\\"\"\nconst id = \"\"\n", ""},
+		{"failing", []Option{WithPrefix("${"), WithSuffix("}"), WithOnMissing(FailOnMissing)}, "", "Values for keys [ID] not found"},
+		{"options", []Option{WithOptions(Options{Prefix: "${", Suffix: "}", Escaping: true, OnMissing: EmptyMissing, Transforms: map[string]string{"NAME": "base64"}})}, "const name = \"c2F5ICJoaSI=\"\nconst id = \"\"\n", ""},
+		{"options overridden", []Option{WithOptions(Options{Prefix: "${", Suffix: "}", OnMissing: FailOnMissing}), WithOnMissing(KeepMissing)}, "const name = \"say \"hi\"\"\nconst id = \"${ID}\"\n", ""},
+	}
+
+	for _, c := range cases {
+		var buffer bytes.Buffer
+		err := New(c.opts...).Generate(strings.NewReader(template), &buffer, map[string]string{"NAME": "say \"hi\""})
+		if c.err != "" {
+			if err == nil || err.Error() != c.err {
+				t.Errorf("Expected [%s] for %s but got [%v]", c.err, c.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if buffer.String() != c.expected {
+			t.Errorf("Expected [%s] for %s but got [%s]", c.expected, c.name, buffer.String())
+		}
+	}
+}
+
+func TestWithTransformFuncs(t *testing.T) {
+	upper := func(value string) (string, error) { return strings.ToUpper(value), nil }
+	g := New(WithTransformFuncs(map[string]TransformFunc{"test-upper": upper, "base64": upper}), WithTransforms(map[string]string{"ID": "test-upper"}))
+
+	var buffer bytes.Buffer
+	if err := g.Generate(strings.NewReader("id=ENV_ID name=ENV_NAME|test-upper|base64\n"), &buffer, map[string]string{"ID": "id", "NAME": "n"}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "id=ID name=Tg==\n"; buffer.String() != expected {
+		t.Errorf("Expected the transforms of the generator, the builtin ones winning, but got [%s]", buffer.String())
+	}
+
+	// Other generators don't have them
+	buffer.Reset()
+	if err := New().Generate(strings.NewReader("name=ENV_NAME|test-upper\n"), &buffer, map[string]string{"NAME": "n"}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "name=n|test-upper\n"; buffer.String() != expected {
+		t.Errorf("Expected the transform to be unknown to other generators but got [%s]", buffer.String())
+	}
+
+	template := New(WithEngine(GoTemplateEngine), WithTransformFuncs(map[string]TransformFunc{"test-upper": upper}))
+	buffer.Reset()
+	if err := template.Generate(strings.NewReader("name={{ .NAME | transform \"test-upper\" }}\n"), &buffer, map[string]string{"NAME": "n"}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "name=N\n"; buffer.String() != expected {
+		t.Errorf("Expected the transform of the generator in templates but got [%s]", buffer.String())
+	}
+
+	if err := New(WithTransformFuncs(map[string]TransformFunc{"Not a name": upper})).Generate(strings.NewReader(""), &bytes.Buffer{}, nil); err == nil {
+		t.Error("Expected an invalid transform name to be rejected")
+	}
+}
@@ -0,0 +1,90 @@
+package safekeeper
+
+import (
+	"bytes"
+	"strings"
+)
+
+// headerMarkers start the comments safekeeper adds to the header of generated files
+var headerMarkers = []string{contentHashMarker, versionMarker, fingerprintsMarker, generatedAtMarker}
+
+// isHeaderLine reports whether line is a line of the header of a generated file: the code generation warning (or
+// an older one, a comment mentioning safekeeper and not to edit the file), the go:generate line running safekeeper
+// or the comment of one of headerMarkers
+func isHeaderLine(line string) bool {
+	if strings.Contains(line, generatedNotice) || IsGenerateLine(line) {
+		return true
+	}
+	for _, marker := range headerMarkers {
+		if strings.Contains(line, marker) {
+			return true
+		}
+	}
+	lower := strings.ToLower(line)
+	return strings.Contains(lower, "safekeeper") && strings.Contains(lower, "do not edit")
+}
+
+// ReplaceHeader returns content, a generated file, with its header replaced by header, e.g. after the format of
+// headers changed: the lines of the header of content are the ones of isHeaderLine from the first one, which can
+// only follow blank lines and build constraints, to the first other line. What precedes and follows them is kept
+// as is, as well as the fingerprints of the values since they can't be computed again without them. header gets
+// the line endings of the header it replaces and the content hash is updated. ok is false if content has no
+// header.
+func ReplaceHeader(content []byte, header []byte) (replaced []byte, ok bool) {
+	lines := bytes.SplitAfter(content, []byte("\n"))
+	start, end := headerRange(lines)
+	if start < 0 {
+		return content, false
+	}
+	var fingerprints string
+	for _, line := range lines[start:end] {
+		if j := bytes.Index(line, []byte(fingerprintsMarker)); j >= 0 {
+			fingerprints = strings.Fields(string(line[j:]))[0]
+		}
+	}
+	crlf := bytes.HasSuffix(lines[start], []byte("\r\n"))
+
+	if fingerprints != "" {
+		header, _, _ = addNoticeComment(header, fingerprints)
+	}
+	if crlf {
+		header = bytes.ReplaceAll(bytes.ReplaceAll(header, []byte("\r\n"), []byte("\n")), []byte("\n"), []byte("\r\n"))
+	}
+	var buffer bytes.Buffer
+	buffer.Write(bytes.Join(lines[:start], nil))
+	if start == 0 && bytes.HasPrefix(content, []byte("\uFEFF")) {
+		buffer.WriteString("\uFEFF")
+	}
+	buffer.Write(header)
+	buffer.Write(bytes.Join(lines[end:], nil))
+	return AddContentHash(buffer.Bytes()), true
+}
+
+// headerRange returns the range of the lines of the header of a generated file, see ReplaceHeader, with start -1
+// if it has none
+func headerRange(lines [][]byte) (start int, end int) {
+	start, end = -1, -1
+	for i, line := range lines {
+		text := strings.TrimRight(string(line), "\r\n")
+		if i == 0 {
+			text = strings.TrimPrefix(text, "\uFEFF")
+		}
+		if isHeaderLine(text) {
+			if start < 0 {
+				start = i
+			}
+			end = i + 1
+			continue
+		}
+		trimmed := strings.TrimSpace(text)
+		if start >= 0 || (trimmed != "" && !isConstraintLine(trimmed)) {
+			break
+		}
+	}
+	return start, end
+}
+
+// isConstraintLine reports whether line, trimmed, is a build constraint
+func isConstraintLine(line string) bool {
+	return strings.HasPrefix(line, "//go:build") || strings.HasPrefix(line, "// +build")
+}
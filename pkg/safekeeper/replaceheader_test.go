@@ -0,0 +1,35 @@
+package safekeeper
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReplaceHeader(t *testing.T) {
+	header := []byte(AddVersion([]byte(generatedComment+"//go:generate safekeeper --keys=TOKEN $GOFILE\n"), "v1.4.0"))
+	body := "package secrets\n\nconst token = \"s3cr3t\"\n"
+
+	old := "//go:build prod\n\n// Code generated by safekeeper. DO NOT EDIT.\n//go:generate safekeeper --keys=TOKEN\n" + body
+	replaced, ok := ReplaceHeader([]byte(old), header)
+	if !ok {
+		t.Fatal("Expected the old header to be found")
+	}
+	lines := strings.Split(string(replaced), "\n")
+	if lines[0] != "//go:build prod" || lines[2] != strings.TrimSuffix(generatedComment, "\n") || !strings.HasPrefix(lines[3], "// "+contentHashMarker) || lines[4] != "// "+versionMarker+"v1.4.0" || !strings.HasSuffix(string(replaced), "\n"+body) {
+		t.Fatalf("Expected the header to be replaced, the build constraint and the body kept, but got:\n%s", replaced)
+	}
+	if hashed, intact := CheckContentHash(replaced); !hashed || !intact {
+		t.Errorf("Expected the content hash to be updated")
+	}
+
+	// Fingerprints are kept and line endings follow the file
+	crlf := "// " + generatedNotice + "\r\n// " + fingerprintsMarker + "TOKEN:abcd\r\n//go:generate safekeeper\r\npackage secrets\r\n"
+	replaced, _ = ReplaceHeader([]byte(crlf), header)
+	if !strings.Contains(string(replaced), "\r\n// "+fingerprintsMarker+"TOKEN:abcd\r\n") || strings.Contains(strings.ReplaceAll(string(replaced), "\r\n", ""), "\n") {
+		t.Errorf("Expected the fingerprints and CRLF line endings to be kept but got [%q]", replaced)
+	}
+
+	if _, ok := ReplaceHeader([]byte(body), header); ok {
+		t.Error("Expected no header in a file without one")
+	}
+}
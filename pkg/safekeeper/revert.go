@@ -0,0 +1,319 @@
+package safekeeper
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// lineDirectivePattern matches the //line directives written with LineDirectives
+var lineDirectivePattern = regexp.MustCompile(`^//line \S+:\d+\s*$`)
+
+// Revert reads a source generated with values from src and writes it to dst with the values replaced by the
+// placeholder of their key, the reverse of Generate. The header of the generated source (see ReplaceHeader) and
+// its //line directives are left out. Values are only replaced where they're whole words (8 in "host:8" but not
+// in 80) and, in ASTMode, in string literals, by {{ .KEY }} with GoTemplateEngine. Values going through
+// transforms or escaped aren't recognized, and empty values are never replaced. When the template the source was
+// generated from is known, RevertTemplate only replaces the values of the lines that differ from it.
+func (g *Generator) Revert(src io.Reader, dst io.Writer, values map[string]string) error {
+	placeholders, err := g.revertValues(values)
+	if err != nil {
+		return err
+	}
+	replacer := newWordReplacer(placeholders)
+
+	source, err := ioutil.ReadAll(src)
+	if err != nil {
+		return err
+	}
+	if g.Mode == ASTMode {
+		if source, err = substituteLiterals(source, func(literal string) (string, error) {
+			return replacer.Replace(literal), nil
+		}); err != nil {
+			return err
+		}
+	} else {
+		source = []byte(replacer.Replace(string(source)))
+	}
+
+	ew := &errWriter{w: dst}
+	for _, line := range generatedBody(source) {
+		ew.writeString(line)
+	}
+	return ew.err
+}
+
+// RevertTemplate reads a source generated with values from src and writes the template it's generated from to
+// dst: the lines of template, the existing template, that the source still has as generated from them are kept as
+// they are, and the lines of the source that differ (edited or added ones) are reverted as by Revert. Lines of
+// template that aren't generated with values (directives, conditional blocks left out) are kept, and the other
+// ones are left out if the source doesn't have them anymore, unless with GoTemplateEngine whose lines can't be
+// told from the ones of actions left out. A value appearing in the source outside of the placeholders of template
+// (e.g. a port also used as a literal) is thus only replaced on the lines that changed.
+func (g *Generator) RevertTemplate(src io.Reader, template []byte, dst io.Writer, values map[string]string) error {
+	placeholders, err := g.revertValues(values)
+	if err != nil {
+		return err
+	}
+	replacer := newWordReplacer(placeholders)
+	source, err := ioutil.ReadAll(src)
+	if err != nil {
+		return err
+	}
+	units, err := g.templateUnits(template, values)
+	if err != nil {
+		return err
+	}
+	body := generatedBody(source)
+
+	var aligned []templateUnit
+	for _, unit := range units {
+		if unit.generated != nil {
+			aligned = append(aligned, unit)
+		}
+	}
+	matches := alignLines(aligned, body)
+
+	ew := &errWriter{w: dst}
+	next, a := 0, 0
+	// insert writes the reverted lines of the source up to line, the ones that don't match template
+	insert := func(line int) {
+		for ; next < line; next++ {
+			ew.writeString(replacer.Replace(body[next]))
+		}
+	}
+	for _, unit := range units {
+		if unit.generated == nil {
+			ew.writeString(unit.line)
+			continue
+		}
+		match := matches[a]
+		a++
+		if match >= 0 {
+			insert(match)
+			ew.writeString(unit.line)
+			next = match + len(unit.generated)
+			continue
+		}
+		// A line of template that the source doesn't have was deleted, or replaced by the lines inserted before
+		// the next match
+		if g.Engine == GoTemplateEngine && !replaced(matches[a:], next, len(body)) {
+			ew.writeString(unit.line)
+		}
+	}
+	insert(len(body))
+	return ew.err
+}
+
+// replaced reports whether lines of the source, of size lines, are inserted from next before the next match of
+// matches
+func replaced(matches []int, next int, size int) bool {
+	for _, match := range matches {
+		if match >= 0 {
+			return match > next
+		}
+	}
+	return size > next
+}
+
+// templateUnit is a line of a template with the lines it's generated as, nil if it isn't generated as is
+// (directives, conditional blocks left out, lines that can't be generated on their own)
+type templateUnit struct {
+	line      string
+	generated []string
+}
+
+// templateUnits returns the lines of template with the lines they're generated as with values
+func (g *Generator) templateUnits(template []byte, values map[string]string) ([]templateUnit, error) {
+	kept := make(map[int]bool)
+	var pattern *regexp.Regexp
+	if g.Engine != GoTemplateEngine {
+		_, origins, err := selectBlocks(template, values)
+		if err != nil {
+			return nil, err
+		}
+		for _, origin := range origins {
+			kept[origin] = true
+		}
+		if pattern, err = g.placeholderPattern(); err != nil {
+			return nil, err
+		}
+	}
+
+	var units []templateUnit
+	scanner := newLineReader(bytes.NewReader(template))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		unit := templateUnit{line: scanner.Text()}
+		line, ending := splitLineEnding(unit.line)
+		var generated string
+		var err error
+		generatedAsIs := false
+		if g.Engine == GoTemplateEngine && !IsGenerateLine(line) && !isSettingLine(line) {
+			var buffer bytes.Buffer
+			err = g.executeTemplate(strings.NewReader(line), &buffer, values)
+			generated, generatedAsIs = buffer.String(), err == nil
+		} else if g.Engine != GoTemplateEngine && kept[lineNumber] {
+			generated, err = g.replacePlaceholders(pattern, line, values, nil, nil)
+			generatedAsIs = err == nil
+		}
+		if generatedAsIs {
+			unit.generated = splitLines(generated + ending)
+		}
+		units = append(units, unit)
+	}
+	return units, scanner.Err()
+}
+
+// alignLines returns, for each unit of units, the index of the line of lines it matches, -1 if it doesn't: the
+// longest sequence of units generated as lines of lines, in order
+func alignLines(units []templateUnit, lines []string) []int {
+	// matchAt reports whether the unit u is generated as the lines from l
+	matchAt := func(u int, l int) bool {
+		generated := units[u].generated
+		if l+len(generated) > len(lines) {
+			return false
+		}
+		for i, line := range generated {
+			if trimEnding(line) != trimEnding(lines[l+i]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	// Most lines are unchanged, the units matching from the start and the end aren't aligned
+	matches := make([]int, len(units))
+	start, l := 0, 0
+	for ; start < len(units) && matchAt(start, l); start++ {
+		matches[start] = l
+		l += len(units[start].generated)
+	}
+	end, endLine := len(units), len(lines)
+	for end > start && endLine-len(units[end-1].generated) >= l && matchAt(end-1, endLine-len(units[end-1].generated)) {
+		endLine -= len(units[end-1].generated)
+		matches[end-1] = endLine
+		end--
+	}
+
+	// lengths[u][i] is the length of the longest alignment of the units from start+u with the lines from l+i
+	n, m := end-start, endLine-l
+	lengths := make([][]int32, n+1)
+	for u := range lengths {
+		lengths[u] = make([]int32, m+1)
+	}
+	for u := n - 1; u >= 0; u-- {
+		for i := m - 1; i >= 0; i-- {
+			best := lengths[u+1][i]
+			if lengths[u][i+1] > best {
+				best = lengths[u][i+1]
+			}
+			if size := len(units[start+u].generated); i+size <= m && matchAt(start+u, l+i) && lengths[u+1][i+size]+1 > best {
+				best = lengths[u+1][i+size] + 1
+			}
+			lengths[u][i] = best
+		}
+	}
+	for u, i := 0, 0; u < n; {
+		size := len(units[start+u].generated)
+		switch {
+		case i < m && i+size <= m && matchAt(start+u, l+i) && lengths[u][i] == lengths[u+1][i+size]+1:
+			matches[start+u] = l + i
+			u, i = u+1, i+size
+		case i < m && lengths[u][i] == lengths[u][i+1]:
+			i++
+		default:
+			matches[start+u] = -1
+			u++
+		}
+	}
+	return matches
+}
+
+// generatedBody returns the lines of source, a generated source, without its header and //line directives
+func generatedBody(source []byte) []string {
+	lines := bytes.SplitAfter(source, []byte("\n"))
+	if start, end := headerRange(lines); start >= 0 {
+		lines = lines[end:]
+	}
+	body := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if len(line) > 0 && !lineDirectivePattern.Match(bytes.TrimRight(line, "\r\n")) {
+			body = append(body, string(line))
+		}
+	}
+	return body
+}
+
+// splitLines returns the lines of s with their ending
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// trimEnding returns line without its ending
+func trimEnding(line string) string {
+	line, _ = splitLineEnding(line)
+	return line
+}
+
+// placeholder returns the plain placeholder of key
+func (g *Generator) placeholder(key string) string {
+	if g.Engine == GoTemplateEngine {
+		return "{{ ." + key + " }}"
+	}
+	return g.prefix() + key + g.Suffix
+}
+
+// revertValues returns the placeholders of the keys of values by value, values being left out if empty. Keys with
+// the same value can't be told apart.
+func (g *Generator) revertValues(values map[string]string) (map[string]string, error) {
+	keys := make([]string, 0, len(values))
+	for key, value := range values {
+		if value != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	placeholders := make(map[string]string, len(keys))
+	keyOf := make(map[string]string, len(keys))
+	for _, key := range keys {
+		if other, ok := keyOf[values[key]]; ok {
+			return nil, fmt.Errorf("Keys [%s] and [%s] have the same value, it can't be reverted to either", other, key)
+		}
+		keyOf[values[key]] = key
+		placeholders[values[key]] = g.placeholder(key)
+	}
+	return placeholders, nil
+}
+
+// revertReplacer returns the replacer of values by the placeholder of their key wherever they appear, longer
+// values first so that a value containing another one is replaced whole
+func (g *Generator) revertReplacer(values map[string]string) (*strings.Replacer, error) {
+	placeholders, err := g.revertValues(values)
+	if err != nil {
+		return nil, err
+	}
+	sorted := make([]string, 0, len(placeholders))
+	for value := range placeholders {
+		sorted = append(sorted, value)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if len(sorted[i]) != len(sorted[j]) {
+			return len(sorted[i]) > len(sorted[j])
+		}
+		return sorted[i] < sorted[j]
+	})
+	pairs := make([]string, 0, 2*len(sorted))
+	for _, value := range sorted {
+		pairs = append(pairs, value, placeholders[value])
+	}
+	return strings.NewReplacer(pairs...), nil
+}
@@ -0,0 +1,82 @@
+package safekeeper
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRevert(t *testing.T) {
+	generated := "// GENERATED by safekeeper (https://github.com/alexandre-normand/safekeeper, DO NOT EDIT\n//go:generate safekeeper --keys=TOKEN,TOKEN_ID $GOFILE\npackage secrets\n\n// s3cr3t-id isn't replaced in comments\nconst (\n\ttoken = \"s3cr3t\"\n\ttokenId = \"s3cr3t-id\"\n)\n"
+
+	generator := Generator{Mode: ASTMode}
+	var output bytes.Buffer
+	if err := generator.Revert(strings.NewReader(generated), &output, map[string]string{"TOKEN": "s3cr3t", "TOKEN_ID": "s3cr3t-id", "EMPTY": ""}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "package secrets\n\n// s3cr3t-id isn't replaced in comments\nconst (\n\ttoken = \"ENV_TOKEN\"\n\ttokenId = \"ENV_TOKEN_ID\"\n)\n"
+	if output.String() != expected {
+		t.Errorf("Expected template to be [%s] but was [%s]", expected, output.String())
+	}
+}
+
+func TestRevertWithDelimiters(t *testing.T) {
+	generator := Generator{Prefix: "${", Suffix: "}"}
+	var output bytes.Buffer
+	if err := generator.Revert(strings.NewReader("url: https://api.example.com\n"), &output, map[string]string{"API_URL": "https://api.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "url: ${API_URL}\n"
+	if output.String() != expected {
+		t.Errorf("Expected template to be [%s] but was [%s]", expected, output.String())
+	}
+}
+
+func TestRevertSameValues(t *testing.T) {
+	var generator Generator
+	var output bytes.Buffer
+	err := generator.Revert(strings.NewReader("a\n"), &output, map[string]string{"A": "s3cr3t", "B": "s3cr3t"})
+	if err == nil || strings.Contains(err.Error(), "s3cr3t") {
+		t.Errorf("Expected an error without the value but got [%v]", err)
+	}
+}
+
+func TestRevertTemplate(t *testing.T) {
+	template := "package secrets\n\n//safekeeper:if DEBUG\nconst debug = true\n//safekeeper:endif\n\n// port is ENV_PORT\nconst port = \"ENV_PORT\"\nconst host = \"db:ENV_PORT\"\nconst maxRetries = 8\nconst name = \"app\"\n"
+	// An unedited generated file, its header having a build constraint, a TTL and a //line directive
+	generated := "//go:build prod\n\n// GENERATED by safekeeper (https://github.com/alexandre-normand/safekeeper, DO NOT EDIT\n// safekeeper:sha256=0123456789ab\n// safekeeper:generated=2026-10-15T02:00:55Z ttl=1h0m0s\n//go:generate safekeeper --keys=PORT --value-ttl=1h0m0s --line-directives --build-tags=prod $GOFILE\n//line secrets.go.safekeeper:1\npackage secrets\n\n//line secrets.go.safekeeper:6\n\n// port is 8\nconst port = \"8\"\nconst host = \"db:8\"\nconst maxRetries = 8\nconst name = \"app\"\n"
+
+	var generator Generator
+	var output bytes.Buffer
+	if err := generator.RevertTemplate(strings.NewReader(generated), []byte(template), &output, map[string]string{"PORT": "8"}); err != nil {
+		t.Fatal(err)
+	}
+	if output.String() != template {
+		t.Errorf("Expected the template of an unedited file to be kept as is [%s] but was [%s]", template, output.String())
+	}
+
+	// Edited and added lines are reverted, deleted ones left out
+	edited := strings.Replace(strings.Replace(generated, "const host = \"db:8\"\n", "const host = \"db2:8\"\nconst port2 = 8\n", 1), "const name = \"app\"\n", "", 1)
+	output.Reset()
+	if err := generator.RevertTemplate(strings.NewReader(edited), []byte(template), &output, map[string]string{"PORT": "8"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := "package secrets\n\n//safekeeper:if DEBUG\nconst debug = true\n//safekeeper:endif\n\n// port is ENV_PORT\nconst port = \"ENV_PORT\"\nconst host = \"db2:ENV_PORT\"\nconst port2 = ENV_PORT\nconst maxRetries = 8\n"
+	if output.String() != expected {
+		t.Errorf("Expected template to be [%s] but was [%s]", expected, output.String())
+	}
+}
+
+func TestRevertWholeWords(t *testing.T) {
+	var generator Generator
+	var output bytes.Buffer
+	if err := generator.Revert(strings.NewReader("const port = \"8\"\nconst max = 80\nconst v8 = \"host:8\"\n"), &output, map[string]string{"PORT": "8"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := "const port = \"ENV_PORT\"\nconst max = 80\nconst v8 = \"host:ENV_PORT\"\n"
+	if output.String() != expected {
+		t.Errorf("Expected template to be [%s] but was [%s]", expected, output.String())
+	}
+}
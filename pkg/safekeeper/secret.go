@@ -0,0 +1,86 @@
+package safekeeper
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Redacted is what a Secret prints as
+const Redacted = "[REDACTED]"
+
+// MinRedactedLength is the length of the shortest value replaced by NewRedactor, and looked for elsewhere. Values
+// are only replaced where they're whole words, so that 8080 doesn't garble 18080, but shorter values (e.g. 1 or
+// 80) would still replace line numbers and counts; they still print as Redacted as a Secret.
+const MinRedactedLength = 4
+
+// Secret is a resolved value that never prints itself: whatever the verb, formatting it, marshaling it as JSON or
+// text and String all give Redacted, so that a secret ending up in an error message, a log or a panic doesn't
+// leak. Reveal returns the value itself, for substitution.
+type Secret string
+
+// Reveal returns the value of s
+func (s Secret) Reveal() string {
+	return string(s)
+}
+
+func (s Secret) String() string {
+	return Redacted
+}
+
+func (s Secret) GoString() string {
+	return Redacted
+}
+
+// Format redacts s for all the verbs of the fmt package
+func (s Secret) Format(f fmt.State, verb rune) {
+	io.WriteString(f, Redacted)
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(Redacted)
+}
+
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(Redacted), nil
+}
+
+// Secrets returns values as secrets
+func Secrets(values map[string]string) map[string]Secret {
+	secrets := make(map[string]Secret, len(values))
+	for key, value := range values {
+		secrets[key] = Secret(value)
+	}
+	return secrets
+}
+
+// Reveal returns the values of secrets, for Generate
+func Reveal(secrets map[string]Secret) map[string]string {
+	values := make(map[string]string, len(secrets))
+	for key, secret := range secrets {
+		values[key] = secret.Reveal()
+	}
+	return values
+}
+
+// Redactor replaces the values of secrets by Redacted
+type Redactor struct {
+	replacer *wordReplacer
+}
+
+// NewRedactor returns the Redactor of the values of secrets of MinRedactedLength or more, replaced where they're
+// whole words, longer values first so that a value containing another one is redacted whole
+func NewRedactor(secrets map[string]Secret) *Redactor {
+	replacements := make(map[string]string, len(secrets))
+	for _, secret := range secrets {
+		if len(secret) >= MinRedactedLength {
+			replacements[secret.Reveal()] = Redacted
+		}
+	}
+	return &Redactor{replacer: newWordReplacer(replacements)}
+}
+
+// Replace returns s with the values of r redacted
+func (r *Redactor) Replace(s string) string {
+	return r.replacer.Replace(s)
+}
@@ -0,0 +1,46 @@
+package safekeeper
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSecretNeverPrints(t *testing.T) {
+	secret := Secret("s3nt1nel")
+	wrapped := struct {
+		Token Secret
+	}{secret}
+
+	for _, printed := range []string{
+		fmt.Sprint(secret), fmt.Sprintf("%s %v %q %x %d %+v %#v", secret, secret, secret, secret, secret, wrapped, wrapped),
+		fmt.Errorf("Failed with %s", secret).Error(), secret.String(),
+	} {
+		if strings.Contains(printed, "s3nt1nel") || !strings.Contains(printed, Redacted) {
+			t.Errorf("Expected the secret to be redacted but got [%s]", printed)
+		}
+	}
+	if marshaled, err := json.Marshal(wrapped); err != nil || strings.Contains(string(marshaled), "s3nt1nel") {
+		t.Errorf("Expected the secret to be redacted from JSON but got [%s] (%v)", string(marshaled), err)
+	}
+	if secret.Reveal() != "s3nt1nel" {
+		t.Errorf("Expected Reveal to give the value back")
+	}
+}
+
+func TestRedactor(t *testing.T) {
+	redactor := NewRedactor(map[string]Secret{"SHORT": "abcdef", "LONG": "abcdefghi", "EMPTY": ""})
+	if redacted := redactor.Replace("abcdefghi then abcdef"); redacted != Redacted+" then "+Redacted {
+		t.Errorf("Expected the longer value to be redacted whole but got [%s]", redacted)
+	}
+}
+
+func TestRedactorShortValues(t *testing.T) {
+	redactor := NewRedactor(map[string]Secret{"PORT": "1", "PIN": "4711", "HTTP_PORT": "8080"})
+	message := "Unreplaced placeholders: [TYPO] at line 15 in nonexist_dir_1/x.go, 1 generated files are out of date (pin 4711, port 8080 or 18080)"
+	expected := "Unreplaced placeholders: [TYPO] at line 15 in nonexist_dir_1/x.go, 1 generated files are out of date (pin " + Redacted + ", port " + Redacted + " or 18080)"
+	if redacted := redactor.Replace(message); redacted != expected {
+		t.Errorf("Expected short values to be redacted where they're whole words, leaving line numbers and counts intact, but got [%s]", redacted)
+	}
+}
@@ -0,0 +1,91 @@
+package safekeeper
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+)
+
+// settingPattern matches the lines of the file settings of a template, commented in its syntax (e.g.
+// //safekeeper:key STRIPE_KEY required or #safekeeper:output app.yaml), the setting and its arguments being the
+// submatches
+var settingPattern = regexp.MustCompile(`^\s*(?:[^\w\s]*\s*)?safekeeper:(key|output)\b(.*)$`)
+
+// keyPattern matches the name of a key
+var keyPattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// FileSettings are the settings a template declares for the file generated from it with comment directives, so
+// that they live with the template rather than in go:generate lines. Directive lines are left out of generated
+// files.
+//
+//	//safekeeper:key STRIPE_KEY required
+//	//safekeeper:key SENTRY_DSN optional
+//	//safekeeper:output secrets_gen.go
+type FileSettings struct {
+	// Keys are the keys declared by safekeeper:key KEY directives, in order, whether the template has placeholders
+	// of them or not
+	Keys []string
+	// Required are the keys declared with required, which must have a value even if their placeholders have a
+	// default, and Optional the ones declared with optional, replaced by an empty string when they have no value
+	Required map[string]bool
+	Optional map[string]bool
+	// Output is the file generated from the template declared by safekeeper:output, relative to the directory of
+	// the template, if any
+	Output string
+}
+
+// ReadFileSettings returns the settings declared by the directives of the template read from src
+func ReadFileSettings(src io.Reader) (FileSettings, error) {
+	settings := FileSettings{Required: make(map[string]bool), Optional: make(map[string]bool)}
+	source, err := ioutil.ReadAll(src)
+	if err != nil {
+		return settings, err
+	}
+
+	scanner := newLineReader(bytes.NewReader(source))
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		match := settingPattern.FindStringSubmatch(strings.TrimRight(scanner.Text(), "\r\n"))
+		if match == nil {
+			continue
+		}
+		args := strings.Fields(settingArgs(match[2]))
+
+		switch match[1] {
+		case "key":
+			if len(args) == 0 || len(args) > 2 || !keyPattern.MatchString(args[0]) || (len(args) == 2 && args[1] != "required" && args[1] != "optional") {
+				return settings, fmt.Errorf("Invalid safekeeper:key at line %d, expected safekeeper:key KEY [required|optional]", lineNumber)
+			}
+			settings.Keys = append(settings.Keys, args[0])
+			if len(args) == 2 && args[1] == "required" {
+				settings.Required[args[0]] = true
+			} else if len(args) == 2 {
+				settings.Optional[args[0]] = true
+			}
+		case "output":
+			if len(args) != 1 {
+				return settings, fmt.Errorf("Invalid safekeeper:output at line %d, expected safekeeper:output NAME", lineNumber)
+			}
+			if settings.Output != "" {
+				return settings, fmt.Errorf("Duplicate safekeeper:output at line %d", lineNumber)
+			}
+			settings.Output = args[0]
+		}
+	}
+	return settings, scanner.Err()
+}
+
+// settingArgs returns the arguments of a setting directive, without the end of a block comment (e.g. --> or */)
+func settingArgs(args string) string {
+	for _, end := range []string{"-->", "*/"} {
+		args = strings.TrimSuffix(strings.TrimSpace(args), end)
+	}
+	return args
+}
+
+// isSettingLine reports whether line is the directive of a file setting, see FileSettings
+func isSettingLine(line string) bool {
+	return settingPattern.MatchString(strings.TrimRight(line, "\r\n"))
+}
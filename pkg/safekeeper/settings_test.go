@@ -0,0 +1,43 @@
+package safekeeper
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFileSettings(t *testing.T) {
+	template := "package secrets\n\n//safekeeper:key STRIPE_KEY required\n// safekeeper:key SENTRY_DSN optional\n//safekeeper:key API_URL\n//safekeeper:output secrets_gen.go\nconst key = \"ENV_STRIPE_KEY:-sk_test\"\nvar usage = fmt.Sprint(\"safekeeper:output\")\n"
+
+	settings, err := ReadFileSettings(strings.NewReader(template))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(settings.Keys, []string{"STRIPE_KEY", "SENTRY_DSN", "API_URL"}) || !settings.Required["STRIPE_KEY"] || !settings.Optional["SENTRY_DSN"] || settings.Required["API_URL"] || settings.Optional["API_URL"] {
+		t.Errorf("Unexpected keys %+v", settings)
+	}
+	if settings.Output != "secrets_gen.go" {
+		t.Errorf("Expected output [secrets_gen.go] but got [%s]", settings.Output)
+	}
+
+	var generator Generator
+	var output bytes.Buffer
+	if err := generator.Generate(strings.NewReader(template), &output, map[string]string{"STRIPE_KEY": "sk_live"}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "package secrets\n\nconst key = \"sk_live\"\nvar usage = fmt.Sprint(\"safekeeper:output\")\n"; output.String() != expected {
+		t.Errorf("Expected the directives to be left out of [%s] but got [%s]", expected, output.String())
+	}
+
+	yaml, err := ReadFileSettings(strings.NewReader("# safekeeper:output app.yaml\n<!-- safekeeper:key TOKEN required -->\n"))
+	if err != nil || yaml.Output != "app.yaml" || !yaml.Required["TOKEN"] {
+		t.Errorf("Expected settings in other comment syntaxes to be read but got %+v (%v)", yaml, err)
+	}
+
+	for _, invalid := range []string{"//safekeeper:key\n", "//safekeeper:key TOKEN maybe\n", "//safekeeper:key ENV-TOKEN\n", "//safekeeper:output\n", "//safekeeper:output a.go\n//safekeeper:output b.go\n"} {
+		if _, err := ReadFileSettings(strings.NewReader(invalid)); err == nil || !strings.Contains(err.Error(), "line") {
+			t.Errorf("Expected [%s] to be invalid but got [%v]", invalid, err)
+		}
+	}
+}
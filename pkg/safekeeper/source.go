@@ -0,0 +1,37 @@
+package safekeeper
+
+// SecretSource resolves the values of keys from a backing store (process environment, dotenv file, secret manager,
+// etc.)
+type SecretSource interface {
+	// Resolve returns the value of key and whether it was found. A non-nil error means the source itself failed
+	// (as opposed to the key simply being absent).
+	Resolve(key string) (value string, found bool, err error)
+}
+
+// chain is a SecretSource resolving keys with each of its sources in order
+type chain []SecretSource
+
+// Chain returns a SecretSource that resolves a key with the first of sources that has it. An error from a source
+// stops the resolution.
+func Chain(sources ...SecretSource) SecretSource {
+	return chain(sources)
+}
+
+func (c chain) Resolve(key string) (string, bool, error) {
+	for _, source := range c {
+		value, found, err := source.Resolve(key)
+		if err != nil || found {
+			return value, found, err
+		}
+	}
+
+	return "", false, nil
+}
+
+// MapSource is a SecretSource resolving keys from a fixed set of values
+type MapSource map[string]string
+
+func (s MapSource) Resolve(key string) (string, bool, error) {
+	value, found := s[key]
+	return value, found, nil
+}
@@ -0,0 +1,43 @@
+package safekeeper
+
+import (
+	"errors"
+	"testing"
+)
+
+// failingSource is a SecretSource that always fails
+type failingSource struct{}
+
+func (failingSource) Resolve(key string) (string, bool, error) {
+	return "", false, errors.New("backend down")
+}
+
+func TestChainFirstMatchWins(t *testing.T) {
+	source := Chain(MapSource{"A": "first"}, MapSource{"A": "second", "B": "second"}, MapSource{"C": "third"})
+
+	for key, expected := range map[string]string{"A": "first", "B": "second", "C": "third"} {
+		value, found, err := source.Resolve(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !found || value != expected {
+			t.Errorf("Expected [%s] for [%s] but got [%s] (found: %t)", expected, key, value, found)
+		}
+	}
+
+	if _, found, _ := source.Resolve("D"); found {
+		t.Errorf("Key absent from all sources should not be found")
+	}
+}
+
+func TestChainStopsOnError(t *testing.T) {
+	source := Chain(MapSource{"A": "first"}, failingSource{}, MapSource{"B": "third"})
+
+	if value, _, err := source.Resolve("A"); err != nil || value != "first" {
+		t.Errorf("Key found before the failing source should resolve but got [%s] (%v)", value, err)
+	}
+
+	if _, _, err := source.Resolve("B"); err == nil {
+		t.Errorf("Expected the error of the failing source to stop the resolution")
+	}
+}
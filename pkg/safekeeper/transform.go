@@ -0,0 +1,163 @@
+package safekeeper
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// transformsMu guards transforms, which RegisterTransform adds to
+var transformsMu sync.RWMutex
+
+// builtinTransforms are the names of the transforms that can't be replaced with RegisterTransform
+var builtinTransforms = map[string]bool{"base64": true, "base64decode": true, "hex": true, "hexdecode": true, "json-escape": true, "url-encode": true}
+
+// transformNamePattern is the pattern of the names of registered transforms
+var transformNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// TransformFunc is a transform of values, failing if a value can't go through it
+type TransformFunc func(value string) (string, error)
+
+// transforms are the functions placeholder values can go through, by name
+var transforms = map[string]TransformFunc{
+	"base64": func(value string) (string, error) {
+		return base64.StdEncoding.EncodeToString([]byte(value)), nil
+	},
+	"base64decode": func(value string) (string, error) {
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		return string(decoded), err
+	},
+	"hex": func(value string) (string, error) {
+		return hex.EncodeToString([]byte(value)), nil
+	},
+	"hexdecode": func(value string) (string, error) {
+		decoded, err := hex.DecodeString(value)
+		return string(decoded), err
+	},
+	"json-escape": func(value string) (string, error) {
+		var buffer bytes.Buffer
+		encoder := json.NewEncoder(&buffer)
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(value); err != nil {
+			return "", err
+		}
+		// The encoded string is quoted and followed by a newline
+		encoded := strings.TrimSuffix(buffer.String(), "\n")
+		return encoded[1 : len(encoded)-1], nil
+	},
+	"url-encode": func(value string) (string, error) {
+		return url.QueryEscape(value), nil
+	},
+}
+
+// RegisterTransform makes transform available to placeholders and Generator.Transforms under name, replacing the
+// transform registered under it before, if any. Names are made of lowercase letters, digits and dashes, and the
+// builtin transforms can't be replaced. Generators read the transforms when generating, so they're registered
+// before. Transforms registered this way are shared by all generators, see WithTransformFuncs for the ones of a
+// generator.
+func RegisterTransform(name string, transform func(value string) (string, error)) error {
+	if !transformNamePattern.MatchString(name) {
+		return fmt.Errorf("Invalid transform name [%s], expected lowercase letters, digits and dashes", name)
+	}
+	if builtinTransforms[name] {
+		return fmt.Errorf("Transform [%s] is builtin and can't be replaced", name)
+	}
+
+	transformsMu.Lock()
+	defer transformsMu.Unlock()
+	transforms[name] = transform
+	return nil
+}
+
+// TransformNames returns the sorted names of the transforms placeholder values can go through
+func TransformNames() []string {
+	transformsMu.RLock()
+	defer transformsMu.RUnlock()
+	names := make([]string, 0, len(transforms))
+	for name := range transforms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// transformNames returns the sorted names of the transforms placeholder values can go through with g
+func (g *Generator) transformNames() []string {
+	names := TransformNames()
+	for name := range g.TransformFuncs {
+		if _, ok := transformFunc(name); !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// transformsPattern returns the pattern of the |<transform> chain of a placeholder, failing on invalid names of
+// g.TransformFuncs
+func (g *Generator) transformsPattern() (string, error) {
+	for name := range g.TransformFuncs {
+		if !transformNamePattern.MatchString(name) {
+			return "", fmt.Errorf("Invalid transform name [%s], expected lowercase letters, digits and dashes", name)
+		}
+	}
+	names := g.transformNames()
+	// Longer names first so that base64decode isn't matched as base64
+	sort.SliceStable(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+	for i, name := range names {
+		names[i] = regexp.QuoteMeta(name)
+	}
+	return "((?:\\|(?:" + strings.Join(names, "|") + "))*)", nil
+}
+
+// parseTransforms returns the names of a|b chain of transforms, failing on unknown ones
+func (g *Generator) parseTransforms(chain string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(chain, "|") {
+		if name == "" {
+			continue
+		}
+		if _, ok := g.transformFunc(name); !ok {
+			return nil, fmt.Errorf("Unknown transform [%s], expected one of %s", name, strings.Join(g.transformNames(), ", "))
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// applyTransforms returns the value of key passed through the named transforms, in order. Failures don't
+// quote the value since it's a secret.
+func (g *Generator) applyTransforms(key string, value string, names []string) (string, error) {
+	for _, name := range names {
+		transform, _ := g.transformFunc(name)
+		transformed, err := transform(value)
+		if err != nil {
+			return "", fmt.Errorf("Value of [%s] can't go through transform [%s]", key, name)
+		}
+		value = transformed
+	}
+	return value, nil
+}
+
+// transformFunc returns the transform of g or else the one registered under name
+func (g *Generator) transformFunc(name string) (TransformFunc, bool) {
+	if transform, ok := g.TransformFuncs[name]; ok && !builtinTransforms[name] {
+		return transform, true
+	}
+	return transformFunc(name)
+}
+
+// transformFunc returns the transform registered under name
+func transformFunc(name string) (TransformFunc, bool) {
+	transformsMu.RLock()
+	defer transformsMu.RUnlock()
+	transform, ok := transforms[name]
+	return transform, ok
+}
@@ -0,0 +1,110 @@
+package safekeeper
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTransforms(t *testing.T) {
+	cases := []struct {
+		transform string
+		value     string
+		expected  string
+	}{
+		{"base64", "secret", "c2VjcmV0"},
+		{"base64decode", "c2VjcmV0", "secret"},
+		{"hex", "key", "6b6579"},
+		{"hexdecode", "6b6579", "key"},
+		{"json-escape", "line\n\"<quoted>\"", "line\\n\\\"<quoted>\\\""},
+		{"url-encode", "p@ss word&", "p%40ss+word%26"},
+	}
+
+	for _, c := range cases {
+		transformed, err := (&Generator{}).applyTransforms("KEY", c.value, []string{c.transform})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if transformed != c.expected {
+			t.Errorf("Expected [%s] through %s to be [%s] but was [%s]", c.value, c.transform, c.expected, transformed)
+		}
+	}
+}
+
+func TestGenerateWithTransforms(t *testing.T) {
+	template := "const (\n\tcert = \"ENV_CERT|base64decode|json-escape\"\n\turl = \"ENV_URL|url-encode:-a&b\"\n\ttoken = \"ENV_TOKEN\"\n)\n"
+
+	generator := Generator{Transforms: map[string]string{"TOKEN": "hex"}}
+	var output bytes.Buffer
+	values := map[string]string{"CERT": "LS0tLS1CRUdJTgpkYXRhCg==", "TOKEN": "tk"}
+	if err := generator.Generate(strings.NewReader(template), &output, values); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "const (\n\tcert = \"-----BEGIN\\ndata\\n\"\n\turl = \"a%26b\"\n\ttoken = \"746b\"\n)\n"
+	if output.String() != expected {
+		t.Errorf("Expected generated source to be [%s] but was [%s]", expected, output.String())
+	}
+}
+
+func TestGenerateTransformFailure(t *testing.T) {
+	var generator Generator
+	var output bytes.Buffer
+	err := generator.Generate(strings.NewReader("const cert = \"ENV_CERT|base64decode\"\n"), &output, map[string]string{"CERT": "not base64!"})
+	if err == nil || err.Error() != "Value of [CERT] can't go through transform [base64decode]" {
+		t.Errorf("Expected the failed transform to be reported without the value but got [%v]", err)
+	}
+}
+
+func TestUnknownKeyTransform(t *testing.T) {
+	generator := Generator{Transforms: map[string]string{"CERT": "rot13"}}
+	if err := generator.Generate(strings.NewReader(""), &bytes.Buffer{}, nil); err == nil {
+		t.Error("Expected an unknown transform to be rejected")
+	}
+}
+
+func TestFindPlaceholdersWithTransforms(t *testing.T) {
+	var generator Generator
+	placeholders, err := generator.FindPlaceholders(strings.NewReader("cert := \"ENV_CERT|base64decode|json-escape:-\"\nflag := \"ENV_FLAG|unknown\"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Placeholder{
+		{Key: "CERT", Transforms: []string{"base64decode", "json-escape"}, HasDefault: true, Line: 1},
+		{Key: "FLAG", Line: 2},
+	}
+	if !reflect.DeepEqual(placeholders, expected) {
+		t.Errorf("Expected placeholders %+v but got %+v", expected, placeholders)
+	}
+}
+
+func TestRegisterTransform(t *testing.T) {
+	reverse := func(value string) (string, error) {
+		reversed := []rune(value)
+		for i, j := 0, len(reversed)-1; i < j; i, j = i+1, j-1 {
+			reversed[i], reversed[j] = reversed[j], reversed[i]
+		}
+		return string(reversed), nil
+	}
+	if err := RegisterTransform("test-reverse", reverse); err != nil {
+		t.Fatal(err)
+	}
+
+	var generator Generator
+	var output bytes.Buffer
+	if err := generator.Generate(strings.NewReader("const token = \"ENV_TOKEN|test-reverse|hex\"\n"), &output, map[string]string{"TOKEN": "ab"}); err != nil {
+		t.Fatal(err)
+	}
+	if output.String() != "const token = \"6261\"\n" {
+		t.Errorf("Expected the registered transform to apply but got [%s]", output.String())
+	}
+
+	if err := RegisterTransform("base64", reverse); err == nil {
+		t.Error("Expected a builtin transform not to be replaced")
+	}
+	if err := RegisterTransform("Not a name", reverse); err == nil {
+		t.Error("Expected an invalid transform name to be rejected")
+	}
+}
@@ -0,0 +1,47 @@
+package safekeeper
+
+import (
+	"bytes"
+	"time"
+)
+
+// generatedAtMarker starts the time a file was generated at, followed by how long its values are valid, in a
+// comment of its header
+const generatedAtMarker = "safekeeper:generated="
+
+// ttlMarker starts how long the values of a file are valid, following its generation time
+const ttlMarker = "ttl="
+
+// AddTTL returns the generated source src with the time it's generated at and ttl, how long its values are valid
+// (e.g. short-lived tokens), in a comment following the code generation warning like AddVersion:
+// safekeeper:generated=2026-10-15T10:00:00Z ttl=1h0m0s. src is returned as is if it has no code generation warning.
+func AddTTL(src []byte, generated time.Time, ttl time.Duration) []byte {
+	commented, _, _ := addNoticeComment(src, generatedAtMarker+generated.UTC().Format(time.RFC3339)+" "+ttlMarker+ttl.String())
+	return commented
+}
+
+// ReadTTL returns the generation time and the time to live of the values recorded in content by AddTTL, ok being
+// false if there are none
+func ReadTTL(content []byte) (generated time.Time, ttl time.Duration, ok bool) {
+	for _, line := range bytes.Split(content, []byte("\n")) {
+		i := bytes.Index(line, []byte(generatedAtMarker))
+		if i < 0 {
+			continue
+		}
+
+		fields := bytes.Fields(line[i+len(generatedAtMarker):])
+		if len(fields) < 2 || !bytes.HasPrefix(fields[1], []byte(ttlMarker)) {
+			return time.Time{}, 0, false
+		}
+		generated, err := time.Parse(time.RFC3339, string(fields[0]))
+		if err != nil {
+			return time.Time{}, 0, false
+		}
+		ttl, err := time.ParseDuration(string(bytes.TrimPrefix(fields[1], []byte(ttlMarker))))
+		if err != nil {
+			return time.Time{}, 0, false
+		}
+		return generated, ttl, true
+	}
+	return time.Time{}, 0, false
+}
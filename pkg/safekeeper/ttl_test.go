@@ -0,0 +1,29 @@
+package safekeeper
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTTL(t *testing.T) {
+	generated := generatedComment + "//go:generate safekeeper $GOFILE\npackage secrets\n"
+	at := time.Date(2026, 10, 15, 10, 0, 0, 0, time.UTC)
+
+	src := AddTTL([]byte(generated), at, time.Hour)
+	lines := strings.Split(string(src), "\n")
+	if lines[1] != "// "+generatedAtMarker+"2026-10-15T10:00:00Z "+ttlMarker+"1h0m0s" {
+		t.Fatalf("Expected the generation time and TTL to follow the code generation warning but got [%s]", string(src))
+	}
+	if recorded, ttl, ok := ReadTTL(src); !ok || !recorded.Equal(at) || ttl != time.Hour {
+		t.Errorf("Expected the time and TTL back but got %s, %s (%t)", recorded, ttl, ok)
+	}
+
+	yaml := AddTTL([]byte("# "+generatedNotice+"\r\ntoken: s3cr3t\r\n"), at, 30*time.Minute)
+	if recorded, ttl, ok := ReadTTL(yaml); !ok || !recorded.Equal(at) || ttl != 30*time.Minute {
+		t.Errorf("Expected the time and TTL back from a CRLF file but got %s, %s (%t)", recorded, ttl, ok)
+	}
+	if _, _, ok := ReadTTL([]byte(generated)); ok {
+		t.Errorf("Expected no TTL in a file without one")
+	}
+}
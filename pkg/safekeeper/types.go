@@ -0,0 +1,204 @@
+package safekeeper
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// typeLiterals return the Go literal of a value of their type, by type name
+var typeLiterals = map[string]func(value string) (string, error){
+	"int": func(value string) (string, error) {
+		n, err := strconv.ParseInt(value, 0, 64)
+		return strconv.FormatInt(n, 10), err
+	},
+	"bool": func(value string) (string, error) {
+		b, err := strconv.ParseBool(value)
+		return strconv.FormatBool(b), err
+	},
+	"float": func(value string) (string, error) {
+		f, err := strconv.ParseFloat(value, 64)
+		if err == nil && (math.IsInf(f, 0) || math.IsNaN(f)) {
+			return "", fmt.Errorf("%v has no Go literal", f)
+		}
+		return strconv.FormatFloat(f, 'g', -1, 64), err
+	},
+	// Durations are written in nanoseconds, for time.Duration constants
+	"duration": func(value string) (string, error) {
+		d, err := time.ParseDuration(value)
+		return strconv.FormatInt(int64(d), 10), err
+	},
+}
+
+const (
+	// listType is the type of the keys whose value is a delimited list, written as a []string literal
+	listType = "list"
+	// mapType is the type of the keys whose value is a delimited list of NAME=VALUE pairs, written as a
+	// map[string]string literal
+	mapType = "map"
+)
+
+// DefaultListDelimiter is the delimiter of the elements of list values unless configured otherwise
+const DefaultListDelimiter = ","
+
+// TypeNames returns the sorted names of the types keys can be declared with in Generator.Types
+func TypeNames() []string {
+	names := []string{listType, mapType}
+	for name := range typeLiterals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// isTyped reports whether values of type typeName are written as something else than a string literal
+func isTyped(typeName string) bool {
+	return typeLiterals[typeName] != nil || typeName == listType || typeName == mapType
+}
+
+// typeLiteral returns the Go literal of the value of key, of type typeName
+func (g *Generator) typeLiteral(key string, typeName string, value string) (string, error) {
+	switch typeName {
+	case listType:
+		return g.listLiteral(value), nil
+	case mapType:
+		return g.mapLiteral(key, value)
+	}
+	literal, err := typeLiterals[typeName](strings.TrimSpace(value))
+	if err != nil {
+		return "", fmt.Errorf("Value of [%s] isn't a valid %s", key, typeName)
+	}
+	return literal, nil
+}
+
+// listDelimiter returns the configured list delimiter or DefaultListDelimiter
+func (g *Generator) listDelimiter() string {
+	if g.ListDelimiter == "" {
+		return DefaultListDelimiter
+	}
+	return g.ListDelimiter
+}
+
+// listLiteral returns the []string literal of the elements of value
+func (g *Generator) listLiteral(value string) string {
+	elements := g.splitList(value)
+	for i, element := range elements {
+		elements[i] = strconv.Quote(element)
+	}
+	return "[]string{" + strings.Join(elements, ", ") + "}"
+}
+
+// mapLiteral returns the map[string]string literal of the NAME=VALUE elements of the value of key, sorted by name
+func (g *Generator) mapLiteral(key string, value string) (string, error) {
+	pairs := g.splitList(value)
+	for i, pair := range pairs {
+		separator := strings.Index(pair, "=")
+		if separator < 0 {
+			return "", fmt.Errorf("Value of [%s] isn't a valid %s, expected NAME=VALUE elements", key, mapType)
+		}
+		pairs[i] = strconv.Quote(pair[:separator]) + ": " + strconv.Quote(pair[separator+1:])
+	}
+	sort.Strings(pairs)
+	return "map[string]string{" + strings.Join(pairs, ", ") + "}", nil
+}
+
+// splitList returns the elements of value, separated by the list delimiter. Elements are trimmed, a delimiter
+// escaped with a backslash (\,) or a backslash escaped with another one (\\) being part of its element. An empty
+// value has no elements.
+func (g *Generator) splitList(value string) []string {
+	delimiter := g.listDelimiter()
+	var elements []string
+	var element strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch {
+		case value[i] == '\\' && strings.HasPrefix(value[i+1:], delimiter):
+			element.WriteString(delimiter)
+			i += len(delimiter)
+		case value[i] == '\\' && strings.HasPrefix(value[i+1:], "\\"):
+			element.WriteByte('\\')
+			i++
+		case strings.HasPrefix(value[i:], delimiter):
+			elements = append(elements, strings.TrimSpace(element.String()))
+			element.Reset()
+			i += len(delimiter) - 1
+		default:
+			element.WriteByte(value[i])
+		}
+	}
+	if strings.TrimSpace(value) != "" {
+		elements = append(elements, strings.TrimSpace(element.String()))
+	}
+	return elements
+}
+
+// EscapeListElement returns element escaped for a list value delimited by delimiter, DefaultListDelimiter if
+// empty, so that it's split back whole
+func EscapeListElement(element string, delimiter string) string {
+	if delimiter == "" {
+		delimiter = DefaultListDelimiter
+	}
+	element = strings.Replace(element, "\\", "\\\\", -1)
+	return strings.Replace(element, delimiter, "\\"+delimiter, -1)
+}
+
+// checkTypes fails if g.Types has unknown types
+func (g *Generator) checkTypes() error {
+	for key, typeName := range g.Types {
+		if !isTyped(typeName) && typeName != "string" {
+			return fmt.Errorf("Unknown type [%s] of key [%s], expected string or one of %s", typeName, key, strings.Join(TypeNames(), ", "))
+		}
+	}
+	return nil
+}
+
+// typedValues returns values with the ones of the keys of g.Types replaced by their Go literal, failing on the
+// ones that don't parse as their type
+func (g *Generator) typedValues(values map[string]string) (map[string]string, error) {
+	typed := make(map[string]string, len(values))
+	for key, value := range values {
+		typed[key] = value
+		if typeName := g.Types[key]; isTyped(typeName) {
+			literal, err := g.typeLiteral(key, typeName, value)
+			if err != nil {
+				return nil, err
+			}
+			typed[key] = literal
+		}
+	}
+	return typed, nil
+}
+
+// unquoteTypedLiterals returns source with the string literals made of a single placeholder of a typed key (e.g.
+// "ENV_MAX_CONNS") replaced by the Go literal of its value (50), so that templates can be valid Go whatever the
+// mode. Literals of keys without value nor default are left as is.
+func (g *Generator) unquoteTypedLiterals(pattern *regexp.Regexp, source []byte, values map[string]string, keyTransforms map[string][]string) ([]byte, error) {
+	literalPattern, err := regexp.Compile("\"" + pattern.String() + "\"|`" + pattern.String() + "`")
+	if err != nil {
+		return nil, err
+	}
+
+	var failure error
+	unquoted := literalPattern.ReplaceAllFunc(source, func(literal []byte) []byte {
+		placeholder := string(literal[1 : len(literal)-1])
+		match := pattern.FindStringSubmatch(placeholder)
+		typeName := g.Types[match[1]]
+		if !isTyped(typeName) || failure != nil {
+			return literal
+		}
+
+		value, err := g.replacePlaceholders(pattern, placeholder, values, keyTransforms, nil)
+		if err == nil && value == placeholder {
+			return literal
+		}
+		if err == nil {
+			value, err = g.typeLiteral(match[1], typeName, value)
+		}
+		failure = err
+		return []byte(value)
+	})
+	return unquoted, failure
+}
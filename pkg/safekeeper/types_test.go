@@ -0,0 +1,73 @@
+package safekeeper
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestGenerateTypedValues(t *testing.T) {
+	template := "const (\n\tmaxConns = \"ENV_MAX_CONNS\"\n\tdebug = `ENV_DEBUG`\n\tratio = \"ENV_RATIO:-0.5\"\n\ttimeout = \"ENV_TIMEOUT\"\n\turl = \"ENV_HOST:ENV_MAX_CONNS\"\n)\n"
+
+	generator := Generator{Types: map[string]string{"MAX_CONNS": "int", "DEBUG": "bool", "RATIO": "float", "TIMEOUT": "duration", "HOST": "string"}}
+	var output bytes.Buffer
+	values := map[string]string{"MAX_CONNS": "0x32", "DEBUG": "1", "TIMEOUT": "1.5s", "HOST": "db"}
+	if err := generator.Generate(strings.NewReader(template), &output, values); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "const (\n\tmaxConns = 50\n\tdebug = true\n\tratio = 0.5\n\ttimeout = 1500000000\n\turl = \"db:50\"\n)\n"
+	if output.String() != expected {
+		t.Errorf("Expected generated source to be [%s] but was [%s]", expected, output.String())
+	}
+}
+
+func TestGenerateInvalidTypedValue(t *testing.T) {
+	generator := Generator{Types: map[string]string{"MAX_CONNS": "int"}}
+	err := generator.Generate(strings.NewReader("const maxConns = \"ENV_MAX_CONNS\"\n"), &bytes.Buffer{}, map[string]string{"MAX_CONNS": "fifty"})
+	if err == nil || err.Error() != "Value of [MAX_CONNS] isn't a valid int" {
+		t.Errorf("Expected the invalid value to be reported without the value but got [%v]", err)
+	}
+}
+
+func TestUnknownType(t *testing.T) {
+	generator := Generator{Types: map[string]string{"MAX_CONNS": "uint"}}
+	if err := generator.Generate(strings.NewReader(""), &bytes.Buffer{}, nil); err == nil {
+		t.Error("Expected an unknown type to be rejected")
+	}
+}
+
+func TestGenerateListValues(t *testing.T) {
+	template := "var (\n\torigins = \"ENV_ALLOWED_ORIGINS\"\n\tpaths = \"ENV_PATHS\"\n\tnone = \"ENV_NONE\"\n)\n"
+
+	generator := Generator{Types: map[string]string{"ALLOWED_ORIGINS": "list", "PATHS": "list", "NONE": "list"}, ListDelimiter: ";"}
+	var output bytes.Buffer
+	values := map[string]string{"ALLOWED_ORIGINS": "a.com; b.com", "PATHS": "C:\\\\dir;a\\;b;\"quoted\"", "NONE": ""}
+	if err := generator.Generate(strings.NewReader(template), &output, values); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "var (\n\torigins = []string{\"a.com\", \"b.com\"}\n\tpaths = []string{\"C:\\\\dir\", \"a;b\", \"\\\"quoted\\\"\"}\n\tnone = []string{}\n)\n"
+	if output.String() != expected {
+		t.Errorf("Expected generated source to be [%s] but was [%s]", expected, output.String())
+	}
+}
+
+func TestGenerateMapValues(t *testing.T) {
+	generator := Generator{Types: map[string]string{"FEATURES": "map"}}
+	var output bytes.Buffer
+	value := "FEATURE_B=on," + EscapeListElement("FEATURE_A=x,y", "")
+	if err := generator.Generate(strings.NewReader("var features = \"ENV_FEATURES\"\n"), &output, map[string]string{"FEATURES": value}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "var features = map[string]string{\"FEATURE_A\": \"x,y\", \"FEATURE_B\": \"on\"}\n"
+	if output.String() != expected {
+		t.Errorf("Expected generated source to be [%s] but was [%s]", expected, output.String())
+	}
+
+	err := generator.Generate(strings.NewReader("var features = \"ENV_FEATURES\"\n"), &bytes.Buffer{}, map[string]string{"FEATURES": "on"})
+	if err == nil || err.Error() != "Value of [FEATURES] isn't a valid map, expected NAME=VALUE elements" {
+		t.Errorf("Expected the invalid map to be reported but got [%v]", err)
+	}
+}
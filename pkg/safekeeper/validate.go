@@ -0,0 +1,93 @@
+package safekeeper
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+)
+
+// keyStartPattern matches a line starting with the characters of a key
+var keyStartPattern = regexp.MustCompile(`^[A-Za-z0-9_]`)
+
+// CheckBlocks fails if the conditional blocks of the template read from src aren't balanced or have a condition
+// without a key, whatever the values of their keys
+func CheckBlocks(src io.Reader) error {
+	source, err := ioutil.ReadAll(src)
+	if err != nil {
+		return err
+	}
+	_, _, err = selectBlocks(source, nil)
+	return err
+}
+
+// UnreplacedPlaceholders returns the placeholders of the template read from src, at its lines, that generating it
+// with values leaves as they are: the ones without a default of the keys without a value, on the lines kept by
+// conditional blocks. Templates of GoTemplateEngine have none, keys without a value being empty.
+func (g *Generator) UnreplacedPlaceholders(src io.Reader, values map[string]string) ([]Placeholder, error) {
+	if g.Engine == GoTemplateEngine {
+		return nil, nil
+	}
+	template, err := ioutil.ReadAll(src)
+	if err != nil {
+		return nil, err
+	}
+	_, origins, err := selectBlocks(template, values)
+	if err != nil {
+		return nil, err
+	}
+	kept := make(map[int]bool, len(origins))
+	for _, origin := range origins {
+		kept[origin] = true
+	}
+	placeholders, err := g.FindPlaceholders(bytes.NewReader(template))
+	if err != nil {
+		return nil, err
+	}
+
+	var unreplaced []Placeholder
+	for _, p := range placeholders {
+		if _, ok := values[p.Key]; !ok && !p.HasDefault && !p.Condition && kept[p.Line] {
+			unreplaced = append(unreplaced, p)
+		}
+	}
+	return unreplaced, nil
+}
+
+// SplitPlaceholders returns the lines of the template read from src where a placeholder is split over two lines,
+// so that it's never replaced: its prefix ending the line with its key on the next one (ENV_ then TOKEN) or, with
+// a suffix, its suffix missing from the line ({{TOKEN then }}). Templates of GoTemplateEngine have none, their
+// unterminated actions failing to parse instead.
+func (g *Generator) SplitPlaceholders(src io.Reader) ([]int, error) {
+	if g.Engine == GoTemplateEngine {
+		return nil, nil
+	}
+	pattern, err := g.placeholderPattern()
+	if err != nil {
+		return nil, err
+	}
+	unterminated, err := regexp.Compile(regexp.QuoteMeta(g.prefix()) + "[A-Za-z0-9_]+")
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []int
+	var previous string
+	scanner := newLineReader(src)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line, _ := splitLineEnding(scanner.Text())
+		if IsGenerateLine(line) {
+			previous = ""
+			continue
+		}
+		if g.Suffix == "" && strings.HasSuffix(previous, g.prefix()) && keyStartPattern.MatchString(line) {
+			lines = append(lines, lineNumber-1)
+		}
+		if g.Suffix != "" && unterminated.MatchString(pattern.ReplaceAllString(line, "")) {
+			lines = append(lines, lineNumber)
+		}
+		previous = line
+	}
+	return lines, scanner.Err()
+}
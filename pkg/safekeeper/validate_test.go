@@ -0,0 +1,56 @@
+package safekeeper
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckBlocks(t *testing.T) {
+	if err := CheckBlocks(strings.NewReader("# safekeeper:if KEY\nkey: ENV_KEY\n# safekeeper:else\nkey: none\n# safekeeper:endif\n")); err != nil {
+		t.Errorf("Expected balanced blocks to pass but got %s", err)
+	}
+	// Unbalanced blocks fail even when their branch would be left out
+	if err := CheckBlocks(strings.NewReader("# safekeeper:if !KEY\n# safekeeper:if OTHER\n# safekeeper:endif\n")); err == nil || err.Error() != "safekeeper:if at line 1 has no safekeeper:endif" {
+		t.Errorf("Expected the unbalanced block to fail but got %v", err)
+	}
+}
+
+func TestSplitPlaceholders(t *testing.T) {
+	cases := []struct {
+		name      string
+		generator Generator
+		template  string
+		lines     []int
+	}{
+		{"whole", Generator{}, "const token = \"ENV_TOKEN\"\nconst host = `ENV_HOST:-localhost`\n", nil},
+		{"prefix ending a line", Generator{}, "const token = `ENV_\nTOKEN`\n", []int{1}},
+		{"prefix ending a line without key", Generator{}, "// ENV_\n// is the prefix\n", nil},
+		{"suffix on the next line", Generator{Prefix: "{{", Suffix: "}}"}, "token: {{TOKEN}}\nhost: {{HOST:-local\nhost}}\n", []int{2}},
+		{"go:generate line", Generator{}, "//go:generate safekeeper --keys=TOKEN ENV_\nTOKEN\n", nil},
+		{"go template", Generator{Engine: GoTemplateEngine}, "token: {{ .TOKEN }}\n", nil},
+	}
+
+	for _, c := range cases {
+		lines, err := c.generator.SplitPlaceholders(strings.NewReader(c.template))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(lines, c.lines) {
+			t.Errorf("Expected split placeholders at lines %v for %s but got %v", c.lines, c.name, lines)
+		}
+	}
+}
+
+func TestUnreplacedPlaceholders(t *testing.T) {
+	template := "package config\n\n//safekeeper:if DEBUG\nconst level = \"ENV_LEVEL\"\n//safekeeper:endif\nconst id = \"ENV_ID\"\nconst level = \"ENV_LEVEL\"\nconst region = \"ENV_REGION:-eu\"\n"
+	var generator Generator
+	placeholders, err := generator.UnreplacedPlaceholders(strings.NewReader(template), map[string]string{"ID": "id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Placeholder{{Key: "LEVEL", Line: 7}}
+	if !reflect.DeepEqual(placeholders, expected) {
+		t.Errorf("Expected unreplaced placeholders %+v but got %+v", expected, placeholders)
+	}
+}
@@ -0,0 +1,37 @@
+package safekeeper
+
+import (
+	"bytes"
+)
+
+// versionMarker starts the version of safekeeper that generated a file, in a comment of its header
+const versionMarker = "safekeeper:version="
+
+// AddVersion returns the generated source src with version, the version of safekeeper generating it, in a comment
+// following the code generation warning, like AddContentHash, so that differences between files generated on
+// different machines can be traced back to the tool. src is returned as is if it has no code generation warning or
+// version is empty.
+func AddVersion(src []byte, version string) []byte {
+	if version == "" {
+		return src
+	}
+	commented, _, _ := addNoticeComment(src, versionMarker+version)
+	return commented
+}
+
+// ReadVersion returns the version of safekeeper recorded in content by AddVersion, empty if there's none
+func ReadVersion(content []byte) string {
+	for _, line := range bytes.Split(content, []byte("\n")) {
+		i := bytes.Index(line, []byte(versionMarker))
+		if i < 0 {
+			continue
+		}
+
+		recorded := line[i+len(versionMarker):]
+		if j := bytes.IndexAny(recorded, " \t\r"); j >= 0 {
+			recorded = recorded[:j]
+		}
+		return string(recorded)
+	}
+	return ""
+}
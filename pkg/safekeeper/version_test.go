@@ -0,0 +1,31 @@
+package safekeeper
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVersion(t *testing.T) {
+	generated := generatedComment + "//go:generate safekeeper $GOFILE\npackage secrets\n"
+
+	src := AddVersion([]byte(generated), "v1.4.0")
+	lines := strings.Split(string(src), "\n")
+	if lines[1] != "// "+versionMarker+"v1.4.0" {
+		t.Fatalf("Expected the version to follow the code generation warning but got [%s]", string(src))
+	}
+	if version := ReadVersion(src); version != "v1.4.0" {
+		t.Errorf("Expected version [v1.4.0] but got [%s]", version)
+	}
+
+	yaml := AddVersion([]byte("# "+generatedNotice+"\r\ntoken: s3cr3t\r\n"), "v1.4.0")
+	if !strings.Contains(string(yaml), "\r\n# "+versionMarker+"v1.4.0\r\n") || ReadVersion(yaml) != "v1.4.0" {
+		t.Errorf("Expected the version to have the comment syntax and line ending of the warning but got [%s]", string(yaml))
+	}
+
+	if src := AddVersion([]byte(generated), ""); string(src) != generated {
+		t.Errorf("Expected a source to be left as is without version but got [%s]", string(src))
+	}
+	if version := ReadVersion([]byte(generated)); version != "" {
+		t.Errorf("Expected no version but got [%s]", version)
+	}
+}
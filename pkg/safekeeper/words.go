@@ -0,0 +1,82 @@
+package safekeeper
+
+import (
+	"sort"
+	"strings"
+)
+
+// wordReplacer replaces values by their replacement only where they aren't part of a longer word: a value starting
+// with a letter, digit or underscore isn't replaced after one, and likewise at its end, so that the value 8 is
+// replaced in "host:8" but not in 80 or v8. Longer values are tried first so that a value containing another one
+// is replaced whole.
+type wordReplacer struct {
+	values       []string
+	replacements map[string]string
+}
+
+// newWordReplacer returns the wordReplacer of the values of replacements by their replacement, empty values
+// being left out
+func newWordReplacer(replacements map[string]string) *wordReplacer {
+	r := &wordReplacer{replacements: make(map[string]string, len(replacements))}
+	for value, replacement := range replacements {
+		if value != "" {
+			r.values = append(r.values, value)
+			r.replacements[value] = replacement
+		}
+	}
+	sort.Slice(r.values, func(i, j int) bool {
+		if len(r.values[i]) != len(r.values[j]) {
+			return len(r.values[i]) > len(r.values[j])
+		}
+		return r.values[i] < r.values[j]
+	})
+	return r
+}
+
+// Replace returns s with the values of r replaced where they're whole words
+func (r *wordReplacer) Replace(s string) string {
+	if len(r.values) == 0 {
+		return s
+	}
+	var buffer strings.Builder
+	last := 0
+	for i := 0; i < len(s); {
+		value := r.valueAt(s, i)
+		if value == "" {
+			i++
+			continue
+		}
+		buffer.WriteString(s[last:i])
+		buffer.WriteString(r.replacements[value])
+		i += len(value)
+		last = i
+	}
+	if last == 0 {
+		return s
+	}
+	buffer.WriteString(s[last:])
+	return buffer.String()
+}
+
+// valueAt returns the longest value of r that is a whole word of s at i, none if empty
+func (r *wordReplacer) valueAt(s string, i int) string {
+	for _, value := range r.values {
+		if !strings.HasPrefix(s[i:], value) {
+			continue
+		}
+		end := i + len(value)
+		if isWordByte(value[0]) && i > 0 && isWordByte(s[i-1]) {
+			continue
+		}
+		if isWordByte(value[len(value)-1]) && end < len(s) && isWordByte(s[end]) {
+			continue
+		}
+		return value
+	}
+	return ""
+}
+
+// isWordByte reports whether b is a letter, a digit or an underscore
+func isWordByte(b byte) bool {
+	return b == '_' || ('0' <= b && b <= '9') || ('a' <= b && b <= 'z') || ('A' <= b && b <= 'Z')
+}
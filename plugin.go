@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+const (
+	// providerPluginPrefix starts the names of the executables of plugin sources, safekeeper-provider-NAME being
+	// selected with --source NAME[:argument]
+	providerPluginPrefix = "safekeeper-provider-"
+	// transformPluginPrefix starts the names of the executables of plugin transforms, safekeeper-transform-NAME
+	// being applied with ENV_KEY|NAME or --transform KEY=NAME
+	transformPluginPrefix = "safekeeper-transform-"
+	// pluginProtocol is the version of the protocol of the requests sent to plugins
+	pluginProtocol = 1
+)
+
+// pluginRequest is the JSON request a plugin reads from its standard input, once per key for a provider and
+// once per value for a transform
+type pluginRequest struct {
+	Protocol int `json:"protocol"`
+	// Key and Argument are the key a provider resolves and the argument of its --source, if any
+	Key      string `json:"key,omitempty"`
+	Argument string `json:"argument,omitempty"`
+	// Value is the value a transform goes through
+	Value string `json:"value,omitempty"`
+}
+
+// pluginResponse is the JSON response a plugin writes to its standard output. A plugin failing writes an error
+// (or exits with a non-zero status, its standard error being reported), which doesn't quote secrets.
+type pluginResponse struct {
+	Value string `json:"value"`
+	// Found reports whether a provider has a value for the key
+	Found bool   `json:"found"`
+	Error string `json:"error"`
+}
+
+// pluginRunner runs the plugin at path with input as its standard input and returns its standard output. Plugin
+// sources and transforms take one so that tests can stub the plugin.
+type pluginRunner func(path string, input []byte) ([]byte, error)
+
+// pluginRunner returns the pluginRunner of opts, killing the plugins still running once its context is done
+func (opts options) pluginRunner() pluginRunner {
+	ctx := opts.context()
+	return func(path string, input []byte) ([]byte, error) {
+		return execCommandInput(ctx, input, path)
+	}
+}
+
+// findPlugins returns the executables of the directories of path (a PATH list) named with prefix, by the rest of
+// their name (without .exe on Windows), the first directory having a plugin winning as with PATH lookups
+func findPlugins(path string, prefix string) map[string]string {
+	plugins := make(map[string]string)
+	for _, dir := range filepath.SplitList(path) {
+		files, err := ioutil.ReadDir(valueOr(dir, "."))
+		if err != nil {
+			continue
+		}
+		for _, file := range files {
+			name := file.Name()
+			if runtime.GOOS == "windows" {
+				name = strings.TrimSuffix(name, ".exe")
+			}
+			if !strings.HasPrefix(name, prefix) || name == prefix || file.IsDir() || (runtime.GOOS != "windows" && file.Mode()&0111 == 0) {
+				continue
+			}
+			if _, seen := plugins[name[len(prefix):]]; !seen {
+				plugins[name[len(prefix):]] = filepath.Join(dir, file.Name())
+			}
+		}
+	}
+	return plugins
+}
+
+// callPlugin sends request to the plugin at path and returns its response, what fails being reported as the
+// plugin of kind named name
+func callPlugin(run pluginRunner, path string, kind string, name string, request pluginRequest) (pluginResponse, error) {
+	request.Protocol = pluginProtocol
+	input, err := json.Marshal(request)
+	if err != nil {
+		return pluginResponse{}, err
+	}
+	out, err := run(path, input)
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return pluginResponse{}, fmt.Errorf("%s plugin [%s] not found at [%s]", kind, name, path)
+		}
+		return pluginResponse{}, fmt.Errorf("Error running %s plugin [%s]: %s", kind, name, err)
+	}
+
+	var response pluginResponse
+	if err := json.Unmarshal(out, &response); err != nil {
+		return pluginResponse{}, fmt.Errorf("Invalid response of %s plugin [%s]: %s", kind, name, err)
+	}
+	if response.Error != "" {
+		return pluginResponse{}, fmt.Errorf("%s plugin [%s] failed: %s", kind, name, response.Error)
+	}
+	return response, nil
+}
+
+// pluginSource resolves keys with a safekeeper-provider-NAME executable, sending it a request per key
+type pluginSource struct {
+	name     string
+	path     string
+	argument string
+	run      pluginRunner
+}
+
+func (s *pluginSource) Resolve(key string) (string, bool, error) {
+	response, err := callPlugin(s.run, s.path, "Provider", s.name, pluginRequest{Key: key, Argument: s.argument})
+	if err != nil {
+		return "", false, err
+	}
+	return response.Value, response.Found, nil
+}
+
+// transformPlugins returns the transforms of the safekeeper-transform-NAME executables of the PATH of opts, by
+// name, for the generator of opts
+func transformPlugins(opts options) map[string]safekeeper.TransformFunc {
+	run := opts.pluginRunner()
+	transforms := make(map[string]safekeeper.TransformFunc)
+	for name, path := range findPlugins(opts.getenv("PATH"), transformPluginPrefix) {
+		name, path := name, path
+		transforms[name] = func(value string) (string, error) {
+			response, err := callPlugin(run, path, "Transform", name, pluginRequest{Value: value})
+			return response.Value, err
+		}
+	}
+	return transforms
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+func TestPluginSource(t *testing.T) {
+	var request pluginRequest
+	source := &pluginSource{name: "vaultwarden", path: "/plugins/safekeeper-provider-vaultwarden", argument: "prod", run: func(path string, input []byte) ([]byte, error) {
+		if err := json.Unmarshal(input, &request); err != nil {
+			t.Fatal(err)
+		}
+		if request.Key == "MISSING" {
+			return []byte(`{"found": false}`), nil
+		}
+		return []byte(`{"value": "s3cr3t", "found": true}`), nil
+	}}
+
+	value, found, err := source.Resolve("DB_PASSWORD")
+	if err != nil || !found || value != "s3cr3t" {
+		t.Errorf("Expected [DB_PASSWORD] to be [s3cr3t] but got [%s] (found: %t, err: %v)", value, found, err)
+	}
+	if request != (pluginRequest{Protocol: pluginProtocol, Key: "DB_PASSWORD", Argument: "prod"}) {
+		t.Errorf("Expected the key and argument to be sent to the plugin but got %+v", request)
+	}
+	if _, found, err := source.Resolve("MISSING"); found || err != nil {
+		t.Errorf("Expected [MISSING] not to be found but got found: %t, err: %v", found, err)
+	}
+
+	source.run = func(path string, input []byte) ([]byte, error) {
+		return []byte(`{"error": "not logged in"}`), nil
+	}
+	if _, _, err := source.Resolve("DB_PASSWORD"); err == nil || err.Error() != "Provider plugin [vaultwarden] failed: not logged in" {
+		t.Errorf("Expected the error of the plugin to be reported but got [%v]", err)
+	}
+}
+
+func TestFindPlugins(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Plugins are found by extension on Windows")
+	}
+	tempDir, err := ioutil.TempDir("", "plugins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	first, second := filepath.Join(tempDir, "first"), filepath.Join(tempDir, "second")
+	for path, mode := range map[string]os.FileMode{
+		filepath.Join(first, "safekeeper-provider-acme"):    0755,
+		filepath.Join(second, "safekeeper-provider-acme"):   0755,
+		filepath.Join(second, "safekeeper-provider-vault2"): 0755,
+		filepath.Join(second, "safekeeper-provider-notes"):  0644,
+		filepath.Join(second, "safekeeper-transform-rot"):   0755,
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte("#!/bin/sh\n"), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	plugins := findPlugins(strings.Join([]string{first, second, filepath.Join(tempDir, "missing")}, string(os.PathListSeparator)), providerPluginPrefix)
+	expected := map[string]string{"acme": filepath.Join(first, "safekeeper-provider-acme"), "vault2": filepath.Join(second, "safekeeper-provider-vault2")}
+	if len(plugins) != len(expected) || plugins["acme"] != expected["acme"] || plugins["vault2"] != expected["vault2"] {
+		t.Errorf("Expected plugins %v but got %v", expected, plugins)
+	}
+}
+
+func TestGenerateWithPlugins(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Plugins are shell scripts")
+	}
+	tempDir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	provider := "#!/bin/sh\ncase \"$(cat)\" in\n*'\"key\":\"CLIENT_ID\"'*) echo '{\"value\": \"safeid\", \"found\": true}' ;;\n*) echo '{\"found\": false}' ;;\nesac\n"
+	transform := "#!/bin/sh\ncat >/dev/null\necho '{\"value\": \"transformed\"}'\n"
+	for name, script := range map[string]string{"safekeeper-provider-acme": provider, "safekeeper-transform-acme-mask": transform} {
+		if err := ioutil.WriteFile(filepath.Join(tempDir, name), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	templateFile := filepath.Join(tempDir, "secrets.go.safekeeper")
+	if err := ioutil.WriteFile(templateFile, []byte("package secrets\n\nconst (\n\tclientID = \"ENV_CLIENT_ID\"\n\tmasked   = \"ENV_CLIENT_ID|acme-mask\"\n)\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	env := safekeeper.LookupMap(map[string]string{"PATH": tempDir})
+	if err := run(options{paths: []string{templateFile}, sources: []string{"acme"}, noHeader: true, lookupEnv: env}); err != nil {
+		t.Fatal(err)
+	}
+	generated, err := ioutil.ReadFile(filepath.Join(tempDir, "secrets.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(generated), "clientID = \"safeid\"") || !strings.Contains(string(generated), "masked   = \"transformed\"") {
+		t.Errorf("Expected the values of the plugins to be substituted but got [%s]", string(generated))
+	}
+
+	if err := run(options{paths: []string{templateFile}, sources: []string{"unknown"}, lookupEnv: env}); err == nil || !strings.Contains(err.Error(), "safekeeper-provider-unknown") {
+		t.Errorf("Expected an unknown source to suggest a plugin but got [%v]", err)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// postHookVariables are the variables of post-generation hooks, in the order they're documented
+var postHookVariables = []string{"output", "template", "keys"}
+
+// checkPostHooks fails if a command of postHooks, the ones of --post-hook, is empty, badly quoted or has variables
+// other than {{output}}, {{template}} and {{keys}}
+func checkPostHooks(postHooks []string) error {
+	for _, postHook := range postHooks {
+		words, err := splitGenerateLine(postHook)
+		if err != nil {
+			return fmt.Errorf("Invalid --post-hook [%s]: %s", postHook, err)
+		}
+		if len(words) == 0 {
+			return fmt.Errorf("--post-hook [%s] has no command", postHook)
+		}
+		for _, match := range outputPatternVariable.FindAllStringSubmatch(postHook, -1) {
+			known := false
+			for _, variable := range postHookVariables {
+				known = known || match[1] == variable
+			}
+			if !known {
+				return fmt.Errorf("Unknown variable [%s] in --post-hook [%s], expected {{%s}}", match[0], postHook, strings.Join(postHookVariables, "}}, {{"))
+			}
+		}
+	}
+	return nil
+}
+
+// isFilePostHook reports whether postHook runs once per generated file, using its {{output}} or {{template}},
+// rather than once per run
+func isFilePostHook(postHook string) bool {
+	for _, match := range outputPatternVariable.FindAllStringSubmatch(postHook, -1) {
+		if match[1] == "output" || match[1] == "template" {
+			return true
+		}
+	}
+	return false
+}
+
+// postHookCommands returns the commands run by postHooks after the generation of files, in order: a post hook using
+// {{output}} or {{template}} runs once per generated file (except the ones written to stdout) with the paths of
+// the file, the others once with {{keys}} being all the keys substituted. {{keys}} are comma-delimited names,
+// never values. Variables are replaced in the words of the command, so paths with spaces stay a single argument.
+func postHookCommands(postHooks []string, files []manifestFile) [][]string {
+	files = append([]manifestFile{}, files...)
+	sort.Slice(files, func(i, j int) bool { return files[i].Output < files[j].Output })
+
+	var allKeys []string
+	seen := make(map[string]bool)
+	for _, file := range files {
+		for _, key := range file.Keys {
+			if !seen[key] {
+				seen[key] = true
+				allKeys = append(allKeys, key)
+			}
+		}
+	}
+	sort.Strings(allKeys)
+
+	var commands [][]string
+	for _, postHook := range postHooks {
+		words, _ := splitGenerateLine(postHook)
+		if !isFilePostHook(postHook) {
+			commands = append(commands, expandPostHook(words, map[string]string{"keys": strings.Join(allKeys, ",")}))
+			continue
+		}
+		for _, file := range files {
+			if file.Output == stdio {
+				continue
+			}
+			vars := map[string]string{"output": file.Output, "template": file.Template, "keys": strings.Join(file.Keys, ",")}
+			commands = append(commands, expandPostHook(words, vars))
+		}
+	}
+	return commands
+}
+
+// expandPostHook returns the words of a post hook with their variables replaced by vars
+func expandPostHook(words []string, vars map[string]string) []string {
+	expanded := make([]string, len(words))
+	for i, word := range words {
+		expanded[i] = outputPatternVariable.ReplaceAllStringFunc(word, func(variable string) string {
+			return vars[outputPatternVariable.FindStringSubmatch(variable)[1]]
+		})
+	}
+	return expanded
+}
+
+// runPostHooks runs the commands of postHooks for the files generated by g with run, writing their output to w.
+// The first failing command fails the run.
+func (g *generation) runPostHooks(postHooks []string, run commandRunner, w io.Writer) error {
+	for _, command := range postHookCommands(postHooks, g.manifestFiles) {
+		g.log.infof("Running post-generation hook [%s]", strings.Join(command, " "))
+		out, err := run(command[0], command[1:]...)
+		if err != nil {
+			return fmt.Errorf("Post-generation hook [%s] failed: %s", strings.Join(command, " "), err)
+		}
+		if _, err := w.Write(out); err != nil {
+			return withExitCode(exitWriteFailure, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+func TestPostHookCommands(t *testing.T) {
+	files := []manifestFile{
+		{Template: "db/config.go.safekeeper", Output: "db/config.go", Keys: []string{"DB_PASSWORD"}},
+		{Template: "api key.go.safekeeper", Output: "api key.go", Keys: []string{"API_KEY", "DB_PASSWORD"}},
+		{Template: stdio, Output: stdio, Keys: []string{"TOKEN"}},
+	}
+	commands := postHookCommands([]string{"goimports -w {{output}}", "go vet ./...", `./check.sh "--keys={{ keys }}"`, "echo {{template}}:{{keys}}"}, files)
+	expected := [][]string{
+		{"goimports", "-w", "api key.go"},
+		{"goimports", "-w", "db/config.go"},
+		{"go", "vet", "./..."},
+		{"./check.sh", "--keys=API_KEY,DB_PASSWORD,TOKEN"},
+		{"echo", "api key.go.safekeeper:API_KEY,DB_PASSWORD"},
+		{"echo", "db/config.go.safekeeper:DB_PASSWORD"},
+	}
+	if !reflect.DeepEqual(commands, expected) {
+		t.Errorf("Expected hook commands %q but got %q", expected, commands)
+	}
+}
+
+func TestCheckPostHooks(t *testing.T) {
+	if err := checkPostHooks([]string{"goimports -w {{output}}", "go vet ./..."}); err != nil {
+		t.Errorf("Expected the hooks to be valid but got [%s]", err)
+	}
+	for hook, message := range map[string]string{"gofmt -w {{file}}": "Unknown variable [{{file}}]", " ": "has no command", `echo "{{output}}`: "Unterminated quoted string"} {
+		if err := checkPostHooks([]string{hook}); err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("Expected an error containing [%s] for hook [%s] but got [%v]", message, hook, err)
+		}
+	}
+}
+
+func TestPostHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Hooks are shell commands")
+	}
+	tempDir, err := ioutil.TempDir("", "hooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "secrets.go"+templateExt), []byte("package secrets\n\nconst token = \"ENV_TOKEN\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	copied := filepath.Join(tempDir, "copy.go")
+	opts := options{paths: []string{tempDir}, postHooks: []string{"cp {{output}} " + copied}, lookupEnv: safekeeper.LookupMap(map[string]string{"TOKEN": "s3cr3t"})}
+	if err := run(opts); err != nil {
+		t.Fatal(err)
+	}
+	if content, err := ioutil.ReadFile(copied); err != nil || !strings.Contains(string(content), "s3cr3t") {
+		t.Errorf("Expected the hook to run on the generated file but got [%s] (%v)", content, err)
+	}
+
+	opts.postHooks = []string{"sh -c \"echo {{keys}} >&2; exit 3\""}
+	if err := run(opts); err == nil || !strings.Contains(err.Error(), "Post-generation hook [sh -c echo TOKEN >&2; exit 3] failed: exit status 3: TOKEN") {
+		t.Errorf("Expected the failure of the hook to be reported but got [%v]", err)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const (
+	// progressMinFiles is the number of files from which a run reports its progress, smaller runs being quick
+	progressMinFiles = 20
+	// progressInterval is how often the bar of a terminal is redrawn at most
+	progressInterval = 100 * time.Millisecond
+	// progressWidth is the number of characters of the bar
+	progressWidth = 30
+)
+
+// progress reports how many of the files of a run are done: a bar redrawn on terminals, a line every 10% through
+// the logger otherwise. Files are added as inputs are expanded, so the total can grow during the run. A nil
+// progress reports nothing.
+type progress struct {
+	w   io.Writer
+	tty bool
+	log *logger
+
+	mu       sync.Mutex
+	total    int
+	done     int
+	reported int
+	drawn    time.Time
+}
+
+// newProgress returns the progress of a run writing its bar to w if it's a terminal, its lines to log otherwise
+func newProgress(w io.Writer, log *logger) *progress {
+	p := &progress{w: w, log: log}
+	if f, ok := w.(*os.File); ok {
+		p.tty = term.IsTerminal(int(f.Fd()))
+	}
+	return p
+}
+
+// add adds n files to the total
+func (p *progress) add(n int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.total += n
+}
+
+// step reports that one more file is done
+func (p *progress) step() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done++
+	if p.total < progressMinFiles {
+		return
+	}
+
+	percent := p.done * 100 / p.total
+	if p.tty {
+		if now := time.Now(); now.Sub(p.drawn) >= progressInterval || p.done == p.total {
+			p.drawn = now
+			filled := progressWidth * p.done / p.total
+			fmt.Fprintf(p.w, "\r[%s%s] %3d%% %d/%d files", strings.Repeat("=", filled), strings.Repeat(" ", progressWidth-filled), percent, p.done, p.total)
+		}
+		return
+	}
+	if decile := percent / 10 * 10; decile > p.reported {
+		p.reported = decile
+		p.log.note("Progress: %d%% (%d/%d files)", decile, p.done, p.total)
+	}
+}
+
+// finish ends the bar of a terminal
+func (p *progress) finish() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.tty && !p.drawn.IsZero() {
+		fmt.Fprintln(p.w)
+	}
+}
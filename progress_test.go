@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestProgressLines(t *testing.T) {
+	var stderr bytes.Buffer
+	log, err := newLogger(&stderr, "error", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := newProgress(&stderr, log)
+
+	p.add(10)
+	p.add(10)
+	for i := 0; i < 20; i++ {
+		p.step()
+	}
+	p.finish()
+
+	lines := strings.Split(strings.TrimSpace(stderr.String()), "\n")
+	if len(lines) != 10 || lines[0] != "Progress: 10% (2/20 files)" || lines[9] != "Progress: 100% (20/20 files)" {
+		t.Errorf("Expected a line every 10%% whatever the log level but got [%s]", stderr.String())
+	}
+}
+
+func TestProgressSmallRuns(t *testing.T) {
+	var stderr bytes.Buffer
+	log, err := newLogger(&stderr, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := newProgress(&stderr, log)
+
+	p.add(3)
+	for i := 0; i < 3; i++ {
+		p.step()
+	}
+	p.finish()
+	if stderr.Len() != 0 {
+		t.Errorf("Expected no progress for a small run but got [%s]", stderr.String())
+	}
+
+	// A quiet run has no progress at all
+	var quiet *progress
+	quiet.add(100)
+	quiet.step()
+	quiet.finish()
+}
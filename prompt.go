@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+	"github.com/zalando/go-keyring"
+	"golang.org/x/term"
+)
+
+// promptSource resolves the keys its source doesn't have by asking for them, with --interactive
+type promptSource struct {
+	source safekeeper.SecretSource
+	// skipped are the keys never asked for, having a default or being optional
+	skipped map[string]bool
+	// ask returns the value of a key typed in, empty if none
+	ask func(key string) (string, error)
+	// confirm asks a yes or no question
+	confirm func(question string) (bool, error)
+	// store stores the value of a key for the next runs, nil if it can't be stored
+	store func(key string, value string) error
+}
+
+func (s promptSource) Resolve(key string) (string, bool, error) {
+	value, found, err := s.source.Resolve(key)
+	if err != nil || found || s.skipped[key] {
+		return value, found, err
+	}
+
+	if value, err = s.ask(key); err != nil || value == "" {
+		return "", false, err
+	}
+	if s.store != nil {
+		store, err := s.confirm(fmt.Sprintf("Store %s in the OS keyring for next time?", key))
+		if err != nil {
+			return "", false, err
+		}
+		if store {
+			if err := s.store(key, value); err != nil {
+				return "", false, fmt.Errorf("Error storing [%s] in the OS keyring: %s", key, err)
+			}
+		}
+	}
+	return value, true, nil
+}
+
+// newTerminalPromptSource returns the promptSource of source asking on the terminal of in, with hidden input, and
+// storing values in the OS keyring. source is returned as is if in isn't a terminal (e.g. in CI), keys without a
+// value failing as usual.
+func newTerminalPromptSource(source safekeeper.SecretSource, skipped map[string]bool, in *os.File, prompt io.Writer) safekeeper.SecretSource {
+	if !term.IsTerminal(int(in.Fd())) {
+		return source
+	}
+
+	return promptSource{
+		source:  source,
+		skipped: skipped,
+		ask: func(key string) (string, error) {
+			return readValue(key, in, prompt)
+		},
+		confirm: func(question string) (bool, error) {
+			fmt.Fprintf(prompt, "%s [y/N] ", question)
+			answer, err := readLine(in)
+			answer = strings.ToLower(strings.TrimSpace(answer))
+			return answer == "y" || answer == "yes", err
+		},
+		store: func(key string, value string) error {
+			return keyring.Set(keyringService, key, value)
+		},
+	}
+}
+
+// readLine reads a line from r a byte at a time, so that nothing after it is consumed
+func readLine(r io.Reader) (string, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		n, err := r.Read(b)
+		if n > 0 && b[0] == '\n' {
+			return strings.TrimRight(string(line), "\r"), nil
+		}
+		line = append(line, b[:n]...)
+		if err == io.EOF {
+			return string(line), nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
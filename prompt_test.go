@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+func TestPromptSource(t *testing.T) {
+	var asked []string
+	stored := make(map[string]string)
+	source := promptSource{
+		source:  safekeeper.MapSource{"CLIENT_ID": "id"},
+		skipped: map[string]bool{"DEBUG": true},
+		ask: func(key string) (string, error) {
+			asked = append(asked, key)
+			if key == "EMPTY" {
+				return "", nil
+			}
+			return "typed-" + key, nil
+		},
+		confirm: func(question string) (bool, error) {
+			return strings.Contains(question, "CLIENT_SECRET"), nil
+		},
+		store: func(key string, value string) error {
+			stored[key] = value
+			return nil
+		},
+	}
+
+	values, err := loadKeyValues(context.Background(), []string{"CLIENT_ID", "CLIENT_SECRET", "API_KEY", "DEBUG"}, source, nil, map[string]bool{"DEBUG": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values["CLIENT_ID"] != "id" || values["CLIENT_SECRET"] != "typed-CLIENT_SECRET" || values["API_KEY"] != "typed-API_KEY" || values["DEBUG"] != "" {
+		t.Errorf("Expected the missing keys to be asked for but got %v", values)
+	}
+	if strings.Join(asked, ",") != "CLIENT_SECRET,API_KEY" {
+		t.Errorf("Expected only the missing keys without default to be asked for but got %v", asked)
+	}
+	if len(stored) != 1 || stored["CLIENT_SECRET"] != "typed-CLIENT_SECRET" {
+		t.Errorf("Expected only the confirmed value to be stored but got %v", stored)
+	}
+
+	if _, err := loadKeyValues(context.Background(), []string{"EMPTY"}, source, nil, nil); exitCode(err) != exitMissingValue {
+		t.Errorf("Expected a key left empty to be missing but got [%v]", err)
+	}
+}
+
+func TestReadLine(t *testing.T) {
+	r := strings.NewReader("y\r\nrest")
+	line, err := readLine(r)
+	if err != nil || line != "y" {
+		t.Errorf("Expected [y] but got [%s] (%v)", line, err)
+	}
+	if r.Len() != 4 {
+		t.Errorf("Expected the rest to be left unread but %d bytes are left", r.Len())
+	}
+}
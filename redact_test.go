@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+// sentinel is the value of the keys of the tests making sure that values are never printed
+const sentinel = "S3NT1NEL-7f3a9c"
+
+func TestNoValueInOutput(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "redact")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	os.Setenv("SAFEKEEPER_TEST_SENTINEL", sentinel)
+	defer os.Unsetenv("SAFEKEEPER_TEST_SENTINEL")
+
+	template := filepath.Join(tempDir, "secrets.go"+templateExt)
+	generated := filepath.Join(tempDir, "secrets.go")
+	cases := map[string]struct {
+		template string
+		opts     options
+	}{
+		"rule":      {"const token = \"ENV_SAFEKEEPER_TEST_SENTINEL\"\n", options{rules: map[string]keyRule{"SAFEKEEPER_TEST_SENTINEL": {Charset: "hex"}}}},
+		"type":      {"const token = \"ENV_SAFEKEEPER_TEST_SENTINEL\"\n", options{types: map[string]string{"SAFEKEEPER_TEST_SENTINEL": "int"}}},
+		"transform": {"const token = \"ENV_SAFEKEEPER_TEST_SENTINEL|base64decode\"\n", options{}},
+		"template":  {"token: {{ .SAFEKEEPER_TEST_SENTINEL | b64dec }}\n", options{engine: "gotemplate"}},
+		"invalid":   {"const token = ENV_SAFEKEEPER_TEST_SENTINEL\n", options{validate: true}},
+		"strict":    {"const token = \"ENV_SAFEKEEPER_TEST_SENTINEL\" + \"ENV_SAFEKEEPER_TEST_MISSING:-\"\n", options{strict: true, logLevel: "debug", report: reportFormatJSON}},
+		"check":     {"const token = \"ENV_SAFEKEEPER_TEST_SENTINEL\"\n", options{check: true, logLevel: "debug", logFormat: logFormatJSON}},
+	}
+	for name, c := range cases {
+		if err := ioutil.WriteFile(template, []byte("package secrets\n\n"+c.template), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		var stdout, stderr bytes.Buffer
+		opts := c.opts
+		opts.paths, opts.stdout, opts.stderr = []string{generated}, &stdout, &stderr
+		err := run(opts)
+		for output, printed := range map[string]string{"error": fmt.Sprint(err), "stdout": stdout.String(), "stderr": stderr.String()} {
+			if strings.Contains(printed, sentinel) {
+				t.Errorf("The value leaked to the %s of the %s case: %s", output, name, printed)
+			}
+		}
+		os.Remove(generated)
+	}
+}
+
+func TestRedactError(t *testing.T) {
+	secrets := safekeeper.Secrets(map[string]string{"TOKEN": sentinel})
+	err := redactError(withExitCode(exitSourceFailure, fmt.Errorf("Backend rejected [%s]", sentinel)), secrets)
+	if err.Error() != "Backend rejected ["+safekeeper.Redacted+"]" || exitCode(err) != exitSourceFailure {
+		t.Errorf("Expected the value to be redacted, keeping the exit code, but got [%v] (exit code %d)", err, exitCode(err))
+	}
+
+	plain := errors.New("Nothing to redact")
+	if redactError(plain, secrets) != plain {
+		t.Errorf("Expected errors without values to be left as is")
+	}
+}
+
+func TestRedactErrorShortValues(t *testing.T) {
+	secrets := safekeeper.Secrets(map[string]string{"PORT": "1", "PIN": "4711"})
+	plain := errors.New("Unreplaced placeholders: [TYPO] at line 15 of [nonexist_dir_1/x.go], 1 generated files are out of date")
+	if err := redactError(plain, secrets); err != plain {
+		t.Errorf("Expected short values to leave line numbers, counts and paths intact but got [%v]", err)
+	}
+	if err := redactError(fmt.Errorf("Backend rejected pin 4711"), secrets); err.Error() != "Backend rejected pin "+safekeeper.Redacted {
+		t.Errorf("Expected values of 4 characters to be redacted but got [%v]", err)
+	}
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+// generateLine is a go:generate line running safekeeper, found by regenerate
+type generateLine struct {
+	// file is the Go file of the line and number its line number, starting at 1
+	file   string
+	number int
+	// pkg is the name of the package of file, $GOPACKAGE for go generate
+	pkg string
+	// args are the arguments of safekeeper on the line, $VARS not expanded
+	args []string
+}
+
+// generateLineRunner runs safekeeper with the arguments of line, as go generate would, returning its output
+type generateLineRunner func(ctx context.Context, line generateLine) ([]byte, error)
+
+// regenerate runs the go:generate lines running safekeeper of the Go files matching patterns (./... by default),
+// without the Go toolchain. Lines run in the order of go generate, or concurrently with parallel (up to the jobs
+// of opts), the lines of a directory still running in order. Identical lines of a directory, such as the one of a
+// source and the one kept in the header of the file it generates, only run once.
+func regenerate(opts options, patterns []string, parallel bool, run generateLineRunner) error {
+	opts, cancel := opts.withContext()
+	defer cancel()
+	log, err := opts.logger()
+	if err != nil {
+		return err
+	}
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+
+	var files []string
+	for _, pattern := range patterns {
+		matched, err := goFilesOf(pattern)
+		if err != nil {
+			return err
+		}
+		files = append(files, matched...)
+	}
+
+	// Lines are grouped by directory, which run in order
+	var dirs []string
+	linesOf := make(map[string][]generateLine)
+	seen := make(map[string]bool)
+	for _, file := range files {
+		// The go:generate line kept in the header of a file generated from another input would fail on its missing
+		// template, the line of that input regenerating it
+		if generatedFromOtherInput(opts.templateExt, file) {
+			log.debugf("Skipping the go:generate lines of %s, generated from another input", file)
+			continue
+		}
+		lines, err := generateLinesOf(file)
+		if err != nil {
+			return err
+		}
+		for _, line := range lines {
+			dir := filepath.Dir(line.file)
+			id := pathKey(dir) + "\x00" + strings.Join(line.args, "\x00")
+			if seen[id] {
+				log.debugf("Skipping %s:%d, already run for %s", line.file, line.number, dir)
+				continue
+			}
+			seen[id] = true
+			if _, ok := linesOf[dir]; !ok {
+				dirs = append(dirs, dir)
+			}
+			linesOf[dir] = append(linesOf[dir], line)
+		}
+	}
+	if len(dirs) == 0 {
+		log.warnf("No go:generate line running safekeeper in %s", strings.Join(patterns, " "))
+		return nil
+	}
+
+	stdout := opts.stdout
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	var mu sync.Mutex
+	runDir := func(i int) error {
+		for _, line := range linesOf[dirs[i]] {
+			if err := contextErr(opts.context()); err != nil {
+				return err
+			}
+			log.infof("Running %s:%d", line.file, line.number)
+			out, err := run(opts.context(), line)
+			mu.Lock()
+			stdout.Write(out)
+			mu.Unlock()
+			if err != nil {
+				return inputError{path: fmt.Sprintf("%s:%d", line.file, line.number), err: err}
+			}
+		}
+		return nil
+	}
+
+	// Like go generate, running in order stops at the first failure
+	if !parallel {
+		for i := range dirs {
+			if err := runDir(i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	jobs := opts.jobs
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
+	}
+	sem := newSemaphore(jobs)
+	errs := forEach(len(dirs), func(i int) error {
+		sem.acquire()
+		defer sem.release()
+		return runDir(i)
+	})
+
+	var failed generationErrors
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	switch len(failed) {
+	case 0:
+		return nil
+	case 1:
+		return failed[0]
+	default:
+		return failed
+	}
+}
+
+// goFilesOf returns the Go files matching pattern, a directory or a file, or a directory followed by /... for the
+// Go files of its tree, skipping the directories go ignores (testdata, vendor and the ones starting with . or _)
+func goFilesOf(pattern string) ([]string, error) {
+	dir, recursive := pattern, false
+	if pattern == "..." || strings.HasSuffix(filepath.ToSlash(pattern), "/...") {
+		dir, recursive = strings.TrimSuffix(strings.TrimSuffix(filepath.ToSlash(pattern), "..."), "/"), true
+		dir = filepath.FromSlash(valueOr(dir, "."))
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{dir}, nil
+	}
+
+	var files []string
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			name := info.Name()
+			if path != dir && (!recursive || name == "testdata" || name == "vendor" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if hasPathSuffix(path, ".go") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// generateLinesOf returns the go:generate lines running safekeeper of the Go file at path, either directly (e.g.
+// //go:generate safekeeper --keys=API_KEY) or with go run
+func generateLinesOf(path string) ([]generateLine, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Contains(content, []byte("//go:generate")) {
+		return nil, nil
+	}
+
+	var pkg string
+	if file, err := parser.ParseFile(token.NewFileSet(), path, content, parser.PackageClauseOnly); err == nil {
+		pkg = file.Name.Name
+	}
+
+	var lines []generateLine
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(content)+1)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if !strings.HasPrefix(line, "//go:generate ") || !safekeeper.IsGenerateLine(line) {
+			continue
+		}
+		words, err := splitGenerateLine(strings.TrimPrefix(line, "//go:generate "))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", path, number, err)
+		}
+		if args, ok := safekeeperArgs(words); ok {
+			lines = append(lines, generateLine{file: path, number: number, pkg: pkg, args: args})
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// safekeeperArgs returns the arguments of safekeeper of the words of a go:generate line, false if it doesn't run
+// safekeeper: safekeeper [args] or go run [build flags] <package of safekeeper> [args]
+func safekeeperArgs(words []string) ([]string, bool) {
+	if len(words) == 0 {
+		return nil, false
+	}
+	if isSafekeeperCommand(words[0]) {
+		return words[1:], true
+	}
+	if len(words) > 2 && words[0] == "go" && words[1] == "run" {
+		for i, word := range words[2:] {
+			if !strings.HasPrefix(word, "-") {
+				return words[i+3:], strings.Contains(word, "safekeeper")
+			}
+		}
+	}
+	return nil, false
+}
+
+// isSafekeeperCommand returns true if command is safekeeper, or a path to it
+func isSafekeeperCommand(command string) bool {
+	name := filepath.Base(filepath.FromSlash(command))
+	return name == "safekeeper" || name == "safekeeper.exe"
+}
+
+// splitGenerateLine splits the command of a go:generate line into words as go generate does: words are separated
+// by spaces and tabs, and double-quoted words are Go strings
+func splitGenerateLine(line string) ([]string, error) {
+	var words []string
+	for line = strings.TrimLeft(line, " \t"); line != ""; line = strings.TrimLeft(line, " \t") {
+		if line[0] != '"' {
+			end := strings.IndexAny(line, " \t")
+			if end < 0 {
+				end = len(line)
+			}
+			words = append(words, line[:end])
+			line = line[end:]
+			continue
+		}
+		end := 1
+		for ; end < len(line) && line[end] != '"'; end++ {
+			if line[end] == '\\' {
+				end++
+			}
+		}
+		if end >= len(line) {
+			return nil, fmt.Errorf("Unterminated quoted string in go:generate line [%s]", line)
+		}
+		word, err := strconv.Unquote(line[:end+1])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid quoted string %s in go:generate line", line[:end+1])
+		}
+		words = append(words, word)
+		line = line[end+1:]
+	}
+	return words, nil
+}
+
+// generateEnv returns the environment go generate runs the command of line with: the one of the process with
+// $GOFILE, $GOLINE, $GOPACKAGE, $GOARCH, $GOOS and $DOLLAR
+func generateEnv(line generateLine) []string {
+	return append(os.Environ(),
+		"GOFILE="+filepath.Base(line.file),
+		"GOLINE="+strconv.Itoa(line.number),
+		"GOPACKAGE="+line.pkg,
+		"GOARCH="+valueOr(os.Getenv("GOARCH"), runtime.GOARCH),
+		"GOOS="+valueOr(os.Getenv("GOOS"), runtime.GOOS),
+		"DOLLAR=$",
+	)
+}
+
+// execGenerateLine is the default generateLineRunner, running this safekeeper executable in the directory of
+// line with its arguments, $VARS expanded with the environment of go generate
+func execGenerateLine(ctx context.Context, line generateLine) ([]byte, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	env := generateEnv(line)
+	vars := make(map[string]string, len(env))
+	for _, v := range env {
+		if i := strings.IndexByte(v, '='); i > 0 {
+			vars[v[:i]] = v[i+1:]
+		}
+	}
+	args := make([]string, len(line.args))
+	for i, arg := range line.args {
+		args[i] = os.Expand(arg, func(name string) string { return vars[name] })
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, executable, args...)
+	cmd.Dir, cmd.Env, cmd.Stderr = filepath.Dir(line.file), env, &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return out, fmt.Errorf("%w: %s", err, msg)
+		}
+		return out, err
+	}
+	// Warnings of successful runs are kept
+	os.Stderr.Write(stderr.Bytes())
+	return out, nil
+}
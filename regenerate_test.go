@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRegenerate(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "regenerate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	files := map[string]string{
+		"config/gen.go":     "package config\n\n//go:generate safekeeper --keys=API_KEY \"--output=api key.go\"\n//go:generate stringer -type=Level\n",
+		"config/api key.go": "// GENERATED by safekeeper (https://github.com/alexandre-normand/safekeeper, DO NOT EDIT\n//go:generate safekeeper --keys=API_KEY \"--output=api key.go\"\n\npackage config\n",
+		// Generated into itself, from cache/secrets.go.safekeeper, its line is the one regenerating it
+		"cache/secrets.go":            "// GENERATED by safekeeper (https://github.com/alexandre-normand/safekeeper, DO NOT EDIT\n//go:generate safekeeper --keys=REDIS_URL $GOFILE\n\npackage cache\n",
+		"cache/secrets.go.safekeeper": "package cache\n",
+		"db/gen.go":                   "package db\n\n//go:generate go run github.com/alexandre-normand/safekeeper@latest --keys=DB_PASSWORD\n",
+		"db/testdata/gen.go":          "package testdata\n\n//go:generate safekeeper --keys=IGNORED\n",
+		".hidden/gen.go":              "package hidden\n\n//go:generate safekeeper --keys=IGNORED\n",
+		"db/secrets.go.tmpl":          "//go:generate safekeeper --keys=NOT_GO\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(tempDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var mu sync.Mutex
+	var ran []generateLine
+	run := func(ctx context.Context, line generateLine) ([]byte, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		ran = append(ran, line)
+		return []byte(line.pkg + "\n"), nil
+	}
+	var stdout bytes.Buffer
+	if err := regenerate(options{stdout: &stdout}, []string{filepath.Join(tempDir, "...")}, false, run); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []generateLine{
+		{file: filepath.Join(tempDir, "cache", "secrets.go"), number: 2, pkg: "cache", args: []string{"--keys=REDIS_URL", "$GOFILE"}},
+		// The line kept in the header of api key.go, generated from gen.go, would fail on its missing template
+		{file: filepath.Join(tempDir, "config", "gen.go"), number: 3, pkg: "config", args: []string{"--keys=API_KEY", "--output=api key.go"}},
+		{file: filepath.Join(tempDir, "db", "gen.go"), number: 3, pkg: "db", args: []string{"--keys=DB_PASSWORD"}},
+	}
+	if !reflect.DeepEqual(ran, expected) {
+		t.Errorf("Expected the go:generate lines running safekeeper to run once each, in order, but ran %+v", ran)
+	}
+	if stdout.String() != "cache\nconfig\ndb\n" {
+		t.Errorf("Expected the output of the lines but got [%s]", stdout.String())
+	}
+
+	ran = nil
+	failing := func(ctx context.Context, line generateLine) ([]byte, error) {
+		run(ctx, line)
+		return nil, errors.New("missing value")
+	}
+	err = regenerate(options{stdout: &stdout}, []string{filepath.Join(tempDir, "...")}, true, failing)
+	if _, ok := err.(generationErrors); !ok || len(ran) != 3 || !strings.Contains(err.Error(), "gen.go:3: missing value") {
+		t.Errorf("Expected every directory to run concurrently and every failure to be reported but got [%v]", err)
+	}
+}
+
+func TestSplitGenerateLine(t *testing.T) {
+	for _, test := range []struct {
+		line     string
+		expected []string
+	}{
+		{"safekeeper --keys=A,B", []string{"safekeeper", "--keys=A,B"}},
+		{"safekeeper\t \"--output=a b.go\"  $GOFILE", []string{"safekeeper", "--output=a b.go", "$GOFILE"}},
+		{`safekeeper "--prefix=\"x\""`, []string{"safekeeper", `--prefix="x"`}},
+	} {
+		words, err := splitGenerateLine(test.line)
+		if err != nil || !reflect.DeepEqual(words, test.expected) {
+			t.Errorf("Expected [%s] to be split into %q but got %q (%v)", test.line, test.expected, words, err)
+		}
+	}
+	if _, err := splitGenerateLine(`safekeeper "--output=a`); err == nil {
+		t.Error("Expected an unterminated quoted string to fail")
+	}
+}
+
+func TestSafekeeperArgs(t *testing.T) {
+	for _, test := range []struct {
+		words    []string
+		expected []string
+		ok       bool
+	}{
+		{[]string{"safekeeper", "--keys=A"}, []string{"--keys=A"}, true},
+		{[]string{"../bin/safekeeper"}, []string{}, true},
+		{[]string{"go", "run", "-mod=mod", "github.com/alexandre-normand/safekeeper", "--keys=A"}, []string{"--keys=A"}, true},
+		{[]string{"go", "run", "./tools/gen", "--name=safekeeper"}, nil, false},
+		{[]string{"echo", "safekeeper"}, nil, false},
+	} {
+		args, ok := safekeeperArgs(test.words)
+		if ok != test.ok || (ok && !reflect.DeepEqual(args, test.expected)) {
+			t.Errorf("Expected the arguments of %q to be %q (%t) but got %q (%t)", test.words, test.expected, test.ok, args, ok)
+		}
+	}
+}
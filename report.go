@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+// Formats of --report
+const (
+	reportFormatJSON  = "json"
+	reportFormatSARIF = "sarif"
+)
+
+// unreplacedRule is the SARIF rule of the placeholders left in generated files
+const unreplacedRule = "unreplaced-placeholder"
+
+// Statuses of the files of a report
+const (
+	statusGenerated = "generated"
+	statusUpToDate  = "up-to-date"
+	statusStale     = "stale"
+	statusDiffed    = "diffed"
+	statusFailed    = "failed"
+)
+
+// runReport is the summary of a run printed by --report. It never has values, only the number of their
+// substitutions.
+type runReport struct {
+	Files []*fileReport `json:"files"`
+	// Skipped are the templates that weren't generated, e.g. binary files
+	Skipped []string `json:"skipped"`
+	// Substitutions are the number of placeholders substituted by key, over all the files
+	Substitutions map[string]int `json:"substitutions"`
+	Warnings      []string       `json:"warnings"`
+	DurationMs    int64          `json:"duration_ms"`
+	// Phases are the durations of resolving the values, substituting them and writing the files
+	Phases phaseTimings `json:"phases"`
+
+	mu    sync.Mutex
+	start time.Time
+}
+
+// fileReport is the summary of the generation of a file
+type fileReport struct {
+	Template      string         `json:"template"`
+	Output        string         `json:"output,omitempty"`
+	Status        string         `json:"status"`
+	Substitutions map[string]int `json:"substitutions"`
+	// Unreplaced are the placeholders left in the generated file, as KEY:line
+	Unreplaced []string `json:"unreplaced,omitempty"`
+	Error      string   `json:"error,omitempty"`
+	DurationMs int64    `json:"duration_ms"`
+	// SubstitutionMs and WritingMs are the parts of DurationMs substituting values and writing the file, in
+	// fractional milliseconds since they're usually under one
+	SubstitutionMs float64 `json:"substitution_ms"`
+	WritingMs      float64 `json:"writing_ms"`
+
+	start time.Time
+}
+
+// count counts the substitutions of the placeholders of template having a value in values or a default, and the
+// placeholders left in src, the file generated from it. Nothing is counted by a nil report.
+func (f *fileReport) count(generator *safekeeper.Generator, template []byte, src []byte, values map[string]safekeeper.Secret) error {
+	if f == nil {
+		return nil
+	}
+	placeholders, err := generator.FindPlaceholders(bytes.NewReader(template))
+	if err != nil {
+		return err
+	}
+	for _, placeholder := range placeholders {
+		if _, ok := values[placeholder.Key]; (ok || placeholder.HasDefault) && !placeholder.Condition {
+			f.Substitutions[placeholder.Key]++
+		}
+	}
+
+	if generator.Engine == safekeeper.GoTemplateEngine {
+		return nil
+	}
+	if placeholders, err = generator.FindPlaceholders(bytes.NewReader(src)); err != nil {
+		return err
+	}
+	for _, placeholder := range placeholders {
+		f.Unreplaced = append(f.Unreplaced, fmt.Sprintf("%s:%d", placeholder.Key, placeholder.Line))
+	}
+	return nil
+}
+
+// setStatus sets the status of the file, unless f is nil
+func (f *fileReport) setStatus(status string, out string) {
+	if f != nil {
+		f.Status, f.Output = status, out
+	}
+}
+
+// newRunReport returns the report of a run starting now
+func newRunReport() *runReport {
+	return &runReport{Skipped: []string{}, Substitutions: make(map[string]int), Warnings: []string{}, start: time.Now()}
+}
+
+// startFile returns the report of the generation of the file of template, starting now
+func (r *runReport) startFile(template string) *fileReport {
+	return &fileReport{Template: template, Substitutions: make(map[string]int), start: time.Now()}
+}
+
+// endFile adds the report of a file once generated, failed if err isn't nil
+func (r *runReport) endFile(file *fileReport, err error) {
+	file.DurationMs = durationMs(time.Since(file.start))
+	if err != nil {
+		file.Status, file.Error = statusFailed, err.Error()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Files = append(r.Files, file)
+	for key, count := range file.Substitutions {
+		r.Substitutions[key] += count
+	}
+	for _, placeholder := range file.Unreplaced {
+		r.Warnings = append(r.Warnings, fmt.Sprintf("Unreplaced placeholder %s in [%s]", placeholder, file.Template))
+	}
+}
+
+// skip adds a template that isn't generated, with the reason. Nothing is reported by a nil report.
+func (r *runReport) skip(template string, reason string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Skipped = append(r.Skipped, template)
+	r.Warnings = append(r.Warnings, fmt.Sprintf("Skipping %s [%s]", reason, template))
+}
+
+// write writes the report as JSON to w, files sorted by template since they're generated concurrently
+func (r *runReport) write(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.DurationMs = durationMs(time.Since(r.start))
+	sort.Slice(r.Files, func(i, j int) bool { return r.Files[i].Template < r.Files[j].Template })
+	if r.Files == nil {
+		r.Files = []*fileReport{}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// writeSARIF writes the placeholders left in the generated files of the report to w as SARIF findings of
+// safekeeper at version, errors if strict since they fail the run, warnings otherwise
+func (r *runReport) writeSARIF(w io.Writer, version string, strict bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sort.Slice(r.Files, func(i, j int) bool { return r.Files[i].Template < r.Files[j].Template })
+
+	level := sarifWarning
+	if strict {
+		level = sarifError
+	}
+	var results []sarifResult
+	for _, file := range r.Files {
+		path := valueOr(file.Output, file.Template)
+		for _, placeholder := range file.Unreplaced {
+			separator := strings.LastIndexByte(placeholder, ':')
+			key := placeholder[:separator]
+			line, _ := strconv.Atoi(placeholder[separator+1:])
+			message := fmt.Sprintf("Placeholder of key [%s] left unreplaced, is it missing from --keys?", key)
+			results = append(results, newSARIFResult(unreplacedRule, level, path, line, message))
+		}
+	}
+	rules := []sarifRule{{
+		ID:                   unreplacedRule,
+		ShortDescription:     sarifMessage{Text: "Placeholder left in a generated file, whose key had no value"},
+		DefaultConfiguration: sarifConfiguration{Level: level},
+	}}
+	return writeSARIF(w, version, rules, results)
+}
+
+// durationMs returns d in milliseconds
+func durationMs(d time.Duration) int64 {
+	return int64(d / time.Millisecond)
+}
+
+// fractionalMs returns d in milliseconds, to the microsecond
+func fractionalMs(d time.Duration) float64 {
+	return float64(d.Round(time.Microsecond)) / float64(time.Millisecond)
+}
+
+// writeReport writes report in the format of opts to its report file or, if none, to its standard output
+func writeReport(report *runReport, opts options) error {
+	write := report.write
+	if opts.report == reportFormatSARIF {
+		write = func(w io.Writer) error { return report.writeSARIF(w, opts.version, opts.strict) }
+	}
+	return writeReportOutput(opts, write)
+}
+
+// writeReportOutput writes a report with write to the report file of opts or, if none, to its standard output
+func writeReportOutput(opts options, write func(w io.Writer) error) error {
+	if opts.reportFile == "" {
+		stdout := opts.stdout
+		if stdout == nil {
+			stdout = os.Stdout
+		}
+		return withExitCode(exitWriteFailure, write(stdout))
+	}
+
+	var buffer bytes.Buffer
+	if err := write(&buffer); err != nil {
+		return err
+	}
+	return withExitCode(exitWriteFailure, writeFileAtomic(opts.reportFile, buffer.Bytes(), defaultFileMode))
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJSONReport(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	os.Setenv("TOKEN", "s3cr3t")
+	defer os.Unsetenv("TOKEN")
+
+	templates := map[string]string{
+		"secrets.go": "package secrets\n\nconst token = \"ENV_TOKEN\"\nconst again = \"ENV_TOKEN\"\nconst region = \"ENV_REGION:-us\"\n",
+		"logo.png":   "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR ENV_MISSING",
+	}
+	for name, template := range templates {
+		if err := ioutil.WriteFile(filepath.Join(tempDir, name+templateExt), []byte(template), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var stdout bytes.Buffer
+	if err := run(options{paths: []string{tempDir}, stdout: &stdout, stderr: &bytes.Buffer{}, report: reportFormatJSON}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(stdout.String(), "s3cr3t") {
+		t.Errorf("The report should never have values but was:\n\n%s", stdout.String())
+	}
+
+	var report runReport
+	if err := json.Unmarshal(stdout.Bytes(), &report); err != nil {
+		t.Fatalf("Expected a JSON report but got [%s]: %s", stdout.String(), err)
+	}
+	if len(report.Files) != 1 || report.Files[0].Status != statusGenerated || report.Files[0].Output != filepath.Join(tempDir, "secrets.go") {
+		t.Fatalf("Expected the generated file to be reported but got %+v", report.Files)
+	}
+	expected := map[string]int{"TOKEN": 2, "REGION": 1}
+	if !reflect.DeepEqual(report.Substitutions, expected) || !reflect.DeepEqual(report.Files[0].Substitutions, expected) {
+		t.Errorf("Expected substitutions %v but got %v", expected, report.Substitutions)
+	}
+	if !reflect.DeepEqual(report.Skipped, []string{filepath.Join(tempDir, "logo.png"+templateExt)}) || len(report.Warnings) != 1 {
+		t.Errorf("Expected the binary file to be skipped with a warning but got %v and %v", report.Skipped, report.Warnings)
+	}
+}
+
+func TestJSONReportFile(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "secrets.go"+templateExt), []byte("package secrets\n\nconst token = \"ENV_SAFEKEEPER_TEST_MISSING\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	reportFile := filepath.Join(tempDir, "report.json")
+
+	var stdout bytes.Buffer
+	opts := options{paths: []string{tempDir}, stdout: &stdout, optionalKeys: "SAFEKEEPER_TEST_MISSING", report: reportFormatJSON, reportFile: reportFile}
+	if err := run(opts); err != nil {
+		t.Fatal(err)
+	}
+	if stdout.Len() > 0 {
+		t.Errorf("Expected nothing on standard output with a report file but got [%s]", stdout.String())
+	}
+	report, err := ioutil.ReadFile(reportFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(report), "\"status\": \"generated\"") {
+		t.Errorf("Expected the report to be written to the report file but got [%s]", string(report))
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+)
+
+// checkReproducible fails if opts has settings that make generated files depend on more than the templates and
+// the values, such as the machine they're generated on
+func checkReproducible(opts options) error {
+	outputs := []string{opts.output}
+	for _, out := range opts.outputs {
+		outputs = append(outputs, out)
+	}
+	for _, out := range outputs {
+		// Outputs are written in the go:generate line of the header
+		if out != "" && out != stdio && filepath.IsAbs(out) {
+			return fmt.Errorf("Output [%s] is absolute, so generated files depend on the machine, use a relative path with --reproducible", out)
+		}
+	}
+	if opts.valueTTL > 0 {
+		return errors.New("--value-ttl stamps the time of generation in generated files, it can't be used with --reproducible")
+	}
+	if filepath.IsAbs(opts.keysFile) {
+		return fmt.Errorf("Keys file [%s] is absolute, so generated files depend on the machine, use a relative path with --reproducible", opts.keysFile)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReproducible(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "reproducible")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	os.Setenv("CLIENT_ID", "id")
+	os.Setenv("CLIENT_SECRET", "s3cr3t")
+	defer os.Unsetenv("CLIENT_ID")
+	defer os.Unsetenv("CLIENT_SECRET")
+
+	template := filepath.Join(tempDir, "secrets.go"+templateExt)
+	if err := ioutil.WriteFile(template, []byte("package secrets\r\n\r\nconst id, secret = \"ENV_CLIENT_ID\", \"ENV_CLIENT_SECRET\"\r\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	generated := filepath.Join(tempDir, "secrets.go")
+	var outputs []string
+	for _, keys := range []string{"CLIENT_SECRET,CLIENT_ID", "CLIENT_ID,CLIENT_SECRET"} {
+		if err := run(options{keys: keys, paths: []string{generated}, reproducible: true}); err != nil {
+			t.Fatal(err)
+		}
+		output, err := ioutil.ReadFile(generated)
+		if err != nil {
+			t.Fatal(err)
+		}
+		outputs = append(outputs, string(output))
+	}
+
+	if outputs[0] != outputs[1] {
+		t.Errorf("Expected the same generated file whatever the order of the keys but got:\n\n%s\n\n%s", outputs[0], outputs[1])
+	}
+	if strings.Contains(outputs[0], "\r") || !strings.Contains(outputs[0], "--keys=CLIENT_ID,CLIENT_SECRET --reproducible $GOFILE") {
+		t.Errorf("Expected LF line endings and sorted keys but got:\n\n%q", outputs[0])
+	}
+
+	err = run(options{paths: []string{generated}, output: filepath.Join(tempDir, "out.go"), reproducible: true})
+	if err == nil || !strings.Contains(err.Error(), "absolute") {
+		t.Errorf("Expected an absolute output to be rejected but got [%v]", err)
+	}
+}
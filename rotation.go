@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ruleDateLayouts are the layouts of the dates of the rotation metadata of rules
+var ruleDateLayouts = []string{"2006-01-02", time.RFC3339}
+
+// parseRuleDate parses the date of setting (e.g. rotated) of the rule of key, a day or a RFC 3339 timestamp
+func parseRuleDate(key string, setting string, value string) (time.Time, error) {
+	for _, layout := range ruleDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("Invalid %s of the rule of [%s], expected a date such as 2024-01-31: %s", setting, key, value)
+}
+
+// parseRotatePeriod parses the rotate-after of the rule of key, a number of days (90d) or weeks (12w) or a Go
+// duration (2160h)
+func parseRotatePeriod(key string, value string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, err := strconv.Atoi(strings.TrimSuffix(value, suffix)); err == nil && strings.HasSuffix(value, suffix) && n > 0 {
+			return time.Duration(n) * unit, nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return d, nil
+	}
+	return 0, fmt.Errorf("Invalid rotate-after of the rule of [%s], expected a period such as 90d, 12w or 2160h: %s", key, value)
+}
+
+// rotationDue returns why the secret of key is due for rotation at now according to r (expired, or rotated longer
+// than its rotate-after ago), empty if it isn't
+func (r keyRule) rotationDue(key string, now time.Time) (string, error) {
+	if r.Expires != "" {
+		expires, err := parseRuleDate(key, "expires", r.Expires)
+		if err != nil {
+			return "", err
+		}
+		if !now.Before(expires) {
+			return fmt.Sprintf("Secret of [%s] expired on %s", key, r.Expires), nil
+		}
+	}
+	if r.RotateAfter != "" {
+		period, err := parseRotatePeriod(key, r.RotateAfter)
+		if err != nil {
+			return "", err
+		}
+		if r.Rotated == "" {
+			return fmt.Sprintf("Secret of [%s] must be rotated every %s but its last rotation isn't recorded, see rotated in its rule", key, r.RotateAfter), nil
+		}
+		rotated, err := parseRuleDate(key, "rotated", r.Rotated)
+		if err != nil {
+			return "", err
+		}
+		if due := rotated.Add(period); !now.Before(due) {
+			return fmt.Sprintf("Secret of [%s] was last rotated on %s and was due for rotation on %s (rotate-after %s)", key, r.Rotated, due.Format(ruleDateLayouts[0]), r.RotateAfter), nil
+		}
+	}
+	return "", nil
+}
+
+// checkRotation returns the warnings of the keys of values that are due for rotation at now according to their
+// rule, in key order, failing with the first one if enforce is set
+func checkRotation(rules map[string]keyRule, values map[string]string, now time.Time, enforce bool) ([]string, error) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var warnings []string
+	for _, key := range keys {
+		rule, ok := rules[key]
+		if !ok {
+			continue
+		}
+		warning, err := rule.rotationDue(key, now)
+		if err != nil {
+			return nil, err
+		}
+		if warning == "" {
+			continue
+		}
+		if enforce {
+			return nil, withExitCode(exitRotationDue, fmt.Errorf("%s, see --enforce-rotation", warning))
+		}
+		warnings = append(warnings, warning)
+	}
+	return warnings, nil
+}
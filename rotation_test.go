@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckRotation(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	rules := map[string]keyRule{
+		"FRESH":      {Rotated: "2024-05-01", RotateAfter: "90d"},
+		"STALE":      {Rotated: "2024-01-15", RotateAfter: "12w"},
+		"EXPIRED":    {Expires: "2024-05-31T00:00:00Z"},
+		"UNRECORDED": {RotateAfter: "2160h"},
+		"VALID":      {Expires: "2025-01-01"},
+	}
+	values := map[string]string{"FRESH": "a", "STALE": "b", "EXPIRED": "c", "UNRECORDED": "d", "VALID": "e", "OTHER": "f"}
+
+	warnings, err := checkRotation(rules, values, now, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 3 {
+		t.Fatalf("Expected 3 warnings but got %v", warnings)
+	}
+	for i, expected := range []string{"[EXPIRED] expired on 2024-05-31", "[STALE] was last rotated on 2024-01-15 and was due for rotation on 2024-04-08", "[UNRECORDED] must be rotated every 2160h"} {
+		if !strings.Contains(warnings[i], expected) {
+			t.Errorf("Expected warning %d to contain [%s] but got [%s]", i, expected, warnings[i])
+		}
+	}
+
+	_, err = checkRotation(rules, values, now, true)
+	if exitCode(err) != exitRotationDue || !strings.Contains(err.Error(), "[EXPIRED]") {
+		t.Errorf("Expected --enforce-rotation to fail with exit code %d on [EXPIRED] but got %d (%v)", exitRotationDue, exitCode(err), err)
+	}
+
+	for _, rule := range []keyRule{{RotateAfter: "soon"}, {RotateAfter: "-3d"}, {Rotated: "last week", RotateAfter: "7d"}, {Expires: "31/12/2024"}} {
+		if _, err := checkRotation(map[string]keyRule{"KEY": rule}, map[string]string{"KEY": "value"}, now, false); err == nil || !strings.Contains(err.Error(), "[KEY]") {
+			t.Errorf("Expected invalid rotation metadata %+v to fail but got [%v]", rule, err)
+		}
+	}
+}
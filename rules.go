@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"unicode/utf8"
+)
+
+// keyRule is the constraints the value of a key must satisfy, declared in the config file to catch wrong values
+// (e.g. the wrong variable exported) before they ship
+type keyRule struct {
+	// Pattern is a regular expression the value must match
+	Pattern string `yaml:"pattern" toml:"pattern"`
+	// MinLength and MaxLength bound the number of characters of the value, if not 0
+	MinLength int `yaml:"min-length" toml:"min-length"`
+	MaxLength int `yaml:"max-length" toml:"max-length"`
+	// Charset is the characters of the value: one of charsets or a regular expression character class such as
+	// [a-z0-9-]
+	Charset string `yaml:"charset" toml:"charset"`
+	// MinEntropy is the minimum Shannon entropy of the value, in bits per character
+	MinEntropy float64 `yaml:"min-entropy" toml:"min-entropy"`
+	// Rotated is the date the secret was last rotated, due for rotation RotateAfter (e.g. 90d) later. Expires is the
+	// date it stops being valid. See checkRotation.
+	Rotated     string `yaml:"rotated" toml:"rotated"`
+	RotateAfter string `yaml:"rotate-after" toml:"rotate-after"`
+	Expires     string `yaml:"expires" toml:"expires"`
+}
+
+// charsets are the character classes of the named charsets of rules
+var charsets = map[string]string{
+	"alphanumeric": "[A-Za-z0-9]",
+	"hex":          "[0-9a-fA-F]",
+	"base64":       "[A-Za-z0-9+/=]",
+	"base64url":    "[A-Za-z0-9_=-]",
+	"ascii":        "[\\x20-\\x7E]",
+}
+
+// check fails if value doesn't satisfy r, the error never including the value
+func (r keyRule) check(key string, value string) error {
+	fail := func(format string, args ...interface{}) error {
+		return withExitCode(exitInvalidValue, fmt.Errorf("Value of [%s] doesn't satisfy its rule: %s", key, fmt.Sprintf(format, args...)))
+	}
+
+	length := utf8.RuneCountInString(value)
+	if r.MinLength > 0 && length < r.MinLength {
+		return fail("it has %d characters, at least %d expected", length, r.MinLength)
+	}
+	if r.MaxLength > 0 && length > r.MaxLength {
+		return fail("it has %d characters, at most %d expected", length, r.MaxLength)
+	}
+	if r.Pattern != "" {
+		pattern, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return fmt.Errorf("Invalid pattern of the rule of [%s]: %s", key, err)
+		}
+		if !pattern.MatchString(value) {
+			return fail("it doesn't match %s", r.Pattern)
+		}
+	}
+	if r.Charset != "" {
+		class, ok := charsets[r.Charset]
+		if !ok {
+			class = r.Charset
+		}
+		pattern, err := regexp.Compile("^" + class + "*$")
+		if err != nil {
+			return fmt.Errorf("Invalid charset of the rule of [%s], expected a character class or one of alphanumeric, hex, base64, base64url and ascii: %s", key, err)
+		}
+		if !pattern.MatchString(value) {
+			return fail("it has characters outside of %s", r.Charset)
+		}
+	}
+	if r.MinEntropy > 0 {
+		if e := entropy(value); e < r.MinEntropy {
+			return fail("its entropy is %.2f bits per character, at least %.2f expected", e, r.MinEntropy)
+		}
+	}
+	return nil
+}
+
+// checkRules fails if one of values doesn't satisfy the rule of its key, in key order. Empty values, the ones of
+// optional keys without value, aren't checked.
+func checkRules(rules map[string]keyRule, values map[string]string) error {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if rule, ok := rules[key]; ok && values[key] != "" {
+			if err := rule.check(key, values[key]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckRules(t *testing.T) {
+	rules := map[string]keyRule{
+		"STRIPE_KEY": {Pattern: "^sk_(live|test)_", MinLength: 12},
+		"TOKEN":      {Charset: "hex", MaxLength: 8},
+		"PASSWORD":   {MinEntropy: 2},
+		"SLUG":       {Charset: "[a-z-]"},
+	}
+
+	valid := map[string]string{"STRIPE_KEY": "sk_test_s3cr3t", "TOKEN": "c0ffee", "PASSWORD": "h4rd2gu3ss", "SLUG": "my-app", "OTHER": "anything"}
+	if err := checkRules(rules, valid); err != nil {
+		t.Errorf("Expected valid values to pass but got [%v]", err)
+	}
+	if err := checkRules(rules, map[string]string{"STRIPE_KEY": ""}); err != nil {
+		t.Errorf("Expected empty optional values to be skipped but got [%v]", err)
+	}
+
+	for key, value := range map[string]string{"STRIPE_KEY": "pk_test_s3cr3t", "TOKEN": "c0ffee-s3cr3t", "PASSWORD": "aaaaaaaa", "SLUG": "My_App"} {
+		err := checkRules(rules, map[string]string{key: value})
+		if exitCode(err) != exitInvalidValue {
+			t.Errorf("Expected [%s] to fail its rule with exit code %d but got %d (%v)", key, exitInvalidValue, exitCode(err), err)
+			continue
+		}
+		if !strings.Contains(err.Error(), "["+key+"]") || strings.Contains(err.Error(), value) {
+			t.Errorf("Expected the error to name [%s] without its value but got [%v]", key, err)
+		}
+	}
+}
@@ -1,159 +1,1647 @@
 package main
 
 import (
-	"bufio"
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"github.com/alecthomas/kingpin"
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+	"io"
+	"io/fs"
 	"io/ioutil"
-	"log"
 	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
+	"text/template"
+	"time"
 )
 
 var (
-	keyNames = kingpin.Flag("keys", "Comma-delimited list of keys to be replaced by their respective environment variable value.").Required().String()
-	output   = kingpin.Flag("output", "Output file name. default srcdir/source.go").String()
-	paths    = kingpin.Arg("paths", "directories or files").Strings()
+	keyNames        = kingpin.Flag("keys", "Comma-delimited list of keys to be replaced by their respective environment variable value. Discovered from the ENV_<KEY> placeholders of the templates if not set. A key ending with ? is optional.").String()
+	keysFile        = kingpin.Flag("keys-file", "File listing keys one per line, as in --keys, with # comments. Its keys are added to the ones of --keys and the go:generate line of the header refers to it rather than listing them.").String()
+	optionalKeys    = kingpin.Flag("optional-keys", "Comma-delimited list of optional keys, replaced by an empty string when they have no value instead of failing.").String()
+	prefix          = kingpin.Flag("prefix", "Start of the placeholders, defaults to ENV_ (e.g. ${ for ${KEY} placeholders).").String()
+	suffix          = kingpin.Flag("suffix", "End of the placeholders, none by default (e.g. } for ${KEY} placeholders).").String()
+	mode            = kingpin.Flag("mode", "Where placeholders are replaced: text (anywhere, default) or ast (only in the string literals of Go templates, never in identifiers, comments or import paths).").Enum("text", "ast")
+	obfuscate       = kingpin.Flag("obfuscate", "Embed the string literals with placeholders as xor-obfuscated or aes-encrypted byte slices revealed at runtime instead of plaintext, against trivial extraction from binaries. Such literals can't be constants.").Enum("xor", "aes")
+	split           = kingpin.Flag("split", "Split the string literals with placeholders into that many fragments, reassembled at runtime so that values never appear contiguously in the source or binary (composable with --obfuscate). Such literals can't be constants.").Int()
+	envFallback     = kingpin.Flag("env-fallback", "Replace the placeholders of string literals by a call to an accessor of their key, returning the environment variable of the key when set at runtime and else the generated value, so that a binary can be overridden (e.g. in staging). Such literals can't be constants.").Bool()
+	escape          = kingpin.Flag("escape", "Escape values for the Go string literal (interpreted or raw) their placeholder is in, so that quotes, backslashes, newlines and backticks don't break the generated source.").Bool()
+	keyMap          = kingpin.Flag("map", "KEY=NAME mapping of a key of the templates to the name it's resolved as by the sources (repeatable), e.g. API_KEY=SERVICE_FOO_API_KEY when the environment variable isn't named like the placeholder.").StringMap()
+	transforms      = kingpin.Flag("transform", "KEY=transform mapping of a key to the transforms its value goes through (repeatable), e.g. CERT=base64decode or CERT=base64decode|json-escape. Transforms are "+strings.Join(safekeeper.TransformNames(), ", ")+".").StringMap()
+	wasmModules     = kingpin.Flag("wasm-transform", "NAME=path mapping of a transform to the WebAssembly module implementing it (repeatable), run sandboxed without filesystem or network access.").StringMap()
+	prefixMaps      = kingpin.Flag("prefix-map", "KEY=PREFIX mapping of a key to the prefix of the environment variables gathered as its value (repeatable), e.g. FEATURES=FEATURE_, its quoted placeholders being replaced by a map[string]string literal of the variables.").StringMap()
+	listDelimiter   = kingpin.Flag("list-delimiter", "Delimiter of the elements of the values of list keys (see --type), defaults to a comma. Delimiters escaped with a backslash are part of their element.").String()
+	engine          = kingpin.Flag("engine", "How templates are substituted: placeholder (replacing ENV_<KEY> placeholders, default) or gotemplate (executing them with text/template, keys being {{ .KEY }} with sprig-style functions such as upper, trim or b64enc).").Enum("placeholder", "gotemplate")
+	types           = kingpin.Flag("type", "KEY=type mapping of a key to the type of its value (repeatable), e.g. MAX_CONNS=int, its quoted placeholders being replaced by an unquoted Go literal. Types are "+strings.Join(safekeeper.TypeNames(), ", ")+".").StringMap()
+	formatOutput    = kingpin.Flag("format", "Format generated files with gofmt, failing if they aren't valid Go.").Bool()
+	validateOutput  = kingpin.Flag("validate", "Fail if generated files aren't valid Go.").Bool()
+	enforceRotation = kingpin.Flag("enforce-rotation", "Fail if a secret is expired or due for rotation according to the rotated, rotate-after and expires of its rule in the config file, instead of warning.").Bool()
+	fingerprint     = kingpin.Flag("fingerprint", "Record salted HMAC fingerprints of the values of generated files in their header, for check to name the keys whose value changed since (e.g. rotated secrets) without revealing values.").Bool()
+	fingerprintKey  = kingpin.Flag("fingerprint-key", "Secret the fingerprints of --fingerprint are keyed with, so that the values of low-entropy keys can't be guessed from them.").Envar("SAFEKEEPER_FINGERPRINT_KEY").String()
+	strict          = kingpin.Flag("strict", "Fail if generated files still have placeholders, e.g. of a key missing from --keys.").Bool()
+	noHeader        = kingpin.Flag("no-header", "Leave the header (code generation warning and go:generate line) out of generated files.").Bool()
+	headerTemplate  = kingpin.Flag("header-template", "Go template of the header of generated files, replacing the default one, with {{.Keys}}, {{.Output}}, {{.Template}} and {{.GenerateLine}} (the go:generate line of the default header, if any) as variables.").String()
+	buildTags       = kingpin.Flag("build-tags", "Build constraint of generated files, e.g. prod or 'prod && !debug', written as a //go:build line so that they're only compiled with these tags (a stub file serving other builds).").String()
+	lineDirectives  = kingpin.Flag("line-directives", "Write //line directives in generated Go files so that the positions of panics and debuggers point back to the lines of their template.").Bool()
+	fileMode        = kingpin.Flag("file-mode", "Octal permissions of generated files, e.g. 0600 for the ones with secrets, the ones of their template by default.").String()
+	lineEndings     = kingpin.Flag("line-endings", "Line endings of generated files: preserve (the ones of the template, default), lf or crlf.").Enum(lineEndingsPreserve, lineEndingsLF, lineEndingsCRLF)
+	reproducible    = kingpin.Flag("reproducible", "Guarantee byte-identical generated files for the same templates and values: keys of the header are sorted, line endings are LF and settings depending on the machine (e.g. an absolute --output) are rejected.").Bool()
+	valueTTL        = kingpin.Flag("value-ttl", "How long the values of generated files are valid, e.g. 1h for short-lived tokens: the time of generation and the TTL are stamped in their header, and --check flags the files generated longer ago as out of date. Can't be used with --reproducible.").Duration()
+	check           = kingpin.Flag("check", "Check that generated files are up to date instead of writing them, failing with the list of the outdated ones.").Bool()
+	dryRun          = kingpin.Flag("dry-run", "Print the unified diff between the files on disk and the generated ones instead of writing them.").Bool()
+	excludes        = kingpin.Flag("exclude", "Pattern of the paths skipped when walking directories (repeatable), e.g. testdata or internal/**/fixtures, on top of the ones of .safekeeperignore files.").Strings()
+	includeSkipped  = kingpin.Flag("include-skipped", "Walk the vendor, node_modules, testdata and hidden directories, skipped by default.").Bool()
+	gitignore       = kingpin.Flag("gitignore", "Skip the paths ignored by .gitignore files when walking directories.").Bool()
+	includeHidden   = kingpin.Flag("include-hidden", "Walk the hidden directories (e.g. .config), skipped by default, without walking the vendor, node_modules and testdata ones.").Bool()
+	maxDepth        = kingpin.Flag("max-depth", "Number of levels of directories walked, e.g. 1 for the templates of the input directories only, unlimited by default.").Int()
+	maxFileSize     = kingpin.Flag("max-file-size", "Size of the largest template generated when walking directories, in bytes or with a KB, MB or GB suffix (e.g. 1MB), larger ones being skipped with a warning. Unlimited by default.").String()
+	followSymlinks  = kingpin.Flag("follow-symlinks", "Walk the directories symbolic links point to and generate the templates they point to when walking directories, rather than skipping them with a warning. Links to a directory being walked are skipped since they would loop.").Bool()
+	templateFileExt = kingpin.Flag("template-ext", "Extension of the template files, defaults to "+templateExt+". An extension ending with a file extension keeps it in outputs (e.g. with .tmpl.go, secrets.tmpl.go generates secrets.go).").String()
+	jobs            = kingpin.Flag("jobs", "Maximum number of files generated concurrently, the number of CPUs by default.").Int()
+	lang            = kingpin.Flag("lang", "Language of the generated files: go (default) or text for any other file (YAML, JSON, Dockerfiles, etc.), whose header is then a comment in the syntax of the file, if it has one. The package command also generates js, python and java constant files.").Enum(langGo, langText, langJS, langPython, langJava)
+	copyOthers      = kingpin.Flag("copy-others", "Copy the files of directory inputs that aren't templates (nor generated from one) to --output-dir as is, keeping their mode, for the output directory to be a complete tree.").Bool()
+	outputDir       = kingpin.Flag("output-dir", "Directory generated files are written to instead of next to their templates, the templates of directory inputs keeping their path relative to the input (e.g. templates/db/config.go.safekeeper generates <output-dir>/db/config.go).").String()
+	outputPattern   = kingpin.Flag("output-pattern", "Naming pattern of the files generated from templates, with the variables {{dir}}, {{name}}, {{base}} and {{ext}} of their default output (e.g. {{dir}}/{{base}}_gen{{ext}} generates config/secrets_gen.go from config/secrets.go.safekeeper).").String()
+	manifestPath    = kingpin.Flag("manifest", "JSON file recording each generated file with its template, the keys substituted (names only) and their content hashes, e.g. safekeeper-manifest.json.").String()
+	force           = kingpin.Flag("force", "Overwrite generated files even if they were edited since they were generated.").Bool()
+	backup          = kingpin.Flag("backup", "Save the previous content of overwritten generated files as <name>.bak.").Bool()
+	backupDir       = kingpin.Flag("backup-dir", "Directory the previous content of overwritten generated files is saved to (implies --backup), rather than next to them.").String()
+	lockfile        = kingpin.Flag("lockfile", "Lockfile recording the template hashes, keys and output hashes of the generated files, updated by each successful generation, for status to report the outdated ones, e.g. "+defaultLockFile+".").String()
+	attestation     = kingpin.Flag("attestation", "JSON file the in-toto attestation (SLSA provenance) of the generated files is written to, recording the digest of each file and of its template, the keys substituted (names only) and the version of safekeeper.").String()
+	signAttestation = kingpin.Flag("sign-attestation", "Sign the attestation of --attestation with cosign keyless signing, writing the signature and certificate to <attestation>.bundle for cosign verify-blob.").Bool()
+	postHooks       = kingpin.Flag("post-hook", "Command run after a successful generation (repeatable), e.g. \"goimports -w {{output}}\" or \"go vet ./...\". A command with {{output}} or {{template}} runs once per generated file, the other ones once, {{keys}} being the names of the keys substituted.").Strings()
+	auditLogPath    = kingpin.Flag("audit-log", "File every generation appends a JSON line to, recording the time, the user, the keys used (names only), the sources and the hashes of the generated files.").String()
+	output          = kingpin.Flag("output", "Output file name, - for stdout. default srcdir/source.go").String()
+	interactive     = kingpin.Flag("interactive", "Ask on the terminal (with hidden input) for the values of the keys found in no source instead of failing, offering to store them in the OS keyring. With init, ask for the key of each other string literal of the file, skipping the ones without one.").Bool()
+	noColor         = kingpin.Flag("no-color", "Never color diffs, warnings and errors, colored on terminals unless the NO_COLOR environment variable is set.").Bool()
+	logLevel        = kingpin.Flag("log-level", "Level of the messages written to standard error: debug, info (per-file progress), warn (default) or error. Values are always redacted.").Enum("debug", "info", "warn", "error")
+	report          = kingpin.Flag("report", "Print a summary of the run in this format once done: json, with the files processed, the number of substitutions per key (never values), warnings, skipped files and durations, or sarif, with the placeholders left in generated files as findings for GitHub code scanning.").Enum(reportFormatJSON, reportFormatSARIF)
+	reportFile      = kingpin.Flag("report-file", "Write the summary of --report to this file rather than to standard output.").String()
+	quiet           = kingpin.Flag("quiet", "Don't report the progress of runs of many files, nor warnings unless --log-level is set.").Bool()
+	timeout         = kingpin.Flag("timeout", "Give up once this duration (e.g. 30s) elapses, cancelling the resolution of values and the walk of directories rather than hanging on a backend. No timeout by default.").Duration()
+	logFormat       = kingpin.Flag("log-format", "Format of the messages written to standard error: text (default) or json, one object per line.").Enum(logFormatText, logFormatJSON)
+	explainKeys     = kingpin.Flag("explain", "Print the source the value of KEY comes from and the other sources having one (repeatable) instead of generating, values never being printed.").Strings()
+	server          = kingpin.Flag("server", "Address of a safekeeper serve server resolving the keys in place of --source (a "+unixAddressPrefix+"<path> socket or a localhost host:port), holding the sessions of its sources across runs.").Envar("SAFEKEEPER_SERVER").String()
+	precedence      = kingpin.Flag("precedence", "Order of the groups of sources, the first having a key winning: values (of the config file), sources (of --source), secrets-files and env-files, by default in this order. Groups left out follow in their default order.").String()
+	sources         = kingpin.Flag("source", "Source of the key values as name[:argument] (repeatable), the first source having a key winning, env by default. Sources are "+strings.Join(sourceNames, ", ")+".").Strings()
+	keySources      = kingpin.Flag("key-source", "KEY=name[:argument] mapping of a key to the source resolving it instead of the chain of --source (repeatable), e.g. DB_PASSWORD=vault or FEATURE_FLAGS=envfile:flags.env.").StringMap()
+	opRef           = kingpin.Flag("op-ref", "1Password secret reference a key maps to when using --source=op, {key} being replaced by the key name. Defaults to "+defaultOpRefTemplate+".").String()
+	opRefs          = kingpin.Flag("op-item", "KEY=op://vault/item/field mapping of a key to its 1Password secret reference when using --source=op (repeatable), overriding --op-ref.").StringMap()
+	vaultAddr       = kingpin.Flag("vault-addr", "Address of the Vault server when using --source=vault.").Envar("VAULT_ADDR").String()
+	vaultPath       = kingpin.Flag("vault-path", "Path of the Vault secret holding the keys as fields when using --source=vault, e.g. secret/data/myapp.").String()
+	awsSecretId     = kingpin.Flag("aws-secret-id", "Name or ARN of a JSON secret whose fields are the keys when using --source=aws-secretsmanager.").String()
+	awsSecrets      = kingpin.Flag("aws-secret", "KEY=name-or-arn mapping of a key to its own secret when using --source=aws-secretsmanager (repeatable). Unmapped keys are read from the secret named after them unless --aws-secret-id is set.").StringMap()
+	ssmPath         = kingpin.Flag("ssm-path", "Path prefix of the AWS SSM parameters of the keys when using --source=aws-ssm (e.g. /myapp/prod/), a key being the name of its parameter under it as is or in lower case.").String()
+	ssmParams       = kingpin.Flag("ssm-parameter", "KEY=name-or-arn mapping of a key to its AWS SSM parameter when using --source=aws-ssm (repeatable). Unmapped keys are read from the parameter named after them unless --ssm-path is set.").StringMap()
+	gcpProject      = kingpin.Flag("gcp-project", "Google Cloud project of the secrets when using --source=gcp-secretmanager.").Envar("GOOGLE_CLOUD_PROJECT").String()
+	gcpSecrets      = kingpin.Flag("gcp-secret", "KEY=secret-name mapping of a key to a differently named secret (or full secret version resource name) when using --source=gcp-secretmanager (repeatable).").StringMap()
+	azureVault      = kingpin.Flag("azure-vault-name", "Name of the Azure Key Vault when using --source=azure-keyvault.").String()
+	azureSecrets    = kingpin.Flag("azure-secret", "KEY=secret-name mapping of a key to a differently named secret when using --source=azure-keyvault (repeatable). Unmapped keys are read from the secret named after them with underscores replaced by dashes.").StringMap()
+	dopplerProject  = kingpin.Flag("doppler-project", "Doppler project when using --source=doppler, optional with service tokens.").Envar("DOPPLER_PROJECT").String()
+	dopplerConfig   = kingpin.Flag("doppler-config", "Doppler config when using --source=doppler, optional with service tokens.").Envar("DOPPLER_CONFIG").String()
+	infisicalID     = kingpin.Flag("infisical-project", "ID of the Infisical project when using --source=infisical.").Envar("INFISICAL_PROJECT_ID").String()
+	infisicalEnv    = kingpin.Flag("infisical-env", "Slug of the Infisical environment when using --source=infisical. Defaults to "+defaultInfisicalEnv+".").String()
+	infisicalPath   = kingpin.Flag("infisical-path", "Folder of the Infisical secrets when using --source=infisical. Defaults to /.").String()
+	infisicalURL    = kingpin.Flag("infisical-url", "URL of a self-hosted Infisical instance. Defaults to "+infisicalEndpoint+".").Envar("INFISICAL_API_URL").String()
+	bwsProject      = kingpin.Flag("bws-project", "ID of the Bitwarden Secrets Manager project when using --source=bitwarden, all the projects of the machine account if not set.").String()
+	bwsSecrets      = kingpin.Flag("bws-secret", "KEY=id-or-name mapping of a key to its Bitwarden secret when using --source=bitwarden (repeatable). Unmapped keys are read from the secret named after them.").StringMap()
+	passPath        = kingpin.Flag("pass-path", "Path of the pass entry a key maps to when using --source=pass, {key} being replaced by the key name and {lowerkey} by the key name in lower case. Defaults to "+defaultPassPathTemplate+".").String()
+	k8sSecret       = kingpin.Flag("k8s-secret", "Name of the Kubernetes Secret holding the keys when using --source=kubernetes.").String()
+	k8sNamespace    = kingpin.Flag("k8s-namespace", "Namespace of the Kubernetes Secret, defaults to the pod namespace in a cluster and the kubeconfig namespace otherwise.").String()
+	k8sContext      = kingpin.Flag("k8s-context", "kubeconfig context to use outside of a cluster.").String()
+	dockerDir       = kingpin.Flag("docker-secrets-dir", "Directory of the Docker or Swarm secrets when using --source=docker-secrets, a key being read from the file named after it as is or in lower case. Defaults to "+defaultDockerSecretsDir+".").String()
+	ageIdentity     = kingpin.Flag("age-identity", "age identity file (or AGE-SECRET-KEY-... key) decrypting the .age secrets files.").Envar("AGE_IDENTITY").String()
+	secretsFiles    = kingpin.Flag("secrets-file", "SOPS-encrypted file (or age-encrypted .age file of KEY=VALUE lines) to load values from (repeatable). Secrets files take precedence over env files and later files over earlier ones.").Strings()
+	profile         = kingpin.Flag("profile", "Profile of the config file to use, e.g. staging, its settings overriding the other ones of the config file.").Envar("SAFEKEEPER_PROFILE").String()
+	configFile      = kingpin.Flag("config", "Config file, by default the first safekeeper.yaml or .safekeeper.toml (or .yml/.yaml/.toml variant) found from the working directory up to the repository root. Flags override its settings.").String()
+	envFiles        = kingpin.Flag("env-file", "Dotenv file to load values from (repeatable). The selected source and secrets files take precedence over env files and later files over earlier ones.").Strings()
+
+	generateCommand = kingpin.Command("generate", "Generate sources from their templates (default, safekeeper [<flags>] <paths>... being the same as safekeeper generate).").Default()
+	paths           = generateCommand.Arg("paths", "directories or files, - reading the template from stdin and writing to stdout").Strings()
+
+	checkCommand = kingpin.Command("check", "Check that generated sources are up to date instead of writing them, failing with the list of the outdated ones (same as generate --check).")
+	checkPaths   = checkCommand.Arg("paths", "directories or files").Strings()
+
+	regenerateCommand  = kingpin.Command("regenerate", "Run the go:generate lines running safekeeper of the Go files of packages (./... by default) as go generate would, without the Go toolchain, e.g. in images without Go.")
+	regeneratePatterns = regenerateCommand.Arg("packages", "directories or Go files, dir/... for the ones of a tree").Strings()
+	regenerateParallel = regenerateCommand.Flag("parallel", "Run the lines of different directories concurrently (up to --jobs), the lines of a directory still running in order.").Bool()
+
+	statusCommand = kingpin.Command("status", "Print the generated files of the lockfile (--lockfile, "+defaultLockFile+" by default) that are out of date relative to their template or its keys, or that changed since, without resolving any value. Fails if there are any.")
+
+	listKeysCommand = kingpin.Command("list-keys", "Print the keys of the placeholders of templates, one per line, e.g. to document the environment variables a repository needs.")
+	listKeysPaths   = listKeysCommand.Arg("paths", "directories or files, - reading the template from stdin").Strings()
+	listKeysFiles   = listKeysCommand.Flag("files", "Also print the templates using each key.").Bool()
+	listKeysJSON    = listKeysCommand.Flag("json", "Print the keys and the templates using them as JSON.").Bool()
+
+	watchCommand = kingpin.Command("watch", "Generate sources and regenerate them whenever their templates, env files or secrets files change.")
+	watchPaths   = watchCommand.Arg("paths", "directories or files").Strings()
+
+	whichCommand = kingpin.Command("which", "Print the templates using a key and the files they generate, e.g. to know what to regenerate when rotating a secret.")
+	whichKey     = whichCommand.Arg("key", "Key to look for.").Required().String()
+	whichPaths   = whichCommand.Arg("paths", "directories or files of the templates, the working directory if none (and none in the config file)").Strings()
+
+	scanCommand        = kingpin.Command("scan", "Report the likely hardcoded secrets of files (matching known provider patterns or high-entropy strings) that should move behind templates.")
+	scanPaths          = scanCommand.Arg("paths", "directories or files, the working directory by default").Strings()
+	scanFailOnFindings = scanCommand.Flag("fail-on-findings", "Fail if any likely secret is found, e.g. in CI.").Bool()
+
+	validateCommand = kingpin.Command("validate", "Check the structure of templates without resolving any value nor writing anything, e.g. in CI without access to secrets: their safekeeper:key and safekeeper:output directives, the balance of their conditional blocks, placeholders split over two lines and, with --engine=gotemplate or --mode=ast, their syntax. Prints the keys they reference, one per line, and fails with an exit code per failure class.")
+	validatePaths   = validateCommand.Arg("paths", "directories or files, - reading the template from stdin").Strings()
+
+	headerOnlyCommand = kingpin.Command("header-only", "Rewrite the header of generated files to the current format (e.g. after upgrading safekeeper), keeping their body as is, without resolving any value. Keys of the go:generate line default to the ones of the existing line. --check reports the files whose header is out of date and --dry-run prints the diff instead.")
+	headerOnlyPaths   = headerOnlyCommand.Arg("paths", "directories or generated files").Strings()
+
+	cleanCommand = kingpin.Command("clean", "Rewrite the templates of generated files from the files themselves, replacing the values of their keys back by placeholders (e.g. after the generated file was edited directly). --dry-run prints the diff of the templates instead.")
+	cleanPaths   = cleanCommand.Arg("paths", "directories or generated files").Strings()
+
+	filterSmudgeCommand = kingpin.Command("filter-smudge", "Git smudge filter replacing the placeholders of the file read from stdin by their values, for files with a safekeeper filter in .gitattributes.")
+	filterCleanCommand  = kingpin.Command("filter-clean", "Git clean filter replacing the values of --keys of the file read from stdin back by placeholders, so that committed files never have values.")
+
+	hookCommand          = kingpin.Command("hook", "Git hooks.")
+	hookPreCommitCommand = hookCommand.Command("pre-commit", "Fail if staged files have the value of a key (of --keys or else of the templates of paths) or, for generated files, likely hardcoded secrets, blocking the commit.")
+	hookPaths            = hookPreCommitCommand.Arg("paths", "directories or files of the templates whose keys are looked for, the working directory by default").Strings()
+	hookInstallCommand   = hookCommand.Command("install", "Install the git pre-commit hook running safekeeper hook pre-commit.")
+
+	initCommand = kingpin.Command("init", "Extract the template of an existing Go file, replacing the values of --keys (from the sources) and --value mappings in its string literals by placeholders, and add the go:generate line to the file.")
+	initFile    = initCommand.Arg("file", "Go file with the values, e.g. secrets.go").Required().String()
+	initValues  = initCommand.Flag("value", "KEY=value mapping of a key to the value it replaces (repeatable).").StringMap()
+
+	packageCommand = kingpin.Command("package", "Generate a Go package with a typed accessor per key (e.g. --keys=API_KEY,MAX_CONNS:int), without a template. Types are string (default), int, int64, float64, bool and duration.")
+	packageName    = packageCommand.Flag("name", "Name of the generated package, defaults to the name of the directory of the output. The Java package of --lang=java, none by default.").String()
+
+	ldflagsCommand = kingpin.Command("ldflags", "Print the -X flags of go build -ldflags setting a variable per key (e.g. APIKey for API_KEY) to its value, keeping values out of generated sources. --output writes them to a file instead.")
+	ldflagsPackage = ldflagsCommand.Flag("package", "Import path of the package of the variables, e.g. github.com/me/app/config.").Required().String()
+	ldflagsVars    = ldflagsCommand.Flag("vars", "Also generate the Go file declaring the variables, e.g. config/vars.go.").String()
+
+	storeCommand = kingpin.Command("store", "Store the value of a key in the OS keyring (read from standard input), for use with --source=keyring.")
+	storeKeyName = storeCommand.Arg("key", "Key to store.").Required().String()
+
+	deleteCommand = kingpin.Command("delete", "Delete the value of a key from the OS keyring.")
+	deleteKeyName = deleteCommand.Arg("key", "Key to delete.").Required().String()
+
+	serveCommand = kingpin.Command("serve", "Resolve the keys of the runs given --server with the sources of the flags until interrupted, keeping their sessions (Vault token, cloud credentials) and values instead of authenticating on every run. Clients authenticate with "+serverTokenEnv+" when it's set.")
+	serveListen  = serveCommand.Flag("listen", "Address to listen on, a "+unixAddressPrefix+"<path> socket or a localhost host:port (requiring "+serverTokenEnv+").").Default(defaultServerAddress()).String()
+
+	completionCommand = kingpin.Command("completion", "Print the completion script of a shell for the commands, flags and keys (of the config file and its templates, names only) of safekeeper, e.g. source <(safekeeper completion bash).")
+	completionShell   = completionCommand.Arg("shell", "Shell of the script: "+shellBash+", "+shellZsh+" or "+shellFish+".").Required().Enum(shellBash, shellZsh, shellFish)
 )
 
-type errWriter struct {
-	b   *bytes.Buffer
-	err error
+// options holds the settings of a single safekeeper invocation
+type options struct {
+	keys          string
+	keysFile      string
+	optionalKeys  string
+	prefix        string
+	suffix        string
+	mode          string
+	transforms    map[string]string
+	wasmModules   map[string]string
+	engine        string
+	listDelimiter string
+	prefixMaps    map[string]string
+	types         map[string]string
+	keyMap        map[string]string
+	// values are the values of keys that aren't secrets, from the config file, taking precedence over sources
+	values map[string]string
+	// rules are the constraints of the values of keys, from the config file
+	rules          map[string]keyRule
+	enforceRotate  bool
+	profile        string
+	interactive    bool
+	escape         bool
+	obfuscate      string
+	split          int
+	envFallback    bool
+	format         bool
+	validate       bool
+	strict         bool
+	fingerprint    bool
+	fingerprintKey string
+	reproducible   bool
+	valueTTL       time.Duration
+	lineEndings    string
+	noHeader       bool
+	headerTemplate string
+	buildTags      string
+	fileMode       string
+	lineDirectives bool
+	check          bool
+	dryRun         bool
+	jobs           int
+	templateExt    templateExtension
+	excludes       []string
+	includeSkipped bool
+	gitignore      bool
+	includeHidden  bool
+	maxDepth       int
+	maxFileSize    string
+	followSymlinks bool
+	// stdin, stdout and stderr are the standard streams, used for -, diffs and warnings, os.Stdin, os.Stdout and
+	// os.Stderr if nil
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	// lookupEnv and environ are the environment variables values are resolved from (and that configure the sources),
+	// the process ones if nil, see lookup and environment
+	lookupEnv func(string) (string, bool)
+	environ   func() []string
+	// timeout is how long a command may take, ctx being its context once started (see withContext)
+	timeout time.Duration
+	ctx     context.Context
+	// now returns the time files are generated at, time.Now if nil
+	now func() time.Time
+	// logLevel and logFormat configure the messages written to stderr, see newLogger
+	logLevel  string
+	logFormat string
+	// quiet silences the progress of runs, and warnings unless logLevel is set
+	quiet bool
+	// noColor never colors the output, colored on terminals otherwise, see useColor
+	noColor bool
+	// version is the version of safekeeper recorded in the header of generated files, none if empty, and
+	// requiredVersion the minimum one of the config file
+	version         string
+	requiredVersion string
+	// attestation is the file the in-toto attestation of the generated files is written to, signed with cosign
+	// if signAttestation
+	attestation     string
+	signAttestation bool
+	// report is the format of the summary of the run, written to reportFile or stdout
+	report         string
+	reportFile     string
+	output         string
+	outputDir      string
+	copyOthers     bool
+	outputPattern  string
+	manifest       string
+	auditLog       string
+	lockfile       string
+	postHooks      []string
+	backup         bool
+	backupDir      string
+	force          bool
+	lang           string
+	outputs        map[string]string
+	paths          []string
+	sources        []string
+	keySources     map[string]string
+	precedence     string
+	server         string
+	opRefTemplate  string
+	opRefs         map[string]string
+	vaultAddr      string
+	vaultPath      string
+	awsSecretId    string
+	awsSecrets     map[string]string
+	ssmPath        string
+	ssmParams      map[string]string
+	gcpProject     string
+	gcpSecrets     map[string]string
+	azureVault     string
+	azureSecrets   map[string]string
+	dopplerProject string
+	dopplerConfig  string
+	infisicalID    string
+	infisicalEnv   string
+	infisicalPath  string
+	infisicalURL   string
+	bwsProject     string
+	bwsSecrets     map[string]string
+	passPath       string
+	k8sSecret      string
+	k8sNamespace   string
+	k8sContext     string
+	dockerDir      string
+	secretsFiles   []string
+	ageIdentity    string
+	envFiles       []string
+	// setFlags are the names of the flags set on the command line, whose value wins over the config file even when
+	// false
+	setFlags map[string]bool
+}
+
+// stdio is the path standing for stdin as an input and stdout as an output
+const stdio = "-"
+
+// clock returns the time files are generated at, now
+func (opts options) clock() time.Time {
+	if opts.now == nil {
+		return time.Now()
+	}
+	return opts.now()
 }
 
-func (ew *errWriter) writeString(value string) {
-	if ew.err != nil {
-		return
+// stdinOrDefault returns the stdin of opts or os.Stdin if not set
+func (opts options) stdinOrDefault() io.Reader {
+	if opts.stdin == nil {
+		return os.Stdin
+	}
+	return opts.stdin
+}
+
+// finder returns the template finder of opts, whose --max-file-size is checked by checkWalkLimits
+func (opts options) finder() templateFinder {
+	maxFileSize, _ := parseFileSize(opts.maxFileSize)
+	return templateFinder{ext: opts.templateExt, excludes: opts.excludes, includeSkipped: opts.includeSkipped, includeHidden: opts.includeHidden, maxDepth: opts.maxDepth, maxFileSize: maxFileSize, gitignore: opts.gitignore, followSymlinks: opts.followSymlinks, ctx: opts.context()}
+}
+
+// inputError is the error of a single input path
+type inputError struct {
+	path string
+	err  error
+}
+
+func (e inputError) Error() string {
+	return fmt.Sprintf("%s: %s", e.path, e.err)
+}
+
+func (e inputError) Unwrap() error {
+	return e.err
+}
+
+// generationErrors holds the errors of every input that failed to generate
+type generationErrors []error
+
+func (errs generationErrors) Error() string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("%d inputs failed to generate:\n%s", len(errs), strings.Join(messages, "\n"))
+}
+
+// stdioArgs returns the command line args with the bare - arguments rewritten for app, kingpin taking them for an
+// unnamed short flag: - following a flag taking a value becomes its value (--output - as --output=-) and the other
+// ones are moved after --, as arguments
+func stdioArgs(app *kingpin.Application, args []string) []string {
+	model := app.Model()
+	takesValue := make(map[string]bool)
+	flags := model.Flags
+	for commands := model.Commands; len(commands) > 0; commands = commands[1:] {
+		flags = append(flags, commands[0].Flags...)
+		commands = append(commands, commands[0].Commands...)
+	}
+	for _, flag := range flags {
+		takesValue[flag.Name] = !flag.IsBoolFlag()
+	}
+
+	var rewritten, positional []string
+	for i, arg := range args {
+		if arg == "--" {
+			return append(append(append(rewritten, "--"), positional...), args[i+1:]...)
+		}
+		if arg != stdio {
+			rewritten = append(rewritten, arg)
+			continue
+		}
+		if last := len(rewritten) - 1; last >= 0 && strings.HasPrefix(rewritten[last], "--") && !strings.Contains(rewritten[last], "=") && takesValue[strings.TrimPrefix(rewritten[last], "--")] {
+			rewritten[last] += "=" + stdio
+			continue
+		}
+		positional = append(positional, arg)
+	}
+	if len(positional) == 0 {
+		return rewritten
+	}
+	return append(append(rewritten, "--"), positional...)
+}
+
+// parsedOptions returns the options of the flags of the command line parsed from args
+func parsedOptions(version string, args []string) options {
+	return options{
+		keys:           *keyNames,
+		keysFile:       *keysFile,
+		optionalKeys:   *optionalKeys,
+		prefix:         *prefix,
+		suffix:         *suffix,
+		mode:           *mode,
+		transforms:     *transforms,
+		wasmModules:    *wasmModules,
+		engine:         *engine,
+		listDelimiter:  *listDelimiter,
+		prefixMaps:     *prefixMaps,
+		logLevel:       *logLevel,
+		logFormat:      *logFormat,
+		report:         *report,
+		reportFile:     *reportFile,
+		timeout:        *timeout,
+		quiet:          *quiet,
+		noColor:        *noColor,
+		types:          *types,
+		keyMap:         *keyMap,
+		profile:        *profile,
+		interactive:    *interactive,
+		escape:         *escape,
+		obfuscate:      *obfuscate,
+		split:          *split,
+		envFallback:    *envFallback,
+		format:         *formatOutput,
+		validate:       *validateOutput,
+		enforceRotate:  *enforceRotation,
+		strict:         *strict,
+		fingerprint:    *fingerprint,
+		fingerprintKey: *fingerprintKey,
+		reproducible:   *reproducible,
+		valueTTL:       *valueTTL,
+		version:        version,
+		lineEndings:    *lineEndings,
+		noHeader:       *noHeader,
+		headerTemplate: *headerTemplate,
+		buildTags:      *buildTags,
+		fileMode:       *fileMode,
+		lineDirectives: *lineDirectives,
+		check:          *check,
+		dryRun:         *dryRun,
+		jobs:           *jobs,
+		templateExt:    templateExtension(*templateFileExt),
+		excludes:       *excludes,
+		includeSkipped: *includeSkipped,
+		gitignore:      *gitignore,
+		includeHidden:  *includeHidden,
+		maxDepth:       *maxDepth,
+		maxFileSize:    *maxFileSize,
+		followSymlinks: *followSymlinks,
+		output:         *output,
+		outputDir:      *outputDir,
+		copyOthers:     *copyOthers,
+		outputPattern:  *outputPattern,
+		manifest:       *manifestPath,
+		auditLog:       *auditLogPath,
+		postHooks:      *postHooks,
+		lockfile:       *lockfile,
+		backup:         *backup,
+		backupDir:      *backupDir,
+		force:          *force,
+		lang:           *lang,
+		paths:          *paths,
+		sources:        *sources,
+		keySources:     *keySources,
+		precedence:     *precedence,
+		server:         *server,
+		opRefTemplate:  *opRef,
+		opRefs:         *opRefs,
+		vaultAddr:      *vaultAddr,
+		vaultPath:      *vaultPath,
+		awsSecretId:    *awsSecretId,
+		awsSecrets:     *awsSecrets,
+		ssmPath:        *ssmPath,
+		ssmParams:      *ssmParams,
+		gcpProject:     *gcpProject,
+		gcpSecrets:     *gcpSecrets,
+		azureVault:     *azureVault,
+		azureSecrets:   *azureSecrets,
+		dopplerProject: *dopplerProject,
+		dopplerConfig:  *dopplerConfig,
+		infisicalID:    *infisicalID,
+		infisicalEnv:   *infisicalEnv,
+		infisicalPath:  *infisicalPath,
+		infisicalURL:   *infisicalURL,
+		bwsProject:     *bwsProject,
+		bwsSecrets:     *bwsSecrets,
+		passPath:       *passPath,
+		k8sSecret:      *k8sSecret,
+		k8sNamespace:   *k8sNamespace,
+		k8sContext:     *k8sContext,
+		dockerDir:      *dockerDir,
+		secretsFiles:   *secretsFiles,
+		ageIdentity:    *ageIdentity,
+		envFiles:       *envFiles,
+
+		attestation:     *attestation,
+		signAttestation: *signAttestation,
+		setFlags:        flagsSetOn(kingpin.CommandLine, args),
 	}
-	_, ew.err = ew.b.WriteString(value)
 }
 
 func main() {
-	kingpin.Version("1.0.0")
-	kingpin.Parse()
+	version := currentVersion()
+	kingpin.Version(version)
+	args := stdioArgs(kingpin.CommandLine, os.Args[1:])
+	command := kingpin.MustParse(kingpin.CommandLine.Parse(args))
+
+	opts := parsedOptions(version, args)
+
+	var err error
+	switch command {
+	case watchCommand.FullCommand():
+		opts.paths = *watchPaths
+		if opts, err = withConfig(*configFile, opts); err == nil {
+			err = watch(opts, nil)
+		}
+	case checkCommand.FullCommand():
+		opts.paths = *checkPaths
+		opts.check = true
+		if opts, err = withConfig(*configFile, opts); err == nil {
+			err = run(opts)
+		}
+	case regenerateCommand.FullCommand():
+		if opts, err = withConfig(*configFile, opts); err == nil {
+			err = regenerate(opts, *regeneratePatterns, *regenerateParallel, execGenerateLine)
+		}
+	case statusCommand.FullCommand():
+		if opts, err = withConfig(*configFile, opts); err == nil {
+			err = status(opts)
+		}
+	case listKeysCommand.FullCommand():
+		opts.paths = *listKeysPaths
+		if opts, err = withConfig(*configFile, opts); err == nil {
+			err = listKeys(opts, *listKeysFiles, *listKeysJSON)
+		}
+	case whichCommand.FullCommand():
+		opts.paths = *whichPaths
+		if opts, err = withConfig(*configFile, opts); err == nil {
+			if len(opts.paths) == 0 {
+				opts.paths = []string{"."}
+			}
+			err = which(opts, *whichKey)
+		}
+	case scanCommand.FullCommand():
+		// Scans default to the whole tree rather than to the templates of the config file
+		opts.paths = *scanPaths
+		if len(opts.paths) == 0 {
+			opts.paths = []string{"."}
+		}
+		if opts, err = withConfig(*configFile, opts); err == nil {
+			err = scan(opts, *scanFailOnFindings, opts.report)
+		}
+	case validateCommand.FullCommand():
+		opts.paths = *validatePaths
+		if opts, err = withConfig(*configFile, opts); err == nil {
+			err = validateTemplates(opts)
+		}
+	case headerOnlyCommand.FullCommand():
+		opts.paths = *headerOnlyPaths
+		if opts, err = withConfig(*configFile, opts); err == nil {
+			err = headerOnly(opts)
+		}
+	case cleanCommand.FullCommand():
+		opts.paths = *cleanPaths
+		if opts, err = withConfig(*configFile, opts); err == nil {
+			err = clean(opts)
+		}
+	case filterSmudgeCommand.FullCommand():
+		if opts, err = withConfig(*configFile, opts); err == nil {
+			err = filterSmudge(opts)
+		}
+	case filterCleanCommand.FullCommand():
+		if opts, err = withConfig(*configFile, opts); err == nil {
+			err = filterClean(opts)
+		}
+	case hookPreCommitCommand.FullCommand():
+		opts.paths = *hookPaths
+		if opts, err = withConfig(*configFile, opts); err == nil {
+			err = preCommit(opts, execCommand)
+		}
+	case hookInstallCommand.FullCommand():
+		err = installHook(execCommand)
+	case initCommand.FullCommand():
+		if opts, err = withConfig(*configFile, opts); err == nil {
+			err = initTemplate(opts, *initFile, *initValues, opts.interactive, os.Stderr)
+		}
+	case packageCommand.FullCommand():
+		if opts, err = withConfig(*configFile, opts); err == nil {
+			err = generateConfigPackage(opts, *packageName)
+		}
+	case ldflagsCommand.FullCommand():
+		if opts, err = withConfig(*configFile, opts); err == nil {
+			err = generateLdflags(opts, *ldflagsPackage, *ldflagsVars)
+		}
+	case storeCommand.FullCommand():
+		err = storeKey(*storeKeyName, os.Stdin, os.Stderr)
+	case deleteCommand.FullCommand():
+		err = deleteKey(*deleteKeyName)
+	case serveCommand.FullCommand():
+		if opts, err = withConfig(*configFile, opts); err == nil {
+			err = serve(opts, *serveListen, interrupted())
+		}
+	case completionCommand.FullCommand():
+		if opts, err = withConfig(*configFile, opts); err == nil {
+			err = completion(opts, *completionShell, kingpin.CommandLine.Model())
+		}
+	default:
+		if opts, err = withConfig(*configFile, opts); err == nil && len(*explainKeys) > 0 {
+			err = explain(opts, *explainKeys)
+		} else if err == nil {
+			err = run(opts)
+		}
+	}
+
+	if err != nil {
+		// An invalid --log-level or --log-format is the error itself
+		log, logErr := opts.logger()
+		if logErr != nil {
+			log, _ = newLogger(os.Stderr, "", "")
+		}
+		log.errorf("%s", err)
+		os.Exit(exitCode(err))
+	}
+}
+
+// logger returns the logger of opts, writing to its standard error
+func (opts options) logger() (*logger, error) {
+	level := opts.logLevel
+	if opts.quiet && level == "" {
+		level = "error"
+	}
+	log, err := newLogger(opts.stderrOrDefault(), level, opts.logFormat)
+	if err != nil {
+		return nil, err
+	}
+	log.color = !log.json && opts.useColor(opts.stderrOrDefault())
+	return log, nil
+}
+
+// stderrOrDefault returns the stderr of opts or os.Stderr if not set
+func (opts options) stderrOrDefault() io.Writer {
+	if opts.stderr == nil {
+		return os.Stderr
+	}
+	return opts.stderr
+}
+
+// transformFuncs returns the transforms of the generator of opts, its transform plugins and WebAssembly modules
+func (opts options) transformFuncs() map[string]safekeeper.TransformFunc {
+	transforms := transformPlugins(opts)
+	for name, transform := range wasmTransforms(opts) {
+		transforms[name] = transform
+	}
+	return transforms
+}
+
+// generator returns the generator configured by opts
+func (opts options) generator() (safekeeper.Generator, error) {
+	generatorMode, err := parseMode(opts.mode)
+	if err != nil {
+		return safekeeper.Generator{}, err
+	}
+	engine, err := parseEngine(opts.engine)
+	if err != nil {
+		return safekeeper.Generator{}, err
+	}
+	// Keys of prefix maps are of the map type
+	types := opts.types
+	if len(opts.prefixMaps) > 0 {
+		types = make(map[string]string, len(opts.types)+len(opts.prefixMaps))
+		for key, typeName := range opts.types {
+			types[key] = typeName
+		}
+		for key := range opts.prefixMaps {
+			types[key] = "map"
+		}
+	}
+	obfuscation, err := parseObfuscation(opts.obfuscate)
+	if err != nil {
+		return safekeeper.Generator{}, err
+	}
+	generatorOptions := []safekeeper.Option{
+		safekeeper.WithPrefix(opts.prefix),
+		safekeeper.WithSuffix(opts.suffix),
+		safekeeper.WithMode(generatorMode),
+		safekeeper.WithObfuscation(obfuscation),
+		safekeeper.WithSplit(opts.split),
+		safekeeper.WithEscaping(opts.escape),
+		safekeeper.WithTransforms(opts.transforms),
+		safekeeper.WithTransformFuncs(opts.transformFuncs()),
+		safekeeper.WithTypes(types),
+		safekeeper.WithEngine(engine),
+		safekeeper.WithListDelimiter(opts.listDelimiter),
+	}
+	if opts.envFallback {
+		generatorOptions = append(generatorOptions, safekeeper.WithEnvFallback())
+	}
+	return *safekeeper.New(generatorOptions...), nil
+}
 
-	if err := run(*keyNames, *output, *paths); err != nil {
-		log.Fatal(err)
+// stdinTemplate returns the template read from stdin if one of inputPaths is -, nil otherwise
+func (opts options) stdinTemplate(inputPaths []string) ([]byte, error) {
+	for _, path := range inputPaths {
+		if path == stdio {
+			return ioutil.ReadAll(opts.stdinOrDefault())
+		}
 	}
+	return nil, nil
 }
 
-func run(keys string, out string, inputPaths []string) error {
-	k := strings.Split(keys, ",")
-	keyValues, err := loadKeyValues(k)
+func run(opts options) (err error) {
+	// Whatever fails once values are resolved, their errors and panics can't print them
+	var keyValues map[string]safekeeper.Secret
+	defer func() {
+		if r := recover(); r != nil {
+			panic(safekeeper.NewRedactor(keyValues).Replace(fmt.Sprint(r)))
+		}
+		err = redactError(err, keyValues)
+	}()
+	opts, cancel := opts.withContext()
+	defer cancel()
+	start := time.Now()
+
+	log, err := opts.logger()
+	if err != nil {
+		return err
+	}
+	if err := checkRequiredVersion(opts.requiredVersion, opts.version); err != nil {
+		return err
+	}
+	if err := checkWalkLimits(opts); err != nil {
+		return err
+	}
+	if opts, err = withKeysFile(opts); err != nil {
+		return err
+	}
+	out := opts.output
+	finder := opts.finder()
+	finder.warnings = newWalkWarnings(log)
+	inputPaths, err := finder.expandPaths(opts.paths)
+	if err != nil {
+		return err
+	}
+	// The go:generate line kept in the header of a file generated from another input is run by go generate too
+	if kept := withoutGeneratedGoFile(inputPaths, opts.getenv("GOFILE"), finder.ext, log); len(kept) < len(inputPaths) {
+		if len(kept) == 0 {
+			return nil
+		}
+		inputPaths = kept
+	}
+
+	secretSource, err := newSecretSource(opts)
 	if err != nil {
 		return err
 	}
 
-	if len(inputPaths) == 1 && isFile(inputPaths[0]) {
-		var buffer bytes.Buffer
+	// Keys given explicitly are repeated in the go:generate line of the header, discovered keys aren't so that
+	// they're discovered again when regenerating
+	generator, err := opts.generator()
+	if err != nil {
+		return err
+	}
+	// stdin is read once since it's both scanned for keys and generated
+	stdinTemplate, err := opts.stdinTemplate(inputPaths)
+	if err != nil {
+		return err
+	}
 
-		if err := writeHeader(&buffer, k, out); err != nil {
+	k, defaulted, settings, err := scanTemplates(&generator, finder, inputPaths, stdinTemplate)
+	if err != nil {
+		return err
+	}
+	optional := parseOptionalKeys(opts.optionalKeys)
+	for _, fileSettings := range settings {
+		for key := range fileSettings.Optional {
+			optional[key] = true
+		}
+	}
+	var headerKeys []string
+	if opts.keys != "" {
+		k = parseKeys(opts.keys, optional)
+		if headerKeys, err = withoutKeysFile(annotateKeys(k, optional), opts.keysFile); err != nil {
 			return err
 		}
+		k = withSettingsKeys(k, settings)
+	}
+
+	if opts.interactive {
+		skipped := make(map[string]bool)
+		for key := range defaulted {
+			skipped[key] = true
+		}
+		for key := range optional {
+			skipped[key] = true
+		}
+		secretSource = newTerminalPromptSource(secretSource, skipped, os.Stdin, os.Stderr)
+	}
+	resolving := time.Now()
+	values, err := loadKeyValues(opts.context(), k, secretSource, defaulted, optional)
+	if err != nil {
+		return err
+	}
+	resolution := time.Since(resolving)
+	keyValues = safekeeper.Secrets(values)
+	log.redact(keyValues)
+	log.debugf("Resolved %d keys for %d inputs: %s", len(keyValues), len(inputPaths), strings.Join(k, ", "))
+	if err := checkRules(opts.rules, values); err != nil {
+		return err
+	}
+	warnings, err := checkRotation(opts.rules, values, time.Now(), opts.enforceRotate)
+	if err != nil {
+		return err
+	}
+	for _, warning := range warnings {
+		log.warnf("%s", warning)
+	}
+
+	// Several inputs with a single output are merged into it
+	merge := len(inputPaths) > 1 && out != ""
+	if merge {
+		if err := checkMerge(opts); err != nil {
+			return err
+		}
+	}
+	if err := checkLang(opts); err != nil {
+		return err
+	}
+	if opts.outputDir != "" && out != "" {
+		return errors.New("--output can't be used with --output-dir")
+	}
+	if opts.copyOthers && opts.outputDir == "" {
+		return errors.New("--copy-others requires --output-dir, files would be copied over themselves")
+	}
+	if err := checkPostHooks(opts.postHooks); err != nil {
+		return err
+	}
+	if opts.signAttestation && opts.attestation == "" {
+		return errors.New("--sign-attestation requires --attestation")
+	}
+	if opts.outputPattern != "" {
+		if out != "" {
+			return errors.New("--output can't be used with --output-pattern")
+		}
+		if err := checkOutputPattern(opts.outputPattern); err != nil {
+			return err
+		}
+	}
+	var constraintLine string
+	if opts.buildTags != "" {
+		if constraintLine, err = buildConstraint(opts.buildTags); err != nil {
+			return err
+		}
+	}
+	var fileMode os.FileMode
+	if opts.fileMode != "" {
+		if fileMode, err = parseFileMode(opts.fileMode); err != nil {
+			return err
+		}
+	}
+	var header *template.Template
+	if opts.headerTemplate != "" {
+		if header, err = template.New("header").Parse(opts.headerTemplate); err != nil {
+			return fmt.Errorf("Invalid --header-template: %s", err)
+		}
+	}
+	if opts.reproducible {
+		if err := checkReproducible(opts); err != nil {
+			return err
+		}
+		// The header doesn't depend on the order of --keys
+		sort.Strings(headerKeys)
+	}
+	if err := checkLineEndings(opts.lineEndings); err != nil {
+		return err
+	}
+	lineEndings := opts.lineEndings
+	if opts.reproducible && lineEndings != lineEndingsCRLF {
+		lineEndings = lineEndingsLF
+	}
+
+	g := &generation{
+		keys:            headerKeys,
+		keysFile:        opts.keysFile,
+		keyValues:       keyValues,
+		sources:         opts.sources,
+		secretsFiles:    opts.secretsFiles,
+		envFiles:        opts.envFiles,
+		keyMap:          opts.keyMap,
+		generator:       generator,
+		format:          opts.format,
+		validate:        opts.validate,
+		strict:          opts.strict,
+		fingerprint:     opts.fingerprint,
+		fingerprintKey:  []byte(opts.fingerprintKey),
+		check:           opts.check,
+		dryRun:          opts.dryRun,
+		stdout:          opts.stdout,
+		log:             log,
+		stdin:           stdinTemplate,
+		finder:          finder,
+		settings:        settings,
+		outputDir:       opts.outputDir,
+		copyOthers:      opts.copyOthers,
+		outputPattern:   opts.outputPattern,
+		lang:            opts.lang,
+		recordFiles:     opts.manifest != "" || opts.auditLog != "" || opts.lockfile != "" || opts.attestation != "" || len(opts.postHooks) > 0,
+		reproducible:    opts.reproducible,
+		valueTTL:        opts.valueTTL,
+		now:             opts.clock(),
+		version:         opts.version,
+		lineEndings:     lineEndings,
+		noHeader:        opts.noHeader,
+		headerTemplate:  header,
+		buildConstraint: constraintLine,
+		fileMode:        fileMode,
+		lineDirectives:  opts.lineDirectives,
+		merge:           merge,
+		backup:          opts.backup || opts.backupDir != "",
+		backupDir:       opts.backupDir,
+		force:           opts.force,
+		directive:       currentDirective(opts.getenv),
+		ctx:             opts.context(),
+		timings:         newRunTimings(resolution),
+	}
+	if opts.report != "" {
+		g.report = newRunReport()
+	}
+	if !opts.quiet {
+		g.progress = newProgress(opts.stderrOrDefault(), log)
+	}
+	if g.stdout == nil {
+		g.stdout = os.Stdout
+	}
+	g.color = opts.useColor(g.stdout)
+	jobs := opts.jobs
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
+	}
+	g.jobs = newSemaphore(jobs)
+
+	var pathErrs []error
+	if merge {
+		pathErrs = []error{g.generateMerged(inputPaths, out)}
+	} else {
+		pathErrs = forEach(len(inputPaths), func(i int) error {
+			pathOut := out
+			if absPath, err := filepath.Abs(inputPaths[i]); err == nil && pathOut == "" {
+				pathOut = opts.outputs[pathKey(absPath)]
+			}
+			return g.generatePath(inputPaths[i], pathOut)
+		})
+	}
+	g.progress.finish()
+	g.timings.logSummary(log, time.Since(start), opts.quiet)
 
-		src, err := substituteValues(inputPaths[0], keyValues, &buffer)
+	var errs generationErrors
+	for i, err := range pathErrs {
 		if err != nil {
+			errs = append(errs, inputError{path: inputPaths[i], err: err})
+		}
+	}
+	if g.report != nil {
+		g.report.Phases = g.timings.phases()
+		if err := writeReport(g.report, opts); err != nil {
 			return err
 		}
+	}
 
-		// Write to file.
-		if out == "" {
-			out = inputPaths[0]
+	switch len(errs) {
+	case 0:
+		if opts.manifest != "" {
+			if err := g.writeManifest(opts.manifest); err != nil {
+				return err
+			}
+		}
+		if opts.auditLog != "" && !opts.check && !opts.dryRun {
+			if err := g.appendAuditLog(opts, time.Now()); err != nil {
+				return err
+			}
+		}
+		if opts.lockfile != "" && !opts.check && !opts.dryRun {
+			if err := g.updateLock(opts.lockfile); err != nil {
+				return err
+			}
+		}
+		if opts.attestation != "" && !opts.check && !opts.dryRun {
+			if err := g.writeAttestation(opts.attestation, start, time.Now(), opts.signAttestation, opts.runner()); err != nil {
+				return err
+			}
+		}
+		if len(opts.postHooks) > 0 && !opts.check && !opts.dryRun {
+			if err := g.runPostHooks(opts.postHooks, opts.runner(), opts.stderrOrDefault()); err != nil {
+				return err
+			}
+		}
+		if len(g.stale) > 0 {
+			sort.Strings(g.stale)
+			return withExitCode(exitStale, fmt.Errorf("%d generated files are out of date, run go generate:\n%s", len(g.stale), strings.Join(g.stale, "\n")))
 		}
-		err = ioutil.WriteFile(out, src, 0644)
+		return nil
+	case 1:
+		return errs[0]
+	default:
+		return errs
+	}
+}
+
+// parseMode returns the generator mode named name, text if empty
+func parseMode(name string) (safekeeper.Mode, error) {
+	switch name {
+	case "", "text":
+		return safekeeper.TextMode, nil
+	case "ast":
+		return safekeeper.ASTMode, nil
+	default:
+		return safekeeper.TextMode, fmt.Errorf("Unknown mode [%s], expected text or ast", name)
+	}
+}
+
+// parseEngine returns the engine named name, placeholder if empty
+func parseEngine(name string) (safekeeper.Engine, error) {
+	switch name {
+	case "", "placeholder":
+		return safekeeper.PlaceholderEngine, nil
+	case "gotemplate":
+		return safekeeper.GoTemplateEngine, nil
+	default:
+		return safekeeper.PlaceholderEngine, fmt.Errorf("Unknown engine [%s], expected placeholder or gotemplate", name)
+	}
+}
+
+// parseObfuscation returns the obfuscation named name, none if empty
+func parseObfuscation(name string) (safekeeper.Obfuscation, error) {
+	switch name {
+	case "", "none":
+		return safekeeper.NoObfuscation, nil
+	case "xor":
+		return safekeeper.XORObfuscation, nil
+	case "aes":
+		return safekeeper.AESObfuscation, nil
+	default:
+		return safekeeper.NoObfuscation, fmt.Errorf("Unknown obfuscation [%s], expected xor or aes", name)
+	}
+}
+
+// generation holds what's shared by all the files generated by a run
+type generation struct {
+	// keys are the keys written in the go:generate line of the header
+	keys []string
+	// keysFile is the --keys-file the go:generate line of the header refers to for the other keys, if any
+	keysFile string
+	// keyValues are the values of the keys, revealed only to be substituted
+	keyValues map[string]safekeeper.Secret
+	generator safekeeper.Generator
+	// sources, secretsFiles, envFiles and keyMap are where the values are resolved from, written in the
+	// go:generate line of the header for regenerations to resolve them the same way
+	sources      []string
+	secretsFiles []string
+	envFiles     []string
+	keyMap       map[string]string
+	// format and validate check that generated files are valid Go, format also running them through gofmt
+	format   bool
+	validate bool
+	// strict fails the generation of files left with placeholders
+	strict bool
+	// fingerprint records the fingerprints of the values of generated files, keyed with fingerprintKey, see
+	// safekeeper.AddFingerprints
+	fingerprint    bool
+	fingerprintKey []byte
+	// check compares generated files with the ones on disk instead of writing them, the outdated ones being
+	// added to stale
+	check bool
+	stale []string
+	// dryRun prints the diff of generated files to stdout instead of writing them
+	dryRun bool
+	stdout io.Writer
+	// color colors the diffs written to stdout
+	color bool
+	// stdin is the template of the - input
+	stdin []byte
+	// finder finds the templates of directory inputs
+	finder templateFinder
+	// settings are the settings of the templates by output, see safekeeper.ReadFileSettings
+	settings map[string]safekeeper.FileSettings
+	// outputDir is the directory generated files are written to, next to their templates if empty
+	outputDir string
+	// copyOthers copies the files of directory inputs that aren't templates to outputDir as is, see copyOther
+	copyOthers bool
+	// outputPattern names the generated files after their default output, see applyOutputPattern
+	outputPattern string
+	// lang is the language of the generated files, Go if empty
+	lang string
+	// jobs bounds the number of files generated concurrently
+	jobs semaphore
+	// buildConstraint is the //go:build line starting generated files, if any
+	buildConstraint string
+	// noHeader leaves the header out of generated files, headerTemplate replacing it if not nil
+	noHeader       bool
+	headerTemplate *template.Template
+	// directive is the go:generate line running safekeeper, if run by go generate
+	directive generateDirective
+	// ctx is the context of the run, generations failing once it's done
+	ctx context.Context
+	// outputs is the filesystem generated files are written to, the OS one (with atomic writes) if nil
+	outputs safekeeper.WriteFS
+	// reproducible is written in the header, for regenerations to stay reproducible
+	reproducible bool
+	// valueTTL is how long the values of generated files are valid, stamped in their header with the time of
+	// generation now, see safekeeper.AddTTL
+	valueTTL time.Duration
+	now      time.Time
+	// version is the version of safekeeper recorded in the header, none if empty
+	version string
+	// lineEndings is how the line endings of generated files are normalized, preserved if empty
+	lineEndings string
+	// fileMode is the mode of generated files, the one of their template if 0
+	fileMode os.FileMode
+	// lineDirectives writes //line directives mapping generated files back to their template, see fileGenerator
+	lineDirectives bool
+	// merge generates the sources of all the inputs into a single output, see generateMerged
+	merge bool
+	// backup saves overwritten generated files as .bak files, in backupDir if set
+	backup    bool
+	backupDir string
+	// force overwrites generated files edited since they were generated
+	force bool
+	// recordFiles records the generated files in manifestFiles, for the manifest, the audit log and the lockfile
+	recordFiles   bool
+	manifestFiles []manifestFile
+	// log is where warnings and progress are written
+	log *logger
+	// report is the summary of the run printed by --report, nil without it
+	report *runReport
+	// progress reports how many files are done, nil with --quiet
+	progress *progress
+	// timings are the durations of the phases of the run and of its files, for its summary
+	timings *runTimings
+	// mu guards stale, manifestFiles and stdout, shared by concurrent generations
+	mu sync.Mutex
+}
+
+// generatePath generates the source for path, path being either a file (with a matching .safekeeper template),
+// a template or a directory of templates
+func (g *generation) generatePath(path string, out string) error {
+	if path == stdio {
+		g.progress.add(1)
+		return g.generateFile(path, out)
+	}
+	path = outputPath(path, g.finder.ext)
+
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		// The output doesn't exist yet, it only has to have a template
+		if _, templateErr := os.Stat(g.finder.ext.templateOf(path)); templateErr == nil {
+			g.progress.add(1)
+			return g.generateFile(path, g.outputIn(path, out))
+		}
+		return withExitCode(exitTemplateNotFound, err)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		g.progress.add(1)
+		return g.generateFile(path, g.outputIn(path, out))
+	}
+
+	if out != "" {
+		return errors.New("--output can't be used with a directory input")
+	}
+	return g.generateDir(path)
+}
+
+// outputIn returns out or, if it's empty, the file named after path in the output directory if there's one,
+// according to the output pattern if any
+func (g *generation) outputIn(path string, out string) string {
+	if out != "" {
+		return out
+	}
+	if g.outputDir != "" {
+		out = filepath.Join(g.outputDir, filepath.Base(path))
+	}
+	return g.patternOutput(path, out)
+}
+
+// generateFile generates the source for path from its .safekeeper template and writes it to out (or path
+// if out is empty)
+func (g *generation) generateFile(path string, out string) (err error) {
+	g.jobs.acquire()
+	defer g.jobs.release()
+	defer g.progress.step()
+	if err := contextErr(g.ctx); err != nil {
+		return err
+	}
+	out = g.settingsOutput(path, out)
+	if err := g.checkOutputNotTemplate(path, valueOr(out, path)); err != nil {
+		return err
+	}
+
+	var report *fileReport
+	if g.report != nil {
+		template := path
+		if path != stdio {
+			template = g.finder.ext.templateOf(path)
+		}
+		report = g.report.startFile(template)
+		defer func() { g.report.endFile(report, err) }()
+	}
+
+	var buffer bytes.Buffer
+	if err := g.writeHeader(&buffer, path, out); err != nil {
+		return err
+	}
+	headerLen := buffer.Len()
+
+	if destination := valueOr(out, path); g.streamable(path, destination) {
+		if g.outputDir != "" || g.outputPattern != "" {
+			if err := os.MkdirAll(filepath.Dir(destination), 0755); err != nil {
+				return withExitCode(exitWriteFailure, err)
+			}
+		}
+		writing := time.Now()
+		err := g.streamFile(path, destination, buffer.Bytes())
+		g.timings.timeFile(report, destination, 0, time.Since(writing))
 		if err != nil {
 			return err
 		}
-	} else {
-		return errors.New("Only single file inputs are currently supported")
+		g.log.fileInfof(destination, "Generated [%s] from the template of [%s]", destination, path)
+		return nil
+	}
+
+	substituting := time.Now()
+	src, template, err := g.substituteValues(path, valueOr(out, path), &buffer)
+	if err != nil {
+		return err
+	}
+	src = g.withBOM(g.withLineEndings(src, headerLen, template), template)
+	if err := report.count(&g.generator, template, src, g.keyValues); err != nil {
+		return err
+	}
+	substitution, writing := time.Since(substituting), time.Now()
+	defer func() { g.timings.timeFile(report, valueOr(out, path), substitution, time.Since(writing)) }()
+	return g.finishFile(path, out, template, src, report)
+}
+
+// finishFile checks the source src generated from template as configured and writes it to out (or path if out
+// is empty), or compares it with the file on disk with --check and --dry-run
+func (g *generation) finishFile(path string, out string, template []byte, src []byte, report *fileReport) (err error) {
+	if g.strict {
+		if err := g.checkReplaced(path, template, src); err != nil {
+			// The report locates the placeholders in the file that would have been generated
+			report.setStatus(statusFailed, valueOr(out, path))
+			return err
+		}
 	}
 
+	if g.format {
+		if src, err = formatSource(src); err != nil {
+			return err
+		}
+	} else if g.validate {
+		if err := validateSource(src); err != nil {
+			return err
+		}
+	}
+
+	// Write to file.
+	if out == "" {
+		out = path
+	}
+	if out != stdio {
+		if g.fingerprint {
+			values, err := g.fingerprintValues(template)
+			if err != nil {
+				return err
+			}
+			src = safekeeper.AddFingerprints(src, g.fingerprintKey, values)
+		}
+		// The hash lets regenerations tell if the file was edited since
+		src = safekeeper.AddContentHash(src)
+	}
+	if err := g.record(path, out, template, src); err != nil {
+		return err
+	}
+	if out == stdio {
+		report.setStatus(statusGenerated, out)
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		_, err := g.stdout.Write(src)
+		return withExitCode(exitWriteFailure, err)
+	}
+	if g.check {
+		report.setStatus(statusUpToDate, out)
+		if current, err := ioutil.ReadFile(out); err != nil || !bytes.Equal(current, src) || g.expired(current) {
+			report.setStatus(statusStale, out)
+			reason := g.staleReason(current)
+			g.log.fileInfof(out, "[%s] is out of date%s", out, reason)
+			g.mu.Lock()
+			g.stale = append(g.stale, out+reason)
+			g.mu.Unlock()
+		}
+		return nil
+	}
+	if g.dryRun {
+		report.setStatus(statusDiffed, out)
+		return g.printDiff(out, src, template)
+	}
+	report.setStatus(statusGenerated, out)
+	if g.outputDir != "" || g.outputPattern != "" {
+		if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+			return withExitCode(exitWriteFailure, err)
+		}
+	}
+	if err := g.writeOutput(path, out, src); err != nil {
+		return err
+	}
+	g.log.fileInfof(out, "Generated [%s] from the template of [%s]", out, path)
 	return nil
 }
 
-// loadKeyValues loads all values for the keys specified via the command-line flag
-func loadKeyValues(keys []string) (map[string]string, error) {
-	keyValues := make(map[string]string)
-	for _, key := range keys {
-		if value := os.Getenv(key); value == "" {
-			return nil, errors.New(fmt.Sprintf("Environment variable [%s] not found", key))
-		} else {
-			keyValues[key] = value
+// writeDefaultHeader writes the header of the file generated from path to out, unless configured otherwise
+func (g *generation) writeDefaultHeader(w io.Writer, path string, out string) error {
+	if g.lang == langText {
+		if out == "" {
+			out = path
 		}
+		if syntax, ok := commentSyntaxOf(out); ok && out != stdio {
+			return safekeeper.WriteGeneratedNotice(w, syntax.start, syntax.end)
+		}
+		return nil
 	}
 
-	return keyValues, nil
+	// There's no file for go:generate to regenerate when streaming, nor when the template isn't next to the
+	// generated file, names it or is merged with others
+	if path == stdio || out == stdio || g.outputDir != "" || g.outputPattern != "" || g.hasSettingsOutput(path) || g.merge {
+		return safekeeper.WriteGeneratedComment(w)
+	}
+	// The go:generate line running safekeeper is kept as is in the file it regenerates
+	if g.directive.line != "" && equalPaths(path, g.directive.file) {
+		if err := safekeeper.WriteGeneratedComment(w); err != nil {
+			return err
+		}
+		_, err := io.WriteString(w, g.directive.line+"\n")
+		return err
+	}
+	return g.generator.WriteHeader(w, g.keys, out, g.headerArgs()...)
 }
 
-// isFile reports whether the named file is a file (not a directory).
-func isFile(name string) bool {
-	info, err := os.Stat(name)
-	if err != nil {
-		log.Fatal(err)
+// headerArgs returns the flags of the go:generate line of the header that aren't settings of the generator, all
+// the other flags the generated file depends on for the line to regenerate it as is
+func (g *generation) headerArgs() []string {
+	var args []string
+	if g.keysFile != "" {
+		args = append(args, "--keys-file="+filepath.ToSlash(g.keysFile))
+	}
+	mapped := make([]string, 0, len(g.keyMap))
+	for key := range g.keyMap {
+		mapped = append(mapped, key)
+	}
+	sort.Strings(mapped)
+	for _, key := range mapped {
+		args = append(args, "--map="+key+"="+g.keyMap[key])
+	}
+	for _, source := range g.sources {
+		args = append(args, "--source="+source)
+	}
+	for _, file := range g.secretsFiles {
+		args = append(args, "--secrets-file="+filepath.ToSlash(file))
 	}
-	return !info.IsDir()
+	for _, file := range g.envFiles {
+		args = append(args, "--env-file="+filepath.ToSlash(file))
+	}
+	if g.format {
+		args = append(args, "--format")
+	} else if g.validate {
+		args = append(args, "--validate")
+	}
+	if g.strict {
+		args = append(args, "--strict")
+	}
+	if ext := g.finder.ext; ext != "" && ext != templateExt {
+		args = append(args, "--template-ext="+string(ext))
+	}
+	if g.reproducible {
+		args = append(args, "--reproducible")
+	}
+	if g.valueTTL > 0 {
+		args = append(args, "--value-ttl="+g.valueTTL.String())
+	}
+	if g.fingerprint {
+		args = append(args, "--fingerprint")
+	}
+	if g.lineDirectives {
+		args = append(args, "--line-directives")
+	}
+	if g.lineEndings == lineEndingsCRLF || (g.lineEndings == lineEndingsLF && !g.reproducible) {
+		args = append(args, "--line-endings="+g.lineEndings)
+	}
+	if g.fileMode != 0 {
+		args = append(args, fmt.Sprintf("--file-mode=%04o", g.fileMode))
+	}
+	if g.buildConstraint != "" {
+		args = append(args, "--build-tags="+strings.TrimPrefix(g.buildConstraint, "//go:build "))
+	}
+	return args
 }
 
-// substituteValues replaces all occurences of keys in the source file by the env value
-// of that key
-func substituteValues(path string, keyValues map[string]string, buffer *bytes.Buffer) ([]byte, error) {
-	file, err := openTemplateFile(path)
+// printDiff prints the unified diff between the file at out and src, the file being considered empty if it
+// doesn't exist. Values are masked from both when src was generated from template, so that the diff tells which
+// values changed without showing them.
+func (g *generation) printDiff(out string, src []byte, template []byte) error {
+	fromName := out
+	current, err := ioutil.ReadFile(out)
+	if os.IsNotExist(err) {
+		fromName = os.DevNull
+	} else if err != nil {
+		return err
+	}
+	if template != nil {
+		values := safekeeper.Reveal(g.keyValues)
+		if current, err = g.generator.Mask(current, template, values); err != nil {
+			return err
+		}
+		if src, err = g.generator.Mask(src, template, values); err != nil {
+			return err
+		}
+	}
+
+	// The diff is written at once so that the diffs of concurrent generations don't interleave
+	var diff bytes.Buffer
+	if err := writeUnifiedDiff(&diff, fromName, out, string(current), string(src)); err != nil {
+		return err
+	}
+
+	colored := diff.Bytes()
+	if g.color {
+		colored = colorDiff(colored)
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	_, err = g.stdout.Write(colored)
+	return err
+}
+
+// checkReplaced fails if the source src generated from template, the one of path, still has placeholders,
+// reporting their keys at the lines of the template (template:line), or of the generated source for the ones
+// that came with values
+func (g *generation) checkReplaced(path string, template []byte, src []byte) error {
+	// Executed templates have nothing left to replace, keys without a value being empty
+	if g.generator.Engine == safekeeper.GoTemplateEngine {
+		return nil
+	}
+	left, err := g.generator.FindPlaceholders(bytes.NewReader(src))
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer file.Close()
-	replacers := setupReplacers(keyValues)
-	scanner := bufio.NewScanner(file)
-
-	for scanner.Scan() {
-		line := scanner.Text()
-		// Any go:generate safekeeper line should be ignored since it was read from the original source and
-		// is going to be included in the header
-		if !(strings.Contains(line, "go:generate") && strings.Contains(line, "safekeeper")) {
-			for _, replacer := range replacers {
-				line = replacer.Replace(line)
-			}
-			buffer.WriteString(fmt.Sprintln(line))
+	if len(left) == 0 {
+		return nil
+	}
+
+	text, err := templateText(path, template)
+	if err != nil {
+		return err
+	}
+	placeholders, err := g.generator.UnreplacedPlaceholders(bytes.NewReader(text), safekeeper.Reveal(g.keyValues))
+	if err != nil {
+		return err
+	}
+	templateName := path
+	if path != stdio {
+		templateName = g.finder.ext.templateOf(path)
+	}
+	positions := make([]string, len(placeholders))
+	located := make(map[string]bool, len(placeholders))
+	for i, placeholder := range placeholders {
+		positions[i] = fmt.Sprintf("%s:%d", templateName, placeholder.Line)
+		located[placeholder.Key] = true
+	}
+	for _, placeholder := range left {
+		if !located[placeholder.Key] {
+			placeholders = append(placeholders, placeholder)
+			positions = append(positions, fmt.Sprintf("line %d of the generated source", placeholder.Line))
 		}
 	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, err
+	// Keys that are typos of known keys are likely why they're left
+	known := append([]string{}, g.keys...)
+	for key := range g.keyValues {
+		known = append(known, key)
+	}
+	remaining := make([]string, len(placeholders))
+	for i, placeholder := range placeholders {
+		remaining[i] = fmt.Sprintf("[%s] at %s", placeholder.Key, positions[i])
+		if suggestion, ok := suggestKey(placeholder.Key, known); ok {
+			remaining[i] += fmt.Sprintf(" (did you mean [%s]?)", suggestion)
+		}
 	}
+	return withExitCode(exitMissingValue, fmt.Errorf("Unreplaced placeholders: %s", strings.Join(remaining, ", ")))
+}
 
-	return buffer.Bytes(), nil
+// parseKeys returns the names of the comma-delimited keys, adding the ones marked optional with a ? suffix
+// to optional
+func parseKeys(keys string, optional map[string]bool) []string {
+	names := strings.Split(keys, ",")
+	for i, name := range names {
+		if strings.HasSuffix(name, "?") {
+			names[i] = strings.TrimSuffix(name, "?")
+			optional[names[i]] = true
+		}
+	}
+	return names
 }
 
-// openTemplateFile opens the template source for the current file (by appending .safekeeper to the path)
-func openTemplateFile(path string) (*os.File, error) {
-	templateFileName := fmt.Sprintf("%s.safekeeper", path)
-	return os.Open(templateFileName)
+// parseOptionalKeys returns the set of the comma-delimited optional keys
+func parseOptionalKeys(keys string) map[string]bool {
+	optional := make(map[string]bool)
+	for _, key := range strings.Split(keys, ",") {
+		if key != "" {
+			optional[key] = true
+		}
+	}
+	return optional
+}
+
+// annotateKeys returns keys with a ? suffix for the optional ones, as they're written in the go:generate line
+func annotateKeys(keys []string, optional map[string]bool) []string {
+	annotated := make([]string, len(keys))
+	for i, key := range keys {
+		annotated[i] = key
+		if optional[key] {
+			annotated[i] += "?"
+		}
+	}
+	return annotated
+}
+
+// loadKeyValues loads all values for the keys specified via the command-line flag from the given source, giving
+// up once ctx is done. Keys in defaulted, having a default in the templates, are left out of the values when not
+// found and optional keys get an empty value. All the keys without a value are reported at once.
+func loadKeyValues(ctx context.Context, keys []string, source safekeeper.SecretSource, defaulted map[string]bool, optional map[string]bool) (map[string]string, error) {
+	keyValues := make(map[string]string)
+	var missing []string
+	for _, key := range keys {
+		value, found, err := safekeeper.ResolveContext(ctx, source, key)
+		if ctxErr := contextErr(ctx); ctxErr != nil {
+			return nil, ctxErr
+		}
+		if err != nil {
+			return nil, withExitCode(exitSourceFailure, err)
+		}
+		if !found && defaulted[key] {
+			continue
+		}
+		if !found && optional[key] {
+			keyValues[key] = ""
+			continue
+		}
+		if !found {
+			missing = append(missing, resolvedName(source, key))
+			continue
+		}
+		keyValues[key] = value
+	}
+
+	if len(missing) > 0 {
+		return nil, withExitCode(exitMissingValue, missingKeysError(missing, isEnvSource(source)))
+	}
+
+	return keyValues, nil
+}
 
+// missingKeysError returns the error reporting the keys without a value, as environment variables if fromEnv
+func missingKeysError(keys []string, fromEnv bool) error {
+	names := strings.Join(keys, ", ")
+	switch {
+	case fromEnv && len(keys) == 1:
+		return errors.New(fmt.Sprintf("Environment variable [%s] not found", names))
+	case fromEnv:
+		return errors.New(fmt.Sprintf("Environment variables [%s] not found", names))
+	case len(keys) == 1:
+		return errors.New(fmt.Sprintf("Value for key [%s] not found", names))
+	default:
+		return errors.New(fmt.Sprintf("Values for keys [%s] not found", names))
+	}
 }
 
-// writeHeader writes the header of the file (code generation warning as well as the go:generate line)
-func writeHeader(buffer *bytes.Buffer, keyNames []string, output string) error {
-	ew := &errWriter{b: buffer}
-	ew.writeString(fmt.Sprintln("// GENERATED by safekeeper (https://github.com/alexandre-normand/safekeeper, DO NOT EDIT"))
-	ew.writeString(fmt.Sprintf("//go:generate safekeeper --keys=%s", strings.Join(keyNames, ",")))
-	if output != "" {
-		ew.writeString(fmt.Sprintf(" --output=%s", output))
+// substituteValues replaces all occurences of keys in the source file by the env value
+// of that key, for the file generated to out
+func (g *generation) substituteValues(path string, out string, buffer *bytes.Buffer) (src []byte, template []byte, err error) {
+	file, err := openTemplateFile(g.finder.filesystem(), path, g.finder.ext, g.stdin)
+	if os.IsNotExist(err) {
+		return nil, nil, withExitCode(exitTemplateNotFound, err)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	if template, err = ioutil.ReadAll(file); err != nil {
+		return nil, nil, err
+	}
+	text, err := templateText(path, template)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := checkTemplateNotGenerated(g.finder.ext, path, text); err != nil {
+		return nil, nil, err
+	}
+	generator := g.fileGenerator(path, out)
+	if err := generator.GenerateContext(g.ctx, bytes.NewReader(text), buffer, safekeeper.Reveal(g.keyValues)); err != nil {
+		if ctxErr := contextErr(g.ctx); ctxErr != nil {
+			return nil, nil, ctxErr
+		}
+		return nil, nil, err
 	}
-	ew.writeString(" $GOFILE\n")
 
-	return ew.err
+	return buffer.Bytes(), template, nil
 }
 
-// setupReplacers creates a string replacer for each key/value pair
-func setupReplacers(keyValues map[string]string) []strings.Replacer {
-	replacers := make([]strings.Replacer, len(keyValues))
-	i := 0
-	for key, value := range keyValues {
-		replacers[i] = *strings.NewReplacer(fmt.Sprintf("ENV_%s", key), value)
-		i = i + 1
+// openTemplateFile opens the template source for the current file (e.g. by appending .safekeeper to the path).
+// The template of - is stdin, read beforehand.
+func openTemplateFile(fsys fs.FS, path string, ext templateExtension, stdin []byte) (io.ReadCloser, error) {
+	if path == stdio {
+		return ioutil.NopCloser(bytes.NewReader(stdin)), nil
 	}
 
-	return replacers
+	templateFileName := ext.templateOf(path)
+	return fsys.Open(templateFileName)
 }
@@ -1,12 +1,16 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
 )
 
 func TestMissingEnvVariable(t *testing.T) {
@@ -26,7 +30,7 @@ func TestMissingEnvVariable(t *testing.T) {
 	}
 
 	generatedFile := filepath.Join(tempDir, "appsecrets.go")
-	err = run("CLIENT_ID,CLIENT_SECRET", generatedFile, []string{generationDriverFile})
+	err = run(options{keys: "CLIENT_ID,CLIENT_SECRET", output: generatedFile, paths: []string{generationDriverFile}})
 	if !strings.Contains(err.Error(), "CLIENT_ID") || !strings.HasSuffix(err.Error(), "not found") {
 		t.Fatalf("Error should mention missing environment variable CLIENT_ID but was [%s]", err.Error())
 	}
@@ -47,7 +51,7 @@ func TestMissingSafekeeperFile(t *testing.T) {
 	os.Setenv("CLIENT_SECRET", "safesecret")
 
 	generatedFile := filepath.Join(tempDir, "appsecrets.go")
-	err = run("CLIENT_ID,CLIENT_SECRET", generatedFile, []string{generationDriverFile})
+	err = run(options{keys: "CLIENT_ID,CLIENT_SECRET", output: generatedFile, paths: []string{generationDriverFile}})
 	if !strings.Contains(err.Error(), "secrets.go.safekeeper") || !strings.HasSuffix(err.Error(), "no such file or directory") {
 		t.Fatalf("Error should mention missing .safekeeper file but was [%s]", err.Error())
 	}
@@ -73,7 +77,7 @@ func TestValidCase(t *testing.T) {
 	os.Setenv("CLIENT_SECRET", "safesecret")
 
 	generatedFile := filepath.Join(tempDir, "appsecrets.go")
-	err = run("CLIENT_ID,CLIENT_SECRET", generatedFile, []string{generationDriverFile})
+	err = run(options{keys: "CLIENT_ID,CLIENT_SECRET", output: generatedFile, paths: []string{generationDriverFile}})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -100,12 +104,12 @@ func TestValidCase(t *testing.T) {
 
 	expectedClientIdLine := "appSecrets.ClientId = \"safeid\""
 	if !strings.Contains(string(output), expectedClientIdLine) {
-		t.Errorf("Result file should have replaced ENV_CLIENT_ID with the client id value \"safeid\" but was: \n\n%s", expectedClientIdLine, string(output))
+		t.Errorf("Result file should have replaced ENV_CLIENT_ID with the client id value [%s] but was: \n\n%s", expectedClientIdLine, string(output))
 	}
 
 	expectedClientSecretLine := "appSecrets.ClientSecret = \"safesecret\""
 	if !strings.Contains(string(output), expectedClientSecretLine) {
-		t.Errorf("Result file should have replaced ENV_CLIENT_SECRET with the client secret value \"safesecret\" but was: \n\n%s", expectedClientIdLine, string(output))
+		t.Errorf("Result file should have replaced ENV_CLIENT_SECRET with the client secret value [%s] but was: \n\n%s", expectedClientSecretLine, string(output))
 	}
 }
 
@@ -117,7 +121,7 @@ func Example() {
 	os.Setenv("CLIENT_SECRET", "safesecret")
 
 	generatedFile := filepath.Join(tempDir, "appsecrets.go")
-	run("CLIENT_ID,CLIENT_SECRET", generatedFile, []string{generationDriverFile})
+	run(options{keys: "CLIENT_ID,CLIENT_SECRET", output: generatedFile, paths: []string{generationDriverFile}})
 
 	ouputFile, _ := os.Open(generatedFile)
 
@@ -162,3 +166,430 @@ func writeGenerationDriverFile(tempDir string) (driverPath string, err error) {
 
 	return driverFile, nil
 }
+
+func TestMultipleFileInputs(t *testing.T) {
+	validDir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(validDir)
+
+	brokenDir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(brokenDir)
+
+	if _, err := writeTestTemplate(validDir); err != nil {
+		t.Fatal(err)
+	}
+
+	validDriverFile, err := writeGenerationDriverFile(validDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	brokenDriverFile, err := writeGenerationDriverFile(brokenDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("CLIENT_ID", "safeid")
+	os.Setenv("CLIENT_SECRET", "safesecret")
+
+	missingFile := filepath.Join(brokenDir, "missing.go")
+	err = run(options{keys: "CLIENT_ID,CLIENT_SECRET", paths: []string{brokenDriverFile, validDriverFile, missingFile}})
+	if err == nil {
+		t.Fatal("Expected an error for the inputs without templates")
+	}
+
+	for _, failed := range []string{brokenDriverFile, missingFile} {
+		if !strings.Contains(err.Error(), failed) {
+			t.Errorf("Error should report failed input [%s] but was [%s]", failed, err.Error())
+		}
+	}
+
+	if strings.Contains(err.Error(), validDriverFile+":") {
+		t.Errorf("Error shouldn't report valid input [%s] but was [%s]", validDriverFile, err.Error())
+	}
+
+	output, err := ioutil.ReadFile(validDriverFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedClientIdLine := "appSecrets.ClientId = \"safeid\""
+	if !strings.Contains(string(output), expectedClientIdLine) {
+		t.Errorf("Valid input should have been generated despite other failures but was: \n\n%s", string(output))
+	}
+}
+
+func TestDiscoveredKeys(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	generationDriverFile, err := writeGenerationDriverFile(tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writeTestTemplate(tempDir); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("CLIENT_ID", "safeid")
+	os.Setenv("CLIENT_SECRET", "safesecret")
+	defer os.Unsetenv("CLIENT_ID")
+
+	generatedFile := filepath.Join(tempDir, "appsecrets.go")
+	err = run(options{output: generatedFile, paths: []string{generationDriverFile}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := ioutil.ReadFile(generatedFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{"appSecrets.ClientId = \"safeid\"", "appSecrets.ClientSecret = \"safesecret\"", "//go:generate safekeeper --output=" + generatedFile + " $GOFILE"} {
+		if !strings.Contains(string(output), expected) {
+			t.Errorf("Generated file should contain [%s] but was: \n\n%s", expected, string(output))
+		}
+	}
+}
+
+func TestDefaultValues(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	template := "package secrets\n\nconst (\n\tApiUrl = \"ENV_SAFEKEEPER_API_URL:-https://api.example.com\"\n\tRegion = \"ENV_SAFEKEEPER_REGION:-us-east-1\"\n)\n"
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "config.go.safekeeper"), []byte(template), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("SAFEKEEPER_REGION", "eu-west-1")
+	defer os.Unsetenv("SAFEKEEPER_REGION")
+
+	generatedFile := filepath.Join(tempDir, "config.go")
+	if err := run(options{keys: "SAFEKEEPER_API_URL,SAFEKEEPER_REGION", paths: []string{generatedFile}}); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := ioutil.ReadFile(generatedFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{"ApiUrl = \"https://api.example.com\"", "Region = \"eu-west-1\"", "--keys=SAFEKEEPER_API_URL,SAFEKEEPER_REGION $GOFILE"} {
+		if !strings.Contains(string(output), expected) {
+			t.Errorf("Generated file should contain [%s] but was: \n\n%s", expected, string(output))
+		}
+	}
+}
+
+func TestOptionalKeys(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	template := "package secrets\n\nconst (\n\tToken = \"ENV_SAFEKEEPER_TOKEN\"\n\tDebug = \"ENV_SAFEKEEPER_DEBUG\"\n\tTrace = \"ENV_SAFEKEEPER_TRACE\"\n)\n"
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "config.go.safekeeper"), []byte(template), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("SAFEKEEPER_TOKEN", "s3cr3t")
+	defer os.Unsetenv("SAFEKEEPER_TOKEN")
+
+	generatedFile := filepath.Join(tempDir, "config.go")
+	err = run(options{keys: "SAFEKEEPER_TOKEN,SAFEKEEPER_DEBUG?,SAFEKEEPER_TRACE", optionalKeys: "SAFEKEEPER_TRACE", paths: []string{generatedFile}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := ioutil.ReadFile(generatedFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{"Token = \"s3cr3t\"", "Debug = \"\"", "Trace = \"\"", "--keys=SAFEKEEPER_TOKEN,SAFEKEEPER_DEBUG?,SAFEKEEPER_TRACE? $GOFILE"} {
+		if !strings.Contains(string(output), expected) {
+			t.Errorf("Generated file should contain [%s] but was: \n\n%s", expected, string(output))
+		}
+	}
+}
+
+func TestAllMissingKeysReported(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	generationDriverFile, err := writeGenerationDriverFile(tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writeTestTemplate(tempDir); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("SAFEKEEPER_PRESENT", "value")
+	defer os.Unsetenv("SAFEKEEPER_PRESENT")
+
+	err = run(options{keys: "SAFEKEEPER_MISSING_A,SAFEKEEPER_PRESENT,SAFEKEEPER_MISSING_B", paths: []string{generationDriverFile}})
+	if err == nil {
+		t.Fatal("Expected missing keys to fail the generation")
+	}
+
+	expected := "Environment variables [SAFEKEEPER_MISSING_A, SAFEKEEPER_MISSING_B] not found"
+	if err.Error() != expected {
+		t.Errorf("Expected error [%s] but got [%s]", expected, err)
+	}
+	if code := exitCode(err); code != exitMissingValue {
+		t.Errorf("Expected exit code %d but got %d", exitMissingValue, code)
+	}
+}
+
+func TestCustomDelimiters(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	template := "package secrets\n\nconst Token = \"{{SAFEKEEPER_TOKEN}}\"\n\nvar ENV_SAFEKEEPER_TOKEN = 1\n"
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "config.go.safekeeper"), []byte(template), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("SAFEKEEPER_TOKEN", "s3cr3t")
+	defer os.Unsetenv("SAFEKEEPER_TOKEN")
+
+	generatedFile := filepath.Join(tempDir, "config.go")
+	if err := run(options{prefix: "{{", suffix: "}}", paths: []string{generatedFile}}); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := ioutil.ReadFile(generatedFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{"Token = \"s3cr3t\"", "var ENV_SAFEKEEPER_TOKEN = 1", "//go:generate safekeeper --prefix={{ --suffix=}} $GOFILE"} {
+		if !strings.Contains(string(output), expected) {
+			t.Errorf("Generated file should contain [%s] but was: \n\n%s", expected, string(output))
+		}
+	}
+}
+
+func TestASTMode(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	template := "package secrets\n\n// ENV_SAFEKEEPER_TOKEN is the API token\nvar ENV_SAFEKEEPER_TOKEN = \"ENV_SAFEKEEPER_TOKEN\"\n"
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "config.go.safekeeper"), []byte(template), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("SAFEKEEPER_TOKEN", "s3cr3t")
+	defer os.Unsetenv("SAFEKEEPER_TOKEN")
+
+	generatedFile := filepath.Join(tempDir, "config.go")
+	if err := run(options{mode: "ast", paths: []string{generatedFile}}); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := ioutil.ReadFile(generatedFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{"// ENV_SAFEKEEPER_TOKEN is the API token", "var ENV_SAFEKEEPER_TOKEN = \"s3cr3t\"", "//go:generate safekeeper --mode=ast $GOFILE"} {
+		if !strings.Contains(string(output), expected) {
+			t.Errorf("Generated file should contain [%s] but was: \n\n%s", expected, string(output))
+		}
+	}
+}
+
+func TestStrictMode(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	generationDriverFile, err := writeGenerationDriverFile(tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writeTestTemplate(tempDir); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("CLIENT_ID", "safeid")
+	defer os.Unsetenv("CLIENT_ID")
+
+	generatedFile := filepath.Join(tempDir, "appsecrets.go")
+	err = run(options{keys: "CLIENT_ID", output: generatedFile, strict: true, paths: []string{generationDriverFile}})
+	if err == nil || !strings.Contains(err.Error(), "[CLIENT_SECRET] at "+filepath.Join(tempDir, "secrets.go"+templateExt)+":12") {
+		t.Fatalf("Expected the unreplaced CLIENT_SECRET placeholder to be reported but got [%v]", err)
+	}
+	if _, err := os.Stat(generatedFile); !os.IsNotExist(err) {
+		t.Errorf("Expected no file to be written in strict mode but got [%v]", err)
+	}
+}
+
+func TestCheckMode(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	generationDriverFile, err := writeGenerationDriverFile(tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writeTestTemplate(tempDir); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("CLIENT_ID", "safeid")
+	os.Setenv("CLIENT_SECRET", "safesecret")
+	defer os.Unsetenv("CLIENT_ID")
+
+	generatedFile := filepath.Join(tempDir, "appsecrets.go")
+	opts := options{keys: "CLIENT_ID,CLIENT_SECRET", output: generatedFile, paths: []string{generationDriverFile}}
+
+	opts.check = true
+	if err := run(opts); exitCode(err) != exitStale || !strings.Contains(err.Error(), generatedFile) {
+		t.Fatalf("Expected the missing file to be reported as out of date but got [%v]", err)
+	}
+	if _, err := os.Stat(generatedFile); !os.IsNotExist(err) {
+		t.Fatalf("Expected --check not to write anything but got [%v]", err)
+	}
+
+	opts.check = false
+	if err := run(opts); err != nil {
+		t.Fatal(err)
+	}
+
+	opts.check = true
+	if err := run(opts); err != nil {
+		t.Errorf("Expected the generated file to be up to date but got [%s]", err)
+	}
+
+	os.Setenv("CLIENT_SECRET", "rotated")
+	if err := run(opts); exitCode(err) != exitStale {
+		t.Errorf("Expected the generated file to be out of date after a value change but got [%v]", err)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	generationDriverFile, err := writeGenerationDriverFile(tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writeTestTemplate(tempDir); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("CLIENT_ID", "safeid")
+	os.Setenv("CLIENT_SECRET", "safesecret")
+	defer os.Unsetenv("CLIENT_ID")
+
+	generatedFile := filepath.Join(tempDir, "appsecrets.go")
+	opts := options{keys: "CLIENT_ID,CLIENT_SECRET", output: generatedFile, paths: []string{generationDriverFile}}
+	if err := run(opts); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("CLIENT_SECRET", "rotated")
+	var diff bytes.Buffer
+	opts.dryRun, opts.stdout = true, &diff
+	if err := run(opts); err != nil {
+		t.Fatal(err)
+	}
+
+	// Values are masked, the markers telling that the secret changed
+	for _, expected := range []string{"--- " + generatedFile, "-appSecrets.ClientSecret = \"" + safekeeper.MaskValue("safesecret") + "\"", "+appSecrets.ClientSecret = \"" + safekeeper.MaskValue("rotated") + "\""} {
+		if !strings.Contains(diff.String(), expected) {
+			t.Errorf("Diff should contain [%s] but was: \n\n%s", expected, diff.String())
+		}
+	}
+	if strings.Contains(diff.String(), "safesecret") || strings.Contains(diff.String(), "rotated") {
+		t.Errorf("Diff shouldn't show values but was: \n\n%s", diff.String())
+	}
+
+	output, err := ioutil.ReadFile(generatedFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(output), "safesecret") {
+		t.Errorf("Expected --dry-run to leave the generated file untouched but was: \n\n%s", string(output))
+	}
+}
+
+func TestStdinToStdout(t *testing.T) {
+	os.Setenv("SAFEKEEPER_TOKEN", "s3cr3t")
+	defer os.Unsetenv("SAFEKEEPER_TOKEN")
+
+	var output bytes.Buffer
+	err := run(options{paths: []string{"-"}, stdin: strings.NewReader("token: ENV_SAFEKEEPER_TOKEN\n"), stdout: &output})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "// GENERATED by safekeeper (https://github.com/alexandre-normand/safekeeper, DO NOT EDIT\ntoken: s3cr3t\n"
+	if output.String() != expected {
+		t.Errorf("Expected [%s] on stdout but got [%s]", expected, output.String())
+	}
+}
+
+func TestCommandLine(t *testing.T) {
+	// Flags of commands can't clash with the global ones, which kingpin only reports when parsing
+	if _, err := kingpin.CommandLine.Parse([]string{"scan", "--report=sarif"}); err != nil {
+		t.Fatalf("Expected the command line to parse but got [%s]", err)
+	}
+
+	// - reads the template from stdin, even though kingpin takes it for a short flag
+	cases := map[string][]string{
+		"- --keys=TOKEN":                      {"-", "--keys=TOKEN"},
+		"--keys=TOKEN - --output -":           {"--keys=TOKEN", "-", "--output", "-"},
+		"--check --output=- -":                {"--check", "--output=-", "-"},
+		"generate --keys=TOKEN -- -":          {"generate", "--keys=TOKEN", "--", "-"},
+		"generate --keys TOKEN - -- other.go": {"generate", "--keys", "TOKEN", "-", "--", "other.go"},
+	}
+	for name, args := range cases {
+		*paths, *output = nil, ""
+		command, err := kingpin.CommandLine.Parse(stdioArgs(kingpin.CommandLine, args))
+		if err != nil {
+			t.Errorf("Expected [%s] to parse but got [%s]", name, err)
+			continue
+		}
+		if command != generateCommand.FullCommand() || len(*paths) == 0 || (*paths)[0] != stdio {
+			t.Errorf("Expected [%s] to generate from stdin but got command %s with paths %v", name, command, *paths)
+		}
+		if strings.Contains(name, "--output") && *output != stdio {
+			t.Errorf("Expected [%s] to write to stdout but got [%s]", name, *output)
+		}
+	}
+	*paths, *output = nil, ""
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+)
+
+// SARIF 2.1.0 (https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html), the format of the findings
+// that GitHub code scanning and other security dashboards ingest, as far as safekeeper needs it
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+// Levels of SARIF results
+const (
+	sarifError   = "error"
+	sarifWarning = "warning"
+)
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+// sarifRule describes the findings of a kind, e.g. aws-access-key-id
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+// sarifResult is a finding of a rule, at a line of a file
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// newSARIFResult returns the result of rule at line of the file at path, with message
+func newSARIFResult(rule string, level string, path string, line int, message string) sarifResult {
+	location := sarifLocation{PhysicalLocation: sarifPhysicalLocation{
+		ArtifactLocation: sarifArtifactLocation{URI: sarifURI(path)},
+		Region:           sarifRegion{StartLine: line},
+	}}
+	return sarifResult{RuleID: rule, Level: level, Message: sarifMessage{Text: message}, Locations: []sarifLocation{location}}
+}
+
+// sarifURI returns the URI of the file at path in results: relative to the working directory when under it, for
+// dashboards to map it to the files of the repository, with forward slashes
+func sarifURI(path string) string {
+	if filepath.IsAbs(path) {
+		if abs, err := filepath.Abs("."); err == nil {
+			if rel, err := filepath.Rel(abs, path); err == nil && within(path, abs) {
+				path = rel
+			}
+		}
+	}
+	return filepath.ToSlash(filepath.Clean(path))
+}
+
+// writeSARIF writes the results of rules as the SARIF log of a single run of safekeeper at version to w
+func writeSARIF(w io.Writer, version string, rules []sarifRule, results []sarifResult) error {
+	if results == nil {
+		results = []sarifResult{}
+	}
+	log := sarifLog{
+		Version: sarifVersion,
+		Schema:  sarifSchema,
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "safekeeper",
+				Version:        version,
+				InformationURI: "https://github.com/alexandre-normand/safekeeper",
+				Rules:          rules,
+			}},
+			Results: results,
+		}},
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(log)
+}
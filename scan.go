@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// maxScannedFileSize is the size of the largest file scanned for secrets, larger files being data more often
+// than sources
+const maxScannedFileSize = 1 << 20
+
+// secretRule is a known pattern of provider secrets
+type secretRule struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+// secretRules are the patterns of the secrets of known providers
+var secretRules = []secretRule{
+	{"aws-access-key-id", regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"github-token", regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{82})\b`)},
+	{"slack-token", regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`)},
+	{"stripe-key", regexp.MustCompile(`\b[sr]k_live_[0-9A-Za-z]{24,}\b`)},
+	{"google-api-key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{"private-key", regexp.MustCompile(`-----BEGIN ([A-Z]+ )?PRIVATE KEY-----`)},
+}
+
+// quotedPattern matches the quoted strings that could be tokens, as candidates of the entropy heuristic. URLs,
+// whose scheme has a colon, are never candidates.
+var quotedPattern = regexp.MustCompile("[\"'`]([A-Za-z0-9+/=_.-]{20,})[\"'`]")
+
+// pathLikePattern matches the candidates that are paths rather than tokens: absolute, relative to . or .., or
+// starting with a dotted host followed by / like import paths and URLs without their scheme
+var pathLikePattern = regexp.MustCompile(`^(\.{0,2}/|[A-Za-z0-9-]+(\.[A-Za-z0-9-]+)+/)`)
+
+// importSpecPattern matches the lines of import specs, the import line itself or the line of a spec in an import
+// block, the imported path being the last quoted string
+var importSpecPattern = regexp.MustCompile(`^\s*(import\s+)?([A-Za-z_][A-Za-z0-9_]*\s+|\.\s+)?"[^"]*"\s*(//.*)?$`)
+
+// minSecretEntropy is the Shannon entropy (in bits per character) above which a quoted token is likely a secret.
+// Random tokens of 32 letters and digits are always above it and two thirds of the ones of 24, while names and
+// paths seldom reach it.
+const minSecretEntropy = 4.2
+
+// finding is a likely hardcoded secret
+type finding struct {
+	path string
+	line int
+	rule string
+	// match is the secret, only ever reported redacted, if not empty
+	match string
+}
+
+func (f finding) String() string {
+	if f.match == "" {
+		return fmt.Sprintf("%s:%d: %s", f.path, f.line, f.rule)
+	}
+	return fmt.Sprintf("%s:%d: %s %s", f.path, f.line, f.rule, redact(f.match))
+}
+
+// redact returns value with all but its first 4 characters masked
+func redact(value string) string {
+	if len(value) <= 4 {
+		return strings.Repeat("*", len(value))
+	}
+	return value[:4] + strings.Repeat("*", len(value)-4)
+}
+
+// scanSecrets returns the likely secrets of the source read from r, matching a known provider pattern or being
+// quoted tokens with a high entropy, path being the one reported in findings
+func scanSecrets(path string, r io.Reader) ([]finding, error) {
+	var findings []finding
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxScannedFileSize)
+	inImports := false
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		isImport := inImports || strings.HasPrefix(trimmed, "import ")
+		switch {
+		case strings.HasPrefix(trimmed, "import ("):
+			inImports = true
+		case inImports && strings.HasPrefix(trimmed, ")"):
+			inImports = false
+		}
+		matched := false
+		for _, rule := range secretRules {
+			for _, match := range rule.pattern.FindAllString(line, -1) {
+				findings = append(findings, finding{path: path, line: lineNumber, rule: rule.name, match: match})
+				matched = true
+			}
+		}
+		if matched || (isImport && importSpecPattern.MatchString(line)) {
+			continue
+		}
+		for _, match := range quotedPattern.FindAllStringSubmatch(line, -1) {
+			if isTokenLike(match[1]) && entropy(match[1]) >= minSecretEntropy {
+				findings = append(findings, finding{path: path, line: lineNumber, rule: highEntropyRule, match: match[1]})
+			}
+		}
+	}
+	return findings, scanner.Err()
+}
+
+// isTokenLike reports whether candidate, a quoted string, could be a token: random tokens mix letters with digits,
+// which names and flags seldom do, and aren't paths
+func isTokenLike(candidate string) bool {
+	return strings.ContainsAny(candidate, "0123456789") && strings.IndexFunc(candidate, unicode.IsLetter) >= 0 && !pathLikePattern.MatchString(candidate)
+}
+
+// entropy returns the Shannon entropy of value, in bits per character
+func entropy(value string) float64 {
+	counts := make(map[rune]int)
+	for _, c := range value {
+		counts[c]++
+	}
+
+	var entropy float64
+	for _, count := range counts {
+		p := float64(count) / float64(len(value))
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// scanFiles returns the likely secrets of the files of paths, directories being walked by finder. Binary and
+// large files are skipped.
+func scanFiles(finder templateFinder, paths []string) ([]finding, error) {
+	var findings []finding
+	scanFile := func(path string) error {
+		source, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if len(source) > maxScannedFileSize || isBinary(source) {
+			return nil
+		}
+		fileFindings, err := scanSecrets(path, bytes.NewReader(source))
+		findings = append(findings, fileFindings...)
+		return err
+	}
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			if err := scanFile(path); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		err = finder.walk(path, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+			return scanFile(path)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool { return filepath.ToSlash(findings[i].path) < filepath.ToSlash(findings[j].path) })
+	return findings, nil
+}
+
+// Formats of the findings of scan
+const (
+	scanFormatText  = "text"
+	scanFormatSARIF = "sarif"
+)
+
+// highEntropyRule is the rule of the findings of the entropy heuristic
+const highEntropyRule = "high-entropy-string"
+
+// scan reports the likely hardcoded secrets of the paths of opts to its stdout in format, text if empty or sarif
+// (the format of --report, since scan's own --format would clash with the global one, written to --report-file if
+// set), failing on findings if failOnFindings is set
+func scan(opts options, failOnFindings bool, format string) error {
+	if format != "" && format != scanFormatText && format != scanFormatSARIF {
+		return fmt.Errorf("scan reports its findings as text or with --report=%s, not as %s", scanFormatSARIF, format)
+	}
+	findings, err := scanFiles(opts.finder(), opts.paths)
+	if err != nil {
+		return err
+	}
+
+	stdout := opts.stdout
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	if format == scanFormatSARIF {
+		write := func(w io.Writer) error { return writeSARIF(w, opts.version, scanRules(), scanResults(findings)) }
+		if err := writeReportOutput(opts, write); err != nil {
+			return err
+		}
+	} else {
+		for _, f := range findings {
+			if _, err := fmt.Fprintln(stdout, f); err != nil {
+				return withExitCode(exitWriteFailure, err)
+			}
+		}
+	}
+
+	if failOnFindings && len(findings) > 0 {
+		return withExitCode(exitFindings, fmt.Errorf("%d likely hardcoded secrets found, move them behind safekeeper templates", len(findings)))
+	}
+	return nil
+}
+
+// scanRules returns the SARIF rules of the findings of scan, errors for the secrets of known providers and
+// warnings for the high-entropy strings, which can be false positives
+func scanRules() []sarifRule {
+	var rules []sarifRule
+	for _, rule := range secretRules {
+		rules = append(rules, sarifRule{
+			ID:                   rule.name,
+			ShortDescription:     sarifMessage{Text: fmt.Sprintf("Hardcoded %s, move it behind a safekeeper template", rule.name)},
+			DefaultConfiguration: sarifConfiguration{Level: sarifError},
+		})
+	}
+	return append(rules, sarifRule{
+		ID:                   highEntropyRule,
+		ShortDescription:     sarifMessage{Text: "High-entropy string, likely a hardcoded secret to move behind a safekeeper template"},
+		DefaultConfiguration: sarifConfiguration{Level: sarifWarning},
+	})
+}
+
+// scanResults returns the SARIF results of findings, their secrets redacted
+func scanResults(findings []finding) []sarifResult {
+	results := make([]sarifResult, len(findings))
+	for i, f := range findings {
+		level := sarifError
+		if f.rule == highEntropyRule {
+			level = sarifWarning
+		}
+		message := fmt.Sprintf("Likely hardcoded %s %s", f.rule, redact(f.match))
+		results[i] = newSARIFResult(f.rule, level, f.path, f.line, message)
+	}
+	return results
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+const (
+	// serverTokenEnv is the environment variable of the token clients of serve authenticate with, required by the
+	// server when set and to listen on TCP addresses
+	serverTokenEnv = "SAFEKEEPER_SERVER_TOKEN"
+	// unixAddressPrefix starts the addresses of Unix sockets, e.g. unix:/tmp/safekeeper.sock
+	unixAddressPrefix = "unix:"
+	// serverShutdownTimeout bounds the time the requests in flight have to complete once serve is stopped
+	serverShutdownTimeout = 5 * time.Second
+)
+
+// defaultServerAddress is the address serve listens on by default, a Unix socket only its user can connect to
+func defaultServerAddress() string {
+	return unixAddressPrefix + filepath.Join(os.TempDir(), fmt.Sprintf("safekeeper-%d.sock", os.Getuid()))
+}
+
+// resolveRequest is the JSON body of the POST /v1/resolve requests of the clients of serve
+type resolveRequest struct {
+	Key string `json:"key"`
+}
+
+// resolveResponse is the JSON response to a resolveRequest, Error being set when the sources of the server fail
+type resolveResponse struct {
+	Value string `json:"value,omitempty"`
+	Found bool   `json:"found"`
+	Error string `json:"error,omitempty"`
+}
+
+// serve resolves the keys of the runs given --server with the sources of opts, listening on address (a Unix
+// socket or a loopback TCP address) until done is closed. The sources are kept for the life of the server, with
+// their sessions and the values they read, so that runs don't authenticate again.
+func serve(opts options, address string, done <-chan struct{}) error {
+	listener, err := listen(address)
+	if err != nil {
+		return err
+	}
+	if path := strings.TrimPrefix(address, unixAddressPrefix); path != address {
+		defer os.Remove(path)
+	}
+	return serveListener(opts, listener, done)
+}
+
+// serveListener is serve, accepting the connections of listener
+func serveListener(opts options, listener net.Listener, done <-chan struct{}) error {
+	// Any local process, or web page through DNS rebinding, can connect to a TCP address, unlike to a socket
+	// only its user can
+	token := opts.getenv(serverTokenEnv)
+	if listener.Addr().Network() == "tcp" && token == "" {
+		listener.Close()
+		return fmt.Errorf("Serving on TCP address [%s] requires %s, set it or listen on a %s<path> socket", listener.Addr(), serverTokenEnv, unixAddressPrefix)
+	}
+
+	// The server doesn't forward to another one
+	opts.server = ""
+	source, err := newSecretSource(opts)
+	if err != nil {
+		listener.Close()
+		return err
+	}
+
+	// Requests are reported unless asked otherwise, serving being interactive
+	if opts.logLevel == "" {
+		opts.logLevel = "info"
+	}
+	log, err := opts.logger()
+	if err != nil {
+		listener.Close()
+		return err
+	}
+
+	server := &http.Server{Handler: serverHandler{source: source, token: token, log: log}}
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(listener)
+	}()
+	log.infof("Serving values on [%s]", listener.Addr())
+
+	select {
+	case err := <-served:
+		return err
+	case <-done:
+		ctx, cancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
+		defer cancel()
+		return server.Shutdown(ctx)
+	}
+}
+
+// listen returns the listener of address, refusing TCP addresses that aren't loopback ones since values are
+// served in the clear
+func listen(address string) (net.Listener, error) {
+	if path := strings.TrimPrefix(address, unixAddressPrefix); path != address {
+		// A socket left by a server that didn't stop cleanly is replaced
+		if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(path)
+		}
+		listener, err := net.Listen("unix", path)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.Chmod(path, 0600); err != nil {
+			listener.Close()
+			return nil, err
+		}
+		return listener, nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid address [%s], expected host:port or %s<path>", address, unixAddressPrefix)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, fmt.Errorf("Address [%s] isn't a loopback address, serve only listens on localhost or Unix sockets", address)
+	}
+	return net.Listen("tcp", address)
+}
+
+// interrupted returns a channel closed once the process is interrupted or terminated
+func interrupted() <-chan struct{} {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		<-signals
+		close(done)
+	}()
+	return done
+}
+
+// serverHandler serves the API of serve: POST /v1/resolve resolving a key and GET /v1/health
+type serverHandler struct {
+	source safekeeper.SecretSource
+	token  string
+	log    *logger
+}
+
+func (h serverHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+h.token)) != 1 {
+		writeJSON(w, http.StatusUnauthorized, resolveResponse{Error: "Invalid or missing " + serverTokenEnv})
+		return
+	}
+
+	switch {
+	case r.URL.Path == "/v1/health" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	case r.URL.Path == "/v1/resolve" && r.Method == http.MethodPost:
+		var request resolveRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Key == "" {
+			writeJSON(w, http.StatusBadRequest, resolveResponse{Error: "Invalid request, expected {\"key\": \"KEY\"}"})
+			return
+		}
+		value, found, err := safekeeper.ResolveContext(r.Context(), h.source, request.Key)
+		if err != nil {
+			h.log.warnf("Error resolving [%s]: %s", request.Key, err)
+			writeJSON(w, http.StatusBadGateway, resolveResponse{Error: err.Error()})
+			return
+		}
+		h.log.debugf("Resolved [%s] (found: %t)", request.Key, found)
+		writeJSON(w, http.StatusOK, resolveResponse{Value: value, Found: found})
+	default:
+		writeJSON(w, http.StatusNotFound, resolveResponse{Error: fmt.Sprintf("No %s %s, expected POST /v1/resolve or GET /v1/health", r.Method, r.URL.Path)})
+	}
+}
+
+// writeJSON writes body as the JSON response of status
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// serverSource resolves keys with a safekeeper server started by serve, in place of the sources of --source
+type serverSource struct {
+	address  string
+	endpoint string
+	token    string
+	client   *http.Client
+}
+
+// newServerSource returns the source of the server at address, authenticating with token if not empty
+func newServerSource(address string, token string) *serverSource {
+	s := &serverSource{address: address, endpoint: "http://" + address, token: token, client: http.DefaultClient}
+	if path := strings.TrimPrefix(address, unixAddressPrefix); path != address {
+		var dialer net.Dialer
+		s.endpoint = "http://safekeeper"
+		s.client = &http.Client{Transport: &http.Transport{DialContext: func(ctx context.Context, _ string, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", path)
+		}}}
+	}
+	return s
+}
+
+func (s *serverSource) Resolve(key string) (string, bool, error) {
+	return s.ResolveContext(context.Background(), key)
+}
+
+func (s *serverSource) ResolveContext(ctx context.Context, key string) (string, bool, error) {
+	body, err := json.Marshal(resolveRequest{Key: key})
+	if err != nil {
+		return "", false, err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint+"/v1/resolve", bytes.NewReader(body))
+	if err != nil {
+		return "", false, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if s.token != "" {
+		request.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return "", false, fmt.Errorf("Error reaching the safekeeper server at [%s], is safekeeper serve running? %s", s.address, err)
+	}
+	defer response.Body.Close()
+	content, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return "", false, err
+	}
+
+	var resolved resolveResponse
+	if err := json.Unmarshal(content, &resolved); err != nil {
+		return "", false, fmt.Errorf("Invalid response of the safekeeper server at [%s], status %d", s.address, response.StatusCode)
+	}
+	if response.StatusCode != http.StatusOK {
+		return "", false, fmt.Errorf("Server at [%s] failed to resolve [%s]: %s", s.address, key, resolved.Error)
+	}
+	return resolved.Value, resolved.Found, nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+func TestServe(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	listener, err := listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	done := make(chan struct{})
+	served := make(chan error, 1)
+	serverEnv := map[string]string{"CLIENT_ID": "safeid", "CLIENT_SECRET": "safesecret", serverTokenEnv: "t0ken"}
+	go func() {
+		served <- serveListener(options{lookupEnv: safekeeper.LookupMap(serverEnv), stderr: &bytes.Buffer{}}, listener, done)
+	}()
+
+	source := newServerSource(address, "t0ken")
+	if value, found, err := source.Resolve("CLIENT_ID"); err != nil || !found || value != "safeid" {
+		t.Errorf("Expected [CLIENT_ID] to be [safeid] but got [%s] (found: %t, err: %v)", value, found, err)
+	}
+	if _, found, err := source.Resolve("MISSING"); found || err != nil {
+		t.Errorf("Expected [MISSING] not to be found but got found: %t, err: %v", found, err)
+	}
+	if _, _, err := newServerSource(address, "wrong").Resolve("CLIENT_ID"); err == nil || !strings.Contains(err.Error(), serverTokenEnv) {
+		t.Errorf("Expected a client with the wrong token to be rejected but got [%v]", err)
+	}
+
+	generationDriverFile, err := writeGenerationDriverFile(tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writeTestTemplate(tempDir); err != nil {
+		t.Fatal(err)
+	}
+	clientEnv := safekeeper.LookupMap(map[string]string{serverTokenEnv: "t0ken"})
+	if err := run(options{keys: "CLIENT_ID,CLIENT_SECRET", paths: []string{generationDriverFile}, server: address, lookupEnv: clientEnv}); err != nil {
+		t.Fatal(err)
+	}
+	generated, err := ioutil.ReadFile(generationDriverFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(generated), "safeid") || !strings.Contains(string(generated), "safesecret") {
+		t.Errorf("Expected the values of the server to be substituted but got [%s]", string(generated))
+	}
+
+	close(done)
+	if err := <-served; err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := source.Resolve("CLIENT_ID"); err == nil {
+		t.Error("Expected a stopped server to be unreachable")
+	}
+}
+
+func TestServeUnixSocket(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "serve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	address := unixAddressPrefix + filepath.Join(tempDir, "safekeeper.sock")
+	done := make(chan struct{})
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(options{lookupEnv: safekeeper.LookupMap(map[string]string{"CLIENT_ID": "safeid"}), stderr: &bytes.Buffer{}}, address, done)
+	}()
+
+	// The socket exists once the server listens
+	socket := filepath.Join(tempDir, "safekeeper.sock")
+	for i := 0; i < 100; i++ {
+		if _, err := os.Stat(socket); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	value, _, err := newServerSource(address, "").Resolve("CLIENT_ID")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != "safeid" {
+		t.Errorf("Expected [CLIENT_ID] to be served over the socket but got [%s]", value)
+	}
+	info, err := os.Stat(socket)
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the socket to only be accessible to its user, got %v (err: %v)", info, err)
+	}
+
+	close(done)
+	if err := <-served; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServeRequiresTokenOnTCP(t *testing.T) {
+	listener, err := listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = serveListener(options{lookupEnv: safekeeper.LookupMap(map[string]string{"CLIENT_ID": "safeid"}), stderr: &bytes.Buffer{}}, listener, make(chan struct{}))
+	if err == nil || !strings.Contains(err.Error(), serverTokenEnv) {
+		t.Errorf("Expected serving on TCP without %s to be refused but got [%v]", serverTokenEnv, err)
+	}
+	// The listener is closed
+	if conn, err := net.Dial("tcp", listener.Addr().String()); err == nil {
+		conn.Close()
+		t.Error("Expected the listener to be closed")
+	}
+}
+
+func TestListenRefusesPublicAddresses(t *testing.T) {
+	for _, address := range []string{"0.0.0.0:0", "example.com:7700", "no-port"} {
+		if listener, err := listen(address); err == nil {
+			listener.Close()
+			t.Errorf("Expected [%s] to be refused", address)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"path/filepath"
+	"sort"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+// settingsOutput returns the output of the file generated from path, given its output out: the output set by the
+// safekeeper:output directive of its template, if any, is relative to the directory of the file it replaces. An
+// output given explicitly wins, only the ones named after path in the output directory or by the output pattern being replaced.
+func (g *generation) settingsOutput(path string, out string) string {
+	output := g.settings[pathKey(path)].Output
+	if output == "" || path == stdio || out == stdio || (out != "" && g.outputDir == "" && g.outputPattern == "") {
+		return out
+	}
+	return filepath.Join(filepath.Dir(valueOr(out, path)), output)
+}
+
+// hasSettingsOutput reports whether the output of the file generated from path is set by its template
+func (g *generation) hasSettingsOutput(path string) bool {
+	return g.settings[pathKey(path)].Output != ""
+}
+
+// withSettingsKeys returns keys with the keys declared by the safekeeper:key directives of templates that it's
+// missing, for the keys given explicitly not to leave them out
+func withSettingsKeys(keys []string, settings map[string]safekeeper.FileSettings) []string {
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		seen[key] = true
+	}
+	paths := make([]string, 0, len(settings))
+	for path := range settings {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		for _, key := range settings[path].Keys {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
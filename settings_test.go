@@ -0,0 +1,48 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+func TestTemplateSettings(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	template := "//safekeeper:output creds.go\n//safekeeper:key API_TOKEN required\n//safekeeper:key SENTRY_DSN optional\npackage secrets\n\nconst clientID = \"ENV_CLIENT_ID\"\nconst sentryDSN = \"ENV_SENTRY_DSN\"\n"
+	templateFile := filepath.Join(tempDir, "secrets.go.safekeeper")
+	if err := ioutil.WriteFile(templateFile, []byte(template), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	env := map[string]string{"CLIENT_ID": "safeid"}
+	if err := run(options{paths: []string{templateFile}, lookupEnv: safekeeper.LookupMap(env)}); err == nil || !strings.Contains(err.Error(), "API_TOKEN") {
+		t.Fatalf("Expected the required key declared by the template to be missing but got [%v]", err)
+	}
+
+	env["API_TOKEN"] = "token"
+	if err := run(options{paths: []string{templateFile}, lookupEnv: safekeeper.LookupMap(env)}); err != nil {
+		t.Fatal(err)
+	}
+	generated, err := ioutil.ReadFile(filepath.Join(tempDir, "creds.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(generated), "const clientID = \"safeid\"") || !strings.Contains(string(generated), "const sentryDSN = \"\"") || strings.Contains(string(generated), "safekeeper:output") {
+		t.Errorf("Expected the values to be substituted and the settings left out of the generated file but got [%s]", string(generated))
+	}
+	if strings.Contains(string(generated), "go:generate") {
+		t.Errorf("Expected no go:generate line in a file named by its template but got [%s]", string(generated))
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "secrets.go")); !os.IsNotExist(err) {
+		t.Errorf("Expected no file generated next to the template, got [%v]", err)
+	}
+}
@@ -0,0 +1,485 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+	"golang.org/x/sync/singleflight"
+)
+
+// prefixMapSource resolves the keys of --prefix-map as the list of the NAME=VALUE pairs of the environment
+// variables starting with their prefix, for the map type
+type prefixMapSource struct {
+	prefixes  map[string]string
+	delimiter string
+	environ   func() []string
+}
+
+func (s prefixMapSource) Resolve(key string) (string, bool, error) {
+	prefix, ok := s.prefixes[key]
+	if !ok {
+		return "", false, nil
+	}
+
+	var pairs []string
+	for _, variable := range s.environ() {
+		if strings.HasPrefix(variable, prefix) {
+			pairs = append(pairs, safekeeper.EscapeListElement(variable, s.delimiter))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, valueOr(s.delimiter, safekeeper.DefaultListDelimiter)), true, nil
+}
+
+// cachingSource wraps a SecretSource so that it is consulted at most once per key, even when lookups for
+// the same key happen concurrently
+type cachingSource struct {
+	source safekeeper.SecretSource
+	group  singleflight.Group
+
+	mu      sync.Mutex
+	results map[string]lookupResult
+}
+
+// lookupResult is the memoized outcome of a SecretSource resolution, its value kept as a Secret so that it
+// can't be printed with the cache
+type lookupResult struct {
+	value safekeeper.Secret
+	found bool
+	err   error
+}
+
+func newCachingSource(source safekeeper.SecretSource) *cachingSource {
+	return &cachingSource{source: source, results: make(map[string]lookupResult)}
+}
+
+func (s *cachingSource) Resolve(key string) (string, bool, error) {
+	return s.ResolveContext(context.Background(), key)
+}
+
+// ResolveContext resolves key once, the resolutions given up on because their context is done being left out of
+// the cache
+func (s *cachingSource) ResolveContext(ctx context.Context, key string) (string, bool, error) {
+	s.mu.Lock()
+	result, ok := s.results[key]
+	s.mu.Unlock()
+	if ok {
+		return result.value.Reveal(), result.found, result.err
+	}
+
+	v, _, _ := s.group.Do(key, func() (interface{}, error) {
+		s.mu.Lock()
+		result, ok := s.results[key]
+		s.mu.Unlock()
+		if ok {
+			return result, nil
+		}
+
+		value, found, err := safekeeper.ResolveContext(ctx, s.source, key)
+		result = lookupResult{value: safekeeper.Secret(value), found: found, err: err}
+		if ctx.Err() != nil {
+			return result, nil
+		}
+
+		s.mu.Lock()
+		s.results[key] = result
+		s.mu.Unlock()
+		return result, nil
+	})
+
+	result = v.(lookupResult)
+	return result.value.Reveal(), result.found, result.err
+}
+
+// commandRunner runs an external command and returns its standard output. Sources that shell out to
+// a CLI take one so that tests can stub the command.
+type commandRunner func(name string, args ...string) ([]byte, error)
+
+// execCommand is the default commandRunner. The standard error of a failed command is included in the
+// returned error.
+func execCommand(name string, args ...string) ([]byte, error) {
+	return execCommandContext(context.Background(), name, args...)
+}
+
+// runner returns the commandRunner of opts, killing the commands still running once its context is done
+func (opts options) runner() commandRunner {
+	ctx := opts.context()
+	return func(name string, args ...string) ([]byte, error) {
+		return execCommandContext(ctx, name, args...)
+	}
+}
+
+// execCommandContext is execCommand, killing the command once ctx is done
+func execCommandContext(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return execCommandInput(ctx, nil, name, args...)
+}
+
+// execCommandInput is execCommandContext, the command reading input from its standard input if not nil
+func execCommandInput(ctx context.Context, input []byte, name string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stderr = &stderr
+	if input != nil {
+		cmd.Stdin = bytes.NewReader(input)
+	}
+
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// sourceNames are the names of the sources that can be selected with --source
+var sourceNames = []string{"env", "envfile", "secretsfile", "op", "vault", "aws-secretsmanager", "gcp-secretmanager", "azure-keyvault", "doppler", "keyring", "pass", "kubernetes", "docker-secrets", "aws-ssm", "infisical", "bitwarden"}
+
+// Groups of sources, the order of which is given by --precedence
+const (
+	// precedenceValues are the values of the config file
+	precedenceValues = "values"
+	// precedenceSources are the sources of --source, in order
+	precedenceSources = "sources"
+	// precedenceSecretsFiles are the files of --secrets-file, later files first
+	precedenceSecretsFiles = "secrets-files"
+	// precedenceEnvFiles are the files of --env-file, later files first
+	precedenceEnvFiles = "env-files"
+)
+
+// defaultPrecedence is the order of the groups of sources, the first group having a key winning
+var defaultPrecedence = []string{precedenceValues, precedenceSources, precedenceSecretsFiles, precedenceEnvFiles}
+
+// namedSource is a source of the chain of an invocation, with the name --explain reports it as
+type namedSource struct {
+	name   string
+	source safekeeper.SecretSource
+	// aliased sources resolve keys under the names of --map, the values of the config file being named after
+	// the keys of the templates
+	aliased bool
+}
+
+// layeredSource resolves keys with the first of its layers that has them, or with their own source when routed
+type layeredSource struct {
+	layers  []namedSource
+	aliases map[string]string
+	// routes are the sources of the keys of --key-source, resolving them alone
+	routes map[string]namedSource
+}
+
+func (s layeredSource) Resolve(key string) (string, bool, error) {
+	return s.ResolveContext(context.Background(), key)
+}
+
+func (s layeredSource) ResolveContext(ctx context.Context, key string) (string, bool, error) {
+	for _, layer := range s.layersOf(key) {
+		value, found, err := safekeeper.ResolveContext(ctx, layer.source, s.nameIn(layer, key))
+		if err != nil || found {
+			return value, found, err
+		}
+	}
+	return "", false, nil
+}
+
+// layersOf returns the layers resolving key: its own source if routed, all the layers otherwise
+func (s layeredSource) layersOf(key string) []namedSource {
+	if route, ok := s.routes[key]; ok {
+		return []namedSource{route}
+	}
+	return s.layers
+}
+
+// nameIn returns the name key is resolved as by layer
+func (s layeredSource) nameIn(layer namedSource, key string) string {
+	if name, ok := s.aliases[key]; ok && layer.aliased {
+		return name
+	}
+	return key
+}
+
+// newSecretSource returns the SecretSource for opts: the values of the config file, the sources given as
+// name[:argument] specs, in order, the --secrets-file and then the --env-file files, later files taking
+// precedence over previous ones, unless ordered otherwise by --precedence. The process environment is the only
+// source if none is given. Keys of --key-source are only resolved by their own source. Resolutions are cached for
+// the duration of the invocation.
+func newSecretSource(opts options) (safekeeper.SecretSource, error) {
+	source, err := newLayeredSource(opts)
+	if err != nil {
+		return nil, err
+	}
+	return newCachingSource(source), nil
+}
+
+// newLayeredSource returns the uncached source of opts, see newSecretSource
+func newLayeredSource(opts options) (layeredSource, error) {
+	precedence, err := parsePrecedence(opts.precedence)
+	if err != nil {
+		return layeredSource{}, err
+	}
+
+	groups := make(map[string][]namedSource)
+	if len(opts.values) > 0 {
+		groups[precedenceValues] = []namedSource{{name: "values of the config file", source: safekeeper.MapSource(opts.values)}}
+	}
+
+	specs := opts.sources
+	if len(specs) == 0 {
+		specs = []string{"env"}
+	}
+	// A server resolves keys with its own sources
+	if opts.server != "" {
+		server := newServerSource(opts.server, opts.getenv(serverTokenEnv))
+		groups[precedenceSources] = []namedSource{{name: "server " + opts.server, source: server, aliased: true}}
+		specs = nil
+	}
+	for _, spec := range specs {
+		name, arg := spec, ""
+		if separator := strings.Index(spec, ":"); separator >= 0 {
+			name, arg = spec[:separator], spec[separator+1:]
+		}
+
+		source, err := newSource(name, arg, opts)
+		if err != nil {
+			return layeredSource{}, err
+		}
+		groups[precedenceSources] = append(groups[precedenceSources], namedSource{name: spec, source: source, aliased: true})
+	}
+
+	for i := len(opts.secretsFiles) - 1; i >= 0; i-- {
+		secretsFile, err := opts.secretsFile(opts.secretsFiles[i])
+		if err != nil {
+			return layeredSource{}, err
+		}
+		groups[precedenceSecretsFiles] = append(groups[precedenceSecretsFiles], namedSource{name: "secretsfile:" + opts.secretsFiles[i], source: secretsFile, aliased: true})
+	}
+	for i := len(opts.envFiles) - 1; i >= 0; i-- {
+		envFile, err := loadEnvFile(opts.envFiles[i])
+		if err != nil {
+			return layeredSource{}, err
+		}
+		groups[precedenceEnvFiles] = append(groups[precedenceEnvFiles], namedSource{name: "envfile:" + opts.envFiles[i], source: envFile, aliased: true})
+	}
+
+	// Keys of prefix maps are never resolved by the other sources
+	source := layeredSource{aliases: opts.keyMap}
+	if opts.server == "" && len(opts.keySources) > 0 {
+		if source.routes, err = keyRoutes(opts, groups[precedenceSources]); err != nil {
+			return layeredSource{}, err
+		}
+	}
+	if len(opts.prefixMaps) > 0 {
+		source.layers = append(source.layers, namedSource{name: "environment variables of --prefix-map", source: prefixMapSource{prefixes: opts.prefixMaps, delimiter: opts.listDelimiter, environ: opts.environment}})
+	}
+	for _, group := range precedence {
+		source.layers = append(source.layers, groups[group]...)
+	}
+	return source, nil
+}
+
+// keyRoutes returns the sources of the keys of --key-source, sharing the ones of --source with the same spec (and
+// so their sessions) and the other ones from key to key
+func keyRoutes(opts options, sources []namedSource) (map[string]namedSource, error) {
+	specs := make(map[string]namedSource, len(sources))
+	for _, source := range sources {
+		specs[source.name] = source
+	}
+	routes := make(map[string]namedSource, len(opts.keySources))
+	for key, spec := range opts.keySources {
+		if _, ok := specs[spec]; !ok {
+			name, arg := spec, ""
+			if separator := strings.Index(spec, ":"); separator >= 0 {
+				name, arg = spec[:separator], spec[separator+1:]
+			}
+			source, err := newSource(name, arg, opts)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid source of key [%s]: %s", key, err)
+			}
+			specs[spec] = namedSource{name: spec, source: source, aliased: true}
+		}
+		routes[key] = specs[spec]
+	}
+	return routes, nil
+}
+
+// parsePrecedence returns the order of the groups of sources given by the comma-separated precedence, the groups
+// it leaves out following in their default order
+func parsePrecedence(precedence string) ([]string, error) {
+	var order []string
+	seen := make(map[string]bool)
+	for _, group := range strings.Split(precedence, ",") {
+		if group = strings.TrimSpace(group); group == "" {
+			continue
+		}
+		known := false
+		for _, name := range defaultPrecedence {
+			known = known || group == name
+		}
+		if !known {
+			return nil, fmt.Errorf("Unknown source group [%s] in --precedence, expected %s", group, strings.Join(defaultPrecedence, ", "))
+		}
+		if seen[group] {
+			return nil, fmt.Errorf("Source group [%s] is given twice in --precedence", group)
+		}
+		seen[group] = true
+		order = append(order, group)
+	}
+	for _, group := range defaultPrecedence {
+		if !seen[group] {
+			order = append(order, group)
+		}
+	}
+	return order, nil
+}
+
+// resolvedName returns the name key is resolved as by the sources of source, key itself unless mapped with --map
+func resolvedName(source safekeeper.SecretSource, key string) string {
+	if p, ok := source.(promptSource); ok {
+		source = p.source
+	}
+	if c, ok := source.(*cachingSource); ok {
+		source = c.source
+	}
+	if l, ok := source.(layeredSource); ok {
+		if name, ok := l.aliases[key]; ok {
+			return name
+		}
+	}
+	return key
+}
+
+// newSource returns the source registered under name. The meaning of arg depends on the source and, when given,
+// overrides the equivalent flag.
+func newSource(name string, arg string, opts options) (safekeeper.SecretSource, error) {
+	switch name {
+	case "env":
+		return safekeeper.EnvSource{Lookup: opts.lookup()}, nil
+	case "envfile":
+		if arg == "" {
+			return nil, errors.New("envfile source needs a path, e.g. envfile:.env")
+		}
+		return loadEnvFile(arg)
+	case "secretsfile":
+		if arg == "" {
+			return nil, errors.New("secretsfile source needs a path, e.g. secretsfile:secrets.enc.yaml")
+		}
+		return opts.secretsFile(arg)
+	case "op":
+		op := &opSource{refTemplate: valueOr(arg, opts.opRefTemplate), refs: opts.opRefs, run: opts.runner()}
+		if host, token := opts.getenv("OP_CONNECT_HOST"), opts.getenv("OP_CONNECT_TOKEN"); host != "" && token != "" {
+			op.connect = &opConnectClient{host: host, token: token, client: http.DefaultClient}
+		}
+		return op, nil
+	case "vault":
+		vault := newVaultSource(opts.vaultAddr, valueOr(arg, opts.vaultPath))
+		vault.getenv = opts.getenv
+		return vault, nil
+	case "aws-secretsmanager":
+		return &awsSecretsManagerSource{jsonSecretId: valueOr(arg, opts.awsSecretId), secretIds: opts.awsSecrets, run: opts.runner()}, nil
+	case "gcp-secretmanager":
+		gcp := newGCPSecretManagerSource(valueOr(arg, opts.gcpProject), opts.gcpSecrets)
+		gcp.run, gcp.getenv = opts.runner(), opts.getenv
+		return gcp, nil
+	case "azure-keyvault":
+		return &azureKeyVaultSource{vaultName: valueOr(arg, opts.azureVault), secretNames: opts.azureSecrets, run: opts.runner()}, nil
+	case "doppler":
+		project, config := opts.dopplerProject, opts.dopplerConfig
+		if arg != "" {
+			parts := strings.SplitN(arg, "/", 2)
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("Invalid doppler source argument [%s], expected project/config", arg)
+			}
+			project, config = parts[0], parts[1]
+		}
+		doppler := newDopplerSource(project, config)
+		doppler.getenv = opts.getenv
+		return doppler, nil
+	case "keyring":
+		return keyringSource{}, nil
+	case "pass":
+		return &passSource{pathTemplate: valueOr(arg, opts.passPath), run: opts.runner()}, nil
+	case "kubernetes":
+		namespace, secret := opts.k8sNamespace, opts.k8sSecret
+		if arg != "" {
+			secret = arg
+			if separator := strings.Index(arg, "/"); separator >= 0 {
+				namespace, secret = arg[:separator], arg[separator+1:]
+			}
+		}
+		k8s := newKubernetesSecretSource(secret, namespace, opts.k8sContext)
+		k8s.run, k8s.getenv = opts.runner(), opts.getenv
+		return k8s, nil
+	case "aws-ssm":
+		return &awsSSMSource{path: valueOr(arg, opts.ssmPath), names: opts.ssmParams, run: opts.runner()}, nil
+	case "infisical":
+		infisical := newInfisicalSource(opts.infisicalID, valueOr(arg, opts.infisicalEnv), opts.infisicalPath)
+		infisical.endpoint = valueOr(opts.infisicalURL, infisicalEndpoint)
+		infisical.getenv = opts.getenv
+		return infisical, nil
+	case "bitwarden":
+		return &bitwardenSource{project: valueOr(arg, opts.bwsProject), secrets: opts.bwsSecrets, run: opts.runner()}, nil
+	case "docker-secrets":
+		return dockerSecretsSource{dir: valueOr(arg, opts.dockerDir)}, nil
+	default:
+		if path, ok := findPlugins(opts.getenv("PATH"), providerPluginPrefix)[name]; ok {
+			return &pluginSource{name: name, path: path, argument: arg, run: opts.pluginRunner()}, nil
+		}
+		return nil, fmt.Errorf("Unknown source [%s], expected one of %s or a %s%s plugin in PATH", name, strings.Join(sourceNames, ", "), providerPluginPrefix, name)
+	}
+}
+
+// valueOr returns value or fallback if value is empty
+func valueOr(value string, fallback string) string {
+	if value != "" {
+		return value
+	}
+	return fallback
+}
+
+// isEnvSource reports whether source reads from the process environment only, besides the values of the config
+// file
+func isEnvSource(source safekeeper.SecretSource) bool {
+	if p, ok := source.(promptSource); ok {
+		source = p.source
+	}
+	if c, ok := source.(*cachingSource); ok {
+		source = c.source
+	}
+	l, ok := source.(layeredSource)
+	if !ok {
+		_, ok := source.(safekeeper.EnvSource)
+		return ok
+	}
+
+	// Routed keys can be resolved by other sources than the layers, which they may share
+	layers := l.layers
+	for _, route := range l.routes {
+		shared := false
+		for _, layer := range l.layers {
+			shared = shared || layer.name == route.name
+		}
+		if !shared {
+			layers = append(layers, route)
+		}
+	}
+	envOnly := false
+	for _, layer := range layers {
+		if !layer.aliased {
+			continue
+		}
+		if _, ok := layer.source.(safekeeper.EnvSource); !ok || envOnly {
+			return false
+		}
+		envOnly = true
+	}
+	return envOnly
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+// ageExt is the extension of the secrets files encrypted with age rather than SOPS
+const ageExt = ".age"
+
+// ageKeyPrefix starts the age secret keys given directly rather than as the path of an identity file
+const ageKeyPrefix = "AGE-SECRET-KEY-"
+
+// loadAgeFile decrypts the age-encrypted file at path (https://age-encryption.org) with identity, the path of an
+// identity file or a secret key itself, and returns the KEY=VALUE pairs it contains, in the dotenv syntax. The age
+// CLI does the decryption.
+func loadAgeFile(path string, identity string, run commandRunner) (safekeeper.MapSource, error) {
+	if identity == "" {
+		return nil, fmt.Errorf("Decrypting [%s] needs an age identity, set AGE_IDENTITY or --age-identity", path)
+	}
+	if strings.HasPrefix(strings.TrimSpace(identity), ageKeyPrefix) {
+		// age only reads identities from files
+		file, err := ioutil.TempFile("", "safekeeper-age-*")
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(file.Name())
+		_, err = file.WriteString(strings.TrimSpace(identity) + "\n")
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, err
+		}
+		identity = file.Name()
+	}
+
+	out, err := run("age", "--decrypt", "--identity", identity, path)
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, errors.New("age not found in PATH, see https://age-encryption.org")
+		}
+		return nil, fmt.Errorf("Error decrypting [%s] with age: %s", path, err)
+	}
+
+	values, err := parseEnvFile(bytes.NewReader(out))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return values, nil
+}
+
+// secretsFile loads the secrets file at path of opts, with age if it's encrypted with it and SOPS otherwise
+func (opts options) secretsFile(path string) (safekeeper.MapSource, error) {
+	if strings.HasSuffix(path, ageExt) {
+		return loadAgeFile(path, opts.ageIdentity, opts.runner())
+	}
+	return loadSecretsFile(path, opts.runner())
+}
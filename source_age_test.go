@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestAgeFile(t *testing.T) {
+	var commandLine string
+	values, err := loadAgeFile("secrets.age", "keys.txt", stubRunner(&commandLine, "# Production\nCLIENT_ID=safeid\nCLIENT_SECRET=\"safe secret\"\n", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commandLine != "age --decrypt --identity keys.txt secrets.age" {
+		t.Errorf("Unexpected age invocation [%s]", commandLine)
+	}
+	if values["CLIENT_ID"] != "safeid" || values["CLIENT_SECRET"] != "safe secret" {
+		t.Errorf("Expected the pairs of the decrypted file but got %d values", len(values))
+	}
+
+	if _, err := loadAgeFile("secrets.age", "", stubRunner(&commandLine, "", nil)); err == nil || !strings.Contains(err.Error(), "AGE_IDENTITY") {
+		t.Errorf("Expected a missing identity to fail but got [%v]", err)
+	}
+	if _, err := loadAgeFile("secrets.age", "keys.txt", stubRunner(&commandLine, "", errors.New("exit status 1: age: error: no identity matched any of the recipients"))); err == nil || !strings.Contains(err.Error(), "no identity matched") {
+		t.Errorf("Expected a failed decryption to be reported but got [%v]", err)
+	}
+}
+
+func TestAgeFileSecretKey(t *testing.T) {
+	const key = "AGE-SECRET-KEY-1QYQSZQGPQYQSZQGPQYQSZQGPQYQSZQGPQYQSZQGPQYQSZQGPQYQSZQGPQYQS"
+	var identityFile, identity string
+	run := func(name string, args ...string) ([]byte, error) {
+		identityFile = args[2]
+		content, err := ioutil.ReadFile(identityFile)
+		identity = string(content)
+		return []byte("CLIENT_ID=safeid\n"), err
+	}
+
+	if _, err := loadAgeFile("secrets.age", key, run); err != nil {
+		t.Fatal(err)
+	}
+	if identity != key+"\n" {
+		t.Errorf("Expected the key to be passed to age in an identity file")
+	}
+	if _, err := os.Stat(identityFile); !os.IsNotExist(err) {
+		t.Errorf("Expected the identity file of the key to be removed")
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// awsSecretsManagerSource resolves keys from AWS Secrets Manager with the aws CLI, which uses the default AWS
+// credential chain (environment, shared config, instance/task roles, IRSA, etc.). A key is read either from a JSON
+// secret whose fields are the keys or from its own secret, named after the key unless mapped to another name or ARN.
+type awsSecretsManagerSource struct {
+	// jsonSecretId is the name or ARN of a JSON secret whose fields are keys
+	jsonSecretId string
+	// secretIds maps keys to the name or ARN of the secret holding their value
+	secretIds map[string]string
+	run       commandRunner
+
+	once   sync.Once
+	fields map[string]string
+	err    error
+}
+
+func (s *awsSecretsManagerSource) Resolve(key string) (string, bool, error) {
+	if secretId, mapped := s.secretIds[key]; mapped || s.jsonSecretId == "" {
+		if !mapped {
+			secretId = key
+		}
+		return s.getSecretString(secretId)
+	}
+
+	s.once.Do(func() {
+		s.fields, s.err = s.readJSONSecret()
+	})
+	if s.err != nil {
+		return "", false, s.err
+	}
+
+	value, found := s.fields[key]
+	return value, found, nil
+}
+
+// readJSONSecret reads the JSON secret and returns its fields
+func (s *awsSecretsManagerSource) readJSONSecret() (map[string]string, error) {
+	secret, found, err := s.getSecretString(s.jsonSecretId)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("AWS secret [%s] not found", s.jsonSecretId)
+	}
+
+	fields := make(map[string]string)
+	if err := json.Unmarshal([]byte(secret), &fields); err != nil {
+		return nil, fmt.Errorf("AWS secret [%s] isn't a JSON object of string fields: %s", s.jsonSecretId, err)
+	}
+
+	return fields, nil
+}
+
+// getSecretString returns the string value of the secret with the given name or ARN
+func (s *awsSecretsManagerSource) getSecretString(secretId string) (string, bool, error) {
+	out, err := s.run("aws", "secretsmanager", "get-secret-value", "--secret-id", secretId, "--query", "SecretString", "--output", "text")
+	if err != nil {
+		switch {
+		case errors.Is(err, exec.ErrNotFound):
+			return "", false, errors.New("AWS CLI (aws) not found in PATH, see https://aws.amazon.com/cli")
+		case strings.Contains(err.Error(), "ResourceNotFoundException"):
+			return "", false, nil
+		default:
+			return "", false, fmt.Errorf("Error reading AWS secret [%s]: %s", secretId, err)
+		}
+	}
+
+	return strings.TrimSuffix(string(out), "\n"), true, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestAWSSecretsManagerSourcePerKeySecrets(t *testing.T) {
+	var commandLine string
+	source := &awsSecretsManagerSource{
+		secretIds: map[string]string{"CLIENT_SECRET": "arn:aws:secretsmanager:us-east-1:123456789012:secret:myapp"},
+		run:       stubRunner(&commandLine, "safesecret\n", nil),
+	}
+
+	value, found, err := source.Resolve("CLIENT_SECRET")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found || value != "safesecret" {
+		t.Errorf("Expected [safesecret] but got [%s] (found: %t)", value, found)
+	}
+	if !strings.Contains(commandLine, "--secret-id arn:aws:secretsmanager:us-east-1:123456789012:secret:myapp") {
+		t.Errorf("Expected the mapped secret ARN to be read but command was [%s]", commandLine)
+	}
+
+	source.Resolve("CLIENT_ID")
+	if !strings.Contains(commandLine, "--secret-id CLIENT_ID ") {
+		t.Errorf("Expected an unmapped key to be read from the secret named after it but command was [%s]", commandLine)
+	}
+}
+
+func TestAWSSecretsManagerSourceJSONSecret(t *testing.T) {
+	var commandLine string
+	calls := 0
+	run := stubRunner(&commandLine, `{"CLIENT_ID": "safeid", "CLIENT_SECRET": "safesecret"}`, nil)
+	source := &awsSecretsManagerSource{jsonSecretId: "myapp/prod", run: func(name string, args ...string) ([]byte, error) {
+		calls++
+		return run(name, args...)
+	}}
+
+	for key, expected := range map[string]string{"CLIENT_ID": "safeid", "CLIENT_SECRET": "safesecret"} {
+		value, found, err := source.Resolve(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !found || value != expected {
+			t.Errorf("Expected [%s] for [%s] but got [%s] (found: %t)", expected, key, value, found)
+		}
+	}
+
+	if _, found, _ := source.Resolve("OTHER"); found {
+		t.Errorf("Field absent from the JSON secret should not be found")
+	}
+	if calls != 1 {
+		t.Errorf("Expected the JSON secret to be read once but was read %d times", calls)
+	}
+}
+
+func TestAWSSecretsManagerSourceMissingSecret(t *testing.T) {
+	var commandLine string
+	source := &awsSecretsManagerSource{run: stubRunner(&commandLine, "", errors.New("exit status 254: An error occurred (ResourceNotFoundException) when calling the GetSecretValue operation"))}
+
+	_, found, err := source.Resolve("CLIENT_ID")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found {
+		t.Errorf("Missing secret should be reported as not found")
+	}
+}
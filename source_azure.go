@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// azureKeyVaultSource resolves keys from the secrets of an Azure Key Vault with the az CLI, which authenticates
+// with the az login session, a managed identity or the service principal of the pipeline. Since secret names can
+// only contain alphanumeric characters and dashes, a key is read from the secret named after it with underscores
+// replaced by dashes (CLIENT_ID is read from CLIENT-ID) unless mapped to another name.
+type azureKeyVaultSource struct {
+	vaultName string
+	// secretNames maps keys to the name of the secret holding their value
+	secretNames map[string]string
+	run         commandRunner
+}
+
+func (s *azureKeyVaultSource) Resolve(key string) (string, bool, error) {
+	if s.vaultName == "" {
+		return "", false, errors.New("Azure Key Vault name not set, use --azure-vault-name")
+	}
+
+	name, mapped := s.secretNames[key]
+	if !mapped {
+		name = strings.Replace(key, "_", "-", -1)
+	}
+
+	out, err := s.run("az", "keyvault", "secret", "show", "--vault-name", s.vaultName, "--name", name, "--query", "value", "--output", "tsv")
+	if err != nil {
+		switch {
+		case errors.Is(err, exec.ErrNotFound):
+			return "", false, errors.New("Azure CLI (az) not found in PATH, see https://learn.microsoft.com/cli/azure/install-azure-cli")
+		case strings.Contains(err.Error(), "SecretNotFound"):
+			return "", false, nil
+		case strings.Contains(err.Error(), "az login"):
+			return "", false, errors.New("Azure CLI (az) is not logged in, run `az login` first")
+		default:
+			return "", false, fmt.Errorf("Error reading secret [%s] from Azure Key Vault [%s]: %s", name, s.vaultName, err)
+		}
+	}
+
+	return strings.TrimSuffix(string(out), "\n"), true, nil
+}
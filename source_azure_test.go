@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestAzureKeyVaultSource(t *testing.T) {
+	var commandLine string
+	source := &azureKeyVaultSource{vaultName: "myvault", secretNames: map[string]string{"CLIENT_ID": "oauth-client"}, run: stubRunner(&commandLine, "safesecret\n", nil)}
+
+	value, found, err := source.Resolve("CLIENT_SECRET")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found || value != "safesecret" {
+		t.Errorf("Expected [safesecret] but got [%s] (found: %t)", value, found)
+	}
+	if !strings.Contains(commandLine, "--vault-name myvault --name CLIENT-SECRET ") {
+		t.Errorf("Expected the secret named after the key with dashes to be read but command was [%s]", commandLine)
+	}
+
+	source.Resolve("CLIENT_ID")
+	if !strings.Contains(commandLine, "--name oauth-client ") {
+		t.Errorf("Expected the mapped secret to be read but command was [%s]", commandLine)
+	}
+}
+
+func TestAzureKeyVaultSourceErrors(t *testing.T) {
+	var commandLine string
+	source := &azureKeyVaultSource{vaultName: "myvault", run: stubRunner(&commandLine, "", errors.New("exit status 3: ERROR: (SecretNotFound) A secret with (name/id) CLIENT-ID was not found in this key vault."))}
+	if _, found, err := source.Resolve("CLIENT_ID"); found || err != nil {
+		t.Errorf("Missing secret should be reported as not found but got (found: %t, err: %v)", found, err)
+	}
+
+	source.run = stubRunner(&commandLine, "", errors.New("exit status 1: ERROR: Please run 'az login' to setup account."))
+	if _, _, err := source.Resolve("CLIENT_ID"); err == nil || !strings.Contains(err.Error(), "not logged in") {
+		t.Errorf("Expected an error about az not being logged in but got [%v]", err)
+	}
+}
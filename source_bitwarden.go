@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sync"
+)
+
+// bitwardenSource resolves keys from Bitwarden Secrets Manager with the bws CLI, authenticated with the access
+// token of a machine account in BWS_ACCESS_TOKEN. The secrets the machine account can read (in the project, if
+// set) are listed at once, and a key is read from the secret named after it unless mapped to the ID or the name of
+// another secret.
+type bitwardenSource struct {
+	// project is the ID of the project of the secrets, all the projects of the machine account if empty
+	project string
+	// secrets maps keys to the ID or name of the secret holding their value
+	secrets map[string]string
+	run     commandRunner
+
+	once   sync.Once
+	byID   map[string]string
+	byName map[string][]string
+	err    error
+}
+
+// bitwardenSecret is a secret in the output of bws secret list
+type bitwardenSecret struct {
+	ID    string `json:"id"`
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+func (s *bitwardenSource) Resolve(key string) (string, bool, error) {
+	s.once.Do(func() {
+		s.err = s.list()
+	})
+	if s.err != nil {
+		return "", false, s.err
+	}
+
+	ref := key
+	if mapped, ok := s.secrets[key]; ok {
+		ref = mapped
+	}
+	if value, found := s.byID[ref]; found {
+		return value, true, nil
+	}
+	switch values := s.byName[ref]; len(values) {
+	case 0:
+		return "", false, nil
+	case 1:
+		return values[0], true, nil
+	default:
+		return "", false, fmt.Errorf("Several Bitwarden secrets named [%s], map [%s] to the ID of one with --bws-secret", ref, key)
+	}
+}
+
+// list reads the secrets of the project, by ID and by name
+func (s *bitwardenSource) list() error {
+	args := []string{"secret", "list"}
+	if s.project != "" {
+		args = append(args, s.project)
+	}
+	out, err := s.run("bws", append(args, "--output", "json")...)
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return errors.New("Bitwarden Secrets Manager CLI (bws) not found in PATH, see https://bitwarden.com/help/secrets-manager-cli")
+		}
+		return fmt.Errorf("Error listing Bitwarden secrets: %s", err)
+	}
+
+	var secrets []bitwardenSecret
+	if err := json.Unmarshal(out, &secrets); err != nil {
+		return fmt.Errorf("Invalid output of bws secret list: %s", err)
+	}
+	s.byID = make(map[string]string, len(secrets))
+	s.byName = make(map[string][]string, len(secrets))
+	for _, secret := range secrets {
+		s.byID[secret.ID] = secret.Value
+		s.byName[secret.Key] = append(s.byName[secret.Key], secret.Value)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestBitwardenSource(t *testing.T) {
+	var commandLine string
+	calls := 0
+	run := stubRunner(&commandLine, `[
+		{"id": "be8e0ad8-d545-4017-a55a-b02f014d4158", "organizationId": "org", "projectId": "project", "key": "DB_PASSWORD", "value": "s3cr3t"},
+		{"id": "2c8d1fa3-7e0b-4a6c-9d35-b0300125e3c1", "organizationId": "org", "projectId": "project", "key": "stripe-key", "value": "sk_live"},
+		{"id": "4bd2e8ca-33f6-46a8-8d1c-b03001260a55", "organizationId": "org", "projectId": "project", "key": "TOKEN", "value": "one"},
+		{"id": "f0310b54-63a2-4f0e-a4f7-b03001261b7e", "organizationId": "org", "projectId": "project", "key": "TOKEN", "value": "two"}
+	]`, nil)
+	source := &bitwardenSource{project: "project", secrets: map[string]string{"STRIPE_KEY": "stripe-key", "SESSION_TOKEN": "f0310b54-63a2-4f0e-a4f7-b03001261b7e"}, run: func(name string, args ...string) ([]byte, error) {
+		calls++
+		return run(name, args...)
+	}}
+
+	for key, expected := range map[string]string{"DB_PASSWORD": "s3cr3t", "STRIPE_KEY": "sk_live", "SESSION_TOKEN": "two"} {
+		value, found, err := source.Resolve(key)
+		if err != nil || !found || value != expected {
+			t.Errorf("Expected [%s] to be [%s] but got [%s] (found: %t, err: %v)", key, expected, value, found, err)
+		}
+	}
+	if _, found, err := source.Resolve("MISSING"); found || err != nil {
+		t.Errorf("Expected a key without a secret to be missing but got (found: %t, err: %v)", found, err)
+	}
+	if _, _, err := source.Resolve("TOKEN"); err == nil || !strings.Contains(err.Error(), "--bws-secret") {
+		t.Errorf("Expected a key matching several secrets to fail but got [%v]", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected the secrets to be listed once but they were listed %d times", calls)
+	}
+	if commandLine != "bws secret list project --output json" {
+		t.Errorf("Unexpected bws invocation [%s]", commandLine)
+	}
+}
+
+func TestBitwardenSourceMissingCLI(t *testing.T) {
+	var commandLine string
+	source := &bitwardenSource{run: stubRunner(&commandLine, "", exec.ErrNotFound)}
+
+	if _, _, err := source.Resolve("DB_PASSWORD"); err == nil || !strings.Contains(err.Error(), "bws") {
+		t.Errorf("Expected a missing bws CLI to be reported but got [%v]", err)
+	}
+	if commandLine != "bws secret list --output json" {
+		t.Errorf("Unexpected bws invocation [%s]", commandLine)
+	}
+
+	failing := &bitwardenSource{run: stubRunner(&commandLine, "", errors.New("exit status 1: Missing access token"))}
+	if _, _, err := failing.Resolve("DB_PASSWORD"); err == nil || !strings.Contains(err.Error(), "Missing access token") {
+		t.Errorf("Expected the bws error to be reported but got [%v]", err)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultDockerSecretsDir is the directory Docker and Swarm mount secrets in
+const defaultDockerSecretsDir = "/run/secrets"
+
+// dockerSecretsSource resolves keys from the files of mounted Docker or Swarm secrets, named after the key as is or
+// in lower case (e.g. /run/secrets/db_password for DB_PASSWORD)
+type dockerSecretsSource struct {
+	dir string
+}
+
+func (s dockerSecretsSource) Resolve(key string) (string, bool, error) {
+	dir := valueOr(s.dir, defaultDockerSecretsDir)
+	for _, name := range []string{key, strings.ToLower(key)} {
+		content, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", false, fmt.Errorf("Error reading the Docker secret [%s]: %s", name, err)
+		}
+		// Secrets created from files usually end with a newline that isn't part of the value
+		value := strings.TrimSuffix(string(content), "\n")
+		return strings.TrimSuffix(value, "\r"), true, nil
+	}
+	return "", false, nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDockerSecretsSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for name, content := range map[string]string{"db_password": "s3cr3t\n", "API_KEY": "key\r\n", "MULTILINE": "line1\nline2\n"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0400); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	source := dockerSecretsSource{dir: dir}
+	for key, expected := range map[string]string{"DB_PASSWORD": "s3cr3t", "API_KEY": "key", "MULTILINE": "line1\nline2"} {
+		value, found, err := source.Resolve(key)
+		if err != nil || !found || value != expected {
+			t.Errorf("Expected [%s] to be [%s] but got [%s] (found: %t, err: %v)", key, expected, value, found, err)
+		}
+	}
+	if _, found, err := source.Resolve("MISSING"); err != nil || found {
+		t.Errorf("Expected a key without a secret to be missing but got (found: %t, err: %v)", found, err)
+	}
+}
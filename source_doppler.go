@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// dopplerEndpoint is the base URL of the Doppler API
+const dopplerEndpoint = "https://api.doppler.com"
+
+// dopplerSource resolves keys from the secrets of a Doppler config (https://www.doppler.com), downloaded once with the
+// API using DOPPLER_TOKEN. Project and config can be omitted with service tokens since those are scoped to a config.
+type dopplerSource struct {
+	project  string
+	config   string
+	endpoint string
+	client   *http.Client
+	getenv   func(string) string
+
+	once    sync.Once
+	secrets map[string]string
+	err     error
+}
+
+func newDopplerSource(project string, config string) *dopplerSource {
+	return &dopplerSource{project: project, config: config, endpoint: dopplerEndpoint, client: http.DefaultClient, getenv: os.Getenv}
+}
+
+func (s *dopplerSource) Resolve(key string) (string, bool, error) {
+	s.once.Do(func() {
+		s.secrets, s.err = s.download()
+	})
+	if s.err != nil {
+		return "", false, s.err
+	}
+
+	value, found := s.secrets[key]
+	return value, found, nil
+}
+
+// download returns all the secrets of the config
+func (s *dopplerSource) download() (map[string]string, error) {
+	token := s.getenv("DOPPLER_TOKEN")
+	if token == "" {
+		return nil, errors.New("DOPPLER_TOKEN not set")
+	}
+
+	query := url.Values{"format": {"json"}}
+	if s.project != "" {
+		query.Set("project", s.project)
+	}
+	if s.config != "" {
+		query.Set("config", s.config)
+	}
+
+	request, err := http.NewRequest("GET", fmt.Sprintf("%s/v3/configs/config/secrets/download?%s", s.endpoint, query.Encode()), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.SetBasicAuth(token, "")
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	content, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Error downloading Doppler secrets, status %d: %s", response.StatusCode, strings.TrimSpace(string(content)))
+	}
+
+	secrets := make(map[string]string)
+	if err := json.Unmarshal(content, &secrets); err != nil {
+		return nil, err
+	}
+
+	return secrets, nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDopplerSource(t *testing.T) {
+	downloads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, _, _ := r.BasicAuth()
+		if token != "dp.st.dev.token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"messages": ["Invalid auth token"], "success": false}`))
+			return
+		}
+		if r.URL.Path != "/v3/configs/config/secrets/download" || r.URL.Query().Get("project") != "myapp" || r.URL.Query().Get("config") != "dev" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		downloads++
+		w.Write([]byte(`{"CLIENT_ID": "safeid", "CLIENT_SECRET": "safesecret"}`))
+	}))
+	defer server.Close()
+
+	source := newDopplerSource("myapp", "dev")
+	source.endpoint = server.URL
+	source.getenv = fakeEnv(map[string]string{"DOPPLER_TOKEN": "dp.st.dev.token"})
+
+	for key, expected := range map[string]string{"CLIENT_ID": "safeid", "CLIENT_SECRET": "safesecret"} {
+		value, found, err := source.Resolve(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !found || value != expected {
+			t.Errorf("Expected [%s] for [%s] but got [%s] (found: %t)", expected, key, value, found)
+		}
+	}
+
+	if _, found, _ := source.Resolve("OTHER"); found {
+		t.Errorf("Key absent from the config should not be found")
+	}
+	if downloads != 1 {
+		t.Errorf("Expected secrets to be downloaded once but were downloaded %d times", downloads)
+	}
+
+	unauthorized := newDopplerSource("myapp", "dev")
+	unauthorized.endpoint = server.URL
+	unauthorized.getenv = fakeEnv(map[string]string{"DOPPLER_TOKEN": "revoked"})
+	if _, _, err := unauthorized.Resolve("CLIENT_ID"); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Expected an authentication error but got [%v]", err)
+	}
+}
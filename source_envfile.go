@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+// loadEnvFile loads the values of the dotenv file at path
+func loadEnvFile(path string) (safekeeper.MapSource, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	values, err := parseEnvFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	return values, nil
+}
+
+// parseEnvFile parses dotenv content: one KEY=VALUE per line, optionally prefixed by export. Values
+// can be single-quoted (taken literally), double-quoted (supporting \n, \t, \" and \\ escapes) or bare, in
+// which case a # preceded by whitespace starts a comment. Blank lines and lines starting with # are ignored.
+func parseEnvFile(r io.Reader) (map[string]string, error) {
+	values := make(map[string]string)
+	// Lines are read whole since values can be of any length (e.g. base64 certificates)
+	reader := bufio.NewReader(r)
+
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if err == io.EOF && line == "" {
+			break
+		}
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+		separator := strings.Index(line, "=")
+		if separator <= 0 {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", lineNumber)
+		}
+
+		key := strings.TrimSpace(line[:separator])
+		if !isValidKey(key) {
+			return nil, fmt.Errorf("line %d: invalid key [%s]", lineNumber, key)
+		}
+
+		value, err := parseEnvValue(strings.TrimSpace(line[separator+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", lineNumber, err)
+		}
+		values[key] = value
+	}
+
+	return values, nil
+}
+
+// parseEnvValue parses the value part of a dotenv line
+func parseEnvValue(raw string) (string, error) {
+	if raw == "" {
+		return "", nil
+	}
+
+	switch raw[0] {
+	case '\'':
+		end := strings.Index(raw[1:], "'")
+		if end < 0 {
+			return "", errors.New("unterminated single-quoted value")
+		}
+		return raw[1 : end+1], nil
+	case '"':
+		var value strings.Builder
+		for i := 1; i < len(raw); i++ {
+			c := raw[i]
+			switch {
+			case c == '"':
+				return value.String(), nil
+			case c == '\\' && i+1 < len(raw):
+				i++
+				switch raw[i] {
+				case 'n':
+					value.WriteByte('\n')
+				case 't':
+					value.WriteByte('\t')
+				case 'r':
+					value.WriteByte('\r')
+				default:
+					value.WriteByte(raw[i])
+				}
+			default:
+				value.WriteByte(c)
+			}
+		}
+		return "", errors.New("unterminated double-quoted value")
+	default:
+		if comment := strings.Index(raw, " #"); comment >= 0 {
+			raw = raw[:comment]
+		}
+		return strings.TrimSpace(raw), nil
+	}
+}
+
+// isValidKey reports whether key is a valid environment variable name
+func isValidKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i, c := range key {
+		if !(c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || i > 0 && c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseEnvFile(t *testing.T) {
+	content := `# Application secrets
+CLIENT_ID=safeid
+export CLIENT_SECRET="safe \"secret\"\nline2" # trailing comment
+PASSWORD='p@ss # not a comment'
+BARE=value with spaces # comment
+
+EMPTY=
+`
+	values, err := parseEnvFile(strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"CLIENT_ID":     "safeid",
+		"CLIENT_SECRET": "safe \"secret\"\nline2",
+		"PASSWORD":      "p@ss # not a comment",
+		"BARE":          "value with spaces",
+		"EMPTY":         "",
+	}
+
+	if len(values) != len(expected) {
+		t.Errorf("Expected %d values but got %d: %v", len(expected), len(values), values)
+	}
+	for key, value := range expected {
+		if values[key] != value {
+			t.Errorf("Expected [%s] to be [%s] but was [%s]", key, value, values[key])
+		}
+	}
+}
+
+func TestParseEnvFileLongLines(t *testing.T) {
+	// Longer than the 64KiB lines of bufio.Scanner, without a final newline
+	certificate := strings.Repeat("TUlJQ", 20000)
+	values, err := parseEnvFile(strings.NewReader("CERT=" + certificate + "\nCLIENT_ID=safeid"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values["CERT"] != certificate || values["CLIENT_ID"] != "safeid" {
+		t.Errorf("Expected the long value and the last line to be read but got %d values", len(values))
+	}
+}
+
+func TestParseEnvFileErrors(t *testing.T) {
+	for _, content := range []string{"NOT A PAIR", "KEY=\"unterminated", "1KEY=value"} {
+		_, err := parseEnvFile(strings.NewReader("OK=1\n" + content))
+		if err == nil || !strings.HasPrefix(err.Error(), "line 2") {
+			t.Errorf("Expected an error on line 2 for [%s] but got [%v]", content, err)
+		}
+	}
+}
+
+func TestEnvFilePrecedence(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	first := filepath.Join(tempDir, "first.env")
+	second := filepath.Join(tempDir, "second.env")
+	if err := ioutil.WriteFile(first, []byte("SAFEKEEPER_TEST_A=first\nSAFEKEEPER_TEST_B=first\nSAFEKEEPER_TEST_C=first\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(second, []byte("SAFEKEEPER_TEST_B=second\nSAFEKEEPER_TEST_C=second\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("SAFEKEEPER_TEST_C", "env")
+	defer os.Unsetenv("SAFEKEEPER_TEST_C")
+
+	source, err := newSecretSource(options{envFiles: []string{first, second}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := loadKeyValues(context.Background(), []string{"SAFEKEEPER_TEST_A", "SAFEKEEPER_TEST_B", "SAFEKEEPER_TEST_C"}, source, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{"SAFEKEEPER_TEST_A": "first", "SAFEKEEPER_TEST_B": "second", "SAFEKEEPER_TEST_C": "env"}
+	for key, value := range expected {
+		if values[key] != value {
+			t.Errorf("Expected [%s] to be [%s] but was [%s]", key, value, values[key])
+		}
+	}
+}
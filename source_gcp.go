@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// gcpSecretManagerEndpoint is the base URL of the Google Secret Manager API
+const gcpSecretManagerEndpoint = "https://secretmanager.googleapis.com/v1"
+
+// gcpSecretManagerSource resolves keys from Google Secret Manager, reading projects/<project>/secrets/<name>/versions/latest
+// where name is the key unless mapped to another secret name (or full version resource name). It authenticates with
+// Application Default Credentials, the access token being taken from GOOGLE_OAUTH_ACCESS_TOKEN or obtained with
+// gcloud auth application-default print-access-token.
+type gcpSecretManagerSource struct {
+	project string
+	// secretNames maps keys to the name of the secret holding their value
+	secretNames map[string]string
+	endpoint    string
+	client      *http.Client
+	run         commandRunner
+	getenv      func(string) string
+
+	once  sync.Once
+	token string
+	err   error
+}
+
+func newGCPSecretManagerSource(project string, secretNames map[string]string) *gcpSecretManagerSource {
+	return &gcpSecretManagerSource{
+		project:     project,
+		secretNames: secretNames,
+		endpoint:    gcpSecretManagerEndpoint,
+		client:      http.DefaultClient,
+		run:         execCommand,
+		getenv:      os.Getenv,
+	}
+}
+
+func (s *gcpSecretManagerSource) Resolve(key string) (string, bool, error) {
+	resource, err := s.resourceName(key)
+	if err != nil {
+		return "", false, err
+	}
+
+	s.once.Do(func() {
+		s.token, s.err = s.accessToken()
+	})
+	if s.err != nil {
+		return "", false, s.err
+	}
+
+	request, err := http.NewRequest("GET", fmt.Sprintf("%s/%s:access", s.endpoint, resource), nil)
+	if err != nil {
+		return "", false, err
+	}
+	request.Header.Set("Authorization", "Bearer "+s.token)
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return "", false, err
+	}
+	defer response.Body.Close()
+
+	content, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return "", false, err
+	}
+
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return "", false, nil
+	default:
+		return "", false, fmt.Errorf("Error accessing GCP secret [%s], status %d: %s", resource, response.StatusCode, strings.TrimSpace(string(content)))
+	}
+
+	var version struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(content, &version); err != nil {
+		return "", false, err
+	}
+
+	value, err := base64.StdEncoding.DecodeString(version.Payload.Data)
+	if err != nil {
+		return "", false, err
+	}
+
+	return string(value), true, nil
+}
+
+// resourceName returns the resource name of the secret version holding the value of key
+func (s *gcpSecretManagerSource) resourceName(key string) (string, error) {
+	name := key
+	if mapped, ok := s.secretNames[key]; ok {
+		name = mapped
+	}
+
+	if strings.HasPrefix(name, "projects/") {
+		if !strings.Contains(name, "/versions/") {
+			name = name + "/versions/latest"
+		}
+		return name, nil
+	}
+
+	if s.project == "" {
+		return "", errors.New("GCP project not set, use --gcp-project or map keys to full secret resource names")
+	}
+	return fmt.Sprintf("projects/%s/secrets/%s/versions/latest", s.project, name), nil
+}
+
+// accessToken returns an OAuth access token from the Application Default Credentials
+func (s *gcpSecretManagerSource) accessToken() (string, error) {
+	if token := s.getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		return token, nil
+	}
+
+	out, err := s.run("gcloud", "auth", "application-default", "print-access-token")
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return "", errors.New("gcloud not found in PATH, install the Google Cloud SDK or set GOOGLE_OAUTH_ACCESS_TOKEN")
+		}
+		return "", fmt.Errorf("Error getting Application Default Credentials, run `gcloud auth application-default login`: %s", err)
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newGCPTestServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer adc-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/projects/myproject/secrets/client-secret/versions/latest:access":
+			// base64 of safesecret
+			w.Write([]byte(`{"name": "projects/myproject/secrets/client-secret/versions/3", "payload": {"data": "c2FmZXNlY3JldA=="}}`))
+		case "/projects/other/secrets/CLIENT_ID/versions/2:access":
+			// base64 of safeid
+			w.Write([]byte(`{"payload": {"data": "c2FmZWlk"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestGCPSecretManagerSource(t *testing.T) {
+	server := newGCPTestServer(t)
+	defer server.Close()
+
+	var commandLine string
+	source := newGCPSecretManagerSource("myproject", map[string]string{
+		"CLIENT_SECRET": "client-secret",
+		"CLIENT_ID":     "projects/other/secrets/CLIENT_ID/versions/2",
+	})
+	source.endpoint = server.URL
+	source.run = stubRunner(&commandLine, "adc-token\n", nil)
+	source.getenv = fakeEnv(nil)
+
+	for key, expected := range map[string]string{"CLIENT_SECRET": "safesecret", "CLIENT_ID": "safeid"} {
+		value, found, err := source.Resolve(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !found || value != expected {
+			t.Errorf("Expected [%s] for [%s] but got [%s] (found: %t)", expected, key, value, found)
+		}
+	}
+
+	if commandLine != "gcloud auth application-default print-access-token" {
+		t.Errorf("Expected the access token to come from gcloud but command was [%s]", commandLine)
+	}
+
+	if _, found, err := source.Resolve("OTHER"); found || err != nil {
+		t.Errorf("Missing secret should be reported as not found but got (found: %t, err: %v)", found, err)
+	}
+}
+
+func TestGCPSecretManagerSourceWithoutProject(t *testing.T) {
+	source := newGCPSecretManagerSource("", nil)
+	source.getenv = fakeEnv(map[string]string{"GOOGLE_OAUTH_ACCESS_TOKEN": "adc-token"})
+
+	_, _, err := source.Resolve("CLIENT_ID")
+	if err == nil || !strings.Contains(err.Error(), "--gcp-project") {
+		t.Fatalf("Expected an error about the missing project but got [%v]", err)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// infisicalEndpoint is the base URL of the Infisical API, unless self-hosted
+const infisicalEndpoint = "https://app.infisical.com"
+
+// defaultInfisicalEnv is the environment of the Infisical secrets unless configured otherwise
+const defaultInfisicalEnv = "dev"
+
+// infisicalSource resolves keys from the secrets of an environment of an Infisical project (https://infisical.com),
+// downloaded once with the API. It authenticates with the service token of INFISICAL_TOKEN or else with a machine
+// identity using universal auth (INFISICAL_UNIVERSAL_AUTH_CLIENT_ID and INFISICAL_UNIVERSAL_AUTH_CLIENT_SECRET).
+// Keys are the names of the secrets, which Infisical already spells as environment variables.
+type infisicalSource struct {
+	// project is the ID of the project (workspace) of the secrets
+	project     string
+	environment string
+	// path is the folder of the secrets, / if empty
+	path     string
+	endpoint string
+	client   *http.Client
+	getenv   func(string) string
+
+	once    sync.Once
+	secrets map[string]string
+	err     error
+}
+
+// infisicalSecrets is the response of the API listing the secrets of a folder
+type infisicalSecrets struct {
+	Secrets []struct {
+		Key   string `json:"secretKey"`
+		Value string `json:"secretValue"`
+	} `json:"secrets"`
+}
+
+func newInfisicalSource(project string, environment string, path string) *infisicalSource {
+	return &infisicalSource{project: project, environment: environment, path: path, endpoint: infisicalEndpoint, client: http.DefaultClient, getenv: os.Getenv}
+}
+
+func (s *infisicalSource) Resolve(key string) (string, bool, error) {
+	s.once.Do(func() {
+		s.secrets, s.err = s.download()
+	})
+	if s.err != nil {
+		return "", false, s.err
+	}
+
+	value, found := s.secrets[key]
+	return value, found, nil
+}
+
+// download returns all the secrets of the folder
+func (s *infisicalSource) download() (map[string]string, error) {
+	token, err := s.token()
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{"environment": {valueOr(s.environment, defaultInfisicalEnv)}, "secretPath": {valueOr(s.path, "/")}}
+	if s.project != "" {
+		query.Set("workspaceId", s.project)
+	}
+	request, err := http.NewRequest("GET", fmt.Sprintf("%s/api/v3/secrets/raw?%s", s.endpointURL(), query.Encode()), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Authorization", "Bearer "+token)
+
+	content, err := s.do(request, "downloading Infisical secrets")
+	if err != nil {
+		return nil, err
+	}
+	var listed infisicalSecrets
+	if err := json.Unmarshal(content, &listed); err != nil {
+		return nil, fmt.Errorf("Invalid Infisical secrets: %s", err)
+	}
+
+	secrets := make(map[string]string, len(listed.Secrets))
+	for _, secret := range listed.Secrets {
+		secrets[secret.Key] = secret.Value
+	}
+	return secrets, nil
+}
+
+// token returns the service token or else the access token of the universal auth login of the machine identity
+func (s *infisicalSource) token() (string, error) {
+	if token := s.getenv("INFISICAL_TOKEN"); token != "" {
+		return token, nil
+	}
+	clientId, clientSecret := s.getenv("INFISICAL_UNIVERSAL_AUTH_CLIENT_ID"), s.getenv("INFISICAL_UNIVERSAL_AUTH_CLIENT_SECRET")
+	if clientId == "" || clientSecret == "" {
+		return "", errors.New("INFISICAL_TOKEN or INFISICAL_UNIVERSAL_AUTH_CLIENT_ID and INFISICAL_UNIVERSAL_AUTH_CLIENT_SECRET not set")
+	}
+
+	body, err := json.Marshal(map[string]string{"clientId": clientId, "clientSecret": clientSecret})
+	if err != nil {
+		return "", err
+	}
+	request, err := http.NewRequest("POST", s.endpointURL()+"/api/v1/auth/universal-auth/login", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	content, err := s.do(request, "logging in to Infisical")
+	if err != nil {
+		return "", err
+	}
+	var login struct {
+		AccessToken string `json:"accessToken"`
+	}
+	if err := json.Unmarshal(content, &login); err != nil || login.AccessToken == "" {
+		return "", errors.New("Error logging in to Infisical: no access token in the response")
+	}
+	return login.AccessToken, nil
+}
+
+// do sends request and returns the body of its successful response, failing with what it was doing otherwise
+func (s *infisicalSource) do(request *http.Request, doing string) ([]byte, error) {
+	response, err := s.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	content, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Error %s, status %d: %s", doing, response.StatusCode, strings.TrimSpace(string(content)))
+	}
+	return content, nil
+}
+
+// endpointURL returns the base URL of the API, without the /api of INFISICAL_API_URL style URLs
+func (s *infisicalSource) endpointURL() string {
+	return strings.TrimSuffix(strings.TrimSuffix(s.endpoint, "/"), "/api")
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestInfisicalSource(t *testing.T) {
+	downloads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/auth/universal-auth/login":
+			var credentials map[string]string
+			json.NewDecoder(r.Body).Decode(&credentials)
+			if credentials["clientId"] != "machine" || credentials["clientSecret"] != "machine-secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"accessToken": "access-token", "expiresIn": 7200}`))
+		case "/api/v3/secrets/raw":
+			if auth := r.Header.Get("Authorization"); auth != "Bearer st.service-token" && auth != "Bearer access-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"message": "Invalid token"}`))
+				return
+			}
+			query := r.URL.Query()
+			if query.Get("workspaceId") != "project-id" || query.Get("environment") != "prod" || query.Get("secretPath") != "/backend" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			downloads++
+			w.Write([]byte(`{"secrets": [{"secretKey": "CLIENT_ID", "secretValue": "safeid"}, {"secretKey": "CLIENT_SECRET", "secretValue": "safesecret"}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	for name, env := range map[string]map[string]string{
+		"service token":  {"INFISICAL_TOKEN": "st.service-token"},
+		"universal auth": {"INFISICAL_UNIVERSAL_AUTH_CLIENT_ID": "machine", "INFISICAL_UNIVERSAL_AUTH_CLIENT_SECRET": "machine-secret"},
+	} {
+		downloads = 0
+		source := newInfisicalSource("project-id", "prod", "/backend")
+		source.endpoint = server.URL + "/api"
+		source.getenv = fakeEnv(env)
+
+		for key, expected := range map[string]string{"CLIENT_ID": "safeid", "CLIENT_SECRET": "safesecret"} {
+			value, found, err := source.Resolve(key)
+			if err != nil {
+				t.Fatalf("%s: %s", name, err)
+			}
+			if !found || value != expected {
+				t.Errorf("%s: expected [%s] for [%s] but got [%s] (found: %t)", name, expected, key, value, found)
+			}
+		}
+		if _, found, _ := source.Resolve("OTHER"); found {
+			t.Errorf("%s: key absent from the environment should not be found", name)
+		}
+		if downloads != 1 {
+			t.Errorf("%s: expected secrets to be downloaded once but were downloaded %d times", name, downloads)
+		}
+	}
+
+	unauthorized := newInfisicalSource("project-id", "prod", "/backend")
+	unauthorized.endpoint = server.URL
+	unauthorized.getenv = fakeEnv(map[string]string{"INFISICAL_TOKEN": "revoked"})
+	if _, _, err := unauthorized.Resolve("CLIENT_ID"); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Expected an authentication error but got [%v]", err)
+	}
+
+	unconfigured := newInfisicalSource("project-id", "prod", "")
+	unconfigured.getenv = fakeEnv(nil)
+	if _, _, err := unconfigured.Resolve("CLIENT_ID"); err == nil || !strings.Contains(err.Error(), "INFISICAL_TOKEN") {
+		t.Errorf("Expected missing credentials to fail but got [%v]", err)
+	}
+}
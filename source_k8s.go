@@ -0,0 +1,184 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// serviceAccountDir is where Kubernetes mounts the service account credentials of a pod
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// kubernetesSecretSource resolves keys from the data of a Kubernetes Secret. Inside a cluster, the Secret is read
+// from the API server with the pod's service account. Elsewhere, it is read with kubectl and the current kubeconfig.
+type kubernetesSecretSource struct {
+	name      string
+	namespace string
+	context   string
+	run       commandRunner
+	getenv    func(string) string
+	// serviceAccountDir is the directory holding the service account token, CA certificate and namespace
+	serviceAccountDir string
+
+	once sync.Once
+	data map[string]string
+	err  error
+}
+
+// kubernetesSecret is the part of a Kubernetes Secret object safekeeper reads
+type kubernetesSecret struct {
+	Data map[string]string `json:"data"`
+}
+
+func newKubernetesSecretSource(name string, namespace string, context string) *kubernetesSecretSource {
+	return &kubernetesSecretSource{
+		name:              name,
+		namespace:         namespace,
+		context:           context,
+		run:               execCommand,
+		getenv:            os.Getenv,
+		serviceAccountDir: serviceAccountDir,
+	}
+}
+
+func (s *kubernetesSecretSource) Resolve(key string) (string, bool, error) {
+	s.once.Do(func() {
+		s.data, s.err = s.readSecret()
+	})
+	if s.err != nil {
+		return "", false, s.err
+	}
+
+	value, found := s.data[key]
+	return value, found, nil
+}
+
+// readSecret reads the Secret and returns its decoded data
+func (s *kubernetesSecretSource) readSecret() (map[string]string, error) {
+	if s.name == "" {
+		return nil, errors.New("Kubernetes Secret name not set, use --k8s-secret")
+	}
+
+	var content []byte
+	var err error
+	if host := s.getenv("KUBERNETES_SERVICE_HOST"); host != "" {
+		content, err = s.readInCluster(host, s.getenv("KUBERNETES_SERVICE_PORT"))
+	} else {
+		content, err = s.readWithKubectl()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var secret kubernetesSecret
+	if err := json.Unmarshal(content, &secret); err != nil {
+		return nil, err
+	}
+
+	data := make(map[string]string)
+	for key, encoded := range secret.Data {
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid data for [%s] in Kubernetes Secret [%s]: %s", key, s.name, err)
+		}
+		data[key] = string(value)
+	}
+
+	return data, nil
+}
+
+// readWithKubectl returns the Secret object read with kubectl
+func (s *kubernetesSecretSource) readWithKubectl() ([]byte, error) {
+	args := []string{"get", "secret", s.name, "--output", "json"}
+	if s.namespace != "" {
+		args = append(args, "--namespace", s.namespace)
+	}
+	if s.context != "" {
+		args = append(args, "--context", s.context)
+	}
+
+	out, err := s.run("kubectl", args...)
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, errors.New("kubectl not found in PATH, see https://kubernetes.io/docs/tasks/tools")
+		}
+		return nil, fmt.Errorf("Error reading Kubernetes Secret [%s] with kubectl: %s", s.name, err)
+	}
+
+	return out, nil
+}
+
+// readInCluster returns the Secret object read from the API server with the service account of the pod
+func (s *kubernetesSecretSource) readInCluster(host string, port string) ([]byte, error) {
+	token, err := ioutil.ReadFile(filepath.Join(s.serviceAccountDir, "token"))
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the service account token: %s", err)
+	}
+
+	namespace := s.namespace
+	if namespace == "" {
+		podNamespace, err := ioutil.ReadFile(filepath.Join(s.serviceAccountDir, "namespace"))
+		if err != nil {
+			return nil, fmt.Errorf("Error reading the pod namespace, use --k8s-namespace: %s", err)
+		}
+		namespace = strings.TrimSpace(string(podNamespace))
+	}
+
+	client, err := s.inClusterClient()
+	if err != nil {
+		return nil, err
+	}
+
+	if port == "" {
+		port = "443"
+	}
+	url := fmt.Sprintf("https://%s/api/v1/namespaces/%s/secrets/%s", net.JoinHostPort(host, port), namespace, s.name)
+	request, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	content, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Error reading Kubernetes Secret [%s/%s], status %d: %s", namespace, s.name, response.StatusCode, strings.TrimSpace(string(content)))
+	}
+
+	return content, nil
+}
+
+// inClusterClient returns an HTTP client trusting the cluster CA certificate
+func (s *kubernetesSecretSource) inClusterClient() (*http.Client, error) {
+	ca, err := ioutil.ReadFile(filepath.Join(s.serviceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the cluster CA certificate: %s", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("Invalid cluster CA certificate")
+	}
+
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}, nil
+}
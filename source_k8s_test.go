@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// base64 of safeid and safesecret
+const testSecretObject = `{"kind": "Secret", "metadata": {"name": "myapp"}, "data": {"CLIENT_ID": "c2FmZWlk", "CLIENT_SECRET": "c2FmZXNlY3JldA=="}}`
+
+func TestKubernetesSecretSourceWithKubectl(t *testing.T) {
+	var commandLine string
+	source := newKubernetesSecretSource("myapp", "prod", "ci")
+	source.run = stubRunner(&commandLine, testSecretObject, nil)
+	source.getenv = fakeEnv(nil)
+
+	value, found, err := source.Resolve("CLIENT_SECRET")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found || value != "safesecret" {
+		t.Errorf("Expected [safesecret] but got [%s] (found: %t)", value, found)
+	}
+
+	expectedCommandLine := "kubectl get secret myapp --output json --namespace prod --context ci"
+	if commandLine != expectedCommandLine {
+		t.Errorf("Expected kubectl to be invoked as [%s] but was [%s]", expectedCommandLine, commandLine)
+	}
+}
+
+func TestKubernetesSecretSourceInCluster(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer sa-token" || r.URL.Path != "/api/v1/namespaces/ci/secrets/myapp" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(testSecretObject))
+	}))
+	defer server.Close()
+
+	accountDir, err := ioutil.TempDir("", "serviceaccount")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(accountDir)
+
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	files := map[string]string{"token": "sa-token\n", "namespace": "ci", "ca.crt": string(ca)}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(accountDir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source := newKubernetesSecretSource("myapp", "", "")
+	source.serviceAccountDir = accountDir
+	source.getenv = fakeEnv(map[string]string{"KUBERNETES_SERVICE_HOST": serverURL.Hostname(), "KUBERNETES_SERVICE_PORT": serverURL.Port()})
+
+	value, found, err := source.Resolve("CLIENT_ID")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found || value != "safeid" {
+		t.Errorf("Expected [safeid] but got [%s] (found: %t)", value, found)
+	}
+}
+
+func TestKubernetesSecretSourceWithoutName(t *testing.T) {
+	source := newKubernetesSecretSource("", "", "")
+	_, _, err := source.Resolve("CLIENT_ID")
+	if err == nil || !strings.Contains(err.Error(), "--k8s-secret") {
+		t.Fatalf("Expected an error about the missing Secret name but got [%v]", err)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/zalando/go-keyring"
+	"golang.org/x/term"
+)
+
+// keyringService is the service name under which safekeeper stores its entries in the OS keyring
+const keyringService = "safekeeper"
+
+// keyringSource resolves keys from the OS keyring (macOS Keychain, Windows Credential Manager or Secret Service on
+// Linux), entries being stored by safekeeper store
+type keyringSource struct{}
+
+func (keyringSource) Resolve(key string) (string, bool, error) {
+	value, err := keyring.Get(keyringService, key)
+	if err == keyring.ErrNotFound {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("Error reading [%s] from the OS keyring: %s", key, err)
+	}
+
+	return value, true, nil
+}
+
+// storeKey stores the value of key in the OS keyring. The value is read from in, with a hidden prompt if in is a
+// terminal, so it never shows up in the shell history.
+func storeKey(key string, in *os.File, prompt io.Writer) error {
+	if !isValidKey(key) {
+		return fmt.Errorf("Invalid key [%s]", key)
+	}
+
+	value, err := readValue(key, in, prompt)
+	if err != nil {
+		return err
+	}
+	if value == "" {
+		return fmt.Errorf("Empty value for [%s], nothing stored", key)
+	}
+
+	return keyring.Set(keyringService, key, value)
+}
+
+// deleteKey deletes the entry of key from the OS keyring
+func deleteKey(key string) error {
+	err := keyring.Delete(keyringService, key)
+	if err == keyring.ErrNotFound {
+		return withExitCode(exitMissingValue, fmt.Errorf("Key [%s] not found in the OS keyring", key))
+	}
+
+	return err
+}
+
+// readValue reads the value of key from in. When in is a terminal, the user is prompted and the input isn't echoed.
+// Otherwise, the first line of in is the value.
+func readValue(key string, in *os.File, prompt io.Writer) (string, error) {
+	if term.IsTerminal(int(in.Fd())) {
+		fmt.Fprintf(prompt, "Value for %s: ", key)
+		value, err := term.ReadPassword(int(in.Fd()))
+		fmt.Fprintln(prompt)
+		return string(value), err
+	}
+
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	if err == io.EOF && line == "" {
+		return "", errors.New(fmt.Sprintf("No value for [%s] on standard input", key))
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zalando/go-keyring"
+)
+
+// valueFile returns a file whose content is value, to stand in for a piped standard input
+func valueFile(t *testing.T, value string) *os.File {
+	tempDir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(tempDir, "stdin")
+	if err := ioutil.WriteFile(path, []byte(value), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestKeyringStoreLookupDelete(t *testing.T) {
+	keyring.MockInit()
+
+	in := valueFile(t, "safesecret\n")
+	defer in.Close()
+	if err := storeKey("CLIENT_SECRET", in, ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+
+	value, found, err := keyringSource{}.Resolve("CLIENT_SECRET")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found || value != "safesecret" {
+		t.Errorf("Expected stored value [safesecret] but got [%s] (found: %t)", value, found)
+	}
+
+	if err := deleteKey("CLIENT_SECRET"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, found, _ := (keyringSource{}).Resolve("CLIENT_SECRET"); found {
+		t.Errorf("Deleted key should not be found anymore")
+	}
+
+	if err := deleteKey("CLIENT_SECRET"); exitCode(err) != exitMissingValue {
+		t.Errorf("Deleting a missing key should fail with exit code %d but got [%v]", exitMissingValue, err)
+	}
+}
+
+func TestKeyringStoreRejectsEmptyValue(t *testing.T) {
+	keyring.MockInit()
+
+	in := valueFile(t, "")
+	defer in.Close()
+	if err := storeKey("CLIENT_SECRET", in, ioutil.Discard); err == nil {
+		t.Fatal("Expected an error when no value is given")
+	}
+}
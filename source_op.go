@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strings"
+)
+
+// defaultOpRefTemplate is the 1Password secret reference a key maps to unless configured otherwise
+const defaultOpRefTemplate = "op://Private/{key}/password"
+
+// opSource resolves keys by reading 1Password secret references with the op CLI
+// (https://developer.1password.com/docs/cli) or, when connect is set, with the API of a 1Password Connect server
+type opSource struct {
+	// refTemplate is the secret reference for a key with {key} standing for the key name
+	refTemplate string
+	// refs maps keys to their secret reference, overriding refTemplate
+	refs    map[string]string
+	run     commandRunner
+	connect *opConnectClient
+}
+
+func (s *opSource) Resolve(key string) (string, bool, error) {
+	ref := s.reference(key)
+	if s.connect != nil {
+		return s.connect.read(ref)
+	}
+
+	out, err := s.run("op", "read", "--no-newline", ref)
+	if err != nil {
+		switch {
+		case errors.Is(err, exec.ErrNotFound):
+			return "", false, errors.New("1Password CLI (op) not found in PATH, see https://developer.1password.com/docs/cli/get-started")
+		case strings.Contains(err.Error(), "signed in"):
+			return "", false, errors.New("1Password CLI (op) is not signed in, run `op signin` first")
+		case strings.Contains(err.Error(), "isn't an item") || strings.Contains(err.Error(), "isn't a field"):
+			return "", false, nil
+		default:
+			return "", false, fmt.Errorf("Error reading [%s] with op: %s", ref, err)
+		}
+	}
+
+	return string(out), len(out) > 0, nil
+}
+
+// reference returns the op:// secret reference for key
+func (s *opSource) reference(key string) string {
+	if ref, ok := s.refs[key]; ok {
+		return ref
+	}
+
+	template := s.refTemplate
+	if template == "" {
+		template = defaultOpRefTemplate
+	}
+	return strings.Replace(template, "{key}", key, -1)
+}
+
+// opConnectClient reads secret references with the API of a 1Password Connect server
+// (https://developer.1password.com/docs/connect), configured by OP_CONNECT_HOST and OP_CONNECT_TOKEN
+type opConnectClient struct {
+	host   string
+	token  string
+	client *http.Client
+}
+
+// opItem is a 1Password item as returned by the Connect API
+type opItem struct {
+	Id     string `json:"id"`
+	Title  string `json:"title"`
+	Fields []struct {
+		Id      string `json:"id"`
+		Label   string `json:"label"`
+		Value   string `json:"value"`
+		Section *struct {
+			Id    string `json:"id"`
+			Label string `json:"label"`
+		} `json:"section"`
+	} `json:"fields"`
+}
+
+// opFilter returns the Connect filter matching the attribute equal to value, its quotes and backslashes escaped
+func opFilter(attribute string, value string) string {
+	return fmt.Sprintf("%s eq \"%s\"", attribute, strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value))
+}
+
+// read returns the value of the field designated by the op://vault/item[/section]/field reference ref
+func (c *opConnectClient) read(ref string) (string, bool, error) {
+	parts := strings.Split(strings.TrimPrefix(ref, "op://"), "/")
+	if !strings.HasPrefix(ref, "op://") || len(parts) < 3 || len(parts) > 4 {
+		return "", false, fmt.Errorf("Invalid 1Password secret reference [%s], expected op://vault/item[/section]/field", ref)
+	}
+	vaultName, itemName, fieldName := parts[0], parts[1], parts[len(parts)-1]
+	sectionName := ""
+	if len(parts) == 4 {
+		sectionName = parts[2]
+	}
+
+	// Names are also matched here, filters being interpreted by the server
+	var vaults []struct {
+		Id   string `json:"id"`
+		Name string `json:"name"`
+	}
+	if err := c.get("/v1/vaults?filter="+url.QueryEscape(opFilter("name", vaultName)), &vaults); err != nil {
+		return "", false, err
+	}
+	vaultId := ""
+	for _, vault := range vaults {
+		if vault.Name == vaultName {
+			vaultId = vault.Id
+			break
+		}
+	}
+	if vaultId == "" {
+		return "", false, nil
+	}
+
+	var items []opItem
+	if err := c.get(fmt.Sprintf("/v1/vaults/%s/items?filter=%s", vaultId, url.QueryEscape(opFilter("title", itemName))), &items); err != nil {
+		return "", false, err
+	}
+	itemId := ""
+	for _, item := range items {
+		if item.Title == itemName {
+			itemId = item.Id
+			break
+		}
+	}
+	if itemId == "" {
+		return "", false, nil
+	}
+
+	var item opItem
+	if err := c.get(fmt.Sprintf("/v1/vaults/%s/items/%s", vaultId, itemId), &item); err != nil {
+		return "", false, err
+	}
+
+	for _, field := range item.Fields {
+		if field.Label != fieldName && field.Id != fieldName {
+			continue
+		}
+		if sectionName != "" && (field.Section == nil || (field.Section.Label != sectionName && field.Section.Id != sectionName)) {
+			continue
+		}
+		return field.Value, true, nil
+	}
+
+	return "", false, nil
+}
+
+// get sends a GET request for path to the Connect server and decodes the JSON response into result
+func (c *opConnectClient) get(path string, result interface{}) error {
+	request, err := http.NewRequest("GET", strings.TrimSuffix(c.host, "/")+path, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", "Bearer "+c.token)
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	content, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("1Password Connect request %s failed with status %d: %s", path, response.StatusCode, strings.TrimSpace(string(content)))
+	}
+
+	return json.Unmarshal(content, result)
+}
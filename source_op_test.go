@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// stubRunner returns a commandRunner that records the invoked command line and answers with the given output and error
+func stubRunner(commandLine *string, output string, err error) commandRunner {
+	return func(name string, args ...string) ([]byte, error) {
+		*commandLine = strings.Join(append([]string{name}, args...), " ")
+		if err != nil {
+			return nil, err
+		}
+		return []byte(output), nil
+	}
+}
+
+func TestOpSourceReadsReference(t *testing.T) {
+	var commandLine string
+	source := &opSource{refTemplate: "op://Dev/{key}/credential", run: stubRunner(&commandLine, "safesecret", nil)}
+
+	value, found, err := source.Resolve("CLIENT_SECRET")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !found || value != "safesecret" {
+		t.Errorf("Expected value [safesecret] but got [%s] (found: %t)", value, found)
+	}
+
+	expectedCommandLine := "op read --no-newline op://Dev/CLIENT_SECRET/credential"
+	if commandLine != expectedCommandLine {
+		t.Errorf("Expected op to be invoked as [%s] but was [%s]", expectedCommandLine, commandLine)
+	}
+}
+
+func TestOpSourceMissingItem(t *testing.T) {
+	var commandLine string
+	source := &opSource{run: stubRunner(&commandLine, "", errors.New(`exit status 1: [ERROR] "CLIENT_ID" isn't an item in the "Private" vault`))}
+
+	_, found, err := source.Resolve("CLIENT_ID")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if found {
+		t.Errorf("Missing 1Password item should be reported as not found")
+	}
+
+	if !strings.HasPrefix(commandLine, "op read --no-newline op://Private/CLIENT_ID/password") {
+		t.Errorf("Expected the default reference template to be used but command was [%s]", commandLine)
+	}
+}
+
+func TestOpSourceNotInstalled(t *testing.T) {
+	var commandLine string
+	source := &opSource{run: stubRunner(&commandLine, "", fmt.Errorf("exec: \"op\": %w", exec.ErrNotFound))}
+
+	_, _, err := source.Resolve("CLIENT_ID")
+	if err == nil || !strings.Contains(err.Error(), "not found in PATH") {
+		t.Fatalf("Error should mention that op is not installed but was [%v]", err)
+	}
+}
+
+func TestOpSourceNotSignedIn(t *testing.T) {
+	var commandLine string
+	source := &opSource{run: stubRunner(&commandLine, "", errors.New("exit status 1: [ERROR] You are not currently signed in."))}
+
+	_, _, err := source.Resolve("CLIENT_ID")
+	if err == nil || !strings.Contains(err.Error(), "op signin") {
+		t.Fatalf("Error should tell the user to sign in but was [%v]", err)
+	}
+}
+
+func TestOpSourceKeyReferences(t *testing.T) {
+	var commandLine string
+	source := &opSource{refs: map[string]string{"CLIENT_ID": "op://Work/OAuth App/client id"}, run: stubRunner(&commandLine, "safeid", nil)}
+
+	if _, _, err := source.Resolve("CLIENT_ID"); err != nil {
+		t.Fatal(err)
+	}
+
+	if commandLine != "op read --no-newline op://Work/OAuth App/client id" {
+		t.Errorf("Expected the key's own reference to be read but command was [%s]", commandLine)
+	}
+}
+
+func TestOpSourceWithConnect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer connect-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch {
+		case r.URL.Path == "/v1/vaults" && r.URL.Query().Get("filter") == `name eq "Dev"`:
+			w.Write([]byte(`[{"id": "vault1", "name": "Dev"}]`))
+		case r.URL.Path == "/v1/vaults" && r.URL.Query().Get("filter") == `name eq "Dev \"ops\" \\"`:
+			w.Write([]byte(`[{"id": "vault2", "name": "Dev \"ops\" \\"}]`))
+		case r.URL.Path == "/v1/vaults":
+			w.Write([]byte(`[]`))
+		case r.URL.Path == "/v1/vaults/vault1/items" && r.URL.Query().Get("filter") == `title eq "CLIENT_SECRET"`:
+			w.Write([]byte(`[{"id": "item1", "title": "CLIENT_SECRET"}]`))
+		case r.URL.Path == "/v1/vaults/vault2/items" && r.URL.Query().Get("filter") == `title eq "CLIENT_SECRET"`:
+			w.Write([]byte(`[{"id": "item1", "title": "CLIENT_SECRET"}]`))
+		case r.URL.Path == "/v1/vaults/vault1/items" && r.URL.Query().Get("filter") == `title eq "LOOSE"`:
+			// Items whose title isn't the one of the filter aren't read
+			w.Write([]byte(`[{"id": "item1", "title": "LOOSE_MATCH"}]`))
+		case r.URL.Path == "/v1/vaults/vault1/items":
+			w.Write([]byte(`[]`))
+		case r.URL.Path == "/v1/vaults/vault2/items/item1":
+			w.Write([]byte(`{"id": "item1", "fields": [{"id": "f2", "label": "credential", "value": "opssecret"}]}`))
+		case r.URL.Path == "/v1/vaults/vault1/items/item1":
+			w.Write([]byte(`{"id": "item1", "fields": [{"id": "username", "label": "username", "value": "me"}, {"id": "f2", "label": "credential", "value": "safesecret"}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	source := &opSource{refTemplate: "op://Dev/{key}/credential", connect: &opConnectClient{host: server.URL, token: "connect-token", client: http.DefaultClient}}
+
+	value, found, err := source.Resolve("CLIENT_SECRET")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found || value != "safesecret" {
+		t.Errorf("Expected [safesecret] but got [%s] (found: %t)", value, found)
+	}
+
+	if _, found, err := source.Resolve("OTHER"); found || err != nil {
+		t.Errorf("Missing item should be reported as not found but got (found: %t, err: %v)", found, err)
+	}
+	if _, found, err := source.Resolve("LOOSE"); found || err != nil {
+		t.Errorf("Items matching the filter loosely should be reported as not found but got (found: %t, err: %v)", found, err)
+	}
+
+	// Quotes and backslashes of names are escaped in filters
+	quoted := &opSource{refTemplate: `op://Dev "ops" \/{key}/credential`, connect: source.connect}
+	if value, found, err := quoted.Resolve("CLIENT_SECRET"); err != nil || !found || value != "opssecret" {
+		t.Errorf("Expected [opssecret] from the vault with quotes but got [%s] (found: %t, err: %v)", value, found, err)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// defaultPassPathTemplate is the pass entry a key maps to unless configured otherwise
+const defaultPassPathTemplate = "{key}"
+
+// passSource resolves keys from a pass password store (https://www.passwordstore.org), the value being the first line
+// of the entry as is the pass convention
+type passSource struct {
+	// pathTemplate is the path of the entry of a key with {key} standing for the key name and {lowerkey} for the
+	// key name in lower case (e.g. work/{lowerkey} maps STRIPE_KEY to work/stripe_key)
+	pathTemplate string
+	run          commandRunner
+}
+
+func (s *passSource) Resolve(key string) (string, bool, error) {
+	template := s.pathTemplate
+	if template == "" {
+		template = defaultPassPathTemplate
+	}
+	path := strings.NewReplacer("{key}", key, "{lowerkey}", strings.ToLower(key)).Replace(template)
+
+	out, err := s.run("pass", "show", path)
+	if err != nil {
+		switch {
+		case errors.Is(err, exec.ErrNotFound):
+			return "", false, errors.New("pass not found in PATH, see https://www.passwordstore.org")
+		case strings.Contains(err.Error(), "is not in the password store"):
+			return "", false, nil
+		default:
+			return "", false, fmt.Errorf("Error reading [%s] with pass: %s", path, err)
+		}
+	}
+
+	value := strings.SplitN(string(out), "\n", 2)[0]
+	return strings.TrimSuffix(value, "\r"), true, nil
+}
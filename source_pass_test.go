@@ -0,0 +1,35 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPassSource(t *testing.T) {
+	var commandLine string
+	source := &passSource{pathTemplate: "work/{lowerkey}", run: stubRunner(&commandLine, "sk_live_123\nurl: https://stripe.com\n", nil)}
+
+	value, found, err := source.Resolve("STRIPE_KEY")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found || value != "sk_live_123" {
+		t.Errorf("Expected the first line of the entry [sk_live_123] but got [%s] (found: %t)", value, found)
+	}
+	if commandLine != "pass show work/stripe_key" {
+		t.Errorf("Unexpected pass invocation [%s]", commandLine)
+	}
+}
+
+func TestPassSourceMissingEntry(t *testing.T) {
+	var commandLine string
+	source := &passSource{run: stubRunner(&commandLine, "", errors.New("exit status 1: Error: STRIPE_KEY is not in the password store."))}
+
+	_, found, err := source.Resolve("STRIPE_KEY")
+	if err != nil || found {
+		t.Errorf("Missing entry should be reported as not found but got (found: %t, err: %v)", found, err)
+	}
+	if commandLine != "pass show STRIPE_KEY" {
+		t.Errorf("Expected the default path template to be used but command was [%s]", commandLine)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+// loadSecretsFile decrypts the SOPS-encrypted file at path (https://github.com/getsops/sops) and returns its top-level
+// entries as values. The sops CLI does the decryption so age, PGP and cloud KMS keys work as configured in the file.
+func loadSecretsFile(path string, run commandRunner) (safekeeper.MapSource, error) {
+	out, err := run("sops", "--decrypt", "--output-type", "json", path)
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, errors.New("sops not found in PATH, see https://github.com/getsops/sops")
+		}
+		return nil, fmt.Errorf("Error decrypting [%s] with sops: %s", path, err)
+	}
+
+	return decodeSecretValues(path, out)
+}
+
+// decodeSecretValues decodes a JSON object of decrypted secrets. Strings are taken as is and other values are
+// kept in their JSON form.
+func decodeSecretValues(path string, content []byte) (safekeeper.MapSource, error) {
+	var entries map[string]json.RawMessage
+	if err := json.Unmarshal(content, &entries); err != nil {
+		return nil, fmt.Errorf("%s: secrets should be an object of keys: %s", path, err)
+	}
+
+	values := make(safekeeper.MapSource)
+	for key, raw := range entries {
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			value = string(raw)
+		}
+		values[key] = value
+	}
+
+	return values, nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLoadSecretsFile(t *testing.T) {
+	var commandLine string
+	decrypted := `{"CLIENT_ID": "safeid", "MAX_CONNS": 50, "DEBUG": true, "sops": {"version": "3.8.1"}}`
+
+	values, err := loadSecretsFile("secrets.enc.yaml", stubRunner(&commandLine, decrypted, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if commandLine != "sops --decrypt --output-type json secrets.enc.yaml" {
+		t.Errorf("Unexpected sops invocation [%s]", commandLine)
+	}
+
+	expected := map[string]string{"CLIENT_ID": "safeid", "MAX_CONNS": "50", "DEBUG": "true"}
+	for key, value := range expected {
+		if values[key] != value {
+			t.Errorf("Expected [%s] to be [%s] but was [%s]", key, value, values[key])
+		}
+	}
+}
+
+func TestLoadSecretsFileDecryptionFailure(t *testing.T) {
+	var commandLine string
+	_, err := loadSecretsFile("secrets.enc.yaml", stubRunner(&commandLine, "", errors.New("exit status 128: Failed to get the data key required to decrypt the SOPS file.")))
+	if err == nil || !strings.Contains(err.Error(), "secrets.enc.yaml") || !strings.Contains(err.Error(), "data key") {
+		t.Fatalf("Error should mention the file and the sops failure but was [%v]", err)
+	}
+}
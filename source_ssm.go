@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// awsSSMSource resolves keys from AWS Systems Manager Parameter Store with the aws CLI, SecureString parameters
+// being decrypted. With a path prefix (e.g. /myapp/prod/), the parameters under it are read at once and a key is
+// the name of its parameter relative to the prefix, as is or in lower case. Otherwise a key is read from the
+// parameter named after it unless mapped to another name or ARN.
+type awsSSMSource struct {
+	// path is the path prefix of the parameters of the keys
+	path string
+	// names maps keys to the name or ARN of the parameter holding their value
+	names map[string]string
+	run   commandRunner
+
+	once       sync.Once
+	parameters map[string]string
+	err        error
+}
+
+// ssmParameters is the output of aws ssm get-parameters-by-path, once all pages are read by the aws CLI
+type ssmParameters struct {
+	Parameters []struct {
+		Name  string `json:"Name"`
+		Value string `json:"Value"`
+	} `json:"Parameters"`
+}
+
+func (s *awsSSMSource) Resolve(key string) (string, bool, error) {
+	if name, mapped := s.names[key]; mapped || s.path == "" {
+		if !mapped {
+			name = key
+		}
+		return s.getParameter(name)
+	}
+
+	s.once.Do(func() {
+		s.parameters, s.err = s.readPath()
+	})
+	if s.err != nil {
+		return "", false, s.err
+	}
+
+	for _, name := range []string{key, strings.ToLower(key)} {
+		if value, found := s.parameters[name]; found {
+			return value, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// readPath reads the parameters under the path prefix, by name relative to it
+func (s *awsSSMSource) readPath() (map[string]string, error) {
+	path := "/" + strings.Trim(s.path, "/")
+	out, err := s.run("aws", "ssm", "get-parameters-by-path", "--path", path, "--recursive", "--with-decryption", "--output", "json")
+	if err != nil {
+		return nil, ssmError(path, err)
+	}
+
+	var result ssmParameters
+	if err := json.Unmarshal(out, &result); err != nil {
+		return nil, fmt.Errorf("Invalid output of aws ssm get-parameters-by-path for [%s]: %s", path, err)
+	}
+	parameters := make(map[string]string, len(result.Parameters))
+	for _, p := range result.Parameters {
+		parameters[strings.TrimPrefix(p.Name, strings.TrimSuffix(path, "/")+"/")] = p.Value
+	}
+	return parameters, nil
+}
+
+// getParameter returns the decrypted value of the parameter with the given name or ARN
+func (s *awsSSMSource) getParameter(name string) (string, bool, error) {
+	out, err := s.run("aws", "ssm", "get-parameter", "--name", name, "--with-decryption", "--query", "Parameter.Value", "--output", "text")
+	if err != nil {
+		if strings.Contains(err.Error(), "ParameterNotFound") {
+			return "", false, nil
+		}
+		return "", false, ssmError(name, err)
+	}
+
+	return strings.TrimSuffix(string(out), "\n"), true, nil
+}
+
+// ssmError returns the error of a failed aws ssm command reading name
+func ssmError(name string, err error) error {
+	if errors.Is(err, exec.ErrNotFound) {
+		return errors.New("AWS CLI (aws) not found in PATH, see https://aws.amazon.com/cli")
+	}
+	return fmt.Errorf("Error reading AWS SSM parameter [%s]: %s", name, err)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestAWSSSMSourcePath(t *testing.T) {
+	var commandLine string
+	calls := 0
+	run := stubRunner(&commandLine, `{"Parameters": [{"Name": "/myapp/prod/db_password", "Value": "s3cr3t", "Type": "SecureString"}, {"Name": "/myapp/prod/API_URL", "Value": "https://api", "Type": "String"}]}`, nil)
+	source := &awsSSMSource{path: "/myapp/prod/", run: func(name string, args ...string) ([]byte, error) {
+		calls++
+		return run(name, args...)
+	}}
+
+	for key, expected := range map[string]string{"DB_PASSWORD": "s3cr3t", "API_URL": "https://api"} {
+		value, found, err := source.Resolve(key)
+		if err != nil || !found || value != expected {
+			t.Errorf("Expected [%s] to be [%s] but got [%s] (found: %t, err: %v)", key, expected, value, found, err)
+		}
+	}
+	if _, found, _ := source.Resolve("MISSING"); found {
+		t.Error("Expected a key without a parameter to be missing")
+	}
+	if calls != 1 {
+		t.Errorf("Expected the parameters of the path to be read once but they were read %d times", calls)
+	}
+	if commandLine != "aws ssm get-parameters-by-path --path /myapp/prod --recursive --with-decryption --output json" {
+		t.Errorf("Unexpected aws invocation [%s]", commandLine)
+	}
+}
+
+func TestAWSSSMSourceParameters(t *testing.T) {
+	var commandLine string
+	source := &awsSSMSource{names: map[string]string{"DB_PASSWORD": "/shared/db"}, run: stubRunner(&commandLine, "s3cr3t\n", nil)}
+
+	value, found, err := source.Resolve("DB_PASSWORD")
+	if err != nil || !found || value != "s3cr3t" {
+		t.Errorf("Expected [s3cr3t] but got [%s] (found: %t, err: %v)", value, found, err)
+	}
+	if !strings.Contains(commandLine, "get-parameter --name /shared/db --with-decryption") {
+		t.Errorf("Expected the mapped parameter to be read with decryption but command was [%s]", commandLine)
+	}
+
+	missing := &awsSSMSource{run: stubRunner(&commandLine, "", errors.New("exit status 254: An error occurred (ParameterNotFound) when calling the GetParameter operation"))}
+	if _, found, err := missing.Resolve("API_KEY"); err != nil || found {
+		t.Errorf("Missing parameter should be reported as not found but got (found: %t, err: %v)", found, err)
+	}
+}
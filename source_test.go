@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+// countingSource is a SecretSource that counts resolutions per key
+type countingSource struct {
+	mu      sync.Mutex
+	lookups map[string]int
+	calls   int32
+}
+
+func (s *countingSource) Resolve(key string) (string, bool, error) {
+	atomic.AddInt32(&s.calls, 1)
+	s.mu.Lock()
+	s.lookups[key]++
+	s.mu.Unlock()
+	// Give concurrent callers a chance to pile up on the same key
+	time.Sleep(10 * time.Millisecond)
+	return "value-" + key, true, nil
+}
+
+func TestCachingSourceLooksUpEachKeyOnce(t *testing.T) {
+	counting := &countingSource{lookups: make(map[string]int)}
+	source := newCachingSource(counting)
+	keys := []string{"CLIENT_ID", "CLIENT_SECRET", "API_TOKEN"}
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < 20; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, key := range keys {
+				value, found, err := source.Resolve(key)
+				if err != nil || !found || value != "value-"+key {
+					t.Errorf("Unexpected lookup result for [%s]: [%s], %t, %v", key, value, found, err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, key := range keys {
+		if counting.lookups[key] != 1 {
+			t.Errorf("Expected exactly one lookup of [%s] but got %d", key, counting.lookups[key])
+		}
+	}
+	if int(counting.calls) != len(keys) {
+		t.Errorf("Expected %d lookups in total but got %d", len(keys), counting.calls)
+	}
+}
+
+func TestCachingSourceCachesErrors(t *testing.T) {
+	calls := 0
+	source := newCachingSource(resolveFunc(func(key string) (string, bool, error) {
+		calls++
+		return "", false, fmt.Errorf("backend down")
+	}))
+
+	for i := 0; i < 3; i++ {
+		if _, _, err := source.Resolve("CLIENT_ID"); err == nil {
+			t.Fatal("Expected the source error to be returned")
+		}
+	}
+
+	if calls != 1 {
+		t.Errorf("Expected a failed lookup to be cached but the source was called %d times", calls)
+	}
+}
+
+// resolveFunc adapts a function to the SecretSource interface
+type resolveFunc func(key string) (string, bool, error)
+
+func (f resolveFunc) Resolve(key string) (string, bool, error) {
+	return f(key)
+}
+
+func TestSourceSpecsChain(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	first := filepath.Join(tempDir, "first.env")
+	second := filepath.Join(tempDir, "second.env")
+	if err := ioutil.WriteFile(first, []byte("SAFEKEEPER_TEST_A=first\nSAFEKEEPER_TEST_B=first\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(second, []byte("SAFEKEEPER_TEST_A=second\nSAFEKEEPER_TEST_C=second\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("SAFEKEEPER_TEST_B", "env")
+	defer os.Unsetenv("SAFEKEEPER_TEST_B")
+
+	source, err := newSecretSource(options{sources: []string{"envfile:" + first, "env", "envfile:" + second}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{"SAFEKEEPER_TEST_A": "first", "SAFEKEEPER_TEST_B": "first", "SAFEKEEPER_TEST_C": "second"}
+	for key, value := range expected {
+		resolved, found, err := source.Resolve(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !found || resolved != value {
+			t.Errorf("Expected [%s] to be [%s] from the first source having it but was [%s] (found: %t)", key, value, resolved, found)
+		}
+	}
+}
+
+func TestKeyMap(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	os.Setenv("SERVICE_FOO_API_KEY", "s3cr3t")
+	defer os.Unsetenv("SERVICE_FOO_API_KEY")
+
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "secrets.go"+templateExt), []byte("package secrets\n\nconst apiKey = \"ENV_API_KEY\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	generated := filepath.Join(tempDir, "secrets.go")
+
+	if err := run(options{keys: "API_KEY", paths: []string{generated}, keyMap: map[string]string{"API_KEY": "SERVICE_FOO_API_KEY"}}); err != nil {
+		t.Fatal(err)
+	}
+	if output, _ := ioutil.ReadFile(generated); !strings.Contains(string(output), "const apiKey = \"s3cr3t\"") {
+		t.Errorf("Expected API_KEY to be resolved as SERVICE_FOO_API_KEY but got [%s]", string(output))
+	}
+
+	err = run(options{keys: "API_KEY", paths: []string{generated}, keyMap: map[string]string{"API_KEY": "SERVICE_BAR_API_KEY"}})
+	if err == nil || !strings.Contains(err.Error(), "[SERVICE_BAR_API_KEY]") {
+		t.Errorf("Expected the missing value to be reported under its mapped name but got [%v]", err)
+	}
+}
+
+func TestConfigValuesOverrideSources(t *testing.T) {
+	os.Setenv("SAFEKEEPER_TEST_URL", "https://env.example.com")
+	defer os.Unsetenv("SAFEKEEPER_TEST_URL")
+
+	source, err := newSecretSource(options{values: map[string]string{"SAFEKEEPER_TEST_URL": "https://config.example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, found, err := source.Resolve("SAFEKEEPER_TEST_URL"); err != nil || !found || value != "https://config.example.com" {
+		t.Errorf("Expected the value of the config file to win but got [%s] (found: %t, err: %v)", value, found, err)
+	}
+	if !isEnvSource(source) {
+		t.Errorf("Expected the keys without a value in the config file to come from the environment")
+	}
+}
+
+func TestKeySources(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	flags := filepath.Join(tempDir, "flags.env")
+	if err := ioutil.WriteFile(flags, []byte("FEATURE_FLAGS=beta\nSENTRY_DSN=https://flags.example.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	env := safekeeper.LookupMap(map[string]string{"SENTRY_DSN": "https://env.example.com", "FEATURE_FLAGS": "none"})
+	opts := options{lookupEnv: env, sources: []string{"envfile:" + flags}, keySources: map[string]string{"SENTRY_DSN": "env", "FEATURE_FLAGS": "envfile:" + flags, "REGION": "env"}}
+	source, err := newSecretSource(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, expected := range map[string]string{"SENTRY_DSN": "https://env.example.com", "FEATURE_FLAGS": "beta"} {
+		if value, found, err := source.Resolve(key); err != nil || !found || value != expected {
+			t.Errorf("Expected %s to be resolved by its own source as [%s] but got [%s] (found: %t, err: %v)", key, expected, value, found, err)
+		}
+	}
+
+	var stdout bytes.Buffer
+	opts.stdout = &stdout
+	if err := explain(opts, []string{"SENTRY_DSN", "REGION"}); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "SENTRY_DSN: env\nREGION: not found in env\n"; stdout.String() != expected {
+		t.Errorf("Expected routed keys to only be looked up in their source [%s] but got [%s]", expected, stdout.String())
+	}
+
+	_, err = newSecretSource(options{keySources: map[string]string{"DB_PASSWORD": "nope"}})
+	if err == nil || !strings.Contains(err.Error(), "source of key [DB_PASSWORD]: Unknown source [nope]") {
+		t.Errorf("Expected an invalid source of a key to be reported but got [%v]", err)
+	}
+}
+
+func TestInvalidSourceSpecs(t *testing.T) {
+	for spec, message := range map[string]string{"nope": "Unknown source [nope]", "envfile": "needs a path", "doppler:myapp": "expected project/config"} {
+		_, err := newSecretSource(options{sources: []string{spec}})
+		if err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("Expected an error containing [%s] for source [%s] but got [%v]", message, spec, err)
+		}
+	}
+}
+
+func TestPrefixMap(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	os.Setenv("SAFEKEEPER_TEST_FEATURE_SEARCH", "on")
+	defer os.Unsetenv("SAFEKEEPER_TEST_FEATURE_SEARCH")
+	os.Setenv("SAFEKEEPER_TEST_FEATURE_CHAT", "off,beta")
+	defer os.Unsetenv("SAFEKEEPER_TEST_FEATURE_CHAT")
+
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "features.go"+templateExt), []byte("package features\n\nvar flags = \"ENV_FEATURES\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	generated := filepath.Join(tempDir, "features.go")
+
+	if err := run(options{paths: []string{generated}, prefixMaps: map[string]string{"FEATURES": "SAFEKEEPER_TEST_FEATURE_"}}); err != nil {
+		t.Fatal(err)
+	}
+	expected := "var flags = map[string]string{\"SAFEKEEPER_TEST_FEATURE_CHAT\": \"off,beta\", \"SAFEKEEPER_TEST_FEATURE_SEARCH\": \"on\"}"
+	if output, _ := ioutil.ReadFile(generated); !strings.Contains(string(output), expected) {
+		t.Errorf("Expected the variables of the prefix to be gathered in a map but got [%s]", string(output))
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// vaultSource resolves keys from the fields of a HashiCorp Vault secret (https://www.vaultproject.io), read with the
+// HTTP API. It authenticates with VAULT_TOKEN or, if not set, with AppRole using VAULT_ROLE_ID and VAULT_SECRET_ID.
+type vaultSource struct {
+	addr   string
+	path   string
+	client *http.Client
+	getenv func(string) string
+
+	once   sync.Once
+	fields map[string]string
+	err    error
+}
+
+func newVaultSource(addr string, path string) *vaultSource {
+	return &vaultSource{addr: addr, path: path, client: http.DefaultClient, getenv: os.Getenv}
+}
+
+func (s *vaultSource) Resolve(key string) (string, bool, error) {
+	s.once.Do(func() {
+		s.fields, s.err = s.readSecret()
+	})
+	if s.err != nil {
+		return "", false, s.err
+	}
+
+	value, found := s.fields[key]
+	return value, found, nil
+}
+
+// readSecret reads the secret at the configured path and returns its fields. Both KV version 1 and 2 secrets are
+// supported.
+func (s *vaultSource) readSecret() (map[string]string, error) {
+	if s.addr == "" {
+		return nil, errors.New("Vault address not set, use --vault-addr or VAULT_ADDR")
+	}
+	if s.path == "" {
+		return nil, errors.New("Vault secret path not set, use --vault-path")
+	}
+
+	token, err := s.token()
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := s.do("GET", s.path, token, nil, &response); err != nil {
+		return nil, err
+	}
+
+	data := response.Data
+	// KV version 2 secrets nest the fields under data.data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, versioned := data["metadata"]; versioned {
+			data = nested
+		}
+	}
+
+	fields := make(map[string]string)
+	for name, value := range data {
+		switch v := value.(type) {
+		case string:
+			fields[name] = v
+		case nil:
+		default:
+			encoded, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			fields[name] = string(encoded)
+		}
+	}
+
+	return fields, nil
+}
+
+// token returns the Vault token to authenticate with
+func (s *vaultSource) token() (string, error) {
+	if token := s.getenv("VAULT_TOKEN"); token != "" {
+		return token, nil
+	}
+
+	roleId, secretId := s.getenv("VAULT_ROLE_ID"), s.getenv("VAULT_SECRET_ID")
+	if roleId == "" || secretId == "" {
+		return "", errors.New("No Vault credentials, set VAULT_TOKEN or VAULT_ROLE_ID and VAULT_SECRET_ID")
+	}
+
+	body, err := json.Marshal(map[string]string{"role_id": roleId, "secret_id": secretId})
+	if err != nil {
+		return "", err
+	}
+
+	var response struct {
+		Auth struct {
+			ClientToken string `json:"client_token"`
+		} `json:"auth"`
+	}
+	if err := s.do("POST", "auth/approle/login", "", body, &response); err != nil {
+		return "", err
+	}
+
+	return response.Auth.ClientToken, nil
+}
+
+// do sends a request to the Vault API for path and decodes the JSON response into result
+func (s *vaultSource) do(method string, path string, token string, body []byte, result interface{}) error {
+	url := fmt.Sprintf("%s/v1/%s", strings.TrimSuffix(s.addr, "/"), strings.TrimPrefix(path, "/"))
+	request, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		request.Header.Set("X-Vault-Token", token)
+	}
+	if namespace := s.getenv("VAULT_NAMESPACE"); namespace != "" {
+		request.Header.Set("X-Vault-Namespace", namespace)
+	}
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	content, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("Vault request %s %s failed with status %d: %s", method, path, response.StatusCode, strings.TrimSpace(string(content)))
+	}
+
+	return json.Unmarshal(content, result)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeEnv returns a getenv function reading from env
+func fakeEnv(env map[string]string) func(string) string {
+	return func(key string) string {
+		return env[key]
+	}
+}
+
+func newVaultTestServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/approle/login":
+			var credentials map[string]string
+			json.NewDecoder(r.Body).Decode(&credentials)
+			if credentials["role_id"] != "role" || credentials["secret_id"] != "secret" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"auth": {"client_token": "approle-token"}}`))
+		case "/v1/secret/data/myapp":
+			if token := r.Header.Get("X-Vault-Token"); token != "root-token" && token != "approle-token" {
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(`{"errors": ["permission denied"]}`))
+				return
+			}
+			w.Write([]byte(`{"data": {"data": {"CLIENT_ID": "safeid", "CLIENT_SECRET": "safesecret"}, "metadata": {"version": 3}}}`))
+		case "/v1/kv/myapp":
+			w.Write([]byte(`{"data": {"CLIENT_ID": "kv1id"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestVaultSourceWithToken(t *testing.T) {
+	server := newVaultTestServer(t)
+	defer server.Close()
+
+	source := newVaultSource(server.URL, "secret/data/myapp")
+	source.getenv = fakeEnv(map[string]string{"VAULT_TOKEN": "root-token"})
+
+	value, found, err := source.Resolve("CLIENT_SECRET")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found || value != "safesecret" {
+		t.Errorf("Expected [safesecret] but got [%s] (found: %t)", value, found)
+	}
+
+	if _, found, _ := source.Resolve("OTHER"); found {
+		t.Errorf("Key absent from the secret should not be found")
+	}
+}
+
+func TestVaultSourceWithAppRole(t *testing.T) {
+	server := newVaultTestServer(t)
+	defer server.Close()
+
+	source := newVaultSource(server.URL, "secret/data/myapp")
+	source.getenv = fakeEnv(map[string]string{"VAULT_ROLE_ID": "role", "VAULT_SECRET_ID": "secret"})
+
+	value, found, err := source.Resolve("CLIENT_ID")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found || value != "safeid" {
+		t.Errorf("Expected [safeid] but got [%s] (found: %t)", value, found)
+	}
+}
+
+func TestVaultSourceKVv1(t *testing.T) {
+	server := newVaultTestServer(t)
+	defer server.Close()
+
+	source := newVaultSource(server.URL, "kv/myapp")
+	source.getenv = fakeEnv(map[string]string{"VAULT_TOKEN": "root-token"})
+
+	value, _, err := source.Resolve("CLIENT_ID")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != "kv1id" {
+		t.Errorf("Expected [kv1id] but got [%s]", value)
+	}
+}
+
+func TestVaultSourcePermissionDenied(t *testing.T) {
+	server := newVaultTestServer(t)
+	defer server.Close()
+
+	source := newVaultSource(server.URL, "secret/data/myapp")
+	source.getenv = fakeEnv(map[string]string{"VAULT_TOKEN": "bad-token"})
+
+	_, _, err := source.Resolve("CLIENT_ID")
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("Expected a permission error but got [%v]", err)
+	}
+}
+
+func TestVaultSourceWithoutCredentials(t *testing.T) {
+	source := newVaultSource("http://127.0.0.1:8200", "secret/data/myapp")
+	source.getenv = fakeEnv(nil)
+
+	_, _, err := source.Resolve("CLIENT_ID")
+	if err == nil || !strings.Contains(err.Error(), "VAULT_TOKEN") {
+		t.Fatalf("Expected an error about missing credentials but got [%v]", err)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"unicode/utf8"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+// streamMinSize is the size of the templates from which generated files are streamed to their output rather than
+// generated in memory, so that large assets aren't held twice
+var streamMinSize int64 = 8 << 20
+
+// streamable reports whether the file generated from the template of path to out can be streamed: the template is
+// large and no setting of g needs the whole generated source (checks, diffs, formatting, normalized line endings,
+// fingerprints, records, backups or reports)
+func (g *generation) streamable(path string, out string) bool {
+	if path == stdio || out == stdio || g.outputs != nil {
+		return false
+	}
+	if g.check || g.dryRun || g.format || g.validate || g.strict || g.fingerprint || g.recordFiles || g.backup || g.report != nil {
+		return false
+	}
+	if g.lineEndings == lineEndingsLF || g.lineEndings == lineEndingsCRLF {
+		return false
+	}
+	info, err := fs.Stat(g.finder.filesystem(), g.finder.ext.templateOf(path))
+	return err == nil && info.Size() >= streamMinSize
+}
+
+// streamFile generates the file of path, starting with header, and streams it to a temporary file next to out
+// renamed over out once complete, like writeFileAtomic. The template is read line by line as it's generated, and
+// the content hash of the header is filled in last.
+func (g *generation) streamFile(path string, out string, header []byte) (err error) {
+	file, err := openTemplateFile(g.finder.filesystem(), path, g.finder.ext, g.stdin)
+	if os.IsNotExist(err) {
+		return withExitCode(exitTemplateNotFound, err)
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	// The first line of the template tells its byte order mark and line endings, needed to write the header
+	template := &templateLines{ext: g.finder.ext, path: path, r: bufio.NewReader(file)}
+	if err := template.next(); err != nil && err != io.EOF {
+		return err
+	}
+	first := template.pending
+	template.pending = bytes.TrimPrefix(first, utf8BOM)
+
+	if !g.force {
+		if err := checkNotEdited(out); err != nil {
+			return err
+		}
+	}
+	header = g.withBOM(g.withLineEndings(header, len(header), first), first)
+	reserved, offset, hashed := safekeeper.ReserveContentHash(header)
+
+	temp, err := ioutil.TempFile(filepath.Dir(out), "."+filepath.Base(out)+".*.tmp")
+	if err != nil {
+		return withExitCode(exitWriteFailure, err)
+	}
+	defer func() {
+		if err != nil {
+			temp.Close()
+			os.Remove(temp.Name())
+		}
+	}()
+
+	hash := sha256.New()
+	buffered := bufio.NewWriter(temp)
+	hash.Write(header)
+	if _, err := buffered.Write(reserved); err != nil {
+		return withExitCode(exitWriteFailure, err)
+	}
+	generator := g.fileGenerator(path, out)
+	if err := generator.GenerateContext(g.ctx, template, io.MultiWriter(buffered, hash), safekeeper.Reveal(g.keyValues)); err != nil {
+		if ctxErr := contextErr(g.ctx); ctxErr != nil {
+			return ctxErr
+		}
+		return err
+	}
+	if err := buffered.Flush(); err != nil {
+		return withExitCode(exitWriteFailure, err)
+	}
+	if hashed {
+		if _, err := temp.WriteAt([]byte(hex.EncodeToString(hash.Sum(nil))), int64(offset)); err != nil {
+			return withExitCode(exitWriteFailure, err)
+		}
+	}
+
+	if err := temp.Chmod(g.outputMode(path)); err != nil {
+		return withExitCode(exitWriteFailure, err)
+	}
+	if err := temp.Sync(); err != nil {
+		return withExitCode(exitWriteFailure, err)
+	}
+	if err := temp.Close(); err != nil {
+		return withExitCode(exitWriteFailure, err)
+	}
+	return withExitCode(exitWriteFailure, os.Rename(temp.Name(), out))
+}
+
+// templateLines reads the template of path from r a line at a time, failing on the first line that isn't valid
+// UTF-8 or that shows the template is a generated file, as templateText and checkTemplateNotGenerated do for
+// templates read whole
+type templateLines struct {
+	ext     templateExtension
+	path    string
+	r       *bufio.Reader
+	line    int
+	pending []byte
+	err     error
+}
+
+func (t *templateLines) Read(p []byte) (int, error) {
+	for len(t.pending) == 0 {
+		if t.err != nil {
+			return 0, t.err
+		}
+		t.err = t.next()
+	}
+	n := copy(p, t.pending)
+	t.pending = t.pending[n:]
+	return n, nil
+}
+
+// next reads the next line of the template to pending, failing with io.EOF at the end of the template
+func (t *templateLines) next() error {
+	line, err := t.r.ReadBytes('\n')
+	if err != nil && (err != io.EOF || len(line) == 0) {
+		return err
+	}
+	t.line++
+	if !utf8.Valid(line) {
+		return invalidUTF8Error(t.path, t.line)
+	}
+	if err := checkTemplateNotGenerated(t.ext, t.path, line); err != nil {
+		return err
+	}
+	t.pending = line
+	return nil
+}
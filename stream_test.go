@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+func TestStreamedGeneration(t *testing.T) {
+	for name, template := range map[string]string{
+		"config.go":   "package config\n\nconst id = \"ENV_CLIENT_ID\"\n",
+		"crlf.go":     "package config\r\n\r\nconst id = \"ENV_CLIENT_ID\"\r\n",
+		"config.yaml": "\ufeffclient: ENV_CLIENT_ID\n",
+	} {
+		lang := ""
+		if filepath.Ext(name) == ".yaml" {
+			lang = langText
+		}
+
+		generated := make([][]byte, 2)
+		for i, minSize := range []int64{streamMinSize, 0} {
+			dir, err := ioutil.TempDir("", "safekeeper-stream")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			if err := ioutil.WriteFile(filepath.Join(dir, name+templateExt), []byte(template), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			streamMinSize, minSize = minSize, streamMinSize
+			os.Setenv("CLIENT_ID", "id")
+			err = run(options{paths: []string{filepath.Join(dir, name)}, lang: lang, stdout: ioutil.Discard})
+			os.Unsetenv("CLIENT_ID")
+			streamMinSize = minSize
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if generated[i], err = ioutil.ReadFile(filepath.Join(dir, name)); err != nil {
+				t.Fatal(err)
+			}
+			if info, err := os.Stat(filepath.Join(dir, name)); err != nil || info.Mode().Perm() != 0600 {
+				t.Errorf("Expected [%s] to have the mode of its template (%v)", name, err)
+			}
+		}
+
+		if !bytes.Equal(generated[0], generated[1]) {
+			t.Errorf("Expected the streamed [%s] to be the same as the generated one but got [%q] instead of [%q]", name, generated[1], generated[0])
+		}
+		if hashed, intact := safekeeper.CheckContentHash(generated[1]); !hashed || !intact {
+			t.Errorf("Expected the streamed [%s] to have a valid content hash", name)
+		}
+	}
+}
+
+// drainGuardFS opens its files with drainGuardFile
+type drainGuardFS struct {
+	safekeeper.MapFS
+	// output is the glob of the temporary files the output is streamed to
+	output string
+}
+
+func (fsys drainGuardFS) Open(name string) (fs.File, error) {
+	file, err := fsys.MapFS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &drainGuardFile{File: file, output: fsys.output}, nil
+}
+
+// drainGuardFile fails when read to its end before anything is written to the output
+type drainGuardFile struct {
+	fs.File
+	output string
+}
+
+func (f *drainGuardFile) Read(p []byte) (int, error) {
+	n, err := f.File.Read(p)
+	if err != io.EOF {
+		return n, err
+	}
+	temps, _ := filepath.Glob(f.output)
+	for _, temp := range temps {
+		if info, statErr := os.Stat(temp); statErr == nil && info.Size() > 0 {
+			return n, err
+		}
+	}
+	return n, errors.New("template read whole before the output started")
+}
+
+func TestStreamedTemplateReadAsGenerated(t *testing.T) {
+	dir, err := ioutil.TempDir("", "safekeeper-stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	template := "package config\n\n" + strings.Repeat("const id = \"ENV_CLIENT_ID\"\n", 10000)
+	out := filepath.Join(dir, "config.go")
+	files := drainGuardFS{
+		MapFS:  safekeeper.MapFS{"config.go" + templateExt: &fstest.MapFile{Data: []byte(template), Mode: 0600}},
+		output: filepath.Join(dir, ".config.go.*.tmp"),
+	}
+	g := &generation{
+		keyValues: safekeeper.Secrets(map[string]string{"CLIENT_ID": "id"}),
+		finder:    templateFinder{fsys: files},
+		ctx:       context.Background(),
+	}
+
+	if err := g.streamFile("config.go", out, nil); err != nil {
+		t.Fatal(err)
+	}
+	generated, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := strings.Replace(template, "ENV_CLIENT_ID", "id", -1); string(generated) != expected {
+		t.Errorf("Expected the streamed file to be generated from the whole template but got %d bytes instead of %d", len(generated), len(expected))
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import "sort"
+
+// suggestKey returns the key of known that key is most likely a typo of, e.g. TOKEN for TOEKN: the closest one by
+// edit distance, as long as it's at most a third of the length of key (and at least 1). Ties go to the first key in
+// order. It returns false if no key is that close.
+func suggestKey(key string, known []string) (string, bool) {
+	known = append([]string{}, known...)
+	sort.Strings(known)
+	maxDistance := len(key) / 3
+	if maxDistance < 1 {
+		maxDistance = 1
+	}
+
+	var suggestion string
+	best := maxDistance + 1
+	for _, candidate := range known {
+		if candidate == key {
+			continue
+		}
+		if distance := editDistance(key, candidate); distance < best {
+			suggestion, best = candidate, distance
+		}
+	}
+	return suggestion, suggestion != ""
+}
+
+// editDistance returns the edit distance between a and b: the number of insertions, deletions and substitutions
+// of characters and transpositions of adjacent ones turning one into the other (the optimal string alignment
+// distance, a transposition being the most common typo)
+func editDistance(a string, b string) int {
+	s, t := []rune(a), []rune(b)
+	d := make([][]int, len(s)+1)
+	for i := range d {
+		d[i] = make([]int, len(t)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			d[i][j] = d[i-1][j-1] + cost
+			if d[i-1][j]+1 < d[i][j] {
+				d[i][j] = d[i-1][j] + 1
+			}
+			if d[i][j-1]+1 < d[i][j] {
+				d[i][j] = d[i][j-1] + 1
+			}
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] && d[i-2][j-2]+1 < d[i][j] {
+				d[i][j] = d[i-2][j-2] + 1
+			}
+		}
+	}
+	return d[len(s)][len(t)]
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSuggestKey(t *testing.T) {
+	known := []string{"TOKEN", "CLIENT_ID", "CLIENT_SECRET"}
+	cases := map[string]string{
+		"TOEKN":        "TOKEN",
+		"TOKN":         "TOKEN",
+		"CLIENT_SECET": "CLIENT_SECRET",
+		"CLIENT_IDS":   "CLIENT_ID",
+		"REGION":       "",
+		"DATABASE_URL": "",
+		"TOKEN":        "",
+	}
+	for key, expected := range cases {
+		suggestion, ok := suggestKey(key, known)
+		if suggestion != expected || ok != (expected != "") {
+			t.Errorf("Expected [%s] as suggestion for [%s] but got [%s] (%t)", expected, key, suggestion, ok)
+		}
+	}
+	if distance := editDistance("kitten", "sitting"); distance != 3 {
+		t.Errorf("Expected an edit distance of 3 but got %d", distance)
+	}
+	if distance := editDistance("TOEKN", "TOKEN"); distance != 1 {
+		t.Errorf("Expected a transposition to be a single edit but got %d", distance)
+	}
+}
+
+func TestStrictSuggestions(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "suggest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	template := "package secrets\n\nconst token = \"ENV_TOEKN\"\nconst region = \"ENV_REGION\"\n"
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "secrets.go"+templateExt), []byte(template), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("TOKEN", "s3cr3t")
+	defer os.Unsetenv("TOKEN")
+
+	err = run(options{keys: "TOKEN", paths: []string{tempDir}, strict: true, quiet: true})
+	templatePath := filepath.Join(tempDir, "secrets.go"+templateExt)
+	if err == nil || !strings.Contains(err.Error(), "[TOEKN] at "+templatePath+":3 (did you mean [TOKEN]?)") {
+		t.Errorf("Expected a suggestion for the typo but got [%v]", err)
+	}
+	if err == nil || !strings.HasSuffix(err.Error(), "[REGION] at "+templatePath+":4") {
+		t.Errorf("Expected no suggestion for an unrelated key but got [%v]", err)
+	}
+}
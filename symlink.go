@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// walkLink handles the symbolic link at path met by a walk at depth, info being the one of what it points to.
+// Unless t follows links, it's skipped with a warning when it points to a directory or a template, a link to
+// anything else being irrelevant. Otherwise, the directory it points to is walked as if it were at path, unless
+// it's one of the walked directories or contains one, which would loop.
+func (t templateFinder) walkLink(path string, info os.FileInfo, depth int, walked []string, exclusions []exclusion, fn filepath.WalkFunc) error {
+	if !t.followSymlinks {
+		if info.IsDir() || t.ext.isTemplate(info.Name()) {
+			t.warnings.warnf(path, "Skipping symbolic link [%s], use --follow-symlinks to walk it", path)
+		}
+		return nil
+	}
+	if !info.IsDir() {
+		return fn(path, info, nil)
+	}
+
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+	for _, dir := range walked {
+		if within(dir, target) {
+			t.warnings.warnf(path, "Skipping symbolic link [%s] to [%s], which is already being walked", path, target)
+			return nil
+		}
+	}
+	return t.walkTree(path, target, depth, append(walked[:len(walked):len(walked)], target), append([]exclusion(nil), exclusions...), fn)
+}
+
+// within reports whether path is dir or under it, both being real paths
+func within(path string, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// renamedFileInfo is the info of the file a symbolic link points to, named after the link
+type renamedFileInfo struct {
+	os.FileInfo
+	name string
+}
+
+func (info renamedFileInfo) Name() string {
+	return info.name
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSymlinks(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "symlinks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	templatesDir := filepath.Join(tempDir, "templates")
+	sharedDir := filepath.Join(tempDir, "shared", "db")
+	for _, dir := range []string{templatesDir, sharedDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := writeTestTemplate(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(tempDir, "shared"), filepath.Join(templatesDir, "linked")); err != nil {
+		t.Skipf("Symbolic links aren't supported: %s", err)
+	}
+	if err := os.Symlink(templatesDir, filepath.Join(templatesDir, "loop")); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("CLIENT_ID", "safeid")
+	os.Setenv("CLIENT_SECRET", "safesecret")
+	defer os.Unsetenv("CLIENT_ID")
+	defer os.Unsetenv("CLIENT_SECRET")
+
+	var stderr bytes.Buffer
+	if err := run(options{paths: []string{templatesDir}, stderr: &stderr}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(sharedDir, "secrets.go")); !os.IsNotExist(err) {
+		t.Error("Expected the templates of linked directories not to be generated by default")
+	}
+	for _, link := range []string{"linked", "loop"} {
+		warning := "Skipping symbolic link [" + filepath.Join(templatesDir, link) + "], use --follow-symlinks"
+		if strings.Count(stderr.String(), warning) != 1 {
+			t.Errorf("Expected a single warning [%s] but got:\n%s", warning, stderr.String())
+		}
+	}
+
+	stderr.Reset()
+	if err := run(options{paths: []string{templatesDir}, followSymlinks: true, stderr: &stderr}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(sharedDir, "secrets.go")); err != nil {
+		t.Errorf("Expected the templates of linked directories to be generated with --follow-symlinks but got [%s]", err)
+	}
+	if !strings.Contains(stderr.String(), "Skipping symbolic link ["+filepath.Join(templatesDir, "loop")+"] to") {
+		t.Errorf("Expected the link looping back to the walked directory to be skipped but got:\n%s", stderr.String())
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+// errTimeout is the error of the commands interrupted by --timeout
+var errTimeout = withExitCode(exitTimeout, errors.New("Timed out before completing, see --timeout"))
+
+// withContext returns opts with the context of a command, done once the timeout of opts elapses, and the
+// function releasing it. Commands resolving values or walking directories call it once, so that watch gives
+// each generation its own timeout.
+func (opts options) withContext() (options, context.CancelFunc) {
+	var cancel context.CancelFunc
+	if opts.timeout > 0 {
+		opts.ctx, cancel = context.WithTimeout(opts.context(), opts.timeout)
+	} else {
+		opts.ctx, cancel = context.WithCancel(opts.context())
+	}
+	return opts, cancel
+}
+
+// context returns the context of opts, context.Background() if it has none
+func (opts options) context() context.Context {
+	if opts.ctx == nil {
+		return context.Background()
+	}
+	return opts.ctx
+}
+
+// contextErr returns errTimeout once ctx is past its deadline, the error of ctx if it's otherwise done and nil
+// if ctx is nil or isn't done
+func contextErr(ctx context.Context) error {
+	if ctx == nil {
+		return nil
+	}
+	switch err := ctx.Err(); err {
+	case nil:
+		return nil
+	case context.DeadlineExceeded:
+		return errTimeout
+	default:
+		return err
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// hungSource is a SecretSource that never answers, like a backend that's down
+type hungSource struct{}
+
+func (hungSource) Resolve(key string) (string, bool, error) {
+	select {}
+}
+
+func TestTimeoutResolving(t *testing.T) {
+	opts, cancel := options{timeout: 10 * time.Millisecond}.withContext()
+	defer cancel()
+
+	source := newCachingSource(layeredSource{layers: []namedSource{{name: "hung", source: hungSource{}}}})
+	_, err := loadKeyValues(opts.context(), []string{"CLIENT_ID"}, source, nil, nil)
+	if exitCode(err) != exitTimeout {
+		t.Errorf("Expected a timeout but got [%v]", err)
+	}
+	if len(source.results) != 0 {
+		t.Errorf("Expected the timed out resolution to be left out of the cache but got %d results", len(source.results))
+	}
+}
+
+func TestTimeoutWalking(t *testing.T) {
+	dir, err := ioutil.TempDir("", "safekeeper-timeout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "config.go.safekeeper"), []byte("package config\n\nconst id = \"ENV_CLIENT_ID\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now())
+	defer cancel()
+	opts := options{ctx: ctx}
+	if _, err := opts.finder().outputsOf([]string{dir}); exitCode(err) != exitTimeout {
+		t.Errorf("Expected the walk to time out but got [%v]", err)
+	}
+
+	opts = options{paths: []string{dir}, timeout: time.Hour, stdout: ioutil.Discard}
+	os.Setenv("CLIENT_ID", "id")
+	defer os.Unsetenv("CLIENT_ID")
+	if err := run(opts); err != nil {
+		t.Errorf("Expected the run to complete within its timeout but got [%v]", err)
+	}
+}
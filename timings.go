@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// slowestFiles is the number of the slowest files named by the summary of a run
+const slowestFiles = 3
+
+// phaseTimings are the durations of the phases of a run in the report: resolving the values of the keys with the
+// sources, then substituting them in the templates and writing the generated files, the last two summed over the
+// files generated concurrently. They're in fractional milliseconds since substituting and writing a file usually
+// take less than one.
+type phaseTimings struct {
+	ResolutionMs   float64 `json:"resolution_ms"`
+	SubstitutionMs float64 `json:"substitution_ms"`
+	WritingMs      float64 `json:"writing_ms"`
+}
+
+// runTimings are the durations of the phases of a run and of the generation of its files, for its summary
+type runTimings struct {
+	resolution time.Duration
+
+	mu           sync.Mutex
+	substitution time.Duration
+	writing      time.Duration
+	files        map[string]time.Duration
+}
+
+// newRunTimings returns the timings of a run whose values were resolved in resolution
+func newRunTimings(resolution time.Duration) *runTimings {
+	return &runTimings{resolution: resolution, files: make(map[string]time.Duration)}
+}
+
+// timeFile adds the durations of the substitution and the writing of out, also set in report unless nil.
+// Streamed files are substituted as they're written, all the time going to writing. Nothing is timed by nil
+// timings.
+func (t *runTimings) timeFile(report *fileReport, out string, substitution time.Duration, writing time.Duration) {
+	if t == nil {
+		return
+	}
+	if report != nil {
+		report.SubstitutionMs, report.WritingMs = fractionalMs(substitution), fractionalMs(writing)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.substitution += substitution
+	t.writing += writing
+	t.files[out] += substitution + writing
+}
+
+// phases returns the timings of the phases for the report
+func (t *runTimings) phases() phaseTimings {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return phaseTimings{ResolutionMs: fractionalMs(t.resolution), SubstitutionMs: fractionalMs(t.substitution), WritingMs: fractionalMs(t.writing)}
+}
+
+// summary returns the summary of a run of total duration: the number of files, the durations of the phases and
+// the slowest files, e.g. to tell slow sources from pathological templates
+func (t *runTimings) summary(total time.Duration) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	files := make([]string, 0, len(t.files))
+	for file := range t.files {
+		files = append(files, file)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if t.files[files[i]] != t.files[files[j]] {
+			return t.files[files[i]] > t.files[files[j]]
+		}
+		return files[i] < files[j]
+	})
+	if len(files) > slowestFiles {
+		files = files[:slowestFiles]
+	}
+	slowest := make([]string, len(files))
+	for i, file := range files {
+		slowest[i] = fmt.Sprintf("%s (%s)", file, roundDuration(t.files[file]))
+	}
+
+	summary := fmt.Sprintf("Generated %d files in %s: resolving values %s, substituting %s, writing %s", len(t.files), roundDuration(total), roundDuration(t.resolution), roundDuration(t.substitution), roundDuration(t.writing))
+	if len(slowest) > 0 {
+		summary += ", slowest: " + strings.Join(slowest, ", ")
+	}
+	return summary
+}
+
+// logSummary logs the summary of a run of total duration to log: like progress, a batch run of many files notes
+// it unless quiet, smaller runs log it at info
+func (t *runTimings) logSummary(log *logger, total time.Duration, quiet bool) {
+	t.mu.Lock()
+	files := len(t.files)
+	t.mu.Unlock()
+	if files >= progressMinFiles && !quiet {
+		log.note("%s", t.summary(total))
+		return
+	}
+	log.infof("%s", t.summary(total))
+}
+
+// roundDuration returns d rounded to the millisecond, or to the microsecond under a millisecond
+func roundDuration(d time.Duration) time.Duration {
+	if d < time.Millisecond {
+		return d.Round(time.Microsecond)
+	}
+	return d.Round(time.Millisecond)
+}
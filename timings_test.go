@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimingsSummary(t *testing.T) {
+	timings := newRunTimings(1500 * time.Millisecond)
+	var report fileReport
+	timings.timeFile(&report, "a.go", 2*time.Millisecond, 3250*time.Microsecond)
+	timings.timeFile(nil, "b.go", 40*time.Millisecond, 10*time.Millisecond)
+	timings.timeFile(nil, "c.go", 0, 250*time.Microsecond)
+	timings.timeFile(nil, "d.go", time.Millisecond, 0)
+
+	if report.SubstitutionMs != 2 || report.WritingMs != 3.25 {
+		t.Errorf("Expected the timings of the file in its report but got %+v", report)
+	}
+	if phases := timings.phases(); phases != (phaseTimings{ResolutionMs: 1500, SubstitutionMs: 43, WritingMs: 13.5}) {
+		t.Errorf("Expected the phases summed over the files but got %+v", phases)
+	}
+	expected := "Generated 4 files in 2s: resolving values 1.5s, substituting 43ms, writing 14ms, slowest: b.go (50ms), a.go (5ms), d.go (1ms)"
+	if summary := timings.summary(2 * time.Second); summary != expected {
+		t.Errorf("Expected summary [%s] but got [%s]", expected, summary)
+	}
+
+	// Generations outside of run aren't timed
+	var untimed *runTimings
+	untimed.timeFile(&report, "a.go", time.Second, time.Second)
+}
+
+func TestTimingsLogged(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "timings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	os.Setenv("TOKEN", "s3cr3t")
+	defer os.Unsetenv("TOKEN")
+	for i := 0; i < progressMinFiles; i++ {
+		template := fmt.Sprintf("package secrets\n\nconst token%d = \"ENV_TOKEN\"\n", i)
+		if err := ioutil.WriteFile(filepath.Join(tempDir, fmt.Sprintf("secrets%d.go", i)+templateExt), []byte(template), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	if err := run(options{paths: []string{tempDir}, stdout: &stdout, stderr: &stderr, report: reportFormatJSON}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stderr.String(), fmt.Sprintf("Generated %d files in ", progressMinFiles)) || !strings.Contains(stderr.String(), ", slowest: ") {
+		t.Errorf("Expected the summary of a batch run whatever the log level but got [%s]", stderr.String())
+	}
+
+	var report runReport
+	if err := json.Unmarshal(stdout.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range report.Files {
+		// Sub-millisecond phases are reported, not rounded down to 0
+		if file.WritingMs == 0 || file.SubstitutionMs+file.WritingMs > float64(file.DurationMs+1) {
+			t.Errorf("Expected the phases of [%s] within its duration but got %+v", file.Template, file)
+		}
+	}
+	if !strings.Contains(stdout.String(), `"phases": {`) || !strings.Contains(stdout.String(), `"resolution_ms": `) {
+		t.Errorf("Expected the phases in the report but got [%s]", stdout.String())
+	}
+
+	// Quiet runs don't print it
+	stderr.Reset()
+	if err := run(options{paths: []string{tempDir}, stdout: &stdout, stderr: &stderr, quiet: true}); err != nil {
+		t.Fatal(err)
+	}
+	if stderr.Len() != 0 {
+		t.Errorf("Expected nothing from a quiet run but got [%s]", stderr.String())
+	}
+}
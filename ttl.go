@@ -0,0 +1,29 @@
+package main
+
+import (
+	"io/ioutil"
+	"time"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+// generatedAt returns the generation time stamped in the header of the file generated to out with --value-ttl:
+// now, except with --check where the one of the file on disk is kept, so that an up-to-date file still within its
+// TTL doesn't differ from the generated one by its time alone
+func (g *generation) generatedAt(out string) time.Time {
+	if g.check && out != stdio {
+		if current, err := ioutil.ReadFile(out); err == nil {
+			if generated, _, ok := safekeeper.ReadTTL(current); ok {
+				return generated
+			}
+		}
+	}
+	return g.now
+}
+
+// expired reports whether the values of current, a generated file, outlived the TTL stamped in its header by
+// --value-ttl. Files without one never expire.
+func (g *generation) expired(current []byte) bool {
+	generated, ttl, ok := safekeeper.ReadTTL(current)
+	return ok && !g.now.Before(generated.Add(ttl))
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValueTTL(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "ttl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "secrets.go"+templateExt), []byte("package secrets\n\nconst token = \"ENV_TOKEN\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("TOKEN", "s3cr3t")
+	defer os.Unsetenv("TOKEN")
+
+	generated := time.Date(2026, 10, 15, 10, 0, 0, 0, time.UTC)
+	at := func(t time.Time) func() time.Time { return func() time.Time { return t } }
+	opts := options{keys: "TOKEN", paths: []string{tempDir}, valueTTL: time.Hour, now: at(generated), quiet: true}
+	if err := run(opts); err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(filepath.Join(tempDir, "secrets.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "// safekeeper:generated=2026-10-15T10:00:00Z ttl=1h0m0s\n") || !strings.Contains(string(content), "--value-ttl=1h0m0s") {
+		t.Fatalf("Expected the generation time and TTL in the header but got:\n%s", content)
+	}
+
+	// Within the TTL, the file is up to date even though it's checked later
+	opts.check, opts.now = true, at(generated.Add(30*time.Minute))
+	if err := run(opts); err != nil {
+		t.Errorf("Expected the file to be up to date within its TTL but got [%v]", err)
+	}
+
+	// Past the TTL, it has to be regenerated
+	opts.now = at(generated.Add(2 * time.Hour))
+	err = run(opts)
+	if err == nil || !strings.Contains(err.Error(), "values expired at 2026-10-15T11:00:00Z") {
+		t.Errorf("Expected the file to be out of date past its TTL but got [%v]", err)
+	}
+
+	opts.check, opts.reproducible = false, true
+	if err := run(opts); err == nil || !strings.Contains(err.Error(), "--value-ttl") {
+		t.Errorf("Expected --value-ttl to be rejected with --reproducible but got [%v]", err)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"go/format"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"strings"
+)
+
+// formatSource returns src formatted with gofmt, failing if it isn't valid Go
+func formatSource(src []byte) ([]byte, error) {
+	formatted, err := format.Source(src)
+	if err != nil {
+		return nil, invalidSourceError(err)
+	}
+	return formatted, nil
+}
+
+// validateSource fails if src isn't valid Go
+func validateSource(src []byte) error {
+	if _, err := parser.ParseFile(token.NewFileSet(), "", src, parser.AllErrors); err != nil {
+		return invalidSourceError(err)
+	}
+	return nil
+}
+
+// invalidSourceError returns the error reporting a generated source that doesn't parse. Only the positions
+// of the syntax errors are reported since their messages can quote the substituted secrets.
+func invalidSourceError(err error) error {
+	list, ok := err.(scanner.ErrorList)
+	if !ok || len(list) == 0 {
+		return withExitCode(exitInvalidOutput, fmt.Errorf("Generated source isn't valid Go: %s", err))
+	}
+
+	positions := make([]string, len(list))
+	for i, e := range list {
+		positions[i] = fmt.Sprintf("%d:%d", e.Pos.Line, e.Pos.Column)
+	}
+	return withExitCode(exitInvalidOutput, fmt.Errorf("Generated source isn't valid Go, syntax errors at %s (a value may need escaping)", strings.Join(positions, ", ")))
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatSource(t *testing.T) {
+	formatted, err := formatSource([]byte("package secrets\nconst  Token =   \"s3cr3t\"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "package secrets\n\nconst Token = \"s3cr3t\"\n"
+	if string(formatted) != expected {
+		t.Errorf("Expected formatted source [%s] but got [%s]", expected, formatted)
+	}
+}
+
+func TestValidateSourceDoesNotLeakValues(t *testing.T) {
+	err := validateSource([]byte("package secrets\n\nconst Token = \"s3\"cr3t\"\n"))
+	if err == nil {
+		t.Fatal("Expected an unescaped quote to be reported")
+	}
+
+	if !strings.Contains(err.Error(), "syntax errors at 3:") || strings.Contains(err.Error(), "cr3t") {
+		t.Errorf("Expected the error to report the position only but got [%s]", err)
+	}
+	if code := exitCode(err); code != exitInvalidOutput {
+		t.Errorf("Expected exit code %d but got %d", exitInvalidOutput, code)
+	}
+}
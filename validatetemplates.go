@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+// validateTemplates checks the structure of the templates of opts without resolving any value nor writing anything: their
+// file settings, the balance of their conditional blocks, their placeholders split over two lines and that they
+// parse. The keys they reference are written to its stdout, sorted, one per line. Every template is checked, the
+// exit code being the one of the class of the first failure.
+func validateTemplates(opts options) error {
+	finder := opts.finder()
+	inputPaths, err := finder.expandPaths(opts.paths)
+	if err != nil {
+		return err
+	}
+	generator, err := opts.generator()
+	if err != nil {
+		return err
+	}
+	stdinTemplate, err := opts.stdinTemplate(inputPaths)
+	if err != nil {
+		return err
+	}
+	outputs, err := finder.outputsOf(inputPaths)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	found := make(map[string]bool)
+	for _, output := range outputs {
+		template := output
+		if output != stdio {
+			template = finder.ext.templateOf(output)
+		}
+		file, err := openTemplateFile(finder.filesystem(), output, finder.ext, stdinTemplate)
+		if err != nil {
+			errs = append(errs, inputError{path: template, err: withExitCode(exitTemplateNotFound, err)})
+			continue
+		}
+		source, err := ioutil.ReadAll(file)
+		file.Close()
+		if err != nil {
+			return err
+		}
+
+		keys, err := validateTemplate(generator, source)
+		if err != nil {
+			errs = append(errs, inputError{path: template, err: err})
+			continue
+		}
+		for _, key := range keys {
+			found[key] = true
+		}
+	}
+
+	keys := make([]string, 0, len(found))
+	for key := range found {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	stdout := opts.stdout
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	for _, key := range keys {
+		fmt.Fprintln(stdout, key)
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	return withExitCode(exitCode(errs[0]), fmt.Errorf("%d templates are invalid:\n%s", len(errs), strings.Join(messages, "\n")))
+}
+
+// validateTemplate returns the keys referenced by the placeholders and the file settings of the template source
+// of generator, failing with the exit code of the class of its first structural error
+func validateTemplate(generator safekeeper.Generator, source []byte) ([]string, error) {
+	settings, err := safekeeper.ReadFileSettings(bytes.NewReader(source))
+	if err != nil {
+		return nil, withExitCode(exitInvalidSettings, err)
+	}
+	// Conditional blocks are the ones of the placeholder engine, Go templates having their own actions
+	if generator.Engine != safekeeper.GoTemplateEngine {
+		if err := safekeeper.CheckBlocks(bytes.NewReader(source)); err != nil {
+			return nil, withExitCode(exitUnbalancedBlocks, err)
+		}
+	}
+	lines, err := generator.SplitPlaceholders(bytes.NewReader(source))
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) > 0 {
+		numbers := make([]string, len(lines))
+		for i, line := range lines {
+			numbers[i] = fmt.Sprint(line)
+		}
+		return nil, withExitCode(exitSplitPlaceholder, fmt.Errorf("Placeholder split over two lines at line %s, it would never be replaced", strings.Join(numbers, ", ")))
+	}
+	keys, err := generator.FindKeys(bytes.NewReader(source))
+	if err != nil {
+		return nil, withExitCode(exitInvalidTemplate, err)
+	}
+	return append(keys, settings.Keys...), nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateTemplates(t *testing.T) {
+	cases := []struct {
+		name     string
+		opts     options
+		template string
+		code     int
+		message  string
+	}{
+		{"clean", options{}, "package secrets\n\n//safekeeper:key SENTRY_DSN optional\n\n//safekeeper:if DEBUG\nconst token = \"ENV_TOKEN\"\n//safekeeper:else\nconst token = \"ENV_HOST:-localhost\"\n//safekeeper:endif\n", 0, ""},
+		{"unbalanced blocks", options{}, "package secrets\n\n//safekeeper:if DEBUG\nconst token = \"ENV_TOKEN\"\n", exitUnbalancedBlocks, "safekeeper:if at line 3 has no safekeeper:endif"},
+		{"endif without if", options{}, "package secrets\n\nconst token = \"ENV_TOKEN\"\n//safekeeper:endif\n", exitUnbalancedBlocks, "safekeeper:endif without safekeeper:if at line 4"},
+		{"condition without a key", options{}, "package secrets\n\n//safekeeper:if\n//safekeeper:endif\n", exitUnbalancedBlocks, "safekeeper:if without a key at line 3"},
+		{"invalid settings", options{}, "package secrets\n\n//safekeeper:key TOKEN always\nconst token = \"ENV_TOKEN\"\n", exitInvalidSettings, "Invalid safekeeper:key at line 3"},
+		{"split placeholder", options{}, "package secrets\n\nconst token = `ENV_\nTOKEN`\n", exitSplitPlaceholder, "Placeholder split over two lines at line 3"},
+		{"split placeholder with suffix", options{prefix: "{{", suffix: "}}"}, "package secrets\n\nconst token = `{{TOKEN\n}}`\n", exitSplitPlaceholder, "Placeholder split over two lines at line 3"},
+		{"invalid go template", options{engine: "gotemplate"}, "package secrets\n\n{{ if .DEBUG }}\nconst token = \"{{ .TOKEN }}\"\n", exitInvalidTemplate, "Invalid template"},
+		{"invalid go source", options{mode: "ast"}, "package secrets\n\nconst token = \"ENV_TOKEN\n", exitInvalidTemplate, ""},
+	}
+
+	for _, c := range cases {
+		tempDir, err := ioutil.TempDir("", "validate")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(tempDir)
+		if err := ioutil.WriteFile(filepath.Join(tempDir, "secrets.go"+templateExt), []byte(c.template), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		var stdout bytes.Buffer
+		c.opts.paths, c.opts.stdout = []string{tempDir}, &stdout
+		err = validateTemplates(c.opts)
+		if code := exitCode(err); code != c.code {
+			t.Errorf("Expected exit code %d for %s but got %d (%v)", c.code, c.name, code, err)
+		}
+		if c.code != 0 && (err == nil || !strings.Contains(err.Error(), "secrets.go"+templateExt+": "+c.message)) {
+			t.Errorf("Expected the error of the template for %s to contain [%s] but got %v", c.name, c.message, err)
+		}
+		if c.code == 0 && stdout.String() != "DEBUG\nHOST\nSENTRY_DSN\nTOKEN\n" {
+			t.Errorf("Expected the keys of the template for %s but got [%s]", c.name, stdout.String())
+		}
+		if _, err := os.Stat(filepath.Join(tempDir, "secrets.go")); !os.IsNotExist(err) {
+			t.Errorf("Expected nothing to be written for %s but got %v", c.name, err)
+		}
+	}
+}
+
+func TestValidateTemplatesFromStdin(t *testing.T) {
+	var stdout bytes.Buffer
+	opts := options{paths: []string{stdio}, stdin: strings.NewReader("token: ENV_TOKEN\n"), stdout: &stdout}
+	if err := validateTemplates(opts); err != nil {
+		t.Fatal(err)
+	}
+	if stdout.String() != "TOKEN\n" {
+		t.Errorf("Expected the keys of the template read from stdin but got [%s]", stdout.String())
+	}
+}
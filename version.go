@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+// develVersion is the version of the builds that don't have one, e.g. go build in a clone of the repository
+const develVersion = "devel"
+
+// buildVersion is the version of safekeeper set at link time with -ldflags "-X main.buildVersion=v1.2.3", taking
+// precedence over the one of the build info
+var buildVersion string
+
+// currentVersion returns the version of safekeeper: the one set at link time, the version of its module when
+// installed with go install or else devel, followed by the VCS revision of the build if known
+func currentVersion() string {
+	if buildVersion != "" {
+		return buildVersion
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return develVersion
+	}
+	return moduleVersion(info)
+}
+
+// moduleVersion returns the version of the main module of info, devel with its VCS revision when it has none
+func moduleVersion(info *debug.BuildInfo) string {
+	if info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" && len(setting.Value) >= 12 {
+			return develVersion + "+" + setting.Value[:12]
+		}
+	}
+	return develVersion
+}
+
+// semver is a parsed major.minor.patch version with its pre-release, if any
+type semver struct {
+	numbers    [3]int
+	prerelease string
+}
+
+// parseVersion parses a semantic version such as v1.2.3, 1.2 or v1.2.3-rc.1, missing numbers being 0. Build
+// metadata (+...) is ignored.
+func parseVersion(version string) (semver, error) {
+	var v semver
+	s := strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i]
+	}
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		s, v.prerelease = s[:i], s[i+1:]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return v, fmt.Errorf("Invalid version [%s]", version)
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("Invalid version [%s]", version)
+		}
+		v.numbers[i] = n
+	}
+	return v, nil
+}
+
+// less returns true if v precedes other, a pre-release preceding its release
+func (v semver) less(other semver) bool {
+	for i := range v.numbers {
+		if v.numbers[i] != other.numbers[i] {
+			return v.numbers[i] < other.numbers[i]
+		}
+	}
+	switch {
+	case v.prerelease == other.prerelease:
+		return false
+	case v.prerelease == "":
+		return false
+	case other.prerelease == "":
+		return true
+	default:
+		return v.prerelease < other.prerelease
+	}
+}
+
+// checkRequiredVersion fails if version, the version of safekeeper, is older than required, the minimum version of
+// the config file (e.g. 1.4.0 or >= v1.4.0), so that files aren't generated differently from one machine to the
+// next. Devel builds can't be compared and pass.
+func checkRequiredVersion(required string, version string) error {
+	if required == "" || version == "" || strings.HasPrefix(version, develVersion) {
+		return nil
+	}
+	minimum, err := parseVersion(strings.TrimPrefix(strings.TrimSpace(required), ">="))
+	if err != nil {
+		return fmt.Errorf("Invalid required-version [%s] in the config file", required)
+	}
+	current, err := parseVersion(version)
+	if err != nil {
+		return err
+	}
+	if current.less(minimum) {
+		return fmt.Errorf("safekeeper %s is older than the required version [%s] of the config file, upgrade it with go install github.com/alexandre-normand/safekeeper@latest", version, required)
+	}
+	return nil
+}
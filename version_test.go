@@ -0,0 +1,102 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"testing"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+func TestModuleVersion(t *testing.T) {
+	for _, test := range []struct {
+		info     debug.BuildInfo
+		expected string
+	}{
+		{debug.BuildInfo{Main: debug.Module{Version: "v1.4.0"}}, "v1.4.0"},
+		{debug.BuildInfo{Main: debug.Module{Version: "(devel)"}}, "devel"},
+		{debug.BuildInfo{Main: debug.Module{Version: "(devel)"}, Settings: []debug.BuildSetting{{Key: "vcs.revision", Value: "0123456789abcdef0123"}}}, "devel+0123456789ab"},
+	} {
+		if version := moduleVersion(&test.info); version != test.expected {
+			t.Errorf("Expected version [%s] but got [%s]", test.expected, version)
+		}
+	}
+}
+
+func TestCheckRequiredVersion(t *testing.T) {
+	for _, test := range []struct {
+		required string
+		version  string
+		ok       bool
+	}{
+		{"", "v1.0.0", true},
+		{"1.4.0", "v1.4.0", true},
+		{">= v1.4", "v1.10.0", true},
+		{"1.4.0", "v1.3.9", false},
+		{"1.4.0", "v1.4.0-rc.1", false},
+		{"1.4.0", "devel+0123456789ab", true},
+		{"2", "v1.99.0", false},
+	} {
+		err := checkRequiredVersion(test.required, test.version)
+		if test.ok && err != nil {
+			t.Errorf("Expected %s to satisfy required version [%s] but got [%s]", test.version, test.required, err)
+		}
+		if !test.ok && err == nil {
+			t.Errorf("Expected %s not to satisfy required version [%s]", test.version, test.required)
+		}
+	}
+
+	if err := checkRequiredVersion("latest", "v1.0.0"); err == nil || !strings.Contains(err.Error(), "Invalid required-version") {
+		t.Errorf("Expected an invalid required version to fail but got [%v]", err)
+	}
+}
+
+func TestVersionHeader(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	generationDriverFile, err := writeGenerationDriverFile(tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writeTestTemplate(tempDir); err != nil {
+		t.Fatal(err)
+	}
+
+	env := safekeeper.LookupMap(map[string]string{"CLIENT_ID": "safeid", "CLIENT_SECRET": "safesecret"})
+	generatedFile := filepath.Join(tempDir, "appsecrets.go")
+	opts := options{keys: "CLIENT_ID,CLIENT_SECRET", output: generatedFile, paths: []string{generationDriverFile}, version: "v1.4.0", lookupEnv: env}
+	if err := run(opts); err != nil {
+		t.Fatal(err)
+	}
+
+	generated, err := ioutil.ReadFile(generatedFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version := safekeeper.ReadVersion(generated); version != "v1.4.0" {
+		t.Fatalf("Expected the version to be recorded in the header but got [%s] in:\n%s", version, generated)
+	}
+
+	opts.check = true
+	if err := run(opts); err != nil {
+		t.Errorf("Expected the generated file to be up to date but got [%v]", err)
+	}
+	opts.version = "v1.5.0"
+	if err := run(opts); exitCode(err) != exitStale || !strings.Contains(err.Error(), generatedFile+" (generated by safekeeper v1.4.0)") {
+		t.Errorf("Expected the file generated by another version to be reported but got [%v]", err)
+	}
+
+	opts.check = false
+	opts.version = "v1.3.0"
+	opts.requiredVersion = "1.4.0"
+	if err := run(opts); err == nil || !strings.Contains(err.Error(), "older than the required version") {
+		t.Errorf("Expected an older safekeeper to fail but got [%v]", err)
+	}
+}
@@ -0,0 +1,440 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+// templateExt is the default extension of safekeeper template files
+const templateExt = ".safekeeper"
+
+// templateExtension is the extension of template files, templateExt if empty. An extension ending with a file
+// extension of its own keeps it in outputs (e.g. secrets.tmpl.go generates secrets.go with .tmpl.go) while
+// other extensions are appended to the output name (secrets.go.safekeeper generates secrets.go).
+type templateExtension string
+
+func (ext templateExtension) String() string {
+	if ext == "" {
+		return templateExt
+	}
+	return string(ext)
+}
+
+// kept returns the part of ext that outputs keep, if any
+func (ext templateExtension) kept() string {
+	if kept := filepath.Ext(ext.String()); kept != ext.String() {
+		return kept
+	}
+	return ""
+}
+
+// isTemplate reports whether path is named as a template
+func (ext templateExtension) isTemplate(path string) bool {
+	return hasPathSuffix(path, ext.String())
+}
+
+// templateOf returns the path of the template of the output path
+func (ext templateExtension) templateOf(path string) string {
+	return strings.TrimSuffix(path, ext.kept()) + ext.String()
+}
+
+// outputOf returns the path of the output of the template path
+func (ext templateExtension) outputOf(template string) string {
+	return trimPathSuffix(template, ext.String()) + ext.kept()
+}
+
+// templateFinder finds the templates of inputs
+type templateFinder struct {
+	ext templateExtension
+	// excludes are the patterns of the paths skipped by walks, on top of the ones of .safekeeperignore files
+	excludes []string
+	// includeSkipped walks the directories skipped by default (vendor, node_modules, testdata and hidden ones)
+	includeSkipped bool
+	// gitignore skips the paths ignored by .gitignore files as well
+	gitignore bool
+	// followSymlinks walks the directories symbolic links point to and finds the templates they point to rather
+	// than skipping them
+	followSymlinks bool
+	// includeHidden walks the hidden directories, skipped by default
+	includeHidden bool
+	// maxDepth is the number of levels of directories walked, e.g. 1 for the files of the walked directory only,
+	// unlimited if 0
+	maxDepth int
+	// maxFileSize is the size of the largest template walks find, unlimited if 0
+	maxFileSize int64
+	// warnings warns of the skipped symbolic links and templates, if not nil
+	warnings *walkWarnings
+	// ctx interrupts walks once done, if not nil
+	ctx context.Context
+	// fsys is the filesystem templates are read from, the OS one if nil. Directories are walked on the OS one.
+	fsys fs.FS
+}
+
+// filesystem returns the filesystem templates are read from
+func (t templateFinder) filesystem() fs.FS {
+	if t.fsys == nil {
+		return osFS{}
+	}
+	return t.fsys
+}
+
+// findTemplates walks dir recursively and returns the paths of all the templates found
+func (t templateFinder) findTemplates(dir string) ([]string, error) {
+	var templates []string
+	err := t.walk(dir, func(path string, info os.FileInfo, err error) error {
+		if !info.IsDir() && t.ext.isTemplate(info.Name()) {
+			templates = append(templates, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return templates, nil
+}
+
+// generateDir generates the source for every template found under dir, each output being written next
+// to its template (e.g. the template path without the .safekeeper extension) or, with an output directory, at
+// the same path relative to it as the template relative to dir
+func (g *generation) generateDir(dir string) error {
+	templates, err := g.finder.findTemplates(dir)
+	if err != nil {
+		return err
+	}
+
+	if len(templates) == 0 {
+		return withExitCode(exitTemplateNotFound, errors.New(fmt.Sprintf("No %s templates found in [%s]", g.finder.ext, dir)))
+	}
+
+	g.progress.add(len(templates))
+	// All the templates are generated, the first error being reported. Binary files named like templates (e.g.
+	// assets) are skipped rather than corrupted by substitutions.
+	errs := forEach(len(templates), func(i int) error {
+		if binary, err := isBinaryFile(templates[i]); err != nil || binary {
+			if binary {
+				g.log.warnf("Skipping binary file [%s]", templates[i])
+				g.report.skip(templates[i], "binary file")
+			}
+			g.progress.step()
+			return err
+		}
+		path := g.finder.ext.outputOf(templates[i])
+		out := ""
+		if g.outputDir != "" {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			out = filepath.Join(g.outputDir, rel)
+		}
+		return g.generateFile(path, g.patternOutput(path, out))
+	})
+	if g.copyOthers {
+		others, err := g.finder.findOthers(dir, templates)
+		if err != nil {
+			return err
+		}
+		g.progress.add(len(others))
+		errs = append(errs, forEach(len(others), func(i int) error {
+			rel, err := filepath.Rel(dir, others[i])
+			if err != nil {
+				return err
+			}
+			return g.copyOther(others[i], filepath.Join(g.outputDir, rel))
+		})...)
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// outputsOf returns the outputs of paths, files (outputs or templates) or directories of templates found by t
+func (t templateFinder) outputsOf(paths []string) ([]string, error) {
+	var outputs []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err == nil && info.IsDir() {
+			dirTemplates, err := t.findTemplates(path)
+			if err != nil {
+				return nil, err
+			}
+			for _, template := range dirTemplates {
+				outputs = append(outputs, t.ext.outputOf(template))
+			}
+			continue
+		}
+		outputs = append(outputs, outputPath(path, t.ext))
+	}
+	return outputs, nil
+}
+
+// scanTemplates returns the sorted keys of the placeholders of generator found in the templates of paths, paths being
+// files or directories of templates found by finder, as well as the keys having a default in all of their
+// placeholders or a condition. The template of - is stdin. Missing templates are skipped, they're reported when generating, as
+// are binary files. Keys declared by the settings of templates are included, the required ones never being defaulted,
+// and the settings are returned by output.
+func scanTemplates(generator *safekeeper.Generator, finder templateFinder, paths []string, stdin []byte) (keys []string, defaulted map[string]bool, settings map[string]safekeeper.FileSettings, err error) {
+	ext := finder.ext
+	files, err := finder.outputsOf(paths)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	defaulted = make(map[string]bool)
+	conditional := make(map[string]bool)
+	required := make(map[string]bool)
+	settings = make(map[string]safekeeper.FileSettings)
+	for _, path := range files {
+		// Binary files are skipped when generating, they have no placeholders
+		if binary, _ := isBinaryFile(ext.templateOf(path)); binary && path != stdio {
+			continue
+		}
+		file, err := openTemplateFile(finder.filesystem(), path, ext, stdin)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		template, err := ioutil.ReadAll(file)
+		file.Close()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		placeholders, err := generator.FindPlaceholders(bytes.NewReader(template))
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		fileSettings, err := safekeeper.ReadFileSettings(bytes.NewReader(template))
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("Invalid settings of the template of [%s]: %s", path, err)
+		}
+		settings[pathKey(path)] = fileSettings
+		for _, key := range fileSettings.Keys {
+			placeholders = append(placeholders, safekeeper.Placeholder{Key: key})
+			required[key] = required[key] || fileSettings.Required[key]
+		}
+		for _, placeholder := range placeholders {
+			conditional[placeholder.Key] = conditional[placeholder.Key] || placeholder.Condition
+			hasDefault, seen := defaulted[placeholder.Key]
+			if !seen {
+				keys = append(keys, placeholder.Key)
+			}
+			defaulted[placeholder.Key] = placeholder.HasDefault && (hasDefault || !seen)
+		}
+	}
+	sort.Strings(keys)
+
+	// Keys of conditions can go without a value, their conditions then not holding
+	for key, hasDefault := range defaulted {
+		if required[key] {
+			delete(defaulted, key)
+		} else if conditional[key] {
+			defaulted[key] = true
+		} else if !hasDefault {
+			delete(defaulted, key)
+		}
+	}
+
+	return keys, defaulted, settings, nil
+}
+
+// hasGlobMeta reports whether path contains any of the glob special characters
+func hasGlobMeta(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// outputPath returns the output of path: path itself or, if path is a template named with ext (rather than the
+// output it generates), its output
+func outputPath(path string, ext templateExtension) string {
+	if path == stdio || !ext.isTemplate(path) {
+		return path
+	}
+	return ext.outputOf(path)
+}
+
+// expandPaths expands the glob patterns in paths. Patterns support the usual filepath.Match syntax as well as
+// ** to match any number of directories. Since patterns select templates, a pattern matches a file if
+// it has a template (e.g. internal/**/*.go matches internal/config/secrets.go when
+// internal/config/secrets.go.safekeeper exists) and matched templates are mapped to their output file.
+// Paths without glob characters are returned as is.
+func (t templateFinder) expandPaths(paths []string) ([]string, error) {
+	ext := t.ext
+	var expanded []string
+	for _, path := range paths {
+		if !hasGlobMeta(path) {
+			expanded = append(expanded, path)
+			continue
+		}
+
+		matches, err := t.glob(path)
+		if err != nil {
+			return nil, err
+		}
+
+		templateMatches, err := t.glob(ext.templateOf(path))
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, templateMatches...)
+
+		found := false
+		seen := make(map[string]bool)
+		sort.Strings(matches)
+		for _, match := range matches {
+			if ext.isTemplate(match) {
+				match = ext.outputOf(match)
+			}
+			if seen[match] {
+				continue
+			}
+			if info, err := os.Stat(ext.templateOf(match)); err == nil && !info.IsDir() {
+				seen[match] = true
+				found = true
+				expanded = append(expanded, match)
+			}
+		}
+
+		if !found {
+			return nil, withExitCode(exitTemplateNotFound, errors.New(fmt.Sprintf("No templates match pattern [%s]", path)))
+		}
+	}
+
+	return expanded, nil
+}
+
+// glob returns the names of all files matching pattern, in lexical order. The walks of ** patterns skip
+// excluded paths.
+func (t templateFinder) glob(pattern string) ([]string, error) {
+	if !strings.Contains(pattern, "**") {
+		return filepath.Glob(pattern)
+	}
+
+	patternSegments := strings.Split(filepath.ToSlash(pattern), "/")
+	root := baseDir(patternSegments)
+
+	var matches []string
+	err := t.walk(root, func(path string, info os.FileInfo, err error) error {
+		if info.IsDir() {
+			return nil
+		}
+
+		matched, err := matchSegments(patternSegments, strings.Split(filepath.ToSlash(path), "/"))
+		if err != nil {
+			return err
+		}
+		if matched {
+			matches = append(matches, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return matches, nil
+}
+
+// baseDir returns the directory made of the leading pattern segments without glob characters, the place to
+// start walking from
+func baseDir(patternSegments []string) string {
+	var literal []string
+	for _, segment := range patternSegments {
+		if hasGlobMeta(segment) {
+			break
+		}
+		literal = append(literal, segment)
+	}
+
+	if len(literal) == 0 {
+		return "."
+	}
+	if len(literal) == 1 && literal[0] == "" {
+		return "/"
+	}
+	if len(literal) == 1 && volumeRoot(literal[0]) != "" {
+		return volumeRoot(literal[0])
+	}
+	return filepath.FromSlash(strings.Join(literal, "/"))
+}
+
+// matchSegments reports whether the path segments match the pattern segments, ** matching zero or more
+// path segments
+func matchSegments(patternSegments []string, pathSegments []string) (bool, error) {
+	for len(patternSegments) > 0 {
+		if patternSegments[0] == "**" {
+			for i := 0; i <= len(pathSegments); i++ {
+				matched, err := matchSegments(patternSegments[1:], pathSegments[i:])
+				if err != nil || matched {
+					return matched, err
+				}
+			}
+			return false, nil
+		}
+
+		if len(pathSegments) == 0 {
+			return false, nil
+		}
+
+		matched, err := matchName(patternSegments[0], pathSegments[0])
+		if err != nil || !matched {
+			return false, err
+		}
+		patternSegments, pathSegments = patternSegments[1:], pathSegments[1:]
+	}
+
+	return len(pathSegments) == 0, nil
+}
+
+// fileSizeUnits are the suffixes of the sizes of --max-file-size, longest first
+var fileSizeUnits = []struct {
+	suffix string
+	size   int64
+}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1}}
+
+// parseFileSize parses size, a number of bytes optionally followed by B, KB, MB or GB (powers of 1024, regardless
+// of case), e.g. 512KB. It returns 0 if size is empty.
+func parseFileSize(size string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(size))
+	if s == "" {
+		return 0, nil
+	}
+	unit := int64(1)
+	for _, u := range fileSizeUnits {
+		if strings.HasSuffix(s, u.suffix) {
+			s, unit = strings.TrimSpace(strings.TrimSuffix(s, u.suffix)), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("Invalid --max-file-size [%s], expected a number of bytes such as 65536, 512KB or 1MB", size)
+	}
+	return n * unit, nil
+}
+
+// checkWalkLimits fails if the limits of the walks of opts are invalid
+func checkWalkLimits(opts options) error {
+	if opts.maxDepth < 0 {
+		return fmt.Errorf("Invalid --max-depth [%d], expected a positive number of levels", opts.maxDepth)
+	}
+	_, err := parseFileSize(opts.maxFileSize)
+	return err
+}
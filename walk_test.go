@@ -0,0 +1,291 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDirectoryInput(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	nestedDir := filepath.Join(tempDir, "internal", "config")
+	if err := os.MkdirAll(nestedDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dir := range []string{tempDir, nestedDir} {
+		if _, err := writeTestTemplate(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	os.Setenv("CLIENT_ID", "safeid")
+	os.Setenv("CLIENT_SECRET", "safesecret")
+
+	err = run(options{keys: "CLIENT_ID,CLIENT_SECRET", paths: []string{tempDir}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dir := range []string{tempDir, nestedDir} {
+		output, err := ioutil.ReadFile(filepath.Join(dir, "secrets.go"))
+		if err != nil {
+			t.Fatalf("Expected generated file next to the template in [%s] but got [%s]", dir, err)
+		}
+
+		expectedClientIdLine := "appSecrets.ClientId = \"safeid\""
+		if !strings.Contains(string(output), expectedClientIdLine) {
+			t.Errorf("Generated file in [%s] should contain [%s] but was: \n\n%s", dir, expectedClientIdLine, string(output))
+		}
+	}
+}
+
+func TestDirectoryInputWithoutTemplates(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	os.Setenv("CLIENT_ID", "safeid")
+	err = run(options{keys: "CLIENT_ID", paths: []string{tempDir}})
+	if err == nil || !strings.Contains(err.Error(), "No .safekeeper templates found") {
+		t.Fatalf("Error should mention that no templates were found but was [%v]", err)
+	}
+}
+
+func TestDirectoryInputRejectsOutput(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	os.Setenv("CLIENT_ID", "safeid")
+	err = run(options{keys: "CLIENT_ID", output: filepath.Join(tempDir, "out.go"), paths: []string{tempDir}})
+	if err == nil || !strings.Contains(err.Error(), "--output") {
+		t.Fatalf("Error should mention that --output isn't supported with directories but was [%v]", err)
+	}
+}
+
+func TestGlobInput(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	matchingDirs := []string{filepath.Join(tempDir, "internal"), filepath.Join(tempDir, "internal", "deep", "config")}
+	otherDir := filepath.Join(tempDir, "cmd")
+	for _, dir := range append(matchingDirs, otherDir) {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := writeTestTemplate(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A go file without template matching the pattern should be left alone
+	plainFile := filepath.Join(tempDir, "internal", "plain.go")
+	if err := ioutil.WriteFile(plainFile, []byte("package internal\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("CLIENT_ID", "safeid")
+	os.Setenv("CLIENT_SECRET", "safesecret")
+
+	err = run(options{keys: "CLIENT_ID,CLIENT_SECRET", paths: []string{filepath.Join(tempDir, "internal", "**", "*.go")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dir := range matchingDirs {
+		if _, err := os.Stat(filepath.Join(dir, "secrets.go")); err != nil {
+			t.Errorf("Expected template in [%s] to be generated but got [%s]", dir, err)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(otherDir, "secrets.go")); !os.IsNotExist(err) {
+		t.Errorf("Template in [%s] doesn't match the pattern and shouldn't have been generated", otherDir)
+	}
+
+	plain, err := ioutil.ReadFile(plainFile)
+	if err != nil || string(plain) != "package internal\n" {
+		t.Errorf("File without template should be untouched but was [%s] (%v)", string(plain), err)
+	}
+}
+
+func TestGlobInputWithoutMatches(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	pattern := filepath.Join(tempDir, "**", "*.go")
+	err = run(options{keys: "CLIENT_ID", paths: []string{pattern}})
+	if err == nil || !strings.Contains(err.Error(), "No templates match pattern") {
+		t.Fatalf("Error should mention that nothing matched the pattern but was [%v]", err)
+	}
+}
+
+func TestMatchSegments(t *testing.T) {
+	cases := []struct {
+		pattern string
+		path    string
+		matched bool
+	}{
+		{"a/**/*.go", "a/b.go", true},
+		{"a/**/*.go", "a/b/c/d.go", true},
+		{"a/**/*.go", "b/c.go", false},
+		{"**/secrets.go", "x/y/secrets.go", true},
+		{"a/*/c.go", "a/b/b/c.go", false},
+	}
+
+	for _, c := range cases {
+		matched, err := matchSegments(strings.Split(c.pattern, "/"), strings.Split(c.path, "/"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if matched != c.matched {
+			t.Errorf("Expected match of [%s] against [%s] to be %t", c.path, c.pattern, c.matched)
+		}
+	}
+}
+
+func TestTemplateExtension(t *testing.T) {
+	cases := []struct {
+		ext      templateExtension
+		output   string
+		template string
+	}{
+		{"", "secrets.go", "secrets.go.safekeeper"},
+		{".tmpl", "secrets.go", "secrets.go.tmpl"},
+		{".tmpl.go", "secrets.go", "secrets.tmpl.go"},
+	}
+
+	for _, c := range cases {
+		if template := c.ext.templateOf(c.output); template != c.template {
+			t.Errorf("Expected template [%s] for [%s] with extension [%s] but got [%s]", c.template, c.output, c.ext, template)
+		}
+		if output := c.ext.outputOf(c.template); output != c.output {
+			t.Errorf("Expected output [%s] for [%s] with extension [%s] but got [%s]", c.output, c.template, c.ext, output)
+		}
+	}
+}
+
+func TestCustomTemplateExtension(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	nestedDir := filepath.Join(tempDir, "config")
+	if err := os.MkdirAll(nestedDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, template := range []string{filepath.Join(tempDir, "secrets.tmpl.go"), filepath.Join(nestedDir, "config.tmpl.go")} {
+		if err := ioutil.WriteFile(template, []byte("package secrets\n\nconst clientId = \"ENV_CLIENT_ID\"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	os.Setenv("CLIENT_ID", "safeid")
+	defer os.Unsetenv("CLIENT_ID")
+
+	// Templates are found by scanning directories as well as given directly
+	for _, path := range []string{nestedDir, filepath.Join(tempDir, "secrets.tmpl.go")} {
+		if err := run(options{paths: []string{path}, templateExt: ".tmpl.go"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, generated := range []string{filepath.Join(tempDir, "secrets.go"), filepath.Join(nestedDir, "config.go")} {
+		output, err := ioutil.ReadFile(generated)
+		if err != nil {
+			t.Fatalf("Expected generated file [%s] but got [%s]", generated, err)
+		}
+		if !strings.Contains(string(output), "clientId = \"safeid\"") {
+			t.Errorf("Generated file [%s] should have the value of CLIENT_ID but was: \n\n%s", generated, string(output))
+		}
+		if !strings.Contains(string(output), "--template-ext=.tmpl.go") {
+			t.Errorf("Generated file [%s] should be regenerated with its template extension but was: \n\n%s", generated, string(output))
+		}
+	}
+}
+
+func TestOutputDir(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	templatesDir := filepath.Join(tempDir, "templates")
+	for _, dir := range []string{templatesDir, filepath.Join(templatesDir, "db")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := writeTestTemplate(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	os.Setenv("CLIENT_ID", "safeid")
+	os.Setenv("CLIENT_SECRET", "safesecret")
+	defer os.Unsetenv("CLIENT_ID")
+	defer os.Unsetenv("CLIENT_SECRET")
+
+	outputDir := filepath.Join(tempDir, "internal", "config")
+	if err := run(options{paths: []string{templatesDir}, outputDir: outputDir}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, generated := range []string{filepath.Join(outputDir, "secrets.go"), filepath.Join(outputDir, "db", "secrets.go")} {
+		output, err := ioutil.ReadFile(generated)
+		if err != nil {
+			t.Fatalf("Expected generated file [%s] but got [%s]", generated, err)
+		}
+		if strings.Contains(string(output), "go:generate") {
+			t.Errorf("Generated file [%s] shouldn't have a go:generate line since its template is elsewhere:\n\n%s", generated, string(output))
+		}
+	}
+	if _, err := os.Stat(filepath.Join(templatesDir, "secrets.go")); !os.IsNotExist(err) {
+		t.Errorf("Nothing should be generated next to the templates with an output directory")
+	}
+}
+
+func TestConditionKeysWithoutValue(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "conditional")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	template := "package secrets\n\n//safekeeper:if ANALYTICS_TOKEN\nconst analyticsToken = \"ENV_ANALYTICS_TOKEN\"\n\n//safekeeper:endif\nconst clientID = \"ENV_CLIENT_ID\"\n"
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "secrets.go"+templateExt), []byte(template), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("CLIENT_ID", "safeid")
+	defer os.Unsetenv("CLIENT_ID")
+
+	if err := run(options{paths: []string{tempDir}}); err != nil {
+		t.Fatal(err)
+	}
+	output, err := ioutil.ReadFile(filepath.Join(tempDir, "secrets.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(output), "analyticsToken") || !strings.Contains(string(output), "const clientID = \"safeid\"") {
+		t.Errorf("Expected the block of the key without a value to be left out but got:\n\n%s", string(output))
+	}
+}
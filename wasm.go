@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// wasmMemoryPages bounds the memory of WebAssembly transforms, in pages of 64KiB
+const wasmMemoryPages = 256
+
+// wasmTransform is a transform implemented by a WebAssembly module, run sandboxed: modules can import WASI but
+// get no filesystem, network, environment or arguments. A module exports its memory, alloc(size i32) i32
+// returning where the value of size bytes is written, and transform(ptr i32, len i32) i64 returning the
+// transformed value as ptr<<32|len, trapping on failure. Each value goes through a new instance of the module so
+// that no value outlives its transform.
+type wasmTransform struct {
+	name string
+	path string
+	ctx  context.Context
+
+	once     sync.Once
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+	err      error
+}
+
+func (t *wasmTransform) transform(value string) (string, error) {
+	t.once.Do(func() {
+		t.err = t.compile()
+	})
+	if t.err != nil {
+		return "", t.err
+	}
+
+	module, err := t.runtime.InstantiateModule(t.ctx, t.compiled, wazero.NewModuleConfig().WithName(""))
+	if err != nil {
+		return "", fmt.Errorf("Error instantiating WebAssembly transform [%s]: %s", t.name, err)
+	}
+	defer module.Close(t.ctx)
+
+	alloc, transform, memory := module.ExportedFunction("alloc"), module.ExportedFunction("transform"), module.Memory()
+	if alloc == nil || transform == nil || memory == nil {
+		return "", fmt.Errorf("WebAssembly transform [%s] doesn't export memory, alloc and transform", t.name)
+	}
+	results, err := alloc.Call(t.ctx, uint64(len(value)))
+	if err != nil {
+		return "", fmt.Errorf("WebAssembly transform [%s] failed: %s", t.name, err)
+	}
+	ptr := uint32(results[0])
+	if !memory.Write(ptr, []byte(value)) {
+		return "", fmt.Errorf("WebAssembly transform [%s] allocated out of its memory", t.name)
+	}
+	if results, err = transform.Call(t.ctx, uint64(ptr), uint64(len(value))); err != nil {
+		return "", fmt.Errorf("WebAssembly transform [%s] failed: %s", t.name, err)
+	}
+	transformed, ok := memory.Read(uint32(results[0]>>32), uint32(results[0]))
+	if !ok {
+		return "", fmt.Errorf("WebAssembly transform [%s] returned a value out of its memory", t.name)
+	}
+	return string(transformed), nil
+}
+
+// compile compiles the module of t, once for all its values
+func (t *wasmTransform) compile() error {
+	binary, err := ioutil.ReadFile(t.path)
+	if err != nil {
+		return fmt.Errorf("Error reading WebAssembly transform [%s]: %s", t.name, err)
+	}
+
+	t.runtime = wazero.NewRuntimeWithConfig(t.ctx, wazero.NewRuntimeConfig().WithMemoryLimitPages(wasmMemoryPages).WithCloseOnContextDone(true))
+	context.AfterFunc(t.ctx, t.close)
+	if _, err := wasi_snapshot_preview1.Instantiate(t.ctx, t.runtime); err != nil {
+		return err
+	}
+	if t.compiled, err = t.runtime.CompileModule(t.ctx, binary); err != nil {
+		return fmt.Errorf("Invalid WebAssembly transform [%s]: %s", t.name, err)
+	}
+	return nil
+}
+
+// close releases the runtime of t, if compiled. Values can't go through t afterwards.
+func (t *wasmTransform) close() {
+	t.once.Do(func() {
+		t.err = fmt.Errorf("WebAssembly transform [%s] is closed", t.name)
+	})
+	if t.runtime != nil {
+		t.runtime.Close(context.Background())
+	}
+}
+
+// wasmTransforms returns the transforms of the WebAssembly modules of opts, by name, for the generator of opts.
+// Modules are compiled the first time a value goes through them, in a runtime closed once the context of opts is
+// done, at the end of its run.
+func wasmTransforms(opts options) map[string]safekeeper.TransformFunc {
+	transforms := make(map[string]safekeeper.TransformFunc, len(opts.wasmModules))
+	for name, path := range opts.wasmModules {
+		transforms[name] = (&wasmTransform{name: name, path: path, ctx: opts.context()}).transform
+	}
+	return transforms
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexandre-normand/safekeeper/pkg/safekeeper"
+)
+
+// upperModule is a WebAssembly module uppercasing ASCII letters in place:
+//
+//	(module
+//	  (memory (export "memory") 1)
+//	  (func (export "alloc") (param i32) (result i32) i32.const 1024)
+//	  (func (export "transform") (param $ptr i32) (param $len i32) (result i64) (local $i i32) (local $c i32)
+//	    (block $done (loop $next
+//	      (br_if $done (i32.ge_u (local.get $i) (local.get $len)))
+//	      (local.set $c (i32.load8_u (i32.add (local.get $ptr) (local.get $i))))
+//	      (if (i32.lt_u (i32.sub (local.get $c) (i32.const 97)) (i32.const 26))
+//	        (then (i32.store8 (i32.add (local.get $ptr) (local.get $i)) (i32.sub (local.get $c) (i32.const 32)))))
+//	      (local.set $i (i32.add (local.get $i) (i32.const 1)))
+//	      (br $next)))
+//	    (i64.or (i64.shl (i64.extend_i32_u (local.get $ptr)) (i64.const 32)) (i64.extend_i32_u (local.get $len)))))
+const upperModule = "0061736d01000000010c0260017f017f60027f7f017e03030200010503010001071e03066d656d6f7279020005616c6c6f630000097472616e73666f726d00010a4f0205004180080b4701027f02400340200220014f0d01200020026a2d00002103200341e1006b411a490440200020026a200341206b3a00000b200241016a21020c000b0b2000ad4220862001ad840b"
+
+func TestGenerateWithWasmTransform(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	module, err := hex.DecodeString(upperModule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	modulePath := filepath.Join(tempDir, "upper.wasm")
+	if err := ioutil.WriteFile(modulePath, module, 0644); err != nil {
+		t.Fatal(err)
+	}
+	templateFile := filepath.Join(tempDir, "secrets.go.safekeeper")
+	if err := ioutil.WriteFile(templateFile, []byte("package secrets\n\nconst clientID = \"ENV_CLIENT_ID|wasm-upper\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	env := safekeeper.LookupMap(map[string]string{"CLIENT_ID": "safe-id"})
+	if err := run(options{paths: []string{templateFile}, wasmModules: map[string]string{"wasm-upper": modulePath}, noHeader: true, lookupEnv: env}); err != nil {
+		t.Fatal(err)
+	}
+	generated, err := ioutil.ReadFile(filepath.Join(tempDir, "secrets.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(generated), "const clientID = \"SAFE-ID\"") {
+		t.Errorf("Expected the value to go through the WebAssembly transform but got [%s]", string(generated))
+	}
+
+	broken := &wasmTransform{name: "broken", path: templateFile, ctx: (options{}).context()}
+	if _, err := broken.transform("value"); err == nil || !strings.Contains(err.Error(), "Invalid WebAssembly transform [broken]") {
+		t.Errorf("Expected a file that isn't a module to be rejected but got [%v]", err)
+	}
+}
+
+func TestWasmTransformsClosedWithTheirRun(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	module, err := hex.DecodeString(upperModule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	modulePath := filepath.Join(tempDir, "upper.wasm")
+	if err := ioutil.WriteFile(modulePath, module, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts, cancel := options{wasmModules: map[string]string{"wasm-upper": modulePath}}.withContext()
+	transform := wasmTransforms(opts)["wasm-upper"]
+	if value, err := transform("safe-id"); err != nil || value != "SAFE-ID" {
+		t.Fatalf("Expected the value to go through the WebAssembly transform but got [%s] (err: %v)", value, err)
+	}
+
+	// The runtime is closed once the run is over, e.g. before the next run of watch
+	cancel()
+	deadline := time.Now().Add(5 * time.Second)
+	for _, err = transform("safe-id"); err == nil && time.Now().Before(deadline); _, err = transform("safe-id") {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err == nil {
+		t.Error("Expected the runtime of the transform to be closed with its run")
+	}
+
+	// Other runs get their own
+	if value, err := wasmTransforms(options{wasmModules: opts.wasmModules})["wasm-upper"]("safe-id"); err != nil || value != "SAFE-ID" {
+		t.Errorf("Expected the transform of another run to be open but got [%s] (err: %v)", value, err)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is how long watch waits for changes to settle before regenerating, editors often writing a file
+// in several steps
+const watchDebounce = 100 * time.Millisecond
+
+// watch generates the sources of opts and then regenerates them whenever a template, env file or secrets file
+// changes, until done is closed. Generation failures are logged without stopping the watch.
+func watch(opts options, done <-chan struct{}) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	dirs, err := watchDirs(opts)
+	if err != nil {
+		return err
+	}
+	for _, dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			return err
+		}
+	}
+
+	watchedFiles := make(map[string]bool)
+	for _, path := range append(append([]string(nil), opts.envFiles...), opts.secretsFiles...) {
+		watchedFiles[pathKey(path)] = true
+	}
+
+	// Progress is reported unless asked otherwise, watching being interactive
+	if opts.logLevel == "" {
+		opts.logLevel = "info"
+	}
+	log, err := opts.logger()
+	if err != nil {
+		return err
+	}
+	regenerate := func() {
+		if err := run(opts); err != nil {
+			log.errorf("%s", err)
+			return
+		}
+		log.infof("Generated sources are up to date")
+	}
+	regenerate()
+
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-done:
+			return nil
+		case event := <-watcher.Events:
+			// Directories created under a watched one are watched as well, fsnotify not being recursive
+			if event.Op&fsnotify.Create == fsnotify.Create {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := watcher.Add(event.Name); err != nil {
+						log.errorf("%s", err)
+					}
+					continue
+				}
+			}
+			if event.Op&fsnotify.Chmod == event.Op {
+				continue
+			}
+			if opts.finder().ext.isTemplate(event.Name) || watchedFiles[pathKey(event.Name)] {
+				debounce = time.After(watchDebounce)
+			}
+		case err := <-watcher.Errors:
+			log.errorf("%s", err)
+		case <-debounce:
+			debounce = nil
+			regenerate()
+		}
+	}
+}
+
+// watchDirs returns the directories to watch for the inputs of opts: directory inputs and all their
+// subdirectories, the directories of file inputs and the ones of the env and secrets files
+func watchDirs(opts options) ([]string, error) {
+	finder := opts.finder()
+	inputPaths, err := finder.expandPaths(opts.paths)
+	if err != nil {
+		return nil, err
+	}
+
+	var dirs []string
+	seen := make(map[string]bool)
+	add := func(dir string) {
+		dir = filepath.Clean(dir)
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+
+	for _, path := range inputPaths {
+		if path == stdio {
+			return nil, errors.New("stdin can't be watched")
+		}
+
+		info, err := os.Stat(path)
+		if err != nil || !info.IsDir() {
+			add(filepath.Dir(path))
+			continue
+		}
+
+		err = finder.walk(path, func(path string, info os.FileInfo, err error) error {
+			if info.IsDir() {
+				add(path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, path := range append(append([]string(nil), opts.envFiles...), opts.secretsFiles...) {
+		add(filepath.Dir(path))
+	}
+
+	return dirs, nil
+}
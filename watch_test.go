@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWatchDirs(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	templatesDir := filepath.Join(tempDir, "templates")
+	nestedDir := filepath.Join(templatesDir, "nested")
+	packageDir := filepath.Join(tempDir, "secrets")
+	for _, dir := range []string{nestedDir, packageDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dirs, err := watchDirs(options{
+		paths:    []string{templatesDir, filepath.Join(packageDir, "secrets.go")},
+		envFiles: []string{filepath.Join(tempDir, ".env")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{templatesDir, nestedDir, packageDir, tempDir}
+	if !reflect.DeepEqual(dirs, expected) {
+		t.Errorf("Expected watched directories %v but got %v", expected, dirs)
+	}
+}
+
+func TestWatchStdinRejected(t *testing.T) {
+	if _, err := watchDirs(options{paths: []string{"-"}}); err == nil {
+		t.Error("Expected stdin to be rejected as a watched input")
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// which writes the templates of opts that reference key to its stdout, with the file each one generates, so
+// that the files to regenerate when the key changes are known
+func which(opts options, key string) error {
+	usages, err := keyUsages(opts)
+	if err != nil {
+		return err
+	}
+
+	stdout := opts.stdout
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	for _, usage := range usages {
+		if usage.Key != key {
+			continue
+		}
+		for _, template := range usage.Templates {
+			if _, err := fmt.Fprintf(stdout, "%s -> %s\n", template, opts.generatedFileOf(template)); err != nil {
+				return withExitCode(exitWriteFailure, err)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("Key [%s] isn't used by any template", key)
+}
+
+// generatedFileOf returns the file generated from template: the output of opts for it, in its output directory
+// or next to it, named according to the output pattern if any
+func (opts options) generatedFileOf(template string) string {
+	if template == stdio {
+		return stdio
+	}
+	output := opts.finder().ext.outputOf(template)
+	if absPath, err := filepath.Abs(output); err == nil && opts.outputs[pathKey(absPath)] != "" {
+		return opts.outputs[pathKey(absPath)]
+	}
+	if opts.outputDir != "" {
+		output = filepath.Join(opts.outputDir, filepath.Base(output))
+	}
+	return applyOutputPattern(opts.outputPattern, output)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWhich(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "which")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	for name, template := range map[string]string{"a.go.safekeeper": "ENV_STRIPE_KEY", "b.go.safekeeper": "ENV_TOKEN"} {
+		if err := ioutil.WriteFile(filepath.Join(tempDir, name), []byte(template), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var stdout bytes.Buffer
+	if err := which(options{paths: []string{tempDir}, stdout: &stdout}, "STRIPE_KEY"); err != nil {
+		t.Fatal(err)
+	}
+	expected := filepath.Join(tempDir, "a.go.safekeeper") + " -> " + filepath.Join(tempDir, "a.go") + "\n"
+	if stdout.String() != expected {
+		t.Errorf("Expected [%s] but got [%s]", expected, stdout.String())
+	}
+
+	if err := which(options{paths: []string{tempDir}, stdout: &stdout}, "UNUSED"); err == nil {
+		t.Errorf("Expected an error for an unused key")
+	}
+}